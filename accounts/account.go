@@ -0,0 +1,185 @@
+package accounts
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/brsuite/broln/channeldb"
+	"github.com/brsuite/broln/lntypes"
+	"github.com/brsuite/broln/lnwire"
+	"github.com/brsuite/broln/macaroons"
+	"gopkg.in/macaroon-bakery.v2/bakery/checkers"
+	macaroon "gopkg.in/macaroon.v2"
+)
+
+const (
+	// AccountIDLen is the length of the ID of an account.
+	AccountIDLen = 8
+
+	// CondAccount is the name of the macaroon caveat condition that binds
+	// a macaroon to an off-chain account.
+	CondAccount = "account"
+)
+
+var (
+	// ErrAccountNotFound is returned if an account with the given ID
+	// doesn't exist.
+	ErrAccountNotFound = errors.New("account not found")
+
+	// ErrInsufficientBalance is returned if a payment exceeds the
+	// available balance of an account.
+	ErrInsufficientBalance = errors.New("insufficient account balance")
+
+	// ErrAlreadyAssociated is returned if an invoice or payment is already
+	// associated with an account.
+	ErrAlreadyAssociated = errors.New("payment hash already associated " +
+		"with an account")
+)
+
+// AccountID is the unique identifier of an off-chain account.
+type AccountID [AccountIDLen]byte
+
+// String returns the hex encoded account ID.
+func (a AccountID) String() string {
+	return hex.EncodeToString(a[:])
+}
+
+// ParseAccountID parses a hex encoded account ID.
+func ParseAccountID(idStr string) (AccountID, error) {
+	var id AccountID
+
+	idBytes, err := hex.DecodeString(idStr)
+	if err != nil {
+		return id, fmt.Errorf("invalid account ID %q: %v", idStr, err)
+	}
+	if len(idBytes) != AccountIDLen {
+		return id, fmt.Errorf("invalid account ID length %d, expected "+
+			"%d", len(idBytes), AccountIDLen)
+	}
+	copy(id[:], idBytes)
+
+	return id, nil
+}
+
+// PaymentEntry is a payment made with the macaroon of an account.
+type PaymentEntry struct {
+	// Status is the status of the payment. Only in-flight and succeeded
+	// payments are kept, failed payments are removed from the account.
+	Status channeldb.PaymentStatus
+
+	// FullAmount is the amount of the payment including fees. While the
+	// payment is in flight, it's the maximum amount the payment may cost,
+	// which is reserved from the balance of the account.
+	FullAmount lnwire.MilliSatoshi
+}
+
+// OffChainBalanceAccount is a virtual account with an off-chain balance. The
+// balance is credited when invoices created with the macaroon bound to the
+// account are paid, and debited by the payments made with the macaroon.
+type OffChainBalanceAccount struct {
+	// ID is the unique identifier of the account.
+	ID AccountID
+
+	// InitialBalance is the balance the account was created with.
+	InitialBalance lnwire.MilliSatoshi
+
+	// CurrentBalance is the current balance of the account in
+	// millisatoshis. It doesn't take the amounts reserved for in-flight
+	// payments into account. It becomes negative if a payment ends up
+	// costing more than the remaining balance, in which case the account
+	// can't make payments until the debt is paid off by invoices.
+	CurrentBalance int64
+
+	// LastUpdate is the time the account was last updated.
+	LastUpdate time.Time
+
+	// Label is an optional label of the account.
+	Label string
+
+	// Invoices is the set of payment hashes of the invoices created with
+	// the macaroon of the account.
+	Invoices map[lntypes.Hash]struct{}
+
+	// Payments holds the in-flight and succeeded payments made with the
+	// macaroon of the account, keyed by their payment hash.
+	Payments map[lntypes.Hash]*PaymentEntry
+}
+
+// AvailableBalance returns the balance of the account that can still be spent,
+// which is its current balance minus the amounts reserved for its in-flight
+// payments.
+func (a *OffChainBalanceAccount) AvailableBalance() lnwire.MilliSatoshi {
+	var reserved lnwire.MilliSatoshi
+	for _, entry := range a.Payments {
+		if entry.Status == channeldb.StatusInFlight {
+			reserved += entry.FullAmount
+		}
+	}
+
+	available := a.CurrentBalance - int64(reserved)
+	if available <= 0 {
+		return 0
+	}
+
+	return lnwire.MilliSatoshi(available)
+}
+
+// AccountConstraint binds a macaroon to the account with the given ID.
+func AccountConstraint(id AccountID) macaroons.Constraint {
+	return func(mac *macaroon.Macaroon) error {
+		caveat := checkers.Condition(CondAccount, id.String())
+		return mac.AddFirstPartyCaveat([]byte(caveat))
+	}
+}
+
+// AccountChecker returns the Checker of the account caveat condition. It only
+// validates the format of the account ID, as the account restrictions are
+// enforced by the RPC interceptor.
+func AccountChecker() (string, checkers.Func) {
+	return CondAccount, func(_ context.Context, _, arg string) error {
+		_, err := ParseAccountID(arg)
+		return err
+	}
+}
+
+// AccountIDFromMacaroon returns the ID of the account the given macaroon is
+// bound to. False is returned if the macaroon isn't bound to any account. As
+// a macaroon can't be bound to several accounts at once, an error is returned
+// if it carries account caveats with different IDs.
+func AccountIDFromMacaroon(mac *macaroon.Macaroon) (AccountID, bool, error) {
+	var (
+		id    AccountID
+		found bool
+	)
+	if mac == nil {
+		return id, false, nil
+	}
+
+	for _, caveat := range mac.Caveats() {
+		// Third party caveats can't bind a macaroon to an account.
+		if len(caveat.VerificationId) != 0 {
+			continue
+		}
+
+		cond, arg, err := checkers.ParseCaveat(string(caveat.Id))
+		if err != nil || cond != CondAccount {
+			continue
+		}
+
+		caveatID, err := ParseAccountID(arg)
+		if err != nil {
+			return id, false, err
+		}
+
+		if found && caveatID != id {
+			return id, false, fmt.Errorf("macaroon is bound to " +
+				"multiple accounts")
+		}
+		id, found = caveatID, true
+	}
+
+	return id, found, nil
+}
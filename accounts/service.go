@@ -0,0 +1,470 @@
+package accounts
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/brsuite/broln/channeldb"
+	"github.com/brsuite/broln/invoices"
+	"github.com/brsuite/broln/lntypes"
+	"github.com/brsuite/broln/lnwire"
+	"github.com/brsuite/broln/routing"
+)
+
+var (
+	// ErrServiceShuttingDown is returned when the service is asked to do
+	// something while shutting down.
+	ErrServiceShuttingDown = errors.New("account service shutting down")
+)
+
+// Config houses the dependencies of the account Service.
+type Config struct {
+	// Store is the store the accounts are persisted in.
+	Store *Store
+
+	// SubscribeInvoices subscribes to the invoices added to and settled by
+	// the invoice registry after the given indexes.
+	SubscribeInvoices func(addIndex,
+		settleIndex uint64) (*invoices.InvoiceSubscription, error)
+
+	// FetchPayment fetches the payment with the given payment hash from
+	// the control tower.
+	FetchPayment func(lntypes.Hash) (*channeldb.MPPayment, error)
+
+	// SubscribePayment subscribes to the updates of the payment with the
+	// given payment hash.
+	SubscribePayment func(lntypes.Hash) (*routing.ControlTowerSubscriber,
+		error)
+}
+
+// Service keeps the balances of off-chain accounts up to date. It credits an
+// account when one of the invoices associated with it is settled, and debits
+// it when one of its payments succeeds. While a payment is in flight, its
+// maximum cost is reserved from the account balance.
+type Service struct {
+	started sync.Once
+	stopped sync.Once
+
+	cfg *Config
+
+	// invoiceToAccount maps the payment hashes of the invoices associated
+	// with an account to the ID of that account.
+	invoiceToAccount map[lntypes.Hash]AccountID
+
+	// mu serializes all account updates, so that the balance checks and
+	// updates of concurrent payments are atomic.
+	mu sync.Mutex
+
+	wg   sync.WaitGroup
+	quit chan struct{}
+}
+
+// NewService creates a new account Service from the passed config.
+func NewService(cfg *Config) *Service {
+	return &Service{
+		cfg:              cfg,
+		invoiceToAccount: make(map[lntypes.Hash]AccountID),
+		quit:             make(chan struct{}),
+	}
+}
+
+// Start resumes tracking the in-flight payments of all accounts and
+// subscribes to the invoices settled since the service was last running.
+func (s *Service) Start() error {
+	var err error
+	s.started.Do(func() {
+		log.Info("Account service starting")
+		err = s.start()
+	})
+
+	return err
+}
+
+// start loads the accounts and launches the goroutines of the service.
+func (s *Service) start() error {
+	accounts, err := s.cfg.Store.Accounts()
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	for _, account := range accounts {
+		for hash := range account.Invoices {
+			s.invoiceToAccount[hash] = account.ID
+		}
+	}
+	s.mu.Unlock()
+
+	for _, account := range accounts {
+		for hash, entry := range account.Payments {
+			if entry.Status != channeldb.StatusInFlight {
+				continue
+			}
+
+			if err := s.TrackPayment(account.ID, hash); err != nil {
+				return err
+			}
+		}
+	}
+
+	settleIndex, err := s.cfg.Store.LastSettleIndex()
+	if err != nil {
+		return err
+	}
+
+	subscription, err := s.cfg.SubscribeInvoices(0, settleIndex)
+	if err != nil {
+		return err
+	}
+
+	s.wg.Add(1)
+	go s.invoiceHandler(subscription)
+
+	return nil
+}
+
+// Stop signals the Service for a graceful shutdown.
+func (s *Service) Stop() error {
+	s.stopped.Do(func() {
+		log.Info("Account service shutting down")
+
+		close(s.quit)
+		s.wg.Wait()
+	})
+
+	return nil
+}
+
+// NewAccount creates a new account with the given balance and label.
+func (s *Service) NewAccount(balance lnwire.MilliSatoshi,
+	label string) (*OffChainBalanceAccount, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	account, err := s.cfg.Store.NewAccount(balance, label)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Infof("Created account %v with balance %v", account.ID, balance)
+
+	return account, nil
+}
+
+// Account returns the account with the given ID.
+func (s *Service) Account(id AccountID) (*OffChainBalanceAccount, error) {
+	return s.cfg.Store.Account(id)
+}
+
+// Accounts returns all accounts.
+func (s *Service) Accounts() ([]*OffChainBalanceAccount, error) {
+	return s.cfg.Store.Accounts()
+}
+
+// RemoveAccount removes the account with the given ID. Macaroons bound to the
+// account can't be used anymore afterwards.
+func (s *Service) RemoveAccount(id AccountID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	account, err := s.cfg.Store.Account(id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.cfg.Store.RemoveAccount(id); err != nil {
+		return err
+	}
+
+	for hash := range account.Invoices {
+		delete(s.invoiceToAccount, hash)
+	}
+
+	log.Infof("Removed account %v", id)
+
+	return nil
+}
+
+// AssociateInvoice associates the invoice with the given payment hash with an
+// account, so the account is credited once the invoice is settled.
+func (s *Service) AssociateInvoice(id AccountID, hash lntypes.Hash) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.invoiceToAccount[hash]; ok {
+		return ErrAlreadyAssociated
+	}
+
+	account, err := s.cfg.Store.Account(id)
+	if err != nil {
+		return err
+	}
+
+	account.Invoices[hash] = struct{}{}
+	if err := s.cfg.Store.UpdateAccount(account); err != nil {
+		return err
+	}
+
+	s.invoiceToAccount[hash] = id
+
+	return nil
+}
+
+// CheckBalance returns an error if the available balance of an account is
+// less than the given amount.
+func (s *Service) CheckBalance(id AccountID, amt lnwire.MilliSatoshi) error {
+	account, err := s.cfg.Store.Account(id)
+	if err != nil {
+		return err
+	}
+
+	if account.AvailableBalance() < amt {
+		return ErrInsufficientBalance
+	}
+
+	return nil
+}
+
+// AssociatePayment associates the payment with the given payment hash with an
+// account, reserving its maximum cost from the account balance. An error is
+// returned if the available balance of the account doesn't cover the
+// payment. Once the payment is initiated, it must be tracked with
+// TrackPayment.
+func (s *Service) AssociatePayment(id AccountID, hash lntypes.Hash,
+	fullAmt lnwire.MilliSatoshi) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	account, err := s.cfg.Store.Account(id)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := account.Payments[hash]; ok {
+		return ErrAlreadyAssociated
+	}
+
+	// A payment hash can only be reused once all previous payments to it
+	// failed. We refuse to associate a payment that already exists, as
+	// the account would otherwise be debited for a payment it didn't make.
+	payment, err := s.cfg.FetchPayment(hash)
+	switch {
+	case err == channeldb.ErrPaymentNotInitiated:
+
+	case err != nil:
+		return err
+
+	case payment.Status != channeldb.StatusFailed:
+		return fmt.Errorf("payment with hash %v already initiated",
+			hash)
+	}
+
+	if account.AvailableBalance() < fullAmt {
+		return ErrInsufficientBalance
+	}
+
+	account.Payments[hash] = &PaymentEntry{
+		Status:     channeldb.StatusInFlight,
+		FullAmount: fullAmt,
+	}
+
+	return s.cfg.Store.UpdateAccount(account)
+}
+
+// TrackPayment tracks a payment previously associated with an account until
+// it completes, debiting the account if it succeeds and releasing the reserved
+// amount if it fails. If the payment was never initiated, the reserved amount
+// is released immediately.
+func (s *Service) TrackPayment(id AccountID, hash lntypes.Hash) error {
+	subscription, err := s.cfg.SubscribePayment(hash)
+	if err == channeldb.ErrPaymentNotInitiated {
+		return s.removePayment(id, hash)
+	}
+	if err != nil {
+		return err
+	}
+
+	s.wg.Add(1)
+	go s.paymentTracker(id, hash, subscription)
+
+	return nil
+}
+
+// paymentTracker waits for the payment with the given hash to complete and
+// updates its account accordingly.
+//
+// NOTE: This MUST be run as a goroutine.
+func (s *Service) paymentTracker(id AccountID, hash lntypes.Hash,
+	subscription *routing.ControlTowerSubscriber) {
+
+	defer s.wg.Done()
+	defer subscription.Close()
+
+	for {
+		select {
+		case update, ok := <-subscription.Updates:
+			if !ok {
+				return
+			}
+
+			payment, ok := update.(*channeldb.MPPayment)
+			if !ok {
+				continue
+			}
+
+			var err error
+			switch payment.Status {
+			case channeldb.StatusSucceeded:
+				sent, fees := payment.SentAmt()
+				err = s.debitPayment(id, hash, sent+fees)
+
+			case channeldb.StatusFailed:
+				err = s.removePayment(id, hash)
+
+			default:
+				continue
+			}
+			if err != nil {
+				log.Errorf("Unable to update payment %v of "+
+					"account %v: %v", hash, id, err)
+			}
+
+			return
+
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// debitPayment debits the full amount of a succeeded payment from its
+// account.
+func (s *Service) debitPayment(id AccountID, hash lntypes.Hash,
+	fullAmt lnwire.MilliSatoshi) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	account, err := s.cfg.Store.Account(id)
+	if err != nil {
+		return err
+	}
+
+	// The reserved amount covers the maximum cost of the payment, so the
+	// balance can only become negative if the payment ended up exceeding
+	// its fee limit. We still debit the full amount, so that the overrun
+	// is paid off by the next credits of the account.
+	account.CurrentBalance -= int64(fullAmt)
+	if account.CurrentBalance < 0 {
+		log.Warnf("Payment %v of %v exceeds balance of account %v, "+
+			"new balance is %d mSAT", hash, fullAmt, id,
+			account.CurrentBalance)
+	}
+
+	account.Payments[hash] = &PaymentEntry{
+		Status:     channeldb.StatusSucceeded,
+		FullAmount: fullAmt,
+	}
+
+	log.Debugf("Debited %v from account %v for payment %v", fullAmt, id,
+		hash)
+
+	return s.cfg.Store.UpdateAccount(account)
+}
+
+// removePayment removes a payment that failed, or was never initiated, from
+// its account, releasing the amount reserved for it.
+func (s *Service) removePayment(id AccountID, hash lntypes.Hash) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	account, err := s.cfg.Store.Account(id)
+	if err != nil {
+		return err
+	}
+
+	delete(account.Payments, hash)
+
+	return s.cfg.Store.UpdateAccount(account)
+}
+
+// invoiceHandler credits the accounts of the invoices that are settled.
+//
+// NOTE: This MUST be run as a goroutine.
+func (s *Service) invoiceHandler(
+	subscription *invoices.InvoiceSubscription) {
+
+	defer s.wg.Done()
+	defer subscription.Cancel()
+
+	for {
+		select {
+		// We're only interested in settled invoices, but still need to
+		// consume the new ones.
+		case <-subscription.NewInvoices:
+
+		case invoice, ok := <-subscription.SettledInvoices:
+			if !ok {
+				return
+			}
+
+			if err := s.handleSettledInvoice(invoice); err != nil {
+				log.Errorf("Unable to credit settled invoice: "+
+					"%v", err)
+			}
+
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// handleSettledInvoice credits the amount paid to a settled invoice to the
+// account it is associated with, if any.
+func (s *Service) handleSettledInvoice(invoice *channeldb.Invoice) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if invoice.Terms.PaymentPreimage != nil {
+		hash := invoice.Terms.PaymentPreimage.Hash()
+
+		id, ok := s.invoiceToAccount[hash]
+		if ok {
+			err := s.creditInvoice(id, hash, invoice.AmtPaid)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return s.cfg.Store.StoreLastSettleIndex(invoice.SettleIndex)
+}
+
+// creditInvoice credits the amount paid to an invoice to its account.
+//
+// NOTE: This method MUST be called with the service's mutex held.
+func (s *Service) creditInvoice(id AccountID, hash lntypes.Hash,
+	amtPaid lnwire.MilliSatoshi) error {
+
+	account, err := s.cfg.Store.Account(id)
+
+	// If the account was removed in the meantime, there's nothing to
+	// credit.
+	if err == ErrAccountNotFound {
+		delete(s.invoiceToAccount, hash)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	account.CurrentBalance += int64(amtPaid)
+
+	log.Debugf("Credited %v to account %v for invoice %v", amtPaid, id,
+		hash)
+
+	return s.cfg.Store.UpdateAccount(account)
+}
@@ -0,0 +1,210 @@
+package accounts
+
+import (
+	"testing"
+	"time"
+
+	"github.com/brsuite/brond/btcec"
+	"github.com/brsuite/broln/channeldb"
+	"github.com/brsuite/broln/lntypes"
+	"github.com/brsuite/broln/routing"
+	"github.com/brsuite/broln/routing/route"
+	"github.com/stretchr/testify/require"
+)
+
+// TestServicePayments asserts that the maximum cost of a payment is reserved
+// from the balance of its account while in flight, and that the account is
+// debited the actual cost once the payment succeeds.
+func TestServicePayments(t *testing.T) {
+	t.Parallel()
+
+	db, cleanUp, err := channeldb.MakeTestDB()
+	require.NoError(t, err)
+	t.Cleanup(cleanUp)
+
+	store, err := NewStore(db)
+	require.NoError(t, err)
+
+	controlTower := routing.NewControlTower(channeldb.NewPaymentControl(db))
+	svc := NewService(&Config{
+		Store:            store,
+		FetchPayment:     controlTower.FetchPayment,
+		SubscribePayment: controlTower.SubscribePayment,
+	})
+	t.Cleanup(func() {
+		require.NoError(t, svc.Stop())
+	})
+
+	account, err := svc.NewAccount(20000, "")
+	require.NoError(t, err)
+
+	preimage := lntypes.Preimage{1}
+	hash := preimage.Hash()
+
+	// A payment that exceeds the balance of the account is rejected.
+	err = svc.AssociatePayment(account.ID, hash, 20001)
+	require.ErrorIs(t, err, ErrInsufficientBalance)
+
+	// The maximum cost of an accepted payment is reserved.
+	require.NoError(t, svc.AssociatePayment(account.ID, hash, 12000))
+	require.ErrorIs(t, svc.CheckBalance(account.ID, 8001),
+		ErrInsufficientBalance)
+	require.NoError(t, svc.CheckBalance(account.ID, 8000))
+
+	// The same payment can't be associated twice.
+	err = svc.AssociatePayment(account.ID, hash, 1000)
+	require.ErrorIs(t, err, ErrAlreadyAssociated)
+
+	// Initiate the payment, and settle it with a fee of 1000 msat.
+	err = controlTower.InitPayment(hash, &channeldb.PaymentCreationInfo{
+		PaymentIdentifier: hash,
+		Value:             10000,
+		CreationTime:      time.Unix(time.Now().Unix(), 0),
+	})
+	require.NoError(t, err)
+	require.NoError(t, svc.TrackPayment(account.ID, hash))
+
+	priv, err := btcec.NewPrivateKey(btcec.S256())
+	require.NoError(t, err)
+	hop := &route.Hop{
+		PubKeyBytes:      route.NewVertex(priv.PubKey()),
+		ChannelID:        12345,
+		OutgoingTimeLock: 111,
+		AmtToForward:     10000,
+		LegacyPayload:    true,
+	}
+	attempt := channeldb.NewHtlcAttemptInfo(1, priv, route.Route{
+		TotalTimeLock: 123,
+		TotalAmount:   11000,
+		SourcePubKey:  route.NewVertex(priv.PubKey()),
+		Hops:          []*route.Hop{hop, hop},
+	}, time.Time{}, nil)
+	require.NoError(t, controlTower.RegisterAttempt(hash, attempt))
+
+	_, err = controlTower.SettleAttempt(
+		hash, attempt.AttemptID, &channeldb.HTLCSettleInfo{
+			Preimage: preimage,
+		},
+	)
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		account, err := svc.Account(account.ID)
+		require.NoError(t, err)
+
+		entry := account.Payments[hash]
+		return entry.Status == channeldb.StatusSucceeded
+	}, 5*time.Second, 10*time.Millisecond)
+
+	account, err = svc.Account(account.ID)
+	require.NoError(t, err)
+	require.EqualValues(t, 9000, account.CurrentBalance)
+	require.EqualValues(t, 9000, account.AvailableBalance())
+	require.EqualValues(t, 11000, account.Payments[hash].FullAmount)
+
+	// A payment that already succeeded can't be associated with another
+	// account.
+	otherAccount, err := svc.NewAccount(20000, "")
+	require.NoError(t, err)
+	err = svc.AssociatePayment(otherAccount.ID, hash, 12000)
+	require.Error(t, err)
+
+	// The amount reserved for a payment that is never initiated is
+	// released once it is tracked.
+	unknownHash := lntypes.Hash{2}
+	err = svc.AssociatePayment(otherAccount.ID, unknownHash, 12000)
+	require.NoError(t, err)
+	require.NoError(t, svc.TrackPayment(otherAccount.ID, unknownHash))
+
+	otherAccount, err = svc.Account(otherAccount.ID)
+	require.NoError(t, err)
+	require.Empty(t, otherAccount.Payments)
+	require.EqualValues(t, 20000, otherAccount.AvailableBalance())
+}
+
+// TestServiceInvoices asserts that an account is credited the amount paid to
+// the invoices associated with it.
+func TestServiceInvoices(t *testing.T) {
+	t.Parallel()
+
+	store := newTestStore(t)
+	svc := NewService(&Config{
+		Store: store,
+	})
+
+	account, err := svc.NewAccount(1000, "")
+	require.NoError(t, err)
+
+	preimage := lntypes.Preimage{1}
+	hash := preimage.Hash()
+	require.NoError(t, svc.AssociateInvoice(account.ID, hash))
+
+	// An invoice can only be associated with a single account.
+	require.ErrorIs(
+		t, svc.AssociateInvoice(account.ID, hash), ErrAlreadyAssociated,
+	)
+
+	// Settling an invoice that isn't associated with any account only
+	// advances the settle index.
+	otherPreimage := lntypes.Preimage{2}
+	err = svc.handleSettledInvoice(&channeldb.Invoice{
+		Terms: channeldb.ContractTerm{
+			PaymentPreimage: &otherPreimage,
+		},
+		SettleIndex: 1,
+		AmtPaid:     5000,
+	})
+	require.NoError(t, err)
+
+	err = svc.handleSettledInvoice(&channeldb.Invoice{
+		Terms: channeldb.ContractTerm{
+			PaymentPreimage: &preimage,
+		},
+		SettleIndex: 2,
+		AmtPaid:     3000,
+	})
+	require.NoError(t, err)
+
+	account, err = svc.Account(account.ID)
+	require.NoError(t, err)
+	require.EqualValues(t, 4000, account.CurrentBalance)
+
+	index, err := store.LastSettleIndex()
+	require.NoError(t, err)
+	require.EqualValues(t, 2, index)
+}
+
+// TestServiceDebitOverrun asserts that a payment that costs more than the
+// balance of its account leaves the account with a negative balance, which is
+// paid off by later credits.
+func TestServiceDebitOverrun(t *testing.T) {
+	t.Parallel()
+
+	store := newTestStore(t)
+	svc := NewService(&Config{
+		Store: store,
+	})
+
+	account, err := svc.NewAccount(1000, "")
+	require.NoError(t, err)
+
+	require.NoError(t, svc.debitPayment(account.ID, lntypes.Hash{1}, 1500))
+
+	account, err = svc.Account(account.ID)
+	require.NoError(t, err)
+	require.EqualValues(t, -500, account.CurrentBalance)
+	require.Zero(t, account.AvailableBalance())
+	require.ErrorIs(
+		t, svc.CheckBalance(account.ID, 1), ErrInsufficientBalance,
+	)
+
+	svc.mu.Lock()
+	err = svc.creditInvoice(account.ID, lntypes.Hash{2}, 800)
+	svc.mu.Unlock()
+	require.NoError(t, err)
+
+	account, err = svc.Account(account.ID)
+	require.NoError(t, err)
+	require.EqualValues(t, 300, account.CurrentBalance)
+	require.EqualValues(t, 300, account.AvailableBalance())
+}
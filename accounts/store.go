@@ -0,0 +1,419 @@
+package accounts
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/brsuite/brond/wire"
+	"github.com/brsuite/broln/channeldb"
+	"github.com/brsuite/broln/kvdb"
+	"github.com/brsuite/broln/lntypes"
+	"github.com/brsuite/broln/lnwire"
+)
+
+const (
+	// maxLabelLen is the maximum length of the label of an account.
+	maxLabelLen = 1024
+)
+
+var (
+	// accountsBucketName is the name of the top level bucket of the
+	// account store.
+	accountsBucketName = []byte("accounts")
+
+	// accountBucketName is the name of the bucket within the accounts
+	// bucket that holds the serialized accounts, keyed by their ID.
+	accountBucketName = []byte("account")
+
+	// lastSettleIndexKey is the key within the accounts bucket that holds
+	// the settle index of the last invoice processed by the service.
+	lastSettleIndexKey = []byte("last-settle-index")
+
+	// byteOrder is the byte order accounts are serialized with.
+	byteOrder = binary.BigEndian
+)
+
+// Store persists off-chain accounts in a kvdb backend.
+type Store struct {
+	db kvdb.Backend
+}
+
+// NewStore creates a new account store in the given backend, creating its
+// buckets if they don't exist yet.
+func NewStore(db kvdb.Backend) (*Store, error) {
+	err := kvdb.Update(db, func(tx kvdb.RwTx) error {
+		accounts, err := tx.CreateTopLevelBucket(accountsBucketName)
+		if err != nil {
+			return err
+		}
+
+		_, err = accounts.CreateBucketIfNotExists(accountBucketName)
+		return err
+	}, func() {})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Store{db: db}, nil
+}
+
+// NewAccount creates and stores a new account with the given balance and
+// label, and a random ID.
+func (s *Store) NewAccount(balance lnwire.MilliSatoshi,
+	label string) (*OffChainBalanceAccount, error) {
+
+	if len(label) > maxLabelLen {
+		return nil, fmt.Errorf("label exceeds maximum length of %d",
+			maxLabelLen)
+	}
+
+	account := &OffChainBalanceAccount{
+		InitialBalance: balance,
+		CurrentBalance: int64(balance),
+		LastUpdate:     time.Now(),
+		Label:          label,
+		Invoices:       make(map[lntypes.Hash]struct{}),
+		Payments:       make(map[lntypes.Hash]*PaymentEntry),
+	}
+
+	err := kvdb.Update(s.db, func(tx kvdb.RwTx) error {
+		bucket, err := accountBucket(tx)
+		if err != nil {
+			return err
+		}
+
+		// Pick a random ID that isn't used by any other account yet.
+		for {
+			if _, err := rand.Read(account.ID[:]); err != nil {
+				return err
+			}
+
+			if bucket.Get(account.ID[:]) == nil {
+				break
+			}
+		}
+
+		return putAccount(bucket, account)
+	}, func() {})
+	if err != nil {
+		return nil, err
+	}
+
+	return account, nil
+}
+
+// UpdateAccount writes the given account to the store. The account must
+// already exist.
+func (s *Store) UpdateAccount(account *OffChainBalanceAccount) error {
+	return kvdb.Update(s.db, func(tx kvdb.RwTx) error {
+		bucket, err := accountBucket(tx)
+		if err != nil {
+			return err
+		}
+
+		if bucket.Get(account.ID[:]) == nil {
+			return ErrAccountNotFound
+		}
+
+		account.LastUpdate = time.Now()
+
+		return putAccount(bucket, account)
+	}, func() {})
+}
+
+// Account returns the account with the given ID.
+func (s *Store) Account(id AccountID) (*OffChainBalanceAccount, error) {
+	var account *OffChainBalanceAccount
+	err := kvdb.View(s.db, func(tx kvdb.RTx) error {
+		bucket, err := accountReadBucket(tx)
+		if err != nil {
+			return err
+		}
+
+		accountBytes := bucket.Get(id[:])
+		if accountBytes == nil {
+			return ErrAccountNotFound
+		}
+
+		account, err = deserializeAccount(bytes.NewReader(accountBytes))
+		return err
+	}, func() {
+		account = nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return account, nil
+}
+
+// Accounts returns all accounts in the store.
+func (s *Store) Accounts() ([]*OffChainBalanceAccount, error) {
+	var accounts []*OffChainBalanceAccount
+	err := kvdb.View(s.db, func(tx kvdb.RTx) error {
+		bucket, err := accountReadBucket(tx)
+		if err != nil {
+			return err
+		}
+
+		return bucket.ForEach(func(_, accountBytes []byte) error {
+			account, err := deserializeAccount(
+				bytes.NewReader(accountBytes),
+			)
+			if err != nil {
+				return err
+			}
+
+			accounts = append(accounts, account)
+			return nil
+		})
+	}, func() {
+		accounts = nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return accounts, nil
+}
+
+// RemoveAccount removes the account with the given ID from the store.
+func (s *Store) RemoveAccount(id AccountID) error {
+	return kvdb.Update(s.db, func(tx kvdb.RwTx) error {
+		bucket, err := accountBucket(tx)
+		if err != nil {
+			return err
+		}
+
+		if bucket.Get(id[:]) == nil {
+			return ErrAccountNotFound
+		}
+
+		return bucket.Delete(id[:])
+	}, func() {})
+}
+
+// LastSettleIndex returns the settle index of the last invoice processed by
+// the account service, or zero if no invoice was processed yet.
+func (s *Store) LastSettleIndex() (uint64, error) {
+	var index uint64
+	err := kvdb.View(s.db, func(tx kvdb.RTx) error {
+		accounts := tx.ReadBucket(accountsBucketName)
+		if accounts == nil {
+			return fmt.Errorf("accounts bucket not found")
+		}
+
+		indexBytes := accounts.Get(lastSettleIndexKey)
+		if indexBytes != nil {
+			index = byteOrder.Uint64(indexBytes)
+		}
+
+		return nil
+	}, func() {
+		index = 0
+	})
+
+	return index, err
+}
+
+// StoreLastSettleIndex stores the settle index of the last invoice processed
+// by the account service.
+func (s *Store) StoreLastSettleIndex(index uint64) error {
+	return kvdb.Update(s.db, func(tx kvdb.RwTx) error {
+		accounts := tx.ReadWriteBucket(accountsBucketName)
+		if accounts == nil {
+			return fmt.Errorf("accounts bucket not found")
+		}
+
+		var indexBytes [8]byte
+		byteOrder.PutUint64(indexBytes[:], index)
+
+		return accounts.Put(lastSettleIndexKey, indexBytes[:])
+	}, func() {})
+}
+
+// accountBucket returns the bucket holding the serialized accounts.
+func accountBucket(tx kvdb.RwTx) (kvdb.RwBucket, error) {
+	accounts := tx.ReadWriteBucket(accountsBucketName)
+	if accounts == nil {
+		return nil, fmt.Errorf("accounts bucket not found")
+	}
+
+	bucket := accounts.NestedReadWriteBucket(accountBucketName)
+	if bucket == nil {
+		return nil, fmt.Errorf("account bucket not found")
+	}
+
+	return bucket, nil
+}
+
+// accountReadBucket returns the bucket holding the serialized accounts for
+// reading.
+func accountReadBucket(tx kvdb.RTx) (kvdb.RBucket, error) {
+	accounts := tx.ReadBucket(accountsBucketName)
+	if accounts == nil {
+		return nil, fmt.Errorf("accounts bucket not found")
+	}
+
+	bucket := accounts.NestedReadBucket(accountBucketName)
+	if bucket == nil {
+		return nil, fmt.Errorf("account bucket not found")
+	}
+
+	return bucket, nil
+}
+
+// putAccount serializes the given account into the given bucket.
+func putAccount(bucket kvdb.RwBucket, account *OffChainBalanceAccount) error {
+	var b bytes.Buffer
+	if err := serializeAccount(&b, account); err != nil {
+		return err
+	}
+
+	return bucket.Put(account.ID[:], b.Bytes())
+}
+
+// serializeAccount writes the serialized account to the given writer.
+func serializeAccount(w io.Writer, account *OffChainBalanceAccount) error {
+	err := binary.Write(w, byteOrder, account.ID)
+	if err != nil {
+		return err
+	}
+
+	err = binary.Write(w, byteOrder, uint64(account.InitialBalance))
+	if err != nil {
+		return err
+	}
+
+	err = binary.Write(w, byteOrder, account.CurrentBalance)
+	if err != nil {
+		return err
+	}
+
+	err = binary.Write(w, byteOrder, account.LastUpdate.UnixNano())
+	if err != nil {
+		return err
+	}
+
+	err = wire.WriteVarBytes(w, 0, []byte(account.Label))
+	if err != nil {
+		return err
+	}
+
+	err = binary.Write(w, byteOrder, uint32(len(account.Invoices)))
+	if err != nil {
+		return err
+	}
+	for hash := range account.Invoices {
+		if _, err := w.Write(hash[:]); err != nil {
+			return err
+		}
+	}
+
+	err = binary.Write(w, byteOrder, uint32(len(account.Payments)))
+	if err != nil {
+		return err
+	}
+	for hash, entry := range account.Payments {
+		if _, err := w.Write(hash[:]); err != nil {
+			return err
+		}
+
+		err := binary.Write(w, byteOrder, byte(entry.Status))
+		if err != nil {
+			return err
+		}
+
+		err = binary.Write(w, byteOrder, uint64(entry.FullAmount))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deserializeAccount reads a serialized account from the given reader.
+func deserializeAccount(r io.Reader) (*OffChainBalanceAccount, error) {
+	account := &OffChainBalanceAccount{
+		Invoices: make(map[lntypes.Hash]struct{}),
+		Payments: make(map[lntypes.Hash]*PaymentEntry),
+	}
+
+	if _, err := io.ReadFull(r, account.ID[:]); err != nil {
+		return nil, err
+	}
+
+	var (
+		initialBalance uint64
+		currentBalance int64
+	)
+	err := binary.Read(r, byteOrder, &initialBalance)
+	if err != nil {
+		return nil, err
+	}
+	account.InitialBalance = lnwire.MilliSatoshi(initialBalance)
+
+	err = binary.Read(r, byteOrder, &currentBalance)
+	if err != nil {
+		return nil, err
+	}
+	account.CurrentBalance = currentBalance
+
+	var lastUpdate int64
+	if err := binary.Read(r, byteOrder, &lastUpdate); err != nil {
+		return nil, err
+	}
+	account.LastUpdate = time.Unix(0, lastUpdate)
+
+	label, err := wire.ReadVarBytes(r, 0, maxLabelLen, "label")
+	if err != nil {
+		return nil, err
+	}
+	account.Label = string(label)
+
+	var numInvoices uint32
+	if err := binary.Read(r, byteOrder, &numInvoices); err != nil {
+		return nil, err
+	}
+	for i := uint32(0); i < numInvoices; i++ {
+		var hash lntypes.Hash
+		if _, err := io.ReadFull(r, hash[:]); err != nil {
+			return nil, err
+		}
+		account.Invoices[hash] = struct{}{}
+	}
+
+	var numPayments uint32
+	if err := binary.Read(r, byteOrder, &numPayments); err != nil {
+		return nil, err
+	}
+	for i := uint32(0); i < numPayments; i++ {
+		var (
+			hash       lntypes.Hash
+			status     byte
+			fullAmount uint64
+		)
+		if _, err := io.ReadFull(r, hash[:]); err != nil {
+			return nil, err
+		}
+		if err := binary.Read(r, byteOrder, &status); err != nil {
+			return nil, err
+		}
+		err := binary.Read(r, byteOrder, &fullAmount)
+		if err != nil {
+			return nil, err
+		}
+
+		account.Payments[hash] = &PaymentEntry{
+			Status:     channeldb.PaymentStatus(status),
+			FullAmount: lnwire.MilliSatoshi(fullAmount),
+		}
+	}
+
+	return account, nil
+}
@@ -0,0 +1,90 @@
+package accounts
+
+import (
+	"testing"
+
+	"github.com/brsuite/broln/channeldb"
+	"github.com/brsuite/broln/lntypes"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestStore creates a new account store backed by a temporary database.
+func newTestStore(t *testing.T) *Store {
+	db, cleanUp, err := channeldb.MakeTestDB()
+	require.NoError(t, err)
+	t.Cleanup(cleanUp)
+
+	store, err := NewStore(db)
+	require.NoError(t, err)
+
+	return store
+}
+
+// TestAccountStore asserts that accounts are persisted, updated and removed
+// by the account store.
+func TestAccountStore(t *testing.T) {
+	t.Parallel()
+
+	store := newTestStore(t)
+
+	account, err := store.NewAccount(50000, "tenant")
+	require.NoError(t, err)
+
+	_, err = store.NewAccount(1000, "")
+	require.NoError(t, err)
+
+	// Update the account with an invoice and payments, and make sure it
+	// is read back unchanged.
+	account.CurrentBalance = 40000
+	account.Invoices[lntypes.Hash{1}] = struct{}{}
+	account.Payments[lntypes.Hash{2}] = &PaymentEntry{
+		Status:     channeldb.StatusSucceeded,
+		FullAmount: 10000,
+	}
+	account.Payments[lntypes.Hash{3}] = &PaymentEntry{
+		Status:     channeldb.StatusInFlight,
+		FullAmount: 5000,
+	}
+	require.NoError(t, store.UpdateAccount(account))
+
+	dbAccount, err := store.Account(account.ID)
+	require.NoError(t, err)
+	require.Equal(t, account.ID, dbAccount.ID)
+	require.Equal(t, account.InitialBalance, dbAccount.InitialBalance)
+	require.Equal(t, account.CurrentBalance, dbAccount.CurrentBalance)
+	require.Equal(t, account.Label, dbAccount.Label)
+	require.Equal(t, account.Invoices, dbAccount.Invoices)
+	require.Equal(t, account.Payments, dbAccount.Payments)
+	require.Equal(
+		t, account.LastUpdate.UnixNano(),
+		dbAccount.LastUpdate.UnixNano(),
+	)
+
+	// The amount of the in-flight payment is reserved.
+	require.EqualValues(t, 35000, dbAccount.AvailableBalance())
+
+	accounts, err := store.Accounts()
+	require.NoError(t, err)
+	require.Len(t, accounts, 2)
+
+	// Once removed, the account can't be found or updated anymore.
+	require.NoError(t, store.RemoveAccount(account.ID))
+	_, err = store.Account(account.ID)
+	require.ErrorIs(t, err, ErrAccountNotFound)
+	require.ErrorIs(t, store.UpdateAccount(account), ErrAccountNotFound)
+	require.ErrorIs(t, store.RemoveAccount(account.ID), ErrAccountNotFound)
+
+	accounts, err = store.Accounts()
+	require.NoError(t, err)
+	require.Len(t, accounts, 1)
+
+	// Finally, the settle index of the last processed invoice is stored.
+	index, err := store.LastSettleIndex()
+	require.NoError(t, err)
+	require.Zero(t, index)
+
+	require.NoError(t, store.StoreLastSettleIndex(42))
+	index, err = store.LastSettleIndex()
+	require.NoError(t, err)
+	require.EqualValues(t, 42, index)
+}
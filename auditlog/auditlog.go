@@ -0,0 +1,563 @@
+package auditlog
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/brsuite/broln/clock"
+)
+
+const (
+	// DefaultFileName is the default name of the audit log file.
+	DefaultFileName = "audit.log"
+
+	// DefaultKeyFileName is the default name of the file holding the key
+	// the audit log is authenticated with.
+	DefaultKeyFileName = "audit.key"
+
+	// headFileSuffix is appended to the path of the log to get the path of
+	// the file holding its head checkpoint.
+	headFileSuffix = ".head"
+
+	// keyLen is the length of the key the log is authenticated with.
+	keyLen = 32
+
+	// maxLineLen is the maximum length of a serialized entry that is read
+	// back from the log.
+	maxLineLen = 1024 * 1024
+)
+
+var (
+	// ErrLogFailed is returned when appending to a log that a previous
+	// append already failed for.
+	ErrLogFailed = errors.New("audit log failed")
+)
+
+// Query describes the entries of the log to return.
+type Query struct {
+	// IndexOffset is the index of the entry after which entries are
+	// returned.
+	IndexOffset uint64
+
+	// MaxEntries is the maximum number of entries to return. Zero means no
+	// limit.
+	MaxEntries uint64
+
+	// Method only returns the entries of the given method URI if set.
+	Method string
+
+	// StartTime only returns the entries appended at or after the given
+	// time if set.
+	StartTime time.Time
+
+	// EndTime only returns the entries appended before the given time if
+	// set.
+	EndTime time.Time
+}
+
+// matches returns true if the entry satisfies the filters of the query.
+func (q *Query) matches(e *Entry) bool {
+	switch {
+	case e.Index <= q.IndexOffset:
+		return false
+
+	case q.Method != "" && e.Method != q.Method:
+		return false
+
+	case !q.StartTime.IsZero() && e.Timestamp.Before(q.StartTime):
+		return false
+
+	case !q.EndTime.IsZero() && !e.Timestamp.Before(q.EndTime):
+		return false
+	}
+
+	return true
+}
+
+// Verification is the result of verifying the hash chain of the log.
+type Verification struct {
+	// NumEntries is the number of entries that were verified.
+	NumEntries uint64
+
+	// LastHash is the hash of the last entry that was verified. It can be
+	// stored externally to later detect the truncation of the log.
+	LastHash Hash
+
+	// BrokenIndex is the index of the first entry that breaks the hash
+	// chain, or zero if the log is intact.
+	BrokenIndex uint64
+
+	// Reason describes why the entry at BrokenIndex breaks the chain.
+	Reason string
+
+	// lastPrevHash is the hash of the entry preceding the last entry that
+	// was verified.
+	lastPrevHash Hash
+}
+
+// Valid returns true if the hash chain of the log is intact.
+func (v *Verification) Valid() bool {
+	return v.BrokenIndex == 0
+}
+
+// head is the checkpoint of the last entry appended to the log. It is stored
+// outside of the log and authenticated with the key of the log, so that the
+// removal of the latest entries is detected even after a restart.
+type head struct {
+	Index uint64 `json:"index"`
+	Hash  Hash   `json:"hash"`
+	MAC   Hash   `json:"mac"`
+}
+
+// computeMAC returns the MAC authenticating the checkpoint with the given key.
+func (h *head) computeMAC(key []byte) Hash {
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write([]byte("head"))
+
+	var scratch [8]byte
+	binary.BigEndian.PutUint64(scratch[:], h.Index)
+	_, _ = mac.Write(scratch[:])
+	_, _ = mac.Write(h.Hash[:])
+
+	var sum Hash
+	copy(sum[:], mac.Sum(nil))
+
+	return sum
+}
+
+// Log is an append-only log of the state-changing RPC calls, stored as one
+// JSON encoded entry per line. Each entry commits to the hash of the entry
+// preceding it, so that any modification, insertion or removal of an entry
+// can be detected by verifying the chain. The hashes are keyed with a secret
+// key, and a checkpoint of the last entry is kept in a separate file, so that
+// neither forged entries nor the removal of the latest entries go unnoticed.
+type Log struct {
+	path  string
+	clock clock.Clock
+
+	// key is the key the hash chain and the head checkpoint are
+	// authenticated with.
+	key []byte
+
+	// file is the log file, opened in append mode.
+	file *os.File
+
+	// lastIndex and lastHash are the index and hash of the last entry
+	// appended to the log.
+	lastIndex uint64
+	lastHash  Hash
+
+	// err is the error a previous append failed with. Once set, the log
+	// file may hold a partially written entry, so all further appends
+	// fail.
+	err error
+
+	mu sync.Mutex
+}
+
+// Open opens the audit log at the given path, creating it if it doesn't
+// exist yet. The log is authenticated with the key stored at keyPath, which is
+// created if it doesn't exist yet. The hash chain of an existing log is
+// verified against its head checkpoint, and an error is returned if it is
+// broken or truncated, as appending to it would extend a chain that can no
+// longer be trusted.
+func Open(path, keyPath string, clock clock.Clock) (*Log, error) {
+	key, err := loadKey(keyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(
+		path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0600,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open audit log: %v", err)
+	}
+
+	verification, err := verifyEntries(file, key)
+	if err == nil {
+		err = checkHead(path, key, verification)
+	}
+	if err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("audit log %v is broken: %v", path, err)
+	}
+
+	l := &Log{
+		path:      path,
+		clock:     clock,
+		key:       key,
+		file:      file,
+		lastIndex: verification.NumEntries,
+		lastHash:  verification.LastHash,
+	}
+
+	// The checkpoint may lag behind the log by one entry, or not exist
+	// yet, so we bring it up to date before appending to the log.
+	if err := l.writeHead(); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	log.Infof("Opened audit log %v with %d entries", path,
+		verification.NumEntries)
+
+	return l, nil
+}
+
+// checkHead checks that the verified chain of the log at the given path ends
+// with the entry recorded by its head checkpoint.
+func checkHead(path string, key []byte, verification *Verification) error {
+	if !verification.Valid() {
+		return fmt.Errorf("entry %d: %v", verification.BrokenIndex,
+			verification.Reason)
+	}
+
+	h, err := readHead(path + headFileSuffix)
+	if err != nil {
+		return err
+	}
+
+	var authenticated bool
+	if h != nil {
+		mac := h.computeMAC(key)
+		authenticated = hmac.Equal(h.MAC[:], mac[:])
+	}
+
+	switch {
+	// A new log doesn't have a checkpoint yet.
+	case h == nil && verification.NumEntries == 0:
+		return nil
+
+	case h == nil:
+		return fmt.Errorf("head checkpoint missing")
+
+	case !authenticated:
+		return fmt.Errorf("head checkpoint not authenticated by key")
+
+	case verification.NumEntries == h.Index &&
+		verification.LastHash == h.Hash:
+
+		return nil
+
+	// The node may have stopped after appending an entry, but before
+	// updating the checkpoint. As the entry is authenticated by the key,
+	// it can only have been appended by this node.
+	case verification.NumEntries == h.Index+1 &&
+		verification.lastPrevHash == h.Hash:
+
+		return nil
+
+	case verification.NumEntries < h.Index:
+		return fmt.Errorf("log truncated, expected %d entries",
+			h.Index)
+
+	default:
+		return fmt.Errorf("log doesn't end with entry %d of head "+
+			"checkpoint", h.Index)
+	}
+}
+
+// loadKey reads the key the log is authenticated with from the given path. A
+// new random key is created if the file doesn't exist yet.
+func loadKey(path string) ([]byte, error) {
+	key, err := os.ReadFile(path)
+	switch {
+	case err == nil && len(key) != keyLen:
+		return nil, fmt.Errorf("invalid audit log key length %d",
+			len(key))
+
+	case err == nil:
+		return key, nil
+
+	case !os.IsNotExist(err):
+		return nil, fmt.Errorf("unable to read audit log key: %v", err)
+	}
+
+	key = make([]byte, keyLen)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+
+	if err := writeFileAtomic(path, key); err != nil {
+		return nil, fmt.Errorf("unable to write audit log key: %v", err)
+	}
+
+	return key, nil
+}
+
+// readHead reads the head checkpoint from the given path. Nil is returned if
+// the file doesn't exist.
+func readHead(path string) (*head, error) {
+	raw, err := os.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+		return nil, nil
+
+	case err != nil:
+		return nil, fmt.Errorf("unable to read head checkpoint: %v",
+			err)
+	}
+
+	h := &head{}
+	if err := json.Unmarshal(raw, h); err != nil {
+		return nil, fmt.Errorf("unable to decode head checkpoint: %v",
+			err)
+	}
+
+	return h, nil
+}
+
+// writeHead writes the checkpoint of the last entry of the log.
+func (l *Log) writeHead() error {
+	h := &head{
+		Index: l.lastIndex,
+		Hash:  l.lastHash,
+	}
+	h.MAC = h.computeMAC(l.key)
+
+	raw, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+
+	err = writeFileAtomic(l.path+headFileSuffix, raw)
+	if err != nil {
+		return fmt.Errorf("unable to write head checkpoint: %v", err)
+	}
+
+	return nil
+}
+
+// writeFileAtomic replaces the file at the given path with the given content,
+// making sure that either the old or the new content is found at the path
+// after a crash.
+func writeFileAtomic(path string, content []byte) error {
+	tempPath := path + ".tmp"
+	file, err := os.OpenFile(
+		tempPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600,
+	)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(content); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tempPath, path)
+}
+
+// Close closes the log file.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.file.Close()
+}
+
+// Err returns the error a previous append failed with, or nil if all appends
+// succeeded so far. Once an append failed, all further appends fail with
+// ErrLogFailed.
+func (l *Log) Err() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.err
+}
+
+// Append chains the given record to the log, syncs it to disk and updates the
+// head checkpoint.
+func (l *Log) Append(record *Record) (*Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrLogFailed, l.err)
+	}
+
+	entry := &Entry{
+		Index:      l.lastIndex + 1,
+		Timestamp:  l.clock.Now().UTC(),
+		Method:     record.Method,
+		MacaroonID: record.MacaroonID,
+		RootKeyID:  record.RootKeyID,
+		PeerAddr:   record.PeerAddr,
+		Request:    record.Request,
+		Error:      record.Error,
+		PrevHash:   l.lastHash,
+	}
+	entry.Hash = entry.computeHash(l.key)
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return nil, err
+	}
+	line = append(line, '\n')
+
+	if _, err := l.file.Write(line); err != nil {
+		l.err = fmt.Errorf("unable to write audit log: %v", err)
+		return nil, l.err
+	}
+	if err := l.file.Sync(); err != nil {
+		l.err = fmt.Errorf("unable to sync audit log: %v", err)
+		return nil, l.err
+	}
+
+	l.lastIndex = entry.Index
+	l.lastHash = entry.Hash
+
+	if err := l.writeHead(); err != nil {
+		l.err = err
+		return nil, l.err
+	}
+
+	return entry, nil
+}
+
+// Query returns the entries of the log matching the given query, in the
+// order they were appended.
+func (l *Log) Query(q *Query) ([]*Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	file, err := os.Open(l.path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open audit log: %v", err)
+	}
+	defer file.Close()
+
+	var entries []*Entry
+	scanner := newScanner(file)
+	for scanner.Scan() {
+		entry := &Entry{}
+		if err := json.Unmarshal(scanner.Bytes(), entry); err != nil {
+			return nil, fmt.Errorf("unable to decode audit log "+
+				"entry: %v", err)
+		}
+
+		if !q.matches(entry) {
+			continue
+		}
+
+		entries = append(entries, entry)
+		if q.MaxEntries != 0 && uint64(len(entries)) >= q.MaxEntries {
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read audit log: %v", err)
+	}
+
+	return entries, nil
+}
+
+// Verify verifies the hash chain of all entries of the log. Besides the
+// chain itself, it checks that the log still ends with the last entry
+// appended by this node, so that the removal of the latest entries, or the
+// rewrite of the whole chain, is detected too.
+func (l *Log) Verify() (*Verification, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	file, err := os.Open(l.path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open audit log: %v", err)
+	}
+	defer file.Close()
+
+	verification, err := verifyEntries(file, l.key)
+	if err != nil || !verification.Valid() {
+		return verification, err
+	}
+
+	switch {
+	case verification.NumEntries < l.lastIndex:
+		verification.BrokenIndex = verification.NumEntries + 1
+		verification.Reason = fmt.Sprintf("log truncated, expected "+
+			"%d entries", l.lastIndex)
+
+	case verification.NumEntries > l.lastIndex:
+		verification.BrokenIndex = l.lastIndex + 1
+		verification.Reason = "entry not appended by this node"
+
+	case verification.LastHash != l.lastHash:
+		verification.BrokenIndex = l.lastIndex
+		verification.Reason = "hash of last entry changed"
+	}
+
+	return verification, nil
+}
+
+// verifyEntries reads the entries of the log from the given reader, and
+// verifies that each of them is chained to the one preceding it with the given
+// key. An error is only returned if the log can't be read, a broken chain is
+// reported through the returned verification.
+func verifyEntries(r io.Reader, key []byte) (*Verification, error) {
+	verification := &Verification{}
+
+	scanner := newScanner(r)
+	for scanner.Scan() {
+		index := verification.NumEntries + 1
+
+		entry := &Entry{}
+		if err := json.Unmarshal(scanner.Bytes(), entry); err != nil {
+			verification.BrokenIndex = index
+			verification.Reason = fmt.Sprintf("unable to decode "+
+				"entry: %v", err)
+
+			return verification, nil
+		}
+
+		switch {
+		case entry.Index != index:
+			verification.Reason = fmt.Sprintf("unexpected index %d",
+				entry.Index)
+
+		case entry.PrevHash != verification.LastHash:
+			verification.Reason = "previous hash mismatch"
+
+		case entry.Hash != entry.computeHash(key):
+			verification.Reason = "entry hash mismatch"
+		}
+		if verification.Reason != "" {
+			verification.BrokenIndex = index
+			return verification, nil
+		}
+
+		verification.NumEntries = index
+		verification.LastHash = entry.Hash
+		verification.lastPrevHash = entry.PrevHash
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read audit log: %v", err)
+	}
+
+	return verification, nil
+}
+
+// newScanner returns a scanner reading the lines of the log.
+func newScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineLen)
+
+	return scanner
+}
@@ -0,0 +1,340 @@
+package auditlog
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/brsuite/broln/clock"
+	"github.com/stretchr/testify/require"
+)
+
+var testTime = time.Date(2022, 7, 1, 12, 0, 0, 0, time.UTC)
+
+// testPaths returns the paths of a new audit log and its key in a temporary
+// directory.
+func testPaths(t *testing.T) (string, string) {
+	dir := t.TempDir()
+
+	return filepath.Join(dir, DefaultFileName),
+		filepath.Join(dir, DefaultKeyFileName)
+}
+
+// appendTestRecords appends a record for each of the given methods, advancing
+// the clock by a minute before each of them.
+func appendTestRecords(t *testing.T, log *Log, testClock *clock.TestClock,
+	methods ...string) {
+
+	for _, method := range methods {
+		testClock.SetTime(testClock.Now().Add(time.Minute))
+
+		_, err := log.Append(&Record{
+			Method:     method,
+			MacaroonID: "0201",
+			RootKeyID:  "0",
+			PeerAddr:   "127.0.0.1:10009",
+			Request:    `{"amt":"1000"}`,
+		})
+		require.NoError(t, err)
+	}
+}
+
+// TestAppendQuery asserts that appended entries are chained to each other,
+// survive reopening the log, and can be filtered when queried.
+func TestAppendQuery(t *testing.T) {
+	t.Parallel()
+
+	path, keyPath := testPaths(t)
+	testClock := clock.NewTestClock(testTime)
+
+	log, err := Open(path, keyPath, testClock)
+	require.NoError(t, err)
+
+	appendTestRecords(
+		t, log, testClock, "/lnrpc.Lightning/OpenChannelSync",
+		"/lnrpc.Lightning/SendCoins",
+	)
+	require.NoError(t, log.Close())
+
+	// The chain is resumed from the last entry once the log is reopened.
+	log, err = Open(path, keyPath, testClock)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, log.Close())
+	})
+
+	appendTestRecords(t, log, testClock, "/lnrpc.Lightning/SendCoins")
+
+	entries, err := log.Query(&Query{})
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+
+	var prevHash Hash
+	for i, entry := range entries {
+		require.Equal(t, uint64(i+1), entry.Index)
+		require.Equal(t, prevHash, entry.PrevHash)
+		require.True(t, entry.Success())
+		prevHash = entry.Hash
+	}
+
+	entries, err = log.Query(&Query{
+		Method: "/lnrpc.Lightning/SendCoins",
+	})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, uint64(2), entries[0].Index)
+
+	entries, err = log.Query(&Query{
+		IndexOffset: 1,
+		MaxEntries:  1,
+	})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, uint64(2), entries[0].Index)
+
+	entries, err = log.Query(&Query{
+		StartTime: testTime.Add(2 * time.Minute),
+		EndTime:   testTime.Add(3 * time.Minute),
+	})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, uint64(2), entries[0].Index)
+
+	verification, err := log.Verify()
+	require.NoError(t, err)
+	require.True(t, verification.Valid())
+	require.Equal(t, uint64(3), verification.NumEntries)
+	require.Equal(t, prevHash, verification.LastHash)
+}
+
+// TestVerifyTampering asserts that modifying or removing entries of the log
+// is detected when verifying it, and prevents it from being reopened.
+func TestVerifyTampering(t *testing.T) {
+	t.Parallel()
+
+	methods := []string{
+		"/lnrpc.Lightning/SendCoins",
+		"/lnrpc.Lightning/SendCoins",
+		"/lnrpc.Lightning/SendCoins",
+	}
+
+	tests := []struct {
+		name        string
+		tamper      func([][]byte) [][]byte
+		brokenIndex uint64
+	}{
+		{
+			name: "modified entry",
+			tamper: func(lines [][]byte) [][]byte {
+				lines[1] = bytes.Replace(
+					lines[1], []byte("1000"), []byte("9000"),
+					1,
+				)
+				return lines
+			},
+			brokenIndex: 2,
+		},
+		{
+			name: "removed entry",
+			tamper: func(lines [][]byte) [][]byte {
+				return append(lines[:1], lines[2:]...)
+			},
+			brokenIndex: 2,
+		},
+		{
+			name: "truncated log",
+			tamper: func(lines [][]byte) [][]byte {
+				return lines[:2]
+			},
+			brokenIndex: 3,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			path, keyPath := testPaths(t)
+			testClock := clock.NewTestClock(testTime)
+
+			log, err := Open(path, keyPath, testClock)
+			require.NoError(t, err)
+			t.Cleanup(func() {
+				require.NoError(t, log.Close())
+			})
+
+			appendTestRecords(t, log, testClock, methods...)
+
+			raw, err := os.ReadFile(path)
+			require.NoError(t, err)
+
+			lines := bytes.SplitAfter(raw, []byte("\n"))
+			lines = test.tamper(lines[:len(lines)-1])
+			err = os.WriteFile(path, bytes.Join(lines, nil), 0600)
+			require.NoError(t, err)
+
+			verification, err := log.Verify()
+			require.NoError(t, err)
+			require.False(t, verification.Valid())
+			require.Equal(
+				t, test.brokenIndex, verification.BrokenIndex,
+			)
+
+			// Even a truncation at the end of the log, which keeps
+			// the chain itself intact, is detected through the
+			// head checkpoint once the log is reopened.
+			_, err = Open(path, keyPath, testClock)
+			require.Error(t, err)
+		})
+	}
+}
+
+// TestHeadCheckpoint asserts that the log can only be reopened if it ends with
+// the entry of its authenticated head checkpoint, or the entry following it.
+func TestHeadCheckpoint(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+
+		// tamper modifies the log, given the head checkpoint written
+		// after the second of three entries was appended.
+		tamper func(t *testing.T, path, keyPath string,
+			head []byte)
+
+		expectedErr string
+	}{
+		{
+			name: "checkpoint behind by one entry",
+			tamper: func(t *testing.T, path, _ string,
+				head []byte) {
+
+				writeTestFile(t, path+headFileSuffix, head)
+			},
+		},
+		{
+			name: "log truncated",
+			tamper: func(t *testing.T, path, _ string, _ []byte) {
+				raw, err := os.ReadFile(path)
+				require.NoError(t, err)
+
+				lines := bytes.SplitAfter(raw, []byte("\n"))
+				writeTestFile(
+					t, path, bytes.Join(lines[:2], nil),
+				)
+			},
+			expectedErr: "log truncated, expected 3 entries",
+		},
+		{
+			name: "log truncated to checkpoint",
+			tamper: func(t *testing.T, path, _ string, _ []byte) {
+				writeTestFile(t, path, nil)
+			},
+			expectedErr: "log truncated, expected 3 entries",
+		},
+		{
+			name: "checkpoint removed",
+			tamper: func(t *testing.T, path, _ string, _ []byte) {
+				err := os.Remove(path + headFileSuffix)
+				require.NoError(t, err)
+			},
+			expectedErr: "head checkpoint missing",
+		},
+		{
+			name: "checkpoint forged",
+			tamper: func(t *testing.T, path, _ string,
+				head []byte) {
+
+				head = bytes.Replace(
+					head, []byte(`"index":2`),
+					[]byte(`"index":3`), 1,
+				)
+				writeTestFile(t, path+headFileSuffix, head)
+			},
+			expectedErr: "not authenticated by key",
+		},
+		{
+			name: "key replaced",
+			tamper: func(t *testing.T, _, keyPath string,
+				_ []byte) {
+
+				key := bytes.Repeat([]byte{1}, keyLen)
+				writeTestFile(t, keyPath, key)
+			},
+			expectedErr: "entry hash mismatch",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			path, keyPath := testPaths(t)
+			testClock := clock.NewTestClock(testTime)
+
+			log, err := Open(path, keyPath, testClock)
+			require.NoError(t, err)
+
+			appendTestRecords(
+				t, log, testClock, "/lnrpc.Lightning/SendCoins",
+				"/lnrpc.Lightning/SendCoins",
+			)
+			head, err := os.ReadFile(path + headFileSuffix)
+			require.NoError(t, err)
+
+			appendTestRecords(
+				t, log, testClock, "/lnrpc.Lightning/SendCoins",
+			)
+			require.NoError(t, log.Close())
+
+			test.tamper(t, path, keyPath, head)
+
+			log, err = Open(path, keyPath, testClock)
+			if test.expectedErr != "" {
+				require.ErrorContains(t, err, test.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			t.Cleanup(func() {
+				require.NoError(t, log.Close())
+			})
+
+			verification, err := log.Verify()
+			require.NoError(t, err)
+			require.True(t, verification.Valid())
+			require.Equal(t, uint64(3), verification.NumEntries)
+		})
+	}
+}
+
+// TestAppendFailure asserts that once an append failed, all further appends
+// fail as well.
+func TestAppendFailure(t *testing.T) {
+	t.Parallel()
+
+	path, keyPath := testPaths(t)
+	testClock := clock.NewTestClock(testTime)
+
+	log, err := Open(path, keyPath, testClock)
+	require.NoError(t, err)
+	require.NoError(t, log.Err())
+
+	// Closing the log file makes the next write fail.
+	require.NoError(t, log.file.Close())
+
+	_, err = log.Append(&Record{Method: "/lnrpc.Lightning/SendCoins"})
+	require.Error(t, err)
+	require.Error(t, log.Err())
+
+	_, err = log.Append(&Record{Method: "/lnrpc.Lightning/SendCoins"})
+	require.ErrorIs(t, err, ErrLogFailed)
+}
+
+// writeTestFile replaces the content of the file at the given path.
+func writeTestFile(t *testing.T, path string, content []byte) {
+	require.NoError(t, os.WriteFile(path, content, 0600))
+}
@@ -0,0 +1,142 @@
+package auditlog
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Hash is the HMAC-SHA256 chaining an entry of the audit log to the entry
+// preceding it.
+type Hash [sha256.Size]byte
+
+// String returns the hex encoding of the hash.
+func (h Hash) String() string {
+	return hex.EncodeToString(h[:])
+}
+
+// MarshalText encodes the hash as hex when it is serialized.
+func (h Hash) MarshalText() ([]byte, error) {
+	return []byte(h.String()), nil
+}
+
+// UnmarshalText decodes a hex encoded hash.
+func (h *Hash) UnmarshalText(text []byte) error {
+	if len(text) != hex.EncodedLen(sha256.Size) {
+		return fmt.Errorf("invalid hash length %d", len(text))
+	}
+
+	_, err := hex.Decode(h[:], text)
+	return err
+}
+
+// Record describes a state-changing RPC call that is to be appended to the
+// audit log.
+type Record struct {
+	// Method is the full URI of the RPC method that was called.
+	Method string
+
+	// MacaroonID is the hex encoded identifier of the macaroon the call
+	// was made with, if any.
+	MacaroonID string
+
+	// RootKeyID is the ID of the root key of the macaroon the call was
+	// made with, if any.
+	RootKeyID string
+
+	// PeerAddr is the address of the client that made the call.
+	PeerAddr string
+
+	// Request is a summary of the request of the call.
+	Request string
+
+	// Error is the error the call failed with, or an empty string if it
+	// succeeded.
+	Error string
+}
+
+// Entry is a record of the audit log, chained to the entry preceding it by
+// its hash.
+type Entry struct {
+	// Index is the position of the entry in the log, starting at 1.
+	Index uint64 `json:"index"`
+
+	// Timestamp is the time the entry was appended to the log.
+	Timestamp time.Time `json:"timestamp"`
+
+	// Method is the full URI of the RPC method that was called.
+	Method string `json:"method"`
+
+	// MacaroonID is the hex encoded identifier of the macaroon the call
+	// was made with, if any.
+	MacaroonID string `json:"macaroon_id"`
+
+	// RootKeyID is the ID of the root key of the macaroon the call was
+	// made with, if any.
+	RootKeyID string `json:"root_key_id"`
+
+	// PeerAddr is the address of the client that made the call.
+	PeerAddr string `json:"peer_addr"`
+
+	// Request is a summary of the request of the call.
+	Request string `json:"request"`
+
+	// Error is the error the call failed with, or an empty string if it
+	// succeeded.
+	Error string `json:"error"`
+
+	// PrevHash is the hash of the previous entry, or all zeroes for the
+	// first entry of the log.
+	PrevHash Hash `json:"prev_hash"`
+
+	// Hash commits to all fields of the entry, including PrevHash.
+	Hash Hash `json:"hash"`
+}
+
+// Success returns true if the call recorded by the entry succeeded.
+func (e *Entry) Success() bool {
+	return e.Error == ""
+}
+
+// computeHash returns the hash committing to all fields of the entry but the
+// hash itself. The hash is keyed with the key of the log, so that entries can't
+// be forged without it.
+func (e *Entry) computeHash(key []byte) Hash {
+	h := hmac.New(sha256.New, key)
+
+	var scratch [8]byte
+	_, _ = h.Write(e.PrevHash[:])
+
+	binary.BigEndian.PutUint64(scratch[:], e.Index)
+	_, _ = h.Write(scratch[:])
+
+	binary.BigEndian.PutUint64(scratch[:], uint64(e.Timestamp.UnixNano()))
+	_, _ = h.Write(scratch[:])
+
+	fields := []string{
+		e.Method, e.MacaroonID, e.RootKeyID, e.PeerAddr, e.Request,
+		e.Error,
+	}
+	for _, field := range fields {
+		writeString(h, field)
+	}
+
+	var hash Hash
+	copy(hash[:], h.Sum(nil))
+
+	return hash
+}
+
+// writeString writes a length prefixed string, so that the boundaries of
+// consecutive strings are committed to.
+func writeString(w io.Writer, s string) {
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(s)))
+
+	_, _ = w.Write(length[:])
+	_, _ = w.Write([]byte(s))
+}
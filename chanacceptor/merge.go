@@ -148,5 +148,9 @@ func mergeResponse(current, new ChannelAcceptResponse) (ChannelAcceptResponse,
 		return current, err
 	}
 
+	// Like the zero values above, a false ZeroConf value is treated as
+	// unset, so the channel is zero-conf if any acceptor asked for it.
+	current.ZeroConf = current.ZeroConf || new.ZeroConf
+
 	return current, nil
 }
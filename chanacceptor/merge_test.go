@@ -114,6 +114,19 @@ func TestMergeResponse(t *testing.T) {
 			},
 			err: fieldMismatchError(fieldMinDep, 1, 2),
 		},
+		{
+			name: "zero-conf set by one acceptor",
+			current: ChannelAcceptResponse{
+				ZeroConf: true,
+			},
+			new: ChannelAcceptResponse{
+				ZeroConf: false,
+			},
+			merged: ChannelAcceptResponse{
+				ZeroConf: true,
+			},
+			err: nil,
+		},
 		{
 			name: "merge all values",
 			current: ChannelAcceptResponse{
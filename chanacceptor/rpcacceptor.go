@@ -43,6 +43,11 @@ var (
 	errMaxHtlcTooHigh = fmt.Errorf("htlc limit exceeds spec limit of: %v",
 		input.MaxHTLCNumber/2)
 
+	// errZeroConfMinDepth is returned when a zero-conf channel is
+	// requested with a non-zero min accept depth.
+	errZeroConfMinDepth = errors.New("zero-conf channels require a min " +
+		"accept depth of zero")
+
 	// maxErrorLength is the maximum error length we allow the error we
 	// send to our peer to be.
 	maxErrorLength = 500
@@ -107,7 +112,7 @@ func (r *RPCAcceptor) Accept(req *ChannelAcceptRequest) *ChannelAcceptResponse {
 	// Create a rejection response which we can use for the cases where we
 	// reject the channel.
 	rejectChannel := NewChannelAcceptResponse(
-		false, errChannelRejected, nil, 0, 0, 0, 0, 0, 0,
+		false, errChannelRejected, nil, 0, 0, 0, 0, 0, 0, false,
 	)
 
 	// Send the request to the newRequests channel.
@@ -216,6 +221,7 @@ func (r *RPCAcceptor) receiveResponses(errChan chan error,
 			MaxHtlcCount:    resp.MaxHtlcCount,
 			MinHtlcIn:       resp.MinHtlcIn,
 			MinAcceptDepth:  resp.MinAcceptDepth,
+			ZeroConf:        resp.ZeroConf,
 		}
 
 		// We have received a decision for one of our channel
@@ -257,12 +263,29 @@ func (r *RPCAcceptor) sendAcceptRequests(errChan chan error,
 			pendingChanID := req.OpenChanMsg.PendingChannelID
 
 			// Map the channel commitment type to its RPC
-			// counterpart.
-			var commitmentType lnrpc.CommitmentType
+			// counterpart. The zero-conf and scid-alias bits are
+			// reported separately, so we strip them from a copy of
+			// the channel type before matching.
+			var (
+				commitmentType lnrpc.CommitmentType
+				wantsZeroConf  bool
+				wantsScidAlias bool
+			)
 			if req.OpenChanMsg.ChannelType != nil {
-				channelFeatures := lnwire.RawFeatureVector(
+				rawChanType := lnwire.RawFeatureVector(
 					*req.OpenChanMsg.ChannelType,
 				)
+				channelFeatures := rawChanType.Clone()
+
+				wantsZeroConf = channelFeatures.IsSet(
+					lnwire.ZeroConfRequired,
+				)
+				wantsScidAlias = channelFeatures.IsSet(
+					lnwire.ScidAliasRequired,
+				)
+				channelFeatures.Unset(lnwire.ZeroConfRequired)
+				channelFeatures.Unset(lnwire.ScidAliasRequired)
+
 				switch {
 				case channelFeatures.OnlyContains(
 					lnwire.ScriptEnforcedLeaseRequired,
@@ -310,6 +333,8 @@ func (r *RPCAcceptor) sendAcceptRequests(errChan chan error,
 				MaxAcceptedHtlcs: uint32(req.OpenChanMsg.MaxAcceptedHTLCs),
 				ChannelFlags:     uint32(req.OpenChanMsg.ChannelFlags),
 				CommitmentType:   commitmentType,
+				WantsZeroConf:    wantsZeroConf,
+				WantsScidAlias:   wantsScidAlias,
 			}
 
 			if err := r.send(chanAcceptReq); err != nil {
@@ -348,6 +373,7 @@ func (r *RPCAcceptor) sendAcceptRequests(errChan chan error,
 				bronutil.Amount(resp.ReserveSat),
 				lnwire.MilliSatoshi(resp.InFlightMaxMsat),
 				lnwire.MilliSatoshi(resp.MinHtlcIn),
+				resp.ZeroConf,
 			)
 
 			// Delete the channel from the acceptRequests map.
@@ -408,6 +434,14 @@ func (r *RPCAcceptor) validateAcceptorResponse(dustLimit bronutil.Amount,
 		return false, errChannelRejected, nil, errInvalidUpfrontShutdown
 	}
 
+	// A zero-conf channel can only be accepted with a min depth of zero.
+	if req.ZeroConf && req.MinAcceptDepth != 0 {
+		log.Errorf("Zero-conf channel: %v requested with non-zero "+
+			"min accept depth: %v", channelStr, req.MinAcceptDepth)
+
+		return false, errChannelRejected, nil, errZeroConfMinDepth
+	}
+
 	// Check that the custom error provided is valid.
 	if len(req.Error) > maxErrorLength {
 		return false, errChannelRejected, nil, errCustomLength
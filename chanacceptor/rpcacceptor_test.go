@@ -115,6 +115,17 @@ func TestValidateAcceptorResponse(t *testing.T) {
 			acceptorErr: errChannelRejected,
 			error:       errMaxHtlcTooHigh,
 		},
+		{
+			name: "zero-conf with non-zero min depth",
+			response: &lnrpc.ChannelAcceptResponse{
+				Accept:         true,
+				ZeroConf:       true,
+				MinAcceptDepth: 3,
+			},
+			accept:      false,
+			acceptorErr: errChannelRejected,
+			error:       errZeroConfMinDepth,
+		},
 	}
 
 	for _, test := range tests {
@@ -0,0 +1,256 @@
+package chanbackup
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/brsuite/brond/btcec"
+	"github.com/brsuite/brond/wire"
+	"github.com/brsuite/broln/keychain"
+	"github.com/brsuite/broln/lnwire"
+)
+
+// ErrPeerBackupTooLarge is returned when the packed backup of the channels
+// with a peer doesn't fit within a single peer storage message.
+var ErrPeerBackupTooLarge = errors.New("peer backup exceeds max peer " +
+	"storage size")
+
+// PeerStorer is an interface that allows the chanbackup.PeerBackupPusher to
+// hand the encrypted backup of our channels with a peer to that same peer,
+// which will store it on our behalf.
+type PeerStorer interface {
+	// StorePeerBackup asks the target peer to store the packed backup of
+	// the channels we have with it, replacing any backup it stored for us
+	// before.
+	StorePeerBackup(peer *btcec.PublicKey, backup PackedMulti) error
+}
+
+// PeerBackupPusher subscribes to new updates to the open channel state, and
+// hands an encrypted multi backup of the channels we have with each peer to
+// that peer in response. Each peer only ever learns an encrypted blob of its
+// own channels, which it hands back to us when we reconnect. This allows a
+// node that was restored from its seed alone to recover its channels without
+// a backup file.
+type PeerBackupPusher struct {
+	started sync.Once
+	stopped sync.Once
+
+	// backupState are the set of SCBs for all open channels we know of,
+	// keyed by the compressed public key of the channel peer.
+	backupState map[[33]byte]map[wire.OutPoint]Single
+
+	// stateMtx guards backupState.
+	stateMtx sync.Mutex
+
+	// chanEvents is an active subscription to receive new channel state
+	// over.
+	chanEvents *ChannelSubscription
+
+	// keyRing is the main key ring that will allow us to pack the new
+	// multi backups.
+	keyRing keychain.KeyRing
+
+	PeerStorer
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewPeerBackupPusher creates a new instance of the PeerBackupPusher given
+// the starting set of channels, and the required interfaces to be notified of
+// new channel updates, pack a multi backup, and hand it to the channel peer.
+func NewPeerBackupPusher(startingChans []Single, chanNotifier ChannelNotifier,
+	keyRing keychain.KeyRing, storer PeerStorer) (*PeerBackupPusher, error) {
+
+	// First, we'll subscribe to the latest set of channel updates given
+	// the set of channels we already know of.
+	knownChans := make(map[wire.OutPoint]struct{})
+	for _, chanBackup := range startingChans {
+		knownChans[chanBackup.FundingOutpoint] = struct{}{}
+	}
+	chanEvents, err := chanNotifier.SubscribeChans(knownChans)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &PeerBackupPusher{
+		backupState: make(map[[33]byte]map[wire.OutPoint]Single),
+		chanEvents:  chanEvents,
+		keyRing:     keyRing,
+		PeerStorer:  storer,
+		quit:        make(chan struct{}),
+	}
+	for _, chanBackup := range startingChans {
+		p.addBackup(chanBackup)
+	}
+
+	return p, nil
+}
+
+// Start starts the chanbackup.PeerBackupPusher.
+func (p *PeerBackupPusher) Start() error {
+	p.started.Do(func() {
+		log.Infof("Starting chanbackup.PeerBackupPusher")
+
+		p.wg.Add(1)
+		go p.backupPusher()
+	})
+
+	return nil
+}
+
+// Stop signals the PeerBackupPusher to being a graceful shutdown.
+func (p *PeerBackupPusher) Stop() error {
+	p.stopped.Do(func() {
+		log.Infof("Stopping chanbackup.PeerBackupPusher")
+
+		close(p.quit)
+		p.wg.Wait()
+	})
+	return nil
+}
+
+// addBackup adds the backup to the backup state of its channel peer.
+//
+// NOTE: The stateMtx MUST be held, or the pusher not yet started.
+func (p *PeerBackupPusher) addBackup(backup Single) {
+	var peer [33]byte
+	copy(peer[:], backup.RemoteNodePub.SerializeCompressed())
+
+	if _, ok := p.backupState[peer]; !ok {
+		p.backupState[peer] = make(map[wire.OutPoint]Single)
+	}
+	p.backupState[peer][backup.FundingOutpoint] = backup
+}
+
+// removeBackup removes the backup of the closed channel from the backup state
+// of its channel peer, returning the public key of the peer.
+//
+// NOTE: The stateMtx MUST be held.
+func (p *PeerBackupPusher) removeBackup(closedChan wire.OutPoint) (
+	*btcec.PublicKey, bool) {
+
+	for peer, backups := range p.backupState {
+		backup, ok := backups[closedChan]
+		if !ok {
+			continue
+		}
+
+		delete(backups, closedChan)
+		if len(backups) == 0 {
+			delete(p.backupState, peer)
+		}
+
+		return backup.RemoteNodePub, true
+	}
+
+	return nil, false
+}
+
+// PackPeerBackup packs (encrypts+encodes) a multi backup of all channels we
+// have with the target peer. An empty multi is packed if we have no channels
+// with the peer.
+func (p *PeerBackupPusher) PackPeerBackup(peer *btcec.PublicKey) (
+	PackedMulti, error) {
+
+	var peerKey [33]byte
+	copy(peerKey[:], peer.SerializeCompressed())
+
+	p.stateMtx.Lock()
+	var peerMulti Multi
+	for _, backup := range p.backupState[peerKey] {
+		peerMulti.StaticBackups = append(
+			peerMulti.StaticBackups, backup,
+		)
+	}
+	p.stateMtx.Unlock()
+
+	var b bytes.Buffer
+	if err := peerMulti.PackToWriter(&b, p.keyRing); err != nil {
+		return nil, fmt.Errorf("unable to pack multi backup: %v", err)
+	}
+
+	if b.Len() > lnwire.MaxPeerStorageBytes {
+		return nil, fmt.Errorf("%w: %v channels packed to %v bytes",
+			ErrPeerBackupTooLarge, len(peerMulti.StaticBackups),
+			b.Len())
+	}
+
+	return PackedMulti(b.Bytes()), nil
+}
+
+// PushPeerBackup hands the latest backup of the channels we have with the
+// target peer to the peer for storage. This should be called each time the
+// peer connects.
+func (p *PeerBackupPusher) PushPeerBackup(peer *btcec.PublicKey) error {
+	backup, err := p.PackPeerBackup(peer)
+	if err != nil {
+		return err
+	}
+
+	return p.StorePeerBackup(peer, backup)
+}
+
+// backupPusher is the primary goroutine of the PeerBackupPusher which is
+// responsible for listening for changes to the channel, and pushing a new
+// packed multi of the latest channel state to each peer whose channels
+// changed.
+func (p *PeerBackupPusher) backupPusher() {
+	// Ensure that once we exit, we'll cancel our active channel
+	// subscription.
+	defer p.chanEvents.Cancel()
+	defer p.wg.Done()
+
+	log.Debugf("PeerBackupPusher's backupPusher is active!")
+
+	for {
+		select {
+		// The channel state has been modified! We'll update the backup
+		// state of each peer that was affected, and hand it the new
+		// backup.
+		case chanUpdate := <-p.chanEvents.ChanUpdates:
+			changedPeers := make(map[[33]byte]*btcec.PublicKey)
+
+			p.stateMtx.Lock()
+			for _, newChan := range chanUpdate.NewChans {
+				backup := NewSingle(
+					newChan.OpenChannel, newChan.Addrs,
+				)
+				p.addBackup(backup)
+
+				var peer [33]byte
+				copy(peer[:], backup.RemoteNodePub.
+					SerializeCompressed())
+				changedPeers[peer] = backup.RemoteNodePub
+			}
+			for _, closedChan := range chanUpdate.ClosedChans {
+				pub, ok := p.removeBackup(closedChan)
+				if !ok {
+					continue
+				}
+
+				var peer [33]byte
+				copy(peer[:], pub.SerializeCompressed())
+				changedPeers[peer] = pub
+			}
+			p.stateMtx.Unlock()
+
+			for _, peer := range changedPeers {
+				log.Debugf("Pushing updated channel backup "+
+					"to peer %x", peer.SerializeCompressed())
+
+				if err := p.PushPeerBackup(peer); err != nil {
+					log.Errorf("Unable to push channel "+
+						"backup to peer %x: %v",
+						peer.SerializeCompressed(), err)
+				}
+			}
+
+		// Exit at once if a quit signal is detected.
+		case <-p.quit:
+			return
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package chanbackup
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/brsuite/brond/btcec"
+	"github.com/brsuite/brond/wire"
+	"github.com/brsuite/broln/keychain"
+)
+
+// peerBackup is a backup handed to a peer by the mockPeerStorer.
+type peerBackup struct {
+	peer   *btcec.PublicKey
+	backup PackedMulti
+}
+
+type mockPeerStorer struct {
+	backups chan peerBackup
+}
+
+func newMockPeerStorer() *mockPeerStorer {
+	return &mockPeerStorer{
+		backups: make(chan peerBackup, 10),
+	}
+}
+
+func (m *mockPeerStorer) StorePeerBackup(peer *btcec.PublicKey,
+	backup PackedMulti) error {
+
+	m.backups <- peerBackup{peer: peer, backup: backup}
+
+	return nil
+}
+
+func assertPeerBackup(t *testing.T, storer *mockPeerStorer,
+	keyRing keychain.KeyRing, peer *btcec.PublicKey,
+	expectedChanSet map[wire.OutPoint]struct{}) {
+
+	t.Helper()
+
+	select {
+	case newBackup := <-storer.backups:
+		if !newBackup.peer.IsEqual(peer) {
+			t.Fatalf("backup pushed to wrong peer: expected %x, "+
+				"got %x", peer.SerializeCompressed(),
+				newBackup.peer.SerializeCompressed())
+		}
+
+		newMulti, err := newBackup.backup.Unpack(keyRing)
+		if err != nil {
+			t.Fatalf("unable to unpack multi: %v", err)
+		}
+
+		if len(newMulti.StaticBackups) != len(expectedChanSet) {
+			t.Fatalf("expected %v backups have %v",
+				len(expectedChanSet),
+				len(newMulti.StaticBackups))
+		}
+
+		for _, backup := range newMulti.StaticBackups {
+			_, ok := expectedChanSet[backup.FundingOutpoint]
+			if !ok {
+				t.Fatalf("didn't find backup in expected "+
+					"set: %v", backup.FundingOutpoint)
+			}
+
+			if !backup.RemoteNodePub.IsEqual(peer) {
+				t.Fatalf("backup of other peer pushed: %v",
+					backup.FundingOutpoint)
+			}
+		}
+
+	case <-time.After(time.Second * 5):
+		t.Fatalf("peer backup wasn't pushed")
+	}
+}
+
+// TestNewPeerBackupPusherSubscribeFail tests that if we're unable to obtain a
+// channel subscription, then the peer backup pusher will fail to start.
+func TestNewPeerBackupPusherSubscribeFail(t *testing.T) {
+	t.Parallel()
+
+	keyRing := &mockKeyRing{}
+	chanNotifier := mockChannelNotifier{
+		fail: true,
+	}
+
+	_, err := NewPeerBackupPusher(
+		nil, &chanNotifier, keyRing, newMockPeerStorer(),
+	)
+	if err == nil {
+		t.Fatalf("expected fail due to lack of subscription")
+	}
+}
+
+// TestPeerBackupPusher tests that the PeerBackupPusher hands each peer a
+// backup of only the channels it has with us, and pushes a new backup to the
+// peer once a channel with it is opened or closed.
+func TestPeerBackupPusher(t *testing.T) {
+	t.Parallel()
+
+	keyRing := &mockKeyRing{}
+	chanNotifier := newMockChannelNotifier()
+	storer := newMockPeerStorer()
+
+	// We'll start out with two channels with our first peer, and a single
+	// channel with another peer.
+	chanA1, err := genRandomOpenChannelShell()
+	if err != nil {
+		t.Fatalf("unable to make test chan: %v", err)
+	}
+	chanA2, err := genRandomOpenChannelShell()
+	if err != nil {
+		t.Fatalf("unable to make test chan: %v", err)
+	}
+	chanA2.IdentityPub = chanA1.IdentityPub
+
+	chanB, err := genRandomOpenChannelShell()
+	if err != nil {
+		t.Fatalf("unable to make test chan: %v", err)
+	}
+
+	peerA := chanA1.IdentityPub
+	peerB := chanB.IdentityPub
+
+	initialChanSet := []Single{
+		NewSingle(chanA1, nil),
+		NewSingle(chanA2, nil),
+		NewSingle(chanB, nil),
+	}
+
+	pusher, err := NewPeerBackupPusher(
+		initialChanSet, chanNotifier, keyRing, storer,
+	)
+	if err != nil {
+		t.Fatalf("unable to make pusher: %v", err)
+	}
+	if err := pusher.Start(); err != nil {
+		t.Fatalf("unable to start pusher: %v", err)
+	}
+	defer pusher.Stop()
+
+	// Once our first peer connects, it should be handed a backup of both
+	// of its channels.
+	if err := pusher.PushPeerBackup(peerA); err != nil {
+		t.Fatalf("unable to push backup: %v", err)
+	}
+	peerAChans := map[wire.OutPoint]struct{}{
+		chanA1.FundingOutpoint: {},
+		chanA2.FundingOutpoint: {},
+	}
+	assertPeerBackup(t, storer, keyRing, peerA, peerAChans)
+
+	// The other peer is only handed the backup of its own channel.
+	if err := pusher.PushPeerBackup(peerB); err != nil {
+		t.Fatalf("unable to push backup: %v", err)
+	}
+	assertPeerBackup(t, storer, keyRing, peerB, map[wire.OutPoint]struct{}{
+		chanB.FundingOutpoint: {},
+	})
+
+	// Next, we'll open a new channel with our first peer, which should
+	// result in a new backup being pushed to it.
+	chanA3, err := genRandomOpenChannelShell()
+	if err != nil {
+		t.Fatalf("unable to make test chan: %v", err)
+	}
+	chanA3.IdentityPub = peerA
+
+	select {
+	case chanNotifier.chanEvents <- ChannelEvent{
+		NewChans: []ChannelWithAddrs{
+			{
+				OpenChannel: chanA3,
+			},
+		},
+	}:
+	case <-time.After(time.Second * 5):
+		t.Fatalf("pusher didn't read new channel")
+	}
+
+	peerAChans[chanA3.FundingOutpoint] = struct{}{}
+	assertPeerBackup(t, storer, keyRing, peerA, peerAChans)
+
+	// Closing the channel with our second peer should push it an empty
+	// backup.
+	select {
+	case chanNotifier.chanEvents <- ChannelEvent{
+		ClosedChans: []wire.OutPoint{chanB.FundingOutpoint},
+	}:
+	case <-time.After(time.Second * 5):
+		t.Fatalf("pusher didn't read closed channel")
+	}
+
+	assertPeerBackup(t, storer, keyRing, peerB, nil)
+
+	// Finally, closing a channel we don't know of shouldn't push a backup
+	// to anyone.
+	var unknownChan wire.OutPoint
+	unknownChan.Index = 1
+	select {
+	case chanNotifier.chanEvents <- ChannelEvent{
+		ClosedChans: []wire.OutPoint{unknownChan},
+	}:
+	case <-time.After(time.Second * 5):
+		t.Fatalf("pusher didn't read closed channel")
+	}
+
+	select {
+	case newBackup := <-storer.backups:
+		t.Fatalf("unexpected backup pushed to %x",
+			newBackup.peer.SerializeCompressed())
+	case <-time.After(time.Millisecond * 100):
+	}
+}
+
+// TestPackPeerBackupTooLarge tests that a backup that doesn't fit within a
+// single peer storage message is refused.
+func TestPackPeerBackupTooLarge(t *testing.T) {
+	t.Parallel()
+
+	keyRing := &mockKeyRing{}
+
+	// We'll create more channels with a single peer than what fits
+	// within a single peer storage message.
+	const numChans = 200
+	var (
+		peer    *btcec.PublicKey
+		chanSet []Single
+	)
+	for i := 0; i < numChans; i++ {
+		channel, err := genRandomOpenChannelShell()
+		if err != nil {
+			t.Fatalf("unable to make test chan: %v", err)
+		}
+
+		if peer == nil {
+			peer = channel.IdentityPub
+		}
+		channel.IdentityPub = peer
+
+		chanSet = append(chanSet, NewSingle(channel, nil))
+	}
+
+	pusher, err := NewPeerBackupPusher(
+		chanSet, newMockChannelNotifier(), keyRing,
+		newMockPeerStorer(),
+	)
+	if err != nil {
+		t.Fatalf("unable to make pusher: %v", err)
+	}
+
+	_, err = pusher.PackPeerBackup(peer)
+	if !errors.Is(err, ErrPeerBackupTooLarge) {
+		t.Fatalf("expected ErrPeerBackupTooLarge, got %v", err)
+	}
+}
@@ -0,0 +1,275 @@
+package channeldb
+
+import (
+	"errors"
+	"math"
+
+	"github.com/brsuite/broln/kvdb"
+	"github.com/brsuite/broln/lnwire"
+)
+
+var (
+	// aliasBucket is the top level bucket that maps each alias
+	// ShortChannelID we've handed out to the base ShortChannelID of the
+	// channel it refers to.
+	//
+	// alias-bucket
+	//      |
+	//      |-- <alias-scid>: <base-scid>
+	//      |-- <alias-scid>: <base-scid>
+	aliasBucket = []byte("alias-bucket")
+
+	// peerAliasBucket is the top level bucket that stores the alias
+	// ShortChannelID our peer sent us in funding_locked, keyed by the
+	// channel ID of the channel.
+	peerAliasBucket = []byte("peer-alias-bucket")
+
+	// aliasAllocBucket is the top level bucket that stores the last alias
+	// that was allocated under lastAliasKey.
+	aliasAllocBucket = []byte("alias-alloc-bucket")
+
+	// lastAliasKey is the key under which the last allocated alias is
+	// stored within the aliasAllocBucket.
+	lastAliasKey = []byte("last-alias")
+)
+
+var (
+	// ErrAliasNotFound is returned when an alias ShortChannelID or the
+	// base ShortChannelID it maps to cannot be found.
+	ErrAliasNotFound = errors.New("alias not found")
+
+	// ErrNoPeerAlias is returned when the peer hasn't sent us an alias for
+	// the given channel.
+	ErrNoPeerAlias = errors.New("no peer alias found")
+
+	// ErrAliasSpaceExhausted is returned when there are no more alias
+	// ShortChannelIDs that can be allocated.
+	ErrAliasSpaceExhausted = errors.New("alias space exhausted")
+)
+
+const (
+	// AliasStartHeight is the block height of the first alias
+	// ShortChannelID that is handed out. Alias ShortChannelIDs use block
+	// heights that won't be reached for many decades, so they can never
+	// collide with a confirmed channel.
+	AliasStartHeight uint32 = 16_000_000
+
+	// AliasEndHeight is the (exclusive) upper bound on the block height of
+	// alias ShortChannelIDs.
+	AliasEndHeight uint32 = 16_250_000
+
+	// maxAliasTxIndex is the largest transaction index that fits into
+	// the three bytes allotted to it in a ShortChannelID.
+	maxAliasTxIndex uint32 = 1<<24 - 1
+)
+
+// StartingAlias is the first alias ShortChannelID that will be allocated.
+var StartingAlias = lnwire.ShortChannelID{
+	BlockHeight: AliasStartHeight,
+	TxIndex:     0,
+	TxPosition:  0,
+}
+
+// IsAlias returns true if the passed ShortChannelID falls within the range
+// reserved for alias ShortChannelIDs.
+func IsAlias(scid lnwire.ShortChannelID) bool {
+	return scid.BlockHeight >= AliasStartHeight &&
+		scid.BlockHeight < AliasEndHeight
+}
+
+// nextAlias returns the alias ShortChannelID that follows the passed one. The
+// TxPosition is incremented first, then the TxIndex and finally the
+// BlockHeight.
+func nextAlias(last lnwire.ShortChannelID) (lnwire.ShortChannelID, error) {
+	next := last
+
+	switch {
+	case last.TxPosition < math.MaxUint16:
+		next.TxPosition++
+
+	case last.TxIndex < maxAliasTxIndex:
+		next.TxIndex++
+		next.TxPosition = 0
+
+	default:
+		next.BlockHeight++
+		next.TxIndex = 0
+		next.TxPosition = 0
+	}
+
+	if !IsAlias(next) {
+		return lnwire.ShortChannelID{}, ErrAliasSpaceExhausted
+	}
+
+	return next, nil
+}
+
+// scidKey serializes a ShortChannelID into an 8-byte database key.
+func scidKey(scid lnwire.ShortChannelID) []byte {
+	var k [8]byte
+	byteOrder.PutUint64(k[:], scid.ToUint64())
+	return k[:]
+}
+
+// RequestAlias allocates a new alias ShortChannelID that hasn't been handed
+// out before. The allocation is persisted so aliases are never reused across
+// restarts.
+func (c *ChannelStateDB) RequestAlias() (lnwire.ShortChannelID, error) {
+	var alias lnwire.ShortChannelID
+	err := kvdb.Update(c.backend, func(tx kvdb.RwTx) error {
+		bucket, err := tx.CreateTopLevelBucket(aliasAllocBucket)
+		if err != nil {
+			return err
+		}
+
+		lastBytes := bucket.Get(lastAliasKey)
+		if lastBytes == nil {
+			alias = StartingAlias
+		} else {
+			last := lnwire.NewShortChanIDFromInt(
+				byteOrder.Uint64(lastBytes),
+			)
+
+			alias, err = nextAlias(last)
+			if err != nil {
+				return err
+			}
+		}
+
+		return bucket.Put(lastAliasKey, scidKey(alias))
+	}, func() {
+		alias = lnwire.ShortChannelID{}
+	})
+
+	return alias, err
+}
+
+// AddLocalAlias persists a mapping from the passed alias ShortChannelID to
+// the base ShortChannelID of the channel it refers to. For zero-conf channels
+// the base ShortChannelID is the first alias that was assigned to it.
+func (c *ChannelStateDB) AddLocalAlias(alias,
+	baseScid lnwire.ShortChannelID) error {
+
+	return kvdb.Update(c.backend, func(tx kvdb.RwTx) error {
+		bucket, err := tx.CreateTopLevelBucket(aliasBucket)
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put(scidKey(alias), scidKey(baseScid))
+	}, func() {})
+}
+
+// DeleteLocalAlias removes the mapping for the passed alias ShortChannelID.
+func (c *ChannelStateDB) DeleteLocalAlias(alias lnwire.ShortChannelID) error {
+	return kvdb.Update(c.backend, func(tx kvdb.RwTx) error {
+		bucket := tx.ReadWriteBucket(aliasBucket)
+		if bucket == nil {
+			return ErrAliasNotFound
+		}
+
+		return bucket.Delete(scidKey(alias))
+	}, func() {})
+}
+
+// GetAliases returns all alias ShortChannelIDs that map to the passed base
+// ShortChannelID.
+func (c *ChannelStateDB) GetAliases(
+	baseScid lnwire.ShortChannelID) ([]lnwire.ShortChannelID, error) {
+
+	var aliases []lnwire.ShortChannelID
+	err := kvdb.View(c.backend, func(tx kvdb.RTx) error {
+		bucket := tx.ReadBucket(aliasBucket)
+		if bucket == nil {
+			return nil
+		}
+
+		base := baseScid.ToUint64()
+		return bucket.ForEach(func(k, v []byte) error {
+			if byteOrder.Uint64(v) != base {
+				return nil
+			}
+
+			aliases = append(
+				aliases,
+				lnwire.NewShortChanIDFromInt(byteOrder.Uint64(k)),
+			)
+
+			return nil
+		})
+	}, func() {
+		aliases = nil
+	})
+
+	return aliases, err
+}
+
+// FindBaseSCID returns the base ShortChannelID the passed alias maps to. If
+// the alias is unknown, ErrAliasNotFound is returned.
+func (c *ChannelStateDB) FindBaseSCID(
+	alias lnwire.ShortChannelID) (lnwire.ShortChannelID, error) {
+
+	var base lnwire.ShortChannelID
+	err := kvdb.View(c.backend, func(tx kvdb.RTx) error {
+		bucket := tx.ReadBucket(aliasBucket)
+		if bucket == nil {
+			return ErrAliasNotFound
+		}
+
+		baseBytes := bucket.Get(scidKey(alias))
+		if baseBytes == nil {
+			return ErrAliasNotFound
+		}
+
+		base = lnwire.NewShortChanIDFromInt(byteOrder.Uint64(baseBytes))
+
+		return nil
+	}, func() {
+		base = lnwire.ShortChannelID{}
+	})
+
+	return base, err
+}
+
+// PutPeerAlias stores the alias ShortChannelID our peer sent us for the
+// channel with the passed channel ID.
+func (c *ChannelStateDB) PutPeerAlias(chanID lnwire.ChannelID,
+	alias lnwire.ShortChannelID) error {
+
+	return kvdb.Update(c.backend, func(tx kvdb.RwTx) error {
+		bucket, err := tx.CreateTopLevelBucket(peerAliasBucket)
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put(chanID[:], scidKey(alias))
+	}, func() {})
+}
+
+// GetPeerAlias returns the alias ShortChannelID our peer sent us for the
+// channel with the passed channel ID. If no alias was received,
+// ErrNoPeerAlias is returned.
+func (c *ChannelStateDB) GetPeerAlias(
+	chanID lnwire.ChannelID) (lnwire.ShortChannelID, error) {
+
+	var alias lnwire.ShortChannelID
+	err := kvdb.View(c.backend, func(tx kvdb.RTx) error {
+		bucket := tx.ReadBucket(peerAliasBucket)
+		if bucket == nil {
+			return ErrNoPeerAlias
+		}
+
+		aliasBytes := bucket.Get(chanID[:])
+		if aliasBytes == nil {
+			return ErrNoPeerAlias
+		}
+
+		alias = lnwire.NewShortChanIDFromInt(byteOrder.Uint64(aliasBytes))
+
+		return nil
+	}, func() {
+		alias = lnwire.ShortChannelID{}
+	})
+
+	return alias, err
+}
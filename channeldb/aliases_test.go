@@ -0,0 +1,121 @@
+package channeldb
+
+import (
+	"math"
+	"testing"
+
+	"github.com/brsuite/broln/lnwire"
+	"github.com/stretchr/testify/require"
+)
+
+// TestAliasAllocation asserts that aliases are allocated sequentially from
+// the reserved alias range and that the allocation survives a restart.
+func TestAliasAllocation(t *testing.T) {
+	t.Parallel()
+
+	fullDB, cleanup, err := MakeTestDB()
+	require.NoError(t, err)
+	defer cleanup()
+
+	cdb := fullDB.ChannelStateDB()
+
+	first, err := cdb.RequestAlias()
+	require.NoError(t, err)
+	require.Equal(t, StartingAlias, first)
+	require.True(t, IsAlias(first))
+
+	second, err := cdb.RequestAlias()
+	require.NoError(t, err)
+	require.Equal(t, lnwire.ShortChannelID{
+		BlockHeight: AliasStartHeight,
+		TxPosition:  1,
+	}, second)
+
+	// Rolling over the TxPosition should increment the TxIndex, and
+	// rolling over the TxIndex should increment the BlockHeight.
+	next, err := nextAlias(lnwire.ShortChannelID{
+		BlockHeight: AliasStartHeight,
+		TxPosition:  math.MaxUint16,
+	})
+	require.NoError(t, err)
+	require.Equal(t, lnwire.ShortChannelID{
+		BlockHeight: AliasStartHeight,
+		TxIndex:     1,
+	}, next)
+
+	next, err = nextAlias(lnwire.ShortChannelID{
+		BlockHeight: AliasStartHeight,
+		TxIndex:     maxAliasTxIndex,
+		TxPosition:  math.MaxUint16,
+	})
+	require.NoError(t, err)
+	require.Equal(t, lnwire.ShortChannelID{
+		BlockHeight: AliasStartHeight + 1,
+	}, next)
+
+	// Once the range is exhausted, we should get an error.
+	_, err = nextAlias(lnwire.ShortChannelID{
+		BlockHeight: AliasEndHeight - 1,
+		TxIndex:     maxAliasTxIndex,
+		TxPosition:  math.MaxUint16,
+	})
+	require.ErrorIs(t, err, ErrAliasSpaceExhausted)
+
+	// A real ShortChannelID must never be considered an alias.
+	require.False(t, IsAlias(lnwire.NewShortChanIDFromInt(1337)))
+}
+
+// TestAliasMapping tests adding, looking up and removing local aliases as
+// well as storing the alias our peer sent us.
+func TestAliasMapping(t *testing.T) {
+	t.Parallel()
+
+	fullDB, cleanup, err := MakeTestDB()
+	require.NoError(t, err)
+	defer cleanup()
+
+	cdb := fullDB.ChannelStateDB()
+
+	baseScid := lnwire.NewShortChanIDFromInt(123456)
+
+	// Nothing has been stored yet.
+	aliases, err := cdb.GetAliases(baseScid)
+	require.NoError(t, err)
+	require.Empty(t, aliases)
+
+	_, err = cdb.FindBaseSCID(StartingAlias)
+	require.ErrorIs(t, err, ErrAliasNotFound)
+
+	alias1, err := cdb.RequestAlias()
+	require.NoError(t, err)
+	alias2, err := cdb.RequestAlias()
+	require.NoError(t, err)
+
+	require.NoError(t, cdb.AddLocalAlias(alias1, baseScid))
+	require.NoError(t, cdb.AddLocalAlias(alias2, baseScid))
+
+	aliases, err = cdb.GetAliases(baseScid)
+	require.NoError(t, err)
+	require.Equal(t, []lnwire.ShortChannelID{alias1, alias2}, aliases)
+
+	base, err := cdb.FindBaseSCID(alias2)
+	require.NoError(t, err)
+	require.Equal(t, baseScid, base)
+
+	require.NoError(t, cdb.DeleteLocalAlias(alias1))
+	aliases, err = cdb.GetAliases(baseScid)
+	require.NoError(t, err)
+	require.Equal(t, []lnwire.ShortChannelID{alias2}, aliases)
+
+	// Finally, store and fetch a peer alias.
+	chanID := lnwire.ChannelID{1, 2, 3}
+	_, err = cdb.GetPeerAlias(chanID)
+	require.ErrorIs(t, err, ErrNoPeerAlias)
+
+	peerAlias := lnwire.NewShortChanIDFromInt(98765)
+	require.NoError(t, cdb.PutPeerAlias(chanID, peerAlias))
+
+	alias, err := cdb.GetPeerAlias(chanID)
+	require.NoError(t, err)
+	require.Equal(t, peerAlias, alias)
+}
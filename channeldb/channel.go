@@ -198,6 +198,10 @@ const (
 	// A tlv type definition used to serialize and deserialize a KeyLocator
 	// from the database.
 	keyLocType tlv.Type = 1
+
+	// A tlv type used to serialize and deserialize the confirmed
+	// ShortChannelID of a zero-conf channel.
+	realScidType tlv.Type = 2
 )
 
 // indexStatus is an enum-like type that describes what state the
@@ -219,7 +223,10 @@ const (
 // fee negotiation, channel closing, the format of HTLCs, etc. Structure-wise,
 // a ChannelType is a bit field, with each bit denoting a modification from the
 // base channel type of single funder.
-type ChannelType uint8
+//
+// NOTE: The channel type is serialized as a variable length integer, so types
+// that only use the lower seven bits remain a single byte on disk.
+type ChannelType uint64
 
 const (
 	// NOTE: iota isn't used here for this enum needs to be stable
@@ -262,6 +269,21 @@ const (
 	// period of time, constraining every output that pays to the channel
 	// initiator with an additional CLTV of the lease maturity.
 	LeaseExpirationBit ChannelType = 1 << 6
+
+	// ZeroConfBit indicates that the channel is a zero-conf channel, and
+	// may be used before its funding transaction has confirmed.
+	ZeroConfBit ChannelType = 1 << 7
+
+	// ScidAliasChanBit indicates that the channel has negotiated the
+	// option_scid_alias channel type, meaning the real ShortChannelID
+	// must never be used to refer to the channel in forwarding or
+	// invoice route hints.
+	ScidAliasChanBit ChannelType = 1 << 8
+
+	// ScidAliasFeatureBit indicates that the scid-alias feature bit was
+	// negotiated during the lifetime of this channel, so the peer knows
+	// how to handle alias ShortChannelIDs in funding_locked.
+	ScidAliasFeatureBit ChannelType = 1 << 9
 )
 
 // IsSingleFunder returns true if the channel type if one of the known single
@@ -311,6 +333,23 @@ func (c ChannelType) HasLeaseExpiration() bool {
 	return c&LeaseExpirationBit == LeaseExpirationBit
 }
 
+// HasZeroConf returns true if the channel is a zero-conf channel.
+func (c ChannelType) HasZeroConf() bool {
+	return c&ZeroConfBit == ZeroConfBit
+}
+
+// HasScidAliasChan returns true if the option_scid_alias channel type was
+// negotiated.
+func (c ChannelType) HasScidAliasChan() bool {
+	return c&ScidAliasChanBit == ScidAliasChanBit
+}
+
+// HasScidAliasFeature returns true if the scid-alias feature bit was
+// negotiated during the lifetime of this channel.
+func (c ChannelType) HasScidAliasFeature() bool {
+	return c&ScidAliasFeatureBit == ScidAliasFeatureBit
+}
+
 // ChannelConstraints represents a set of constraints meant to allow a node to
 // limit their exposure, enact flow control and ensure that all HTLCs are
 // economically relevant. This struct will be mirrored for both sides of the
@@ -612,8 +651,15 @@ type OpenChannel struct {
 	// ShortChannelID encodes the exact location in the chain in which the
 	// channel was initially confirmed. This includes: the block height,
 	// transaction index, and the output within the target transaction.
+	//
+	// NOTE: For zero-conf channels this is an alias ShortChannelID that
+	// was assigned when the channel was opened.
 	ShortChannelID lnwire.ShortChannelID
 
+	// confirmedScid is the confirmed ShortChannelID of a zero-conf
+	// channel. It is unset until the funding transaction has confirmed.
+	confirmedScid lnwire.ShortChannelID
+
 	// IsPending indicates whether a channel's funding transaction has been
 	// confirmed.
 	IsPending bool
@@ -754,6 +800,50 @@ func (c *OpenChannel) ShortChanID() lnwire.ShortChannelID {
 	return c.ShortChannelID
 }
 
+// IsZeroConf returns whether the option_zeroconf channel type was negotiated.
+func (c *OpenChannel) IsZeroConf() bool {
+	c.RLock()
+	defer c.RUnlock()
+
+	return c.ChanType.HasZeroConf()
+}
+
+// IsOptionScidAlias returns whether the option_scid_alias channel type was
+// negotiated.
+func (c *OpenChannel) IsOptionScidAlias() bool {
+	c.RLock()
+	defer c.RUnlock()
+
+	return c.ChanType.HasScidAliasChan()
+}
+
+// NegotiatedAliasFeature returns whether the option-scid-alias feature bit was
+// negotiated.
+func (c *OpenChannel) NegotiatedAliasFeature() bool {
+	c.RLock()
+	defer c.RUnlock()
+
+	return c.ChanType.HasScidAliasFeature()
+}
+
+// ZeroConfConfirmed returns whether the zero-conf channel has confirmed. This
+// should only be called if IsZeroConf returns true.
+func (c *OpenChannel) ZeroConfConfirmed() bool {
+	c.RLock()
+	defer c.RUnlock()
+
+	return !c.confirmedScid.IsDefault()
+}
+
+// ZeroConfRealScid returns the real confirmed ShortChannelID of a zero-conf
+// channel. This should only be called if ZeroConfConfirmed returns true.
+func (c *OpenChannel) ZeroConfRealScid() lnwire.ShortChannelID {
+	c.RLock()
+	defer c.RUnlock()
+
+	return c.confirmedScid
+}
+
 // ChanStatus returns the current ChannelStatus of this channel.
 func (c *OpenChannel) ChanStatus() ChannelStatus {
 	c.RLock()
@@ -1033,6 +1123,37 @@ func (c *OpenChannel) MarkAsOpen(openLoc lnwire.ShortChannelID) error {
 	return nil
 }
 
+// MarkRealScid marks the zero-conf channel's confirmed ShortChannelID. This
+// should only be done if IsZeroConf returns true.
+func (c *OpenChannel) MarkRealScid(realScid lnwire.ShortChannelID) error {
+	c.Lock()
+	defer c.Unlock()
+
+	if err := kvdb.Update(c.Db.backend, func(tx kvdb.RwTx) error {
+		chanBucket, err := fetchChanBucketRw(
+			tx, c.IdentityPub, &c.FundingOutpoint, c.ChainHash,
+		)
+		if err != nil {
+			return err
+		}
+
+		channel, err := fetchOpenChannel(chanBucket, &c.FundingOutpoint)
+		if err != nil {
+			return err
+		}
+
+		channel.confirmedScid = realScid
+
+		return putOpenChannel(chanBucket, channel)
+	}, func() {}); err != nil {
+		return err
+	}
+
+	c.confirmedScid = realScid
+
+	return nil
+}
+
 // MarkDataLoss marks sets the channel status to LocalDataLoss and stores the
 // passed commitPoint for use to retrieve funds in case the remote force closes
 // the channel.
@@ -1757,6 +1878,36 @@ type HTLC struct {
 	// from the HtlcIndex as this will be incremented for each new log
 	// update added.
 	LogIndex uint64
+
+	// ExtraData contains the TLV records that were sent along with the
+	// update_add_htlc message of this HTLC, such as its blinding point.
+	//
+	// NOTE: The extra data is stored right behind the fixed size onion
+	// blob, so it is only persisted for HTLCs that carry a full onion.
+	ExtraData lnwire.ExtraOpaqueData
+}
+
+// BlindingPoint returns the blinding point that was sent along with the HTLC,
+// or nil if the HTLC wasn't received within a blinded route.
+func (h *HTLC) BlindingPoint() (*btcec.PublicKey, error) {
+	if len(h.ExtraData) == 0 {
+		return nil, nil
+	}
+
+	var blindingPoint lnwire.BlindingPoint
+	typeMap, err := h.ExtraData.ExtractRecords(&blindingPoint)
+	if err != nil {
+		return nil, err
+	}
+
+	val, ok := typeMap[lnwire.BlindingPointRecordType]
+	if !ok || val != nil {
+		return nil, nil
+	}
+
+	key := btcec.PublicKey(blindingPoint)
+
+	return &key, nil
 }
 
 // SerializeHtlcs writes out the passed set of HTLC's into the passed writer
@@ -1771,9 +1922,28 @@ func SerializeHtlcs(b io.Writer, htlcs ...HTLC) error {
 	}
 
 	for _, htlc := range htlcs {
+		// Any extra data is appended to the onion blob, which allows
+		// us to add it without changing the on-disk format. Older
+		// versions read it as part of the (opaque) onion blob.
+		onionAndExtraData := htlc.OnionBlob
+		if len(htlc.OnionBlob) == lnwire.OnionPacketSize &&
+			len(htlc.ExtraData) > 0 {
+
+			onionAndExtraData = make(
+				[]byte, 0, len(htlc.OnionBlob)+
+					len(htlc.ExtraData),
+			)
+			onionAndExtraData = append(
+				onionAndExtraData, htlc.OnionBlob...,
+			)
+			onionAndExtraData = append(
+				onionAndExtraData, htlc.ExtraData...,
+			)
+		}
+
 		if err := WriteElements(b,
 			htlc.Signature, htlc.RHash, htlc.Amt, htlc.RefundTimeout,
-			htlc.OutputIndex, htlc.Incoming, htlc.OnionBlob[:],
+			htlc.OutputIndex, htlc.Incoming, onionAndExtraData,
 			htlc.HtlcIndex, htlc.LogIndex,
 		); err != nil {
 			return err
@@ -1810,6 +1980,15 @@ func DeserializeHtlcs(r io.Reader) ([]HTLC, error) {
 		); err != nil {
 			return htlcs, err
 		}
+
+		// Split off any extra data that was stored behind the onion
+		// blob.
+		const onionSize = lnwire.OnionPacketSize
+		onionAndExtraData := htlcs[i].OnionBlob
+		if len(onionAndExtraData) > onionSize {
+			htlcs[i].OnionBlob = onionAndExtraData[:onionSize]
+			htlcs[i].ExtraData = onionAndExtraData[onionSize:]
+		}
 	}
 
 	return htlcs, nil
@@ -3347,12 +3526,19 @@ func putChanInfo(chanBucket kvdb.RwBucket, channel *OpenChannel) error {
 		return err
 	}
 
-	// Write the RevocationKeyLocator as the first entry in a tlv stream.
+	// Write the RevocationKeyLocator as the first entry in a tlv stream,
+	// followed by the confirmed ShortChannelID of zero-conf channels.
 	keyLocRecord := MakeKeyLocRecord(
 		keyLocType, &channel.RevocationKeyLocator,
 	)
 
-	tlvStream, err := tlv.NewStream(keyLocRecord)
+	tlvStream, err := tlv.NewStream(
+		keyLocRecord,
+		tlv.MakeStaticRecord(
+			realScidType, &channel.confirmedScid, 8,
+			lnwire.EShortChannelID, lnwire.DShortChannelID,
+		),
+	)
 	if err != nil {
 		return err
 	}
@@ -3551,7 +3737,13 @@ func fetchChanInfo(chanBucket kvdb.RBucket, channel *OpenChannel) error {
 	}
 
 	keyLocRecord := MakeKeyLocRecord(keyLocType, &channel.RevocationKeyLocator)
-	tlvStream, err := tlv.NewStream(keyLocRecord)
+	tlvStream, err := tlv.NewStream(
+		keyLocRecord,
+		tlv.MakeStaticRecord(
+			realScidType, &channel.confirmedScid, 8,
+			lnwire.EShortChannelID, lnwire.DShortChannelID,
+		),
+	)
 	if err != nil {
 		return err
 	}
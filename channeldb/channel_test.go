@@ -1271,6 +1271,54 @@ func TestRefreshShortChanID(t *testing.T) {
 	}
 }
 
+// TestZeroConfRealScid tests that the confirmed ShortChannelID of a zero-conf
+// channel, along with its channel type, is persisted and read back properly.
+func TestZeroConfRealScid(t *testing.T) {
+	t.Parallel()
+
+	fullDB, cleanUp, err := MakeTestDB()
+	require.NoError(t, err)
+	defer cleanUp()
+
+	cdb := fullDB.ChannelStateDB()
+
+	// Create a zero-conf channel that is identified by an alias. The
+	// channel type bits used here don't fit in a single byte anymore.
+	chanType := SingleFunderTweaklessBit | ZeroConfBit | ScidAliasChanBit |
+		ScidAliasFeatureBit
+	state := createTestChannel(
+		t, cdb, channelIDOption(StartingAlias),
+		func(params *testChannelParams) {
+			params.channel.ChanType = chanType
+		},
+	)
+	require.True(t, state.IsZeroConf())
+	require.True(t, state.IsOptionScidAlias())
+	require.True(t, state.NegotiatedAliasFeature())
+	require.False(t, state.ZeroConfConfirmed())
+
+	realScid := lnwire.ShortChannelID{
+		BlockHeight: 105,
+		TxIndex:     10,
+		TxPosition:  15,
+	}
+	require.NoError(t, state.MarkRealScid(realScid))
+	require.True(t, state.ZeroConfConfirmed())
+	require.Equal(t, realScid, state.ZeroConfRealScid())
+
+	// Fetching the channel from disk should return the same channel type
+	// and confirmed ShortChannelID, while the alias is left untouched.
+	channels, err := cdb.FetchPendingChannels()
+	require.NoError(t, err)
+	require.Len(t, channels, 1)
+
+	dbChannel := channels[0]
+	require.Equal(t, chanType, dbChannel.ChanType)
+	require.Equal(t, StartingAlias, dbChannel.ShortChanID())
+	require.True(t, dbChannel.ZeroConfConfirmed())
+	require.Equal(t, realScid, dbChannel.ZeroConfRealScid())
+}
+
 // TestCloseInitiator tests the setting of close initiator statuses for
 // cooperative closes and local force closes.
 func TestCloseInitiator(t *testing.T) {
@@ -1669,3 +1717,46 @@ func TestKeyLocatorEncoding(t *testing.T) {
 	// version are equal.
 	require.Equal(t, keyLoc, decodedKeyLoc)
 }
+
+// TestHTLCExtraDataRoundTrip asserts that the extra data of an HTLC, such as
+// its blinding point, survives serialization next to the onion blob.
+func TestHTLCExtraDataRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	blindingPoint := lnwire.BlindingPoint(*pubKey)
+	var extraData lnwire.ExtraOpaqueData
+	err := lnwire.EncodeMessageExtraData(&extraData, &blindingPoint)
+	require.NoError(t, err)
+
+	htlcs := []HTLC{
+		{
+			Signature: testSig.Serialize(),
+			RHash:     key,
+			Amt:       10,
+			Incoming:  true,
+			OnionBlob: make([]byte, lnwire.OnionPacketSize),
+			ExtraData: extraData,
+		},
+		{
+			Signature: testSig.Serialize(),
+			RHash:     key,
+			Amt:       10,
+			OnionBlob: make([]byte, lnwire.OnionPacketSize),
+		},
+	}
+
+	var b bytes.Buffer
+	require.NoError(t, SerializeHtlcs(&b, htlcs...))
+
+	decoded, err := DeserializeHtlcs(&b)
+	require.NoError(t, err)
+	require.Equal(t, htlcs, decoded)
+
+	point, err := decoded[0].BlindingPoint()
+	require.NoError(t, err)
+	require.True(t, point.IsEqual(pubKey))
+
+	point, err = decoded[1].BlindingPoint()
+	require.NoError(t, err)
+	require.Nil(t, point)
+}
@@ -2607,6 +2607,81 @@ func settleHtlcsAmp(invoice *Invoice,
 	updateMap[setID][circuitKey] = htlc
 }
 
+// invoiceIndexer abstracts the index bookkeeping that needs to be performed
+// while an invoice update is applied. This allows the same update logic to be
+// shared between the kv invoice store and the native SQL invoice store.
+type invoiceIndexer interface {
+	// addSetID indexes the passed AMP set ID for the invoice being
+	// updated. ErrDuplicateSetID is returned if the set ID is already
+	// used by another invoice.
+	addSetID(setID SetID) error
+
+	// nextSettleIndex reserves the next settle index for the invoice
+	// being updated. If setID is non-nil, then the settle index is
+	// reserved for that AMP sub-invoice.
+	nextSettleIndex(setID *SetID) (uint64, error)
+}
+
+// kvInvoiceIndexer is an invoiceIndexer that is backed by the settle and set
+// ID index buckets of the kv invoice store.
+type kvInvoiceIndexer struct {
+	settleIndex kvdb.RwBucket
+	setIDIndex  kvdb.RwBucket
+	invoiceNum  []byte
+}
+
+// addSetID indexes the passed AMP set ID for the invoice being updated.
+//
+// NOTE: This is part of the invoiceIndexer interface.
+func (k *kvInvoiceIndexer) addSetID(setID SetID) error {
+	setIDInvNum := k.setIDIndex.Get(setID[:])
+	switch {
+	case setIDInvNum == nil:
+		return k.setIDIndex.Put(setID[:], k.invoiceNum)
+
+	case !bytes.Equal(setIDInvNum, k.invoiceNum):
+		return ErrDuplicateSetID{setID: setID}
+	}
+
+	return nil
+}
+
+// nextSettleIndex reserves the next settle index for the invoice being
+// updated. If a non-nil setID is passed in, then the value will be appended to
+// the invoice number as well, in order to allow us to detect repeated payments
+// to the same AMP invoices "across time".
+//
+// NOTE: This is part of the invoiceIndexer interface.
+func (k *kvInvoiceIndexer) nextSettleIndex(setID *SetID) (uint64, error) {
+	// Now that we know the invoice hasn't already been settled, we'll
+	// update the settle index so we can place this settle event in the
+	// proper location within our time series.
+	nextSettleSeqNo, err := k.settleIndex.NextSequence()
+	if err != nil {
+		return 0, err
+	}
+
+	// Make a new byte array on the stack that can potentially store the 4
+	// byte invoice number along w/ the 32 byte set ID. We capture valueLen
+	// here which is the number of bytes copied so we can only store the 4
+	// bytes if this is a non-AMP invoice.
+	var indexKey [invoiceSetIDKeyLen]byte
+	valueLen := copy(indexKey[:], k.invoiceNum)
+
+	if setID != nil {
+		valueLen += copy(indexKey[valueLen:], setID[:])
+	}
+
+	var seqNoBytes [8]byte
+	byteOrder.PutUint64(seqNoBytes[:], nextSettleSeqNo)
+	err = k.settleIndex.Put(seqNoBytes[:], indexKey[:valueLen])
+	if err != nil {
+		return 0, err
+	}
+
+	return nextSettleSeqNo, nil
+}
+
 // updateInvoice fetches the invoice, obtains the update descriptor from the
 // callback and applies the updates in a single db transaction.
 func (d *DB) updateInvoice(hash *lntypes.Hash, refSetID *SetID, invoices,
@@ -2641,6 +2716,49 @@ func (d *DB) updateInvoice(hash *lntypes.Hash, refSetID *SetID, invoices,
 		return &invoice, nil
 	}
 
+	indexer := &kvInvoiceIndexer{
+		settleIndex: settleIndex,
+		setIDIndex:  setIDIndex,
+		invoiceNum:  invoiceNum,
+	}
+	htlcsAmpUpdate, err := applyInvoiceUpdate(
+		&invoice, hash, update, d.clock.Now(), indexer,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Reserialize and update invoice.
+	var buf bytes.Buffer
+	if err := serializeInvoice(&buf, &invoice); err != nil {
+		return nil, err
+	}
+
+	if err := invoices.Put(invoiceNum[:], buf.Bytes()); err != nil {
+		return nil, err
+	}
+
+	// If this is an AMP invoice, then we'll actually store the rest of the
+	// HTLCs in-line with the invoice, using the invoice ID as a prefix,
+	// and the AMP key as a suffix: invoiceNum || setID.
+	if invoice.Terms.Features.HasFeature(lnwire.AMPOptional) {
+		err := updateAMPInvoices(invoices, invoiceNum, htlcsAmpUpdate)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &invoice, nil
+}
+
+// applyInvoiceUpdate applies the update descriptor to the passed invoice in
+// place. The passed indexer is used to maintain the set ID and settle indexes
+// of the backing store. The set of AMP HTLCs that were modified is returned,
+// keyed by their set ID, so that the caller can persist them.
+func applyInvoiceUpdate(invoice *Invoice, hash *lntypes.Hash,
+	update *InvoiceUpdateDesc, now time.Time,
+	indexer invoiceIndexer) (map[SetID]map[CircuitKey]*InvoiceHTLC, error) {
+
 	var (
 		newState = invoice.State
 		setID    *[32]byte
@@ -2659,9 +2777,7 @@ func (d *DB) updateInvoice(hash *lntypes.Hash, refSetID *SetID, invoices,
 		setID = (*[32]byte)(update.SetID)
 	}
 
-	now := d.clock.Now()
-
-	invoiceIsAMP := invoiceCopy.Terms.Features.HasFeature(
+	invoiceIsAMP := invoice.Terms.Features.HasFeature(
 		lnwire.AMPOptional,
 	)
 
@@ -2684,14 +2800,8 @@ func (d *DB) updateInvoice(hash *lntypes.Hash, refSetID *SetID, invoices,
 		var setID [32]byte
 		if htlcUpdate.AMP != nil {
 			setID = htlcUpdate.AMP.Record.SetID()
-			setIDInvNum := setIDIndex.Get(setID[:])
-			if setIDInvNum == nil {
-				err = setIDIndex.Put(setID[:], invoiceNum)
-				if err != nil {
-					return nil, err
-				}
-			} else if !bytes.Equal(setIDInvNum, invoiceNum) {
-				return nil, ErrDuplicateSetID{setID: setID}
+			if err := indexer.addSetID(setID); err != nil {
+				return nil, err
 			}
 		}
 
@@ -2712,7 +2822,7 @@ func (d *DB) updateInvoice(hash *lntypes.Hash, refSetID *SetID, invoices,
 		// below, but only if this is an AMP invoice.
 		if invoiceIsAMP {
 			updateHtlcsAmp(
-				&invoice, htlcsAmpUpdate, htlc, setID, key,
+				invoice, htlcsAmpUpdate, htlc, setID, key,
 			)
 		}
 	}
@@ -2748,7 +2858,7 @@ func (d *DB) updateInvoice(hash *lntypes.Hash, refSetID *SetID, invoices,
 		// disk, but once again, only if this is an AMP invoice.
 		if invoiceIsAMP {
 			cancelHtlcsAmp(
-				&invoice, htlcsAmpUpdate, htlc, key,
+				invoice, htlcsAmpUpdate, htlc, key,
 			)
 		}
 	}
@@ -2766,7 +2876,7 @@ func (d *DB) updateInvoice(hash *lntypes.Hash, refSetID *SetID, invoices,
 	// HTLCs.
 	if update.State != nil {
 		newState, err := updateInvoiceState(
-			&invoice, hash, *update.State,
+			invoice, hash, *update.State,
 		)
 		if err != nil {
 			return nil, err
@@ -2787,7 +2897,7 @@ func (d *DB) updateInvoice(hash *lntypes.Hash, refSetID *SetID, invoices,
 		// setSettleMetaFields.
 		if !invoiceIsAMP && update.State.NewState == ContractSettled {
 			err := setSettleMetaFields(
-				settleIndex, invoiceNum, &invoice, now, nil,
+				indexer, invoice, now, nil,
 			)
 			if err != nil {
 				return nil, err
@@ -2853,7 +2963,7 @@ func (d *DB) updateInvoice(hash *lntypes.Hash, refSetID *SetID, invoices,
 		// meta data state.
 		if htlcSettled && invoiceIsAMP {
 			settleHtlcsAmp(
-				&invoice, settledSetIDs, htlcsAmpUpdate, htlc, key,
+				invoice, settledSetIDs, htlcsAmpUpdate, htlc, key,
 			)
 		}
 
@@ -2899,34 +3009,14 @@ func (d *DB) updateInvoice(hash *lntypes.Hash, refSetID *SetID, invoices,
 	for settledSetID := range settledSetIDs {
 		settledSetID := settledSetID
 		err := setSettleMetaFields(
-			settleIndex, invoiceNum, &invoice, now, &settledSetID,
+			indexer, invoice, now, &settledSetID,
 		)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	// Reserialize and update invoice.
-	var buf bytes.Buffer
-	if err := serializeInvoice(&buf, &invoice); err != nil {
-		return nil, err
-	}
-
-	if err := invoices.Put(invoiceNum[:], buf.Bytes()); err != nil {
-		return nil, err
-	}
-
-	// If this is an AMP invoice, then we'll actually store the rest of the
-	// HTLCs in-line with the invoice, using the invoice ID as a prefix,
-	// and the AMP key as a suffix: invoiceNum || setID.
-	if invoiceIsAMP {
-		err := updateAMPInvoices(invoices, invoiceNum, htlcsAmpUpdate)
-		if err != nil {
-			return nil, err
-		}
-	}
-
-	return &invoice, nil
+	return htlcsAmpUpdate, nil
 }
 
 // updateInvoiceState validates and processes an invoice state update. The new
@@ -3138,37 +3228,17 @@ func updateHtlc(resolveTime time.Time, htlc *InvoiceHTLC,
 }
 
 // setSettleMetaFields updates the metadata associated with settlement of an
-// invoice. If a non-nil setID is passed in, then the value will be append to
-// the invoice number as well, in order to allow us to detect repeated payments
-// to the same AMP invoices "across time".
-func setSettleMetaFields(settleIndex kvdb.RwBucket, invoiceNum []byte,
-	invoice *Invoice, now time.Time, setID *SetID) error {
+// invoice. If a non-nil setID is passed in, then the settle metadata of that
+// AMP sub-invoice is updated instead, in order to allow us to detect repeated
+// payments to the same AMP invoices "across time".
+func setSettleMetaFields(indexer invoiceIndexer, invoice *Invoice,
+	now time.Time, setID *SetID) error {
 
-	// Now that we know the invoice hasn't already been settled, we'll
-	// update the settle index so we can place this settle event in the
-	// proper location within our time series.
-	nextSettleSeqNo, err := settleIndex.NextSequence()
+	nextSettleSeqNo, err := indexer.nextSettleIndex(setID)
 	if err != nil {
 		return err
 	}
 
-	// Make a new byte array on the stack that can potentially store the 4
-	// byte invoice number along w/ the 32 byte set ID. We capture valueLen
-	// here which is the number of bytes copied so we can only store the 4
-	// bytes if this is a non-AMP invoice.
-	var indexKey [invoiceSetIDKeyLen]byte
-	valueLen := copy(indexKey[:], invoiceNum)
-
-	if setID != nil {
-		valueLen += copy(indexKey[valueLen:], setID[:])
-	}
-
-	var seqNoBytes [8]byte
-	byteOrder.PutUint64(seqNoBytes[:], nextSettleSeqNo)
-	if err := settleIndex.Put(seqNoBytes[:], indexKey[:valueLen]); err != nil {
-		return err
-	}
-
 	// If the setID is nil, then this means that this is a non-AMP settle,
 	// so we'll update the invoice settle index directly.
 	if setID == nil {
@@ -0,0 +1,1347 @@
+package channeldb
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/brsuite/broln/clock"
+	"github.com/brsuite/broln/htlcswitch/hop"
+	"github.com/brsuite/broln/kvdb"
+	"github.com/brsuite/broln/lntypes"
+	"github.com/brsuite/broln/lnwire"
+	"github.com/brsuite/broln/record"
+	"github.com/brsuite/broln/tlv"
+)
+
+const (
+	// sqlInvoiceAddSeq is the name of the sequence that is used to assign
+	// add indexes to newly added invoices.
+	sqlInvoiceAddSeq = "invoice_add_index"
+
+	// sqlInvoiceSettleSeq is the name of the sequence that is used to
+	// assign settle indexes to settled invoices and AMP sub-invoices.
+	sqlInvoiceSettleSeq = "invoice_settle_index"
+
+	// sqlInvoiceSchema is the schema of the native SQL invoice store. The
+	// only difference between the supported backends is the type used for
+	// binary columns, which is filled in as the first format argument.
+	//
+	// The add and settle indexes are assigned from the invoice_sequences
+	// table rather than from an auto-incrementing column, so that they are
+	// never reused, not even after invoices are deleted. The settle index
+	// sequence is shared between invoices and AMP sub-invoices.
+	sqlInvoiceSchema = `
+CREATE TABLE IF NOT EXISTS invoice_sequences (
+	name TEXT PRIMARY KEY,
+	current_value BIGINT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS invoices (
+	add_index BIGINT PRIMARY KEY,
+	hash %[1]s NOT NULL UNIQUE,
+	preimage %[1]s,
+	payment_addr %[1]s UNIQUE,
+	memo %[1]s,
+	payment_request %[1]s,
+	creation_date BIGINT NOT NULL,
+	settle_date BIGINT NOT NULL,
+	settle_index BIGINT UNIQUE,
+	value_msat BIGINT NOT NULL,
+	cltv_delta INTEGER NOT NULL,
+	expiry BIGINT NOT NULL,
+	features %[1]s,
+	state SMALLINT NOT NULL,
+	amt_paid_msat BIGINT NOT NULL,
+	is_hodl BOOLEAN NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS invoices_state_idx ON invoices (state);
+
+CREATE INDEX IF NOT EXISTS invoices_creation_date_idx
+	ON invoices (creation_date);
+
+CREATE TABLE IF NOT EXISTS invoice_htlcs (
+	invoice_id BIGINT NOT NULL REFERENCES invoices (add_index)
+		ON DELETE CASCADE,
+	chan_id BIGINT NOT NULL,
+	htlc_id BIGINT NOT NULL,
+	amt_msat BIGINT NOT NULL,
+	mpp_total_amt_msat BIGINT NOT NULL,
+	accept_height BIGINT NOT NULL,
+	accept_time BIGINT NOT NULL,
+	resolve_time BIGINT NOT NULL,
+	expiry_height BIGINT NOT NULL,
+	state SMALLINT NOT NULL,
+	custom_records %[1]s,
+	amp_root_share %[1]s,
+	amp_set_id %[1]s,
+	amp_child_index BIGINT,
+	amp_hash %[1]s,
+	amp_preimage %[1]s,
+	PRIMARY KEY (invoice_id, chan_id, htlc_id)
+);
+
+CREATE INDEX IF NOT EXISTS invoice_htlcs_amp_set_id_idx
+	ON invoice_htlcs (amp_set_id);
+
+CREATE TABLE IF NOT EXISTS amp_sub_invoices (
+	set_id %[1]s PRIMARY KEY,
+	invoice_id BIGINT NOT NULL REFERENCES invoices (add_index)
+		ON DELETE CASCADE,
+	state SMALLINT NOT NULL,
+	settle_index BIGINT UNIQUE,
+	settle_date BIGINT NOT NULL,
+	amt_paid_msat BIGINT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS amp_sub_invoices_invoice_id_idx
+	ON amp_sub_invoices (invoice_id);
+`
+
+	// sqlInvoiceColumns is the list of columns that is selected whenever
+	// an invoice is read, in the order expected by scanSQLInvoice.
+	sqlInvoiceColumns = `add_index, hash, preimage, payment_addr, memo,
+		payment_request, creation_date, settle_date, settle_index,
+		value_msat, cltv_delta, expiry, features, state, amt_paid_msat,
+		is_hodl`
+
+	// sqlHtlcColumns is the list of columns that is selected whenever an
+	// invoice htlc is read, in the order expected by scanSQLHtlc.
+	sqlHtlcColumns = `chan_id, htlc_id, amt_msat, mpp_total_amt_msat,
+		accept_height, accept_time, resolve_time, expiry_height, state,
+		custom_records, amp_root_share, amp_set_id, amp_child_index,
+		amp_hash, amp_preimage`
+)
+
+// SQLInvoiceDB is an invoice store that keeps invoices, their HTLCs and AMP
+// sub-invoices in dedicated SQL tables, rather than as serialized blobs in the
+// kv buckets of the channel database. This allows lookups by state or date
+// and paginated queries without scanning all invoices.
+type SQLInvoiceDB struct {
+	db *sql.DB
+
+	clock clock.Clock
+
+	// writeMtx serializes all write transactions. The invoice update logic
+	// reads the invoice, applies the update in memory and writes it back,
+	// so we only allow a single writer at a time.
+	writeMtx sync.Mutex
+}
+
+// NewSQLInvoiceDB creates a new native SQL invoice store on top of the passed
+// database connection. The backend must be the name of the kvdb backend that
+// owns the connection, either kvdb.PostgresBackendName or
+// kvdb.SqliteBackendName. The invoice tables are created if they don't exist
+// yet.
+func NewSQLInvoiceDB(db *sql.DB, backend string,
+	clock clock.Clock) (*SQLInvoiceDB, error) {
+
+	var blobType string
+	switch backend {
+	case kvdb.PostgresBackendName:
+		blobType = "BYTEA"
+
+	case kvdb.SqliteBackendName:
+		blobType = "BLOB"
+
+	default:
+		return nil, fmt.Errorf("native SQL invoices are not supported "+
+			"by the %v backend", backend)
+	}
+
+	_, err := db.ExecContext(
+		context.Background(), fmt.Sprintf(sqlInvoiceSchema, blobType),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create invoice tables: %v",
+			err)
+	}
+
+	return &SQLInvoiceDB{
+		db:    db,
+		clock: clock,
+	}, nil
+}
+
+// executeTx runs the passed closure in a database transaction. The
+// transaction is committed if the closure returns without an error and rolled
+// back otherwise.
+func (s *SQLInvoiceDB) executeTx(readOnly bool, f func(tx *sql.Tx) error) error {
+	if !readOnly {
+		s.writeMtx.Lock()
+		defer s.writeMtx.Unlock()
+	}
+
+	tx, err := s.db.BeginTx(context.Background(), &sql.TxOptions{
+		ReadOnly: readOnly,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := f(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// AddInvoice inserts the targeted invoice into the database. If the invoice has
+// *any* payment hashes which already exists within the database, then the
+// insertion will be aborted and rejected due to the strict policy banning any
+// duplicate payment hashes. A side effect of this function is that it sets
+// AddIndex on newInvoice.
+func (s *SQLInvoiceDB) AddInvoice(newInvoice *Invoice,
+	paymentHash lntypes.Hash) (uint64, error) {
+
+	if err := validateInvoice(newInvoice, paymentHash); err != nil {
+		return 0, err
+	}
+
+	var invoiceAddIndex uint64
+	err := s.executeTx(false, func(tx *sql.Tx) error {
+		// Ensure that an invoice an identical payment hash doesn't
+		// already exist within the database.
+		_, found, err := queryAddIndex(
+			tx, `SELECT add_index FROM invoices WHERE hash = $1`,
+			paymentHash[:],
+		)
+		switch {
+		case err != nil:
+			return err
+
+		case found:
+			return ErrDuplicateInvoice
+		}
+
+		// Check that we aren't inserting an invoice with a duplicate
+		// payment address. The all-zeros payment address is
+		// special-cased to support legacy keysend invoices which don't
+		// assign one.
+		if newInvoice.Terms.PaymentAddr != BlankPayAddr {
+			_, found, err := queryAddIndex(
+				tx, `SELECT add_index FROM invoices
+				WHERE payment_addr = $1`,
+				newInvoice.Terms.PaymentAddr[:],
+			)
+			switch {
+			case err != nil:
+				return err
+
+			case found:
+				return ErrDuplicatePayAddr
+			}
+		}
+
+		addIndex, err := nextSQLSequence(tx, sqlInvoiceAddSeq)
+		if err != nil {
+			return err
+		}
+
+		newInvoice.AddIndex = addIndex
+		if err := insertSQLInvoice(tx, paymentHash, newInvoice); err != nil {
+			return err
+		}
+
+		invoiceAddIndex = addIndex
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return invoiceAddIndex, nil
+}
+
+// InvoicesAddedSince can be used by callers to seek into the event time series
+// of all the invoices added in the database. The specified sinceAddIndex
+// should be the highest add index that the caller knows of. This method will
+// return all invoices with an add index greater than the specified
+// sinceAddIndex.
+//
+// NOTE: The index starts from 1, as a result. We enforce that specifying a
+// value below the starting index value is a noop.
+func (s *SQLInvoiceDB) InvoicesAddedSince(sinceAddIndex uint64) ([]Invoice,
+	error) {
+
+	var newInvoices []Invoice
+
+	// If an index of zero was specified, then in order to maintain
+	// backwards compat, we won't send out any new invoices.
+	if sinceAddIndex == 0 {
+		return newInvoices, nil
+	}
+
+	err := s.executeTx(true, func(tx *sql.Tx) error {
+		return forEachSQLInvoice(
+			tx, `SELECT `+sqlInvoiceColumns+` FROM invoices
+			WHERE add_index > $1 ORDER BY add_index`,
+			[]interface{}{int64(sinceAddIndex)},
+			func(_ lntypes.Hash, invoice *Invoice) error {
+				newInvoices = append(newInvoices, *invoice)
+				return nil
+			},
+		)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return newInvoices, nil
+}
+
+// LookupInvoice attempts to look up an invoice according to its 32 byte
+// payment hash. If an invoice which can settle the HTLC identified by the
+// passed payment hash isn't found, then an error is returned. Otherwise, the
+// full invoice is returned. Before setting the incoming HTLC, the values
+// SHOULD be checked to ensure the payer meets the agreed upon contractual
+// terms of the payment.
+func (s *SQLInvoiceDB) LookupInvoice(ref InvoiceRef) (Invoice, error) {
+	var invoice Invoice
+	err := s.executeTx(true, func(tx *sql.Tx) error {
+		addIndex, err := fetchSQLInvoiceNumByRef(tx, ref)
+		if err != nil {
+			return err
+		}
+
+		var setID *SetID
+		switch {
+		// If this is a payment address ref, and the blank modified was
+		// specified, then we'll use the zero set ID to indicate that
+		// we won't want any HTLCs returned.
+		case ref.PayAddr() != nil && ref.Modifier() == HtlcSetBlankModifier:
+			var zeroSetID SetID
+			setID = &zeroSetID
+
+		// If this is a set ID ref, and the htlc set only modified was
+		// specified, then we'll pass through the specified setID so
+		// only that will be returned.
+		case ref.SetID() != nil && ref.Modifier() == HtlcSetOnlyModifier:
+			setID = (*SetID)(ref.SetID())
+		}
+
+		invoice, err = fetchSQLInvoice(tx, addIndex, setID)
+		return err
+	})
+	if err != nil {
+		return Invoice{}, err
+	}
+
+	return invoice, nil
+}
+
+// ScanInvoices scans trough all invoices and calls the passed scanFunc for
+// for each invoice with its respective payment hash. Additionally a reset()
+// closure is passed which is used to reset/initialize partial results.
+func (s *SQLInvoiceDB) ScanInvoices(
+	scanFunc func(lntypes.Hash, *Invoice) error, reset func()) error {
+
+	reset()
+
+	return s.executeTx(true, func(tx *sql.Tx) error {
+		return forEachSQLInvoice(
+			tx, `SELECT `+sqlInvoiceColumns+` FROM invoices
+			ORDER BY add_index`, nil, scanFunc,
+		)
+	})
+}
+
+// QueryInvoices allows a caller to query the invoice database for invoices
+// within the specified add index range. Unlike its kv counterpart, the offset,
+// limit and pending filter are all evaluated by the database.
+func (s *SQLInvoiceDB) QueryInvoices(q InvoiceQuery) (InvoiceSlice, error) {
+	resp := InvoiceSlice{
+		InvoiceQuery: q,
+	}
+
+	// The index offset is exclusive in both directions. When querying in
+	// reverse, an offset of zero means that we start from the very last
+	// invoice.
+	var (
+		conditions []string
+		args       []interface{}
+		order      = "ASC"
+	)
+	switch {
+	case !q.Reversed:
+		args = append(args, int64(q.IndexOffset))
+		conditions = append(conditions, "add_index > $1")
+
+	case q.IndexOffset != 0:
+		args = append(args, int64(q.IndexOffset))
+		conditions = append(conditions, "add_index < $1")
+		order = "DESC"
+
+	default:
+		order = "DESC"
+	}
+
+	if q.PendingOnly {
+		conditions = append(conditions, fmt.Sprintf("state IN (%d, %d)",
+			ContractOpen, ContractAccepted))
+	}
+
+	query := `SELECT ` + sqlInvoiceColumns + ` FROM invoices`
+	for i, condition := range conditions {
+		if i == 0 {
+			query += " WHERE " + condition
+		} else {
+			query += " AND " + condition
+		}
+	}
+
+	limit := q.NumMaxInvoices
+	if limit > math.MaxInt64 {
+		limit = math.MaxInt64
+	}
+	args = append(args, int64(limit))
+	query += fmt.Sprintf(" ORDER BY add_index %s LIMIT $%d", order,
+		len(args))
+
+	err := s.executeTx(true, func(tx *sql.Tx) error {
+		resp.Invoices = nil
+
+		return forEachSQLInvoice(
+			tx, query, args, func(_ lntypes.Hash,
+				invoice *Invoice) error {
+
+				resp.Invoices = append(resp.Invoices, *invoice)
+				return nil
+			},
+		)
+	})
+	if err != nil {
+		return resp, err
+	}
+
+	// If we iterated through the add index in reverse order, then we'll
+	// need to reverse the slice of invoices to return them in forward
+	// order.
+	if q.Reversed {
+		numInvoices := len(resp.Invoices)
+		for i := 0; i < numInvoices/2; i++ {
+			opposite := numInvoices - i - 1
+			resp.Invoices[i], resp.Invoices[opposite] =
+				resp.Invoices[opposite], resp.Invoices[i]
+		}
+	}
+
+	// Finally, record the indexes of the first and last invoices returned
+	// so that the caller can resume from this point later on.
+	if len(resp.Invoices) > 0 {
+		resp.FirstIndexOffset = resp.Invoices[0].AddIndex
+		resp.LastIndexOffset = resp.Invoices[len(resp.Invoices)-1].AddIndex
+	}
+
+	return resp, nil
+}
+
+// UpdateInvoice attempts to update an invoice corresponding to the passed
+// payment hash. If an invoice matching the passed payment hash doesn't exist
+// within the database, then the action will fail with a "not found" error.
+//
+// The update is performed inside the same database transaction that fetches the
+// invoice and is therefore atomic. The fields to update are controlled by the
+// supplied callback.
+func (s *SQLInvoiceDB) UpdateInvoice(ref InvoiceRef, setIDHint *SetID,
+	callback InvoiceUpdateCallback) (*Invoice, error) {
+
+	var updatedInvoice *Invoice
+	err := s.executeTx(false, func(tx *sql.Tx) error {
+		addIndex, err := fetchSQLInvoiceNumByRef(tx, ref)
+		if err != nil {
+			return err
+		}
+
+		updatedInvoice, err = s.updateInvoice(
+			tx, ref.PayHash(), addIndex, setIDHint, callback,
+		)
+
+		return err
+	})
+
+	return updatedInvoice, err
+}
+
+// updateInvoice fetches the invoice, obtains the update descriptor from the
+// callback and applies the updates within the passed transaction.
+func (s *SQLInvoiceDB) updateInvoice(tx *sql.Tx, hash *lntypes.Hash,
+	addIndex uint64, refSetID *SetID,
+	callback InvoiceUpdateCallback) (*Invoice, error) {
+
+	// If the set ID is non-nil, then we'll use that to filter out the
+	// HTLCs for AMP invoice so we don't need to read them all out to
+	// satisfy the invoice callback below. If it's nil, then we pass in the
+	// zero set ID which means no HTLCs will be read out.
+	var invSetID SetID
+	if refSetID != nil {
+		invSetID = *refSetID
+	}
+	invoice, err := fetchSQLInvoice(tx, addIndex, &invSetID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create deep copy to prevent any accidental modification in the
+	// callback.
+	invoiceCopy := copyInvoice(&invoice)
+
+	// Call the callback and obtain the update descriptor.
+	update, err := callback(invoiceCopy)
+	if err != nil {
+		return &invoice, err
+	}
+
+	// If there is nothing to update, return early.
+	if update == nil {
+		return &invoice, nil
+	}
+
+	indexer := &sqlInvoiceIndexer{
+		tx:       tx,
+		addIndex: addIndex,
+	}
+	_, err = applyInvoiceUpdate(
+		&invoice, hash, update, s.clock.Now(), indexer,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Write back the invoice level fields that may have been changed by
+	// the update. Every HTLC and AMP sub-invoice we have in memory is
+	// written as well, since we don't know which of them were modified.
+	// The untouched ones will simply be overwritten with the same values.
+	preimage, err := sqlPreimage(invoice.Terms.PaymentPreimage)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`UPDATE invoices SET preimage = $1, settle_date = $2,
+		settle_index = $3, state = $4, amt_paid_msat = $5
+		WHERE add_index = $6`,
+		preimage, int64(putNanoTime(invoice.SettleDate)),
+		sqlIndex(invoice.SettleIndex), int64(invoice.State),
+		int64(invoice.AmtPaid), int64(addIndex),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := putSQLInvoiceChildren(tx, &invoice); err != nil {
+		return nil, err
+	}
+
+	return &invoice, nil
+}
+
+// InvoicesSettledSince can be used by callers to catch up any settled invoices
+// they missed within the settled invoice time series. We'll return all known
+// settled invoice that have a settle index higher than the passed
+// sinceSettleIndex. Settled AMP sub-invoices are returned as the AMP invoice
+// populated with only the HTLCs of the settled set.
+//
+// NOTE: The index starts from 1, as a result. We enforce that specifying a
+// value below the starting index value is a noop.
+func (s *SQLInvoiceDB) InvoicesSettledSince(sinceSettleIndex uint64) (
+	[]Invoice, error) {
+
+	var settledInvoices []Invoice
+
+	// If an index of zero was specified, then in order to maintain
+	// backwards compat, we won't send out any new invoices.
+	if sinceSettleIndex == 0 {
+		return settledInvoices, nil
+	}
+
+	err := s.executeTx(true, func(tx *sql.Tx) error {
+		settledInvoices = nil
+
+		rows, err := tx.Query(`SELECT settle_index, add_index, NULL
+			FROM invoices WHERE settle_index > $1
+			UNION ALL
+			SELECT settle_index, invoice_id, set_id
+			FROM amp_sub_invoices WHERE settle_index > $1
+			ORDER BY settle_index`, int64(sinceSettleIndex),
+		)
+		if err != nil {
+			return err
+		}
+
+		// We collect the settle events first, as not all drivers allow
+		// issuing new queries while the rows are still being read.
+		type settleEvent struct {
+			addIndex uint64
+			setID    *SetID
+		}
+		var events []settleEvent
+		for rows.Next() {
+			var (
+				settleIndex, addIndex int64
+				setIDBytes            []byte
+			)
+			err := rows.Scan(&settleIndex, &addIndex, &setIDBytes)
+			if err != nil {
+				_ = rows.Close()
+				return err
+			}
+
+			event := settleEvent{
+				addIndex: uint64(addIndex),
+			}
+			if len(setIDBytes) != 0 {
+				event.setID = new(SetID)
+				copy(event.setID[:], setIDBytes)
+			}
+			events = append(events, event)
+		}
+		if err := rows.Close(); err != nil {
+			return err
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, event := range events {
+			invoice, err := fetchSQLInvoice(
+				tx, event.addIndex, event.setID,
+			)
+			if err != nil {
+				return err
+			}
+
+			settledInvoices = append(settledInvoices, invoice)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return settledInvoices, nil
+}
+
+// DeleteInvoice attempts to delete the passed invoices from the database in
+// one transaction. The passed delete references are checked for consistency
+// with the stored invoices before anything is deleted.
+func (s *SQLInvoiceDB) DeleteInvoice(invoicesToDelete []InvoiceDeleteRef) error {
+	return s.executeTx(false, func(tx *sql.Tx) error {
+		for _, ref := range invoicesToDelete {
+			var (
+				addIndex    int64
+				settleIndex sql.NullInt64
+			)
+			err := tx.QueryRow(`SELECT add_index, settle_index
+				FROM invoices WHERE hash = $1`, ref.PayHash[:],
+			).Scan(&addIndex, &settleIndex)
+			switch {
+			case err == sql.ErrNoRows:
+				return ErrInvoiceNotFound
+
+			case err != nil:
+				return err
+			}
+
+			// To ensure consistency check that the add and settle
+			// indexes of the reference match the stored invoice.
+			if uint64(addIndex) != ref.AddIndex {
+				return fmt.Errorf("unknown invoice in add " +
+					"index")
+			}
+			if ref.SettleIndex > 0 &&
+				uint64(settleIndex.Int64) != ref.SettleIndex {
+
+				return fmt.Errorf("unknown invoice in " +
+					"settle index")
+			}
+
+			// We delete the HTLCs and AMP sub-invoices explicitly,
+			// as the sqlite backend only cascades deletes if
+			// foreign keys are enabled for the connection.
+			for _, query := range []string{
+				`DELETE FROM invoice_htlcs WHERE invoice_id = $1`,
+				`DELETE FROM amp_sub_invoices WHERE invoice_id = $1`,
+				`DELETE FROM invoices WHERE add_index = $1`,
+			} {
+				if _, err := tx.Exec(query, addIndex); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+// sqlInvoiceIndexer is an invoiceIndexer that is backed by the native SQL
+// invoice tables.
+type sqlInvoiceIndexer struct {
+	tx       *sql.Tx
+	addIndex uint64
+}
+
+// addSetID checks that the passed AMP set ID isn't used by another invoice.
+// The set ID itself is indexed through the HTLCs that reference it.
+//
+// NOTE: This is part of the invoiceIndexer interface.
+func (s *sqlInvoiceIndexer) addSetID(setID SetID) error {
+	addIndex, found, err := queryAddIndex(
+		s.tx, `SELECT invoice_id FROM invoice_htlcs
+		WHERE amp_set_id = $1 LIMIT 1`, setID[:],
+	)
+	switch {
+	case err != nil:
+		return err
+
+	case found && addIndex != s.addIndex:
+		return ErrDuplicateSetID{setID: setID}
+	}
+
+	return nil
+}
+
+// nextSettleIndex reserves the next settle index. The settle index is written
+// to the invoice or AMP sub-invoice row itself, so there is no need for a
+// separate index entry.
+//
+// NOTE: This is part of the invoiceIndexer interface.
+func (s *sqlInvoiceIndexer) nextSettleIndex(_ *SetID) (uint64, error) {
+	return nextSQLSequence(s.tx, sqlInvoiceSettleSeq)
+}
+
+// nextSQLSequence increments the named sequence and returns its new value. A
+// sequence that doesn't exist yet starts at 1.
+func nextSQLSequence(tx *sql.Tx, name string) (uint64, error) {
+	var value int64
+	err := tx.QueryRow(`INSERT INTO invoice_sequences (name, current_value)
+		VALUES ($1, 1)
+		ON CONFLICT (name) DO UPDATE
+		SET current_value = invoice_sequences.current_value + 1
+		RETURNING current_value`, name,
+	).Scan(&value)
+	if err != nil {
+		return 0, err
+	}
+
+	return uint64(value), nil
+}
+
+// setSQLSequence sets the current value of the named sequence.
+func setSQLSequence(tx *sql.Tx, name string, value uint64) error {
+	_, err := tx.Exec(`INSERT INTO invoice_sequences (name, current_value)
+		VALUES ($1, $2)
+		ON CONFLICT (name) DO UPDATE
+		SET current_value = excluded.current_value`, name, int64(value),
+	)
+
+	return err
+}
+
+// queryAddIndex runs the passed query which is expected to select a single
+// invoice add index. The returned boolean indicates whether a row was found.
+func queryAddIndex(tx *sql.Tx, query string, args ...interface{}) (uint64,
+	bool, error) {
+
+	var addIndex int64
+	err := tx.QueryRow(query, args...).Scan(&addIndex)
+	switch {
+	case err == sql.ErrNoRows:
+		return 0, false, nil
+
+	case err != nil:
+		return 0, false, err
+	}
+
+	return uint64(addIndex), true, nil
+}
+
+// fetchSQLInvoiceNumByRef retrieves the add index of the invoice for the
+// provided invoice reference. The payment address will be treated as the
+// primary key, falling back to the payment hash if nothing is found for the
+// payment address. An error is returned if the invoice is not found.
+func fetchSQLInvoiceNumByRef(tx *sql.Tx, ref InvoiceRef) (uint64, error) {
+	// If the set id is present, we only consult the set id index for this
+	// invoice. This type of query is only used to facilitate user-facing
+	// requests to lookup, settle or cancel an AMP invoice.
+	if setID := ref.SetID(); setID != nil {
+		addIndex, found, err := queryAddIndex(
+			tx, `SELECT invoice_id FROM invoice_htlcs
+			WHERE amp_set_id = $1 LIMIT 1`, setID[:],
+		)
+		switch {
+		case err != nil:
+			return 0, err
+
+		case !found:
+			return 0, ErrInvoiceNotFound
+		}
+
+		return addIndex, nil
+	}
+
+	payHash := ref.PayHash()
+	payAddr := ref.PayAddr()
+
+	var (
+		numByHash, numByAddr     uint64
+		foundByHash, foundByAddr bool
+		err                      error
+	)
+	if payHash != nil {
+		numByHash, foundByHash, err = queryAddIndex(
+			tx, `SELECT add_index FROM invoices WHERE hash = $1`,
+			payHash[:],
+		)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	// Only allow lookups for payment address if it is not a blank payment
+	// address, which is a special-cased value for legacy keysend invoices.
+	if payAddr != nil && *payAddr != BlankPayAddr {
+		numByAddr, foundByAddr, err = queryAddIndex(
+			tx, `SELECT add_index FROM invoices
+			WHERE payment_addr = $1`, payAddr[:],
+		)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	switch {
+	// If payment address and payment hash both reference an existing
+	// invoice, ensure they reference the _same_ invoice.
+	case foundByAddr && foundByHash:
+		if numByAddr != numByHash {
+			return 0, ErrInvRefEquivocation
+		}
+
+		return numByAddr, nil
+
+	// Return invoices by payment addr only if the invoice ref does not
+	// contain a payment hash, see fetchInvoiceNumByRef for the rationale.
+	case foundByAddr && payHash == nil:
+		return numByAddr, nil
+
+	// If we were only able to reference the invoice by hash, return the
+	// corresponding invoice number. This can happen when no payment address
+	// was provided, or if it didn't match anything in our records.
+	case foundByHash:
+		return numByHash, nil
+
+	// Otherwise we don't know of the target invoice.
+	default:
+		return 0, ErrInvoiceNotFound
+	}
+}
+
+// sqlScanner is the common interface of sql.Row and sql.Rows that is used to
+// read out a single row.
+type sqlScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanSQLInvoice reads the invoice level fields of a single row selected with
+// sqlInvoiceColumns. The HTLCs and AMP state of the invoice are not populated.
+func scanSQLInvoice(row sqlScanner) (lntypes.Hash, Invoice, error) {
+	var (
+		hash lntypes.Hash
+		i    Invoice
+
+		addIndex, creationDate, settleDate int64
+		value, expiry, state, amtPaid      int64
+		cltvDelta                          int32
+		settleIndex                        sql.NullInt64
+		hashBytes, preimage, payAddr       []byte
+		featureBytes                       []byte
+	)
+	err := row.Scan(
+		&addIndex, &hashBytes, &preimage, &payAddr, &i.Memo,
+		&i.PaymentRequest, &creationDate, &settleDate, &settleIndex,
+		&value, &cltvDelta, &expiry, &featureBytes, &state, &amtPaid,
+		&i.HodlInvoice,
+	)
+	if err != nil {
+		return hash, i, err
+	}
+
+	copy(hash[:], hashBytes)
+
+	if len(preimage) != 0 {
+		var p lntypes.Preimage
+		copy(p[:], preimage)
+		i.Terms.PaymentPreimage = &p
+	}
+	copy(i.Terms.PaymentAddr[:], payAddr)
+
+	i.AddIndex = uint64(addIndex)
+	i.SettleIndex = uint64(settleIndex.Int64)
+	i.CreationDate = getNanoTime(uint64(creationDate))
+	i.SettleDate = getNanoTime(uint64(settleDate))
+	i.Terms.Value = lnwire.MilliSatoshi(value)
+	i.Terms.FinalCltvDelta = cltvDelta
+	i.Terms.Expiry = time.Duration(expiry)
+	i.State = ContractState(state)
+	i.AmtPaid = lnwire.MilliSatoshi(amtPaid)
+
+	rawFeatures := lnwire.NewRawFeatureVector()
+	err = rawFeatures.DecodeBase256(
+		bytes.NewReader(featureBytes), len(featureBytes),
+	)
+	if err != nil {
+		return hash, i, err
+	}
+	i.Terms.Features = lnwire.NewFeatureVector(
+		rawFeatures, lnwire.Features,
+	)
+
+	i.Htlcs = make(map[CircuitKey]*InvoiceHTLC)
+	i.AMPState = make(AMPInvoiceState)
+
+	return hash, i, nil
+}
+
+// scanSQLHtlc reads a single invoice htlc row selected with sqlHtlcColumns.
+func scanSQLHtlc(row sqlScanner) (CircuitKey, *InvoiceHTLC, error) {
+	var (
+		key  CircuitKey
+		htlc InvoiceHTLC
+
+		chanID, htlcID, amt, mppTotalAmt int64
+		acceptHeight, expiry, state      int64
+		acceptTime, resolveTime          int64
+		ampChildIndex                    sql.NullInt64
+		customRecords, ampRootShare      []byte
+		ampSetID, ampHash, ampPreimage   []byte
+	)
+	err := row.Scan(
+		&chanID, &htlcID, &amt, &mppTotalAmt, &acceptHeight,
+		&acceptTime, &resolveTime, &expiry, &state, &customRecords,
+		&ampRootShare, &ampSetID, &ampChildIndex, &ampHash,
+		&ampPreimage,
+	)
+	if err != nil {
+		return key, nil, err
+	}
+
+	key.ChanID = lnwire.NewShortChanIDFromInt(uint64(chanID))
+	key.HtlcID = uint64(htlcID)
+
+	htlc.Amt = lnwire.MilliSatoshi(amt)
+	htlc.MppTotalAmt = lnwire.MilliSatoshi(mppTotalAmt)
+	htlc.AcceptHeight = uint32(acceptHeight)
+	htlc.AcceptTime = getNanoTime(uint64(acceptTime))
+	htlc.ResolveTime = getNanoTime(uint64(resolveTime))
+	htlc.Expiry = uint32(expiry)
+	htlc.State = HtlcState(state)
+
+	htlc.CustomRecords, err = deserializeSQLCustomRecords(customRecords)
+	if err != nil {
+		return key, nil, err
+	}
+
+	if len(ampSetID) != 0 && len(ampHash) != 0 {
+		var rootShare, setID [32]byte
+		copy(rootShare[:], ampRootShare)
+		copy(setID[:], ampSetID)
+
+		htlc.AMP = &InvoiceHtlcAMPData{
+			Record: *record.NewAMP(
+				rootShare, setID, uint32(ampChildIndex.Int64),
+			),
+		}
+		copy(htlc.AMP.Hash[:], ampHash)
+
+		if len(ampPreimage) != 0 {
+			var preimage lntypes.Preimage
+			copy(preimage[:], ampPreimage)
+			htlc.AMP.Preimage = &preimage
+		}
+	}
+
+	return key, &htlc, nil
+}
+
+// forEachSQLInvoice runs the passed query, which must select the
+// sqlInvoiceColumns of the invoices table, and calls the callback for each
+// fully populated invoice in the result set.
+func forEachSQLInvoice(tx *sql.Tx, query string, args []interface{},
+	cb func(lntypes.Hash, *Invoice) error) error {
+
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return err
+	}
+
+	// We read out all invoice rows before populating them, as not all
+	// drivers allow issuing new queries while the rows are still being
+	// read.
+	var (
+		hashes   []lntypes.Hash
+		invoices []Invoice
+	)
+	for rows.Next() {
+		hash, invoice, err := scanSQLInvoice(rows)
+		if err != nil {
+			_ = rows.Close()
+			return err
+		}
+
+		hashes = append(hashes, hash)
+		invoices = append(invoices, invoice)
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i := range invoices {
+		err := fetchSQLInvoiceChildren(tx, &invoices[i], nil)
+		if err != nil {
+			return err
+		}
+
+		if err := cb(hashes[i], &invoices[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// fetchSQLInvoice reads out the invoice with the given add index. The setID
+// filters the HTLCs of AMP invoices in the same way as it does for
+// fetchInvoice.
+func fetchSQLInvoice(tx *sql.Tx, addIndex uint64, setID *SetID) (Invoice,
+	error) {
+
+	row := tx.QueryRow(`SELECT `+sqlInvoiceColumns+` FROM invoices
+		WHERE add_index = $1`, int64(addIndex),
+	)
+	_, invoice, err := scanSQLInvoice(row)
+	switch {
+	case err == sql.ErrNoRows:
+		return Invoice{}, ErrInvoiceNotFound
+
+	case err != nil:
+		return Invoice{}, err
+	}
+
+	if err := fetchSQLInvoiceChildren(tx, &invoice, setID); err != nil {
+		return Invoice{}, err
+	}
+
+	return invoice, nil
+}
+
+// fetchSQLInvoiceChildren populates the HTLCs and the AMP state of the passed
+// invoice. All HTLCs are read for non-AMP invoices. For AMP invoices, a nil
+// setID reads all HTLCs, the zero setID reads no HTLCs and any other setID
+// only reads the HTLCs of that set.
+func fetchSQLInvoiceChildren(tx *sql.Tx, invoice *Invoice,
+	setID *SetID) error {
+
+	addIndex := int64(invoice.AddIndex)
+
+	rows, err := tx.Query(`SELECT set_id, state, settle_index, settle_date,
+		amt_paid_msat FROM amp_sub_invoices WHERE invoice_id = $1`,
+		addIndex,
+	)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var (
+			setIDBytes              []byte
+			state, settleDate, paid int64
+			settleIndex             sql.NullInt64
+			ampSetID                SetID
+		)
+		err := rows.Scan(
+			&setIDBytes, &state, &settleIndex, &settleDate, &paid,
+		)
+		if err != nil {
+			_ = rows.Close()
+			return err
+		}
+
+		copy(ampSetID[:], setIDBytes)
+		invoice.AMPState[ampSetID] = InvoiceStateAMP{
+			State:       HtlcState(state),
+			SettleIndex: uint64(settleIndex.Int64),
+			SettleDate:  getNanoTime(uint64(settleDate)),
+			InvoiceKeys: make(map[CircuitKey]struct{}),
+			AmtPaid:     lnwire.MilliSatoshi(paid),
+		}
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// The circuit keys of each AMP sub-invoice are derived from the HTLCs
+	// that carry its set ID.
+	if len(invoice.AMPState) != 0 {
+		rows, err := tx.Query(`SELECT amp_set_id, chan_id, htlc_id
+			FROM invoice_htlcs
+			WHERE invoice_id = $1 AND amp_set_id IS NOT NULL`,
+			addIndex,
+		)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var (
+				setIDBytes     []byte
+				chanID, htlcID int64
+				ampSetID       SetID
+			)
+			err := rows.Scan(&setIDBytes, &chanID, &htlcID)
+			if err != nil {
+				_ = rows.Close()
+				return err
+			}
+
+			copy(ampSetID[:], setIDBytes)
+			ampState, ok := invoice.AMPState[ampSetID]
+			if !ok {
+				continue
+			}
+
+			key := CircuitKey{
+				ChanID: lnwire.NewShortChanIDFromInt(
+					uint64(chanID),
+				),
+				HtlcID: uint64(htlcID),
+			}
+			ampState.InvoiceKeys[key] = struct{}{}
+		}
+		if err := rows.Close(); err != nil {
+			return err
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+
+	query := `SELECT ` + sqlHtlcColumns + ` FROM invoice_htlcs
+		WHERE invoice_id = $1`
+	args := []interface{}{addIndex}
+
+	invoiceIsAMP := invoice.Terms.Features.HasFeature(lnwire.AMPOptional)
+	switch {
+	case !invoiceIsAMP || setID == nil:
+
+	// If the "zero" setID was specified, then this means that no HTLC data
+	// should be returned alongside of it.
+	case *setID == BlankPayAddr:
+		return nil
+
+	default:
+		query += ` AND amp_set_id = $2`
+		args = append(args, setID[:])
+	}
+
+	rows, err = tx.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		key, htlc, err := scanSQLHtlc(rows)
+		if err != nil {
+			_ = rows.Close()
+			return err
+		}
+
+		invoice.Htlcs[key] = htlc
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+
+	return rows.Err()
+}
+
+// insertSQLInvoice inserts the passed invoice, including its HTLCs and AMP
+// state, using the add index that is already set on the invoice.
+func insertSQLInvoice(tx *sql.Tx, hash lntypes.Hash, i *Invoice) error {
+	preimage, err := sqlPreimage(i.Terms.PaymentPreimage)
+	if err != nil {
+		return err
+	}
+
+	var payAddr []byte
+	if i.Terms.PaymentAddr != BlankPayAddr {
+		payAddr = i.Terms.PaymentAddr[:]
+	}
+
+	var fb bytes.Buffer
+	if err := i.Terms.Features.EncodeBase256(&fb); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`INSERT INTO invoices (`+sqlInvoiceColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13,
+		$14, $15, $16)`,
+		int64(i.AddIndex), hash[:], preimage, payAddr, i.Memo,
+		i.PaymentRequest, int64(putNanoTime(i.CreationDate)),
+		int64(putNanoTime(i.SettleDate)), sqlIndex(i.SettleIndex),
+		int64(i.Terms.Value), i.Terms.FinalCltvDelta,
+		int64(i.Terms.Expiry), fb.Bytes(), int64(i.State),
+		int64(i.AmtPaid), i.HodlInvoice,
+	)
+	if err != nil {
+		return err
+	}
+
+	return putSQLInvoiceChildren(tx, i)
+}
+
+// putSQLInvoiceChildren inserts or updates all HTLCs and AMP sub-invoices of
+// the passed invoice. Only the mutable fields of existing rows are updated.
+func putSQLInvoiceChildren(tx *sql.Tx, i *Invoice) error {
+	addIndex := int64(i.AddIndex)
+
+	for key, htlc := range i.Htlcs {
+		customRecords, err := serializeSQLCustomRecords(
+			htlc.CustomRecords,
+		)
+		if err != nil {
+			return err
+		}
+
+		var (
+			ampRootShare, ampSetID, ampHash, ampPreimage []byte
+			ampChildIndex                                sql.NullInt64
+		)
+		if htlc.AMP != nil {
+			rootShare := htlc.AMP.Record.RootShare()
+			setID := htlc.AMP.Record.SetID()
+
+			ampRootShare = rootShare[:]
+			ampSetID = setID[:]
+			ampChildIndex = sql.NullInt64{
+				Int64: int64(htlc.AMP.Record.ChildIndex()),
+				Valid: true,
+			}
+			ampHash = htlc.AMP.Hash[:]
+			if htlc.AMP.Preimage != nil {
+				ampPreimage = htlc.AMP.Preimage[:]
+			}
+		}
+
+		_, err = tx.Exec(`INSERT INTO invoice_htlcs (invoice_id, `+
+			sqlHtlcColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7,
+			$8, $9, $10, $11, $12, $13, $14, $15, $16)
+			ON CONFLICT (invoice_id, chan_id, htlc_id) DO UPDATE
+			SET resolve_time = excluded.resolve_time,
+			state = excluded.state,
+			amp_preimage = excluded.amp_preimage`,
+			addIndex, int64(key.ChanID.ToUint64()),
+			int64(key.HtlcID), int64(htlc.Amt),
+			int64(htlc.MppTotalAmt), int64(htlc.AcceptHeight),
+			int64(putNanoTime(htlc.AcceptTime)),
+			int64(putNanoTime(htlc.ResolveTime)),
+			int64(htlc.Expiry), int64(htlc.State), customRecords,
+			ampRootShare, ampSetID, ampChildIndex, ampHash,
+			ampPreimage,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	for setID, ampState := range i.AMPState {
+		setID := setID
+
+		_, err := tx.Exec(`INSERT INTO amp_sub_invoices (set_id,
+			invoice_id, state, settle_index, settle_date,
+			amt_paid_msat) VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (set_id) DO UPDATE
+			SET state = excluded.state,
+			settle_index = excluded.settle_index,
+			settle_date = excluded.settle_date,
+			amt_paid_msat = excluded.amt_paid_msat`,
+			setID[:], addIndex, int64(ampState.State),
+			sqlIndex(ampState.SettleIndex),
+			int64(putNanoTime(ampState.SettleDate)),
+			int64(ampState.AmtPaid),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sqlPreimage returns the column value for an optional invoice preimage.
+func sqlPreimage(preimage *lntypes.Preimage) ([]byte, error) {
+	if preimage == nil {
+		return nil, nil
+	}
+
+	if *preimage == unknownPreimage {
+		return nil, errors.New("cannot use all-zeroes preimage")
+	}
+
+	return preimage[:], nil
+}
+
+// sqlIndex returns the column value for an optional settle index, which is
+// stored as NULL if it isn't set so it doesn't violate the unique constraint.
+func sqlIndex(index uint64) sql.NullInt64 {
+	return sql.NullInt64{
+		Int64: int64(index),
+		Valid: index != 0,
+	}
+}
+
+// serializeSQLCustomRecords encodes the custom records of an htlc as a tlv
+// stream.
+func serializeSQLCustomRecords(customRecords record.CustomSet) ([]byte,
+	error) {
+
+	// The custom record ids are in the experimental range and sorted by
+	// MapToRecords, so there is no need to sort again.
+	tlvStream, err := tlv.NewStream(tlv.MapToRecords(customRecords)...)
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	if err := tlvStream.Encode(&b); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+// deserializeSQLCustomRecords decodes the custom records of an htlc from a tlv
+// stream.
+func deserializeSQLCustomRecords(b []byte) (record.CustomSet, error) {
+	tlvStream, err := tlv.NewStream()
+	if err != nil {
+		return nil, err
+	}
+
+	parsedTypes, err := tlvStream.DecodeWithParsedTypes(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+
+	return hop.NewCustomRecords(parsedTypes), nil
+}
@@ -0,0 +1,193 @@
+package channeldb
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/brsuite/broln/kvdb"
+	"github.com/brsuite/broln/lntypes"
+)
+
+var (
+	// invoiceBucketTombstone is the key within the invoice bucket that
+	// marks the kv invoices as migrated to the native SQL invoice store.
+	// Once it is set, the invoices in the kv buckets are stale and must no
+	// longer be used.
+	invoiceBucketTombstone = []byte("invoice-tombstone")
+)
+
+// SetInvoiceBucketTombstone marks the kv invoice bucket as migrated to the
+// native SQL invoice store.
+func (d *DB) SetInvoiceBucketTombstone() error {
+	return kvdb.Update(d, func(tx kvdb.RwTx) error {
+		invoices, err := tx.CreateTopLevelBucket(invoiceBucket)
+		if err != nil {
+			return err
+		}
+
+		return invoices.Put(invoiceBucketTombstone, []byte{1})
+	}, func() {})
+}
+
+// GetInvoiceBucketTombstone returns true if the kv invoice bucket has been
+// marked as migrated to the native SQL invoice store.
+func (d *DB) GetInvoiceBucketTombstone() (bool, error) {
+	var tombstoned bool
+	err := kvdb.View(d, func(tx kvdb.RTx) error {
+		invoices := tx.ReadBucket(invoiceBucket)
+		if invoices == nil {
+			return nil
+		}
+
+		tombstoned = invoices.Get(invoiceBucketTombstone) != nil
+		return nil
+	}, func() {
+		tombstoned = false
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return tombstoned, nil
+}
+
+// fetchInvoiceSequences returns the current values of the kv add and settle
+// index sequences.
+func fetchInvoiceSequences(d *DB) (uint64, uint64, error) {
+	var addSeq, settleSeq uint64
+
+	// The sequence of a bucket can only be read through a read-write
+	// bucket, so we need a write transaction even though we only read.
+	err := kvdb.Update(d, func(tx kvdb.RwTx) error {
+		invoices := tx.ReadWriteBucket(invoiceBucket)
+		if invoices == nil {
+			return nil
+		}
+
+		if addIndex := invoices.NestedReadWriteBucket(
+			addIndexBucket,
+		); addIndex != nil {
+			addSeq = addIndex.Sequence()
+		}
+
+		if settleIndex := invoices.NestedReadWriteBucket(
+			settleIndexBucket,
+		); settleIndex != nil {
+			settleSeq = settleIndex.Sequence()
+		}
+
+		return nil
+	}, func() {
+		addSeq, settleSeq = 0, 0
+	})
+
+	return addSeq, settleSeq, err
+}
+
+// MigrateInvoicesToSQL copies all invoices, including their HTLCs and AMP
+// sub-invoices, from the kv buckets of the channel database into the native
+// SQL invoice store. The add and settle indexes of the invoices are preserved.
+// Once all invoices are copied, the kv invoice bucket is tombstoned so the
+// migration is only ever run once.
+func MigrateInvoicesToSQL(kvDB *DB, sqlDB *SQLInvoiceDB) error {
+	migrated, err := kvDB.GetInvoiceBucketTombstone()
+	if err != nil {
+		return err
+	}
+	if migrated {
+		return nil
+	}
+
+	addSeq, settleSeq, err := fetchInvoiceSequences(kvDB)
+	if err != nil {
+		return fmt.Errorf("unable to fetch invoice sequences: %v", err)
+	}
+
+	log.Infof("Migrating invoices to the native SQL invoice store")
+
+	var numInvoices int
+	err = sqlDB.executeTx(false, func(tx *sql.Tx) error {
+		// The copy is done in a single transaction, so if we find any
+		// invoices, a previous run has completed the copy but failed to
+		// set the tombstone.
+		var existing int64
+		err := tx.QueryRow(`SELECT COUNT(*) FROM invoices`).Scan(
+			&existing,
+		)
+		if err != nil {
+			return err
+		}
+		if existing != 0 {
+			log.Infof("Native SQL invoice store already contains "+
+				"%d invoices, skipping copy", existing)
+
+			return nil
+		}
+
+		// The scan may be retried by the kv backend, in which case we
+		// need to remove everything that was copied in the previous
+		// attempt first.
+		var clearTables bool
+		reset := func() {
+			numInvoices = 0
+			clearTables = true
+		}
+
+		err = kvDB.ScanInvoices(func(hash lntypes.Hash,
+			invoice *Invoice) error {
+
+			if clearTables {
+				for _, query := range []string{
+					`DELETE FROM invoice_htlcs`,
+					`DELETE FROM amp_sub_invoices`,
+					`DELETE FROM invoices`,
+				} {
+					if _, err := tx.Exec(query); err != nil {
+						return err
+					}
+				}
+				clearTables = false
+			}
+
+			if invoice.AddIndex > addSeq {
+				addSeq = invoice.AddIndex
+			}
+			if invoice.SettleIndex > settleSeq {
+				settleSeq = invoice.SettleIndex
+			}
+			for _, ampState := range invoice.AMPState {
+				if ampState.SettleIndex > settleSeq {
+					settleSeq = ampState.SettleIndex
+				}
+			}
+
+			numInvoices++
+
+			return insertSQLInvoice(tx, hash, invoice)
+		}, reset)
+		if err != nil && err != ErrNoInvoicesCreated {
+			return err
+		}
+
+		// Continue the sequences where the kv store left off, so we
+		// never hand out an index twice.
+		err = setSQLSequence(tx, sqlInvoiceAddSeq, addSeq)
+		if err != nil {
+			return err
+		}
+
+		return setSQLSequence(tx, sqlInvoiceSettleSeq, settleSeq)
+	})
+	if err != nil {
+		return fmt.Errorf("unable to migrate invoices: %v", err)
+	}
+
+	if err := kvDB.SetInvoiceBucketTombstone(); err != nil {
+		return err
+	}
+
+	log.Infof("Migrated %d invoices to the native SQL invoice store",
+		numInvoices)
+
+	return nil
+}
@@ -0,0 +1,493 @@
+package channeldb
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/brsuite/broln/clock"
+	"github.com/brsuite/broln/kvdb"
+	"github.com/brsuite/broln/lntypes"
+	"github.com/brsuite/broln/lnwire"
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite" // Register the sqlite driver.
+)
+
+// newTestSQLInvoiceDB creates a native SQL invoice store that is backed by a
+// temporary sqlite database.
+func newTestSQLInvoiceDB(t *testing.T, clock clock.Clock) *SQLInvoiceDB {
+	t.Helper()
+
+	tempDir, err := ioutil.TempDir("", "sqlinvoices")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, os.RemoveAll(tempDir))
+	})
+
+	dsn := "file:" + filepath.Join(tempDir, "invoices.sqlite") +
+		"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+	db, err := sql.Open("sqlite", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, db.Close())
+	})
+
+	invoiceDB, err := NewSQLInvoiceDB(db, kvdb.SqliteBackendName, clock)
+	require.NoError(t, err)
+
+	return invoiceDB
+}
+
+// normalizeInvoice unifies the representation of empty byte slices, which may
+// be returned as either nil or empty by the different stores.
+func normalizeInvoice(invoice Invoice) Invoice {
+	if len(invoice.Memo) == 0 {
+		invoice.Memo = nil
+	}
+	if len(invoice.PaymentRequest) == 0 {
+		invoice.PaymentRequest = nil
+	}
+
+	return invoice
+}
+
+// TestSQLInvoiceWorkflow tests adding, updating, querying and deleting
+// invoices in the native SQL invoice store.
+func TestSQLInvoiceWorkflow(t *testing.T) {
+	t.Parallel()
+
+	db := newTestSQLInvoiceDB(t, clock.NewTestClock(testNow))
+
+	const numInvoices = 5
+	amt := lnwire.NewMSatFromSatoshis(1000)
+
+	var (
+		invoices []*Invoice
+		hashes   []lntypes.Hash
+	)
+	for i := 0; i < numInvoices; i++ {
+		invoice, err := randInvoice(amt)
+		require.NoError(t, err)
+
+		hash := invoice.Terms.PaymentPreimage.Hash()
+		addIndex, err := db.AddInvoice(invoice, hash)
+		require.NoError(t, err)
+		require.Equal(t, uint64(i+1), addIndex)
+		require.Equal(t, addIndex, invoice.AddIndex)
+
+		invoices = append(invoices, invoice)
+		hashes = append(hashes, hash)
+	}
+
+	// Adding an invoice with an existing payment hash or payment address
+	// must fail.
+	dupInvoice, err := randInvoice(amt)
+	require.NoError(t, err)
+	_, err = db.AddInvoice(dupInvoice, hashes[0])
+	require.ErrorIs(t, err, ErrDuplicateInvoice)
+
+	dupInvoice.Terms.PaymentAddr = invoices[0].Terms.PaymentAddr
+	_, err = db.AddInvoice(
+		dupInvoice, dupInvoice.Terms.PaymentPreimage.Hash(),
+	)
+	require.ErrorIs(t, err, ErrDuplicatePayAddr)
+
+	// The invoices can be looked up both by hash and by payment address.
+	for i, invoice := range invoices {
+		dbInvoice, err := db.LookupInvoice(InvoiceRefByHash(hashes[i]))
+		require.NoError(t, err)
+		require.Equal(
+			t, normalizeInvoice(*invoice),
+			normalizeInvoice(dbInvoice),
+		)
+
+		dbInvoice, err = db.LookupInvoice(
+			InvoiceRefByAddr(invoice.Terms.PaymentAddr),
+		)
+		require.NoError(t, err)
+		require.Equal(t, invoice.AddIndex, dbInvoice.AddIndex)
+	}
+
+	_, err = db.LookupInvoice(InvoiceRefByHash(lntypes.Hash{9}))
+	require.ErrorIs(t, err, ErrInvoiceNotFound)
+
+	// Settle the second and fourth invoice, they should be assigned
+	// consecutive settle indexes.
+	for i, idx := range []int{1, 3} {
+		ref := InvoiceRefByHash(hashes[idx])
+		dbInvoice, err := db.UpdateInvoice(ref, nil, getUpdateInvoice(amt))
+		require.NoError(t, err)
+		require.Equal(t, ContractSettled, dbInvoice.State)
+		require.Equal(t, uint64(i+1), dbInvoice.SettleIndex)
+		require.Equal(t, amt, dbInvoice.AmtPaid)
+		require.Equal(t, testNow, dbInvoice.SettleDate)
+
+		storedInvoice, err := db.LookupInvoice(ref)
+		require.NoError(t, err)
+		require.Equal(t, *dbInvoice, storedInvoice)
+		require.Len(t, storedInvoice.Htlcs, 1)
+
+		invoices[idx] = dbInvoice
+	}
+
+	// Settling an invoice twice isn't allowed.
+	_, err = db.UpdateInvoice(
+		InvoiceRefByHash(hashes[1]), nil, getUpdateInvoice(amt),
+	)
+	require.ErrorIs(t, err, ErrInvoiceAlreadySettled)
+
+	// Check the add and settle time series.
+	added, err := db.InvoicesAddedSince(2)
+	require.NoError(t, err)
+	require.Len(t, added, 3)
+	require.Equal(t, uint64(3), added[0].AddIndex)
+
+	settled, err := db.InvoicesSettledSince(1)
+	require.NoError(t, err)
+	require.Len(t, settled, 1)
+	require.Equal(t, uint64(4), settled[0].AddIndex)
+
+	// Check that the paginated queries are evaluated correctly.
+	addIndexes := func(invoices []Invoice) []uint64 {
+		var indexes []uint64
+		for _, invoice := range invoices {
+			indexes = append(indexes, invoice.AddIndex)
+		}
+		return indexes
+	}
+	tests := []struct {
+		name     string
+		query    InvoiceQuery
+		expected []uint64
+	}{
+		{
+			name: "forward",
+			query: InvoiceQuery{
+				IndexOffset:    1,
+				NumMaxInvoices: 2,
+			},
+			expected: []uint64{2, 3},
+		},
+		{
+			name: "reversed from end",
+			query: InvoiceQuery{
+				NumMaxInvoices: 2,
+				Reversed:       true,
+			},
+			expected: []uint64{4, 5},
+		},
+		{
+			name: "reversed with offset",
+			query: InvoiceQuery{
+				IndexOffset:    3,
+				NumMaxInvoices: 5,
+				Reversed:       true,
+			},
+			expected: []uint64{1, 2},
+		},
+		{
+			name: "pending only",
+			query: InvoiceQuery{
+				NumMaxInvoices: 5,
+				PendingOnly:    true,
+			},
+			expected: []uint64{1, 3, 5},
+		},
+		{
+			name: "pending only with limit",
+			query: InvoiceQuery{
+				IndexOffset:    1,
+				NumMaxInvoices: 1,
+				PendingOnly:    true,
+			},
+			expected: []uint64{3},
+		},
+	}
+	for _, test := range tests {
+		resp, err := db.QueryInvoices(test.query)
+		require.NoError(t, err, test.name)
+		require.Equal(
+			t, test.expected, addIndexes(resp.Invoices), test.name,
+		)
+		require.Equal(
+			t, test.expected[0], resp.FirstIndexOffset, test.name,
+		)
+		require.Equal(
+			t, test.expected[len(test.expected)-1],
+			resp.LastIndexOffset, test.name,
+		)
+	}
+
+	// Scanning should return all invoices along with their hashes.
+	scanned := make(map[lntypes.Hash]uint64)
+	err = db.ScanInvoices(func(hash lntypes.Hash, invoice *Invoice) error {
+		scanned[hash] = invoice.AddIndex
+		return nil
+	}, func() {})
+	require.NoError(t, err)
+	require.Len(t, scanned, numInvoices)
+	for i, hash := range hashes {
+		require.Equal(t, uint64(i+1), scanned[hash])
+	}
+
+	// Deleting an invoice with inconsistent indexes must fail.
+	err = db.DeleteInvoice([]InvoiceDeleteRef{{
+		PayHash:  hashes[3],
+		AddIndex: 3,
+	}})
+	require.Error(t, err)
+
+	err = db.DeleteInvoice([]InvoiceDeleteRef{{
+		PayHash:     hashes[3],
+		PayAddr:     &invoices[3].Terms.PaymentAddr,
+		AddIndex:    invoices[3].AddIndex,
+		SettleIndex: invoices[3].SettleIndex,
+	}})
+	require.NoError(t, err)
+
+	_, err = db.LookupInvoice(InvoiceRefByHash(hashes[3]))
+	require.ErrorIs(t, err, ErrInvoiceNotFound)
+
+	// Add indexes are never reused, even after deleting an invoice.
+	deleted, err := randInvoice(amt)
+	require.NoError(t, err)
+	addIndex, err := db.AddInvoice(
+		deleted, deleted.Terms.PaymentPreimage.Hash(),
+	)
+	require.NoError(t, err)
+	require.Equal(t, uint64(numInvoices+1), addIndex)
+}
+
+// TestSQLInvoiceAMP tests that AMP sub-invoices are settled and queried
+// correctly in the native SQL invoice store.
+func TestSQLInvoiceAMP(t *testing.T) {
+	t.Parallel()
+
+	db := newTestSQLInvoiceDB(t, clock.NewTestClock(testNow))
+
+	amt := lnwire.NewMSatFromSatoshis(1000)
+	testInvoice, err := randInvoice(amt)
+	require.NoError(t, err)
+	testInvoice.Terms.Features = ampFeatures
+
+	preimage := *testInvoice.Terms.PaymentPreimage
+	payHash := preimage.Hash()
+	_, err = db.AddInvoice(testInvoice, payHash)
+	require.NoError(t, err)
+
+	setIDs := []*[32]byte{{1}, {2}}
+	ref := InvoiceRefByHashAndAddr(payHash, testInvoice.Terms.PaymentAddr)
+	for i, setID := range setIDs {
+		_, err := db.UpdateInvoice(
+			ref, (*SetID)(setID),
+			updateAcceptAMPHtlc(uint64(i+1), amt, setID, true),
+		)
+		require.NoError(t, err)
+	}
+
+	// A set ID that is already used by this invoice can't be used by
+	// another invoice.
+	otherInvoice, err := randInvoice(amt)
+	require.NoError(t, err)
+	otherInvoice.Terms.Features = ampFeatures
+	otherHash := otherInvoice.Terms.PaymentPreimage.Hash()
+	_, err = db.AddInvoice(otherInvoice, otherHash)
+	require.NoError(t, err)
+
+	_, err = db.UpdateInvoice(
+		InvoiceRefByHash(otherHash), (*SetID)(setIDs[0]),
+		updateAcceptAMPHtlc(10, amt, setIDs[0], true),
+	)
+	require.ErrorIs(t, err, ErrDuplicateSetID{setID: *setIDs[0]})
+
+	// Settle both sets in distinct updates.
+	for i, setID := range setIDs {
+		_, err := db.UpdateInvoice(
+			ref, (*SetID)(setID),
+			getUpdateInvoiceAMPSettle(
+				setID, preimage, CircuitKey{HtlcID: uint64(i + 1)},
+			),
+		)
+		require.NoError(t, err)
+	}
+
+	// The blank modifier doesn't return any HTLCs, while the set ID filter
+	// only returns the HTLCs of that set.
+	invoice, err := db.LookupInvoice(
+		InvoiceRefByAddrBlankHtlc(testInvoice.Terms.PaymentAddr),
+	)
+	require.NoError(t, err)
+	require.Empty(t, invoice.Htlcs)
+	require.Len(t, invoice.AMPState, len(setIDs))
+
+	for i, setID := range setIDs {
+		invoice, err := db.LookupInvoice(
+			InvoiceRefBySetIDFiltered(*setID),
+		)
+		require.NoError(t, err)
+		require.Len(t, invoice.Htlcs, 1)
+
+		key := CircuitKey{HtlcID: uint64(i + 1)}
+		htlc := invoice.Htlcs[key]
+		require.Equal(t, HtlcStateSettled, htlc.State)
+		require.Equal(t, *setID, htlc.AMP.Record.SetID())
+		require.Equal(t, preimage, *htlc.AMP.Preimage)
+
+		ampState := invoice.AMPState[*setID]
+		require.Equal(t, HtlcStateSettled, ampState.State)
+		require.Equal(t, uint64(i+1), ampState.SettleIndex)
+		require.Equal(t, amt, ampState.AmtPaid)
+		require.Contains(t, ampState.InvoiceKeys, key)
+	}
+
+	// Each settled set is returned as a separate settle event.
+	settled, err := db.InvoicesSettledSince(1)
+	require.NoError(t, err)
+	require.Len(t, settled, 1)
+	require.Len(t, settled[0].Htlcs, 1)
+	require.Contains(t, settled[0].Htlcs, CircuitKey{HtlcID: 2})
+
+	// The AMP invoice itself stays open, and all HTLCs are returned when
+	// looking it up without a filter.
+	invoice, err = db.LookupInvoice(InvoiceRefByAddr(
+		testInvoice.Terms.PaymentAddr,
+	))
+	require.NoError(t, err)
+	require.Equal(t, ContractOpen, invoice.State)
+	require.Len(t, invoice.Htlcs, len(setIDs))
+	require.Equal(t, 2*amt, invoice.AmtPaid)
+
+	err = db.DeleteInvoice([]InvoiceDeleteRef{{
+		PayHash:  payHash,
+		PayAddr:  &testInvoice.Terms.PaymentAddr,
+		AddIndex: testInvoice.AddIndex,
+	}})
+	require.NoError(t, err)
+
+	_, err = db.LookupInvoice(InvoiceRefBySetID(*setIDs[0]))
+	require.ErrorIs(t, err, ErrInvoiceNotFound)
+}
+
+// TestMigrateInvoicesToSQL tests that the invoices of the kv store are copied
+// to the native SQL invoice store without any loss.
+func TestMigrateInvoicesToSQL(t *testing.T) {
+	t.Parallel()
+
+	testClock := clock.NewTestClock(testNow)
+	kvDB, cleanUp, err := MakeTestDB(OptionClock(testClock))
+	require.NoError(t, err)
+	t.Cleanup(cleanUp)
+
+	sqlDB := newTestSQLInvoiceDB(t, testClock)
+
+	// Populate the kv store with a mix of open, settled and AMP invoices.
+	amt := lnwire.NewMSatFromSatoshis(1000)
+	var hashes []lntypes.Hash
+	for i := 0; i < 4; i++ {
+		invoice, err := randInvoice(amt)
+		require.NoError(t, err)
+
+		hash := invoice.Terms.PaymentPreimage.Hash()
+		_, err = kvDB.AddInvoice(invoice, hash)
+		require.NoError(t, err)
+
+		hashes = append(hashes, hash)
+	}
+	_, err = kvDB.UpdateInvoice(
+		InvoiceRefByHash(hashes[1]), nil, getUpdateInvoice(amt),
+	)
+	require.NoError(t, err)
+
+	ampInvoice, err := randInvoice(amt)
+	require.NoError(t, err)
+	ampInvoice.Terms.Features = ampFeatures
+	preimage := *ampInvoice.Terms.PaymentPreimage
+	ampHash := preimage.Hash()
+	_, err = kvDB.AddInvoice(ampInvoice, ampHash)
+	require.NoError(t, err)
+
+	setID := &[32]byte{1}
+	ref := InvoiceRefByHashAndAddr(ampHash, ampInvoice.Terms.PaymentAddr)
+	_, err = kvDB.UpdateInvoice(
+		ref, (*SetID)(setID), updateAcceptAMPHtlc(1, amt, setID, true),
+	)
+	require.NoError(t, err)
+	_, err = kvDB.UpdateInvoice(
+		ref, (*SetID)(setID), getUpdateInvoiceAMPSettle(
+			setID, preimage, CircuitKey{HtlcID: 1},
+		),
+	)
+	require.NoError(t, err)
+
+	// Add and delete one more invoice, so the kv add index sequence is
+	// ahead of the highest add index of the remaining invoices.
+	deleted, err := randInvoice(amt)
+	require.NoError(t, err)
+	deletedHash := deleted.Terms.PaymentPreimage.Hash()
+	_, err = kvDB.AddInvoice(deleted, deletedHash)
+	require.NoError(t, err)
+	err = kvDB.DeleteInvoice([]InvoiceDeleteRef{{
+		PayHash:  deletedHash,
+		PayAddr:  &deleted.Terms.PaymentAddr,
+		AddIndex: deleted.AddIndex,
+	}})
+	require.NoError(t, err)
+
+	require.NoError(t, MigrateInvoicesToSQL(kvDB, sqlDB))
+
+	tombstoned, err := kvDB.GetInvoiceBucketTombstone()
+	require.NoError(t, err)
+	require.True(t, tombstoned)
+
+	// All invoices must be identical in both stores.
+	query := InvoiceQuery{
+		NumMaxInvoices: 100,
+	}
+	kvInvoices, err := kvDB.QueryInvoices(query)
+	require.NoError(t, err)
+	sqlInvoices, err := sqlDB.QueryInvoices(query)
+	require.NoError(t, err)
+	require.Len(t, sqlInvoices.Invoices, len(kvInvoices.Invoices))
+	for i := range kvInvoices.Invoices {
+		require.Equal(
+			t, normalizeInvoice(kvInvoices.Invoices[i]),
+			normalizeInvoice(sqlInvoices.Invoices[i]),
+		)
+	}
+
+	kvSettled, err := kvDB.InvoicesSettledSince(1)
+	require.NoError(t, err)
+	sqlSettled, err := sqlDB.InvoicesSettledSince(1)
+	require.NoError(t, err)
+	require.Len(t, sqlSettled, len(kvSettled))
+	for i := range kvSettled {
+		require.Equal(
+			t, normalizeInvoice(kvSettled[i]),
+			normalizeInvoice(sqlSettled[i]),
+		)
+	}
+
+	// Running the migration again is a no-op.
+	require.NoError(t, MigrateInvoicesToSQL(kvDB, sqlDB))
+	sqlInvoices, err = sqlDB.QueryInvoices(query)
+	require.NoError(t, err)
+	require.Len(t, sqlInvoices.Invoices, len(kvInvoices.Invoices))
+
+	// New invoices continue where the kv sequences left off.
+	newInvoice, err := randInvoice(amt)
+	require.NoError(t, err)
+	addIndex, err := sqlDB.AddInvoice(
+		newInvoice, newInvoice.Terms.PaymentPreimage.Hash(),
+	)
+	require.NoError(t, err)
+	require.Equal(t, deleted.AddIndex+1, addIndex)
+
+	dbInvoice, err := sqlDB.UpdateInvoice(
+		InvoiceRefByHash(newInvoice.Terms.PaymentPreimage.Hash()), nil,
+		getUpdateInvoice(amt),
+	)
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), dbInvoice.SettleIndex)
+}
@@ -1142,6 +1142,25 @@ func serializeHop(w io.Writer, h *route.Hop) error {
 		records = append(records, h.MPP.Record())
 	}
 
+	if len(h.EncryptedData) > 0 {
+		records = append(records, record.NewEncryptedDataRecord(
+			&h.EncryptedData,
+		))
+	}
+
+	if h.BlindingPoint != nil {
+		records = append(records, record.NewBlindingPointRecord(
+			&h.BlindingPoint,
+		))
+	}
+
+	totalAmt := uint64(h.TotalAmtMsat)
+	if totalAmt != 0 {
+		records = append(records, record.NewTotalAmtMsatBlindedRecord(
+			&totalAmt,
+		))
+	}
+
 	// Final sanity check to absolutely rule out custom records that are not
 	// custom and write into the standard range.
 	if err := h.CustomRecords.Validate(); err != nil {
@@ -1256,6 +1275,29 @@ func deserializeHop(r io.Reader) (*route.Hop, error) {
 		h.MPP = mpp
 	}
 
+	// The same goes for the records of hops within a blinded route.
+	var totalAmt uint64
+	blindedRecords := []tlv.Record{
+		record.NewEncryptedDataRecord(&h.EncryptedData),
+		record.NewBlindingPointRecord(&h.BlindingPoint),
+		record.NewTotalAmtMsatBlindedRecord(&totalAmt),
+	}
+	for _, rec := range blindedRecords {
+		recBytes, ok := tlvMap[uint64(rec.Type())]
+		if !ok {
+			continue
+		}
+		delete(tlvMap, uint64(rec.Type()))
+
+		err := rec.Decode(
+			bytes.NewReader(recBytes), uint64(len(recBytes)),
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+	h.TotalAmtMsat = lnwire.MilliSatoshi(totalAmt)
+
 	h.CustomRecords = tlvMap
 
 	return h, nil
@@ -42,6 +42,16 @@ var (
 		LegacyPayload:    true,
 	}
 
+	testHop3 = &route.Hop{
+		PubKeyBytes:      route.NewVertex(pub),
+		OutgoingTimeLock: 111,
+		AmtToForward:     555,
+		CustomRecords:    record.CustomSet{},
+		EncryptedData:    []byte{1, 2, 3},
+		BlindingPoint:    pub,
+		TotalAmtMsat:     1110,
+	}
+
 	testRoute = route.Route{
 		TotalTimeLock: 123,
 		TotalAmount:   1234567,
@@ -49,6 +59,7 @@ var (
 		Hops: []*route.Hop{
 			testHop2,
 			testHop1,
+			testHop3,
 		},
 	}
 )
@@ -20,21 +20,32 @@ var (
 	//      |
 	//      |-- <peer-pubkey>
 	//      |        |--flap-count-key: <ts><flap count>
+	//      |        |--peer-storage-key: <blob>
 	//      |
 	//      |-- <peer-pubkey>
 	//      |        |--flap-count-key: <ts><flap count>
+	//      |        |--peer-storage-key: <blob>
 	peersBucket = []byte("peers-bucket")
 
 	// flapCountKey is a key used in the peer pubkey sub-bucket that stores
 	// the timestamp of a peer's last flap count and its all time flap
 	// count.
 	flapCountKey = []byte("flap-count")
+
+	// peerStorageKey is a key used in the peer pubkey sub-bucket that
+	// stores the latest encrypted blob the peer asked us to store on its
+	// behalf.
+	peerStorageKey = []byte("peer-storage")
 )
 
 var (
 	// ErrNoPeerBucket is returned when we try to read entries for a peer
 	// that is not tracked.
 	ErrNoPeerBucket = errors.New("peer bucket not found")
+
+	// ErrNoPeerStorage is returned when we try to read the blob stored
+	// on behalf of a peer that never asked us to store one.
+	ErrNoPeerStorage = errors.New("no peer storage found")
 )
 
 // FlapCount contains information about a peer's flap count.
@@ -121,3 +132,52 @@ func (d *DB) ReadFlapCount(pubkey route.Vertex) (*FlapCount, error) {
 
 	return &flapCount, nil
 }
+
+// PutPeerStorage stores the blob a peer asked us to keep on its behalf,
+// replacing any blob stored for the peer before.
+func (c *ChannelStateDB) PutPeerStorage(pubkey route.Vertex,
+	blob []byte) error {
+
+	return kvdb.Update(c.backend, func(tx kvdb.RwTx) error {
+		peers := tx.ReadWriteBucket(peersBucket)
+
+		peerBucket, err := peers.CreateBucketIfNotExists(pubkey[:])
+		if err != nil {
+			return err
+		}
+
+		return peerBucket.Put(peerStorageKey, blob)
+	}, func() {})
+}
+
+// FetchPeerStorage returns the latest blob the peer asked us to store on its
+// behalf. ErrNoPeerStorage is returned if the peer never did so.
+func (c *ChannelStateDB) FetchPeerStorage(pubkey route.Vertex) ([]byte,
+	error) {
+
+	var blob []byte
+	if err := kvdb.View(c.backend, func(tx kvdb.RTx) error {
+		peers := tx.ReadBucket(peersBucket)
+
+		peerBucket := peers.NestedReadBucket(pubkey[:])
+		if peerBucket == nil {
+			return ErrNoPeerStorage
+		}
+
+		storedBlob := peerBucket.Get(peerStorageKey)
+		if storedBlob == nil {
+			return ErrNoPeerStorage
+		}
+
+		blob = make([]byte, len(storedBlob))
+		copy(blob, storedBlob)
+
+		return nil
+	}, func() {
+		blob = nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return blob, nil
+}
@@ -48,3 +48,39 @@ func TestFlapCount(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, peer2FlapCount, count)
 }
+
+// TestPeerStorage tests that the blob stored on behalf of a peer can be read
+// back, and that storing a new blob replaces the prior one.
+func TestPeerStorage(t *testing.T) {
+	fullDB, cleanup, err := MakeTestDB()
+	require.NoError(t, err)
+	defer cleanup()
+
+	db := fullDB.ChannelStateDB()
+
+	// Try to read the blob of a peer that never stored one.
+	_, err = db.FetchPeerStorage(testPub)
+	require.ErrorIs(t, err, ErrNoPeerStorage)
+
+	// A peer that we only track a flap count for has no blob either.
+	err = fullDB.WriteFlapCounts(map[route.Vertex]*FlapCount{
+		testPub: {Count: 1},
+	})
+	require.NoError(t, err)
+
+	_, err = db.FetchPeerStorage(testPub)
+	require.ErrorIs(t, err, ErrNoPeerStorage)
+
+	require.NoError(t, db.PutPeerStorage(testPub, []byte{1, 2, 3}))
+
+	blob, err := db.FetchPeerStorage(testPub)
+	require.NoError(t, err)
+	require.Equal(t, []byte{1, 2, 3}, blob)
+
+	// Storing a new blob replaces the old one.
+	require.NoError(t, db.PutPeerStorage(testPub, []byte{4, 5}))
+
+	blob, err = db.FetchPeerStorage(testPub)
+	require.NoError(t, err)
+	require.Equal(t, []byte{4, 5}, blob)
+}
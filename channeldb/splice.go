@@ -0,0 +1,658 @@
+package channeldb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/brsuite/brond/chaincfg/chainhash"
+	"github.com/brsuite/brond/wire"
+	"github.com/brsuite/bronutil"
+	"github.com/brsuite/broln/kvdb"
+	"github.com/brsuite/broln/lnwire"
+	"github.com/brsuite/broln/tlv"
+)
+
+var (
+	// spliceCandidatesKey is the key of the bucket nested within a
+	// channel's bucket that stores the splice transactions negotiated for
+	// the channel which haven't confirmed yet, keyed by their txid.
+	//
+	// spliceCandidatesKey -> txid -> SpliceCandidate
+	spliceCandidatesKey = []byte("splice-candidates-key")
+
+	// splicedFromKey stores the funding outpoint a channel used before it
+	// was last spliced.
+	splicedFromKey = []byte("spliced-from-key")
+
+	// spliceLockPendingKey stores the txid of the splice transaction a
+	// channel was moved over to, until the splice has been locked in by
+	// both parties. The channel stays frozen until then.
+	spliceLockPendingKey = []byte("splice-lock-pending-key")
+
+	// spliceEdgeKey is the key of the bucket nested within a channel's
+	// bucket that stores a snapshot of the channel's edge in the graph,
+	// taken before its splice transaction confirmed.
+	//
+	// spliceEdgeKey -> chanID -> ChannelEdgeInfo
+	//               -> spliceEdgePolicy1Key -> ChannelEdgePolicy
+	//               -> spliceEdgePolicy2Key -> ChannelEdgePolicy
+	spliceEdgeKey = []byte("splice-edge-key")
+
+	// spliceEdgePolicy1Key and spliceEdgePolicy2Key store the policies of
+	// the first and second node of a spliced channel's edge.
+	spliceEdgePolicy1Key = []byte("policy-1")
+	spliceEdgePolicy2Key = []byte("policy-2")
+
+	// ErrSpliceCandidateNotFound is returned when a splice transaction
+	// isn't one of the splice candidates of a channel.
+	ErrSpliceCandidateNotFound = errors.New("splice candidate not found")
+)
+
+// SpliceCandidate is a splice transaction negotiated for a channel that
+// hasn't confirmed yet. A channel may have several candidates if the splice
+// transaction was replaced by fee. Each candidate carries the commitments
+// spending its funding output, which replace the current commitments of the
+// channel once the candidate confirms.
+type SpliceCandidate struct {
+	// SpliceTx is the splice transaction. It spends the current funding
+	// output of the channel and creates the new one.
+	SpliceTx *wire.MsgTx
+
+	// FundingOutpoint is the new funding outpoint of the channel created
+	// by SpliceTx.
+	FundingOutpoint wire.OutPoint
+
+	// Capacity is the capacity of the channel after the splice.
+	Capacity bronutil.Amount
+
+	// FeePerKw is the fee rate the splice transaction pays.
+	FeePerKw bronutil.Amount
+
+	// LocalCommitment is our commitment spending the new funding output.
+	LocalCommitment ChannelCommitment
+
+	// RemoteCommitment is the remote party's commitment spending the new
+	// funding output.
+	RemoteCommitment ChannelCommitment
+}
+
+// SpliceEdge is a snapshot of the edge of a channel being spliced. The router
+// prunes the edge once the old funding output is spent, so the snapshot is
+// used to restore it at the new funding outpoint.
+type SpliceEdge struct {
+	// Info is the edge of the channel.
+	Info *ChannelEdgeInfo
+
+	// Policies are the policies of the first and second node of the edge.
+	// Either may be nil if it's unknown.
+	Policies [2]*ChannelEdgePolicy
+}
+
+// serializeSpliceCandidate writes the splice candidate to the given writer.
+func serializeSpliceCandidate(w io.Writer, s *SpliceCandidate) error {
+	err := WriteElements(
+		w, s.SpliceTx, s.FundingOutpoint, s.Capacity, s.FeePerKw,
+	)
+	if err != nil {
+		return err
+	}
+
+	if err := serializeChanCommit(w, &s.LocalCommitment); err != nil {
+		return err
+	}
+
+	return serializeChanCommit(w, &s.RemoteCommitment)
+}
+
+// deserializeSpliceCandidate reads a splice candidate from the given reader.
+func deserializeSpliceCandidate(r io.Reader) (*SpliceCandidate, error) {
+	s := &SpliceCandidate{}
+	err := ReadElements(
+		r, &s.SpliceTx, &s.FundingOutpoint, &s.Capacity, &s.FeePerKw,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	s.LocalCommitment, err = deserializeChanCommit(r)
+	if err != nil {
+		return nil, err
+	}
+
+	s.RemoteCommitment, err = deserializeChanCommit(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// AddSpliceCandidate stores a new splice candidate for the channel. A
+// candidate with the same txid is overwritten, which allows the signatures
+// of the splice transaction to be added once they're known.
+func (c *OpenChannel) AddSpliceCandidate(candidate *SpliceCandidate) error {
+	c.Lock()
+	defer c.Unlock()
+
+	var b bytes.Buffer
+	if err := serializeSpliceCandidate(&b, candidate); err != nil {
+		return err
+	}
+
+	txid := candidate.SpliceTx.TxHash()
+
+	return kvdb.Update(c.Db.backend, func(tx kvdb.RwTx) error {
+		chanBucket, err := fetchChanBucketRw(
+			tx, c.IdentityPub, &c.FundingOutpoint, c.ChainHash,
+		)
+		if err != nil {
+			return err
+		}
+
+		candidates, err := chanBucket.CreateBucketIfNotExists(
+			spliceCandidatesKey,
+		)
+		if err != nil {
+			return err
+		}
+
+		return candidates.Put(txid[:], b.Bytes())
+	}, func() {})
+}
+
+// SpliceCandidates returns all splice candidates of the channel that haven't
+// confirmed yet.
+func (c *OpenChannel) SpliceCandidates() ([]*SpliceCandidate, error) {
+	c.RLock()
+	defer c.RUnlock()
+
+	var spliceCandidates []*SpliceCandidate
+	err := kvdb.View(c.Db.backend, func(tx kvdb.RTx) error {
+		chanBucket, err := fetchChanBucket(
+			tx, c.IdentityPub, &c.FundingOutpoint, c.ChainHash,
+		)
+		if err != nil {
+			return err
+		}
+
+		candidates := chanBucket.NestedReadBucket(spliceCandidatesKey)
+		if candidates == nil {
+			return nil
+		}
+
+		return candidates.ForEach(func(_, v []byte) error {
+			candidate, err := deserializeSpliceCandidate(
+				bytes.NewReader(v),
+			)
+			if err != nil {
+				return err
+			}
+
+			spliceCandidates = append(spliceCandidates, candidate)
+
+			return nil
+		})
+	}, func() {
+		spliceCandidates = nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return spliceCandidates, nil
+}
+
+// ClearSpliceCandidates removes all splice candidates of the channel. This
+// is used if the negotiation of a splice fails before any candidate could be
+// broadcast.
+func (c *OpenChannel) ClearSpliceCandidates() error {
+	c.Lock()
+	defer c.Unlock()
+
+	return kvdb.Update(c.Db.backend, func(tx kvdb.RwTx) error {
+		chanBucket, err := fetchChanBucketRw(
+			tx, c.IdentityPub, &c.FundingOutpoint, c.ChainHash,
+		)
+		if err != nil {
+			return err
+		}
+
+		err = chanBucket.DeleteNestedBucket(spliceCandidatesKey)
+		if err != nil && err != kvdb.ErrBucketNotFound {
+			return err
+		}
+
+		return nil
+	}, func() {})
+}
+
+// SplicedFrom returns the funding outpoint the channel used before it was
+// last spliced, or nil if the channel was never spliced.
+func (c *OpenChannel) SplicedFrom() (*wire.OutPoint, error) {
+	c.RLock()
+	defer c.RUnlock()
+
+	var splicedFrom *wire.OutPoint
+	err := kvdb.View(c.Db.backend, func(tx kvdb.RTx) error {
+		chanBucket, err := fetchChanBucket(
+			tx, c.IdentityPub, &c.FundingOutpoint, c.ChainHash,
+		)
+		if err != nil {
+			return err
+		}
+
+		opBytes := chanBucket.Get(splicedFromKey)
+		if opBytes == nil {
+			return nil
+		}
+
+		var op wire.OutPoint
+		if err := readOutpoint(bytes.NewReader(opBytes), &op); err != nil {
+			return err
+		}
+		splicedFrom = &op
+
+		return nil
+	}, func() {
+		splicedFrom = nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return splicedFrom, nil
+}
+
+// CompleteSplice switches the channel over to the funding output created by
+// the confirmed splice candidate with the given txid. The channel is moved to
+// its new funding outpoint within the database, its capacity and commitments
+// are replaced by those of the candidate and all other candidates are
+// discarded. The short channel ID passed is the location of the splice
+// transaction within the chain. If the channel uses an alias, the alias is
+// kept and only the confirmed short channel ID is updated.
+func (c *OpenChannel) CompleteSplice(spliceTxid chainhash.Hash,
+	scid lnwire.ShortChannelID) error {
+
+	c.Lock()
+	defer c.Unlock()
+
+	var (
+		oldChanPoint = c.FundingOutpoint
+		candidate    *SpliceCandidate
+	)
+	if err := kvdb.Update(c.Db.backend, func(tx kvdb.RwTx) error {
+		chainBucket, err := fetchChainBucketRw(tx, c)
+		if err != nil {
+			return err
+		}
+
+		var oldKey bytes.Buffer
+		if err := writeOutpoint(&oldKey, &oldChanPoint); err != nil {
+			return err
+		}
+		oldBucket := chainBucket.NestedReadWriteBucket(oldKey.Bytes())
+		if oldBucket == nil {
+			return ErrChannelNotFound
+		}
+
+		// First, we'll look up the candidate that confirmed.
+		candidates := oldBucket.NestedReadWriteBucket(
+			spliceCandidatesKey,
+		)
+		if candidates == nil {
+			return ErrSpliceCandidateNotFound
+		}
+		candidateBytes := candidates.Get(spliceTxid[:])
+		if candidateBytes == nil {
+			return ErrSpliceCandidateNotFound
+		}
+		candidate, err = deserializeSpliceCandidate(
+			bytes.NewReader(candidateBytes),
+		)
+		if err != nil {
+			return err
+		}
+
+		// The candidates are discarded, as the splice transaction
+		// that confirmed double spends all of them.
+		if err := oldBucket.DeleteNestedBucket(
+			spliceCandidatesKey,
+		); err != nil {
+			return err
+		}
+
+		// With the candidate found, we'll move the channel over to
+		// its new funding outpoint, carrying over all of its state.
+		var newKey bytes.Buffer
+		err = writeOutpoint(&newKey, &candidate.FundingOutpoint)
+		if err != nil {
+			return err
+		}
+		newBucket, err := chainBucket.CreateBucket(newKey.Bytes())
+		if err != nil {
+			return err
+		}
+		if err := copyBucket(newBucket, oldBucket); err != nil {
+			return err
+		}
+		if err := chainBucket.DeleteNestedBucket(
+			oldKey.Bytes(),
+		); err != nil {
+			return err
+		}
+
+		// The forwarding packages are indexed by the short channel ID,
+		// so they are removed if it changes. The channel is quiescent
+		// while splicing, so none of them are pending.
+		if !c.ChanType.HasScidAliasChan() && scid != c.ShortChannelID {
+			if err := c.Packager.Wipe(tx); err != nil {
+				return err
+			}
+		}
+
+		// Now we can update the state of the channel itself.
+		channel, err := fetchOpenChannel(newBucket, &oldChanPoint)
+		if err != nil {
+			return err
+		}
+		applySplice(channel, candidate, scid)
+
+		if err := putOpenChannel(newBucket, channel); err != nil {
+			return err
+		}
+		if err := newBucket.Put(splicedFromKey, oldKey.Bytes()); err != nil {
+			return err
+		}
+		err = newBucket.Put(spliceLockPendingKey, spliceTxid[:])
+		if err != nil {
+			return err
+		}
+
+		// Finally, the old funding outpoint is marked as closed
+		// within our outpoint index, and the new one as open.
+		opBucket := tx.ReadWriteBucket(outpointBucket)
+		if opBucket.Get(oldKey.Bytes()) == nil {
+			return ErrMissingIndexEntry
+		}
+		err = putOutpointStatus(opBucket, oldKey.Bytes(), outpointClosed)
+		if err != nil {
+			return err
+		}
+
+		return putOutpointStatus(opBucket, newKey.Bytes(), outpointOpen)
+	}, func() {
+		candidate = nil
+	}); err != nil {
+		return err
+	}
+
+	applySplice(c, candidate, scid)
+	c.Packager = NewChannelPackager(c.ShortChannelID)
+
+	return nil
+}
+
+// SpliceLockPending returns true if the channel was moved over to the funding
+// output of its splice, but the splice hasn't been locked in by both parties
+// yet.
+func (c *OpenChannel) SpliceLockPending() (bool, error) {
+	c.RLock()
+	defer c.RUnlock()
+
+	var pending bool
+	err := kvdb.View(c.Db.backend, func(tx kvdb.RTx) error {
+		chanBucket, err := fetchChanBucket(
+			tx, c.IdentityPub, &c.FundingOutpoint, c.ChainHash,
+		)
+		if err != nil {
+			return err
+		}
+
+		pending = chanBucket.Get(spliceLockPendingKey) != nil
+
+		return nil
+	}, func() {
+		pending = false
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return pending, nil
+}
+
+// ClearSpliceLock marks the splice of the channel as locked in by both
+// parties, and removes the snapshot of its edge.
+func (c *OpenChannel) ClearSpliceLock() error {
+	c.Lock()
+	defer c.Unlock()
+
+	return kvdb.Update(c.Db.backend, func(tx kvdb.RwTx) error {
+		chanBucket, err := fetchChanBucketRw(
+			tx, c.IdentityPub, &c.FundingOutpoint, c.ChainHash,
+		)
+		if err != nil {
+			return err
+		}
+
+		if err := chanBucket.Delete(spliceLockPendingKey); err != nil {
+			return err
+		}
+
+		err = chanBucket.DeleteNestedBucket(spliceEdgeKey)
+		if err != nil && err != kvdb.ErrBucketNotFound {
+			return err
+		}
+
+		return nil
+	}, func() {})
+}
+
+// PutSpliceEdge stores a snapshot of the channel's edge, replacing any
+// previous one. It's carried over to the new funding outpoint of the channel
+// once its splice completes.
+func (c *OpenChannel) PutSpliceEdge(edge *SpliceEdge) error {
+	c.Lock()
+	defer c.Unlock()
+
+	return kvdb.Update(c.Db.backend, func(tx kvdb.RwTx) error {
+		chanBucket, err := fetchChanBucketRw(
+			tx, c.IdentityPub, &c.FundingOutpoint, c.ChainHash,
+		)
+		if err != nil {
+			return err
+		}
+
+		err = chanBucket.DeleteNestedBucket(spliceEdgeKey)
+		if err != nil && err != kvdb.ErrBucketNotFound {
+			return err
+		}
+		edgeBucket, err := chanBucket.CreateBucket(spliceEdgeKey)
+		if err != nil {
+			return err
+		}
+
+		var chanID [8]byte
+		byteOrder.PutUint64(chanID[:], edge.Info.ChannelID)
+		err = putChanEdgeInfo(edgeBucket, edge.Info, chanID)
+		if err != nil {
+			return err
+		}
+
+		policyKeys := [2][]byte{
+			spliceEdgePolicy1Key, spliceEdgePolicy2Key,
+		}
+		for i, policy := range edge.Policies {
+			if policy == nil {
+				continue
+			}
+
+			var b bytes.Buffer
+			err := serializeChanEdgePolicy(
+				&b, policy, policy.Node.PubKeyBytes[:],
+			)
+			if err != nil {
+				return err
+			}
+
+			err = edgeBucket.Put(policyKeys[i], b.Bytes())
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}, func() {})
+}
+
+// SpliceEdge returns the snapshot of the channel's edge, or nil if none was
+// stored.
+func (c *OpenChannel) SpliceEdge() (*SpliceEdge, error) {
+	c.RLock()
+	defer c.RUnlock()
+
+	var edge *SpliceEdge
+	err := kvdb.View(c.Db.backend, func(tx kvdb.RTx) error {
+		chanBucket, err := fetchChanBucket(
+			tx, c.IdentityPub, &c.FundingOutpoint, c.ChainHash,
+		)
+		if err != nil {
+			return err
+		}
+
+		edgeBucket := chanBucket.NestedReadBucket(spliceEdgeKey)
+		if edgeBucket == nil {
+			return nil
+		}
+
+		edge = &SpliceEdge{}
+		return edgeBucket.ForEach(func(k, v []byte) error {
+			r := bytes.NewReader(v)
+
+			switch {
+			case bytes.Equal(k, spliceEdgePolicy1Key),
+				bytes.Equal(k, spliceEdgePolicy2Key):
+
+				// Like the graph, we'll keep policies stored
+				// before max_htlc was validated.
+				policy, err := deserializeChanEdgePolicyRaw(r)
+				switch {
+				case err == ErrEdgePolicyOptionalFieldNotFound:
+				case err != nil:
+					return err
+				}
+
+				idx := 0
+				if bytes.Equal(k, spliceEdgePolicy2Key) {
+					idx = 1
+				}
+				edge.Policies[idx] = policy
+
+			default:
+				info, err := deserializeChanEdgeInfo(r)
+				if err != nil {
+					return err
+				}
+				edge.Info = &info
+			}
+
+			return nil
+		})
+	}, func() {
+		edge = nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return edge, nil
+}
+
+// applySplice updates the state of the channel to spend the funding output of
+// the given splice candidate.
+func applySplice(c *OpenChannel, candidate *SpliceCandidate,
+	scid lnwire.ShortChannelID) {
+
+	c.FundingOutpoint = candidate.FundingOutpoint
+	c.Capacity = candidate.Capacity
+	c.LocalCommitment = candidate.LocalCommitment
+	c.RemoteCommitment = candidate.RemoteCommitment
+
+	if c.ChanType.HasScidAliasChan() {
+		c.confirmedScid = scid
+	} else {
+		c.ShortChannelID = scid
+	}
+
+	if fundingTxPresent(c) {
+		c.FundingTxn = candidate.SpliceTx
+	}
+}
+
+// fetchChainBucketRw returns the bucket storing all channels of the given
+// channel's node and chain.
+func fetchChainBucketRw(tx kvdb.RwTx, c *OpenChannel) (kvdb.RwBucket, error) {
+	openChanBucket := tx.ReadWriteBucket(openChannelBucket)
+	if openChanBucket == nil {
+		return nil, ErrNoChanDBExists
+	}
+
+	nodePub := c.IdentityPub.SerializeCompressed()
+	nodeChanBucket := openChanBucket.NestedReadWriteBucket(nodePub)
+	if nodeChanBucket == nil {
+		return nil, ErrNoActiveChannels
+	}
+
+	chainBucket := nodeChanBucket.NestedReadWriteBucket(c.ChainHash[:])
+	if chainBucket == nil {
+		return nil, ErrNoActiveChannels
+	}
+
+	return chainBucket, nil
+}
+
+// copyBucket recursively copies all keys and nested buckets of src to dst.
+func copyBucket(dst kvdb.RwBucket, src kvdb.RwBucket) error {
+	return src.ForEach(func(k, v []byte) error {
+		// Values are only nil for nested buckets.
+		if v != nil {
+			return dst.Put(k, v)
+		}
+
+		nestedSrc := src.NestedReadWriteBucket(k)
+		if nestedSrc == nil {
+			return fmt.Errorf("nested bucket %x not found", k)
+		}
+		nestedDst, err := dst.CreateBucket(k)
+		if err != nil {
+			return err
+		}
+
+		return copyBucket(nestedDst, nestedSrc)
+	})
+}
+
+// putOutpointStatus writes the status of the outpoint with the given key to
+// the outpoint index.
+func putOutpointStatus(opBucket kvdb.RwBucket, opKey []byte,
+	opStatus indexStatus) error {
+
+	status := uint8(opStatus)
+
+	// Write the status of this outpoint as the first entry in a tlv
+	// stream.
+	statusRecord := tlv.MakePrimitiveRecord(indexStatusType, &status)
+	opStream, err := tlv.NewStream(statusRecord)
+	if err != nil {
+		return err
+	}
+
+	var b bytes.Buffer
+	if err := opStream.Encode(&b); err != nil {
+		return err
+	}
+
+	return opBucket.Put(opKey, b.Bytes())
+}
@@ -0,0 +1,217 @@
+package channeldb
+
+import (
+	"testing"
+
+	"github.com/brsuite/brond/wire"
+	"github.com/brsuite/bronutil"
+	"github.com/brsuite/broln/lnwire"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSpliceCandidates tests that splice candidates can be stored for a
+// channel, and that completing a splice moves the channel over to the funding
+// outpoint of the confirmed candidate.
+func TestSpliceCandidates(t *testing.T) {
+	t.Parallel()
+
+	fullDB, cleanUp, err := MakeTestDB()
+	require.NoError(t, err)
+	defer cleanUp()
+
+	cdb := fullDB.ChannelStateDB()
+
+	channel := createTestChannel(t, cdb, openChannelOption())
+	oldChanPoint := channel.FundingOutpoint
+
+	candidates, err := channel.SpliceCandidates()
+	require.NoError(t, err)
+	require.Empty(t, candidates)
+
+	splicedFrom, err := channel.SplicedFrom()
+	require.NoError(t, err)
+	require.Nil(t, splicedFrom)
+
+	// newCandidate creates a splice candidate spending the channel's
+	// funding output at the given fee rate.
+	newCandidate := func(feeRate bronutil.Amount) *SpliceCandidate {
+		spliceTx := wire.NewMsgTx(2)
+		spliceTx.AddTxIn(&wire.TxIn{PreviousOutPoint: oldChanPoint})
+		spliceTx.AddTxOut(&wire.TxOut{
+			Value:    int64(channel.Capacity) * 2,
+			PkScript: make([]byte, 34),
+		})
+
+		localCommit := channel.LocalCommitment
+		localCommit.LocalBalance += lnwire.NewMSatFromSatoshis(
+			channel.Capacity,
+		)
+		remoteCommit := channel.RemoteCommitment
+		remoteCommit.LocalBalance += lnwire.NewMSatFromSatoshis(
+			channel.Capacity,
+		)
+
+		return &SpliceCandidate{
+			SpliceTx: spliceTx,
+			FundingOutpoint: wire.OutPoint{
+				Hash: spliceTx.TxHash(),
+			},
+			Capacity:         channel.Capacity * 2,
+			FeePerKw:         feeRate,
+			LocalCommitment:  localCommit,
+			RemoteCommitment: remoteCommit,
+		}
+	}
+
+	// We'll add two candidates, one replacing the other by fee.
+	candidate := newCandidate(253)
+	rbfCandidate := newCandidate(500)
+	rbfCandidate.SpliceTx.LockTime = 1
+	rbfCandidate.FundingOutpoint.Hash = rbfCandidate.SpliceTx.TxHash()
+
+	require.NoError(t, channel.AddSpliceCandidate(candidate))
+	require.NoError(t, channel.AddSpliceCandidate(rbfCandidate))
+
+	// Adding the same candidate again overwrites it.
+	require.NoError(t, channel.AddSpliceCandidate(candidate))
+
+	candidates, err = channel.SpliceCandidates()
+	require.NoError(t, err)
+	require.Len(t, candidates, 2)
+
+	// Completing a splice with a transaction that isn't a candidate
+	// fails.
+	scid := lnwire.ShortChannelID{BlockHeight: 200, TxIndex: 1}
+	err = channel.CompleteSplice(wire.NewMsgTx(2).TxHash(), scid)
+	require.ErrorIs(t, err, ErrSpliceCandidateNotFound)
+
+	// Now we'll complete the splice with the replacement that confirmed.
+	err = channel.CompleteSplice(rbfCandidate.SpliceTx.TxHash(), scid)
+	require.NoError(t, err)
+
+	require.Equal(t, rbfCandidate.FundingOutpoint, channel.FundingOutpoint)
+	require.Equal(t, rbfCandidate.Capacity, channel.Capacity)
+	require.Equal(t, scid, channel.ShortChannelID)
+
+	// The channel can no longer be found under its old funding outpoint,
+	// and its state was moved over to the new one.
+	_, err = cdb.FetchChannel(nil, oldChanPoint)
+	require.ErrorIs(t, err, ErrChannelNotFound)
+
+	dbChannel, err := cdb.FetchChannel(nil, rbfCandidate.FundingOutpoint)
+	require.NoError(t, err)
+	require.Equal(t, rbfCandidate.Capacity, dbChannel.Capacity)
+	require.Equal(t, scid, dbChannel.ShortChannelID)
+	assertCommitmentEqual(
+		t, &rbfCandidate.LocalCommitment, &dbChannel.LocalCommitment,
+	)
+	assertCommitmentEqual(
+		t, &rbfCandidate.RemoteCommitment, &dbChannel.RemoteCommitment,
+	)
+
+	candidates, err = dbChannel.SpliceCandidates()
+	require.NoError(t, err)
+	require.Empty(t, candidates)
+
+	splicedFrom, err = dbChannel.SplicedFrom()
+	require.NoError(t, err)
+	require.Equal(t, &oldChanPoint, splicedFrom)
+
+	// Finally, the revocation state of the channel must have been carried
+	// over as well.
+	require.Equal(
+		t, channel.RemoteCurrentRevocation,
+		dbChannel.RemoteCurrentRevocation,
+	)
+}
+
+// TestSpliceLockState tests that the lock-in state of a splice and the
+// snapshot of the channel's edge are carried over to the new funding outpoint
+// of the channel, until the splice is locked in.
+func TestSpliceLockState(t *testing.T) {
+	t.Parallel()
+
+	fullDB, cleanUp, err := MakeTestDB()
+	require.NoError(t, err)
+	defer cleanUp()
+
+	cdb := fullDB.ChannelStateDB()
+
+	channel := createTestChannel(t, cdb, openChannelOption())
+	oldChanPoint := channel.FundingOutpoint
+
+	edge, err := channel.SpliceEdge()
+	require.NoError(t, err)
+	require.Nil(t, edge)
+
+	// We'll store a snapshot of the channel's edge, with only the policy
+	// of the second node known.
+	node1, err := createTestVertex(fullDB)
+	require.NoError(t, err)
+	node2, err := createTestVertex(fullDB)
+	require.NoError(t, err)
+
+	edgeInfo, scid := createEdge(100, 0, 0, 0, node1, node2)
+	policy := newEdgePolicy(edgeInfo.ChannelID, nil, 1000)
+	policy.ChannelFlags = lnwire.ChanUpdateDirection
+	policy.Node = &LightningNode{PubKeyBytes: node1.PubKeyBytes}
+
+	require.NoError(t, channel.PutSpliceEdge(&SpliceEdge{
+		Info:     &edgeInfo,
+		Policies: [2]*ChannelEdgePolicy{nil, policy},
+	}))
+
+	pending, err := channel.SpliceLockPending()
+	require.NoError(t, err)
+	require.False(t, pending)
+
+	spliceTx := wire.NewMsgTx(2)
+	spliceTx.AddTxIn(&wire.TxIn{PreviousOutPoint: oldChanPoint})
+	spliceTx.AddTxOut(&wire.TxOut{
+		Value:    int64(channel.Capacity),
+		PkScript: make([]byte, 34),
+	})
+	candidate := &SpliceCandidate{
+		SpliceTx: spliceTx,
+		FundingOutpoint: wire.OutPoint{
+			Hash: spliceTx.TxHash(),
+		},
+		Capacity:         channel.Capacity,
+		FeePerKw:         253,
+		LocalCommitment:  channel.LocalCommitment,
+		RemoteCommitment: channel.RemoteCommitment,
+	}
+	require.NoError(t, channel.AddSpliceCandidate(candidate))
+
+	newScid := lnwire.ShortChannelID{BlockHeight: 200, TxIndex: 1}
+	require.NoError(t, channel.CompleteSplice(spliceTx.TxHash(), newScid))
+
+	// The splice now awaits lock-in, and the snapshot of the edge moved
+	// over with the channel.
+	dbChannel, err := cdb.FetchChannel(nil, candidate.FundingOutpoint)
+	require.NoError(t, err)
+
+	pending, err = dbChannel.SpliceLockPending()
+	require.NoError(t, err)
+	require.True(t, pending)
+
+	edge, err = dbChannel.SpliceEdge()
+	require.NoError(t, err)
+	require.NotNil(t, edge)
+	assertEdgeInfoEqual(t, &edgeInfo, edge.Info)
+	require.Equal(t, scid.ToUint64(), edge.Info.ChannelID)
+	require.Nil(t, edge.Policies[0])
+	require.NotNil(t, edge.Policies[1])
+	require.NoError(t, compareEdgePolicies(policy, edge.Policies[1]))
+
+	// Once the splice is locked in, both are removed.
+	require.NoError(t, dbChannel.ClearSpliceLock())
+
+	pending, err = dbChannel.SpliceLockPending()
+	require.NoError(t, err)
+	require.False(t, pending)
+
+	edge, err = dbChannel.SpliceEdge()
+	require.NoError(t, err)
+	require.Nil(t, edge)
+}
@@ -1,6 +1,7 @@
 package broln
 
 import (
+	"bytes"
 	"fmt"
 	"math"
 	"net"
@@ -342,3 +343,113 @@ func (s *server) ConnectPeer(nodePub *btcec.PublicKey, addrs []net.Addr) error {
 	return fmt.Errorf("unable to connect to peer %x for SCB restore",
 		nodePub.SerializeCompressed())
 }
+
+// A compile-time constraint to ensure server implements
+// chanbackup.PeerStorer.
+var _ chanbackup.PeerStorer = (*server)(nil)
+
+// StorePeerBackup asks the target peer to store the packed backup of the
+// channels we have with it. Peers that aren't connected are skipped, as
+// they're handed the latest backup once they connect. Peers that don't
+// provide storage are skipped as well.
+//
+// NOTE: Part of the chanbackup.PeerStorer interface.
+func (s *server) StorePeerBackup(nodePub *btcec.PublicKey,
+	backup chanbackup.PackedMulti) error {
+
+	peer, err := s.FindPeer(nodePub)
+	if err == ErrPeerNotConnected {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if !peer.RemoteFeatures().HasFeature(lnwire.ProvideStorageOptional) {
+		return nil
+	}
+
+	return peer.SendMessageLazy(false, &lnwire.PeerStorage{
+		Blob: lnwire.PeerStorageBlob(backup),
+	})
+}
+
+// handlePeerBackup restores the channels found within the backup a peer
+// handed back to us in the background. See restorePeerBackup.
+func (s *server) handlePeerBackup(nodePub [33]byte, backup []byte) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		if err := s.restorePeerBackup(nodePub, backup); err != nil {
+			ltndLog.Errorf("Unable to restore channels from backup "+
+				"of peer %x: %v", nodePub, err)
+		}
+	}()
+}
+
+// restorePeerBackup restores the channels found within the backup a peer
+// handed back to us, that aren't known to us. This allows a node that was
+// restored from its seed alone to recover its channels without a backup file,
+// as its channel peers hand the backup back once they reconnect.
+func (s *server) restorePeerBackup(nodePub [33]byte, backup []byte) error {
+	packedMulti := chanbackup.PackedMulti(backup)
+	multi, err := packedMulti.Unpack(s.cc.KeyRing)
+	if err != nil {
+		return fmt.Errorf("unable to unpack backup: %v", err)
+	}
+
+	var chansToRestore []chanbackup.Single
+	for _, single := range multi.StaticBackups {
+		// The peer is only supposed to store the backups of the
+		// channels it has with us.
+		if !bytes.Equal(
+			single.RemoteNodePub.SerializeCompressed(), nodePub[:],
+		) {
+
+			ltndLog.Warnf("Ignoring backup of ChannelPoint(%v) "+
+				"handed back by other peer %x",
+				single.FundingOutpoint, nodePub)
+			continue
+		}
+
+		// Channels that are still open, or that were already
+		// restored, are skipped.
+		chanPoint := single.FundingOutpoint
+		_, err := s.chanStateDB.FetchChannel(nil, chanPoint)
+		switch {
+		case err == nil:
+			continue
+
+		case err != channeldb.ErrChannelNotFound:
+			return err
+		}
+
+		// A backup handed back by the peer may be outdated, so we'll
+		// also skip channels that we closed since.
+		_, err = s.chanStateDB.FetchClosedChannel(&chanPoint)
+		switch {
+		case err == nil:
+			continue
+
+		case err != channeldb.ErrClosedChannelNotFound:
+			return err
+		}
+
+		chansToRestore = append(chansToRestore, single)
+	}
+
+	if len(chansToRestore) == 0 {
+		return nil
+	}
+
+	ltndLog.Infof("Restoring %v channel(s) from backup handed back by "+
+		"peer %x", len(chansToRestore), nodePub)
+
+	chanRestorer := &chanDBRestorer{
+		db:         s.chanStateDB,
+		secretKeys: s.cc.KeyRing,
+		chainArb:   s.chainArb,
+	}
+
+	return chanbackup.Recover(chansToRestore, chanRestorer, s)
+}
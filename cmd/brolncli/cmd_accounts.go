@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/brsuite/broln/lnrpc"
+	"github.com/urfave/cli"
+)
+
+var offchainAccountsCommand = cli.Command{
+	Name:     "accounts",
+	Category: "Macaroons",
+	Usage:    "Manage off-chain accounts with virtual balances.",
+	Description: `
+	Off-chain accounts allow several applications to share one node, each
+	spending from its own virtual balance. A macaroon is bound to an account
+	by baking it with the --account_id flag of the bakemacaroon command.
+
+	Payments made with such a macaroon are debited from the account, and
+	invoices created with it credit the account once they are paid.`,
+	Subcommands: []cli.Command{
+		createOffchainAccountCommand,
+		listOffchainAccountsCommand,
+		removeOffchainAccountCommand,
+	},
+}
+
+var createOffchainAccountCommand = cli.Command{
+	Name:      "create",
+	Usage:     "Create a new off-chain account.",
+	ArgsUsage: "balance [--label=]",
+	Description: `
+	Create a new off-chain account with the given initial balance in
+	satoshis.`,
+	Flags: []cli.Flag{
+		cli.Uint64Flag{
+			Name:  "balance",
+			Usage: "the initial balance of the account in satoshis",
+		},
+		cli.StringFlag{
+			Name:  "label",
+			Usage: "an optional label to identify the account",
+		},
+	},
+	Action: actionDecorator(createOffchainAccount),
+}
+
+func createOffchainAccount(ctx *cli.Context) error {
+	ctxc := getContext()
+	client, cleanUp := getClient(ctx)
+	defer cleanUp()
+
+	var (
+		balance uint64
+		err     error
+	)
+	switch {
+	case ctx.IsSet("balance"):
+		balance = ctx.Uint64("balance")
+
+	case ctx.Args().Present():
+		balance, err = strconv.ParseUint(ctx.Args().First(), 10, 64)
+		if err != nil {
+			return fmt.Errorf("unable to decode balance: %v", err)
+		}
+
+	default:
+		return cli.ShowCommandHelp(ctx, "create")
+	}
+
+	resp, err := client.CreateAccount(ctxc, &lnrpc.CreateAccountRequest{
+		BalanceMsat: balance * 1000,
+		Label:       ctx.String("label"),
+	})
+	if err != nil {
+		return err
+	}
+
+	printRespJSON(resp)
+	return nil
+}
+
+var listOffchainAccountsCommand = cli.Command{
+	Name:   "list",
+	Usage:  "List all off-chain accounts.",
+	Action: actionDecorator(listOffchainAccounts),
+}
+
+func listOffchainAccounts(ctx *cli.Context) error {
+	ctxc := getContext()
+	client, cleanUp := getClient(ctx)
+	defer cleanUp()
+
+	resp, err := client.ListAccounts(ctxc, &lnrpc.ListAccountsRequest{})
+	if err != nil {
+		return err
+	}
+
+	printRespJSON(resp)
+	return nil
+}
+
+var removeOffchainAccountCommand = cli.Command{
+	Name:      "remove",
+	Usage:     "Remove an off-chain account.",
+	ArgsUsage: "id",
+	Description: `
+	Remove the off-chain account with the given ID. The macaroons bound to
+	the account can't be used anymore afterwards.`,
+	Action: actionDecorator(removeOffchainAccount),
+}
+
+func removeOffchainAccount(ctx *cli.Context) error {
+	ctxc := getContext()
+	client, cleanUp := getClient(ctx)
+	defer cleanUp()
+
+	if ctx.NArg() != 1 {
+		return cli.ShowCommandHelp(ctx, "remove")
+	}
+
+	resp, err := client.RemoveAccount(ctxc, &lnrpc.RemoveAccountRequest{
+		Id: ctx.Args().First(),
+	})
+	if err != nil {
+		return err
+	}
+
+	printRespJSON(resp)
+	return nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"github.com/brsuite/broln/lnrpc"
+	"github.com/urfave/cli"
+)
+
+var auditLogCommand = cli.Command{
+	Name:     "auditlog",
+	Category: "Macaroons",
+	Usage:    "Query and verify the audit log of state-changing calls.",
+	Description: `
+	The audit log records every state-changing RPC call, such as payments,
+	channel opens and closes, on-chain sends, macaroon bakes and policy
+	updates, along with the macaroon and address of the caller and the
+	result of the call. Each entry is chained to the one preceding it by its
+	hash, so that tampering with the log can be detected.
+
+	The audit log must be enabled with the --auditlog.enable option.`,
+	Subcommands: []cli.Command{
+		queryAuditLogCommand,
+		verifyAuditLogCommand,
+	},
+}
+
+var queryAuditLogCommand = cli.Command{
+	Name:  "query",
+	Usage: "Query the entries of the audit log.",
+	Description: `
+	Return the entries of the audit log in the order they were recorded,
+	optionally filtered by method and time range.`,
+	Flags: []cli.Flag{
+		cli.Uint64Flag{
+			Name: "index_offset",
+			Usage: "the index of the entry after which entries " +
+				"are returned",
+		},
+		cli.Uint64Flag{
+			Name:  "max_entries",
+			Usage: "the maximum number of entries to return",
+			Value: 100,
+		},
+		cli.StringFlag{
+			Name: "method",
+			Usage: "only return the entries of the given full method " +
+				"URI, for example /lnrpc.Lightning/SendCoins",
+		},
+		cli.Int64Flag{
+			Name: "start_time",
+			Usage: "only return the entries recorded at or after " +
+				"the given unix timestamp",
+		},
+		cli.Int64Flag{
+			Name: "end_time",
+			Usage: "only return the entries recorded before the " +
+				"given unix timestamp",
+		},
+	},
+	Action: actionDecorator(queryAuditLog),
+}
+
+func queryAuditLog(ctx *cli.Context) error {
+	ctxc := getContext()
+	client, cleanUp := getClient(ctx)
+	defer cleanUp()
+
+	resp, err := client.QueryAuditLog(ctxc, &lnrpc.QueryAuditLogRequest{
+		IndexOffset: ctx.Uint64("index_offset"),
+		MaxEntries:  ctx.Uint64("max_entries"),
+		Method:      ctx.String("method"),
+		StartTime:   ctx.Int64("start_time"),
+		EndTime:     ctx.Int64("end_time"),
+	})
+	if err != nil {
+		return err
+	}
+
+	printRespJSON(resp)
+	return nil
+}
+
+var verifyAuditLogCommand = cli.Command{
+	Name:  "verify",
+	Usage: "Verify the hash chain of the audit log.",
+	Description: `
+	Verify that each entry of the audit log is chained to the entry
+	preceding it, and that the log still ends with the last entry recorded
+	by the node. The hash of the last entry is returned, so that it can be
+	stored externally to later detect the rewrite of the whole log.`,
+	Action: actionDecorator(verifyAuditLog),
+}
+
+func verifyAuditLog(ctx *cli.Context) error {
+	ctxc := getContext()
+	client, cleanUp := getClient(ctx)
+	defer cleanUp()
+
+	resp, err := client.VerifyAuditLog(
+		ctxc, &lnrpc.VerifyAuditLogRequest{},
+	)
+	if err != nil {
+		return err
+	}
+
+	printRespJSON(resp)
+	return nil
+}
@@ -71,6 +71,12 @@ var addInvoiceCommand = cli.Command{
 			Usage: "creates an AMP invoice. If true, preimage " +
 				"should not be set.",
 		},
+		cli.BoolFlag{
+			Name: "blind",
+			Usage: "[experimental] hide the node behind a blinded " +
+				"path that starts at one of its public peers. " +
+				"Implies --private=false.",
+		},
 	},
 	Action: actionDecorator(addInvoice),
 }
@@ -115,6 +121,13 @@ func addInvoice(ctx *cli.Context) error {
 		return fmt.Errorf("unable to parse description_hash: %v", err)
 	}
 
+	// Route hints would reveal the node blinded invoices are meant to hide,
+	// so they aren't included by default if the invoice is blinded.
+	private := ctx.Bool("private")
+	if ctx.Bool("blind") && !ctx.IsSet("private") {
+		private = false
+	}
+
 	invoice := &lnrpc.Invoice{
 		Memo:            ctx.String("memo"),
 		RPreimage:       preimage,
@@ -123,8 +136,9 @@ func addInvoice(ctx *cli.Context) error {
 		DescriptionHash: descHash,
 		FallbackAddr:    ctx.String("fallback_addr"),
 		Expiry:          ctx.Int64("expiry"),
-		Private:         ctx.Bool("private"),
+		Private:         private,
 		IsAmp:           ctx.Bool("amp"),
+		Blind:           ctx.Bool("blind"),
 	}
 
 	resp, err := client.AddInvoice(ctxc, invoice)
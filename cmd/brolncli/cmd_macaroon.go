@@ -10,7 +10,9 @@ import (
 	"strings"
 	"unicode"
 
+	"github.com/brsuite/bronutil"
 	"github.com/golang/protobuf/proto"
+	"github.com/brsuite/broln/accounts"
 	"github.com/brsuite/broln/lncfg"
 	"github.com/brsuite/broln/lnrpc"
 	"github.com/brsuite/broln/macaroons"
@@ -48,6 +50,20 @@ var bakeMacaroonCommand = cli.Command{
 	The macaroon created by this command would only be allowed to use the
 	"brolncli getinfo" and "brolncli version" commands.
 
+	The funds that can be spent with a macaroon can be restricted with the
+	--payment_max_amt, --payment_budget, --payment_dest and
+	--payment_memo_prefix flags. These restrictions are enforced for the
+	SendPaymentV2, SendCoins, OpenChannel and OpenChannelSync calls, while
+	all other calls able to spend funds are rejected for such a macaroon,
+	for example:
+
+	brolncli bakemacaroon --payment_max_amt=10000 --payment_budget=100000 offchain:read offchain:write
+
+	A macaroon can also be bound to an off-chain account created with the
+	accounts command by passing its ID with --account_id. Such a macaroon
+	can only spend the balance of the account, and is credited the invoices
+	created with it.
+
 	To get a list of all available URIs and permissions, use the
 	"brolncli listpermissions" command.
 	`,
@@ -80,6 +96,33 @@ var bakeMacaroonCommand = cli.Command{
 			Name:  "root_key_id",
 			Usage: "the numerical root key ID used to create the macaroon",
 		},
+		cli.Int64Flag{
+			Name: "payment_max_amt",
+			Usage: "the maximum amount in satoshis of any single " +
+				"payment made with the macaroon",
+		},
+		cli.Int64Flag{
+			Name: "payment_budget",
+			Usage: "the maximum amount in satoshis, including " +
+				"fees, that may be spent with the macaroon " +
+				"within a rolling 24 hour period",
+		},
+		cli.StringSliceFlag{
+			Name: "payment_dest",
+			Usage: "a hex encoded node public key the macaroon " +
+				"is allowed to pay to, can be specified " +
+				"multiple times",
+		},
+		cli.StringFlag{
+			Name: "payment_memo_prefix",
+			Usage: "the prefix the memo of any invoice paid with " +
+				"the macaroon must start with",
+		},
+		cli.StringFlag{
+			Name: "account_id",
+			Usage: "the ID of the off-chain account to bind the " +
+				"macaroon to",
+		},
 		cli.BoolFlag{
 			Name:  "allow_external_permissions",
 			Usage: "whether permissions broln is not familiar with are allowed",
@@ -236,6 +279,53 @@ func bakeMacaroon(ctx *cli.Context) error {
 			),
 		)
 	}
+
+	// Finally, we'll add the caveats that restrict the funds that can be
+	// spent with the macaroon. Their values are validated when they're
+	// applied.
+	if ctx.IsSet("payment_max_amt") {
+		macConstraints = append(
+			macConstraints, macaroons.PaymentMaxAmtConstraint(
+				bronutil.Amount(ctx.Int64("payment_max_amt")),
+			),
+		)
+	}
+	if ctx.IsSet("payment_budget") {
+		macConstraints = append(
+			macConstraints, macaroons.PaymentBudgetConstraint(
+				bronutil.Amount(ctx.Int64("payment_budget")),
+			),
+		)
+	}
+	if ctx.IsSet("payment_dest") {
+		macConstraints = append(
+			macConstraints, macaroons.PaymentDestConstraint(
+				ctx.StringSlice("payment_dest")...,
+			),
+		)
+	}
+	if ctx.IsSet("payment_memo_prefix") {
+		macConstraints = append(
+			macConstraints, macaroons.PaymentMemoPrefixConstraint(
+				ctx.String("payment_memo_prefix"),
+			),
+		)
+	}
+
+	// A macaroon bound to an off-chain account can only spend the balance
+	// of that account.
+	if ctx.IsSet("account_id") {
+		accountID, err := accounts.ParseAccountID(
+			ctx.String("account_id"),
+		)
+		if err != nil {
+			return err
+		}
+
+		macConstraints = append(
+			macConstraints, accounts.AccountConstraint(accountID),
+		)
+	}
 	constrainedMac, err := macaroons.AddConstraints(
 		unmarshalMac, macConstraints...,
 	)
@@ -7,6 +7,7 @@ import (
 	"github.com/brsuite/bronutil"
 	"github.com/brsuite/broln/lnrpc/routerrpc"
 	"github.com/brsuite/broln/lnwire"
+	"github.com/brsuite/broln/routing"
 	"github.com/brsuite/broln/routing/route"
 	"github.com/urfave/cli"
 )
@@ -46,33 +47,79 @@ var setCfgCommand = cli.Command{
 	Usage:    "Set mission control's config.",
 	Description: `
 	Update the config values being used by mission control to calculate 
-	the probability that payment routes will succeed.
+	the probability that payment routes will succeed. The estimator type
+	must be provided to set estimator-related parameters.
 	`,
 	Flags: []cli.Flag{
+		// General settings.
+		cli.UintFlag{
+			Name: "pmtnr",
+			Usage: "the number of payments mission control " +
+				"should store",
+		},
+		cli.DurationFlag{
+			Name: "failrelax",
+			Usage: "the amount of time to wait after a failure " +
+				"before raising failure amount",
+		},
+		// Probability estimator.
+		cli.StringFlag{
+			Name: "estimator",
+			Usage: "the probability estimator to use, choose " +
+				"between 'apriori' or 'bimodal'",
+		},
+		// Apriori config.
 		cli.DurationFlag{
-			Name: "halflife",
+			Name: "apriorihalflife",
 			Usage: "the amount of time taken to restore a node " +
 				"or channel to 50% probability of success.",
 		},
 		cli.Float64Flag{
-			Name: "hopprob",
+			Name: "apriorihopprob",
 			Usage: "the probability of success assigned " +
 				"to hops that we have no information about",
 		},
 		cli.Float64Flag{
-			Name: "weight",
+			Name: "aprioriweight",
 			Usage: "the degree to which mission control should " +
 				"rely on historical results, expressed as " +
-				"value in [0;1]",
-		}, cli.UintFlag{
-			Name: "pmtnr",
-			Usage: "the number of payments mission control " +
-				"should store",
+				"value in [0, 1]",
 		},
+		// Bimodal config.
 		cli.DurationFlag{
-			Name: "failrelax",
-			Usage: "the amount of time to wait after a failure " +
-				"before raising failure amount",
+			Name: "bimodaldecaytime",
+			Usage: "the time span after which we phase out " +
+				"learnings from previous payment attempts",
+		},
+		cli.Uint64Flag{
+			Name: "bimodalscale",
+			Usage: "controls the assumed channel liquidity " +
+				"imbalance in the network, measured in msat. " +
+				"a low value (compared to typical channel " +
+				"capacity) anticipates unbalanced channels.",
+		},
+		cli.Float64Flag{
+			Name: "bimodalweight",
+			Usage: "controls the degree to which the probability " +
+				"estimator takes into account other channels " +
+				"of a router",
+		},
+		// Deprecated apriori flags, replaced by the apriori prefixed
+		// flags above.
+		cli.DurationFlag{
+			Name:   "halflife",
+			Usage:  "deprecated, use apriorihalflife",
+			Hidden: true,
+		},
+		cli.Float64Flag{
+			Name:   "hopprob",
+			Usage:  "deprecated, use apriorihopprob",
+			Hidden: true,
+		},
+		cli.Float64Flag{
+			Name:   "weight",
+			Usage:  "deprecated, use aprioriweight",
+			Hidden: true,
 		},
 	},
 	Action: actionDecorator(setCfg),
@@ -91,36 +138,91 @@ func setCfg(ctx *cli.Context) error {
 	if err != nil {
 		return err
 	}
+	mcCfg := resp.Config
 
 	var haveValue bool
 
-	if ctx.IsSet("halflife") {
+	if ctx.IsSet("pmtnr") {
 		haveValue = true
-		resp.Config.HalfLifeSeconds = uint64(ctx.Duration(
-			"halflife",
-		).Seconds())
+		mcCfg.MaximumPaymentResults = uint32(ctx.Int("pmtnr"))
 	}
 
-	if ctx.IsSet("hopprob") {
+	if ctx.IsSet("failrelax") {
 		haveValue = true
-		resp.Config.HopProbability = float32(ctx.Float64("hopprob"))
+		mcCfg.MinimumFailureRelaxInterval = uint64(ctx.Duration(
+			"failrelax",
+		).Seconds())
 	}
 
-	if ctx.IsSet("weight") {
+	// Switch the estimator if requested. The parameters of the new
+	// estimator start out with their defaults.
+	if ctx.IsSet("estimator") {
 		haveValue = true
-		resp.Config.Weight = float32(ctx.Float64("weight"))
+
+		switch ctx.String("estimator") {
+		case routing.AprioriEstimatorName:
+			if mcCfg.GetApriori() == nil {
+				mcCfg.EstimatorConfig = defaultAprioriParams()
+			}
+			mcCfg.Model = routerrpc.MissionControlConfig_APRIORI
+
+		case routing.BimodalEstimatorName:
+			if mcCfg.GetBimodal() == nil {
+				mcCfg.EstimatorConfig = defaultBimodalParams()
+			}
+			mcCfg.Model = routerrpc.MissionControlConfig_BIMODAL
+
+		default:
+			return fmt.Errorf("unknown estimator %v",
+				ctx.String("estimator"))
+		}
 	}
 
-	if ctx.IsSet("pmtnr") {
-		haveValue = true
-		resp.Config.MaximumPaymentResults = uint32(ctx.Int("pmtnr"))
+	// Apply the parameters of the apriori estimator, also accepting the
+	// deprecated flags.
+	if apriori := mcCfg.GetApriori(); apriori != nil {
+		for _, name := range []string{"apriorihalflife", "halflife"} {
+			if ctx.IsSet(name) {
+				haveValue = true
+				apriori.HalfLifeSeconds = uint64(
+					ctx.Duration(name).Seconds(),
+				)
+			}
+		}
+
+		for _, name := range []string{"apriorihopprob", "hopprob"} {
+			if ctx.IsSet(name) {
+				haveValue = true
+				apriori.HopProbability = ctx.Float64(name)
+			}
+		}
+
+		for _, name := range []string{"aprioriweight", "weight"} {
+			if ctx.IsSet(name) {
+				haveValue = true
+				apriori.Weight = ctx.Float64(name)
+			}
+		}
 	}
 
-	if ctx.IsSet("failrelax") {
-		haveValue = true
-		resp.Config.MinimumFailureRelaxInterval = uint64(ctx.Duration(
-			"failrelax",
-		).Seconds())
+	// Apply the parameters of the bimodal estimator.
+	if bimodal := mcCfg.GetBimodal(); bimodal != nil {
+		if ctx.IsSet("bimodaldecaytime") {
+			haveValue = true
+			bimodal.DecayTime = uint64(
+				ctx.Duration("bimodaldecaytime").Seconds(),
+			)
+		}
+
+		if ctx.IsSet("bimodalscale") {
+			haveValue = true
+			bimodal.ScaleMsat = ctx.Uint64("bimodalscale")
+		}
+
+		if ctx.IsSet("bimodalweight") {
+			haveValue = true
+			bimodal.NodeWeight = ctx.Float64("bimodalweight")
+		}
 	}
 
 	if !haveValue {
@@ -129,12 +231,44 @@ func setCfg(ctx *cli.Context) error {
 
 	_, err = client.SetMissionControlConfig(
 		ctxc, &routerrpc.SetMissionControlConfigRequest{
-			Config: resp.Config,
+			Config: mcCfg,
 		},
 	)
 	return err
 }
 
+// defaultAprioriParams returns the default parameters of the apriori
+// estimator.
+func defaultAprioriParams() *routerrpc.MissionControlConfig_Apriori {
+	defaults := routing.DefaultAprioriConfig()
+
+	return &routerrpc.MissionControlConfig_Apriori{
+		Apriori: &routerrpc.AprioriParameters{
+			HalfLifeSeconds: uint64(
+				defaults.PenaltyHalfLife.Seconds(),
+			),
+			HopProbability: defaults.AprioriHopProbability,
+			Weight:         defaults.AprioriWeight,
+		},
+	}
+}
+
+// defaultBimodalParams returns the default parameters of the bimodal
+// estimator.
+func defaultBimodalParams() *routerrpc.MissionControlConfig_Bimodal {
+	defaults := routing.DefaultBimodalConfig()
+
+	return &routerrpc.MissionControlConfig_Bimodal{
+		Bimodal: &routerrpc.BimodalParameters{
+			NodeWeight: defaults.BimodalNodeWeight,
+			ScaleMsat:  uint64(defaults.BimodalScaleMsat),
+			DecayTime: uint64(
+				defaults.BimodalDecayTime.Seconds(),
+			),
+		},
+	}
+}
+
 var queryMissionControlCommand = cli.Command{
 	Name:     "querymc",
 	Category: "Mission Control",
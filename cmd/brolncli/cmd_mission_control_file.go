@@ -0,0 +1,222 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/brsuite/broln/lnrpc/routerrpc"
+	"github.com/brsuite/broln/lnwire"
+	"github.com/brsuite/broln/routing"
+	"github.com/brsuite/broln/routing/route"
+	"github.com/urfave/cli"
+)
+
+var exportMissionControlCommand = cli.Command{
+	Name:     "exportmc",
+	Category: "Mission Control",
+	Usage:    "Export the internal mission control state to a file.",
+	Description: `
+	Writes the full mission control state of the node to a versioned file
+	that can be merged into the state of another node with mergemc, or used
+	to seed mission control at startup with routerrpc.mcimportfile.`,
+	ArgsUsage: "file",
+	Action:    actionDecorator(exportMissionControl),
+}
+
+func exportMissionControl(ctx *cli.Context) error {
+	ctxc := getContext()
+
+	if ctx.NArg() != 1 {
+		return cli.ShowCommandHelp(ctx, "exportmc")
+	}
+
+	conn := getClientConn(ctx, false)
+	defer conn.Close()
+
+	client := routerrpc.NewRouterClient(conn)
+
+	req := &routerrpc.QueryMissionControlRequest{}
+	resp, err := client.QueryMissionControl(ctxc, req)
+	if err != nil {
+		return err
+	}
+
+	snapshot := &routing.MissionControlSnapshot{
+		Pairs: make(
+			[]routing.MissionControlPairSnapshot, 0, len(resp.Pairs),
+		),
+	}
+	for _, pair := range resp.Pairs {
+		pairSnapshot, err := fromRPCPairHistory(pair)
+		if err != nil {
+			return err
+		}
+		snapshot.Pairs = append(snapshot.Pairs, *pairSnapshot)
+	}
+
+	file, err := os.OpenFile(
+		ctx.Args().First(), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600,
+	)
+	if err != nil {
+		return err
+	}
+
+	err = routing.WriteMissionControlSnapshot(file, snapshot, time.Now())
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Exported %d pairs to %v\n", len(snapshot.Pairs),
+		ctx.Args().First())
+
+	return nil
+}
+
+var mergeMissionControlCommand = cli.Command{
+	Name:     "mergemc",
+	Category: "Mission Control",
+	Usage:    "Merge a mission control export into the internal state.",
+	Description: `
+	Reads a file written by exportmc and merges it into the mission control
+	state of the node. The merge strategy decides what happens if both the
+	node and the file have results for the same pair:
+
+	overwrite:             the results from the file replace the node's.
+	keep-newer:            the more recent failure and success are kept.
+	keep-stronger-failure: the failure with the lower amount is kept, which
+	                       is the more restrictive one, as well as the more
+	                       recent success.
+
+	Merged results are persisted and survive a restart.`,
+	ArgsUsage: "file",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name: "strategy",
+			Usage: "the merge strategy to use, one of " +
+				"overwrite, keep-newer or " +
+				"keep-stronger-failure",
+			Value: routing.MergeKeepNewer.String(),
+		},
+	},
+	Action: actionDecorator(mergeMissionControl),
+}
+
+func mergeMissionControl(ctx *cli.Context) error {
+	ctxc := getContext()
+
+	if ctx.NArg() != 1 {
+		return cli.ShowCommandHelp(ctx, "mergemc")
+	}
+
+	strategy, err := routing.ParseMergeStrategy(ctx.String("strategy"))
+	if err != nil {
+		return err
+	}
+
+	var rpcStrategy routerrpc.MergeStrategy
+	switch strategy {
+	case routing.MergeOverwrite:
+		rpcStrategy = routerrpc.MergeStrategy_OVERWRITE
+
+	case routing.MergeKeepNewer:
+		rpcStrategy = routerrpc.MergeStrategy_KEEP_NEWER
+
+	case routing.MergeKeepStrongerFailure:
+		rpcStrategy = routerrpc.MergeStrategy_KEEP_STRONGER_FAILURE
+	}
+
+	file, err := os.Open(ctx.Args().First())
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	snapshot, err := routing.ReadMissionControlSnapshot(file)
+	if err != nil {
+		return err
+	}
+
+	req := &routerrpc.XImportMissionControlRequest{
+		MergeStrategy: rpcStrategy,
+	}
+	for _, pair := range snapshot.Pairs {
+		req.Pairs = append(req.Pairs, toRPCPairHistory(pair))
+	}
+
+	conn := getClientConn(ctx, false)
+	defer conn.Close()
+
+	client := routerrpc.NewRouterClient(conn)
+
+	resp, err := client.XImportMissionControl(ctxc, req)
+	if err != nil {
+		return err
+	}
+
+	printRespJSON(resp)
+
+	return nil
+}
+
+// fromRPCPairHistory converts a pair as returned by QueryMissionControl to a
+// mission control pair snapshot.
+func fromRPCPairHistory(pair *routerrpc.PairHistory) (
+	*routing.MissionControlPairSnapshot, error) {
+
+	from, err := route.NewVertexFromBytes(pair.NodeFrom)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := route.NewVertexFromBytes(pair.NodeTo)
+	if err != nil {
+		return nil, err
+	}
+
+	result := routing.TimedPairResult{
+		FailAmt:    lnwire.MilliSatoshi(pair.History.FailAmtMsat),
+		SuccessAmt: lnwire.MilliSatoshi(pair.History.SuccessAmtMsat),
+	}
+	if pair.History.FailTime != 0 {
+		result.FailTime = time.Unix(pair.History.FailTime, 0)
+	}
+	if pair.History.SuccessTime != 0 {
+		result.SuccessTime = time.Unix(pair.History.SuccessTime, 0)
+	}
+
+	return &routing.MissionControlPairSnapshot{
+		Pair:            routing.NewDirectedNodePair(from, to),
+		TimedPairResult: result,
+	}, nil
+}
+
+// toRPCPairHistory converts a mission control pair snapshot to the form
+// expected by XImportMissionControl.
+func toRPCPairHistory(
+	pair routing.MissionControlPairSnapshot) *routerrpc.PairHistory {
+
+	history := &routerrpc.PairData{
+		FailAmtMsat:    int64(pair.FailAmt),
+		FailAmtSat:     int64(pair.FailAmt.ToSatoshis()),
+		SuccessAmtMsat: int64(pair.SuccessAmt),
+		SuccessAmtSat:  int64(pair.SuccessAmt.ToSatoshis()),
+	}
+	if !pair.FailTime.IsZero() {
+		history.FailTime = pair.FailTime.Unix()
+	}
+	if !pair.SuccessTime.IsZero() {
+		history.SuccessTime = pair.SuccessTime.Unix()
+	}
+
+	return &routerrpc.PairHistory{
+		NodeFrom: pair.Pair.From[:],
+		NodeTo:   pair.Pair.To[:],
+		History:  history,
+	}
+}
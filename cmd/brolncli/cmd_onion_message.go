@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/brsuite/broln/lnrpc"
+	"github.com/urfave/cli"
+)
+
+var sendOnionMessageCommand = cli.Command{
+	Name:     "sendonionmessage",
+	Category: "Peers",
+	Usage:    "Send an onion message to a node.",
+	Description: `
+	Send an onion message to a node, either directly or over a blinded path
+	the node created. The message carries the given records, each of which
+	is specified as type=hexvalue, with a type of at least 64.
+
+	If --request_reply is set, the message includes a blinded path back to
+	us, and the path ID replies arrive with is printed.`,
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "peer",
+			Usage: "the public key of the node to send the message to",
+		},
+		cli.StringFlag{
+			Name: "blinded_path",
+			Usage: "the hex encoded blinded path to send the " +
+				"message over, instead of to --peer directly",
+		},
+		cli.StringSliceFlag{
+			Name:  "record",
+			Usage: "a record to include, as type=hexvalue",
+		},
+		cli.BoolFlag{
+			Name:  "request_reply",
+			Usage: "include a blinded path the recipient can reply over",
+		},
+	},
+	Action: actionDecorator(sendOnionMessage),
+}
+
+func sendOnionMessage(ctx *cli.Context) error {
+	ctxc := getContext()
+	client, cleanUp := getClient(ctx)
+	defer cleanUp()
+
+	peer, err := hex.DecodeString(ctx.String("peer"))
+	if err != nil {
+		return err
+	}
+
+	blindedPath, err := hex.DecodeString(ctx.String("blinded_path"))
+	if err != nil {
+		return err
+	}
+
+	records := make(map[uint64][]byte)
+	for _, record := range ctx.StringSlice("record") {
+		parts := strings.SplitN(record, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid record %v, expected "+
+				"type=hexvalue", record)
+		}
+
+		recordType, err := strconv.ParseUint(parts[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid record type: %v", err)
+		}
+
+		value, err := hex.DecodeString(parts[1])
+		if err != nil {
+			return fmt.Errorf("invalid record value: %v", err)
+		}
+
+		records[recordType] = value
+	}
+
+	resp, err := client.SendOnionMessage(
+		ctxc,
+		&lnrpc.SendOnionMessageRequest{
+			Peer:          peer,
+			BlindedPath:   blindedPath,
+			CustomRecords: records,
+			RequestReply:  ctx.Bool("request_reply"),
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	printRespJSON(resp)
+
+	return nil
+}
+
+var subscribeOnionMessagesCommand = cli.Command{
+	Name:     "subscribeonionmessages",
+	Category: "Peers",
+	Usage:    "Print the onion messages we receive.",
+	Action:   actionDecorator(subscribeOnionMessages),
+}
+
+func subscribeOnionMessages(ctx *cli.Context) error {
+	ctxc := getContext()
+	client, cleanUp := getClient(ctx)
+	defer cleanUp()
+
+	stream, err := client.SubscribeOnionMessages(
+		ctxc,
+		&lnrpc.SubscribeOnionMessagesRequest{},
+	)
+	if err != nil {
+		return err
+	}
+
+	for {
+		msg, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+
+		printRespJSON(msg)
+	}
+}
@@ -210,6 +210,38 @@ var openChannelCommand = cli.Command{
 				"propose to the remote peer (%q, %q)",
 				channelTypeTweakless, channelTypeAnchors),
 		},
+		cli.BoolFlag{
+			Name: "zero_conf",
+			Usage: "(optional) whether a zero-conf channel open " +
+				"should be attempted, which is usable before " +
+				"the funding transaction confirms",
+		},
+		cli.BoolFlag{
+			Name: "scid_alias",
+			Usage: "(optional) whether an option_scid_alias " +
+				"channel type should be attempted, which " +
+				"hides the confirmed short channel ID from " +
+				"the remote peer. Only valid for private " +
+				"channels",
+		},
+		cli.BoolFlag{
+			Name: "dual_fund",
+			Usage: "(optional) whether a dual funded channel " +
+				"should be opened, allowing the remote peer " +
+				"to contribute funds to the channel",
+		},
+		cli.Int64Flag{
+			Name: "request_remote_amt",
+			Usage: "(optional) the amount in satoshis to lease " +
+				"from the remote peer, which it contributes " +
+				"to the channel. Requires --dual_fund",
+		},
+		cli.Int64Flag{
+			Name: "max_lease_fee",
+			Usage: "(optional) the maximum fee in satoshis to pay " +
+				"for the amount requested with " +
+				"--request_remote_amt",
+		},
 	},
 	Action: actionDecorator(openChannel),
 }
@@ -316,6 +348,11 @@ func openChannel(ctx *cli.Context) error {
 	}
 
 	req.Private = ctx.Bool("private")
+	req.ZeroConf = ctx.Bool("zero_conf")
+	req.ScidAlias = ctx.Bool("scid_alias")
+	req.DualFund = ctx.Bool("dual_fund")
+	req.RequestRemoteAmt = ctx.Int64("request_remote_amt")
+	req.MaxLeaseFeeSat = ctx.Int64("max_lease_fee")
 
 	// Parse the channel type and map it to its RPC representation.
 	channelType := ctx.String("channel_type")
@@ -115,6 +115,18 @@ func paymentFlags() []cli.Flag {
 				"the maximum fee allowed when sending the " +
 				"payment",
 		},
+		cli.Uint64Flag{
+			Name: "fee_limit_ppm",
+			Usage: "maximum fee allowed when sending the payment, " +
+				"in parts per million of the payment's " +
+				"amount; if set together with fee_limit or " +
+				"fee_limit_percent, the larger limit applies",
+		},
+		cli.Uint64Flag{
+			Name: "max_hop_fee_msat",
+			Usage: "maximum fee in milli-satoshis that a single " +
+				"hop of the route may charge",
+		},
 		cli.DurationFlag{
 			Name: "timeout",
 			Usage: "the maximum amount of time we should spend " +
@@ -130,6 +142,41 @@ func paymentFlags() []cli.Flag {
 				"use for the first hop of the payment",
 			Value: 0,
 		},
+		cli.StringSliceFlag{
+			Name: "first_hop",
+			Usage: "pubkey of a peer that is allowed as the " +
+				"first hop of the payment, can be repeated",
+		},
+		cli.StringSliceFlag{
+			Name: "exclude_node",
+			Usage: "pubkey of a node that may not be used in the " +
+				"route of the payment, can be repeated",
+		},
+		cli.StringSliceFlag{
+			Name: "exclude_country",
+			Usage: "ISO 3166-1 alpha-2 code of a country whose " +
+				"nodes may not be used in the route of the " +
+				"payment, can be repeated",
+		},
+		cli.StringSliceFlag{
+			Name: "exclude_asn",
+			Usage: "number of an autonomous system whose nodes " +
+				"may not be used in the route of the " +
+				"payment, can be repeated",
+		},
+		cli.BoolFlag{
+			Name: "exclude_unlocated",
+			Usage: "if set, nodes that don't advertise any " +
+				"address of a known location, such as " +
+				"private nodes, may not be used in the " +
+				"route when excluding countries or " +
+				"autonomous systems",
+		},
+		cli.UintFlag{
+			Name: "max_hops",
+			Usage: "the maximum number of hops of the route of " +
+				"the payment",
+		},
 		cli.BoolFlag{
 			Name:  "force, f",
 			Usage: "will skip payment request confirmation",
@@ -216,6 +263,10 @@ func retrieveFeeLimit(ctx *cli.Context, amt int64) (int64, error) {
 			(amt*ctx.Int64("fee_limit_percent") + 99) / 100
 
 		return feeLimitRoundedUp, nil
+
+	// A proportional fee limit on its own replaces the default limit.
+	case ctx.IsSet("fee_limit_ppm"):
+		return 0, nil
 	}
 
 	// If no fee limit is set, use a default value based on the amount.
@@ -224,11 +275,16 @@ func retrieveFeeLimit(ctx *cli.Context, amt int64) (int64, error) {
 	return int64(limitMsat.ToSatoshis()), nil
 }
 
-func confirmPayReq(resp *lnrpc.PayReq, amt, feeLimit int64) error {
+func confirmPayReq(resp *lnrpc.PayReq, amt, feeLimit int64,
+	feeLimitPPM uint64) error {
+
 	fmt.Printf("Payment hash: %v\n", resp.GetPaymentHash())
 	fmt.Printf("Description: %v\n", resp.GetDescription())
 	fmt.Printf("Amount (in satoshis): %v\n", amt)
 	fmt.Printf("Fee limit (in satoshis): %v\n", feeLimit)
+	if feeLimitPPM != 0 {
+		fmt.Printf("Fee limit (in ppm): %v\n", feeLimitPPM)
+	}
 	fmt.Printf("Destination: %v\n", resp.GetDestination())
 
 	confirm := promptForConfirmation("Confirm payment (yes/no): ")
@@ -427,6 +483,38 @@ func sendPaymentRequest(ctx *cli.Context,
 		req.LastHopPubkey = lastHop[:]
 	}
 
+	for _, firstHop := range ctx.StringSlice("first_hop") {
+		vertex, err := route.NewVertexFromStr(firstHop)
+		if err != nil {
+			return fmt.Errorf("invalid first_hop %v: %v", firstHop,
+				err)
+		}
+		req.FirstHopPubkeys = append(req.FirstHopPubkeys, vertex[:])
+	}
+
+	for _, excluded := range ctx.StringSlice("exclude_node") {
+		vertex, err := route.NewVertexFromStr(excluded)
+		if err != nil {
+			return fmt.Errorf("invalid exclude_node %v: %v",
+				excluded, err)
+		}
+		req.ExcludedNodes = append(req.ExcludedNodes, vertex[:])
+	}
+
+	req.ExcludedCountries = ctx.StringSlice("exclude_country")
+
+	for _, asn := range ctx.StringSlice("exclude_asn") {
+		asnNum, err := strconv.ParseUint(asn, 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid exclude_asn %v: %v", asn,
+				err)
+		}
+		req.ExcludedAsns = append(req.ExcludedAsns, uint32(asnNum))
+	}
+	req.ExcludeUnlocatedNodes = ctx.Bool("exclude_unlocated")
+
+	req.MaxHops = uint32(ctx.Uint("max_hops"))
+
 	req.CltvLimit = int32(ctx.Int(cltvLimitFlag.Name))
 
 	pmtTimeout := ctx.Duration("timeout")
@@ -509,7 +597,10 @@ func sendPaymentRequest(ctx *cli.Context,
 		// Ask for confirmation of amount and fee limit if payment is
 		// forced.
 		if !ctx.Bool("force") {
-			err := confirmPayReq(decodeResp, amt, feeLimit)
+			err := confirmPayReq(
+				decodeResp, amt, feeLimit,
+				ctx.Uint64("fee_limit_ppm"),
+			)
 			if err != nil {
 				return err
 			}
@@ -523,6 +614,8 @@ func sendPaymentRequest(ctx *cli.Context,
 	}
 
 	req.FeeLimitSat = feeLimit
+	req.FeeLimitPpm = ctx.Uint64("fee_limit_ppm")
+	req.MaxHopFeeMsat = ctx.Uint64("max_hop_fee_msat")
 
 	// Always print in-flight updates for the table output.
 	printJSON := ctx.Bool(jsonFlag.Name)
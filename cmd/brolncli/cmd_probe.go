@@ -0,0 +1,241 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/brsuite/bronutil"
+	"github.com/brsuite/broln/lnrpc/routerrpc"
+	"github.com/brsuite/broln/lnwire"
+	"github.com/brsuite/broln/routing/route"
+	"github.com/urfave/cli"
+)
+
+// probeFlags are the flags that describe a set of probes.
+var probeFlags = []cli.Flag{
+	cli.StringSliceFlag{
+		Name:  "dest",
+		Usage: "the public key of a node to probe, can be repeated",
+	},
+	cli.StringSliceFlag{
+		Name: "amt",
+		Usage: "an amount in satoshis to probe every destination " +
+			"with, can be repeated",
+	},
+	cli.Int64Flag{
+		Name: "fee_limit",
+		Usage: "maximum fee in satoshis a probe route may have " +
+			"(default: 100)",
+		Value: 100,
+	},
+	cli.UintFlag{
+		Name: "cltv_limit",
+		Usage: "the maximum time lock that may be used for probe " +
+			"routes",
+	},
+	cli.Int64Flag{
+		Name: "final_cltv_delta",
+		Usage: "the number of blocks the final hop of probes may " +
+			"use",
+	},
+	cli.IntFlag{
+		Name: "timeout",
+		Usage: "the number of seconds after which a single probe is " +
+			"abandoned (default: 60)",
+	},
+}
+
+var probeCommand = cli.Command{
+	Name:     "probe",
+	Category: "Payments",
+	Usage:    "Estimate the amount that can be sent to destinations.",
+	Description: `
+	Probes each destination with the given amounts in ascending order,
+	using payments with a random payment hash that the destination can't
+	settle. A probe that is failed by the destination has reached it. For
+	every destination the largest amount that reached it is returned as an
+	estimate for the amount that can currently be sent to it in a single
+	part.
+
+	The results of all probes feed into mission control.
+
+	Example:
+	    brolncli probe --dest 02abc... --dest 03def... \
+	        --amt 10000 --amt 100000 --amt 1000000`,
+	Flags:  probeFlags,
+	Action: actionDecorator(probe),
+}
+
+func probe(ctx *cli.Context) error {
+	ctxc := getContext()
+
+	req, err := parseProbeRequest(ctx)
+	if err != nil {
+		return err
+	}
+
+	conn := getClientConn(ctx, false)
+	defer conn.Close()
+
+	client := routerrpc.NewRouterClient(conn)
+
+	resp, err := client.ProbeDestinations(ctxc, req)
+	if err != nil {
+		return err
+	}
+
+	printRespJSON(resp)
+
+	return nil
+}
+
+var addProbeScheduleCommand = cli.Command{
+	Name:     "addprobeschedule",
+	Category: "Payments",
+	Usage:    "Repeat probes to destinations at a fixed interval.",
+	Description: `
+	Adds a schedule that repeats the same probes as the probe command in
+	the background. The results of the last run of every schedule can be
+	retrieved with listprobeschedules. Schedules aren't persisted.`,
+	Flags: append([]cli.Flag{
+		cli.UintFlag{
+			Name: "interval",
+			Usage: "the number of seconds between two runs, at " +
+				"least 60",
+			Value: 3600,
+		},
+	}, probeFlags...),
+	Action: actionDecorator(addProbeSchedule),
+}
+
+func addProbeSchedule(ctx *cli.Context) error {
+	ctxc := getContext()
+
+	probes, err := parseProbeRequest(ctx)
+	if err != nil {
+		return err
+	}
+
+	conn := getClientConn(ctx, false)
+	defer conn.Close()
+
+	client := routerrpc.NewRouterClient(conn)
+
+	resp, err := client.AddProbeSchedule(
+		ctxc, &routerrpc.AddProbeScheduleRequest{
+			Probes:          probes,
+			IntervalSeconds: uint32(ctx.Uint("interval")),
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	printRespJSON(resp)
+
+	return nil
+}
+
+var listProbeSchedulesCommand = cli.Command{
+	Name:     "listprobeschedules",
+	Category: "Payments",
+	Usage:    "List probe schedules and the results of their last run.",
+	Action:   actionDecorator(listProbeSchedules),
+}
+
+func listProbeSchedules(ctx *cli.Context) error {
+	ctxc := getContext()
+	conn := getClientConn(ctx, false)
+	defer conn.Close()
+
+	client := routerrpc.NewRouterClient(conn)
+
+	resp, err := client.ListProbeSchedules(
+		ctxc, &routerrpc.ListProbeSchedulesRequest{},
+	)
+	if err != nil {
+		return err
+	}
+
+	printRespJSON(resp)
+
+	return nil
+}
+
+var removeProbeScheduleCommand = cli.Command{
+	Name:      "removeprobeschedule",
+	Category:  "Payments",
+	Usage:     "Remove a probe schedule.",
+	ArgsUsage: "schedule_id",
+	Action:    actionDecorator(removeProbeSchedule),
+}
+
+func removeProbeSchedule(ctx *cli.Context) error {
+	ctxc := getContext()
+
+	if ctx.NArg() != 1 {
+		return cli.ShowCommandHelp(ctx, "removeprobeschedule")
+	}
+
+	id, err := strconv.ParseUint(ctx.Args().First(), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid schedule id: %v", err)
+	}
+
+	conn := getClientConn(ctx, false)
+	defer conn.Close()
+
+	client := routerrpc.NewRouterClient(conn)
+
+	_, err = client.RemoveProbeSchedule(
+		ctxc, &routerrpc.RemoveProbeScheduleRequest{
+			ScheduleId: id,
+		},
+	)
+	return err
+}
+
+// parseProbeRequest parses the probe flags into a probe request.
+func parseProbeRequest(ctx *cli.Context) (*routerrpc.ProbeDestinationsRequest,
+	error) {
+
+	dests := ctx.StringSlice("dest")
+	if len(dests) == 0 {
+		return nil, fmt.Errorf("at least one --dest required")
+	}
+
+	amts := ctx.StringSlice("amt")
+	if len(amts) == 0 {
+		return nil, fmt.Errorf("at least one --amt required")
+	}
+
+	req := &routerrpc.ProbeDestinationsRequest{
+		FeeLimitMsat: int64(lnwire.NewMSatFromSatoshis(
+			bronutil.Amount(ctx.Int64("fee_limit")),
+		)),
+		CltvLimit:      int32(ctx.Uint("cltv_limit")),
+		FinalCltvDelta: int32(ctx.Int64("final_cltv_delta")),
+		TimeoutSeconds: int32(ctx.Int("timeout")),
+	}
+
+	for _, dest := range dests {
+		vertex, err := route.NewVertexFromStr(dest)
+		if err != nil {
+			return nil, fmt.Errorf("invalid dest %v: %v", dest, err)
+		}
+
+		req.Destinations = append(req.Destinations, vertex[:])
+	}
+
+	for _, amt := range amts {
+		amtSat, err := strconv.ParseUint(amt, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid amt %v: %v", amt, err)
+		}
+
+		amtMsat := lnwire.NewMSatFromSatoshis(bronutil.Amount(amtSat))
+		req.AmtsMsat = append(req.AmtsMsat, uint64(amtMsat))
+	}
+
+	return req, nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/brsuite/brond/chaincfg/chainhash"
+	"github.com/brsuite/broln/lnrpc"
+	"github.com/urfave/cli"
+)
+
+var spliceChannelCommand = cli.Command{
+	Name:     "splicechannel",
+	Category: "Channels",
+	Usage:    "Add funds to or remove funds from an existing channel.",
+	Description: `
+	Splice funds from the internal wallet into an existing channel, and/or
+	splice funds out of the channel to an on-chain address, without closing
+	the channel. Both parties must have splicing enabled. Only private
+	channels that aren't zero-conf can be spliced.
+
+	The channel is frozen until the splice transaction has confirmed and
+	has been locked in by both parties: no payments can be sent, received
+	or forwarded over it and no fee updates are sent in the meantime. An
+	unconfirmed splice can be replaced by running the command again with a
+	higher fee rate.
+
+	To view which funding_txids/output_indexes can be used for this command,
+	see the channel_point values within the listchannels command output.
+	The format for a channel_point is 'funding_txid:output_index'.`,
+	ArgsUsage: "funding_txid [output_index]",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "funding_txid",
+			Usage: "the txid of the channel's funding transaction",
+		},
+		cli.IntFlag{
+			Name: "output_index",
+			Usage: "the output index for the funding output of the funding " +
+				"transaction",
+		},
+		cli.Int64Flag{
+			Name: "splice_in",
+			Usage: "the number of satoshis to add to the channel from " +
+				"the internal wallet",
+		},
+		cli.Int64Flag{
+			Name:  "splice_out",
+			Usage: "the number of satoshis to remove from the channel",
+		},
+		cli.StringFlag{
+			Name:  "splice_out_addr",
+			Usage: "the address the removed funds are sent to",
+		},
+		cli.Int64Flag{
+			Name: "conf_target",
+			Usage: "(optional) the number of blocks that the " +
+				"transaction *should* confirm in, will be " +
+				"used for fee estimation",
+		},
+		cli.Uint64Flag{
+			Name: "sat_per_vbyte",
+			Usage: "(optional) a manual fee expressed in " +
+				"sat/vbyte that should be used when crafting " +
+				"the transaction",
+		},
+	},
+	Action: actionDecorator(spliceChannel),
+}
+
+func spliceChannel(ctx *cli.Context) error {
+	ctxc := getContext()
+	client, cleanUp := getClient(ctx)
+	defer cleanUp()
+
+	// Show command help if no arguments and flags were provided.
+	if ctx.NArg() == 0 && ctx.NumFlags() == 0 {
+		cli.ShowCommandHelp(ctx, "splicechannel")
+		return nil
+	}
+
+	// Check that only the field sat_per_vbyte or conf_target was set.
+	if ctx.IsSet("conf_target") && ctx.IsSet("sat_per_vbyte") {
+		return fmt.Errorf("either conf_target or sat_per_vbyte should " +
+			"be set, but not both")
+	}
+
+	channelPoint, err := parseChannelPoint(ctx)
+	if err != nil {
+		return err
+	}
+
+	req := &lnrpc.SpliceChannelRequest{
+		ChannelPoint:     channelPoint,
+		SpliceInSat:      ctx.Int64("splice_in"),
+		SpliceOutSat:     ctx.Int64("splice_out"),
+		SpliceOutAddress: ctx.String("splice_out_addr"),
+		TargetConf:       int32(ctx.Int64("conf_target")),
+		SatPerVbyte:      ctx.Uint64("sat_per_vbyte"),
+	}
+
+	resp, err := client.SpliceChannel(ctxc, req)
+	if err != nil {
+		return err
+	}
+
+	txid, err := chainhash.NewHash(resp.SpliceTxid)
+	if err != nil {
+		return err
+	}
+
+	printJSON(struct {
+		SpliceTxid string `json:"splice_txid"`
+	}{
+		SpliceTxid: txid.String(),
+	})
+	return nil
+}
@@ -698,7 +698,9 @@ var closeChannelCommand = cli.Command{
 	In the case of a cooperative closure, one can manually set the fee to
 	be used for the closing transaction via either the --conf_target or
 	--sat_per_vbyte arguments. This will be the starting value used during
-	fee negotiation. This is optional.
+	fee negotiation. This is optional. The fee rates accepted during the
+	negotiation can be bounded via the --min_sat_per_vbyte and
+	--max_sat_per_vbyte arguments, which are optional as well.
 
 	In the case of a cooperative closure, one can manually set the address
 	to deliver funds to upon closure. This is optional, and may only be used
@@ -746,6 +748,18 @@ var closeChannelCommand = cli.Command{
 				"sat/vbyte that should be used when crafting " +
 				"the transaction",
 		},
+		cli.Uint64Flag{
+			Name: "min_sat_per_vbyte",
+			Usage: "(optional) the minimum fee expressed in " +
+				"sat/vbyte that is accepted when negotiating " +
+				"the fee of the closing transaction",
+		},
+		cli.Uint64Flag{
+			Name: "max_sat_per_vbyte",
+			Usage: "(optional) the maximum fee expressed in " +
+				"sat/vbyte that is accepted when negotiating " +
+				"the fee of the closing transaction",
+		},
 		cli.StringFlag{
 			Name: "delivery_addr",
 			Usage: "(optional) an address to deliver funds " +
@@ -788,6 +802,8 @@ func closeChannel(ctx *cli.Context) error {
 		Force:           ctx.Bool("force"),
 		TargetConf:      int32(ctx.Int64("conf_target")),
 		SatPerVbyte:     ctx.Uint64(feeRateFlag),
+		MinSatPerVbyte:  ctx.Uint64("min_sat_per_vbyte"),
+		MaxSatPerVbyte:  ctx.Uint64("max_sat_per_vbyte"),
 		DeliveryAddress: ctx.String("delivery_addr"),
 	}
 
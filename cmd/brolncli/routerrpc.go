@@ -9,9 +9,15 @@ func routerCommands() []cli.Command {
 		importMissionControlCommand,
 		queryProbCommand,
 		resetMissionControlCommand,
+		exportMissionControlCommand,
+		mergeMissionControlCommand,
 		buildRouteCommand,
 		getCfgCommand,
 		setCfgCommand,
 		updateChanStatusCommand,
+		probeCommand,
+		addProbeScheduleCommand,
+		listProbeSchedulesCommand,
+		removeProbeScheduleCommand,
 	}
 }
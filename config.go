@@ -23,6 +23,7 @@ import (
 	"github.com/brsuite/bronutil"
 	flags "github.com/jessevdk/go-flags"
 	"github.com/brsuite/neutrino"
+	"github.com/brsuite/broln/auditlog"
 	"github.com/brsuite/broln/autopilot"
 	"github.com/brsuite/broln/build"
 	"github.com/brsuite/broln/chainreg"
@@ -350,6 +351,10 @@ type Config struct {
 
 	RejectHTLC bool `long:"rejecthtlc" description:"If true, broln will not forward any HTLCs that are meant as onward payments. This option will still allow broln to send HTLCs and receive HTLCs but broln won't be used as a hop."`
 
+	RequireInterceptor bool `long:"requireinterceptor" description:"If true, forwarded HTLCs are always intercepted, even if no HTLC interceptor is connected. HTLCs are held until an interceptor connects, including across restarts, or until they are failed back once they get close to expiry."`
+
+	InterceptorCltvDelta uint32 `long:"interceptorcltvdelta" description:"The number of blocks before the expiry of an intercepted HTLC at which it is failed back, to prevent the incoming channel from being force closed. Set to zero to never fail intercepted HTLCs automatically."`
+
 	StaggerInitialReconnect bool `long:"stagger-initial-reconnect" description:"If true, will apply a randomized staggering between 0s and 30s when reconnecting to persistent peers on startup. The first 10 reconnections will be attempted instantly, regardless of the flag's value"`
 
 	MaxOutgoingCltvExpiry uint32 `long:"max-cltv-expiry" description:"The maximum number of blocks funds could be locked up for when forwarding payments."`
@@ -394,6 +399,8 @@ type Config struct {
 
 	ProtocolOptions *lncfg.ProtocolOptions `group:"protocol" namespace:"protocol"`
 
+	LiquidityAds *lncfg.LiquidityAds `group:"liquidityads" namespace:"liquidityads"`
+
 	AllowCircularRoute bool `long:"allow-circular-route" description:"If true, our node will allow htlc forwards that arrive and depart on the same channel."`
 
 	HealthChecks *lncfg.HealthCheckConfig `group:"healthcheck" namespace:"healthcheck"`
@@ -404,6 +411,10 @@ type Config struct {
 
 	RPCMiddleware *lncfg.RPCMiddleware `group:"rpcmiddleware" namespace:"rpcmiddleware"`
 
+	RateLimit *lncfg.RateLimit `group:"ratelimit" namespace:"ratelimit"`
+
+	AuditLog *lncfg.AuditLog `group:"auditlog" namespace:"auditlog"`
+
 	RemoteSigner *lncfg.RemoteSigner `group:"remotesigner" namespace:"remotesigner"`
 
 	// LogWriter is the root logger that all of the daemon's subloggers are
@@ -582,6 +593,8 @@ func DefaultConfig() Config {
 		Invoices: &lncfg.Invoices{
 			HoldExpiryDelta: lncfg.DefaultHoldInvoiceExpiryDelta,
 		},
+		LiquidityAds:            lncfg.DefaultLiquidityAds(),
+		InterceptorCltvDelta:    lncfg.DefaultFinalCltvRejectDelta,
 		MaxOutgoingCltvExpiry:   htlcswitch.DefaultMaxOutgoingCltvExpiry,
 		MaxChannelFeeAllocation: htlcswitch.DefaultMaxLinkFeeAllocation,
 		MaxCommitFeeRateAnchors: lnwallet.DefaultAnchorsCommitMaxFeeRateSatPerVByte,
@@ -590,6 +603,8 @@ func DefaultConfig() Config {
 		DB:                      lncfg.DefaultDB(),
 		Cluster:                 lncfg.DefaultCluster(),
 		RPCMiddleware:           lncfg.DefaultRPCMiddleware(),
+		RateLimit:               lncfg.DefaultRateLimit(),
+		AuditLog:                lncfg.DefaultAuditLog(),
 		registeredChains:        chainreg.NewChainRegistry(),
 		ActiveNetParams:         chainreg.BrocoinTestNetParams,
 		ChannelCommitInterval:   defaultChannelCommitInterval,
@@ -823,6 +838,14 @@ func ValidateConfig(cfg Config, interceptor signal.Interceptor, fileParser,
 	cfg.Tor.WatchtowerKeyPath = CleanAndExpandPath(cfg.Tor.WatchtowerKeyPath)
 	cfg.Watchtower.TowerDir = CleanAndExpandPath(cfg.Watchtower.TowerDir)
 	cfg.BackupFilePath = CleanAndExpandPath(cfg.BackupFilePath)
+	cfg.AuditLog.File = CleanAndExpandPath(cfg.AuditLog.File)
+	cfg.AuditLog.KeyFile = CleanAndExpandPath(cfg.AuditLog.KeyFile)
+	cfg.SubRPCServers.RouterRPC.McImportFile = CleanAndExpandPath(
+		cfg.SubRPCServers.RouterRPC.McImportFile,
+	)
+	cfg.SubRPCServers.RouterRPC.NodeLocationFile = CleanAndExpandPath(
+		cfg.SubRPCServers.RouterRPC.NodeLocationFile,
+	)
 	cfg.WalletUnlockPasswordFile = CleanAndExpandPath(
 		cfg.WalletUnlockPasswordFile,
 	)
@@ -901,6 +924,20 @@ func ValidateConfig(cfg Config, interceptor signal.Interceptor, fileParser,
 		)
 	}
 
+	// Zero-conf channels are identified by an alias until the funding
+	// transaction confirms, so they can't be enabled without scid-alias.
+	if cfg.ProtocolOptions.ZeroConf() && !cfg.ProtocolOptions.ScidAlias() {
+		return nil, mkErr("zero-conf feature bit is set but the " +
+			"option-scid-alias feature bit is not")
+	}
+
+	// Splice transactions are constructed with the interactive
+	// transaction protocol used by dual funded channels.
+	if cfg.ProtocolOptions.Splicing() && !cfg.ProtocolOptions.DualFunding() {
+		return nil, mkErr("splicing is enabled but dual-funding " +
+			"is not")
+	}
+
 	// Ensure a valid max channel fee allocation was set.
 	if cfg.MaxChannelFeeAllocation <= 0 || cfg.MaxChannelFeeAllocation > 1 {
 		return nil, mkErr("invalid max channel fee allocation: %v, "+
@@ -1356,6 +1393,19 @@ func ValidateConfig(cfg Config, interceptor signal.Interceptor, fileParser,
 		)
 	}
 
+	// The audit log and its key are stored in the network directory as
+	// well, unless custom paths were specified.
+	if cfg.AuditLog.File == "" {
+		cfg.AuditLog.File = filepath.Join(
+			cfg.networkDir, auditlog.DefaultFileName,
+		)
+	}
+	if cfg.AuditLog.KeyFile == "" {
+		cfg.AuditLog.KeyFile = filepath.Join(
+			cfg.networkDir, auditlog.DefaultKeyFileName,
+		)
+	}
+
 	// Append the network type to the log directory so it is "namespaced"
 	// per network in the same fashion as the data directory.
 	cfg.LogDir = filepath.Join(
@@ -1555,6 +1605,17 @@ func ValidateConfig(cfg Config, interceptor signal.Interceptor, fileParser,
 		return nil, mkErr("error parsing gossip syncer: %v", err)
 	}
 
+	// Log a warning if the expiry delta of intercepted HTLCs is not greater
+	// than our incoming broadcast delta, as held HTLCs may then force close
+	// channels.
+	if cfg.InterceptorCltvDelta <= lncfg.DefaultIncomingBroadcastDelta {
+		ltndLog.Warnf("Interceptor cltv delta: %v <= incoming delta: "+
+			"%v, intercepted HTLCs will force close channels if "+
+			"they are not resolved in time",
+			cfg.InterceptorCltvDelta,
+			lncfg.DefaultIncomingBroadcastDelta)
+	}
+
 	// Log a warning if our expiry delta is not greater than our incoming
 	// broadcast delta. We do not fail here because this value may be set
 	// to zero to intentionally keep broln's behavior unchanged from when we
@@ -1576,12 +1637,20 @@ func ValidateConfig(cfg Config, interceptor signal.Interceptor, fileParser,
 		cfg.Cluster,
 		cfg.HealthChecks,
 		cfg.RPCMiddleware,
+		cfg.RateLimit,
 		cfg.RemoteSigner,
+		cfg.LiquidityAds,
 	)
 	if err != nil {
 		return nil, err
 	}
 
+	// Leasing our liquidity requires dual funded channels.
+	if cfg.LiquidityAds.Enable && !cfg.ProtocolOptions.DualFunding() {
+		return nil, mkErr("liquidityads.enable requires " +
+			"protocol.dual-funding")
+	}
+
 	// Finally, ensure that the user's color is correctly formatted,
 	// otherwise the server will not be able to start after the unlocking
 	// the wallet.
@@ -22,9 +22,12 @@ import (
 	proxy "github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	"github.com/brsuite/neutrino"
 	"github.com/brsuite/neutrino/headerfs"
+	"github.com/brsuite/broln/accounts"
 	"github.com/brsuite/broln/blockcache"
 	"github.com/brsuite/broln/chainreg"
 	"github.com/brsuite/broln/channeldb"
+	"github.com/brsuite/broln/clock"
+	"github.com/brsuite/broln/invoices"
 	"github.com/brsuite/broln/keychain"
 	"github.com/brsuite/broln/kvdb"
 	"github.com/brsuite/broln/lncfg"
@@ -395,10 +398,17 @@ func (d *DefaultWalletImpl) BuildWalletConfig(ctx context.Context,
 	var macaroonService *macaroons.Service
 	if !d.cfg.NoMacaroons {
 		// Create the macaroon authentication/authorization service.
+		checkers := append(
+			[]macaroons.Checker{
+				macaroons.IPLockChecker,
+				macaroons.CustomChecker(interceptorChain),
+				accounts.AccountChecker,
+			},
+			macaroons.PaymentCheckers()...,
+		)
 		macaroonService, err = macaroons.NewService(
 			dbs.MacaroonDB, "broln", walletInitParams.StatelessInit,
-			macaroons.IPLockChecker,
-			macaroons.CustomChecker(interceptorChain),
+			checkers...,
 		)
 		if err != nil {
 			err := fmt.Errorf("unable to set up macaroon "+
@@ -747,6 +757,11 @@ type DatabaseInstances struct {
 	// complete!
 	ChanStateDB *channeldb.DB
 
+	// InvoiceDB is the database that stores all of our node's invoices.
+	// This is either the ChanStateDB above or the native SQL invoice store
+	// if native SQL tables are enabled.
+	InvoiceDB invoices.InvoiceDB
+
 	// HeightHintDB is the database that stores height hints for spends.
 	HeightHintDB kvdb.Backend
 
@@ -899,6 +914,57 @@ func (d *DefaultDatabaseBuilder) BuildDatabase(
 	// using the same struct (and DB backend) instance.
 	dbs.ChanStateDB = dbs.GraphDB
 
+	// Invoices are kept in the channel state DB unless native SQL tables
+	// are enabled, in which case we'll first copy over any invoices that
+	// are still stored in the kv buckets.
+	dbs.InvoiceDB = dbs.ChanStateDB
+	if databaseBackends.NativeSQLDB != nil {
+		invoiceDB, err := channeldb.NewSQLInvoiceDB(
+			databaseBackends.NativeSQLDB, cfg.DB.Backend,
+			clock.NewDefaultClock(),
+		)
+		if err != nil {
+			cleanUp()
+
+			err := fmt.Errorf("unable to open invoice DB: %v", err)
+			d.logger.Error(err)
+			return nil, nil, err
+		}
+
+		err = channeldb.MigrateInvoicesToSQL(dbs.ChanStateDB, invoiceDB)
+		if err != nil {
+			cleanUp()
+
+			err := fmt.Errorf("unable to migrate invoices to native "+
+				"SQL: %v", err)
+			d.logger.Error(err)
+			return nil, nil, err
+		}
+
+		dbs.InvoiceDB = invoiceDB
+	} else {
+		// Once the invoices have been migrated, the ones in the kv
+		// buckets are stale, so there's no way back.
+		migrated, err := dbs.ChanStateDB.GetInvoiceBucketTombstone()
+		if err != nil {
+			cleanUp()
+
+			err := fmt.Errorf("unable to check invoice migration "+
+				"state: %v", err)
+			d.logger.Error(err)
+			return nil, nil, err
+		}
+
+		if migrated {
+			cleanUp()
+
+			err := fmt.Errorf("invoices have been migrated to " +
+				"native SQL, db.use-native-sql must be set")
+			d.logger.Error(err)
+			return nil, nil, err
+		}
+	}
+
 	// Wrap the watchtower client DB and make sure we clean up.
 	if cfg.WtClient.Active {
 		dbs.TowerClientDB, err = wtdb.OpenClientDB(
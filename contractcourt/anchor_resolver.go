@@ -105,12 +105,18 @@ func (c *anchorResolver) Resolve() (ContractResolver, error) {
 		nil,
 	)
 
+	// Now that the commitment is confirmed, there is no deadline to meet
+	// anymore. We'll limit the fees spent on sweeping the anchor to its
+	// own value, so that it is never swept at a loss.
 	resultChan, err := c.Sweeper.SweepInput(
 		&anchorInput,
 		sweep.Params{
 			Fee: sweep.FeePreference{
 				FeeRate: relayFeeRate,
 			},
+			Budget: bronutil.Amount(
+				c.anchorSignDescriptor.Output.Value,
+			),
 		},
 	)
 	if err != nil {
@@ -204,6 +204,10 @@ type ChainArbitrator struct {
 
 	sync.Mutex
 
+	// spliceMtx serializes the completion of splices, which is done by
+	// both the chain watcher of a channel and its peer.
+	spliceMtx sync.Mutex
+
 	// activeChannels is a map of all the active contracts that are still
 	// open, and not fully resolved.
 	activeChannels map[wire.OutPoint]*ChannelArbitrator
@@ -522,6 +526,14 @@ func (c *ChainArbitrator) Start() error {
 				isOurAddr:           c.cfg.IsOurAddress,
 				contractBreach:      breachClosure,
 				extractStateNumHint: lnwallet.GetStateNumHint,
+				spliceConfirmed: func(
+					newChanPoint wire.OutPoint,
+					scid lnwire.ShortChannelID) {
+
+					c.spliceConfirmed(
+						chanPoint, newChanPoint, scid,
+					)
+				},
 			},
 		)
 		if err != nil {
@@ -1126,6 +1138,11 @@ func (c *ChainArbitrator) WatchNewChannel(newChan *channeldb.OpenChannel) error
 				)
 			},
 			extractStateNumHint: lnwallet.GetStateNumHint,
+			spliceConfirmed: func(newChanPoint wire.OutPoint,
+				scid lnwire.ShortChannelID) {
+
+				c.spliceConfirmed(chanPoint, newChanPoint, scid)
+			},
 		},
 	)
 	if err != nil {
@@ -1154,6 +1171,105 @@ func (c *ChainArbitrator) WatchNewChannel(newChan *channeldb.OpenChannel) error
 	return chainWatcher.Start()
 }
 
+// CompleteSplice moves a channel over to the funding output created by its
+// splice transaction, located at the given short channel ID, once the splice
+// transaction is deep enough in the chain. The channel is then watched at its
+// new funding outpoint. The splice is completed both by the chain watcher of
+// the channel, so the channel moves even if the peer is offline, and by the
+// peer once the splice is locked in. Completing a splice again returns the
+// spliced channel.
+func (c *ChainArbitrator) CompleteSplice(oldChanPoint,
+	newChanPoint wire.OutPoint,
+	scid lnwire.ShortChannelID) (*channeldb.OpenChannel, error) {
+
+	c.spliceMtx.Lock()
+	defer c.spliceMtx.Unlock()
+
+	chanDB := c.chanSource.ChannelStateDB()
+	channel, err := chanDB.FetchChannel(nil, oldChanPoint)
+	switch {
+	// The channel no longer exists at its old funding outpoint, so the
+	// splice was already completed.
+	case err == channeldb.ErrChannelNotFound:
+		return chanDB.FetchChannel(nil, newChanPoint)
+
+	case err != nil:
+		return nil, err
+	}
+
+	if err := channel.CompleteSplice(newChanPoint.Hash, scid); err != nil {
+		return nil, err
+	}
+
+	if err := c.spliceChannel(oldChanPoint, channel); err != nil {
+		return nil, err
+	}
+
+	return channel, nil
+}
+
+// spliceConfirmed completes the splice of a channel once its chain watcher
+// saw the splice transaction confirm. This is done in a new goroutine, as the
+// chain watcher is stopped while completing the splice.
+func (c *ChainArbitrator) spliceConfirmed(oldChanPoint,
+	newChanPoint wire.OutPoint, scid lnwire.ShortChannelID) {
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+
+		select {
+		case <-c.quit:
+			return
+		default:
+		}
+
+		_, err := c.CompleteSplice(oldChanPoint, newChanPoint, scid)
+		if err != nil {
+			log.Errorf("Unable to complete splice of "+
+				"ChannelPoint(%v): %v", oldChanPoint, err)
+		}
+	}()
+}
+
+// spliceChannel replaces the ChannelArbitrator and chainWatcher of a channel
+// whose splice transaction confirmed. The channel is now watched at its new
+// funding outpoint, and the state of the arbitrator at the old outpoint is
+// wiped, as the old funding output can no longer be spent by a commitment.
+func (c *ChainArbitrator) spliceChannel(oldChanPoint wire.OutPoint,
+	newChan *channeldb.OpenChannel) error {
+
+	log.Infof("Moving ChannelPoint(%v) to spliced ChannelPoint(%v)",
+		oldChanPoint, newChan.FundingOutpoint)
+
+	c.Lock()
+	chainArb := c.activeChannels[oldChanPoint]
+	delete(c.activeChannels, oldChanPoint)
+
+	chainWatcher := c.activeWatchers[oldChanPoint]
+	delete(c.activeWatchers, oldChanPoint)
+	c.Unlock()
+
+	if chainArb != nil {
+		if err := chainArb.Stop(); err != nil {
+			log.Warnf("unable to stop ChannelArbitrator(%v): %v",
+				oldChanPoint, err)
+		}
+
+		if err := chainArb.log.WipeHistory(); err != nil {
+			return err
+		}
+	}
+	if chainWatcher != nil {
+		if err := chainWatcher.Stop(); err != nil {
+			log.Warnf("unable to stop ChainWatcher(%v): %v",
+				oldChanPoint, err)
+		}
+	}
+
+	return c.WatchNewChannel(newChan)
+}
+
 // SubscribeChannelEvents returns a new active subscription for the set of
 // possible on-chain events for a particular channel. The struct can be used by
 // callers to be notified whenever an event that changes the state of the
@@ -18,6 +18,7 @@ import (
 	"github.com/brsuite/broln/channeldb"
 	"github.com/brsuite/broln/input"
 	"github.com/brsuite/broln/lnwallet"
+	"github.com/brsuite/broln/lnwire"
 )
 
 const (
@@ -187,6 +188,14 @@ type chainWatcherConfig struct {
 	// obfuscater. This is used by the chain watcher to identify which
 	// state was broadcast and confirmed on-chain.
 	extractStateNumHint func(*wire.MsgTx, [lnwallet.StateHintSize]byte) uint64
+
+	// spliceConfirmed is called by the watcher once one of the splice
+	// transactions negotiated for the channel confirmed as deep as the
+	// channel's funding transaction had to. The channel then needs to be
+	// watched at the funding output created by the splice transaction,
+	// located at the given short channel ID.
+	spliceConfirmed func(newChanPoint wire.OutPoint,
+		scid lnwire.ShortChannelID)
 }
 
 // chainWatcher is a system that's assigned to every active channel. The duty
@@ -571,6 +580,27 @@ func (c *chainWatcher) closeObserver(spendNtfn *chainntnfs.SpendEvent) {
 		// revoked state...!!!
 		commitTxBroadcast := commitSpend.SpendingTx
 
+		// Before we treat the spend as a closure, we'll check whether
+		// it's one of the splice transactions negotiated for this
+		// channel. Any of them may confirm, as a splice can be
+		// replaced by fee. The channel then lives on at its new
+		// funding output, where it's watched once the splice is deep
+		// enough.
+		candidate, err := c.spliceCandidate(commitSpend)
+		if err != nil {
+			log.Errorf("Unable to check for splice spend: %v", err)
+			return
+		}
+
+		if candidate != nil {
+			log.Infof("Splice of ChannelPoint(%v) confirmed in "+
+				"txid=%v", c.cfg.chanState.FundingOutpoint,
+				commitSpend.SpenderTxHash)
+
+			c.waitForSpliceConf(commitSpend, candidate)
+			return
+		}
+
 		// First, we'll construct the chainset which includes all the
 		// data we need to dispatch an event to our subscribers about
 		// this possible channel close event.
@@ -620,10 +650,14 @@ func (c *chainWatcher) closeObserver(spendNtfn *chainntnfs.SpendEvent) {
 
 		// Next, we'll check to see if this is a cooperative channel
 		// closure or not. This is characterized by having an input
-		// sequence number that's finalized. This won't happen with
-		// regular commitment transactions due to the state hint
-		// encoding scheme.
-		if commitTxBroadcast.TxIn[0].Sequence == wire.MaxTxInSequenceNum {
+		// sequence number that's finalized, or that signals
+		// replaceability for closing transactions of the RBF
+		// cooperative close flow. This won't happen with regular
+		// commitment transactions due to the state hint encoding
+		// scheme.
+		sequence := commitTxBroadcast.TxIn[0].Sequence
+		if sequence == wire.MaxTxInSequenceNum ||
+			sequence == lnwallet.RbfCloseSequence {
 			// TODO(roasbeef): rare but possible, need itest case
 			// for
 			err := c.dispatchCooperativeClose(commitSpend)
@@ -985,6 +1019,73 @@ func (c *chainWatcher) dispatchCooperativeClose(commitSpend *chainntnfs.SpendDet
 	return nil
 }
 
+// spliceCandidate returns the splice candidate of the channel that the passed
+// spend is, or nil if it isn't one of them.
+func (c *chainWatcher) spliceCandidate(
+	commitSpend *chainntnfs.SpendDetail) (*channeldb.SpliceCandidate,
+	error) {
+
+	candidates, err := c.cfg.chanState.SpliceCandidates()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, candidate := range candidates {
+		if candidate.SpliceTx.TxHash() == *commitSpend.SpenderTxHash {
+			return candidate, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// waitForSpliceConf waits for the splice transaction that spent the funding
+// output to confirm as deep as the channel's funding transaction had to, then
+// hands the channel over to its new funding output. This doesn't depend on
+// the remote peer being online, so the new funding output is always watched.
+func (c *chainWatcher) waitForSpliceConf(commitSpend *chainntnfs.SpendDetail,
+	candidate *channeldb.SpliceCandidate) {
+
+	if c.cfg.spliceConfirmed == nil {
+		return
+	}
+
+	numConfs := uint32(c.cfg.chanState.NumConfsRequired)
+	if numConfs == 0 {
+		numConfs = 1
+	}
+
+	spliceTx := candidate.SpliceTx
+	txid := spliceTx.TxHash()
+	fundingOut := spliceTx.TxOut[candidate.FundingOutpoint.Index]
+	confNtfn, err := c.cfg.notifier.RegisterConfirmationsNtfn(
+		&txid, fundingOut.PkScript, numConfs,
+		uint32(commitSpend.SpendingHeight),
+	)
+	if err != nil {
+		log.Errorf("Unable to register for confirmation of splice "+
+			"tx %v: %v", txid, err)
+		return
+	}
+	defer confNtfn.Cancel()
+
+	select {
+	case conf, ok := <-confNtfn.Confirmed:
+		if !ok {
+			return
+		}
+
+		scid := lnwire.ShortChannelID{
+			BlockHeight: conf.BlockHeight,
+			TxIndex:     conf.TxIndex,
+			TxPosition:  uint16(candidate.FundingOutpoint.Index),
+		}
+		c.cfg.spliceConfirmed(candidate.FundingOutpoint, scid)
+
+	case <-c.quit:
+	}
+}
+
 // dispatchLocalForceClose processes a unilateral close by us being confirmed.
 func (c *chainWatcher) dispatchLocalForceClose(
 	commitSpend *chainntnfs.SpendDetail,
@@ -625,3 +625,125 @@ func TestChainWatcherLocalForceCloseDetect(t *testing.T) {
 		})
 	}
 }
+
+// TestChainWatcherSpliceConfirmed tests that the chain watcher doesn't treat
+// the confirmation of a splice transaction of the channel as a close, and
+// hands the channel over to its new funding output once the splice
+// transaction is deep enough.
+func TestChainWatcherSpliceConfirmed(t *testing.T) {
+	t.Parallel()
+
+	aliceChannel, _, cleanUp, err := lnwallet.CreateTestChannels(
+		channeldb.SingleFunderTweaklessBit,
+	)
+	if err != nil {
+		t.Fatalf("unable to create test channels: %v", err)
+	}
+	defer cleanUp()
+
+	// We'll add a splice candidate to Alice's channel, spending its
+	// funding output.
+	chanState := aliceChannel.State()
+	fundingOut := aliceChannel.FundingOutput()
+	spliceTx := wire.NewMsgTx(2)
+	spliceTx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: chanState.FundingOutpoint,
+	})
+	spliceTx.AddTxOut(&wire.TxOut{
+		Value:    fundingOut.Value * 2,
+		PkScript: fundingOut.PkScript,
+	})
+	spliceTxid := spliceTx.TxHash()
+	candidate := &channeldb.SpliceCandidate{
+		SpliceTx:         spliceTx,
+		FundingOutpoint:  wire.OutPoint{Hash: spliceTxid},
+		Capacity:         chanState.Capacity * 2,
+		LocalCommitment:  chanState.LocalCommitment,
+		RemoteCommitment: chanState.RemoteCommitment,
+	}
+	if err := chanState.AddSpliceCandidate(candidate); err != nil {
+		t.Fatalf("unable to add splice candidate: %v", err)
+	}
+
+	type splice struct {
+		chanPoint wire.OutPoint
+		scid      lnwire.ShortChannelID
+	}
+	spliced := make(chan splice, 1)
+
+	aliceNotifier := &mock.ChainNotifier{
+		SpendChan: make(chan *chainntnfs.SpendDetail),
+		EpochChan: make(chan *chainntnfs.BlockEpoch),
+		ConfChan:  make(chan *chainntnfs.TxConfirmation),
+	}
+	aliceChainWatcher, err := newChainWatcher(chainWatcherConfig{
+		chanState:           chanState,
+		notifier:            aliceNotifier,
+		signer:              aliceChannel.Signer,
+		extractStateNumHint: lnwallet.GetStateNumHint,
+		spliceConfirmed: func(newChanPoint wire.OutPoint,
+			scid lnwire.ShortChannelID) {
+
+			spliced <- splice{newChanPoint, scid}
+		},
+	})
+	if err != nil {
+		t.Fatalf("unable to create chain watcher: %v", err)
+	}
+	if err := aliceChainWatcher.Start(); err != nil {
+		t.Fatalf("unable to start chain watcher: %v", err)
+	}
+	defer aliceChainWatcher.Stop()
+
+	chanEvents := aliceChainWatcher.SubscribeChannelEvents()
+
+	aliceNotifier.SpendChan <- &chainntnfs.SpendDetail{
+		SpenderTxHash:  &spliceTxid,
+		SpendingTx:     spliceTx,
+		SpendingHeight: 100,
+	}
+
+	// The channel is only handed over once the splice transaction is
+	// deep enough.
+	select {
+	case <-spliced:
+		t.Fatalf("splice completed before confirmation")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	select {
+	case aliceNotifier.ConfChan <- &chainntnfs.TxConfirmation{
+		BlockHeight: 100,
+		TxIndex:     3,
+	}:
+	case <-time.After(time.Second * 15):
+		t.Fatalf("chain watcher didn't wait for splice confirmation")
+	}
+
+	expectedScid := lnwire.ShortChannelID{BlockHeight: 100, TxIndex: 3}
+	select {
+	case s := <-spliced:
+		if s.chanPoint != candidate.FundingOutpoint {
+			t.Fatalf("expected spliced ChannelPoint(%v), got %v",
+				candidate.FundingOutpoint, s.chanPoint)
+		}
+		if s.scid != expectedScid {
+			t.Fatalf("expected scid %v, got %v", expectedScid,
+				s.scid)
+		}
+
+	case <-time.After(time.Second * 15):
+		t.Fatalf("splice wasn't completed")
+	}
+
+	// The splice must not have been treated as a close of the channel.
+	select {
+	case <-chanEvents.CooperativeClosure:
+		t.Fatalf("splice detected as cooperative close")
+	case <-chanEvents.RemoteUnilateralClosure:
+		t.Fatalf("splice detected as remote force close")
+	case <-chanEvents.LocalUnilateralClosure:
+		t.Fatalf("splice detected as local force close")
+	default:
+	}
+}
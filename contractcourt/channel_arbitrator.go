@@ -1147,8 +1147,10 @@ func (c *ChannelArbitrator) stateStep(
 }
 
 // sweepAnchors offers all given anchor resolutions to the sweeper. It requests
-// sweeping at the minimum fee rate. This fee rate can be upped manually by the
-// user via the BumpFee rpc.
+// sweeping with the deadline of the HTLCs at stake, with the fee rate being
+// raised as the deadline approaches, up to a budget derived from the value of
+// those HTLCs. This fee rate can be upped manually by the user via the BumpFee
+// rpc.
 func (c *ChannelArbitrator) sweepAnchors(anchors *lnwallet.AnchorResolutions,
 	heightHint uint32) error {
 
@@ -1167,6 +1169,12 @@ func (c *ChannelArbitrator) sweepAnchors(anchors *lnwallet.AnchorResolutions,
 			return err
 		}
 
+		// Find the budget we're willing to spend to meet the deadline.
+		budget, err := c.findCommitmentBudget(htlcs)
+		if err != nil {
+			return err
+		}
+
 		log.Debugf("ChannelArbitrator(%v): pre-confirmation sweep of "+
 			"anchor of %s commit tx %v", c.cfg.ChanPoint,
 			anchorPath, anchor.CommitAnchor)
@@ -1191,16 +1199,22 @@ func (c *ChannelArbitrator) sweepAnchors(anchors *lnwallet.AnchorResolutions,
 		// Also signal that this is a force sweep, so that the anchor
 		// will be swept even if it isn't economical purely based on the
 		// anchor value.
-		_, err = c.cfg.Sweeper.SweepInput(
-			&anchorInput,
-			sweep.Params{
-				Fee: sweep.FeePreference{
-					ConfTarget: deadline,
-				},
-				Force:          true,
-				ExclusiveGroup: &exclusiveGroup,
+		params := sweep.Params{
+			Fee: sweep.FeePreference{
+				ConfTarget: deadline,
 			},
-		)
+			Force:          true,
+			ExclusiveGroup: &exclusiveGroup,
+		}
+
+		// If there are HTLCs at stake, we'll let the sweeper raise the
+		// fee rate as the deadline approaches, up to the budget.
+		if budget != 0 {
+			params.DeadlineHeight = int32(heightHint + deadline)
+			params.Budget = budget
+		}
+
+		_, err = c.cfg.Sweeper.SweepInput(&anchorInput, params)
 		if err != nil {
 			return err
 		}
@@ -1254,10 +1268,58 @@ func (c *ChannelArbitrator) sweepAnchors(anchors *lnwallet.AnchorResolutions,
 func (c *ChannelArbitrator) findCommitmentDeadline(heightHint uint32,
 	htlcs htlcSet) (uint32, error) {
 
+	deadlineHTLCs, err := c.findDeadlineHTLCs(htlcs)
+	if err != nil {
+		return 0, err
+	}
+
 	deadlineMinHeight := uint32(math.MaxUint32)
+	for _, htlc := range deadlineHTLCs {
+		if htlc.RefundTimeout < deadlineMinHeight {
+			deadlineMinHeight = htlc.RefundTimeout
+		}
+	}
+
+	// Calculate the deadline. There are two cases to be handled here,
+	//   - when the deadlineMinHeight never gets updated, which could
+	//     happen when we have no outgoing HTLCs, and, for incoming HTLCs,
+	//       * either we have none, or,
+	//       * none of the HTLCs are preimageAvailable.
+	//   - when our deadlineMinHeight is no greater than the heightHint,
+	//     which means we are behind our schedule.
+	deadline := deadlineMinHeight - heightHint
+	switch {
+	// When we couldn't find a deadline height from our HTLCs, we will fall
+	// back to the default value.
+	case deadlineMinHeight == math.MaxUint32:
+		deadline = anchorSweepConfTarget
 
-	// First, iterate through the outgoingHTLCs to find the lowest CLTV
-	// value.
+	// When the deadline is passed, we will fall back to the smallest conf
+	// target (1 block).
+	case deadlineMinHeight <= heightHint:
+		log.Warnf("ChannelArbitrator(%v): deadline is passed with "+
+			"deadlineMinHeight=%d, heightHint=%d",
+			c.cfg.ChanPoint, deadlineMinHeight, heightHint)
+		deadline = 1
+	}
+
+	log.Debugf("ChannelArbitrator(%v): calculated deadline: %d, "+
+		"using deadlineMinHeight=%d, heightHint=%d",
+		c.cfg.ChanPoint, deadline, deadlineMinHeight, heightHint)
+
+	return deadline, nil
+}
+
+// findDeadlineHTLCs returns the HTLCs of the given set that determine the
+// deadline of a commitment transaction. These are the non-dust outgoing HTLCs,
+// and the non-dust incoming HTLCs we have the preimage for.
+func (c *ChannelArbitrator) findDeadlineHTLCs(
+	htlcs htlcSet) ([]channeldb.HTLC, error) {
+
+	var deadlineHTLCs []channeldb.HTLC
+
+	// First, iterate through the outgoingHTLCs, all of which need to be
+	// timed out on-chain.
 	for _, htlc := range htlcs.outgoingHTLCs {
 		// Skip if the HTLC is dust.
 		if htlc.OutputIndex < 0 {
@@ -1268,13 +1330,11 @@ func (c *ChannelArbitrator) findCommitmentDeadline(heightHint uint32,
 			continue
 		}
 
-		if htlc.RefundTimeout < deadlineMinHeight {
-			deadlineMinHeight = htlc.RefundTimeout
-		}
+		deadlineHTLCs = append(deadlineHTLCs, htlc)
 	}
 
-	// Then going through the incomingHTLCs, and update the minHeight when
-	// conditions met.
+	// Then going through the incomingHTLCs, which only matter if we're
+	// able to claim them.
 	for _, htlc := range htlcs.incomingHTLCs {
 		// Skip if the HTLC is dust.
 		if htlc.OutputIndex < 0 {
@@ -1289,46 +1349,36 @@ func (c *ChannelArbitrator) findCommitmentDeadline(heightHint uint32,
 		// this HTLC.
 		preimageAvailable, err := c.isPreimageAvailable(htlc.RHash)
 		if err != nil {
-			return 0, err
+			return nil, err
 		}
 
 		if !preimageAvailable {
 			continue
 		}
 
-		if htlc.RefundTimeout < deadlineMinHeight {
-			deadlineMinHeight = htlc.RefundTimeout
-		}
+		deadlineHTLCs = append(deadlineHTLCs, htlc)
 	}
 
-	// Calculate the deadline. There are two cases to be handled here,
-	//   - when the deadlineMinHeight never gets updated, which could
-	//     happen when we have no outgoing HTLCs, and, for incoming HTLCs,
-	//       * either we have none, or,
-	//       * none of the HTLCs are preimageAvailable.
-	//   - when our deadlineMinHeight is no greater than the heightHint,
-	//     which means we are behind our schedule.
-	deadline := deadlineMinHeight - heightHint
-	switch {
-	// When we couldn't find a deadline height from our HTLCs, we will fall
-	// back to the default value.
-	case deadlineMinHeight == math.MaxUint32:
-		deadline = anchorSweepConfTarget
+	return deadlineHTLCs, nil
+}
 
-	// When the deadline is passed, we will fall back to the smallest conf
-	// target (1 block).
-	case deadlineMinHeight <= heightHint:
-		log.Warnf("ChannelArbitrator(%v): deadline is passed with "+
-			"deadlineMinHeight=%d, heightHint=%d",
-			c.cfg.ChanPoint, deadlineMinHeight, heightHint)
-		deadline = 1
+// findCommitmentBudget returns the budget we're willing to spend on fees to
+// get a commitment transaction confirmed before its deadline, which is a share
+// of the value of the HTLCs that determine the deadline.
+func (c *ChannelArbitrator) findCommitmentBudget(
+	htlcs htlcSet) (bronutil.Amount, error) {
+
+	deadlineHTLCs, err := c.findDeadlineHTLCs(htlcs)
+	if err != nil {
+		return 0, err
 	}
 
-	log.Debugf("ChannelArbitrator(%v): calculated deadline: %d, "+
-		"using deadlineMinHeight=%d, heightHint=%d",
-		c.cfg.ChanPoint, deadline, deadlineMinHeight, heightHint)
+	var budget bronutil.Amount
+	for _, htlc := range deadlineHTLCs {
+		budget += htlcBudget(htlc)
+	}
 
-	return deadline, nil
+	return budget, nil
 }
 
 // launchResolvers updates the activeResolvers list and starts the resolvers.
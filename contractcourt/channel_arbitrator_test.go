@@ -2391,10 +2391,12 @@ func TestSweepAnchors(t *testing.T) {
 		HtlcIndex:     htlcIndexBase + 2,
 		RefundTimeout: htlcExpiryBase + 2,
 		RHash:         rHash,
+		Amt:           100_000_000,
 	}
 	htlcSmallExipry := channeldb.HTLC{
 		HtlcIndex:     htlcIndexBase + 3,
 		RefundTimeout: htlcExpiryBase + 3,
+		Amt:           200_000_000,
 	}
 
 	// Setup our local HTLC set such that we will use the HTLC's CLTV from
@@ -2474,6 +2476,13 @@ func TestSweepAnchors(t *testing.T) {
 		"remote deadline not matched",
 	)
 
+	// Anchors with HTLCs at stake should be swept with their absolute
+	// deadline and a budget of half the value of those HTLCs, while the
+	// remote anchor has no deadline to meet.
+	require.Equal(t, map[int32]bronutil.Amount{
+		int32(htlcWithPreimage.RefundTimeout): 50_000,
+		int32(htlcSmallExipry.RefundTimeout):  100_000,
+	}, chanArbCtx.sweeper.budgets)
 }
 
 // TestChannelArbitratorAnchors asserts that the commitment tx anchor is swept.
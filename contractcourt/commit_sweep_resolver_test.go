@@ -110,6 +110,10 @@ type mockSweeper struct {
 	createSweepTxChan chan *wire.MsgTx
 
 	deadlines []int
+
+	// budgets maps the deadline heights of the inputs swept with a
+	// deadline to their budgets.
+	budgets map[int32]bronutil.Amount
 }
 
 func newMockSweeper() *mockSweeper {
@@ -119,6 +123,7 @@ func newMockSweeper() *mockSweeper {
 		sweepTx:           &wire.MsgTx{},
 		createSweepTxChan: make(chan *wire.MsgTx),
 		deadlines:         []int{},
+		budgets:           make(map[int32]bronutil.Amount),
 	}
 }
 
@@ -131,6 +136,9 @@ func (s *mockSweeper) SweepInput(input input.Input, params sweep.Params) (
 	if params.Fee.ConfTarget != 0 {
 		s.deadlines = append(s.deadlines, int(params.Fee.ConfTarget))
 	}
+	if params.DeadlineHeight != 0 {
+		s.budgets[params.DeadlineHeight] = params.Budget
+	}
 
 	result := make(chan sweep.Result, 1)
 	result <- sweep.Result{
@@ -7,6 +7,7 @@ import (
 	"io"
 
 	"github.com/brsuite/brond/wire"
+	"github.com/brsuite/bronutil"
 	"github.com/btcsuite/btclog"
 	"github.com/brsuite/broln/build"
 	"github.com/brsuite/broln/channeldb"
@@ -24,8 +25,26 @@ const (
 	// secondLevelConfTarget is the confirmation target we'll use when
 	// adding fees to our second-level HTLC transactions.
 	secondLevelConfTarget = 6
+
+	// htlcBudgetPercent is the percentage of the value of an HTLC we're
+	// willing to spend on fees to get it resolved on-chain before its
+	// deadline.
+	htlcBudgetPercent = 50
+
+	// timeoutDeadlineDelta is the number of blocks after the expiry of an
+	// outgoing HTLC by which its second-level timeout transaction should
+	// confirm. The incoming HTLC it was forwarded from expires at least
+	// the minimum time lock delta later, and needs to be failed back
+	// before we go on-chain to resolve it.
+	timeoutDeadlineDelta = 8
 )
 
+// htlcBudget returns the budget we're willing to spend on fees to resolve the
+// given HTLC on-chain before its deadline.
+func htlcBudget(htlc channeldb.HTLC) bronutil.Amount {
+	return htlc.Amt.ToSatoshis() * htlcBudgetPercent / 100
+}
+
 // ContractResolver is an interface which packages a state machine which is
 // able to carry out the necessary steps required to fully resolve a Brocoin
 // contract on-chain. Resolvers are fully encodable to ensure callers are able
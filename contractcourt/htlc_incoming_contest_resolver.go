@@ -443,9 +443,22 @@ func (h *htlcIncomingContestResolver) SupplementState(_ *channeldb.OpenChannel)
 // decodePayload (re)decodes the hop payload of a received htlc.
 func (h *htlcIncomingContestResolver) decodePayload() (*hop.Payload, error) {
 
+	// HTLCs received within a blinded route can only be decoded with the
+	// blinding point that was sent along with them.
+	blindingPoint, err := h.htlc.BlindingPoint()
+	if err != nil {
+		return nil, err
+	}
+
+	blindingInfo := hop.ReconstructBlindingInfo{
+		IncomingAmt:    h.htlc.Amt,
+		IncomingExpiry: h.htlcExpiry,
+		BlindingPoint:  blindingPoint,
+	}
+
 	onionReader := bytes.NewReader(h.htlc.OnionBlob)
 	iterator, err := h.OnionProcessor.ReconstructHopIterator(
-		onionReader, h.htlc.RHash[:],
+		onionReader, h.htlc.RHash[:], blindingInfo,
 	)
 	if err != nil {
 		return nil, err
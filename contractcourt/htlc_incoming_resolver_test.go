@@ -6,6 +6,7 @@ import (
 	"io/ioutil"
 	"testing"
 
+	"github.com/brsuite/brond/btcec"
 	sphinx "github.com/brsuite/lightning-onion"
 	"github.com/brsuite/broln/chainntnfs"
 	"github.com/brsuite/broln/channeldb"
@@ -144,6 +145,51 @@ func TestHtlcIncomingResolverExitSettle(t *testing.T) {
 	}
 }
 
+// TestHtlcIncomingResolverExitSettleBlinded tests resolution of an exit hop
+// htlc that was received within a blinded route. The blinding point stored
+// along with the htlc must be handed to the onion processor, as the onion
+// can't be decoded without it.
+func TestHtlcIncomingResolverExitSettleBlinded(t *testing.T) {
+	t.Parallel()
+	defer timeout(t)()
+
+	blindingKey, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatal(err)
+	}
+	blindingPoint := lnwire.BlindingPoint(*blindingKey.PubKey())
+
+	var extraData lnwire.ExtraOpaqueData
+	err = lnwire.EncodeMessageExtraData(&extraData, &blindingPoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := newIncomingResolverTestContext(t, true)
+	ctx.resolver.htlc.ExtraData = extraData
+	ctx.registry.notifyResolution = invoices.NewSettleResolution(
+		testResPreimage, testResCircuitKey, testAcceptHeight,
+		invoices.ResultReplayToSettled,
+	)
+
+	ctx.resolve()
+	<-ctx.registry.notifyChan
+	ctx.waitForResult(true)
+
+	info := ctx.onionProcessor.offeredBlindingInfo
+	if info.BlindingPoint == nil ||
+		!info.BlindingPoint.IsEqual(blindingKey.PubKey()) {
+
+		t.Fatal("blinding point not passed to onion processor")
+	}
+	if info.IncomingAmt != lnwire.MilliSatoshi(testHtlcAmount) {
+		t.Fatalf("incorrect incoming amount: %v", info.IncomingAmt)
+	}
+	if info.IncomingExpiry != testHtlcExpiry {
+		t.Fatalf("incorrect incoming expiry: %v", info.IncomingExpiry)
+	}
+}
+
 // TestHtlcIncomingResolverExitCancel tests resolution of an exit hop htlc for
 // an invoice that is already canceled when the resolver starts.
 func TestHtlcIncomingResolverExitCancel(t *testing.T) {
@@ -276,18 +322,20 @@ func (h *mockHopIterator) HopPayload() (*hop.Payload, error) {
 }
 
 type mockOnionProcessor struct {
-	isExit           bool
-	offeredOnionBlob []byte
+	isExit              bool
+	offeredOnionBlob    []byte
+	offeredBlindingInfo hop.ReconstructBlindingInfo
 }
 
-func (o *mockOnionProcessor) ReconstructHopIterator(r io.Reader, rHash []byte) (
-	hop.Iterator, error) {
+func (o *mockOnionProcessor) ReconstructHopIterator(r io.Reader, rHash []byte,
+	blindingInfo hop.ReconstructBlindingInfo) (hop.Iterator, error) {
 
 	data, err := ioutil.ReadAll(r)
 	if err != nil {
 		return nil, err
 	}
 	o.offeredOnionBlob = data
+	o.offeredBlindingInfo = blindingInfo
 
 	return &mockHopIterator{isExit: o.isExit}, nil
 }
@@ -240,12 +240,16 @@ func (h *htlcSuccessResolver) broadcastReSignedSuccessTx() (
 			h.htlcResolution.SignDetails, h.htlcResolution.Preimage,
 			h.broadcastHeight,
 		)
+		// The second-level transaction needs to confirm before the
+		// remote party is able to time out the HTLC.
 		_, err := h.Sweeper.SweepInput(
 			&secondLevelInput,
 			sweep.Params{
 				Fee: sweep.FeePreference{
 					ConfTarget: secondLevelConfTarget,
 				},
+				DeadlineHeight: int32(h.htlc.RefundTimeout),
+				Budget:         htlcBudget(h.htlc),
 			},
 		)
 		if err != nil {
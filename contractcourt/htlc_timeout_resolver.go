@@ -344,12 +344,18 @@ func (h *htlcTimeoutResolver) spendHtlcOutput() (*chainntnfs.SpendDetail, error)
 			h.htlcResolution.SignDetails,
 			h.broadcastHeight,
 		)
+		// The second-level transaction needs to confirm in time for
+		// the incoming HTLC to be failed back.
 		_, err := h.Sweeper.SweepInput(
 			&inp,
 			sweep.Params{
 				Fee: sweep.FeePreference{
 					ConfTarget: secondLevelConfTarget,
 				},
+				DeadlineHeight: int32(
+					h.htlc.RefundTimeout + timeoutDeadlineDelta,
+				),
+				Budget: htlcBudget(h.htlc),
 			},
 		)
 		if err != nil {
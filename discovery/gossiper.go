@@ -708,6 +708,18 @@ func (d *AuthenticatedGossiper) ProcessRemoteAnnouncement(msg lnwire.Message,
 			errChan <- ownErr
 			return errChan
 		}
+
+		// Alias ShortChannelIDs are only used locally and never
+		// appear in valid announcements.
+		if channeldb.IsAlias(m.ShortChannelID) {
+			aliasErr := fmt.Errorf("ignoring remote "+
+				"ChannelAnnouncement with alias "+
+				"short_chan_id=%v", m.ShortChannelID)
+
+			log.Warn(aliasErr)
+			errChan <- aliasErr
+			return errChan
+		}
 	}
 
 	nMsg := &networkMsg{
@@ -4278,3 +4278,36 @@ func TestRejectCacheChannelAnn(t *testing.T) {
 		t.Fatal("did not process remote announcement")
 	}
 }
+
+// TestRejectRemoteAliasAnnouncement tests that a remote ChannelAnnouncement
+// using an alias short channel ID is rejected, as aliases are only used
+// locally.
+func TestRejectRemoteAliasAnnouncement(t *testing.T) {
+	t.Parallel()
+
+	ctx, cleanup, err := createTestCtx(proofMatureDelta)
+	require.NoError(t, err)
+	defer cleanup()
+
+	chanAnn, err := createRemoteChannelAnnouncement(
+		channeldb.StartingAlias.BlockHeight,
+	)
+	require.NoError(t, err)
+	require.True(t, channeldb.IsAlias(chanAnn.ShortChannelID))
+
+	remotePeer := &mockPeer{remoteKeyPriv1.PubKey(), nil, nil}
+
+	select {
+	case err = <-ctx.gossiper.ProcessRemoteAnnouncement(
+		chanAnn, remotePeer,
+	):
+	case <-time.After(2 * time.Second):
+		t.Fatal("did not process remote announcement")
+	}
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "alias")
+
+	ctx.router.mu.Lock()
+	require.Empty(t, ctx.router.infos)
+	ctx.router.mu.Unlock()
+}
@@ -69,6 +69,15 @@ var deps = depDesc{
 		lnwire.ExplicitChannelTypeOptional:  {},
 		lnwire.AnchorsZeroFeeHtlcTxOptional: {},
 	},
+	lnwire.ScidAliasOptional: {
+		lnwire.ExplicitChannelTypeOptional: {},
+	},
+	lnwire.ZeroConfOptional: {
+		lnwire.ScidAliasOptional: {},
+	},
+	lnwire.SpliceOptional: {
+		lnwire.DualFundOptional: {},
+	},
 }
 
 // ValidateDeps asserts that a feature vector sets all features and their
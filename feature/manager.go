@@ -27,6 +27,39 @@ type Config struct {
 	// NoScriptEnforcementLease unsets any bits signaling support for script
 	// enforced leases.
 	NoScriptEnforcementLease bool
+
+	// NoScidAlias unsets any bits signalling support for the
+	// option_scid_alias channel type.
+	NoScidAlias bool
+
+	// NoZeroConf unsets any bits signalling support for zero-conf
+	// channels. This should be set whenever NoScidAlias is set, since
+	// zero-conf depends on option_scid_alias.
+	NoZeroConf bool
+
+	// NoOnionMessages unsets any bits signalling support for forwarding
+	// and receiving onion messages.
+	NoOnionMessages bool
+
+	// NoRouteBlinding unsets any bits signalling support for forwarding
+	// payments within blinded routes.
+	NoRouteBlinding bool
+
+	// NoDualFund unsets any bits signalling support for dual funded
+	// channel opens.
+	NoDualFund bool
+
+	// NoSplice unsets any bits signalling support for splicing funds into
+	// and out of existing channels.
+	NoSplice bool
+
+	// NoPeerStorage unsets any bits signalling support for storing
+	// encrypted backups on behalf of channel peers.
+	NoPeerStorage bool
+
+	// NoRbfCoopClose unsets any bits signalling support for cooperative
+	// closes with replaceable closing transactions.
+	NoRbfCoopClose bool
 }
 
 // Manager is responsible for generating feature vectors for different requested
@@ -115,6 +148,38 @@ func newManager(cfg Config, desc setDesc) (*Manager, error) {
 			raw.Unset(lnwire.ScriptEnforcedLeaseOptional)
 			raw.Unset(lnwire.ScriptEnforcedLeaseRequired)
 		}
+		if cfg.NoScidAlias {
+			raw.Unset(lnwire.ScidAliasOptional)
+			raw.Unset(lnwire.ScidAliasRequired)
+		}
+		if cfg.NoZeroConf {
+			raw.Unset(lnwire.ZeroConfOptional)
+			raw.Unset(lnwire.ZeroConfRequired)
+		}
+		if cfg.NoOnionMessages {
+			raw.Unset(lnwire.OnionMessagesOptional)
+			raw.Unset(lnwire.OnionMessagesRequired)
+		}
+		if cfg.NoRouteBlinding {
+			raw.Unset(lnwire.RouteBlindingOptional)
+			raw.Unset(lnwire.RouteBlindingRequired)
+		}
+		if cfg.NoDualFund {
+			raw.Unset(lnwire.DualFundOptional)
+			raw.Unset(lnwire.DualFundRequired)
+		}
+		if cfg.NoSplice {
+			raw.Unset(lnwire.SpliceOptional)
+			raw.Unset(lnwire.SpliceRequired)
+		}
+		if cfg.NoPeerStorage {
+			raw.Unset(lnwire.ProvideStorageOptional)
+			raw.Unset(lnwire.ProvideStorageRequired)
+		}
+		if cfg.NoRbfCoopClose {
+			raw.Unset(lnwire.SimpleCloseOptional)
+			raw.Unset(lnwire.SimpleCloseRequired)
+		}
 
 		// Ensure that all of our feature sets properly set any
 		// dependent features.
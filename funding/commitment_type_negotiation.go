@@ -60,7 +60,24 @@ func negotiateCommitmentType(channelType *lnwire.ChannelType,
 func explicitNegotiateCommitmentType(channelType lnwire.ChannelType,
 	local, remote *lnwire.FeatureVector) (lnwallet.CommitmentType, error) {
 
-	channelFeatures := lnwire.RawFeatureVector(channelType)
+	// The zero-conf and scid-alias bits may be set on top of any of the
+	// base channel types below, so we check them separately and strip
+	// them from a copy of the channel type before matching.
+	rawChanType := lnwire.RawFeatureVector(channelType)
+	channelFeatures := rawChanType.Clone()
+
+	if channelFeatures.IsSet(lnwire.ZeroConfRequired) {
+		if !hasFeatures(local, remote, lnwire.ZeroConfOptional) {
+			return 0, errUnsupportedChannelType
+		}
+		channelFeatures.Unset(lnwire.ZeroConfRequired)
+	}
+	if channelFeatures.IsSet(lnwire.ScidAliasRequired) {
+		if !hasFeatures(local, remote, lnwire.ScidAliasOptional) {
+			return 0, errUnsupportedChannelType
+		}
+		channelFeatures.Unset(lnwire.ScidAliasRequired)
+	}
 
 	switch {
 	// Lease script enforcement + anchors zero fee + static remote key
@@ -158,3 +175,41 @@ func hasFeatures(local, remote *lnwire.FeatureVector,
 	}
 	return true
 }
+
+// withAliasChanTypeBits returns a copy of the passed channel type with the
+// zero-conf and/or scid-alias bits set. If no channel type is passed, the one
+// implicit negotiation would settle on for the given features is used as the
+// base.
+func withAliasChanTypeBits(channelType *lnwire.ChannelType,
+	local, remote *lnwire.FeatureVector,
+	zeroConf, scidAlias bool) *lnwire.ChannelType {
+
+	if channelType == nil {
+		channelType, _ = implicitNegotiateCommitmentType(local, remote)
+	}
+
+	rawChanType := lnwire.RawFeatureVector(*channelType)
+	features := rawChanType.Clone()
+	if zeroConf {
+		features.Set(lnwire.ZeroConfRequired)
+	}
+	if scidAlias {
+		features.Set(lnwire.ScidAliasRequired)
+	}
+
+	chanType := lnwire.ChannelType(*features)
+	return &chanType
+}
+
+// hasChanTypeBit returns true if the passed channel type is non-nil and has
+// the given feature bit set.
+func hasChanTypeBit(channelType *lnwire.ChannelType,
+	bit lnwire.FeatureBit) bool {
+
+	if channelType == nil {
+		return false
+	}
+
+	rawChanType := lnwire.RawFeatureVector(*channelType)
+	return rawChanType.IsSet(bit)
+}
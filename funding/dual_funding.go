@@ -0,0 +1,1636 @@
+package funding
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"sync"
+
+	"github.com/brsuite/brond/btcec"
+	"github.com/brsuite/brond/txscript"
+	"github.com/brsuite/brond/wire"
+	"github.com/brsuite/bronutil"
+	"github.com/davecgh/go-spew/spew"
+	"github.com/go-errors/errors"
+	"github.com/brsuite/broln/chainntnfs"
+	"github.com/brsuite/broln/chanacceptor"
+	"github.com/brsuite/broln/channeldb"
+	"github.com/brsuite/broln/keychain"
+	"github.com/brsuite/broln/labels"
+	"github.com/brsuite/broln/lnpeer"
+	"github.com/brsuite/broln/lnrpc"
+	"github.com/brsuite/broln/lnwallet"
+	"github.com/brsuite/broln/lnwallet/chainfee"
+	"github.com/brsuite/broln/lnwallet/chanfunding"
+	"github.com/brsuite/broln/lnwire"
+)
+
+var (
+	// txSigsKeyPrefix is the prefix of the keys under which the state of
+	// the exchange of witnesses for the funding transactions of dual
+	// funded channels is stored in the channel opening state database.
+	txSigsKeyPrefix = []byte("tx-sigs")
+)
+
+const (
+	// maxLeaseHeightDelta is the maximum number of blocks the start height
+	// of a lease requested by the opener of a dual funded channel may
+	// differ from our current height.
+	maxLeaseHeightDelta = 6
+)
+
+// dualFundCtx tracks the state of a dual funded channel whose funding
+// transaction is constructed interactively with the remote peer. Contrary to
+// the single funder workflow, the funding transaction is only known once both
+// parties completed the construction, and can only be published once both
+// parties exchanged the witnesses for their inputs, which happens after the
+// commitment transactions have been signed.
+type dualFundCtx struct {
+	// feeRate is the fee rate of the funding transaction, as proposed by
+	// the opener of the channel.
+	feeRate chainfee.SatPerKWeight
+
+	// requestAmt is the amount we asked the remote peer to contribute to
+	// the channel as its opener.
+	requestAmt bronutil.Amount
+
+	// maxLeaseFee is the maximum lease fee we pay for requestAmt as the
+	// opener of the channel.
+	maxLeaseFee bronutil.Amount
+
+	// leaseHeight is the height the lease of the requested funds starts
+	// at.
+	leaseHeight uint32
+
+	// remoteFundingAmt is the amount the remote peer contributes to the
+	// funding output.
+	remoteFundingAmt bronutil.Amount
+
+	// remoteContribution is the channel contribution of the remote peer.
+	// It is processed by the reservation once the construction of the
+	// funding transaction is finished.
+	remoteContribution *lnwallet.ChannelContribution
+
+	// intent is the interactive funding intent of the reservation.
+	intent *chanfunding.InteractiveIntent
+
+	// session is the interactive construction of the funding transaction.
+	session *chanfunding.InteractiveTx
+
+	// pendingMsgs are the messages announcing our inputs and outputs that
+	// still need to be sent to the remote peer.
+	pendingMsgs []lnwire.Message
+
+	// ourTurn is true if we're to send the next message of the
+	// interactive construction.
+	ourTurn bool
+
+	// localComplete and remoteComplete are set once the respective party
+	// sent tx_complete. Both are reset whenever an input or output is
+	// added or removed, the construction is finished once both are set.
+	localComplete  bool
+	remoteComplete bool
+
+	// txComplete is set once the construction of the funding transaction
+	// is finished and we signed the commitment of the remote peer.
+	txComplete bool
+}
+
+// txSigsCtx tracks the exchange of the witnesses for the funding transaction
+// of a dual funded channel whose commitments have been signed. It is persisted
+// until the funding transaction confirmed, so the exchange can be resumed
+// after a restart.
+type txSigsCtx struct {
+	// chanPoint is the funding outpoint of the channel.
+	chanPoint wire.OutPoint
+
+	// peerKey is the identity key of the remote peer.
+	peerKey *btcec.PublicKey
+
+	// signFirst is true if we're to send our witnesses first.
+	signFirst bool
+
+	// fundingTx is the funding transaction carrying only the witnesses of
+	// our own inputs.
+	fundingTx *wire.MsgTx
+
+	// remotePrevOuts are the outputs spent by the inputs of the remote
+	// peer, ordered by their serial IDs. They are required to verify the
+	// witnesses of the remote peer.
+	remotePrevOuts []*wire.TxOut
+
+	// signedTx is the fully signed funding transaction. It is set once
+	// the witnesses of the remote peer have been verified.
+	signedTx *wire.MsgTx
+
+	// sentSigs is set once we sent our witnesses to the remote peer.
+	sentSigs bool
+
+	// done is closed once the funding transaction confirmed.
+	done chan struct{}
+
+	mu sync.Mutex
+}
+
+// encode serializes the state of the exchange that needs to survive a
+// restart.
+func (c *txSigsCtx) encode(w io.Writer) error {
+	if err := binary.Write(w, byteOrder, c.signFirst); err != nil {
+		return err
+	}
+
+	numPrevOuts := uint64(len(c.remotePrevOuts))
+	if err := wire.WriteVarInt(w, 0, numPrevOuts); err != nil {
+		return err
+	}
+	for _, prevOut := range c.remotePrevOuts {
+		err := binary.Write(w, byteOrder, prevOut.Value)
+		if err != nil {
+			return err
+		}
+		err = wire.WriteVarBytes(w, 0, prevOut.PkScript)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := c.fundingTx.Serialize(w); err != nil {
+		return err
+	}
+
+	// The fully signed funding transaction is only present once we
+	// received the witnesses of the remote peer.
+	if c.signedTx == nil {
+		return nil
+	}
+
+	return c.signedTx.Serialize(w)
+}
+
+// decodeTxSigsCtx deserializes the state of an exchange of witnesses that
+// was serialized by encode.
+func decodeTxSigsCtx(b []byte) (*txSigsCtx, error) {
+	r := bytes.NewReader(b)
+	c := &txSigsCtx{
+		done: make(chan struct{}),
+	}
+
+	if err := binary.Read(r, byteOrder, &c.signFirst); err != nil {
+		return nil, err
+	}
+
+	// If we're to sign first, our witnesses were sent right after they
+	// were persisted.
+	c.sentSigs = c.signFirst
+
+	numPrevOuts, err := wire.ReadVarInt(r, 0)
+	if err != nil {
+		return nil, err
+	}
+	for i := uint64(0); i < numPrevOuts; i++ {
+		var value int64
+		if err := binary.Read(r, byteOrder, &value); err != nil {
+			return nil, err
+		}
+		pkScript, err := wire.ReadVarBytes(
+			r, 0, txscript.MaxScriptSize, "pkScript",
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		c.remotePrevOuts = append(
+			c.remotePrevOuts, wire.NewTxOut(value, pkScript),
+		)
+	}
+
+	c.fundingTx = &wire.MsgTx{}
+	if err := c.fundingTx.Deserialize(r); err != nil {
+		return nil, err
+	}
+
+	if r.Len() == 0 {
+		return c, nil
+	}
+
+	c.signedTx = &wire.MsgTx{}
+	if err := c.signedTx.Deserialize(r); err != nil {
+		return nil, err
+	}
+
+	// Once we received the witnesses of the remote peer, we've either
+	// sent our own already or were about to.
+	c.sentSigs = true
+
+	return c, nil
+}
+
+// localWitnesses returns the witnesses of our inputs to the given funding
+// transaction, ordered by their serial IDs. The inputs of the remote peer
+// must not have been signed yet.
+func localWitnesses(fundingTx *wire.MsgTx) []wire.TxWitness {
+	var witnesses []wire.TxWitness
+	for _, txIn := range fundingTx.TxIn {
+		if len(txIn.Witness) != 0 {
+			witnesses = append(witnesses, txIn.Witness)
+		}
+	}
+
+	return witnesses
+}
+
+// addRemoteWitnesses attaches the witnesses of the remote peer, ordered by
+// the serial IDs of its inputs, to the unsigned inputs of a copy of the given
+// funding transaction, and verifies them against the outputs they spend. The
+// fully signed funding transaction is returned.
+func addRemoteWitnesses(fundingTx *wire.MsgTx, prevOuts []*wire.TxOut,
+	witnesses []wire.TxWitness) (*wire.MsgTx, error) {
+
+	var remoteIdxs []int
+	for idx, txIn := range fundingTx.TxIn {
+		if len(txIn.Witness) == 0 {
+			remoteIdxs = append(remoteIdxs, idx)
+		}
+	}
+	if len(remoteIdxs) != len(prevOuts) {
+		return nil, errors.Errorf("expected %v previous outputs, "+
+			"got %v", len(remoteIdxs), len(prevOuts))
+	}
+	if len(remoteIdxs) != len(witnesses) {
+		return nil, errors.Errorf("expected %v witnesses, got %v",
+			len(remoteIdxs), len(witnesses))
+	}
+
+	signedTx := fundingTx.Copy()
+	for i, idx := range remoteIdxs {
+		signedTx.TxIn[idx].Witness = witnesses[i]
+	}
+
+	hashCache := txscript.NewTxSigHashes(signedTx)
+	for i, idx := range remoteIdxs {
+		vm, err := txscript.NewEngine(
+			prevOuts[i].PkScript, signedTx, idx,
+			txscript.StandardVerifyFlags, nil, hashCache,
+			prevOuts[i].Value,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if err := vm.Execute(); err != nil {
+			return nil, errors.Errorf("invalid witness for input "+
+				"%d: %v", idx, err)
+		}
+	}
+
+	return signedTx, nil
+}
+
+// startSession records the interactive construction of the funding
+// transaction, and queues the messages announcing our inputs and outputs.
+func (d *dualFundCtx) startSession(chanID lnwire.ChannelID,
+	session *chanfunding.InteractiveTx) {
+
+	d.session = session
+
+	for _, in := range session.Inputs() {
+		if !in.Local {
+			continue
+		}
+
+		d.pendingMsgs = append(d.pendingMsgs, &lnwire.TxAddInput{
+			ChanID:    chanID,
+			SerialID:  in.SerialID,
+			PrevTx:    in.PrevTx,
+			PrevTxOut: in.PrevTxOut,
+			Sequence:  in.Sequence,
+		})
+	}
+
+	for _, out := range session.Outputs() {
+		if !out.Local {
+			continue
+		}
+
+		d.pendingMsgs = append(d.pendingMsgs, &lnwire.TxAddOutput{
+			ChanID:   chanID,
+			SerialID: out.SerialID,
+			Amount:   bronutil.Amount(out.TxOut.Value),
+			PkScript: out.TxOut.PkScript,
+		})
+	}
+}
+
+// signFirst returns true if we're to send the witnesses of our inputs first.
+// The party that contributed less to the inputs of the funding transaction
+// signs first, with the opener of the channel breaking ties.
+func (d *dualFundCtx) signFirst() bool {
+	local := d.session.InputAmount(true)
+	remote := d.session.InputAmount(false)
+	if local == remote {
+		return d.session.Initiator()
+	}
+
+	return local < remote
+}
+
+// handleOpenChannel2 creates a dual funded 'ChannelReservation' within the
+// wallet, contributing funds to the channel if the remote peer requested our
+// liquidity, then responds with an accept_channel2 message. The construction
+// of the funding transaction starts once the remote peer receives it.
+func (f *Manager) handleOpenChannel2(peer lnpeer.Peer,
+	msg *lnwire.OpenChannel2) {
+
+	peerPubKey := peer.IdentityKey()
+	peerIDKey := newSerializedKey(peerPubKey)
+	pendingChanID := msg.PendingChannelID
+
+	if !hasFeatures(
+		peer.LocalFeatures(), peer.RemoteFeatures(),
+		lnwire.DualFundOptional,
+	) {
+
+		err := errors.New("dual funded channels not supported")
+		f.failFundingFlow(peer, pendingChanID, err)
+		return
+	}
+
+	numPending, err := f.numPendingChannels(peerPubKey)
+	if err != nil {
+		f.failFundingFlow(peer, pendingChanID, err)
+		return
+	}
+	if numPending >= f.cfg.MaxPendingChannels {
+		f.failFundingFlow(
+			peer, pendingChanID, lnwire.ErrMaxPendingChannels,
+		)
+		return
+	}
+
+	// We'll also reject any requests to create channels until we're fully
+	// synced to the network as we won't be able to properly validate the
+	// confirmation of the funding transaction.
+	isSynced, bestHeight, err := f.cfg.Wallet.IsSynced()
+	if err != nil || !isSynced {
+		if err != nil {
+			log.Errorf("unable to query wallet: %v", err)
+		}
+		f.failFundingFlow(
+			peer, pendingChanID, lnwire.ErrSynchronizingChain,
+		)
+		return
+	}
+
+	// If the opener requested our liquidity and we advertise lease rates,
+	// we'll contribute the requested amount up to our configured maximum.
+	// The lease fee is pushed to us in the initial commitment state.
+	var ourAmt, leaseFee bronutil.Amount
+	if msg.RequestFunds != nil && f.cfg.LeaseRates != nil {
+		heightDelta := int64(msg.RequestFunds.BlockHeight) -
+			int64(bestHeight)
+		if heightDelta > maxLeaseHeightDelta ||
+			heightDelta < -maxLeaseHeightDelta {
+
+			err := errors.Errorf("lease height %v too far from "+
+				"current height %v", msg.RequestFunds.BlockHeight,
+				bestHeight)
+			f.failFundingFlow(peer, pendingChanID, err)
+			return
+		}
+
+		ourAmt = msg.RequestFunds.RequestedSats
+		if f.cfg.MaxLeaseAmt != 0 && ourAmt > f.cfg.MaxLeaseAmt {
+			ourAmt = f.cfg.MaxLeaseAmt
+		}
+		leaseFee = f.cfg.LeaseRates.LeaseFee(
+			ourAmt, msg.FundingFeePerKWeight,
+		)
+	}
+
+	// Ensure that the total capacity of the channel is within our limits.
+	capacity := msg.FundingAmount + ourAmt
+	if capacity > f.cfg.MaxChanSize {
+		f.failFundingFlow(
+			peer, pendingChanID,
+			lnwallet.ErrChanTooLarge(capacity, f.cfg.MaxChanSize),
+		)
+		return
+	}
+	if capacity < f.cfg.MinChanSize {
+		f.failFundingFlow(
+			peer, pendingChanID,
+			lnwallet.ErrChanTooSmall(capacity, f.cfg.MinChanSize),
+		)
+		return
+	}
+
+	// Send the request to the ChannelAcceptor to determine whether this
+	// node will accept the channel. The acceptor only knows about single
+	// funded channels, so we'll present the opener's contribution and the
+	// lease fee pushed to us in that form.
+	chanReq := &chanacceptor.ChannelAcceptRequest{
+		Node: peerPubKey,
+		OpenChanMsg: &lnwire.OpenChannel{
+			ChainHash:             msg.ChainHash,
+			PendingChannelID:      pendingChanID,
+			FundingAmount:         msg.FundingAmount,
+			PushAmount:            lnwire.NewMSatFromSatoshis(leaseFee),
+			DustLimit:             msg.DustLimit,
+			MaxValueInFlight:      msg.MaxValueInFlight,
+			HtlcMinimum:           msg.HtlcMinimum,
+			FeePerKiloWeight:      msg.CommitFeePerKWeight,
+			CsvDelay:              msg.CsvDelay,
+			MaxAcceptedHTLCs:      msg.MaxAcceptedHTLCs,
+			FundingKey:            msg.FundingKey,
+			RevocationPoint:       msg.RevocationPoint,
+			PaymentPoint:          msg.PaymentPoint,
+			DelayedPaymentPoint:   msg.DelayedPaymentPoint,
+			HtlcPoint:             msg.HtlcPoint,
+			FirstCommitmentPoint:  msg.FirstCommitmentPoint,
+			ChannelFlags:          msg.ChannelFlags,
+			UpfrontShutdownScript: msg.UpfrontShutdownScript,
+			ChannelType:           msg.ChannelType,
+		},
+	}
+	acceptorResp := f.cfg.OpenChannelPredicate.Accept(chanReq)
+	if acceptorResp.RejectChannel() {
+		f.failFundingFlow(
+			peer, pendingChanID, acceptorResp.ChanAcceptError,
+		)
+		return
+	}
+
+	log.Infof("Recv'd dual fundingRequest(amt=%v, our_amt=%v, "+
+		"lease_fee=%v, delay=%v, pendingId=%x) from peer(%x)",
+		msg.FundingAmount, ourAmt, leaseFee, msg.CsvDelay,
+		pendingChanID, peerPubKey.SerializeCompressed())
+
+	wasExplicit, _, commitType, err := negotiateCommitmentType(
+		msg.ChannelType, peer.LocalFeatures(), peer.RemoteFeatures(),
+		false,
+	)
+	if err != nil {
+		log.Errorf("channel type negotiation failed: %v", err)
+		f.failFundingFlow(peer, pendingChanID, err)
+		return
+	}
+
+	// Only echo back a channel type in AcceptChannel2 if we actually used
+	// explicit negotiation above.
+	var chanTypeFeatureBits *lnwire.ChannelType
+	if wasExplicit {
+		chanTypeFeatureBits = msg.ChannelType
+	}
+
+	// A zero-conf channel is only accepted if our channel acceptor
+	// explicitly allowed it, and the acceptor can only allow it if the
+	// zero-conf channel type was negotiated.
+	zeroConf := hasChanTypeBit(chanTypeFeatureBits, lnwire.ZeroConfRequired)
+	if zeroConf != acceptorResp.ZeroConf {
+		err := errors.New("zero-conf channel type not negotiated " +
+			"and accepted")
+		log.Errorf("Rejecting channel: %v", err)
+		f.failFundingFlow(peer, pendingChanID, err)
+		return
+	}
+
+	// The option_scid_alias channel type is only valid for private
+	// channels.
+	scidAlias := hasChanTypeBit(chanTypeFeatureBits, lnwire.ScidAliasRequired)
+	if scidAlias && msg.ChannelFlags&lnwire.FFAnnounceChannel != 0 {
+		err := errors.New("option_scid_alias is only supported for " +
+			"private channels")
+		f.failFundingFlow(peer, pendingChanID, err)
+		return
+	}
+
+	chainHash := msg.ChainHash
+	req := &lnwallet.InitFundingReserveMsg{
+		ChainHash:        &chainHash,
+		PendingChanID:    pendingChanID,
+		NodeID:           peerPubKey,
+		NodeAddr:         peer.Address(),
+		LocalFundingAmt:  ourAmt,
+		RemoteFundingAmt: msg.FundingAmount,
+		CommitFeePerKw: chainfee.SatPerKWeight(
+			msg.CommitFeePerKWeight,
+		),
+		FundingFeePerKw: chainfee.SatPerKWeight(
+			msg.FundingFeePerKWeight,
+		),
+		PushMSat:        lnwire.NewMSatFromSatoshis(leaseFee),
+		Flags:           msg.ChannelFlags,
+		MinConfs:        1,
+		CommitType:      commitType,
+		ZeroConf:        zeroConf,
+		OptionScidAlias: scidAlias,
+		ScidAliasFeature: hasFeatures(
+			peer.LocalFeatures(), peer.RemoteFeatures(),
+			lnwire.ScidAliasOptional,
+		),
+		DualFundRole:    lnwallet.DualFundAccepter,
+		FundingLockTime: msg.LockTime,
+	}
+	reservation, err := f.cfg.Wallet.InitChannelReservation(req)
+
+	// If we're unable to contribute the requested liquidity, we'll still
+	// accept the channel, leaving it to the opener whether to continue
+	// without our contribution.
+	if err != nil && ourAmt != 0 {
+		log.Warnf("Unable to contribute %v to pending_id(%x), "+
+			"accepting without contribution: %v", ourAmt,
+			pendingChanID, err)
+
+		ourAmt, leaseFee = 0, 0
+		capacity = msg.FundingAmount
+		req.LocalFundingAmt = 0
+		req.PushMSat = 0
+		reservation, err = f.cfg.Wallet.InitChannelReservation(req)
+	}
+	if err != nil {
+		log.Errorf("Unable to initialize reservation: %v", err)
+		f.failFundingFlow(peer, pendingChanID, err)
+		return
+	}
+
+	// As we're the responder, we get to specify the number of
+	// confirmations that we require before both of us consider the channel
+	// open.
+	numConfsReq := f.cfg.NumRequiredConfs(
+		capacity, lnwire.NewMSatFromSatoshis(leaseFee),
+	)
+	if acceptorResp.MinAcceptDepth != 0 {
+		numConfsReq = acceptorResp.MinAcceptDepth
+	}
+	if zeroConf {
+		numConfsReq = 0
+	}
+	reservation.SetNumConfsRequired(numConfsReq)
+
+	// The channel reserve isn't negotiated for dual funded channels, both
+	// parties derive it from the total capacity of the channel.
+	ourDustLimit := reservation.OurContribution().DustLimit
+	maxDustLimit := ourDustLimit
+	if msg.DustLimit > maxDustLimit {
+		maxDustLimit = msg.DustLimit
+	}
+	ourReserve := f.cfg.RequiredRemoteChanReserve(capacity, maxDustLimit)
+	chanReserve := ourReserve
+	if acceptorResp.Reserve != 0 {
+		chanReserve = acceptorResp.Reserve
+	}
+
+	// We'll also validate and apply all the constraints the opener is
+	// attempting to dictate for our commitment transaction.
+	channelConstraints := &channeldb.ChannelConstraints{
+		DustLimit:        msg.DustLimit,
+		ChanReserve:      ourReserve,
+		MaxPendingAmount: msg.MaxValueInFlight,
+		MinHTLC:          msg.HtlcMinimum,
+		MaxAcceptedHtlcs: msg.MaxAcceptedHTLCs,
+		CsvDelay:         msg.CsvDelay,
+	}
+	err = reservation.CommitConstraints(
+		channelConstraints, f.cfg.MaxLocalCSVDelay, true,
+	)
+	if err != nil {
+		log.Errorf("Unacceptable channel constraints: %v", err)
+		f.cancelDualFundReservation(peer, pendingChanID, reservation, err)
+		return
+	}
+
+	shutdown, err := getUpfrontShutdownScript(
+		f.cfg.EnableUpfrontShutdown, peer, acceptorResp.UpfrontShutdown,
+		func() (lnwire.DeliveryAddress, error) {
+			addr, err := f.cfg.Wallet.NewAddress(
+				lnwallet.WitnessPubKey, false,
+				lnwallet.DefaultAccountName,
+			)
+			if err != nil {
+				return nil, err
+			}
+			return txscript.PayToAddrScript(addr)
+		},
+	)
+	if err != nil {
+		err := errors.Errorf("getUpfrontShutdownScript error: %v", err)
+		f.cancelDualFundReservation(peer, pendingChanID, reservation, err)
+		return
+	}
+	reservation.SetOurUpfrontShutdown(shutdown)
+
+	// Generate our required constraints for the remote party, using the
+	// values provided by the channel acceptor if they are non-zero.
+	remoteCsvDelay := f.cfg.RequiredRemoteDelay(capacity)
+	if acceptorResp.CSVDelay != 0 {
+		remoteCsvDelay = acceptorResp.CSVDelay
+	}
+	remoteMaxValue := f.cfg.RequiredRemoteMaxValue(capacity)
+	if acceptorResp.InFlightTotal != 0 {
+		remoteMaxValue = acceptorResp.InFlightTotal
+	}
+	maxHtlcs := f.cfg.RequiredRemoteMaxHTLCs(capacity)
+	if acceptorResp.HtlcLimit != 0 {
+		maxHtlcs = acceptorResp.HtlcLimit
+	}
+	minHtlc := f.cfg.DefaultMinHtlcIn
+	if acceptorResp.MinHtlcIn != 0 {
+		minHtlc = acceptorResp.MinHtlcIn
+	}
+
+	// If we contribute funds in response to a liquidity request, we'll
+	// commit to our lease rates by signing them with our node key.
+	var willFund *lnwire.WillFund
+	if msg.RequestFunds != nil && ourAmt != 0 {
+		willFund, err = f.signLeaseRates(
+			msg.FundingKey, msg.RequestFunds.BlockHeight,
+		)
+		if err != nil {
+			log.Errorf("Unable to sign lease rates: %v", err)
+			f.cancelDualFundReservation(
+				peer, pendingChanID, reservation, err,
+			)
+			return
+		}
+	}
+
+	intent, ok := reservation.DualFundIntent()
+	if !ok {
+		err := errors.New("reservation is not dual funded")
+		f.cancelDualFundReservation(peer, pendingChanID, reservation, err)
+		return
+	}
+
+	// With the opener's funding key known, we can add our inputs and
+	// outputs to the funding transaction. They're announced to the opener
+	// once it started the construction.
+	session, err := reservation.StartInteractiveTx(msg.FundingKey)
+	if err != nil {
+		log.Errorf("Unable to start interactive tx: %v", err)
+		f.cancelDualFundReservation(peer, pendingChanID, reservation, err)
+		return
+	}
+
+	dualFund := &dualFundCtx{
+		feeRate:          chainfee.SatPerKWeight(msg.FundingFeePerKWeight),
+		remoteFundingAmt: msg.FundingAmount,
+		remoteContribution: &lnwallet.ChannelContribution{
+			FundingAmount:        msg.FundingAmount,
+			FirstCommitmentPoint: msg.FirstCommitmentPoint,
+			ChannelConfig: &channeldb.ChannelConfig{
+				ChannelConstraints: channeldb.ChannelConstraints{
+					DustLimit:        msg.DustLimit,
+					MaxPendingAmount: remoteMaxValue,
+					ChanReserve:      chanReserve,
+					MinHTLC:          minHtlc,
+					MaxAcceptedHtlcs: maxHtlcs,
+					CsvDelay:         remoteCsvDelay,
+				},
+				MultiSigKey: keychain.KeyDescriptor{
+					PubKey: copyPubKey(msg.FundingKey),
+				},
+				RevocationBasePoint: keychain.KeyDescriptor{
+					PubKey: copyPubKey(msg.RevocationPoint),
+				},
+				PaymentBasePoint: keychain.KeyDescriptor{
+					PubKey: copyPubKey(msg.PaymentPoint),
+				},
+				DelayBasePoint: keychain.KeyDescriptor{
+					PubKey: copyPubKey(msg.DelayedPaymentPoint),
+				},
+				HtlcBasePoint: keychain.KeyDescriptor{
+					PubKey: copyPubKey(msg.HtlcPoint),
+				},
+			},
+			UpfrontShutdown: msg.UpfrontShutdownScript,
+		},
+		intent: intent,
+	}
+	dualFund.startSession(lnwire.ChannelID(pendingChanID), session)
+
+	// Once the reservation has been created successfully, we add it to
+	// this peer's map of pending reservations to track this particular
+	// reservation until either abort or completion.
+	f.resMtx.Lock()
+	if _, ok := f.activeReservations[peerIDKey]; !ok {
+		f.activeReservations[peerIDKey] = make(pendingChannels)
+	}
+	resCtx := &reservationWithCtx{
+		reservation:    reservation,
+		chanAmt:        capacity,
+		remoteCsvDelay: remoteCsvDelay,
+		remoteMinHtlc:  minHtlc,
+		remoteMaxValue: remoteMaxValue,
+		remoteMaxHtlcs: maxHtlcs,
+		maxLocalCsv:    f.cfg.MaxLocalCSVDelay,
+		channelType:    msg.ChannelType,
+		dualFund:       dualFund,
+		err:            make(chan error, 1),
+		peer:           peer,
+	}
+	f.activeReservations[peerIDKey][pendingChanID] = resCtx
+	f.resMtx.Unlock()
+
+	// Update the timestamp once the open message has been handled.
+	defer resCtx.updateTimestamp()
+
+	log.Infof("Sending dual fundingResp for pending_id(%x)", pendingChanID)
+
+	ourContribution := reservation.OurContribution()
+	fundingAccept := &lnwire.AcceptChannel2{
+		PendingChannelID:      pendingChanID,
+		FundingAmount:         ourAmt,
+		DustLimit:             ourDustLimit,
+		MaxValueInFlight:      remoteMaxValue,
+		HtlcMinimum:           minHtlc,
+		MinAcceptDepth:        uint32(numConfsReq),
+		CsvDelay:              remoteCsvDelay,
+		MaxAcceptedHTLCs:      maxHtlcs,
+		FundingKey:            ourContribution.MultiSigKey.PubKey,
+		RevocationPoint:       ourContribution.RevocationBasePoint.PubKey,
+		PaymentPoint:          ourContribution.PaymentBasePoint.PubKey,
+		DelayedPaymentPoint:   ourContribution.DelayBasePoint.PubKey,
+		HtlcPoint:             ourContribution.HtlcBasePoint.PubKey,
+		FirstCommitmentPoint:  ourContribution.FirstCommitmentPoint,
+		UpfrontShutdownScript: ourContribution.UpfrontShutdown,
+		ChannelType:           chanTypeFeatureBits,
+		WillFund:              willFund,
+	}
+	if err := peer.SendMessage(true, fundingAccept); err != nil {
+		log.Errorf("unable to send dual funding response to peer: %v",
+			err)
+		f.failFundingFlow(peer, pendingChanID, err)
+		return
+	}
+}
+
+// cancelDualFundReservation cancels a dual funded reservation that hasn't
+// been added to the set of active reservations yet, and fails the funding
+// flow with the remote peer.
+func (f *Manager) cancelDualFundReservation(peer lnpeer.Peer,
+	pendingChanID [32]byte, reservation *lnwallet.ChannelReservation,
+	fundingErr error) {
+
+	if err := reservation.Cancel(); err != nil {
+		log.Errorf("unable to cancel reservation: %v", err)
+	}
+
+	f.failFundingFlow(peer, pendingChanID, fundingErr)
+}
+
+// signLeaseRates commits to our lease rates for a lease starting at the given
+// height, whose funds are locked to the given funding key of the opener.
+func (f *Manager) signLeaseRates(fundingKey *btcec.PublicKey,
+	blockHeight uint32) (*lnwire.WillFund, error) {
+
+	digest := f.cfg.LeaseRates.SigDigest(fundingKey, blockHeight)
+	sig, err := f.cfg.SignMessage(f.cfg.IDKeyLoc, digest[:], false)
+	if err != nil {
+		return nil, err
+	}
+
+	wireSig, err := lnwire.NewSigFromSignature(sig)
+	if err != nil {
+		return nil, err
+	}
+
+	return &lnwire.WillFund{
+		Signature:  wireSig,
+		LeaseRates: *f.cfg.LeaseRates,
+	}, nil
+}
+
+// verifyLeaseFee checks the lease rates the remote peer committed to for the
+// liquidity we requested, and returns the lease fee we pay for its
+// contribution.
+func verifyLeaseFee(peerKey, fundingKey *btcec.PublicKey,
+	dualFund *dualFundCtx, remoteAmt bronutil.Amount,
+	willFund *lnwire.WillFund) (bronutil.Amount, error) {
+
+	switch {
+	// If we didn't request any liquidity, the remote peer contributes on
+	// its own behalf and there's no lease fee to pay.
+	case dualFund.requestAmt == 0:
+		if willFund != nil {
+			return 0, errors.New("lease rates sent without request")
+		}
+
+		return 0, nil
+
+	// The remote peer may decline our request by not contributing at all.
+	case remoteAmt == 0:
+		return 0, nil
+
+	case willFund == nil:
+		return 0, errors.New("remote contribution without lease rates")
+	}
+
+	digest := willFund.LeaseRates.SigDigest(fundingKey, dualFund.leaseHeight)
+	sig, err := willFund.Signature.ToSignature()
+	if err != nil {
+		return 0, err
+	}
+	if !sig.Verify(digest[:], peerKey) {
+		return 0, errors.New("invalid lease rates signature")
+	}
+
+	leaseFee := willFund.LeaseRates.LeaseFee(
+		remoteAmt, uint32(dualFund.feeRate),
+	)
+	if leaseFee > dualFund.maxLeaseFee {
+		return 0, errors.Errorf("lease fee %v exceeds maximum of %v",
+			leaseFee, dualFund.maxLeaseFee)
+	}
+
+	return leaseFee, nil
+}
+
+// handleAcceptChannel2 processes the response of the remote peer to a dual
+// funded channel we opened. Once the contribution of the remote peer has been
+// recorded, we start the interactive construction of the funding transaction
+// by announcing our first input or output.
+func (f *Manager) handleAcceptChannel2(peer lnpeer.Peer,
+	msg *lnwire.AcceptChannel2) {
+
+	pendingChanID := msg.PendingChannelID
+	peerKey := peer.IdentityKey()
+
+	resCtx, err := f.getReservationCtx(peerKey, pendingChanID)
+	if err != nil || resCtx.dualFund == nil ||
+		resCtx.dualFund.remoteContribution != nil {
+
+		log.Warnf("Can't find dual funded reservation (peerKey:%v, "+
+			"chan_id:%x)", peerKey, pendingChanID)
+		return
+	}
+	dualFund := resCtx.dualFund
+
+	// Update the timestamp once the accept message has been handled.
+	defer resCtx.updateTimestamp()
+
+	log.Infof("Recv'd dual fundingResponse for pending_id(%x)",
+		pendingChanID[:])
+
+	// The channel type must be echoed back if we proposed one explicitly.
+	if resCtx.channelType != nil {
+		if msg.ChannelType == nil {
+			err := errors.New("explicit channel type not echoed back")
+			f.failFundingFlow(peer, pendingChanID, err)
+			return
+		}
+		proposedFeatures := lnwire.RawFeatureVector(*resCtx.channelType)
+		ackedFeatures := lnwire.RawFeatureVector(*msg.ChannelType)
+		if !proposedFeatures.Equals(&ackedFeatures) {
+			err := errors.New("channel type mismatch")
+			f.failFundingFlow(peer, pendingChanID, err)
+			return
+		}
+	}
+
+	// The required number of confirmations should not be greater than the
+	// maximum number of confirmations required by the ChainNotifier to
+	// properly dispatch confirmations.
+	if msg.MinAcceptDepth > chainntnfs.MaxNumConfs {
+		err := lnwallet.ErrNumConfsTooLarge(
+			msg.MinAcceptDepth, chainntnfs.MaxNumConfs,
+		)
+		log.Warnf("Unacceptable channel constraints: %v", err)
+		f.failFundingFlow(peer, pendingChanID, err)
+		return
+	}
+
+	// A zero-conf channel requires the responder to not require any
+	// confirmations, while any other channel needs at least one.
+	isZeroConf := resCtx.reservation.IsZeroConf()
+	if isZeroConf != (msg.MinAcceptDepth == 0) {
+		err := errors.Errorf("min_depth %v doesn't match zero-conf "+
+			"channel type", msg.MinAcceptDepth)
+		log.Warnf("Unacceptable channel constraints: %v", err)
+		f.failFundingFlow(peer, pendingChanID, err)
+		return
+	}
+
+	ourContribution := resCtx.reservation.OurContribution()
+	leaseFee, err := verifyLeaseFee(
+		peerKey, ourContribution.MultiSigKey.PubKey, dualFund,
+		msg.FundingAmount, msg.WillFund,
+	)
+	if err != nil {
+		log.Warnf("Unacceptable lease: %v", err)
+		f.failFundingFlow(peer, pendingChanID, err)
+		return
+	}
+
+	// Now that we know the contribution of the remote peer, we can update
+	// the capacity of the channel and pay the lease fee in the initial
+	// commitment state.
+	err = resCtx.reservation.SetRemoteFunding(msg.FundingAmount, leaseFee)
+	if err != nil {
+		f.failFundingFlow(peer, pendingChanID, err)
+		return
+	}
+	capacity := resCtx.reservation.Capacity()
+	resCtx.chanAmt = capacity
+
+	log.Infof("pendingChan(%x): remote party contributes %v with "+
+		"lease_fee=%v, capacity=%v", pendingChanID[:],
+		msg.FundingAmount, leaseFee, capacity)
+
+	// The channel reserve isn't negotiated for dual funded channels, both
+	// parties derive it from the total capacity of the channel.
+	maxDustLimit := ourContribution.DustLimit
+	if msg.DustLimit > maxDustLimit {
+		maxDustLimit = msg.DustLimit
+	}
+	chanReserve := f.cfg.RequiredRemoteChanReserve(capacity, maxDustLimit)
+
+	resCtx.reservation.SetNumConfsRequired(uint16(msg.MinAcceptDepth))
+	channelConstraints := &channeldb.ChannelConstraints{
+		DustLimit:        msg.DustLimit,
+		ChanReserve:      chanReserve,
+		MaxPendingAmount: msg.MaxValueInFlight,
+		MinHTLC:          msg.HtlcMinimum,
+		MaxAcceptedHtlcs: msg.MaxAcceptedHTLCs,
+		CsvDelay:         msg.CsvDelay,
+	}
+	err = resCtx.reservation.CommitConstraints(
+		channelConstraints, resCtx.maxLocalCsv, false,
+	)
+	if err != nil {
+		log.Warnf("Unacceptable channel constraints: %v", err)
+		f.failFundingFlow(peer, pendingChanID, err)
+		return
+	}
+
+	intent, ok := resCtx.reservation.DualFundIntent()
+	if !ok {
+		err := errors.New("reservation is not dual funded")
+		f.failFundingFlow(peer, pendingChanID, err)
+		return
+	}
+
+	// With the funding key of the remote peer known, we can add our inputs
+	// and outputs, including the funding output, to the funding
+	// transaction.
+	session, err := resCtx.reservation.StartInteractiveTx(msg.FundingKey)
+	if err != nil {
+		log.Errorf("Unable to start interactive tx: %v", err)
+		f.failFundingFlow(peer, pendingChanID, err)
+		return
+	}
+
+	dualFund.intent = intent
+	dualFund.remoteFundingAmt = msg.FundingAmount
+	dualFund.remoteContribution = &lnwallet.ChannelContribution{
+		FundingAmount:        msg.FundingAmount,
+		FirstCommitmentPoint: msg.FirstCommitmentPoint,
+		ChannelConfig: &channeldb.ChannelConfig{
+			ChannelConstraints: channeldb.ChannelConstraints{
+				DustLimit:        msg.DustLimit,
+				MaxPendingAmount: resCtx.remoteMaxValue,
+				ChanReserve:      chanReserve,
+				MinHTLC:          resCtx.remoteMinHtlc,
+				MaxAcceptedHtlcs: resCtx.remoteMaxHtlcs,
+				CsvDelay:         resCtx.remoteCsvDelay,
+			},
+			MultiSigKey: keychain.KeyDescriptor{
+				PubKey: copyPubKey(msg.FundingKey),
+			},
+			RevocationBasePoint: keychain.KeyDescriptor{
+				PubKey: copyPubKey(msg.RevocationPoint),
+			},
+			PaymentBasePoint: keychain.KeyDescriptor{
+				PubKey: copyPubKey(msg.PaymentPoint),
+			},
+			DelayBasePoint: keychain.KeyDescriptor{
+				PubKey: copyPubKey(msg.DelayedPaymentPoint),
+			},
+			HtlcBasePoint: keychain.KeyDescriptor{
+				PubKey: copyPubKey(msg.HtlcPoint),
+			},
+		},
+		UpfrontShutdown: msg.UpfrontShutdownScript,
+	}
+	dualFund.startSession(lnwire.ChannelID(pendingChanID), session)
+
+	// As the opener, we send the first message of the construction.
+	dualFund.ourTurn = true
+	f.sendNextInteractiveMsg(resCtx, pendingChanID)
+}
+
+// handleInteractiveTxMsg processes a message of the remote peer that adds or
+// removes an input or output of the funding transaction of a dual funded
+// channel, or signals that the remote peer completed its contribution. Every
+// message is answered by one of our own, until both parties sent tx_complete
+// in a row.
+func (f *Manager) handleInteractiveTxMsg(peer lnpeer.Peer,
+	msg lnwire.Message) {
+
+	var chanID lnwire.ChannelID
+	switch msg := msg.(type) {
+	case *lnwire.TxAddInput:
+		chanID = msg.ChanID
+	case *lnwire.TxAddOutput:
+		chanID = msg.ChanID
+	case *lnwire.TxRemoveInput:
+		chanID = msg.ChanID
+	case *lnwire.TxRemoveOutput:
+		chanID = msg.ChanID
+	case *lnwire.TxComplete:
+		chanID = msg.ChanID
+	}
+
+	// During the construction of the funding transaction, the channel is
+	// referenced by its pending channel ID.
+	pendingChanID := [32]byte(chanID)
+	peerKey := peer.IdentityKey()
+	resCtx, err := f.getReservationCtx(peerKey, pendingChanID)
+	if err != nil || resCtx.dualFund == nil ||
+		resCtx.dualFund.session == nil {
+
+		log.Warnf("Can't find interactive tx (peerKey:%v, chan_id:%x)",
+			peerKey, pendingChanID)
+		return
+	}
+	dualFund := resCtx.dualFund
+
+	// Update the timestamp once the message has been handled.
+	defer resCtx.updateTimestamp()
+
+	if dualFund.ourTurn || dualFund.txComplete {
+		err := errors.Errorf("unexpected %v message", msg.MsgType())
+		f.failFundingFlow(peer, pendingChanID, err)
+		return
+	}
+
+	session := dualFund.session
+	switch msg := msg.(type) {
+	case *lnwire.TxAddInput:
+		err = session.AddRemoteInput(
+			msg.SerialID, msg.PrevTx, msg.PrevTxOut, msg.Sequence,
+		)
+
+	case *lnwire.TxAddOutput:
+		err = session.AddRemoteOutput(
+			msg.SerialID, msg.Amount, msg.PkScript,
+		)
+
+	case *lnwire.TxRemoveInput:
+		err = session.RemoveRemoteInput(msg.SerialID)
+
+	case *lnwire.TxRemoveOutput:
+		err = session.RemoveRemoteOutput(msg.SerialID)
+
+	case *lnwire.TxComplete:
+		dualFund.remoteComplete = true
+	}
+	if err != nil {
+		log.Warnf("Invalid interactive tx message for pending_id(%x): "+
+			"%v", pendingChanID, err)
+		f.failFundingFlow(peer, pendingChanID, err)
+		return
+	}
+
+	// Any change to the transaction requires both parties to complete the
+	// construction again.
+	if msg.MsgType() != lnwire.MsgTxComplete {
+		dualFund.localComplete = false
+		dualFund.remoteComplete = false
+	}
+
+	// If the remote peer completed the construction right after we did,
+	// the funding transaction is final.
+	if dualFund.localComplete && dualFund.remoteComplete {
+		f.finishInteractiveTx(resCtx, pendingChanID)
+		return
+	}
+
+	dualFund.ourTurn = true
+	f.sendNextInteractiveMsg(resCtx, pendingChanID)
+}
+
+// sendNextInteractiveMsg announces our next input or output to the remote
+// peer, or signals that we completed our contribution if there's nothing left
+// to announce.
+func (f *Manager) sendNextInteractiveMsg(resCtx *reservationWithCtx,
+	pendingChanID [32]byte) {
+
+	dualFund := resCtx.dualFund
+
+	var next lnwire.Message
+	if len(dualFund.pendingMsgs) > 0 {
+		next = dualFund.pendingMsgs[0]
+		dualFund.pendingMsgs = dualFund.pendingMsgs[1:]
+
+		dualFund.localComplete = false
+		dualFund.remoteComplete = false
+	} else {
+		next = &lnwire.TxComplete{
+			ChanID: lnwire.ChannelID(pendingChanID),
+		}
+		dualFund.localComplete = true
+	}
+
+	if err := resCtx.peer.SendMessage(true, next); err != nil {
+		log.Errorf("Unable to send %v: %v", next.MsgType(), err)
+		f.failFundingFlow(resCtx.peer, pendingChanID, err)
+		return
+	}
+	dualFund.ourTurn = false
+
+	// If we completed the construction right after the remote peer did,
+	// the funding transaction is final.
+	if dualFund.localComplete && dualFund.remoteComplete {
+		f.finishInteractiveTx(resCtx, pendingChanID)
+	}
+}
+
+// finishInteractiveTx is called once both parties completed the construction
+// of the funding transaction. It verifies the contribution of the remote peer,
+// then signs the remote peer's commitment transaction.
+func (f *Manager) finishInteractiveTx(resCtx *reservationWithCtx,
+	pendingChanID [32]byte) {
+
+	dualFund := resCtx.dualFund
+	peer := resCtx.peer
+
+	// The remote peer must pay for its own inputs and outputs, and its
+	// share of the funding output.
+	_, fundingOutput, err := dualFund.intent.FundingOutput()
+	if err != nil {
+		f.failFundingFlow(peer, pendingChanID, err)
+		return
+	}
+	err = dualFund.session.VerifyRemoteContribution(
+		dualFund.feeRate, fundingOutput.PkScript,
+		dualFund.remoteFundingAmt,
+	)
+	if err != nil {
+		log.Warnf("Invalid remote contribution for pending_id(%x): %v",
+			pendingChanID, err)
+		f.failFundingFlow(peer, pendingChanID, err)
+		return
+	}
+
+	// With the funding transaction final, the wallet can construct both
+	// commitment transactions and sign the one of the remote peer.
+	err = resCtx.reservation.ProcessContribution(
+		dualFund.remoteContribution,
+	)
+	if err != nil {
+		log.Errorf("Unable to process contribution from %v: %v",
+			peer.IdentityKey(), err)
+		f.failFundingFlow(peer, pendingChanID, err)
+		return
+	}
+	dualFund.txComplete = true
+
+	fundingPoint := resCtx.reservation.FundingOutpoint()
+	log.Infof("Interactive tx for pending_id(%x) complete, sending "+
+		"CommitSig over ChannelPoint(%v)", pendingChanID[:],
+		fundingPoint)
+
+	_, sig := resCtx.reservation.OurSignatures()
+	ourCommitSig, err := lnwire.NewSigFromSignature(sig)
+	if err != nil {
+		log.Errorf("Unable to parse signature: %v", err)
+		f.failFundingFlow(peer, pendingChanID, err)
+		return
+	}
+
+	commitSig := &lnwire.CommitSig{
+		ChanID:    lnwire.ChannelID(pendingChanID),
+		CommitSig: ourCommitSig,
+	}
+	if err := peer.SendMessage(true, commitSig); err != nil {
+		log.Errorf("Unable to send CommitSig message: %v", err)
+		f.failFundingFlow(peer, pendingChanID, err)
+		return
+	}
+}
+
+// handleDualFundCommitSig processes the signature of the remote peer for our
+// commitment transaction of a dual funded channel. Once verified, the channel
+// is marked pending in the database, and the witnesses for the funding
+// transaction are exchanged.
+func (f *Manager) handleDualFundCommitSig(peer lnpeer.Peer,
+	msg *lnwire.CommitSig) {
+
+	pendingChanID := [32]byte(msg.ChanID)
+	peerKey := peer.IdentityKey()
+
+	resCtx, err := f.getReservationCtx(peerKey, pendingChanID)
+	if err != nil || resCtx.dualFund == nil {
+		log.Warnf("Can't find dual funded reservation (peerKey:%v, "+
+			"chan_id:%x)", peerKey, pendingChanID)
+		return
+	}
+	dualFund := resCtx.dualFund
+
+	if !dualFund.txComplete {
+		err := errors.New("commitment signed before funding tx " +
+			"completed")
+		f.failFundingFlow(peer, pendingChanID, err)
+		return
+	}
+
+	commitSig, err := msg.CommitSig.ToSignature()
+	if err != nil {
+		log.Errorf("Unable to parse signature: %v", err)
+		f.failFundingFlow(peer, pendingChanID, err)
+		return
+	}
+
+	// The witnesses of the funding transaction are exchanged only after
+	// the channel is safely stored, so there's nothing to verify beyond
+	// the commitment signature at this point.
+	completeChan, err := resCtx.reservation.CompleteReservation(
+		nil, commitSig,
+	)
+	if err != nil {
+		log.Errorf("Unable to complete dual funded reservation: %v",
+			err)
+		f.failFundingFlow(peer, pendingChanID, err)
+		return
+	}
+
+	// The channel is marked IsPending in the database, and can be removed
+	// from the set of active reservations.
+	f.deleteReservationCtx(peerKey, pendingChanID)
+
+	fundingPoint := completeChan.FundingOutpoint
+	channelID := lnwire.NewChanIDFromOutPoint(&fundingPoint)
+
+	// The witnesses of our inputs are persisted before they're sent, so
+	// we're able to retransmit them until the funding transaction
+	// confirmed, even across restarts.
+	txSigs := &txSigsCtx{
+		chanPoint: fundingPoint,
+		peerKey:   peerKey,
+		signFirst: dualFund.signFirst(),
+		fundingTx: completeChan.FundingTxn,
+		done:      make(chan struct{}),
+	}
+	for _, in := range dualFund.session.Inputs() {
+		if !in.Local {
+			txSigs.remotePrevOuts = append(
+				txSigs.remotePrevOuts, in.PrevOut(),
+			)
+		}
+	}
+	if err := f.saveTxSigs(txSigs); err != nil {
+		log.Errorf("Unable to persist witnesses for "+
+			"ChannelPoint(%v): %v", fundingPoint, err)
+	}
+
+	f.resMtx.Lock()
+	f.pendingTxSigs[channelID] = txSigs
+	f.resMtx.Unlock()
+
+	// A new channel has almost finished the funding process. In order to
+	// properly synchronize with the writeHandler goroutine, we add a new
+	// channel to the barriers map which will be closed once the channel is
+	// fully open.
+	f.barrierMtx.Lock()
+	log.Debugf("Creating chan barrier for ChanID(%v)", channelID)
+	f.newChanBarriers[channelID] = make(chan struct{})
+	f.barrierMtx.Unlock()
+
+	// Create an entry in the local discovery map so we can ensure that we
+	// process the channel confirmation fully before we receive a funding
+	// locked message.
+	f.localDiscoveryMtx.Lock()
+	f.localDiscoverySignals[channelID] = make(chan struct{})
+	f.localDiscoveryMtx.Unlock()
+
+	if err := f.cfg.WatchNewChannel(completeChan, peerKey); err != nil {
+		log.Errorf("Unable to send new ChannelPoint(%v) for "+
+			"arbitration: %v", fundingPoint, err)
+	}
+
+	log.Infof("Finalizing dual funded pending_id(%x) over "+
+		"ChannelPoint(%v), waiting for channel open on-chain",
+		pendingChanID[:], fundingPoint)
+
+	// Send an update to the upstream client, if any, that the negotiation
+	// process is over.
+	if resCtx.updates != nil {
+		upd := &lnrpc.OpenStatusUpdate{
+			Update: &lnrpc.OpenStatusUpdate_ChanPending{
+				ChanPending: &lnrpc.PendingUpdate{
+					Txid:        fundingPoint.Hash[:],
+					OutputIndex: fundingPoint.Index,
+				},
+			},
+			PendingChanId: pendingChanID[:],
+		}
+
+		select {
+		case resCtx.updates <- upd:
+		case <-f.quit:
+			return
+		}
+	}
+
+	// Inform the ChannelNotifier that the channel has entered pending open
+	// state.
+	f.cfg.NotifyPendingOpenChannelEvent(fundingPoint, completeChan)
+
+	// If we're to sign the funding transaction first, we'll send our
+	// witnesses right away. Otherwise we wait for the remote peer's.
+	if txSigs.signFirst {
+		txSigs.mu.Lock()
+		f.sendTxSignatures(txSigs, peer)
+		txSigs.mu.Unlock()
+	}
+
+	f.wg.Add(2)
+	go f.retransmitTxSignatures(txSigs)
+	go f.advanceFundingState(completeChan, pendingChanID, resCtx.updates)
+}
+
+// sendTxSignatures sends the witnesses of our inputs to the funding
+// transaction of a dual funded channel to the remote peer.
+//
+// NOTE: The caller must hold the mutex of the txSigsCtx.
+func (f *Manager) sendTxSignatures(txSigs *txSigsCtx, peer lnpeer.Peer) {
+	chanID := lnwire.NewChanIDFromOutPoint(&txSigs.chanPoint)
+	msg := &lnwire.TxSignatures{
+		ChanID:    chanID,
+		TxHash:    txSigs.chanPoint.Hash,
+		Witnesses: localWitnesses(txSigs.fundingTx),
+	}
+	if err := peer.SendMessage(true, msg); err != nil {
+		log.Errorf("Unable to send TxSignatures for ChanID(%v): %v",
+			chanID, err)
+		return
+	}
+
+	txSigs.sentSigs = true
+}
+
+// retransmitTxSignatures resends our witnesses for the funding transaction of
+// a dual funded channel every time the remote peer reconnects, as it may
+// have missed them, until the funding transaction confirmed. If we're to
+// sign second, they're only resent once we received the remote peer's.
+//
+// NOTE: This MUST be run as a goroutine.
+func (f *Manager) retransmitTxSignatures(txSigs *txSigsCtx) {
+	defer f.wg.Done()
+
+	var peerKey [33]byte
+	copy(peerKey[:], txSigs.peerKey.SerializeCompressed())
+
+	for {
+		select {
+		case <-f.cfg.NotifyWhenOffline(peerKey):
+		case <-txSigs.done:
+			return
+		case <-f.quit:
+			return
+		}
+
+		peerChan := make(chan lnpeer.Peer, 1)
+		f.cfg.NotifyWhenOnline(peerKey, peerChan)
+
+		var peer lnpeer.Peer
+		select {
+		case peer = <-peerChan:
+		case <-txSigs.done:
+			return
+		case <-f.quit:
+			return
+		}
+
+		txSigs.mu.Lock()
+		if txSigs.sentSigs {
+			log.Debugf("Retransmitting TxSignatures for "+
+				"ChannelPoint(%v)", txSigs.chanPoint)
+
+			f.sendTxSignatures(txSigs, peer)
+		}
+		txSigs.mu.Unlock()
+	}
+}
+
+// restoreTxSigs resumes the exchange of witnesses for the funding transaction
+// of the given pending dual funded channel after a restart, if it was still
+// in progress. If we already received the witnesses of the remote peer, the
+// funding transaction is rebroadcast.
+func (f *Manager) restoreTxSigs(channel *channeldb.OpenChannel) error {
+	txSigs, err := f.fetchTxSigs(&channel.FundingOutpoint)
+	if err == channeldb.ErrChannelNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	txSigs.chanPoint = channel.FundingOutpoint
+	txSigs.peerKey = channel.IdentityPub
+
+	chanID := lnwire.NewChanIDFromOutPoint(&channel.FundingOutpoint)
+	f.resMtx.Lock()
+	f.pendingTxSigs[chanID] = txSigs
+	f.resMtx.Unlock()
+
+	f.wg.Add(1)
+	go f.retransmitTxSignatures(txSigs)
+
+	if txSigs.signedTx != nil {
+		f.publishDualFundingTx(txSigs)
+	}
+
+	return nil
+}
+
+// finishTxSigs ends the exchange of witnesses for the funding transaction of
+// a dual funded channel once the funding transaction confirmed.
+func (f *Manager) finishTxSigs(chanPoint *wire.OutPoint) {
+	chanID := lnwire.NewChanIDFromOutPoint(chanPoint)
+
+	f.resMtx.Lock()
+	txSigs, ok := f.pendingTxSigs[chanID]
+	delete(f.pendingTxSigs, chanID)
+	f.resMtx.Unlock()
+	if !ok {
+		return
+	}
+
+	close(txSigs.done)
+
+	if err := f.deleteTxSigs(chanPoint); err != nil {
+		log.Errorf("Unable to delete witnesses for ChannelPoint(%v): "+
+			"%v", chanPoint, err)
+	}
+}
+
+// txSigsKey returns the key under which the state of the exchange of
+// witnesses for the funding transaction of a dual funded channel is stored in
+// the channel opening state database.
+func txSigsKey(chanPoint *wire.OutPoint) ([]byte, error) {
+	var key bytes.Buffer
+	key.Write(txSigsKeyPrefix)
+	if err := WriteOutpoint(&key, chanPoint); err != nil {
+		return nil, err
+	}
+
+	return key.Bytes(), nil
+}
+
+// saveTxSigs persists the state of the exchange of witnesses for the funding
+// transaction of a dual funded channel.
+func (f *Manager) saveTxSigs(txSigs *txSigsCtx) error {
+	key, err := txSigsKey(&txSigs.chanPoint)
+	if err != nil {
+		return err
+	}
+
+	var value bytes.Buffer
+	if err := txSigs.encode(&value); err != nil {
+		return err
+	}
+
+	return f.cfg.Wallet.Cfg.Database.SaveChannelOpeningState(
+		key, value.Bytes(),
+	)
+}
+
+// fetchTxSigs fetches the state of the exchange of witnesses for the funding
+// transaction of a dual funded channel, or returns ErrChannelNotFound if it
+// isn't found.
+func (f *Manager) fetchTxSigs(chanPoint *wire.OutPoint) (*txSigsCtx, error) {
+	key, err := txSigsKey(chanPoint)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := f.cfg.Wallet.Cfg.Database.GetChannelOpeningState(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeTxSigsCtx(value)
+}
+
+// deleteTxSigs removes the state of the exchange of witnesses for the funding
+// transaction of a dual funded channel from the database.
+func (f *Manager) deleteTxSigs(chanPoint *wire.OutPoint) error {
+	key, err := txSigsKey(chanPoint)
+	if err != nil {
+		return err
+	}
+
+	return f.cfg.Wallet.Cfg.Database.DeleteChannelOpeningState(key)
+}
+
+// handleTxSignatures processes the witnesses of the remote peer for its inputs
+// to the funding transaction of a dual funded channel. Once verified, we send
+// our own witnesses if we haven't yet, and publish the funding transaction.
+func (f *Manager) handleTxSignatures(peer lnpeer.Peer,
+	msg *lnwire.TxSignatures) {
+
+	f.resMtx.Lock()
+	txSigs, ok := f.pendingTxSigs[msg.ChanID]
+	f.resMtx.Unlock()
+	if !ok || !txSigs.peerKey.IsEqual(peer.IdentityKey()) {
+		log.Warnf("Unexpected TxSignatures for ChanID(%v)", msg.ChanID)
+		return
+	}
+
+	txSigs.mu.Lock()
+	defer txSigs.mu.Unlock()
+
+	// The remote peer retransmits its witnesses when it reconnects, which
+	// we may have processed already.
+	if txSigs.signedTx != nil {
+		log.Debugf("Ignoring retransmitted TxSignatures for "+
+			"ChanID(%v)", msg.ChanID)
+		return
+	}
+
+	fundingPoint := txSigs.chanPoint
+	if msg.TxHash != fundingPoint.Hash {
+		log.Errorf("TxSignatures for ChanID(%v) reference tx %v, "+
+			"expected %v", msg.ChanID, msg.TxHash, fundingPoint.Hash)
+		return
+	}
+
+	fundingTx, err := addRemoteWitnesses(
+		txSigs.fundingTx, txSigs.remotePrevOuts, msg.Witnesses,
+	)
+	if err != nil {
+		log.Errorf("Invalid TxSignatures for ChanID(%v): %v",
+			msg.ChanID, err)
+		return
+	}
+
+	// We persist the fully signed funding transaction, so it is
+	// rebroadcast on restart.
+	txSigs.signedTx = fundingTx
+	if err := f.saveTxSigs(txSigs); err != nil {
+		log.Errorf("Unable to persist funding tx for "+
+			"ChannelPoint(%v): %v", fundingPoint, err)
+	}
+
+	if !txSigs.sentSigs {
+		f.sendTxSignatures(txSigs, peer)
+	}
+
+	f.publishDualFundingTx(txSigs)
+}
+
+// publishDualFundingTx publishes the fully signed funding transaction of a
+// dual funded channel.
+func (f *Manager) publishDualFundingTx(txSigs *txSigsCtx) {
+	fundingTx := txSigs.signedTx
+	fundingPoint := txSigs.chanPoint
+
+	var fundingTxBuf bytes.Buffer
+	if err := fundingTx.Serialize(&fundingTxBuf); err != nil {
+		log.Errorf("Unable to serialize funding transaction %v: %v",
+			fundingTx.TxHash(), err)
+		fundingTxBuf.Reset()
+	}
+
+	log.Infof("Broadcasting dual funded funding tx for "+
+		"ChannelPoint(%v): %x", fundingPoint, fundingTxBuf.Bytes())
+	log.Tracef("Funding tx for ChannelPoint(%v): %v", fundingPoint,
+		spew.Sdump(fundingTx))
+
+	// Set a nil short channel ID at this stage because we do not know it
+	// until our funding tx confirms.
+	label := labels.MakeLabel(labels.LabelTypeChannelOpen, nil)
+	if err := f.cfg.PublishTransaction(fundingTx, label); err != nil {
+		log.Errorf("Unable to broadcast funding tx %x for "+
+			"ChannelPoint(%v): %v", fundingTxBuf.Bytes(),
+			fundingPoint, err)
+	}
+}
+
+// handleTxAbort processes the request of the remote peer to abort the
+// construction of the funding transaction of a dual funded channel. We
+// acknowledge it by echoing the abort, then cancel the reservation.
+func (f *Manager) handleTxAbort(peer lnpeer.Peer, msg *lnwire.TxAbort) {
+	pendingChanID := [32]byte(msg.ChanID)
+	peerKey := peer.IdentityKey()
+
+	resCtx, err := f.cancelReservationCtx(peerKey, pendingChanID, true)
+	if err != nil {
+		log.Warnf("Received tx_abort for non-existent funding flow: "+
+			"%v (%v)", err, msg.Error())
+		return
+	}
+
+	ack := &lnwire.TxAbort{
+		ChanID: msg.ChanID,
+		Data:   lnwire.ErrorData("acknowledged"),
+	}
+	if err := peer.SendMessage(false, ack); err != nil {
+		log.Errorf("Unable to acknowledge tx_abort: %v", err)
+	}
+
+	fundingErr := errors.Errorf("received tx_abort from %x: %v",
+		peerKey.SerializeCompressed(), msg.Error())
+	log.Errorf(fundingErr.Error())
+
+	resCtx.err <- fundingErr
+}
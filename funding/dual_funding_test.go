@@ -0,0 +1,262 @@
+package funding
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/brsuite/brond/btcec"
+	"github.com/brsuite/brond/txscript"
+	"github.com/brsuite/brond/wire"
+	"github.com/brsuite/bronutil"
+	"github.com/brsuite/broln/lnwire"
+	"github.com/stretchr/testify/require"
+)
+
+// TestVerifyLeaseFee asserts that the lease rates committed to by the remote
+// party of a dual funded channel are only accepted if they're signed by its
+// node key and the resulting lease fee is within our budget.
+func TestVerifyLeaseFee(t *testing.T) {
+	t.Parallel()
+
+	nodeKey, err := btcec.NewPrivateKey(btcec.S256())
+	require.NoError(t, err)
+	fundingKey, err := btcec.NewPrivateKey(btcec.S256())
+	require.NoError(t, err)
+
+	rates := lnwire.LeaseRates{
+		FundingWeight:   500,
+		LeaseFeeBasis:   100,
+		LeaseFeeBaseSat: 1000,
+	}
+
+	const leaseHeight = 100
+	signRates := func(height uint32) *lnwire.WillFund {
+		digest := rates.SigDigest(fundingKey.PubKey(), height)
+		sig, err := nodeKey.Sign(digest[:])
+		require.NoError(t, err)
+
+		wireSig, err := lnwire.NewSigFromSignature(sig)
+		require.NoError(t, err)
+
+		return &lnwire.WillFund{
+			Signature:  wireSig,
+			LeaseRates: rates,
+		}
+	}
+
+	// With a fee rate of 1000 sat/kw, leasing 100k sat costs the base fee,
+	// 1% of the amount and the funding weight.
+	const (
+		remoteAmt   bronutil.Amount = 100_000
+		expectedFee bronutil.Amount = 1000 + 1000 + 500
+	)
+
+	testCases := []struct {
+		name        string
+		requestAmt  bronutil.Amount
+		maxLeaseFee bronutil.Amount
+		remoteAmt   bronutil.Amount
+		willFund    *lnwire.WillFund
+		expectedFee bronutil.Amount
+		expectErr   bool
+	}{
+		{
+			name:      "no request",
+			remoteAmt: remoteAmt,
+		},
+		{
+			name:      "unrequested lease",
+			remoteAmt: remoteAmt,
+			willFund:  signRates(leaseHeight),
+			expectErr: true,
+		},
+		{
+			name:        "declined request",
+			requestAmt:  remoteAmt,
+			maxLeaseFee: expectedFee,
+		},
+		{
+			name:        "missing lease rates",
+			requestAmt:  remoteAmt,
+			maxLeaseFee: expectedFee,
+			remoteAmt:   remoteAmt,
+			expectErr:   true,
+		},
+		{
+			name:        "valid lease",
+			requestAmt:  remoteAmt,
+			maxLeaseFee: expectedFee,
+			remoteAmt:   remoteAmt,
+			willFund:    signRates(leaseHeight),
+			expectedFee: expectedFee,
+		},
+		{
+			name:        "wrong lease height",
+			requestAmt:  remoteAmt,
+			maxLeaseFee: expectedFee,
+			remoteAmt:   remoteAmt,
+			willFund:    signRates(leaseHeight + 1),
+			expectErr:   true,
+		},
+		{
+			name:        "lease fee too high",
+			requestAmt:  remoteAmt,
+			maxLeaseFee: expectedFee - 1,
+			remoteAmt:   remoteAmt,
+			willFund:    signRates(leaseHeight),
+			expectErr:   true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			dualFund := &dualFundCtx{
+				feeRate:     1000,
+				requestAmt:  testCase.requestAmt,
+				maxLeaseFee: testCase.maxLeaseFee,
+				leaseHeight: leaseHeight,
+			}
+
+			fee, err := verifyLeaseFee(
+				nodeKey.PubKey(), fundingKey.PubKey(), dualFund,
+				testCase.remoteAmt, testCase.willFund,
+			)
+			if testCase.expectErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, testCase.expectedFee, fee)
+		})
+	}
+}
+
+// newTestFundingTx returns a funding transaction with a signed input of ours
+// followed by an unsigned input of the remote party, which spends the
+// returned p2wkh output with the returned key.
+func newTestFundingTx(t *testing.T) (*wire.MsgTx, *wire.TxOut,
+	*btcec.PrivateKey) {
+
+	remoteKey, err := btcec.NewPrivateKey(btcec.S256())
+	require.NoError(t, err)
+
+	pubKeyHash := bronutil.Hash160(remoteKey.PubKey().SerializeCompressed())
+	pkScript, err := txscript.NewScriptBuilder().
+		AddOp(txscript.OP_0).
+		AddData(pubKeyHash).
+		Script()
+	require.NoError(t, err)
+
+	fundingTx := wire.NewMsgTx(2)
+	fundingTx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Index: 1},
+		Witness:          wire.TxWitness{[]byte{1}, []byte{2}},
+	})
+	fundingTx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Index: 2},
+	})
+	fundingTx.AddTxOut(wire.NewTxOut(100_000, []byte{txscript.OP_TRUE}))
+
+	return fundingTx, wire.NewTxOut(60_000, pkScript), remoteKey
+}
+
+// TestAddRemoteWitnesses asserts that the witnesses of the remote party are
+// attached to its inputs of the funding transaction only if they're valid.
+func TestAddRemoteWitnesses(t *testing.T) {
+	t.Parallel()
+
+	fundingTx, prevOut, remoteKey := newTestFundingTx(t)
+	prevOuts := []*wire.TxOut{prevOut}
+
+	require.Equal(
+		t, []wire.TxWitness{fundingTx.TxIn[0].Witness},
+		localWitnesses(fundingTx),
+	)
+
+	hashCache := txscript.NewTxSigHashes(fundingTx)
+	witness, err := txscript.WitnessSignature(
+		fundingTx, hashCache, 1, prevOut.Value, prevOut.PkScript,
+		txscript.SigHashAll, remoteKey, true,
+	)
+	require.NoError(t, err)
+
+	// The number of witnesses must match the inputs of the remote party.
+	_, err = addRemoteWitnesses(fundingTx, prevOuts, nil)
+	require.ErrorContains(t, err, "expected 1 witnesses")
+
+	// A witness that doesn't spend the input is rejected.
+	invalidWitness := wire.TxWitness{witness[0], witness[0]}
+	_, err = addRemoteWitnesses(
+		fundingTx, prevOuts, []wire.TxWitness{invalidWitness},
+	)
+	require.ErrorContains(t, err, "invalid witness for input 1")
+
+	signedTx, err := addRemoteWitnesses(
+		fundingTx, prevOuts, []wire.TxWitness{witness},
+	)
+	require.NoError(t, err)
+	require.Equal(t, witness, signedTx.TxIn[1].Witness)
+
+	// The funding transaction carrying only our witnesses is left
+	// untouched.
+	require.Empty(t, fundingTx.TxIn[1].Witness)
+}
+
+// TestTxSigsCtxEncoding asserts that the state of the exchange of witnesses
+// for the funding transaction of a dual funded channel survives a round trip
+// through its serialization.
+func TestTxSigsCtxEncoding(t *testing.T) {
+	t.Parallel()
+
+	fundingTx, prevOut, _ := newTestFundingTx(t)
+	txSigs := &txSigsCtx{
+		fundingTx:      fundingTx,
+		remotePrevOuts: []*wire.TxOut{prevOut},
+	}
+
+	var b bytes.Buffer
+	require.NoError(t, txSigs.encode(&b))
+
+	decoded, err := decodeTxSigsCtx(b.Bytes())
+	require.NoError(t, err)
+	require.False(t, decoded.signFirst)
+	require.Equal(
+		t, fundingTx.WitnessHash(), decoded.fundingTx.WitnessHash(),
+	)
+	require.Equal(t, txSigs.remotePrevOuts, decoded.remotePrevOuts)
+	require.Nil(t, decoded.signedTx)
+	require.False(t, decoded.sentSigs)
+
+	// Once the funding transaction is fully signed, it is restored as
+	// well, and our witnesses are considered sent.
+	txSigs.signedTx = fundingTx.Copy()
+	txSigs.signedTx.TxIn[1].Witness = wire.TxWitness{[]byte{3}}
+
+	b.Reset()
+	require.NoError(t, txSigs.encode(&b))
+
+	decoded, err = decodeTxSigsCtx(b.Bytes())
+	require.NoError(t, err)
+	require.Equal(
+		t, txSigs.signedTx.WitnessHash(),
+		decoded.signedTx.WitnessHash(),
+	)
+	require.True(t, decoded.sentSigs)
+
+	// If we're to sign first, our witnesses are considered sent right
+	// away.
+	txSigs.signFirst = true
+	txSigs.signedTx = nil
+
+	b.Reset()
+	require.NoError(t, txSigs.encode(&b))
+
+	decoded, err = decodeTxSigsCtx(b.Bytes())
+	require.NoError(t, err)
+	require.True(t, decoded.signFirst)
+	require.Nil(t, decoded.signedTx)
+	require.True(t, decoded.sentSigs)
+}
@@ -150,6 +150,10 @@ type reservationWithCtx struct {
 	// the channel.
 	channelType *lnwire.ChannelType
 
+	// dualFund tracks the interactive construction of the funding
+	// transaction. It is only set for dual funded channels.
+	dualFund *dualFundCtx
+
 	updateMtx   sync.RWMutex
 	lastUpdated time.Time
 
@@ -248,6 +252,31 @@ type InitFundingMsg struct {
 	// support explicit channel type negotiation.
 	ChannelType *lnwire.ChannelType
 
+	// ZeroConf signals that the channel should be usable before the
+	// funding transaction confirms. This requires the remote peer to
+	// explicitly accept the zero-conf channel type.
+	ZeroConf bool
+
+	// ScidAlias signals that the option_scid_alias channel type should be
+	// negotiated, meaning the channel's confirmed short channel ID is
+	// never revealed to the peer or in invoices. Only private channels
+	// can use this channel type.
+	ScidAlias bool
+
+	// DualFund signals that the channel should be opened as a dual funded
+	// channel, constructing the funding transaction interactively with
+	// the remote peer.
+	DualFund bool
+
+	// RemoteFundingAmt is the amount the remote peer is asked to
+	// contribute to a dual funded channel, paying the lease fee it
+	// advertises.
+	RemoteFundingAmt bronutil.Amount
+
+	// MaxLeaseFee is the maximum lease fee we pay the remote peer for
+	// contributing RemoteFundingAmt to the channel.
+	MaxLeaseFee bronutil.Amount
+
 	// Updates is a channel which updates to the opening status of the channel
 	// are sent on.
 	Updates chan *lnrpc.OpenStatusUpdate
@@ -349,6 +378,12 @@ type Config struct {
 	// NOTE: The peerChan channel must be buffered.
 	NotifyWhenOnline func(peer [33]byte, peerChan chan<- lnpeer.Peer)
 
+	// NotifyWhenOffline is a function that allows the FundingManager to
+	// be notified when a certain peer disconnects. This is used to
+	// retransmit the witnesses for the funding transaction of a dual
+	// funded channel once the peer reconnects.
+	NotifyWhenOffline func(peerPubKey [33]byte) <-chan struct{}
+
 	// FindChannel queries the database for the channel with the given
 	// channel ID.
 	FindChannel func(chanID lnwire.ChannelID) (*channeldb.OpenChannel, error)
@@ -462,6 +497,46 @@ type Config struct {
 	// MaxAnchorsCommitFeeRate is the max commitment fee rate we'll use as
 	// the initiator for channels of the anchor type.
 	MaxAnchorsCommitFeeRate chainfee.SatPerKWeight
+
+	// AliasManager is used to allocate and persist the alias short channel
+	// IDs used by zero-conf and option_scid_alias channels.
+	AliasManager AliasManager
+
+	// LeaseRates are the rates at which we lease our funds to peers that
+	// request liquidity when opening a dual funded channel with us. If
+	// nil, we don't contribute to dual funded channels opened by remote
+	// peers.
+	LeaseRates *lnwire.LeaseRates
+
+	// MaxLeaseAmt is the maximum amount we contribute to a single dual
+	// funded channel opened by a remote peer.
+	MaxLeaseAmt bronutil.Amount
+
+	// DeleteAliasEdge removes the edge that was added to the graph under
+	// the alias short channel ID of a public zero-conf channel, once the
+	// channel has confirmed and is about to be announced under its real
+	// short channel ID.
+	DeleteAliasEdge func(scid lnwire.ShortChannelID) error
+}
+
+// AliasManager is an interface that abstracts over the storage of alias short
+// channel IDs.
+type AliasManager interface {
+	// RequestAlias allocates a new alias short channel ID that hasn't been
+	// handed out before.
+	RequestAlias() (lnwire.ShortChannelID, error)
+
+	// AddLocalAlias persists a mapping from the alias to the base short
+	// channel ID of the channel it refers to.
+	AddLocalAlias(alias, baseScid lnwire.ShortChannelID) error
+
+	// GetAliases returns all aliases that map to the base short channel
+	// ID.
+	GetAliases(baseScid lnwire.ShortChannelID) ([]lnwire.ShortChannelID,
+		error)
+
+	// PutPeerAlias stores the alias our peer sent us in funding_locked.
+	PutPeerAlias(chanID lnwire.ChannelID, alias lnwire.ShortChannelID) error
 }
 
 // Manager acts as an orchestrator/bridge between the wallet's
@@ -501,7 +576,13 @@ type Manager struct {
 	// signed by both parties.
 	signedReservations map[lnwire.ChannelID][32]byte
 
-	// resMtx guards both of the maps above to ensure that all access is
+	// pendingTxSigs maps the permanent channel ID of a dual funded
+	// channel whose commitments have been signed to the exchange of the
+	// witnesses for its funding transaction, which lasts until the
+	// funding transaction confirmed.
+	pendingTxSigs map[lnwire.ChannelID]*txSigsCtx
+
+	// resMtx guards all of the maps above to ensure that all access is
 	// goroutine safe.
 	resMtx sync.RWMutex
 
@@ -574,6 +655,7 @@ func NewFundingManager(cfg Config) (*Manager, error) {
 		chanIDKey:                   cfg.TempChanIDSeed,
 		activeReservations:          make(map[serializedPubKey]pendingChannels),
 		signedReservations:          make(map[lnwire.ChannelID][32]byte),
+		pendingTxSigs:               make(map[lnwire.ChannelID]*txSigsCtx),
 		newChanBarriers:             make(map[lnwire.ChannelID]chan struct{}),
 		fundingMsgs:                 make(chan *fundingMsg, msgBufferSize),
 		fundingRequests:             make(chan *InitFundingMsg, msgBufferSize),
@@ -669,6 +751,16 @@ func (f *Manager) start() error {
 						channel.FundingOutpoint, err)
 				}
 			}
+
+			// The exchange of witnesses for the funding
+			// transaction of a dual funded channel is resumed,
+			// which rebroadcasts the funding transaction if it's
+			// signed already.
+			if chanType.IsDualFunder() {
+				if err := f.restoreTxSigs(channel); err != nil {
+					return err
+				}
+			}
 		}
 
 		// We will restart the funding state machine for all channels,
@@ -836,6 +928,21 @@ func (f *Manager) reservationCoordinator() {
 			case *lnwire.FundingLocked:
 				f.wg.Add(1)
 				go f.handleFundingLocked(fmsg.peer, msg)
+			case *lnwire.OpenChannel2:
+				f.handleOpenChannel2(fmsg.peer, msg)
+			case *lnwire.AcceptChannel2:
+				f.handleAcceptChannel2(fmsg.peer, msg)
+			case *lnwire.TxAddInput, *lnwire.TxAddOutput,
+				*lnwire.TxRemoveInput, *lnwire.TxRemoveOutput,
+				*lnwire.TxComplete:
+
+				f.handleInteractiveTxMsg(fmsg.peer, msg)
+			case *lnwire.CommitSig:
+				f.handleDualFundCommitSig(fmsg.peer, msg)
+			case *lnwire.TxSignatures:
+				f.handleTxSignatures(fmsg.peer, msg)
+			case *lnwire.TxAbort:
+				f.handleTxAbort(fmsg.peer, msg)
 			case *lnwire.Error:
 				f.handleErrorMsg(fmsg.peer, msg)
 			}
@@ -865,9 +972,17 @@ func (f *Manager) advanceFundingState(channel *channeldb.OpenChannel,
 	defer f.wg.Done()
 
 	// If the channel is still pending we must wait for the funding
-	// transaction to confirm.
+	// transaction to confirm, unless this is a zero-conf channel which we
+	// can mark as open right away.
 	if channel.IsPending {
-		err := f.advancePendingChannelState(channel, pendingChanID)
+		var err error
+		if channel.IsZeroConf() {
+			err = f.handleZeroConfOpen(channel)
+		} else {
+			err = f.advancePendingChannelState(
+				channel, pendingChanID,
+			)
+		}
 		if err != nil {
 			log.Errorf("Unable to advance pending state of "+
 				"ChannelPoint(%v): %v",
@@ -1025,7 +1140,22 @@ func (f *Manager) stateStep(channel *channeldb.OpenChannel,
 	// The channel was added to the Router's topology, but the channel
 	// announcement was not sent.
 	case addedToRouterGraph:
-		err := f.annAfterSixConfs(channel, shortChanID)
+		// A zero-conf channel was added to the graph under its alias,
+		// so we'll need to wait for the funding transaction to confirm
+		// before it can be announced with its real short channel ID.
+		annScid := shortChanID
+		if channel.IsZeroConf() {
+			realScid, err := f.waitForZeroConfChannel(
+				channel, shortChanID,
+			)
+			if err != nil {
+				return fmt.Errorf("failed waiting for zero-conf "+
+					"channel: %v", err)
+			}
+			annScid = realScid
+		}
+
+		err := f.annAfterSixConfs(channel, annScid)
 		if err != nil {
 			return fmt.Errorf("error sending channel "+
 				"announcement: %v", err)
@@ -1144,23 +1274,12 @@ func (f *Manager) ProcessFundingMsg(msg lnwire.Message, peer lnpeer.Peer) {
 	}
 }
 
-// handleFundingOpen creates an initial 'ChannelReservation' within the wallet,
-// then responds to the source peer with an accept channel message progressing
-// the funding workflow.
-//
-// TODO(roasbeef): add error chan to all, let channelManager handle
-// error+propagate
-func (f *Manager) handleFundingOpen(peer lnpeer.Peer,
-	msg *lnwire.OpenChannel) {
-
-	// Check number of pending channels to be smaller than maximum allowed
-	// number and send ErrorGeneric to remote peer if condition is
-	// violated.
-	peerPubKey := peer.IdentityKey()
+// numPendingChannels returns the number of pending channels with the given
+// peer that count towards the limit of pending channels. This includes the
+// active reservations and the channels pending open in the database.
+func (f *Manager) numPendingChannels(peerPubKey *btcec.PublicKey) (int, error) {
 	peerIDKey := newSerializedKey(peerPubKey)
 
-	amt := msg.FundingAmount
-
 	// We get all pending channels for this peer. This is the list of the
 	// active reservations and the channels pending open in the database.
 	f.resMtx.RLock()
@@ -1181,10 +1300,7 @@ func (f *Manager) handleFundingOpen(peer lnpeer.Peer,
 	// the underlying intent anymore, unfortunately.
 	channels, err := f.cfg.Wallet.Cfg.Database.FetchOpenChannels(peerPubKey)
 	if err != nil {
-		f.failFundingFlow(
-			peer, msg.PendingChannelID, err,
-		)
-		return
+		return 0, err
 	}
 
 	for _, c := range channels {
@@ -1199,6 +1315,34 @@ func (f *Manager) handleFundingOpen(peer lnpeer.Peer,
 		}
 	}
 
+	return numPending, nil
+}
+
+// handleFundingOpen creates an initial 'ChannelReservation' within the wallet,
+// then responds to the source peer with an accept channel message progressing
+// the funding workflow.
+//
+// TODO(roasbeef): add error chan to all, let channelManager handle
+// error+propagate
+func (f *Manager) handleFundingOpen(peer lnpeer.Peer,
+	msg *lnwire.OpenChannel) {
+
+	// Check number of pending channels to be smaller than maximum allowed
+	// number and send ErrorGeneric to remote peer if condition is
+	// violated.
+	peerPubKey := peer.IdentityKey()
+	peerIDKey := newSerializedKey(peerPubKey)
+
+	amt := msg.FundingAmount
+
+	numPending, err := f.numPendingChannels(peerPubKey)
+	if err != nil {
+		f.failFundingFlow(
+			peer, msg.PendingChannelID, err,
+		)
+		return
+	}
+
 	// TODO(roasbeef): modify to only accept a _single_ pending channel per
 	// block unless white listed
 	if numPending >= f.cfg.MaxPendingChannels {
@@ -1305,6 +1449,34 @@ func (f *Manager) handleFundingOpen(peer lnpeer.Peer,
 		chanTypeFeatureBits = msg.ChannelType
 	}
 
+	// A zero-conf channel is only accepted if our channel acceptor
+	// explicitly allowed it, and the acceptor can only allow it if the
+	// zero-conf channel type was negotiated.
+	zeroConf := hasChanTypeBit(chanTypeFeatureBits, lnwire.ZeroConfRequired)
+	switch {
+	case zeroConf && !acceptorResp.ZeroConf:
+		err := errors.New("zero-conf channel not accepted")
+		log.Errorf("Rejecting channel: %v", err)
+		f.failFundingFlow(peer, msg.PendingChannelID, err)
+		return
+
+	case !zeroConf && acceptorResp.ZeroConf:
+		err := errors.New("zero-conf channel type not negotiated")
+		log.Errorf("Rejecting channel: %v", err)
+		f.failFundingFlow(peer, msg.PendingChannelID, err)
+		return
+	}
+
+	// The option_scid_alias channel type is only valid for private
+	// channels.
+	scidAlias := hasChanTypeBit(chanTypeFeatureBits, lnwire.ScidAliasRequired)
+	if scidAlias && msg.ChannelFlags&lnwire.FFAnnounceChannel != 0 {
+		err := errors.New("option_scid_alias is only supported for " +
+			"private channels")
+		f.failFundingFlow(peer, msg.PendingChannelID, err)
+		return
+	}
+
 	chainHash := chainhash.Hash(msg.ChainHash)
 	req := &lnwallet.InitFundingReserveMsg{
 		ChainHash:        &chainHash,
@@ -1319,6 +1491,12 @@ func (f *Manager) handleFundingOpen(peer lnpeer.Peer,
 		Flags:            msg.ChannelFlags,
 		MinConfs:         1,
 		CommitType:       commitType,
+		ZeroConf:         zeroConf,
+		OptionScidAlias:  scidAlias,
+		ScidAliasFeature: hasFeatures(
+			peer.LocalFeatures(), peer.RemoteFeatures(),
+			lnwire.ScidAliasOptional,
+		),
 	}
 
 	reservation, err := f.cfg.Wallet.InitChannelReservation(req)
@@ -1338,6 +1516,12 @@ func (f *Manager) handleFundingOpen(peer lnpeer.Peer,
 	if acceptorResp.MinAcceptDepth != 0 {
 		numConfsReq = acceptorResp.MinAcceptDepth
 	}
+
+	// Zero-conf channels are usable right away, so we don't require any
+	// confirmations.
+	if zeroConf {
+		numConfsReq = 0
+	}
 	reservation.SetNumConfsRequired(numConfsReq)
 
 	// We'll also validate and apply all the constraints the initiating
@@ -1652,6 +1836,23 @@ func (f *Manager) handleFundingAccept(peer lnpeer.Peer,
 		return
 	}
 
+	// A zero-conf channel requires the responder to not require any
+	// confirmations, while any other channel needs at least one.
+	isZeroConf := resCtx.reservation.IsZeroConf()
+	if isZeroConf && msg.MinAcceptDepth != 0 {
+		err := fmt.Errorf("zero-conf channel has min_depth %v",
+			msg.MinAcceptDepth)
+		log.Warnf("Unacceptable channel constraints: %v", err)
+		f.failFundingFlow(peer, msg.PendingChannelID, err)
+		return
+	}
+	if !isZeroConf && msg.MinAcceptDepth == 0 {
+		err := errors.New("non-zero-conf channel has min_depth 0")
+		log.Warnf("Unacceptable channel constraints: %v", err)
+		f.failFundingFlow(peer, msg.PendingChannelID, err)
+		return
+	}
+
 	// We'll also specify the responder's preference for the number of
 	// required confirmations, and also the set of channel constraints
 	// they've specified for commitment states we can create.
@@ -2307,6 +2508,14 @@ func (f *Manager) waitForFundingConfirmation(
 		return
 	}
 	numConfs := uint32(completeChan.NumConfsRequired)
+
+	// Zero-conf channels don't require any confirmations to be used, but
+	// we still need the funding transaction to be included in a block to
+	// learn its real short channel ID.
+	if numConfs == 0 {
+		numConfs = 1
+	}
+
 	confNtfn, err := f.cfg.Notifier.RegisterConfirmationsNtfn(
 		&txid, fundingScript, numConfs,
 		completeChan.FundingBroadcastHeight,
@@ -2440,9 +2649,6 @@ func (f *Manager) handleFundingConfirmation(
 	completeChan *channeldb.OpenChannel,
 	confChannel *confirmedChannel) error {
 
-	fundingPoint := completeChan.FundingOutpoint
-	chanID := lnwire.NewChanIDFromOutPoint(&fundingPoint)
-
 	// TODO(roasbeef): ideally persistent state update for chan above
 	// should be abstracted
 
@@ -2455,15 +2661,56 @@ func (f *Manager) handleFundingConfirmation(
 		return fmt.Errorf("unable to validate channel: %v", err)
 	}
 
+	// If we opened the channel, and broln's wallet published our funding tx
+	// (which is not the case for some channels) then we update our
+	// transaction label with our short channel ID, which is known now that
+	// our funding transaction has confirmed. We do not label transactions
+	// we did not publish, because our wallet has no knowledge of them.
+	f.labelFundingTx(completeChan, confChannel.shortChanID)
+
+	return f.markChannelOpen(completeChan, confChannel.shortChanID)
+}
+
+// handleZeroConfOpen marks a zero-conf channel as open in the database before
+// its funding transaction has confirmed. The channel is assigned a fresh alias
+// that is used as its short channel ID until it confirms.
+func (f *Manager) handleZeroConfOpen(completeChan *channeldb.OpenChannel) error {
+	alias, err := f.cfg.AliasManager.RequestAlias()
+	if err != nil {
+		return fmt.Errorf("unable to request alias: %v", err)
+	}
+
+	// The first alias of a zero-conf channel doubles as its base short
+	// channel ID, so it maps to itself. Any aliases handed out later on,
+	// as well as the confirmed short channel ID, will map to it as well.
+	err = f.cfg.AliasManager.AddLocalAlias(alias, alias)
+	if err != nil {
+		return fmt.Errorf("unable to add alias: %v", err)
+	}
+
+	log.Infof("Marking zero-conf ChannelPoint(%v) as open with alias %v",
+		completeChan.FundingOutpoint, alias)
+
+	return f.markChannelOpen(completeChan, alias)
+}
+
+// markChannelOpen sets the channelOpeningState markedOpen and marks the
+// channel as open in the database using the given short channel ID. In
+// addition it will report the short channel ID to the switch, and close the
+// local discovery signal for this channel.
+func (f *Manager) markChannelOpen(completeChan *channeldb.OpenChannel,
+	shortChanID lnwire.ShortChannelID) error {
+
+	fundingPoint := completeChan.FundingOutpoint
+	chanID := lnwire.NewChanIDFromOutPoint(&fundingPoint)
+
 	// The funding transaction now being confirmed, we add this channel to
 	// the fundingManager's internal persistent state machine that we use
 	// to track the remaining process of the channel opening. This is
 	// useful to resume the opening process in case of restarts. We set the
 	// opening state before we mark the channel opened in the database,
 	// such that we can receover from one of the db writes failing.
-	err = f.saveChannelOpeningState(
-		&fundingPoint, markedOpen, &confChannel.shortChanID,
-	)
+	err := f.saveChannelOpeningState(&fundingPoint, markedOpen, &shortChanID)
 	if err != nil {
 		return fmt.Errorf("error setting channel state to markedOpen: %v",
 			err)
@@ -2471,12 +2718,18 @@ func (f *Manager) handleFundingConfirmation(
 
 	// Now that the channel has been fully confirmed and we successfully
 	// saved the opening state, we'll mark it as open within the database.
-	err = completeChan.MarkAsOpen(confChannel.shortChanID)
+	err = completeChan.MarkAsOpen(shortChanID)
 	if err != nil {
 		return fmt.Errorf("error setting channel pending flag to false: "+
 			"%v", err)
 	}
 
+	// The witnesses for the funding transaction of a dual funded channel
+	// no longer need to be retransmitted.
+	if completeChan.ChanType.IsDualFunder() {
+		f.finishTxSigs(&fundingPoint)
+	}
+
 	// Inform the ChannelNotifier that the channel has transitioned from
 	// pending open to open.
 	f.cfg.NotifyOpenChannelEvent(completeChan.FundingOutpoint)
@@ -2489,25 +2742,6 @@ func (f *Manager) handleFundingConfirmation(
 		log.Errorf("unable to report short chan id: %v", err)
 	}
 
-	// If we opened the channel, and broln's wallet published our funding tx
-	// (which is not the case for some channels) then we update our
-	// transaction label with our short channel ID, which is known now that
-	// our funding transaction has confirmed. We do not label transactions
-	// we did not publish, because our wallet has no knowledge of them.
-	if completeChan.IsInitiator && completeChan.ChanType.HasFundingTx() {
-		shortChanID := completeChan.ShortChanID()
-		label := labels.MakeLabel(
-			labels.LabelTypeChannelOpen, &shortChanID,
-		)
-
-		err = f.cfg.UpdateLabel(
-			completeChan.FundingOutpoint.Hash, label,
-		)
-		if err != nil {
-			log.Errorf("unable to update label: %v", err)
-		}
-	}
-
 	// Close the discoverySignal channel, indicating to a separate
 	// goroutine that the channel now is marked as open in the database
 	// and that it is acceptable to process funding locked messages
@@ -2521,6 +2755,99 @@ func (f *Manager) handleFundingConfirmation(
 	return nil
 }
 
+// labelFundingTx updates the label of the funding transaction with the
+// confirmed short channel ID if we're the initiator and our wallet published
+// the transaction.
+func (f *Manager) labelFundingTx(completeChan *channeldb.OpenChannel,
+	shortChanID lnwire.ShortChannelID) {
+
+	if !completeChan.IsInitiator || !completeChan.ChanType.HasFundingTx() {
+		return
+	}
+
+	label := labels.MakeLabel(labels.LabelTypeChannelOpen, &shortChanID)
+	err := f.cfg.UpdateLabel(completeChan.FundingOutpoint.Hash, label)
+	if err != nil {
+		log.Errorf("unable to update label: %v", err)
+	}
+}
+
+// waitForZeroConfChannel waits for the funding transaction of a zero-conf
+// channel to confirm, and persists the confirmed short channel ID. If the
+// channel is public, the edge that was added to the graph under its alias is
+// replaced by one using the confirmed short channel ID. The confirmed short
+// channel ID is returned.
+func (f *Manager) waitForZeroConfChannel(completeChan *channeldb.OpenChannel,
+	baseScid *lnwire.ShortChannelID) (*lnwire.ShortChannelID, error) {
+
+	fundingPoint := completeChan.FundingOutpoint
+
+	// If we already persisted the confirmed short channel ID, we were
+	// restarted after the confirmation and can skip the wait.
+	if !completeChan.ZeroConfConfirmed() {
+		confChannel, err := f.waitForFundingWithTimeout(completeChan)
+		if err != nil {
+			return nil, err
+		}
+
+		err = f.cfg.Wallet.ValidateChannel(
+			completeChan, confChannel.fundingTx,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("unable to validate channel: "+
+				"%v", err)
+		}
+
+		realScid := confChannel.shortChanID
+		if err := completeChan.MarkRealScid(realScid); err != nil {
+			return nil, fmt.Errorf("unable to mark real scid: %v",
+				err)
+		}
+
+		log.Infof("Zero-conf ChannelPoint(%v) confirmed with "+
+			"short_chan_id=%v", fundingPoint, realScid)
+
+		f.labelFundingTx(completeChan, realScid)
+	}
+
+	realScid := completeChan.ZeroConfRealScid()
+
+	// Unless the option_scid_alias channel type was negotiated, the
+	// confirmed short channel ID may be used to forward HTLCs over this
+	// channel as well. We register it as an alias of the base short
+	// channel ID and let the switch pick it up.
+	if !completeChan.IsOptionScidAlias() {
+		err := f.cfg.AliasManager.AddLocalAlias(realScid, *baseScid)
+		if err != nil {
+			return nil, fmt.Errorf("unable to add alias: %v", err)
+		}
+
+		err = f.cfg.ReportShortChanID(fundingPoint)
+		if err != nil {
+			log.Errorf("unable to report short chan id: %v", err)
+		}
+	}
+
+	// Private channels stay in the graph under their alias, as that's
+	// what's used in route hints. Public channels need to be announced
+	// using their confirmed short channel ID, so we'll replace the edge.
+	if completeChan.ChannelFlags&lnwire.FFAnnounceChannel == 0 {
+		return &realScid, nil
+	}
+
+	err := f.cfg.DeleteAliasEdge(*baseScid)
+	if err != nil && err != channeldb.ErrEdgeNotFound {
+		return nil, fmt.Errorf("unable to delete alias edge: %v", err)
+	}
+
+	if err := f.addToRouterGraph(completeChan, &realScid); err != nil {
+		return nil, fmt.Errorf("failed adding to router graph: %v",
+			err)
+	}
+
+	return &realScid, nil
+}
+
 // sendFundingLocked creates and sends the fundingLocked message.
 // This should be called after the funding transaction has been confirmed,
 // and the channelState is 'markedOpen'.
@@ -2543,6 +2870,17 @@ func (f *Manager) sendFundingLocked(
 	}
 	fundingLockedMsg := lnwire.NewFundingLocked(chanID, nextRevocation)
 
+	// If both of us signal the option_scid_alias feature, we'll include an
+	// alias the peer should use to refer to this channel, e.g. in route
+	// hints.
+	if completeChan.NegotiatedAliasFeature() {
+		alias, err := f.localAlias(*shortChanID)
+		if err != nil {
+			return fmt.Errorf("unable to get alias: %v", err)
+		}
+		fundingLockedMsg.AliasScid = &alias
+	}
+
 	// If the peer has disconnected before we reach this point, we will need
 	// to wait for him to come back online before sending the fundingLocked
 	// message. This is special for fundingLocked, since failing to send any
@@ -2581,6 +2919,37 @@ func (f *Manager) sendFundingLocked(
 	return nil
 }
 
+// localAlias returns an alias that maps to the passed base short channel ID,
+// allocating and persisting a new one if none exists yet.
+func (f *Manager) localAlias(
+	baseScid lnwire.ShortChannelID) (lnwire.ShortChannelID, error) {
+
+	aliases, err := f.cfg.AliasManager.GetAliases(baseScid)
+	if err != nil {
+		return lnwire.ShortChannelID{}, err
+	}
+
+	// The confirmed short channel ID of a zero-conf channel is stored as
+	// an alias as well, so we'll make sure to only return actual aliases.
+	for _, alias := range aliases {
+		if channeldb.IsAlias(alias) {
+			return alias, nil
+		}
+	}
+
+	alias, err := f.cfg.AliasManager.RequestAlias()
+	if err != nil {
+		return lnwire.ShortChannelID{}, err
+	}
+
+	err = f.cfg.AliasManager.AddLocalAlias(alias, baseScid)
+	if err != nil {
+		return lnwire.ShortChannelID{}, err
+	}
+
+	return alias, nil
+}
+
 // addToRouterGraph sends a ChannelAnnouncement and a ChannelUpdate to the
 // gossiper so that the channel is added to the Router's internal graph.
 // These announcement messages are NOT broadcasted to the greater network,
@@ -2859,6 +3228,17 @@ func (f *Manager) handleFundingLocked(peer lnpeer.Peer,
 		return
 	}
 
+	// If the peer sent us an alias for this channel, we'll store it so it
+	// can be used in place of the real short channel ID.
+	if msg.AliasScid != nil && channel.NegotiatedAliasFeature() {
+		err := f.cfg.AliasManager.PutPeerAlias(chanID, *msg.AliasScid)
+		if err != nil {
+			log.Errorf("Unable to store peer alias for "+
+				"ChannelID(%v): %v", chanID, err)
+			return
+		}
+	}
+
 	// If the RemoteNextRevocation is non-nil, it means that we have
 	// already processed fundingLocked for this channel, so ignore.
 	if channel.RemoteNextRevocation != nil {
@@ -3264,6 +3644,73 @@ func (f *Manager) handleInitFundingMsg(msg *InitFundingMsg) {
 		return
 	}
 
+	// The option_scid_alias channel type hides the real short channel ID
+	// from the peer, which only makes sense for private channels.
+	if msg.ScidAlias && !msg.Private {
+		msg.Err <- errors.New("option_scid_alias is only supported " +
+			"for private channels")
+		return
+	}
+
+	// If a zero-conf or scid-alias channel was requested, we'll add the
+	// corresponding bits to the channel type, which forces explicit
+	// channel type negotiation.
+	localFeatures := msg.Peer.LocalFeatures()
+	remoteFeatures := msg.Peer.RemoteFeatures()
+	if msg.ZeroConf || msg.ScidAlias {
+		msg.ChannelType = withAliasChanTypeBits(
+			msg.ChannelType, localFeatures, remoteFeatures,
+			msg.ZeroConf, msg.ScidAlias,
+		)
+	}
+
+	// A dual funded channel requires both of us to support constructing
+	// the funding transaction interactively. As our contribution is
+	// always selected from the internal wallet, custom channel funders
+	// can't be used. The only funds pushed to the remote peer are the
+	// lease fee for the liquidity we request.
+	var (
+		dualFundRole    lnwallet.DualFundRole
+		fundingLockTime uint32
+	)
+	if msg.DualFund {
+		if !hasFeatures(
+			localFeatures, remoteFeatures, lnwire.DualFundOptional,
+		) {
+
+			msg.Err <- errors.New("dual funded channels not " +
+				"supported by both peers")
+			return
+		}
+
+		if msg.ChanFunder != nil {
+			msg.Err <- errors.New("funding shims not supported " +
+				"for dual funded channels")
+			return
+		}
+
+		if msg.PushAmt != 0 {
+			msg.Err <- errors.New("push amount not supported " +
+				"for dual funded channels")
+			return
+		}
+
+		// We'll use the current height as lock time of the funding
+		// transaction to discourage fee sniping.
+		_, bestHeight, err := f.cfg.Wallet.Cfg.ChainIO.GetBestBlock()
+		if err != nil {
+			msg.Err <- err
+			return
+		}
+
+		dualFundRole = lnwallet.DualFundOpener
+		fundingLockTime = uint32(bestHeight)
+	} else if msg.RemoteFundingAmt != 0 {
+		msg.Err <- errors.New("remote funding can only be requested " +
+			"for dual funded channels")
+		return
+	}
+
 	// Initialize a funding reservation with the local wallet. If the
 	// wallet doesn't have enough funds to commit to this channel, then the
 	// request will fail, and be aborted.
@@ -3272,8 +3719,7 @@ func (f *Manager) handleInitFundingMsg(msg *InitFundingMsg) {
 	// format we can use with this peer. This is dependent on *both* us and
 	// the remote peer are signaling the proper feature bit.
 	_, chanType, commitType, err := negotiateCommitmentType(
-		msg.ChannelType, msg.Peer.LocalFeatures(),
-		msg.Peer.RemoteFeatures(), true,
+		msg.ChannelType, localFeatures, remoteFeatures, true,
 	)
 	if err != nil {
 		log.Errorf("channel type negotiation failed: %v", err)
@@ -3313,6 +3759,15 @@ func (f *Manager) handleInitFundingMsg(msg *InitFundingMsg) {
 		MinConfs:         msg.MinConfs,
 		CommitType:       commitType,
 		ChanFunder:       msg.ChanFunder,
+		ZeroConf:         hasChanTypeBit(chanType, lnwire.ZeroConfRequired),
+		OptionScidAlias: hasChanTypeBit(
+			chanType, lnwire.ScidAliasRequired,
+		),
+		ScidAliasFeature: hasFeatures(
+			localFeatures, remoteFeatures, lnwire.ScidAliasOptional,
+		),
+		DualFundRole:    dualFundRole,
+		FundingLockTime: fundingLockTime,
 	}
 
 	reservation, err := f.cfg.Wallet.InitChannelReservation(req)
@@ -3376,6 +3831,14 @@ func (f *Manager) handleInitFundingMsg(msg *InitFundingMsg) {
 		updates:        msg.Updates,
 		err:            msg.Err,
 	}
+	if msg.DualFund {
+		resCtx.dualFund = &dualFundCtx{
+			feeRate:     msg.FundingFeePerKw,
+			requestAmt:  msg.RemoteFundingAmt,
+			maxLeaseFee: msg.MaxLeaseFee,
+			leaseHeight: fundingLockTime,
+		}
+	}
 	f.activeReservations[peerIDKey][chanID] = resCtx
 	f.resMtx.Unlock()
 
@@ -3408,7 +3871,7 @@ func (f *Manager) handleInitFundingMsg(msg *InitFundingMsg) {
 	log.Infof("Starting funding workflow with %v for pending_id(%x), "+
 		"committype=%v", msg.Peer.Address(), chanID, commitType)
 
-	fundingOpen := lnwire.OpenChannel{
+	var fundingOpen lnwire.Message = &lnwire.OpenChannel{
 		ChainHash:             *f.cfg.Wallet.Cfg.NetParams.GenesisHash,
 		PendingChannelID:      chanID,
 		FundingAmount:         capacity,
@@ -3431,7 +3894,43 @@ func (f *Manager) handleInitFundingMsg(msg *InitFundingMsg) {
 		ChannelType:           chanType,
 		LeaseExpiry:           leaseExpiry,
 	}
-	if err := msg.Peer.SendMessage(true, &fundingOpen); err != nil {
+
+	// For dual funded channels, we only announce our own contribution
+	// and ask the remote peer for liquidity if requested.
+	if msg.DualFund {
+		var requestFunds *lnwire.RequestFunds
+		if msg.RemoteFundingAmt != 0 {
+			requestFunds = &lnwire.RequestFunds{
+				RequestedSats: msg.RemoteFundingAmt,
+				BlockHeight:   fundingLockTime,
+			}
+		}
+
+		fundingOpen = &lnwire.OpenChannel2{
+			ChainHash:             *f.cfg.Wallet.Cfg.NetParams.GenesisHash,
+			PendingChannelID:      chanID,
+			FundingFeePerKWeight:  uint32(msg.FundingFeePerKw),
+			CommitFeePerKWeight:   uint32(commitFeePerKw),
+			FundingAmount:         capacity,
+			DustLimit:             ourDustLimit,
+			MaxValueInFlight:      maxValue,
+			HtlcMinimum:           minHtlcIn,
+			CsvDelay:              remoteCsvDelay,
+			MaxAcceptedHTLCs:      maxHtlcs,
+			LockTime:              fundingLockTime,
+			FundingKey:            ourContribution.MultiSigKey.PubKey,
+			RevocationPoint:       ourContribution.RevocationBasePoint.PubKey,
+			PaymentPoint:          ourContribution.PaymentBasePoint.PubKey,
+			DelayedPaymentPoint:   ourContribution.DelayBasePoint.PubKey,
+			HtlcPoint:             ourContribution.HtlcBasePoint.PubKey,
+			FirstCommitmentPoint:  ourContribution.FirstCommitmentPoint,
+			ChannelFlags:          channelFlags,
+			UpfrontShutdownScript: shutdown,
+			ChannelType:           chanType,
+			RequestFunds:          requestFunds,
+		}
+	}
+	if err := msg.Peer.SendMessage(true, fundingOpen); err != nil {
 		e := fmt.Errorf("unable to send funding request message: %v",
 			err)
 		log.Errorf(e.Error())
@@ -450,6 +450,10 @@ func createTestFundingManager(t *testing.T, privKey *btcec.PrivateKey,
 		OpenChannelPredicate:          chainedAcceptor,
 		NotifyPendingOpenChannelEvent: evt.NotifyPendingOpenChannelEvent,
 		RegisteredChains:              chainreg.NewChainRegistry(),
+		AliasManager:                  cdb,
+		DeleteAliasEdge: func(lnwire.ShortChannelID) error {
+			return nil
+		},
 	}
 
 	for _, op := range options {
@@ -483,6 +487,9 @@ func createTestFundingManager(t *testing.T, privKey *btcec.PrivateKey,
 
 		connectedChan <- testNode.remotePeer
 	}
+	f.cfg.NotifyWhenOffline = func([33]byte) <-chan struct{} {
+		return make(chan struct{})
+	}
 
 	return testNode, nil
 }
@@ -534,6 +541,9 @@ func recreateAliceFundingManager(t *testing.T, alice *testNode) {
 
 			connectedChan <- alice.remotePeer
 		},
+		NotifyWhenOffline: func([33]byte) <-chan struct{} {
+			return make(chan struct{})
+		},
 		TempChanIDSeed: oldCfg.TempChanIDSeed,
 		FindChannel:    oldCfg.FindChannel,
 		DefaultRoutingPolicy: htlcswitch.ForwardingPolicy{
@@ -554,6 +564,8 @@ func recreateAliceFundingManager(t *testing.T, alice *testNode) {
 		ZombieSweeperInterval: oldCfg.ZombieSweeperInterval,
 		ReservationTimeout:    oldCfg.ReservationTimeout,
 		OpenChannelPredicate:  chainedAcceptor,
+		AliasManager:          oldCfg.AliasManager,
+		DeleteAliasEdge:       oldCfg.DeleteAliasEdge,
 	})
 	if err != nil {
 		t.Fatalf("failed recreating aliceFundingManager: %v", err)
@@ -3181,6 +3193,75 @@ func TestFundingManagerMaxConfs(t *testing.T) {
 	}
 }
 
+// TestFundingManagerZeroConfUnsupported asserts that a zero-conf or
+// option_scid_alias channel isn't opened if it can't be negotiated.
+func TestFundingManagerZeroConfUnsupported(t *testing.T) {
+	t.Parallel()
+
+	alice, bob := setupFundingManagers(t)
+	defer tearDownFundingManagers(t, alice, bob)
+
+	testCases := []struct {
+		name      string
+		zeroConf  bool
+		scidAlias bool
+		private   bool
+		expErr    string
+	}{
+		{
+			name:     "zero-conf without explicit negotiation",
+			zeroConf: true,
+			private:  true,
+			expErr:   errUnsupportedExplicitNegotiation.Error(),
+		},
+		{
+			name:      "scid-alias without explicit negotiation",
+			scidAlias: true,
+			private:   true,
+			expErr:    errUnsupportedExplicitNegotiation.Error(),
+		},
+		{
+			name:      "public scid-alias channel",
+			scidAlias: true,
+			private:   false,
+			expErr:    "only supported for private channels",
+		},
+	}
+
+	for _, test := range testCases {
+		updateChan := make(chan *lnrpc.OpenStatusUpdate)
+		errChan := make(chan error, 1)
+		initReq := &InitFundingMsg{
+			Peer:            bob,
+			TargetPubkey:    bob.privKey.PubKey(),
+			ChainHash:       *fundingNetParams.GenesisHash,
+			LocalFundingAmt: 500000,
+			Private:         test.private,
+			ZeroConf:        test.zeroConf,
+			ScidAlias:       test.scidAlias,
+			Updates:         updateChan,
+			Err:             errChan,
+		}
+
+		alice.fundingMgr.InitFundingWorkflow(initReq)
+
+		select {
+		case err := <-errChan:
+			if !strings.Contains(err.Error(), test.expErr) {
+				t.Fatalf("%s: expected error %q, got %v",
+					test.name, test.expErr, err)
+			}
+
+		case msg := <-alice.msgChan:
+			t.Fatalf("%s: expected error, alice sent %T",
+				test.name, msg)
+
+		case <-time.After(time.Second * 5):
+			t.Fatalf("%s: funding workflow didn't fail", test.name)
+		}
+	}
+}
+
 // TestFundingManagerFundAll tests that we can initiate a funding request to
 // use the funds remaining in the wallet. This should produce a funding tx with
 // no change output.
@@ -93,7 +93,9 @@ func newOnionProcessor(t *testing.T) *hop.OnionProcessor {
 		t.Fatalf("unable to start sphinx router: %v", err)
 	}
 
-	return hop.NewOnionProcessor(sphinxRouter)
+	return hop.NewOnionProcessor(
+		sphinxRouter, &keychain.PrivKeyECDH{PrivKey: sphinxPrivKey},
+	)
 }
 
 // newCircuitMap creates a new htlcswitch.CircuitMap using a temp db and a
@@ -1,6 +1,7 @@
 package hop
 
 import (
+	"github.com/brsuite/brond/btcec"
 	"github.com/brsuite/broln/lnwire"
 )
 
@@ -26,4 +27,9 @@ type ForwardingInfo struct {
 	// OutgoingCTLV is the specified value of the CTLV timelock to be used
 	// in the outgoing HTLC.
 	OutgoingCTLV uint32
+
+	// NextBlinding is the blinding point that should be passed on to the
+	// next hop along with the outgoing HTLC, if we're forwarding within a
+	// blinded route.
+	NextBlinding *btcec.PublicKey
 }
@@ -2,13 +2,17 @@ package hop
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"sync"
 
 	"github.com/brsuite/brond/btcec"
 	sphinx "github.com/brsuite/lightning-onion"
+	"github.com/brsuite/broln/keychain"
 	"github.com/brsuite/broln/lnwire"
+	"github.com/brsuite/broln/record"
+	"github.com/brsuite/broln/routing/blindedpath"
 )
 
 // Iterator is an interface that abstracts away the routing information
@@ -47,16 +51,39 @@ type sphinxHopIterator struct {
 	// includes the information required to properly forward the packet to
 	// the next hop.
 	processedPacket *sphinx.ProcessedPacket
+
+	// blinding holds the information required to process the payload of
+	// a hop within a blinded route.
+	blinding blindingInfo
+}
+
+// blindingInfo holds everything a hop within a blinded route needs to decrypt
+// the data the recipient left for it and to derive its forwarding instructions.
+type blindingInfo struct {
+	// nodeKey is our node key, used to decrypt the encrypted data.
+	nodeKey keychain.SingleKeyECDH
+
+	// blindingPoint is the blinding point we received along with the
+	// HTLC. It is nil if we're not within a blinded route or if we're its
+	// introduction node.
+	blindingPoint *btcec.PublicKey
+
+	// incomingAmount is the amount of the incoming HTLC.
+	incomingAmount lnwire.MilliSatoshi
+
+	// incomingCltv is the expiry of the incoming HTLC.
+	incomingCltv uint32
 }
 
 // makeSphinxHopIterator converts a processed packet returned from a sphinx
 // router and converts it into an hop iterator for usage in the link.
 func makeSphinxHopIterator(ogPacket *sphinx.OnionPacket,
-	packet *sphinx.ProcessedPacket) *sphinxHopIterator {
+	packet *sphinx.ProcessedPacket, blinding blindingInfo) *sphinxHopIterator {
 
 	return &sphinxHopIterator{
 		ogPacket:        ogPacket,
 		processedPacket: packet,
+		blinding:        blinding,
 	}
 }
 
@@ -90,9 +117,32 @@ func (r *sphinxHopIterator) HopPayload() (*Payload, error) {
 	// Otherwise, if this is the TLV payload, then we'll make a new stream
 	// to decode only what we need to make routing decisions.
 	case sphinx.PayloadTLV:
-		return NewPayloadFromReader(bytes.NewReader(
+		payload, err := NewPayloadFromReader(bytes.NewReader(
 			r.processedPacket.Payload.Payload,
 		))
+		if err != nil {
+			return nil, err
+		}
+
+		// Within a blinded route, the forwarding instructions are
+		// left for us by the recipient instead.
+		if payload.EncryptedData() != nil {
+			return r.blindedPayload(payload)
+		}
+
+		// Hops outside of a blinded route must not receive a blinding
+		// point.
+		if r.blinding.blindingPoint != nil ||
+			payload.BlindingPoint() != nil {
+
+			return nil, ErrInvalidPayload{
+				Type:      record.EncryptedDataOnionType,
+				Violation: OmittedViolation,
+				FinalHop:  r.isFinalHop(),
+			}
+		}
+
+		return payload, nil
 
 	default:
 		return nil, fmt.Errorf("unknown sphinx payload type: %v",
@@ -100,6 +150,150 @@ func (r *sphinxHopIterator) HopPayload() (*Payload, error) {
 	}
 }
 
+// isFinalHop returns true if the onion packet doesn't have to be forwarded
+// any further.
+func (r *sphinxHopIterator) isFinalHop() bool {
+	return r.processedPacket.Action == sphinx.ExitNode
+}
+
+// blindedPayload decrypts the data the recipient of a blinded route left for
+// our hop and completes the payload with the forwarding instructions found in
+// it.
+func (r *sphinxHopIterator) blindedPayload(payload *Payload) (*Payload,
+	error) {
+
+	isFinalHop := r.isFinalHop()
+
+	// The introduction node receives its blinding point within the
+	// onion, all other hops along with the HTLC. Exactly one of them must
+	// be set.
+	blindingPoint := r.blinding.blindingPoint
+	switch {
+	case blindingPoint != nil && payload.BlindingPoint() != nil:
+		return nil, ErrInvalidPayload{
+			Type:      record.BlindingPointOnionType,
+			Violation: IncludedViolation,
+			FinalHop:  isFinalHop,
+		}
+
+	case blindingPoint == nil && payload.BlindingPoint() == nil:
+		return nil, ErrInvalidPayload{
+			Type:      record.BlindingPointOnionType,
+			Violation: OmittedViolation,
+			FinalHop:  isFinalHop,
+		}
+
+	case blindingPoint == nil:
+		blindingPoint = payload.BlindingPoint()
+	}
+
+	plainText, nextBlinding, err := blindedpath.DecryptBlindedHopData(
+		r.blinding.nodeKey, blindingPoint, payload.EncryptedData(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt blinded hop data: "+
+			"%w", err)
+	}
+
+	data, err := blindedpath.DecodeEncryptedData(plainText)
+	if err != nil {
+		return nil, err
+	}
+
+	// Enforce the constraints the recipient placed on the payments
+	// forwarded over the route.
+	if c := data.PaymentConstraints; c != nil {
+		if r.blinding.incomingCltv > c.MaxCltvExpiry {
+			return nil, fmt.Errorf("incoming expiry %d exceeds "+
+				"blinded route max of %d",
+				r.blinding.incomingCltv, c.MaxCltvExpiry)
+		}
+
+		if r.blinding.incomingAmount < c.HtlcMinimumMsat {
+			return nil, fmt.Errorf("incoming amount %v below "+
+				"blinded route min of %v",
+				r.blinding.incomingAmount, c.HtlcMinimumMsat)
+		}
+	}
+
+	// The sender tells us whether we're the final hop with the total
+	// amount record, which must match what the onion itself tells us.
+	hasTotalAmt := payload.TotalAmtMsat() != 0
+	switch {
+	case isFinalHop && !hasTotalAmt:
+		return nil, ErrInvalidPayload{
+			Type:      record.TotalAmtMsatBlindedType,
+			Violation: OmittedViolation,
+			FinalHop:  true,
+		}
+
+	case !isFinalHop && hasTotalAmt:
+		return nil, ErrInvalidPayload{
+			Type:      record.TotalAmtMsatBlindedType,
+			Violation: IncludedViolation,
+			FinalHop:  false,
+		}
+	}
+
+	// As the recipient, the path ID is the payment address we handed out
+	// along with the route. It lets us treat the payment like any other
+	// multi-path payment.
+	if isFinalHop {
+		if data.ShortChannelID != nil || data.NextNodeID != nil {
+			return nil, errors.New("final hop of blinded route " +
+				"has next hop")
+		}
+
+		var payAddr [32]byte
+		if len(data.PathID) != len(payAddr) {
+			return nil, fmt.Errorf("invalid path id length %d",
+				len(data.PathID))
+		}
+		copy(payAddr[:], data.PathID)
+
+		payload.FwdInfo.NextHop = Exit
+		payload.MPP = record.NewMPP(payload.TotalAmtMsat(), payAddr)
+
+		return payload, nil
+	}
+
+	// Intermediate hops derive the outgoing HTLC from the incoming one,
+	// using the relay parameters the recipient picked for us.
+	switch {
+	case data.ShortChannelID == nil:
+		return nil, errors.New("blinded hop data without next channel")
+
+	case data.PaymentRelay == nil:
+		return nil, errors.New("blinded hop data without payment relay")
+	}
+
+	relay := data.PaymentRelay
+	amtToForward, err := relay.ForwardAmount(r.blinding.incomingAmount)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.blinding.incomingCltv < uint32(relay.CltvExpiryDelta) {
+		return nil, fmt.Errorf("incoming expiry %d below cltv delta %d",
+			r.blinding.incomingCltv, relay.CltvExpiryDelta)
+	}
+
+	if data.NextBlindingOverride != nil {
+		nextBlinding = data.NextBlindingOverride
+	}
+
+	payload.FwdInfo = ForwardingInfo{
+		Network:         BrocoinNetwork,
+		NextHop:         *data.ShortChannelID,
+		AmountToForward: amtToForward,
+		OutgoingCTLV: r.blinding.incomingCltv -
+			uint32(relay.CltvExpiryDelta),
+		NextBlinding: nextBlinding,
+	}
+
+	return payload, nil
+}
+
 // ExtractErrorEncrypter decodes and returns the ErrorEncrypter for this hop,
 // along with a failure code to signal if the decoding was successful. The
 // ErrorEncrypter is used to encrypt errors back to the sender in the event that
@@ -123,11 +317,63 @@ func (r *sphinxHopIterator) ExtractErrorEncrypter(
 // tests dependent from the sphinx internal parts.
 type OnionProcessor struct {
 	router *sphinx.Router
+
+	// nodeKey is the key the router processes onions with. It is used to
+	// unblind the onions we receive within blinded routes.
+	nodeKey keychain.SingleKeyECDH
 }
 
 // NewOnionProcessor creates new instance of decoder.
-func NewOnionProcessor(router *sphinx.Router) *OnionProcessor {
-	return &OnionProcessor{router}
+func NewOnionProcessor(router *sphinx.Router,
+	nodeKey keychain.SingleKeyECDH) *OnionProcessor {
+
+	return &OnionProcessor{
+		router:  router,
+		nodeKey: nodeKey,
+	}
+}
+
+// blindOnion prepares an onion we received within a blinded route for
+// processing. The sender created the onion for our blinded node ID, so the
+// ephemeral key is tweaked such that processing it with our real node key
+// yields the same shared secret. The returned function fixes up the ephemeral
+// key of the packet for the next hop once processed, as the sphinx router
+// derives it from the tweaked key. If no blinding point is given, the onion
+// is left untouched.
+func (p *OnionProcessor) blindOnion(onionPkt *sphinx.OnionPacket,
+	blindingPoint *btcec.PublicKey) (func(*sphinx.ProcessedPacket) error,
+	error) {
+
+	if blindingPoint == nil {
+		return func(*sphinx.ProcessedPacket) error { return nil }, nil
+	}
+
+	blindedKey, err := blindedpath.NewBlindedNodeECDH(
+		p.nodeKey, blindingPoint,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	ephemeralKey := onionPkt.EphemeralKey
+	onionPkt.EphemeralKey = blindedKey.BlindRemoteKey(ephemeralKey)
+
+	return func(packet *sphinx.ProcessedPacket) error {
+		if packet.Action != sphinx.MoreHops {
+			return nil
+		}
+
+		sharedSecret, err := blindedKey.ECDH(ephemeralKey)
+		if err != nil {
+			return err
+		}
+
+		packet.NextPacket.EphemeralKey = blindedpath.NextBlindingPoint(
+			ephemeralKey, sharedSecret,
+		)
+
+		return nil
+	}, nil
 }
 
 // Start spins up the onion processor's sphinx router.
@@ -185,20 +431,49 @@ func (p *OnionProcessor) DecodeHopIterator(r io.Reader, rHash []byte,
 		}
 	}
 
-	return makeSphinxHopIterator(onionPkt, sphinxPacket), lnwire.CodeNone
+	blinding := blindingInfo{
+		nodeKey:      p.nodeKey,
+		incomingCltv: incomingCltv,
+	}
+
+	return makeSphinxHopIterator(onionPkt, sphinxPacket, blinding),
+		lnwire.CodeNone
+}
+
+// ReconstructBlindingInfo contains the information about the incoming HTLC
+// that is required to reconstruct the hop iterator of an HTLC that was
+// received within a blinded route.
+type ReconstructBlindingInfo struct {
+	// IncomingAmt is the amount of the incoming HTLC.
+	IncomingAmt lnwire.MilliSatoshi
+
+	// IncomingExpiry is the expiry height of the incoming HTLC.
+	IncomingExpiry uint32
+
+	// BlindingPoint is the blinding point that was received along with
+	// the incoming HTLC, if any.
+	BlindingPoint *btcec.PublicKey
 }
 
 // ReconstructHopIterator attempts to decode a valid sphinx packet from the passed io.Reader
 // instance using the rHash as the associated data when checking the relevant
 // MACs during the decoding process.
-func (p *OnionProcessor) ReconstructHopIterator(r io.Reader, rHash []byte) (
-	Iterator, error) {
+func (p *OnionProcessor) ReconstructHopIterator(r io.Reader, rHash []byte,
+	info ReconstructBlindingInfo) (Iterator, error) {
 
 	onionPkt := &sphinx.OnionPacket{}
 	if err := onionPkt.Decode(r); err != nil {
 		return nil, err
 	}
 
+	// HTLCs within a blinded route are encrypted to our blinded node ID,
+	// so we'll need to tweak the onion's ephemeral key before we're able
+	// to process it.
+	unblind, err := p.blindOnion(onionPkt, info.BlindingPoint)
+	if err != nil {
+		return nil, err
+	}
+
 	// Attempt to process the Sphinx packet. We include the payment hash of
 	// the HTLC as it's authenticated within the Sphinx packet itself as
 	// associated data in order to thwart attempts a replay attacks. In the
@@ -209,7 +484,18 @@ func (p *OnionProcessor) ReconstructHopIterator(r io.Reader, rHash []byte) (
 		return nil, err
 	}
 
-	return makeSphinxHopIterator(onionPkt, sphinxPacket), nil
+	if err := unblind(sphinxPacket); err != nil {
+		return nil, err
+	}
+
+	blinding := blindingInfo{
+		nodeKey:        p.nodeKey,
+		blindingPoint:  info.BlindingPoint,
+		incomingAmount: info.IncomingAmt,
+		incomingCltv:   info.IncomingExpiry,
+	}
+
+	return makeSphinxHopIterator(onionPkt, sphinxPacket, blinding), nil
 }
 
 // DecodeHopIteratorRequest encapsulates all date necessary to process an onion
@@ -219,6 +505,14 @@ type DecodeHopIteratorRequest struct {
 	OnionReader  io.Reader
 	RHash        []byte
 	IncomingCltv uint32
+
+	// IncomingAmount is the amount of the incoming HTLC, which hops
+	// within a blinded route derive the outgoing amount from.
+	IncomingAmount lnwire.MilliSatoshi
+
+	// BlindingPoint is the blinding point received along with the
+	// incoming HTLC, if any.
+	BlindingPoint *btcec.PublicKey
 }
 
 // DecodeHopIteratorResponse encapsulates the outcome of a batched sphinx onion
@@ -250,6 +544,7 @@ func (p *OnionProcessor) DecodeHopIterators(id []byte,
 	var (
 		batchSize = len(reqs)
 		onionPkts = make([]sphinx.OnionPacket, batchSize)
+		unblinds  = make([]func(*sphinx.ProcessedPacket) error, batchSize)
 		resps     = make([]DecodeHopIteratorResponse, batchSize)
 	)
 
@@ -274,6 +569,14 @@ func (p *OnionProcessor) DecodeHopIterators(id []byte,
 			return lnwire.CodeInvalidOnionKey
 		}
 
+		unblinds[seqNum], err = p.blindOnion(
+			onionPkt, req.BlindingPoint,
+		)
+		if err != nil {
+			log.Errorf("unable to unblind onion packet: %v", err)
+			return lnwire.CodeInvalidOnionKey
+		}
+
 		err = tx.ProcessOnionPacket(
 			seqNum, onionPkt, req.RHash, req.IncomingCltv,
 		)
@@ -366,9 +669,23 @@ func (p *OnionProcessor) DecodeHopIterators(id []byte,
 			continue
 		}
 
+		if err := unblinds[i](&packets[i]); err != nil {
+			log.Errorf("unable to unblind onion packet: %v", err)
+			resp.FailCode = lnwire.CodeInvalidOnionKey
+			continue
+		}
+
 		// Finally, construct a hop iterator from our processed sphinx
 		// packet, simultaneously caching the original onion packet.
-		resp.HopIterator = makeSphinxHopIterator(&onionPkts[i], &packets[i])
+		blinding := blindingInfo{
+			nodeKey:        p.nodeKey,
+			blindingPoint:  reqs[i].BlindingPoint,
+			incomingAmount: reqs[i].IncomingAmount,
+			incomingCltv:   reqs[i].IncomingCltv,
+		}
+		resp.HopIterator = makeSphinxHopIterator(
+			&onionPkts[i], &packets[i], blinding,
+		)
 	}
 
 	return resps, nil
@@ -5,11 +5,16 @@ import (
 	"encoding/binary"
 	"testing"
 
+	"github.com/brsuite/brond/btcec"
+	"github.com/brsuite/brond/chaincfg"
 	"github.com/davecgh/go-spew/spew"
 	sphinx "github.com/brsuite/lightning-onion"
+	"github.com/brsuite/broln/keychain"
 	"github.com/brsuite/broln/lnwire"
 	"github.com/brsuite/broln/record"
+	"github.com/brsuite/broln/routing/blindedpath"
 	"github.com/brsuite/broln/tlv"
+	"github.com/stretchr/testify/require"
 )
 
 // TestSphinxHopIteratorForwardingInstructions tests that we're able to
@@ -99,3 +104,179 @@ func TestSphinxHopIteratorForwardingInstructions(t *testing.T) {
 		}
 	}
 }
+
+// TestBlindedRouteProcessing asserts that the hops of a blinded route are able
+// to process the onions they receive and derive their forwarding instructions
+// from the data the recipient left for them.
+func TestBlindedRouteProcessing(t *testing.T) {
+	t.Parallel()
+
+	bobKey, err := btcec.NewPrivateKey(btcec.S256())
+	require.NoError(t, err)
+	carolKey, err := btcec.NewPrivateKey(btcec.S256())
+	require.NoError(t, err)
+
+	// Carol creates a blinded route through Bob, her introduction node,
+	// to herself.
+	var (
+		chanID  = lnwire.NewShortChanIDFromInt(1234)
+		payAddr = [32]byte{1, 2, 3}
+		relay   = &blindedpath.PaymentRelay{
+			CltvExpiryDelta:           40,
+			FeeProportionalMillionths: 1000,
+			FeeBaseMsat:               1000,
+		}
+	)
+	bobData, err := (&blindedpath.EncryptedData{
+		ShortChannelID: &chanID,
+		PaymentRelay:   relay,
+		PaymentConstraints: &blindedpath.PaymentConstraints{
+			MaxCltvExpiry:   1000,
+			HtlcMinimumMsat: 1,
+		},
+	}).Encode()
+	require.NoError(t, err)
+	carolData, err := (&blindedpath.EncryptedData{
+		PathID: payAddr[:],
+	}).Encode()
+	require.NoError(t, err)
+
+	sessionKey, err := btcec.NewPrivateKey(btcec.S256())
+	require.NoError(t, err)
+	path, err := blindedpath.BuildBlindedPath(sessionKey, []*blindedpath.HopInfo{
+		{NodePub: bobKey.PubKey(), PlainText: bobData},
+		{NodePub: carolKey.PubKey(), PlainText: carolData},
+	})
+	require.NoError(t, err)
+
+	// The sender reaches Bob using his real node ID, and Carol using her
+	// blinded one.
+	const (
+		amt  = lnwire.MilliSatoshi(100_000)
+		cltv = uint32(500)
+	)
+	bobPayload := encodeTestPayload(t,
+		record.NewEncryptedDataRecord(&path.BlindedHops[0].CipherText),
+		record.NewBlindingPointRecord(&path.BlindingPoint),
+	)
+
+	amtToFwd, outgoingCltv, totalAmt := uint64(amt), cltv, uint64(amt)
+	carolPayload := encodeTestPayload(t,
+		record.NewAmtToFwdRecord(&amtToFwd),
+		record.NewLockTimeRecord(&outgoingCltv),
+		record.NewEncryptedDataRecord(&path.BlindedHops[1].CipherText),
+		record.NewTotalAmtMsatBlindedRecord(&totalAmt),
+	)
+
+	var onionPath sphinx.PaymentPath
+	onionPath[0] = sphinx.OnionHop{
+		NodePub:    *bobKey.PubKey(),
+		HopPayload: bobPayload,
+	}
+	onionPath[1] = sphinx.OnionHop{
+		NodePub:    *path.BlindedHops[1].BlindedNodePub,
+		HopPayload: carolPayload,
+	}
+
+	onionKey, err := btcec.NewPrivateKey(btcec.S256())
+	require.NoError(t, err)
+
+	rHash := bytes.Repeat([]byte{0xaa}, 32)
+	onion, err := sphinx.NewOnionPacket(
+		&onionPath, onionKey, rHash, sphinx.DeterministicPacketFiller,
+	)
+	require.NoError(t, err)
+
+	var onionBlob bytes.Buffer
+	require.NoError(t, onion.Encode(&onionBlob))
+
+	// Bob derives the amount and expiry of the outgoing HTLC from the
+	// relay parameters Carol picked for him.
+	incomingAmt := amt + lnwire.MilliSatoshi(relay.FeeBaseMsat) + 100
+	bobIterator := processTestOnion(t, bobKey, DecodeHopIteratorRequest{
+		OnionReader:    &onionBlob,
+		RHash:          rHash,
+		IncomingCltv:   cltv + uint32(relay.CltvExpiryDelta),
+		IncomingAmount: incomingAmt,
+	})
+
+	payload, err := bobIterator.HopPayload()
+	require.NoError(t, err)
+
+	fwdInfo := payload.ForwardingInfo()
+	require.Equal(t, chanID, fwdInfo.NextHop)
+	require.Equal(t, amt, fwdInfo.AmountToForward)
+	require.Equal(t, cltv, fwdInfo.OutgoingCTLV)
+	require.NotNil(t, fwdInfo.NextBlinding)
+
+	var nextOnion bytes.Buffer
+	require.NoError(t, bobIterator.EncodeNextHop(&nextOnion))
+
+	// Carol receives the blinding point along with the HTLC and treats
+	// the payment like a regular multi-path payment.
+	carolIterator := processTestOnion(t, carolKey, DecodeHopIteratorRequest{
+		OnionReader:    &nextOnion,
+		RHash:          rHash,
+		IncomingCltv:   fwdInfo.OutgoingCTLV,
+		IncomingAmount: fwdInfo.AmountToForward,
+		BlindingPoint:  fwdInfo.NextBlinding,
+	})
+
+	payload, err = carolIterator.HopPayload()
+	require.NoError(t, err)
+	require.Equal(t, Exit, payload.ForwardingInfo().NextHop)
+	require.Equal(t, amt, payload.ForwardingInfo().AmountToForward)
+	require.Equal(t, amt, payload.MultiPath().TotalMsat())
+	require.Equal(t, payAddr, payload.MultiPath().PaymentAddr())
+
+	// Bob is not able to process the payload without the blinding point
+	// from the onion.
+	bobIterator.processedPacket.Payload.Payload = carolPayload.Payload
+	_, err = bobIterator.HopPayload()
+	require.Error(t, err)
+}
+
+// encodeTestPayload encodes the passed records as a TLV hop payload.
+func encodeTestPayload(t *testing.T, records ...tlv.Record) sphinx.HopPayload {
+	t.Helper()
+
+	stream, err := tlv.NewStream(records...)
+	require.NoError(t, err)
+
+	var b bytes.Buffer
+	require.NoError(t, stream.Encode(&b))
+
+	payload, err := sphinx.NewHopPayload(nil, b.Bytes())
+	require.NoError(t, err)
+
+	return payload
+}
+
+// processTestOnion processes the onion of the passed request with a fresh
+// onion processor using the given node key.
+func processTestOnion(t *testing.T, nodeKey *btcec.PrivateKey,
+	req DecodeHopIteratorRequest) *sphinxHopIterator {
+
+	t.Helper()
+
+	router := sphinx.NewRouter(
+		nodeKey, &chaincfg.RegressionNetParams,
+		sphinx.NewMemoryReplayLog(),
+	)
+	require.NoError(t, router.Start())
+	t.Cleanup(router.Stop)
+
+	processor := NewOnionProcessor(
+		router, &keychain.PrivKeyECDH{PrivKey: nodeKey},
+	)
+
+	resps, err := processor.DecodeHopIterators(
+		[]byte{1}, []DecodeHopIteratorRequest{req},
+	)
+	require.NoError(t, err)
+
+	iterator, failCode := resps[0].Result()
+	require.Equal(t, lnwire.CodeNone, failCode)
+
+	return iterator.(*sphinxHopIterator)
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 
+	"github.com/brsuite/brond/btcec"
 	sphinx "github.com/brsuite/lightning-onion"
 	"github.com/brsuite/broln/lnwire"
 	"github.com/brsuite/broln/record"
@@ -93,6 +94,19 @@ type Payload struct {
 	// customRecords are user-defined records in the custom type range that
 	// were included in the payload.
 	customRecords record.CustomSet
+
+	// encryptedData is the encrypted_recipient_data the recipient left
+	// for our hop of a blinded route.
+	encryptedData []byte
+
+	// blindingPoint is the current_path_key the sender included for us,
+	// which is only the case if we're the introduction node of a blinded
+	// route.
+	blindingPoint *btcec.PublicKey
+
+	// totalAmtMsat is the total amount the final hop of a blinded route
+	// should receive.
+	totalAmtMsat lnwire.MilliSatoshi
 }
 
 // NewLegacyPayload builds a Payload from the amount, cltv, and next hop
@@ -115,11 +129,14 @@ func NewLegacyPayload(f *sphinx.HopData) *Payload {
 // should correspond to the bytes encapsulated in a TLV onion payload.
 func NewPayloadFromReader(r io.Reader) (*Payload, error) {
 	var (
-		cid  uint64
-		amt  uint64
-		cltv uint32
-		mpp  = &record.MPP{}
-		amp  = &record.AMP{}
+		cid           uint64
+		amt           uint64
+		cltv          uint32
+		mpp           = &record.MPP{}
+		amp           = &record.AMP{}
+		encryptedData []byte
+		blindingPoint *btcec.PublicKey
+		totalAmt      uint64
 	)
 
 	tlvStream, err := tlv.NewStream(
@@ -127,7 +144,10 @@ func NewPayloadFromReader(r io.Reader) (*Payload, error) {
 		record.NewLockTimeRecord(&cltv),
 		record.NewNextHopIDRecord(&cid),
 		mpp.Record(),
+		record.NewEncryptedDataRecord(&encryptedData),
+		record.NewBlindingPointRecord(&blindingPoint),
 		amp.Record(),
+		record.NewTotalAmtMsatBlindedRecord(&totalAmt),
 	)
 	if err != nil {
 		return nil, err
@@ -139,9 +159,16 @@ func NewPayloadFromReader(r io.Reader) (*Payload, error) {
 	}
 
 	// Validate whether the sender properly included or omitted tlv records
-	// in accordance with BOLT 04.
+	// in accordance with BOLT 04. Hops of a blinded route receive most of
+	// their forwarding instructions from the recipient instead, so they
+	// follow different rules.
 	nextHop := lnwire.NewShortChanIDFromInt(cid)
-	err = ValidateParsedPayloadTypes(parsedTypes, nextHop)
+	_, isBlinded := parsedTypes[record.EncryptedDataOnionType]
+	if isBlinded {
+		err = ValidateBlindedPayloadTypes(parsedTypes)
+	} else {
+		err = ValidateParsedPayloadTypes(parsedTypes, nextHop)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -168,6 +195,12 @@ func NewPayloadFromReader(r io.Reader) (*Payload, error) {
 		amp = nil
 	}
 
+	// Only the introduction node of a blinded route receives its blinding
+	// point within the onion.
+	if _, ok := parsedTypes[record.BlindingPointOnionType]; !ok {
+		blindingPoint = nil
+	}
+
 	// Filter out the custom records.
 	customRecords := NewCustomRecords(parsedTypes)
 
@@ -181,6 +214,9 @@ func NewPayloadFromReader(r io.Reader) (*Payload, error) {
 		MPP:           mpp,
 		AMP:           amp,
 		customRecords: customRecords,
+		encryptedData: encryptedData,
+		blindingPoint: blindingPoint,
+		totalAmtMsat:  lnwire.MilliSatoshi(totalAmt),
 	}, nil
 }
 
@@ -266,6 +302,85 @@ func ValidateParsedPayloadTypes(parsedTypes tlv.TypeMap,
 	return nil
 }
 
+// ValidateBlindedPayloadTypes checks the types parsed from the payload of a hop
+// within a blinded route to ensure that the proper fields are either included
+// or omitted. Only the final hop of a blinded route is given an amount and
+// cltv expiry by the sender, along with the total amount of the payment, while
+// all other hops derive them from the encrypted data the recipient left for
+// them. The requirements for this method are described in BOLT 04.
+func ValidateBlindedPayloadTypes(parsedTypes tlv.TypeMap) error {
+	_, hasAmt := parsedTypes[record.AmtOnionType]
+	_, hasLockTime := parsedTypes[record.LockTimeOnionType]
+	_, hasNextHop := parsedTypes[record.NextHopOnionType]
+	_, hasMPP := parsedTypes[record.MPPOnionType]
+	_, hasAMP := parsedTypes[record.AMPOnionType]
+	_, hasTotalAmt := parsedTypes[record.TotalAmtMsatBlindedType]
+
+	isFinalHop := hasTotalAmt
+
+	switch {
+
+	// The next hop is always given by the recipient.
+	case hasNextHop:
+		return ErrInvalidPayload{
+			Type:      record.NextHopOnionType,
+			Violation: IncludedViolation,
+			FinalHop:  isFinalHop,
+		}
+
+	// The final hop must include an amount to forward.
+	case isFinalHop && !hasAmt:
+		return ErrInvalidPayload{
+			Type:      record.AmtOnionType,
+			Violation: OmittedViolation,
+			FinalHop:  true,
+		}
+
+	// The final hop must include a cltv expiry.
+	case isFinalHop && !hasLockTime:
+		return ErrInvalidPayload{
+			Type:      record.LockTimeOnionType,
+			Violation: OmittedViolation,
+			FinalHop:  true,
+		}
+
+	// Intermediate hops derive the amount to forward from the encrypted
+	// data.
+	case !isFinalHop && hasAmt:
+		return ErrInvalidPayload{
+			Type:      record.AmtOnionType,
+			Violation: IncludedViolation,
+			FinalHop:  false,
+		}
+
+	// Intermediate hops derive the cltv expiry from the encrypted data.
+	case !isFinalHop && hasLockTime:
+		return ErrInvalidPayload{
+			Type:      record.LockTimeOnionType,
+			Violation: IncludedViolation,
+			FinalHop:  false,
+		}
+
+	// The total amount replaces the MPP record within blinded routes.
+	case hasMPP:
+		return ErrInvalidPayload{
+			Type:      record.MPPOnionType,
+			Violation: IncludedViolation,
+			FinalHop:  isFinalHop,
+		}
+
+	// Blinded routes can't be used for AMP payments.
+	case hasAMP:
+		return ErrInvalidPayload{
+			Type:      record.AMPOnionType,
+			Violation: IncludedViolation,
+			FinalHop:  isFinalHop,
+		}
+	}
+
+	return nil
+}
+
 // MultiPath returns the record corresponding the option_mpp parsed from the
 // onion payload.
 func (h *Payload) MultiPath() *record.MPP {
@@ -284,6 +399,24 @@ func (h *Payload) CustomRecords() record.CustomSet {
 	return h.customRecords
 }
 
+// EncryptedData returns the encrypted data the recipient left for our hop of a
+// blinded route, or nil if the payload doesn't belong to a blinded route.
+func (h *Payload) EncryptedData() []byte {
+	return h.encryptedData
+}
+
+// BlindingPoint returns the blinding point the sender included in the payload,
+// which is only the case if we're the introduction node of a blinded route.
+func (h *Payload) BlindingPoint() *btcec.PublicKey {
+	return h.blindingPoint
+}
+
+// TotalAmtMsat returns the total amount the final hop of a blinded route
+// should receive.
+func (h *Payload) TotalAmtMsat() lnwire.MilliSatoshi {
+	return h.totalAmtMsat
+}
+
 // getMinRequiredViolation checks for unrecognized required (even) fields in the
 // standard range and returns the lowest required type. Always returning the
 // lowest required type allows a failure message to be deterministic.
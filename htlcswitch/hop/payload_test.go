@@ -258,6 +258,60 @@ var decodePayloadTests = []decodePayloadTest{
 		},
 		shouldHaveAMP: true,
 	},
+	{
+		name:    "blinded intermediate hop valid",
+		payload: []byte{0x0a, 0x01, 0xaa},
+	},
+	{
+		name:    "blinded intermediate hop amount present",
+		payload: []byte{0x02, 0x00, 0x0a, 0x01, 0xaa},
+		expErr: hop.ErrInvalidPayload{
+			Type:      record.AmtOnionType,
+			Violation: hop.IncludedViolation,
+			FinalHop:  false,
+		},
+	},
+	{
+		name: "blinded hop next sid present",
+		payload: []byte{0x06, 0x08, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x0a, 0x01, 0xaa,
+		},
+		expErr: hop.ErrInvalidPayload{
+			Type:      record.NextHopOnionType,
+			Violation: hop.IncludedViolation,
+			FinalHop:  false,
+		},
+	},
+	{
+		name: "blinded final hop valid",
+		payload: []byte{0x02, 0x00, 0x04, 0x00, 0x0a, 0x01, 0xaa, 0x12,
+			0x00,
+		},
+	},
+	{
+		name:    "blinded final hop no expiry",
+		payload: []byte{0x02, 0x00, 0x0a, 0x01, 0xaa, 0x12, 0x00},
+		expErr: hop.ErrInvalidPayload{
+			Type:      record.LockTimeOnionType,
+			Violation: hop.OmittedViolation,
+			FinalHop:  true,
+		},
+	},
+	{
+		name: "blinded final hop with mpp",
+		payload: []byte{0x02, 0x00, 0x04, 0x00, 0x08, 0x21,
+			0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x11,
+			0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x11,
+			0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x11,
+			0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x11,
+			0x08, 0x0a, 0x01, 0xaa, 0x12, 0x00,
+		},
+		expErr: hop.ErrInvalidPayload{
+			Type:      record.MPPOnionType,
+			Violation: hop.IncludedViolation,
+			FinalHop:  true,
+		},
+	},
 }
 
 // TestDecodeHopPayloadRecordValidation asserts that parsing the payloads in the
@@ -3,8 +3,10 @@ package htlcswitch
 import (
 	"fmt"
 	"sync"
+	"sync/atomic"
 
 	"github.com/go-errors/errors"
+	"github.com/brsuite/broln/chainntnfs"
 	"github.com/brsuite/broln/channeldb"
 	"github.com/brsuite/broln/htlcswitch/hop"
 	"github.com/brsuite/broln/lntypes"
@@ -15,39 +17,292 @@ var (
 	// ErrFwdNotExists is an error returned when the caller tries to resolve
 	// a forward that doesn't exist anymore.
 	ErrFwdNotExists = errors.New("forward does not exist")
+
+	// ErrOutgoingAmtExceedsIncoming is an error returned when the caller
+	// tries to resume a forward with an outgoing amount that exceeds the
+	// incoming amount of the htlc.
+	ErrOutgoingAmtExceedsIncoming = errors.New("outgoing amount exceeds " +
+		"incoming amount")
 )
 
+// InterceptableSwitchConfig contains the configuration of
+// InterceptableSwitch.
+type InterceptableSwitchConfig struct {
+	// Switch is a reference to the actual switch implementation that
+	// packets get sent to on resume.
+	Switch *Switch
+
+	// Notifier is an instance of a chain notifier that we'll use to be
+	// notified of new blocks, in order to fail held htlcs that are about
+	// to expire.
+	Notifier chainntnfs.ChainNotifier
+
+	// CltvRejectDelta defines the number of blocks before the expiry of an
+	// intercepted htlc at which it is failed back, to prevent the incoming
+	// channel from being force closed. If zero, intercepted htlcs are
+	// never failed automatically.
+	CltvRejectDelta uint32
+
+	// RequireInterceptor indicates whether forwards should be held while
+	// no interceptor is connected. If set, held forwards are handed to the
+	// next interceptor that connects, rather than being resumed once the
+	// interceptor disconnects.
+	//
+	// NOTE: Held forwards survive restarts, as the adds of the incoming
+	// link remain unacknowledged within its forwarding packages until the
+	// forward is resolved. They are replayed to the switch and thus held
+	// again once the link starts.
+	RequireInterceptor bool
+}
+
 // InterceptableSwitch is an implementation of ForwardingSwitch interface.
 // This implementation is used like a proxy that wraps the switch and
 // intercepts forward requests. A reference to the Switch is held in order
 // to communicate back the interception result where the options are:
 // Resume - forwards the original request to the switch as is.
+// ResumeModified - forwards a modified request to the switch.
 // Settle - routes UpdateFulfillHTLC to the originating link.
 // Fail - routes UpdateFailHTLC to the originating link.
 type InterceptableSwitch struct {
+	started sync.Once
+	stopped sync.Once
+
+	// currentHeight is the height of the best block known to the switch.
+	//
+	// NOTE: This MUST be used atomically.
+	currentHeight uint32
+
 	sync.RWMutex
 
 	// htlcSwitch is the underline switch
 	htlcSwitch *Switch
 
+	// notifier is used to subscribe to new blocks.
+	notifier chainntnfs.ChainNotifier
+
+	// cltvRejectDelta is the number of blocks before the expiry of a held
+	// htlc at which it is failed back.
+	cltvRejectDelta uint32
+
+	// requireInterceptor indicates whether forwards are held while no
+	// interceptor is connected.
+	requireInterceptor bool
+
 	// fwdInterceptor is the callback that is called for each forward of
 	// an incoming htlc. It should return true if it is interested in handling
 	// it.
 	fwdInterceptor ForwardInterceptor
+
+	// heldForwards is the set of intercepted forwards that haven't been
+	// resolved yet, keyed by their incoming circuit.
+	heldForwards map[channeldb.CircuitKey]*interceptedForward
+
+	quit chan struct{}
+	wg   sync.WaitGroup
 }
 
 // NewInterceptableSwitch returns an instance of InterceptableSwitch.
-func NewInterceptableSwitch(s *Switch) *InterceptableSwitch {
-	return &InterceptableSwitch{htlcSwitch: s}
+func NewInterceptableSwitch(cfg *InterceptableSwitchConfig) *InterceptableSwitch {
+	return &InterceptableSwitch{
+		htlcSwitch:         cfg.Switch,
+		notifier:           cfg.Notifier,
+		cltvRejectDelta:    cfg.CltvRejectDelta,
+		requireInterceptor: cfg.RequireInterceptor,
+		heldForwards: make(
+			map[channeldb.CircuitKey]*interceptedForward,
+		),
+		quit: make(chan struct{}),
+	}
+}
+
+// Start starts the InterceptableSwitch, which subscribes to new blocks in
+// order to fail held htlcs that are about to expire.
+func (s *InterceptableSwitch) Start() error {
+	var err error
+	s.started.Do(func() {
+		var blockEpochStream *chainntnfs.BlockEpochEvent
+		blockEpochStream, err = s.notifier.RegisterBlockEpochNtfn(nil)
+		if err != nil {
+			return
+		}
+
+		s.wg.Add(1)
+		go s.run(blockEpochStream)
+	})
+
+	return err
+}
+
+// Stop signals the InterceptableSwitch to gracefully shut down.
+func (s *InterceptableSwitch) Stop() error {
+	s.stopped.Do(func() {
+		close(s.quit)
+		s.wg.Wait()
+	})
+
+	return nil
+}
+
+// run is the main loop of the InterceptableSwitch, which fails back held
+// htlcs once they get too close to their expiry.
+//
+// NOTE: This MUST be run as a goroutine.
+func (s *InterceptableSwitch) run(blockEpochStream *chainntnfs.BlockEpochEvent) {
+	defer s.wg.Done()
+	defer blockEpochStream.Cancel()
+
+	for {
+		select {
+		case blockEpoch, ok := <-blockEpochStream.Epochs:
+			if !ok {
+				return
+			}
+
+			atomic.StoreUint32(
+				&s.currentHeight, uint32(blockEpoch.Height),
+			)
+			s.failExpiringForwards()
+
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// expiresSoon returns true if an htlc with the given incoming expiry is too
+// close to expiry to be held any longer.
+func (s *InterceptableSwitch) expiresSoon(incomingExpiry uint32) bool {
+	if s.cltvRejectDelta == 0 {
+		return false
+	}
+
+	currentHeight := atomic.LoadUint32(&s.currentHeight)
+	return incomingExpiry <= currentHeight+s.cltvRejectDelta
+}
+
+// failExpiringForwards fails back all held forwards that are too close to
+// expiry to be held any longer.
+func (s *InterceptableSwitch) failExpiringForwards() {
+	var expiring []*interceptedForward
+
+	s.Lock()
+	for key, fwd := range s.heldForwards {
+		if !s.expiresSoon(fwd.packet.incomingTimeout) {
+			continue
+		}
+
+		expiring = append(expiring, fwd)
+		delete(s.heldForwards, key)
+	}
+	s.Unlock()
+
+	for _, fwd := range expiring {
+		log.Infof("Failing intercepted forward %v, as it expires at "+
+			"height %v", fwd.incomingCircuit(),
+			fwd.packet.incomingTimeout)
+
+		if err := fwd.fail(); err != nil {
+			log.Errorf("Unable to fail intercepted forward %v: %v",
+				fwd.incomingCircuit(), err)
+		}
+	}
 }
 
-// SetInterceptor sets the ForwardInterceptor to be used.
+// SetInterceptor sets the ForwardInterceptor to be used. All forwards held
+// so far are handed to a newly set interceptor. If the interceptor is unset
+// instead, held forwards are resumed unless we're required to hold them until
+// another interceptor is set.
 func (s *InterceptableSwitch) SetInterceptor(
 	interceptor ForwardInterceptor) {
 
 	s.Lock()
-	defer s.Unlock()
 	s.fwdInterceptor = interceptor
+
+	heldForwards := make([]*interceptedForward, 0, len(s.heldForwards))
+	for _, fwd := range s.heldForwards {
+		heldForwards = append(heldForwards, fwd)
+	}
+	s.Unlock()
+
+	if len(heldForwards) == 0 {
+		return
+	}
+
+	// The interceptor may block while we hand it the forwards, so we'll
+	// do so in the background.
+	if interceptor != nil {
+		log.Infof("Replaying %v held forwards to interceptor",
+			len(heldForwards))
+
+		s.wg.Add(1)
+		go s.replayForwards(interceptor, heldForwards)
+
+		return
+	}
+
+	if s.requireInterceptor {
+		log.Infof("Interceptor disconnected, holding %v forwards until "+
+			"an interceptor connects", len(heldForwards))
+		return
+	}
+
+	log.Infof("Interceptor disconnected, resuming %v held forwards",
+		len(heldForwards))
+
+	for _, fwd := range heldForwards {
+		err := fwd.Resume()
+		if err != nil && !errors.Is(err, ErrFwdNotExists) {
+			log.Errorf("Unable to resume held forward %v: %v",
+				fwd.incomingCircuit(), err)
+		}
+	}
+}
+
+// replayForwards hands the given held forwards to the interceptor.
+//
+// NOTE: This MUST be run as a goroutine.
+func (s *InterceptableSwitch) replayForwards(interceptor ForwardInterceptor,
+	heldForwards []*interceptedForward) {
+
+	defer s.wg.Done()
+
+	for _, fwd := range heldForwards {
+		// Skip any forward that was resolved in the meantime.
+		s.RLock()
+		_, ok := s.heldForwards[fwd.incomingCircuit()]
+		s.RUnlock()
+		if !ok {
+			continue
+		}
+
+		if interceptor(fwd) || s.requireInterceptor {
+			continue
+		}
+
+		// The interceptor isn't interested in the forward, so we'll
+		// carry on with the default behavior.
+		err := fwd.Resume()
+		if err != nil && !errors.Is(err, ErrFwdNotExists) {
+			log.Errorf("Unable to resume held forward %v: %v",
+				fwd.incomingCircuit(), err)
+		}
+	}
+}
+
+// release removes the forward of the given incoming circuit from the set of
+// held forwards, returning the forward if it was held.
+func (s *InterceptableSwitch) release(
+	key channeldb.CircuitKey) (*interceptedForward, bool) {
+
+	s.Lock()
+	defer s.Unlock()
+
+	fwd, ok := s.heldForwards[key]
+	if ok {
+		delete(s.heldForwards, key)
+	}
+
+	return fwd, ok
 }
 
 // ForwardPackets attempts to forward the batch of htlcs through the
@@ -62,8 +317,9 @@ func (s *InterceptableSwitch) ForwardPackets(linkQuit chan struct{},
 	interceptor = s.fwdInterceptor
 	s.Unlock()
 
-	// Optimize for the case we don't have an interceptor.
-	if interceptor == nil {
+	// Optimize for the case we don't have an interceptor, and aren't
+	// required to hold forwards until one connects.
+	if interceptor == nil && !s.requireInterceptor {
 		return s.htlcSwitch.ForwardPackets(linkQuit, packets...)
 	}
 
@@ -91,14 +347,59 @@ func (s *InterceptableSwitch) interceptForward(packet *htlcPacket,
 		}
 
 		intercepted := &interceptedForward{
-			linkQuit:   linkQuit,
-			htlc:       htlc,
-			packet:     packet,
-			htlcSwitch: s.htlcSwitch,
+			linkQuit:            linkQuit,
+			htlc:                htlc,
+			packet:              packet,
+			htlcSwitch:          s.htlcSwitch,
+			interceptableSwitch: s,
+		}
+
+		// Holding an htlc that is about to expire would force close
+		// the incoming channel, so we fail it back right away.
+		if s.expiresSoon(packet.incomingTimeout) {
+			log.Debugf("Failing forward %v, as it expires at height "+
+				"%v", intercepted.incomingCircuit(),
+				packet.incomingTimeout)
+
+			if err := intercepted.fail(); err != nil {
+				log.Errorf("Unable to fail forward %v: %v",
+					intercepted.incomingCircuit(), err)
+			}
+
+			return true
+		}
+
+		// We'll track the forward before handing it to the interceptor,
+		// so it can be resolved right away. If we already hold the
+		// forward, the packet was replayed by the incoming link. We'll
+		// then replace the packet with the latest one, but won't hand
+		// it to the interceptor again.
+		inKey := intercepted.incomingCircuit()
+		s.Lock()
+		_, alreadyHeld := s.heldForwards[inKey]
+		s.heldForwards[inKey] = intercepted
+		s.Unlock()
+
+		if alreadyHeld {
+			return true
 		}
 
 		// If this htlc was intercepted, don't handle the forward.
-		return interceptor(intercepted)
+		if interceptor != nil && interceptor(intercepted) {
+			return true
+		}
+
+		// Otherwise we'll keep holding it until an interceptor
+		// connects if required.
+		if s.requireInterceptor {
+			log.Debugf("Holding forward %v until an interceptor "+
+				"connects", inKey)
+			return true
+		}
+
+		s.release(inKey)
+
+		return false
 	default:
 		return false
 	}
@@ -108,37 +409,125 @@ func (s *InterceptableSwitch) interceptForward(packet *htlcPacket,
 // It is passed from the switch to external interceptors that are interested
 // in holding forwards and resolve them manually.
 type interceptedForward struct {
-	linkQuit   chan struct{}
-	htlc       *lnwire.UpdateAddHTLC
-	packet     *htlcPacket
-	htlcSwitch *Switch
+	linkQuit            chan struct{}
+	htlc                *lnwire.UpdateAddHTLC
+	packet              *htlcPacket
+	htlcSwitch          *Switch
+	interceptableSwitch *InterceptableSwitch
+}
+
+// incomingCircuit returns the circuit key of the incoming htlc.
+func (f *interceptedForward) incomingCircuit() channeldb.CircuitKey {
+	return channeldb.CircuitKey{
+		ChanID: f.packet.incomingChanID,
+		HtlcID: f.packet.incomingHTLCID,
+	}
+}
+
+// release stops the forward from being held by the switch, returning the
+// latest forward of the incoming htlc. ErrFwdNotExists is returned if the
+// forward was resolved already.
+func (f *interceptedForward) release() (*interceptedForward, error) {
+	fwd, ok := f.interceptableSwitch.release(f.incomingCircuit())
+	if !ok {
+		return nil, ErrFwdNotExists
+	}
+
+	return fwd, nil
 }
 
 // Packet returns the intercepted htlc packet.
 func (f *interceptedForward) Packet() InterceptedPacket {
 	return InterceptedPacket{
-		IncomingCircuit: channeldb.CircuitKey{
-			ChanID: f.packet.incomingChanID,
-			HtlcID: f.packet.incomingHTLCID,
-		},
-		OutgoingChanID: f.packet.outgoingChanID,
-		Hash:           f.htlc.PaymentHash,
-		OutgoingExpiry: f.htlc.Expiry,
-		OutgoingAmount: f.htlc.Amount,
-		IncomingAmount: f.packet.incomingAmount,
-		IncomingExpiry: f.packet.incomingTimeout,
-		CustomRecords:  f.packet.customRecords,
-		OnionBlob:      f.htlc.OnionBlob,
+		IncomingCircuit: f.incomingCircuit(),
+		OutgoingChanID:  f.packet.outgoingChanID,
+		Hash:            f.htlc.PaymentHash,
+		OutgoingExpiry:  f.htlc.Expiry,
+		OutgoingAmount:  f.htlc.Amount,
+		IncomingAmount:  f.packet.incomingAmount,
+		IncomingExpiry:  f.packet.incomingTimeout,
+		CustomRecords:   f.packet.customRecords,
+		OnionBlob:       f.htlc.OnionBlob,
 	}
 }
 
 // Resume resumes the default behavior as if the packet was not intercepted.
 func (f *interceptedForward) Resume() error {
-	return f.htlcSwitch.ForwardPackets(f.linkQuit, f.packet)
+	fwd, err := f.release()
+	if err != nil {
+		return err
+	}
+
+	return f.htlcSwitch.ForwardPackets(fwd.linkQuit, fwd.packet)
+}
+
+// ResumeModified resumes the forward of the packet, after replacing its
+// outgoing channel, amount and custom records with the non-zero values
+// given. The forward is left held if the modification is invalid.
+func (f *interceptedForward) ResumeModified(
+	outgoingChanID lnwire.ShortChannelID,
+	outgoingAmount lnwire.MilliSatoshi,
+	customRecords lnwire.CustomRecords) error {
+
+	// The outgoing channel may have been opened while the packet was
+	// held, so we only require the link to be known to the switch by the
+	// time the packet is resumed.
+	if outgoingChanID.ToUint64() != 0 {
+		_, err := f.htlcSwitch.GetLinkByShortID(outgoingChanID)
+		if err != nil {
+			return fmt.Errorf("unable to find outgoing link "+
+				"%v: %v", outgoingChanID, err)
+		}
+	}
+
+	// Forwarding more than we received would have us pay for the
+	// difference.
+	if outgoingAmount > f.packet.incomingAmount {
+		return fmt.Errorf("%w: outgoing=%v, incoming=%v",
+			ErrOutgoingAmtExceedsIncoming, outgoingAmount,
+			f.packet.incomingAmount)
+	}
+
+	if err := customRecords.Validate(); err != nil {
+		return err
+	}
+
+	fwd, err := f.release()
+	if err != nil {
+		return err
+	}
+
+	packet := *fwd.packet
+	if outgoingChanID.ToUint64() != 0 {
+		packet.outgoingChanID = outgoingChanID
+	}
+
+	htlc := *fwd.htlc
+	if outgoingAmount != 0 {
+		htlc.Amount = outgoingAmount
+		packet.amount = outgoingAmount
+	}
+
+	if customRecords != nil {
+		htlc.CustomRecords = customRecords
+	}
+	packet.htlc = &htlc
+
+	return f.htlcSwitch.ForwardPackets(fwd.linkQuit, &packet)
 }
 
 // Fail forward a failed packet to the switch.
 func (f *interceptedForward) Fail() error {
+	fwd, err := f.release()
+	if err != nil {
+		return err
+	}
+
+	return fwd.fail()
+}
+
+// fail fails the htlc back to the incoming link.
+func (f *interceptedForward) fail() error {
 	update, err := f.htlcSwitch.cfg.FetchLastChannelUpdate(
 		f.packet.incomingChanID,
 	)
@@ -162,7 +551,13 @@ func (f *interceptedForward) Settle(preimage lntypes.Preimage) error {
 	if !preimage.Matches(f.htlc.PaymentHash) {
 		return errors.New("preimage does not match hash")
 	}
-	return f.resolve(&lnwire.UpdateFulfillHTLC{
+
+	fwd, err := f.release()
+	if err != nil {
+		return err
+	}
+
+	return fwd.resolve(&lnwire.UpdateFulfillHTLC{
 		PaymentPreimage: preimage,
 	})
 }
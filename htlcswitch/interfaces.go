@@ -150,6 +150,11 @@ type ChannelLink interface {
 	// the original funding output can be found.
 	ShortChanID() lnwire.ShortChannelID
 
+	// IsOptionScidAlias returns true if the option_scid_alias channel type
+	// was negotiated for the channel. Such channels may only be forwarded
+	// over using one of their aliases.
+	IsOptionScidAlias() bool
+
 	// UpdateShortChanID updates the short channel ID for a link. This may
 	// be required in the event that a link is created before the short
 	// chan ID for it is known, or a re-org occurs, and the funding
@@ -283,7 +288,7 @@ type InterceptedPacket struct {
 // htlc. It contains all the information about the packet which accordingly
 // the interceptor decides if to hold or not.
 // In addition this interface allows a later resolution by calling either
-// Resume, Settle or Fail.
+// Resume, ResumeModified, Settle or Fail.
 type InterceptedForward interface {
 	// Packet returns the intercepted packet.
 	Packet() InterceptedPacket
@@ -293,6 +298,13 @@ type InterceptedForward interface {
 	// this htlc which usually means forward it.
 	Resume() error
 
+	// ResumeModified notifies the intention to resume an existing hold
+	// forward with a modified outgoing channel, amount and custom records.
+	// Zero values leave the corresponding part of the forward unmodified.
+	ResumeModified(outgoingChanID lnwire.ShortChannelID,
+		outgoingAmount lnwire.MilliSatoshi,
+		customRecords lnwire.CustomRecords) error
+
 	// Settle notifies the intention to settle an existing hold
 	// forward with a given preimage.
 	Settle(lntypes.Preimage) error
@@ -105,6 +105,14 @@ type ChanClose struct {
 	// process for the cooperative closure transaction kicks off.
 	TargetFeePerKw chainfee.SatPerKWeight
 
+	// MinFeePerKw is an optional lower bound of the fee rate we accept for
+	// the cooperative closure transaction.
+	MinFeePerKw chainfee.SatPerKWeight
+
+	// MaxFeePerKw is an optional upper bound of the fee rate we accept for
+	// the cooperative closure transaction.
+	MaxFeePerKw chainfee.SatPerKWeight
+
 	// DeliveryScript is an optional delivery script to pay funds out to.
 	DeliveryScript lnwire.DeliveryAddress
 
@@ -200,6 +208,27 @@ type Config struct {
 	// DustThreshold is the threshold in milli-satoshis after which we'll
 	// fail incoming or outgoing dust payments for a particular channel.
 	DustThreshold lnwire.MilliSatoshi
+
+	// GetAliases returns the set of alias ShortChannelIDs that map to the
+	// passed base ShortChannelID. Links are reachable in the forwarding
+	// index through any of their aliases. If nil, links are only indexed
+	// by their base ShortChannelID.
+	GetAliases func(base lnwire.ShortChannelID) ([]lnwire.ShortChannelID,
+		error)
+}
+
+// ForwardingStats houses the running totals of the HTLCs forwarded by the
+// switch since it was started.
+type ForwardingStats struct {
+	// NumSettled is the number of forwarded HTLCs that were settled.
+	NumSettled uint64
+
+	// NumFailed is the number of forwarded HTLCs that were failed, either
+	// by the switch itself or by a downstream node.
+	NumFailed uint64
+
+	// FeesEarned is the sum of the fees earned by the settled forwards.
+	FeesEarned lnwire.MilliSatoshi
 }
 
 // Switch is the central messaging bus for all incoming/outgoing HTLCs.
@@ -288,6 +317,11 @@ type Switch struct {
 	fwdEventMtx         sync.Mutex
 	pendingFwdingEvents []channeldb.ForwardingEvent
 
+	// fwdStats are the running totals of the HTLCs forwarded since the
+	// switch was started.
+	fwdStatsMtx sync.Mutex
+	fwdStats    ForwardingStats
+
 	// blockEpochStream is an active block epoch event stream backed by an
 	// active ChainNotifier instance. This will be used to retrieve the
 	// lastest height of the chain.
@@ -1054,6 +1088,23 @@ func (s *Switch) handlePacketForward(packet *htlcPacket) error {
 		interfaceLinks, _ := s.getLinks(targetPeerKey)
 		s.indexMtx.RUnlock()
 
+		// Channels that negotiated option_scid_alias must not be
+		// reachable through their confirmed short channel ID, as that
+		// would reveal their funding output to the sender.
+		if targetLink.IsOptionScidAlias() &&
+			!channeldb.IsAlias(packet.outgoingChanID) {
+
+			log.Debugf("Refusing to forward over option_scid_alias "+
+				"channel %v using its real short_chan_id %v",
+				targetLink.ChanID(), packet.outgoingChanID)
+
+			return s.failAddPacket(
+				packet, NewLinkError(
+					&lnwire.FailUnknownNextPeer{},
+				),
+			)
+		}
+
 		// We'll keep track of any HTLC failures during the link
 		// selection process. This way we can return the error for
 		// precise link that the sender selected, while optimistically
@@ -1264,9 +1315,23 @@ func (s *Switch) handlePacketForward(packet *htlcPacket) error {
 					},
 				)
 				s.fwdEventMtx.Unlock()
+
+				s.fwdStatsMtx.Lock()
+				s.fwdStats.NumSettled++
+				s.fwdStats.FeesEarned += circuit.IncomingAmount -
+					circuit.OutgoingAmount
+				s.fwdStatsMtx.Unlock()
 			}
 		}
 
+		// If this is an HTLC fail, and it wasn't from a locally
+		// initiated HTLC, then we'll count it as a failed forward.
+		if isFail && packet.incomingChanID != hop.Source {
+			s.fwdStatsMtx.Lock()
+			s.fwdStats.NumFailed++
+			s.fwdStatsMtx.Unlock()
+		}
+
 		// A blank IncomingChanID in a circuit indicates that it is a pending
 		// user-initiated payment.
 		if packet.incomingChanID == hop.Source {
@@ -1332,6 +1397,10 @@ func (s *Switch) failAddPacket(packet *htlcPacket, failure *LinkError) error {
 
 	log.Error(failure.Error())
 
+	s.fwdStatsMtx.Lock()
+	s.fwdStats.NumFailed++
+	s.fwdStatsMtx.Unlock()
+
 	// Create a failure packet for this htlc. The the full set of
 	// information about the htlc failure is included so that they can
 	// be included in link failure notifications.
@@ -1529,11 +1598,13 @@ func (s *Switch) teardownCircuit(pkt *htlcPacket) error {
 // CloseLink creates and sends the close channel command to the target link
 // directing the specified closure type. If the closure type is CloseRegular,
 // targetFeePerKw parameter should be the ideal fee-per-kw that will be used as
-// a starting point for close negotiation. The deliveryScript parameter is an
-// optional parameter which sets a user specified script to close out to.
+// a starting point for close negotiation. The optional minFeePerKw and
+// maxFeePerKw parameters bound the fee rates accepted during the negotiation.
+// The deliveryScript parameter is an optional parameter which sets a user
+// specified script to close out to.
 func (s *Switch) CloseLink(chanPoint *wire.OutPoint,
 	closeType contractcourt.ChannelCloseType,
-	targetFeePerKw chainfee.SatPerKWeight,
+	targetFeePerKw, minFeePerKw, maxFeePerKw chainfee.SatPerKWeight,
 	deliveryScript lnwire.DeliveryAddress) (chan interface{}, chan error) {
 
 	// TODO(roasbeef) abstract out the close updates.
@@ -1545,6 +1616,8 @@ func (s *Switch) CloseLink(chanPoint *wire.OutPoint,
 		ChanPoint:      chanPoint,
 		Updates:        updateChan,
 		TargetFeePerKw: targetFeePerKw,
+		MinFeePerKw:    minFeePerKw,
+		MaxFeePerKw:    maxFeePerKw,
 		DeliveryScript: deliveryScript,
 		Err:            errChan,
 	}
@@ -2098,6 +2171,9 @@ func (s *Switch) addLiveLink(link ChannelLink) {
 	// in the multi-hop setting.
 	s.linkIndex[link.ChanID()] = link
 	s.forwardingIndex[link.ShortChanID()] = link
+	for _, alias := range s.linkAliases(link) {
+		s.forwardingIndex[alias] = link
+	}
 
 	// Next we'll add the link to the interface index so we can
 	// quickly look up all the channels for a particular node.
@@ -2108,6 +2184,23 @@ func (s *Switch) addLiveLink(link ChannelLink) {
 	s.interfaceIndex[peerPub][link.ChanID()] = link
 }
 
+// linkAliases returns the alias ShortChannelIDs that are registered for the
+// base ShortChannelID of the passed link.
+func (s *Switch) linkAliases(link ChannelLink) []lnwire.ShortChannelID {
+	if s.cfg.GetAliases == nil {
+		return nil
+	}
+
+	aliases, err := s.cfg.GetAliases(link.ShortChanID())
+	if err != nil {
+		log.Errorf("Unable to fetch aliases for ChannelLink(%v): %v",
+			link.ChanID(), err)
+		return nil
+	}
+
+	return aliases
+}
+
 // GetLink is used to initiate the handling of the get link command. The
 // request will be propagated/handled to/in the main goroutine.
 func (s *Switch) GetLink(chanID lnwire.ChannelID) (ChannelUpdateHandler,
@@ -2198,6 +2291,9 @@ func (s *Switch) removeLink(chanID lnwire.ChannelID) ChannelLink {
 	delete(s.pendingLinkIndex, link.ChanID())
 	delete(s.linkIndex, link.ChanID())
 	delete(s.forwardingIndex, link.ShortChanID())
+	for _, alias := range s.linkAliases(link) {
+		delete(s.forwardingIndex, alias)
+	}
 
 	// If the link has been added to the peer index, then we'll move to
 	// delete the entry within the index.
@@ -2222,6 +2318,15 @@ func (s *Switch) UpdateShortChanID(chanID lnwire.ChannelID) error {
 	s.indexMtx.Lock()
 	defer s.indexMtx.Unlock()
 
+	// If the link is already live, its short channel ID won't change, but
+	// new aliases may have been registered for it, e.g. the confirmed
+	// short channel ID of a zero-conf channel. We'll re-add it so that
+	// the forwarding index picks them up.
+	if link, ok := s.linkIndex[chanID]; ok {
+		s.addLiveLink(link)
+		return nil
+	}
+
 	// Locate the target link in the pending link index. If no such link
 	// exists, then we will ignore the request.
 	link, ok := s.pendingLinkIndex[chanID]
@@ -2353,6 +2458,15 @@ func (s *Switch) FlushForwardingEvents() error {
 	return s.cfg.FwdingLog.AddForwardingEvents(events)
 }
 
+// ForwardingStats returns the running totals of the HTLCs forwarded since the
+// switch was started.
+func (s *Switch) ForwardingStats() ForwardingStats {
+	s.fwdStatsMtx.Lock()
+	defer s.fwdStatsMtx.Unlock()
+
+	return s.fwdStats
+}
+
 // BestHeight returns the best height known to the switch.
 func (s *Switch) BestHeight() uint32 {
 	return atomic.LoadUint32(&s.bestHeight)
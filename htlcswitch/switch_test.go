@@ -12,9 +12,11 @@ import (
 
 	"github.com/brsuite/bronutil"
 	"github.com/davecgh/go-spew/spew"
+	"github.com/brsuite/broln/chainntnfs"
 	"github.com/brsuite/broln/channeldb"
 	"github.com/brsuite/broln/htlcswitch/hodl"
 	"github.com/brsuite/broln/htlcswitch/hop"
+	"github.com/brsuite/broln/lntest/mock"
 	"github.com/brsuite/broln/lntypes"
 	"github.com/brsuite/broln/lnwire"
 	"github.com/brsuite/broln/ticker"
@@ -162,6 +164,166 @@ func TestSwitchHasActiveLink(t *testing.T) {
 	}
 }
 
+// TestSwitchLinkAliases asserts that a live link can be looked up by any of
+// the aliases registered for its short channel ID, and that new aliases are
+// picked up when the short channel ID is reported again.
+func TestSwitchLinkAliases(t *testing.T) {
+	t.Parallel()
+
+	alicePeer, err := newMockServer(
+		t, "alice", testStartingHeight, nil, testDefaultDelta,
+	)
+	if err != nil {
+		t.Fatalf("unable to create alice server: %v", err)
+	}
+
+	s, err := initSwitchWithDB(testStartingHeight, nil)
+	if err != nil {
+		t.Fatalf("unable to init switch: %v", err)
+	}
+
+	chanID1, _, aliceChanID, _ := genIDs()
+	alias1 := lnwire.NewShortChanIDFromInt(16_000_000 << 40)
+	alias2 := lnwire.NewShortChanIDFromInt(16_000_001 << 40)
+
+	aliases := []lnwire.ShortChannelID{alias1}
+	s.cfg.GetAliases = func(
+		base lnwire.ShortChannelID) ([]lnwire.ShortChannelID, error) {
+
+		if base != aliceChanID {
+			return nil, nil
+		}
+		return aliases, nil
+	}
+
+	if err := s.Start(); err != nil {
+		t.Fatalf("unable to start switch: %v", err)
+	}
+	defer s.Stop()
+
+	aliceChannelLink := newMockChannelLink(
+		s, chanID1, aliceChanID, alicePeer, true,
+	)
+	if err := s.AddLink(aliceChannelLink); err != nil {
+		t.Fatalf("unable to add alice link: %v", err)
+	}
+
+	for _, scid := range []lnwire.ShortChannelID{aliceChanID, alias1} {
+		if _, err := s.GetLinkByShortID(scid); err != nil {
+			t.Fatalf("unable to find link by %v: %v", scid, err)
+		}
+	}
+	if _, err := s.GetLinkByShortID(alias2); err == nil {
+		t.Fatalf("link shouldn't be reachable by unknown alias")
+	}
+
+	// Register a second alias and report the channel again, which should
+	// add it to the forwarding index.
+	aliases = append(aliases, alias2)
+	if err := s.UpdateShortChanID(chanID1); err != nil {
+		t.Fatalf("unable to update alice short_chan_id: %v", err)
+	}
+	if _, err := s.GetLinkByShortID(alias2); err != nil {
+		t.Fatalf("unable to find link by %v: %v", alias2, err)
+	}
+
+	// Removing the link should clear all of its aliases.
+	s.RemoveLink(chanID1)
+	for _, scid := range []lnwire.ShortChannelID{alias1, alias2} {
+		if _, err := s.GetLinkByShortID(scid); err == nil {
+			t.Fatalf("link should be removed for %v", scid)
+		}
+	}
+}
+
+// TestSwitchForwardScidAlias asserts that HTLCs are only forwarded over
+// option_scid_alias channels if they address the channel by one of its
+// aliases, and never by its real short channel ID.
+func TestSwitchForwardScidAlias(t *testing.T) {
+	t.Parallel()
+
+	alicePeer, err := newMockServer(
+		t, "alice", testStartingHeight, nil, testDefaultDelta,
+	)
+	require.NoError(t, err)
+	bobPeer, err := newMockServer(
+		t, "bob", testStartingHeight, nil, testDefaultDelta,
+	)
+	require.NoError(t, err)
+
+	s, err := initSwitchWithDB(testStartingHeight, nil)
+	require.NoError(t, err)
+
+	chanID1, chanID2, aliceChanID, bobChanID := genIDs()
+	bobAlias := lnwire.NewShortChanIDFromInt(16_000_000 << 40)
+	s.cfg.GetAliases = func(
+		base lnwire.ShortChannelID) ([]lnwire.ShortChannelID, error) {
+
+		if base != bobChanID {
+			return nil, nil
+		}
+		return []lnwire.ShortChannelID{bobAlias}, nil
+	}
+
+	require.NoError(t, s.Start())
+	defer s.Stop()
+
+	aliceChannelLink := newMockChannelLink(
+		s, chanID1, aliceChanID, alicePeer, true,
+	)
+	bobChannelLink := newMockChannelLink(
+		s, chanID2, bobChanID, bobPeer, true,
+	)
+	bobChannelLink.optionScidAlias = true
+	require.NoError(t, s.AddLink(aliceChannelLink))
+	require.NoError(t, s.AddLink(bobChannelLink))
+
+	newPacket := func(htlcID uint64,
+		outgoing lnwire.ShortChannelID) *htlcPacket {
+
+		return &htlcPacket{
+			incomingChanID: aliceChannelLink.ShortChanID(),
+			incomingHTLCID: htlcID,
+			outgoingChanID: outgoing,
+			incomingAmount: 2,
+			amount:         1,
+			obfuscator:     NewMockObfuscator(),
+			htlc: &lnwire.UpdateAddHTLC{
+				PaymentHash: [32]byte{byte(htlcID)},
+				Amount:      1,
+			},
+		}
+	}
+
+	// Addressing bob's channel by its real short channel ID must fail the
+	// htlc back to alice.
+	require.NoError(t, s.ForwardPackets(nil, newPacket(0, bobChanID)))
+
+	select {
+	case p := <-aliceChannelLink.packets:
+		require.NotEmpty(t, p.linkFailure)
+		assertFailureCode(
+			t, p.linkFailure, lnwire.CodeUnknownNextPeer,
+		)
+
+	case <-bobChannelLink.packets:
+		t.Fatal("htlc forwarded using the real short_chan_id")
+
+	case <-time.After(time.Second):
+		t.Fatal("no timely reply from switch")
+	}
+	require.Zero(t, s.circuits.NumOpen())
+
+	// Using the alias, the htlc is forwarded to bob.
+	require.NoError(t, s.ForwardPackets(nil, newPacket(1, bobAlias)))
+
+	select {
+	case <-bobChannelLink.packets:
+	case <-time.After(time.Second):
+		t.Fatal("request was not propagated to destination")
+	}
+}
+
 // TestSwitchSendPending checks the inability of htlc switch to forward adds
 // over pending links, and the UpdateShortChanID makes a pending link live.
 func TestSwitchSendPending(t *testing.T) {
@@ -339,6 +501,8 @@ func TestSwitchForward(t *testing.T) {
 		incomingChanID: aliceChannelLink.ShortChanID(),
 		incomingHTLCID: 0,
 		outgoingChanID: bobChannelLink.ShortChanID(),
+		incomingAmount: 2,
+		amount:         1,
 		obfuscator:     NewMockObfuscator(),
 		htlc: &lnwire.UpdateAddHTLC{
 			PaymentHash: rhash,
@@ -397,6 +561,17 @@ func TestSwitchForward(t *testing.T) {
 	if s.circuits.NumOpen() != 0 {
 		t.Fatal("wrong amount of circuits")
 	}
+
+	// The settled forward should be reflected in the forwarding stats,
+	// along with the fee we earned for it.
+	expectedStats := ForwardingStats{
+		NumSettled: 1,
+		FeesEarned: 1,
+	}
+	if stats := s.ForwardingStats(); stats != expectedStats {
+		t.Fatalf("expected forwarding stats %v, got %v",
+			expectedStats, stats)
+	}
 }
 
 func TestSwitchForwardFailAfterFullAdd(t *testing.T) {
@@ -587,6 +762,11 @@ func TestSwitchForwardFailAfterFullAdd(t *testing.T) {
 		t.Fatalf("wrong amount of circuits")
 	}
 
+	// The failed forward should be counted by the restarted switch.
+	if stats := s2.ForwardingStats(); stats.NumFailed != 1 {
+		t.Fatalf("expected 1 failed forward, got %v", stats.NumFailed)
+	}
+
 	// Send the fail packet from the remote peer through the switch.
 	if err := s.ForwardPackets(nil, fail); err != nil {
 		t.Fatal(err)
@@ -3160,6 +3340,16 @@ func (m *mockForwardInterceptor) resume() error {
 	return m.intercepted.Resume()
 }
 
+func (m *mockForwardInterceptor) resumeModified(
+	outgoingChanID lnwire.ShortChannelID,
+	outgoingAmount lnwire.MilliSatoshi,
+	customRecords lnwire.CustomRecords) error {
+
+	return m.intercepted.ResumeModified(
+		outgoingChanID, outgoingAmount, customRecords,
+	)
+}
+
 func assertNumCircuits(t *testing.T, s *Switch, pending, opened int) {
 	if s.circuits.NumPending() != pending {
 		t.Fatal("wrong amount of half circuits")
@@ -3259,7 +3449,11 @@ func TestSwitchHoldForward(t *testing.T) {
 	}
 
 	forwardInterceptor := &mockForwardInterceptor{}
-	switchForwardInterceptor := NewInterceptableSwitch(s)
+	switchForwardInterceptor := NewInterceptableSwitch(
+		&InterceptableSwitchConfig{
+			Switch: s,
+		},
+	)
 	switchForwardInterceptor.SetInterceptor(forwardInterceptor.InterceptForwardHtlc)
 	linkQuit := make(chan struct{})
 
@@ -3321,6 +3515,316 @@ func TestSwitchHoldForward(t *testing.T) {
 	assertNumCircuits(t, s, 0, 0)
 }
 
+// TestSwitchHoldForwardModified tests that a held forward can be resumed with
+// a modified outgoing channel, amount and custom records, and that invalid
+// modifications are refused without releasing the held forward.
+func TestSwitchHoldForwardModified(t *testing.T) {
+	t.Parallel()
+
+	alicePeer, err := newMockServer(
+		t, "alice", testStartingHeight, nil, testDefaultDelta,
+	)
+	require.NoError(t, err, "unable to create alice server")
+	bobPeer, err := newMockServer(
+		t, "bob", testStartingHeight, nil, testDefaultDelta,
+	)
+	require.NoError(t, err, "unable to create bob server")
+	carolPeer, err := newMockServer(
+		t, "carol", testStartingHeight, nil, testDefaultDelta,
+	)
+	require.NoError(t, err, "unable to create carol server")
+
+	s, err := initSwitchWithDB(testStartingHeight, nil)
+	require.NoError(t, err, "unable to init switch")
+	require.NoError(t, s.Start(), "unable to start switch")
+	defer s.Stop()
+
+	chanID1, chanID2, aliceChanID, bobChanID := genIDs()
+	chanID3, carolChanID := genID()
+
+	aliceChannelLink := newMockChannelLink(
+		s, chanID1, aliceChanID, alicePeer, true,
+	)
+	bobChannelLink := newMockChannelLink(
+		s, chanID2, bobChanID, bobPeer, true,
+	)
+	require.NoError(t, s.AddLink(aliceChannelLink))
+	require.NoError(t, s.AddLink(bobChannelLink))
+
+	// Create request which should be forwarded from Alice channel link to
+	// bob channel link.
+	preimage := [sha256.Size]byte{1}
+	rhash := sha256.Sum256(preimage[:])
+	ogPacket := &htlcPacket{
+		incomingChanID: aliceChannelLink.ShortChanID(),
+		incomingHTLCID: 0,
+		outgoingChanID: bobChannelLink.ShortChanID(),
+		incomingAmount: 10,
+		amount:         9,
+		obfuscator:     NewMockObfuscator(),
+		htlc: &lnwire.UpdateAddHTLC{
+			PaymentHash: rhash,
+			Amount:      9,
+		},
+	}
+
+	forwardInterceptor := &mockForwardInterceptor{}
+	switchForwardInterceptor := NewInterceptableSwitch(
+		&InterceptableSwitchConfig{
+			Switch: s,
+		},
+	)
+	switchForwardInterceptor.SetInterceptor(
+		forwardInterceptor.InterceptForwardHtlc,
+	)
+	linkQuit := make(chan struct{})
+
+	err = switchForwardInterceptor.ForwardPackets(linkQuit, ogPacket)
+	require.NoError(t, err, "can't forward htlc packet")
+	assertOutgoingLinkReceive(t, bobChannelLink, false)
+
+	// Resuming the forward over a channel the switch doesn't know of yet
+	// should fail.
+	err = forwardInterceptor.resumeModified(carolChanID, 0, nil)
+	require.Error(t, err)
+
+	// Forwarding more than we received isn't allowed either.
+	err = forwardInterceptor.resumeModified(lnwire.ShortChannelID{}, 11, nil)
+	require.ErrorIs(t, err, ErrOutgoingAmtExceedsIncoming)
+
+	// And neither are custom records outside of the custom range.
+	err = forwardInterceptor.resumeModified(
+		lnwire.ShortChannelID{}, 0, lnwire.CustomRecords{1: []byte{1}},
+	)
+	require.Error(t, err)
+
+	// None of the failed attempts should have resulted in a forward.
+	assertOutgoingLinkReceive(t, bobChannelLink, false)
+	assertNumCircuits(t, s, 0, 0)
+
+	// Now we'll open a new channel with carol while the forward is held,
+	// and resume it over that channel with a lower amount and some custom
+	// records attached.
+	carolChannelLink := newMockChannelLink(
+		s, chanID3, carolChanID, carolPeer, true,
+	)
+	require.NoError(t, s.AddLink(carolChannelLink))
+
+	customRecords := lnwire.CustomRecords{
+		lnwire.MinCustomRecordsTlvType: []byte{1, 2, 3},
+	}
+	err = forwardInterceptor.resumeModified(
+		carolChannelLink.ShortChanID(), 8, customRecords,
+	)
+	require.NoError(t, err, "unable to resume modified forward")
+
+	select {
+	case packet := <-carolChannelLink.packets:
+		require.Equal(t, lnwire.MilliSatoshi(8), packet.amount)
+
+		htlc, ok := packet.htlc.(*lnwire.UpdateAddHTLC)
+		require.True(t, ok, "expected add htlc")
+		require.Equal(t, lnwire.MilliSatoshi(8), htlc.Amount)
+		require.Equal(t, customRecords, htlc.CustomRecords)
+
+	case <-time.After(time.Second):
+		t.Fatal("request was not propagated to carol")
+	}
+	assertOutgoingLinkReceive(t, bobChannelLink, false)
+
+	// The original packet should be left untouched.
+	require.Equal(t, lnwire.MilliSatoshi(9), ogPacket.amount)
+	require.Nil(t, ogPacket.htlc.(*lnwire.UpdateAddHTLC).CustomRecords)
+}
+
+// TestSwitchRequireInterceptor tests that forwards are held while no
+// interceptor is connected if an interceptor is required, are handed to the
+// interceptors that connect later on, and are failed back once they get too
+// close to their expiry.
+func TestSwitchRequireInterceptor(t *testing.T) {
+	t.Parallel()
+
+	alicePeer, err := newMockServer(
+		t, "alice", testStartingHeight, nil, testDefaultDelta,
+	)
+	require.NoError(t, err, "unable to create alice server")
+	bobPeer, err := newMockServer(
+		t, "bob", testStartingHeight, nil, testDefaultDelta,
+	)
+	require.NoError(t, err, "unable to create bob server")
+
+	s, err := initSwitchWithDB(testStartingHeight, nil)
+	require.NoError(t, err, "unable to init switch")
+	require.NoError(t, s.Start(), "unable to start switch")
+	defer s.Stop()
+
+	chanID1, chanID2, aliceChanID, bobChanID := genIDs()
+	aliceChannelLink := newMockChannelLink(
+		s, chanID1, aliceChanID, alicePeer, true,
+	)
+	bobChannelLink := newMockChannelLink(
+		s, chanID2, bobChanID, bobPeer, true,
+	)
+	require.NoError(t, s.AddLink(aliceChannelLink))
+	require.NoError(t, s.AddLink(bobChannelLink))
+
+	const cltvRejectDelta = 10
+	notifier := &mock.ChainNotifier{
+		EpochChan: make(chan *chainntnfs.BlockEpoch),
+	}
+	switchForwardInterceptor := NewInterceptableSwitch(
+		&InterceptableSwitchConfig{
+			Switch:             s,
+			Notifier:           notifier,
+			CltvRejectDelta:    cltvRejectDelta,
+			RequireInterceptor: true,
+		},
+	)
+	require.NoError(t, switchForwardInterceptor.Start())
+	defer switchForwardInterceptor.Stop()
+
+	sendEpoch := func(height int32) {
+		select {
+		case notifier.EpochChan <- &chainntnfs.BlockEpoch{
+			Height: height,
+		}:
+		case <-time.After(time.Second):
+			t.Fatal("epoch not consumed")
+		}
+	}
+	sendEpoch(testStartingHeight)
+
+	newPacket := func(htlcID uint64, expiry uint32) *htlcPacket {
+		preimage := [sha256.Size]byte{byte(htlcID)}
+		return &htlcPacket{
+			incomingChanID:  aliceChannelLink.ShortChanID(),
+			incomingHTLCID:  htlcID,
+			outgoingChanID:  bobChannelLink.ShortChanID(),
+			incomingTimeout: expiry,
+			obfuscator:      NewMockObfuscator(),
+			htlc: &lnwire.UpdateAddHTLC{
+				PaymentHash: sha256.Sum256(preimage[:]),
+				Amount:      1,
+			},
+		}
+	}
+
+	// newInterceptor returns an interceptor that takes control of all
+	// forwards, along with the channel the forwards are delivered on.
+	newInterceptor := func() (ForwardInterceptor, chan InterceptedForward) {
+		intercepted := make(chan InterceptedForward, 10)
+		return func(fwd InterceptedForward) bool {
+			intercepted <- fwd
+			return true
+		}, intercepted
+	}
+
+	assertIntercepted := func(intercepted chan InterceptedForward,
+		htlcID uint64) InterceptedForward {
+
+		t.Helper()
+
+		select {
+		case fwd := <-intercepted:
+			require.Equal(
+				t, htlcID, fwd.Packet().IncomingCircuit.HtlcID,
+			)
+			return fwd
+
+		case <-time.After(time.Second):
+			t.Fatal("forward not intercepted")
+			return nil
+		}
+	}
+
+	assertFailedBack := func(htlcID uint64) {
+		t.Helper()
+
+		select {
+		case pkt := <-aliceChannelLink.packets:
+			require.IsType(t, &lnwire.UpdateFailHTLC{}, pkt.htlc)
+			require.Equal(t, htlcID, pkt.incomingHTLCID)
+
+		case <-time.After(time.Second):
+			t.Fatal("forward not failed back")
+		}
+	}
+
+	linkQuit := make(chan struct{})
+	expiry := uint32(testStartingHeight + 100)
+
+	// With no interceptor connected, the forward should be held.
+	packet := newPacket(0, expiry)
+	err = switchForwardInterceptor.ForwardPackets(linkQuit, packet)
+	require.NoError(t, err, "can't forward htlc packet")
+	assertOutgoingLinkReceive(t, bobChannelLink, false)
+
+	// Once an interceptor connects, the held forward should be handed to
+	// it.
+	interceptor, intercepted := newInterceptor()
+	switchForwardInterceptor.SetInterceptor(interceptor)
+	assertIntercepted(intercepted, 0)
+
+	// Disconnecting the interceptor should keep the forward held, rather
+	// than resuming it.
+	switchForwardInterceptor.SetInterceptor(nil)
+	assertOutgoingLinkReceive(t, bobChannelLink, false)
+
+	// The incoming link replaying the packet, as it does after a restart,
+	// shouldn't result in the forward being held twice.
+	err = switchForwardInterceptor.ForwardPackets(linkQuit, packet)
+	require.NoError(t, err, "can't forward htlc packet")
+	assertOutgoingLinkReceive(t, bobChannelLink, false)
+
+	// A new interceptor should be handed the forward again, and be able
+	// to resume it.
+	interceptor, intercepted = newInterceptor()
+	switchForwardInterceptor.SetInterceptor(interceptor)
+	fwd := assertIntercepted(intercepted, 0)
+	select {
+	case fwd := <-intercepted:
+		t.Fatalf("forward %v intercepted twice",
+			fwd.Packet().IncomingCircuit)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	require.NoError(t, fwd.Resume(), "unable to resume forward")
+	assertOutgoingLinkReceive(t, bobChannelLink, true)
+
+	// The forward was resolved, so it can't be resolved again.
+	require.ErrorIs(t, fwd.Fail(), ErrFwdNotExists)
+
+	// A forward that is too close to expiry to be held should be failed
+	// back right away.
+	switchForwardInterceptor.SetInterceptor(nil)
+	packet = newPacket(1, testStartingHeight+cltvRejectDelta)
+	err = switchForwardInterceptor.ForwardPackets(linkQuit, packet)
+	require.NoError(t, err, "can't forward htlc packet")
+	assertOutgoingLinkReceive(t, bobChannelLink, false)
+	assertFailedBack(1)
+
+	// Finally, a held forward should be failed back once a block arrives
+	// that brings it too close to expiry.
+	packet = newPacket(2, expiry)
+	err = switchForwardInterceptor.ForwardPackets(linkQuit, packet)
+	require.NoError(t, err, "can't forward htlc packet")
+	assertOutgoingLinkReceive(t, aliceChannelLink, false)
+
+	sendEpoch(int32(expiry - cltvRejectDelta))
+	assertOutgoingLinkReceive(t, bobChannelLink, false)
+	assertFailedBack(2)
+
+	// The failed forward shouldn't be handed to the next interceptor.
+	interceptor, intercepted = newInterceptor()
+	switchForwardInterceptor.SetInterceptor(interceptor)
+	select {
+	case fwd := <-intercepted:
+		t.Fatalf("failed forward %v intercepted",
+			fwd.Packet().IncomingCircuit)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
 // TestSwitchDustForwarding tests that the switch properly fails HTLC's which
 // have incoming or outgoing links that breach their dust thresholds.
 func TestSwitchDustForwarding(t *testing.T) {
@@ -1,9 +1,63 @@
 package invoices
 
 import (
+	"github.com/brsuite/broln/channeldb"
+	"github.com/brsuite/broln/lntypes"
 	"github.com/brsuite/broln/record"
 )
 
+// InvoiceDB is the database that stores the information about invoices.
+// Implementations of this interface are the kv based channeldb.DB and the
+// native SQL based channeldb.SQLInvoiceDB.
+type InvoiceDB interface {
+	// AddInvoice inserts the targeted invoice into the database. If the
+	// invoice has *any* payment hashes which already exists within the
+	// database, then the insertion will be aborted and rejected due to the
+	// strict policy banning any duplicate payment hashes.
+	AddInvoice(invoice *channeldb.Invoice,
+		paymentHash lntypes.Hash) (uint64, error)
+
+	// InvoicesAddedSince returns all invoices with an add index greater
+	// than the specified sinceAddIndex.
+	InvoicesAddedSince(sinceAddIndex uint64) ([]channeldb.Invoice, error)
+
+	// LookupInvoice attempts to look up an invoice according to its 32
+	// byte payment hash, payment address or set ID.
+	LookupInvoice(ref channeldb.InvoiceRef) (channeldb.Invoice, error)
+
+	// ScanInvoices scans through all invoices and calls the passed
+	// scanFunc for each invoice with its respective payment hash. The
+	// reset closure is used to reset/initialize partial results and also
+	// to signal if the underlying transaction has been retried.
+	ScanInvoices(scanFunc func(lntypes.Hash, *channeldb.Invoice) error,
+		reset func()) error
+
+	// QueryInvoices allows a caller to query the invoice database for
+	// invoices within the specified add index range.
+	QueryInvoices(q channeldb.InvoiceQuery) (channeldb.InvoiceSlice, error)
+
+	// UpdateInvoice attempts to update an invoice corresponding to the
+	// passed invoice ref. The update is performed atomically and the
+	// fields to update are controlled by the supplied callback.
+	UpdateInvoice(ref channeldb.InvoiceRef, setIDHint *channeldb.SetID,
+		callback channeldb.InvoiceUpdateCallback) (*channeldb.Invoice,
+		error)
+
+	// InvoicesSettledSince returns all invoices (or AMP sub-invoices) with
+	// a settle index greater than the specified sinceSettleIndex.
+	InvoicesSettledSince(sinceSettleIndex uint64) ([]channeldb.Invoice,
+		error)
+
+	// DeleteInvoice attempts to delete the passed invoices from the
+	// database in one transaction.
+	DeleteInvoice(invoicesToDelete []channeldb.InvoiceDeleteRef) error
+}
+
+// A compile-time check to ensure that both invoice stores implement the
+// InvoiceDB interface.
+var _ InvoiceDB = (*channeldb.DB)(nil)
+var _ InvoiceDB = (*channeldb.SQLInvoiceDB)(nil)
+
 // Payload abstracts access to any additional fields provided in the final hop's
 // TLV onion payload.
 type Payload interface {
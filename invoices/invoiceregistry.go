@@ -101,7 +101,7 @@ func (r *htlcReleaseEvent) Less(other queue.PriorityQueueItem) bool {
 type InvoiceRegistry struct {
 	sync.RWMutex
 
-	cdb *channeldb.DB
+	cdb InvoiceDB
 
 	// cfg contains the registry's configuration parameters.
 	cfg *RegistryConfig
@@ -140,7 +140,7 @@ type InvoiceRegistry struct {
 // wraps the persistent on-disk invoice storage with an additional in-memory
 // layer. The in-memory layer is in place such that debug invoices can be added
 // which are volatile yet available system wide within the daemon.
-func NewRegistry(cdb *channeldb.DB, expiryWatcher *InvoiceExpiryWatcher,
+func NewRegistry(cdb InvoiceDB, expiryWatcher *InvoiceExpiryWatcher,
 	cfg *RegistryConfig) *InvoiceRegistry {
 
 	return &InvoiceRegistry{
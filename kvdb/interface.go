@@ -1,6 +1,8 @@
 package kvdb
 
 import (
+	"database/sql"
+
 	"github.com/brsuite/bronwallet/walletdb"
 )
 
@@ -55,6 +57,14 @@ var Create = walletdb.Create
 // through read or read+write transactions.
 type Backend = walletdb.DB
 
+// SQLBackend is an optional interface that is implemented by the backends
+// that store their data in a SQL database. It exposes the underlying database
+// connection so stores with a native relational schema can share it.
+type SQLBackend interface {
+	// SQLDB returns the underlying SQL database connection.
+	SQLDB() *sql.DB
+}
+
 // Open opens an existing database for the specified type. The arguments are
 // specific to the database type driver. See the documentation for the database
 // driver for further details.
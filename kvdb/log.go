@@ -3,6 +3,7 @@ package kvdb
 import (
 	"github.com/btcsuite/btclog"
 	"github.com/brsuite/broln/kvdb/postgres"
+	"github.com/brsuite/broln/kvdb/sqlite"
 )
 
 // log is a logger that is initialized as disabled.  This means the package will
@@ -14,4 +15,5 @@ func UseLogger(logger btclog.Logger) {
 	log = logger
 
 	postgres.UseLogger(log)
+	sqlite.UseLogger(log)
 }
@@ -231,6 +231,13 @@ func (db *db) executeTransaction(f func(tx walletdb.ReadWriteTx) error,
 	return tx.Commit()
 }
 
+// SQLDB returns the underlying postgres database connection.
+//
+// NOTE: This is part of the kvdb.SQLBackend interface.
+func (db *db) SQLDB() *sql.DB {
+	return db.db
+}
+
 // PrintStats returns all collected stats pretty printed into a string.
 func (db *db) PrintStats() string {
 	return "stats not supported by Postgres driver"
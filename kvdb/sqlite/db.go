@@ -0,0 +1,349 @@
+//go:build kvdb_sqlite
+// +build kvdb_sqlite
+
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/brsuite/bronwallet/walletdb"
+)
+
+const (
+	// kvTableName is the name of the table that will contain all the kv
+	// pairs.
+	kvTableName = "kv"
+
+	// sqliteOptionPrefix is the string prefix sqlite uses to set various
+	// options. This is used in the following format:
+	//   * sqliteOptionPrefix || option_name = option_value.
+	sqliteOptionPrefix = "_pragma"
+
+	// sqliteTxLockImmediate is a dsn option used to ensure that write
+	// transactions are started immediately.
+	sqliteTxLockImmediate = "_txlock=immediate"
+
+	// defaultMaxConns is the number of permitted open connections if no
+	// limit is configured. Every connection holds its own page cache, so
+	// there is little use in opening more connections than there are
+	// concurrent readers.
+	defaultMaxConns = 25
+)
+
+// db holds a reference to the sqlite connection.
+type db struct {
+	// cfg is the sqlite connection config.
+	cfg *Config
+
+	// prefix is the table name prefix that is used to simulate namespaces.
+	// SQLite does not support schemas, so all namespaces share the same
+	// database file and are distinguished by their table names.
+	prefix string
+
+	// ctx is the overall context for the database driver.
+	//
+	// TODO: This is an anti-pattern that is in place until the kvdb
+	// interface supports a context.
+	ctx context.Context
+
+	// db is the underlying database connection instance.
+	db *sql.DB
+
+	// dbFile is the full path of the database file. It is used as the key
+	// for the shared connection set.
+	dbFile string
+
+	// lock is the per-file write lock that ensures single writer. It is
+	// shared between all namespaces that live in the same database file.
+	// Readers don't acquire the lock, as the WAL journal mode allows them
+	// to run concurrently with a writer.
+	lock *sync.Mutex
+
+	// table is the name of the table that contains the data for all
+	// top-level buckets that have keys that cannot be mapped to a distinct
+	// sql table.
+	table string
+}
+
+// Enforce db implements the walletdb.DB interface.
+var _ walletdb.DB = (*db)(nil)
+
+// Global set of database connections.
+var dbConns = newDbConnSet()
+
+// newSqliteBackend returns a db object initialized with the passed backend
+// config. If the sqlite database cannot be opened, then an error is returned.
+func newSqliteBackend(ctx context.Context, config *Config, dbPath, fileName,
+	prefix string) (*db, error) {
+
+	if prefix == "" {
+		return nil, errors.New("empty sqlite prefix")
+	}
+
+	if dbPath == "" || fileName == "" {
+		return nil, errors.New("empty sqlite database path")
+	}
+
+	// Make sure the directory the database file lives in exists.
+	if err := os.MkdirAll(dbPath, 0700); err != nil {
+		return nil, err
+	}
+
+	dbFile := filepath.Join(dbPath, fileName)
+	dsn := getDsn(config, dbFile)
+
+	maxConns := defaultMaxConns
+	if config.MaxConnections > 0 {
+		maxConns = config.MaxConnections
+	}
+
+	dbConn, lock, err := dbConns.Open(dbFile, dsn, maxConns)
+	if err != nil {
+		return nil, err
+	}
+
+	// Compose system table names.
+	table := fmt.Sprintf(
+		"%s_%s", prefix, kvTableName,
+	)
+
+	// Execute the create statements to set up a kv table in sqlite. Every
+	// row points to the bucket that it is one via its parent_id field. A
+	// NULL parent_id means that the key belongs to the upper-most bucket in
+	// this table. A constraint on parent_id is enforcing referential
+	// integrity.
+	//
+	// Furthermore there is a <table>_p index on parent_id that is required
+	// for the foreign key constraint.
+	//
+	// Finally there are unique indices on (parent_id, key) to prevent the
+	// same key being present in a bucket more than once (<table>_up and
+	// <table>_unp). In sqlite, a single index wouldn't enforce the unique
+	// constraint on rows with a NULL parent_id. Therefore two indices are
+	// defined.
+	_, err = dbConn.ExecContext(ctx, `
+CREATE TABLE IF NOT EXISTS `+table+`
+(
+    key BLOB NOT NULL,
+    value BLOB,
+    parent_id BIGINT,
+    id INTEGER PRIMARY KEY,
+    sequence BIGINT,
+    CONSTRAINT `+table+`_parent FOREIGN KEY (parent_id)
+        REFERENCES `+table+` (id)
+        ON UPDATE NO ACTION
+        ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS `+table+`_p
+    ON `+table+` (parent_id);
+
+CREATE UNIQUE INDEX IF NOT EXISTS `+table+`_up
+    ON `+table+`
+    (parent_id, key) WHERE parent_id IS NOT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS `+table+`_unp
+    ON `+table+` (key) WHERE parent_id IS NULL;
+`)
+	if err != nil {
+		_ = dbConns.Close(dbFile)
+
+		return nil, err
+	}
+
+	backend := &db{
+		cfg:    config,
+		prefix: prefix,
+		ctx:    ctx,
+		db:     dbConn,
+		dbFile: dbFile,
+		lock:   lock,
+		table:  table,
+	}
+
+	return backend, nil
+}
+
+// getDsn returns the data source name for the given database file, with all
+// the pragma options we require and the ones set by the user applied.
+func getDsn(config *Config, dbFile string) string {
+	// Foreign keys must be enabled for the cascading bucket deletes to
+	// work. The WAL journal mode allows readers to proceed concurrently
+	// with a writer, and a full sync makes sure a committed transaction
+	// survives a crash or power loss.
+	pragmaOptions := []string{
+		fmt.Sprintf("busy_timeout=%d", config.BusyTimeout.Milliseconds()),
+		"foreign_keys=on",
+		"journal_mode=WAL",
+		"synchronous=full",
+	}
+
+	// User supplied pragmas are applied after our defaults, so they take
+	// precedence.
+	pragmaOptions = append(pragmaOptions, config.PragmaOptions...)
+
+	query := url.Values{}
+	for _, option := range pragmaOptions {
+		query.Add(sqliteOptionPrefix, option)
+	}
+
+	return fmt.Sprintf(
+		"file:%s?%s&%s", dbFile, query.Encode(), sqliteTxLockImmediate,
+	)
+}
+
+// getTimeoutCtx gets a timeout context for database requests.
+func (db *db) getTimeoutCtx() (context.Context, func()) {
+	if db.cfg.Timeout == time.Duration(0) {
+		return db.ctx, func() {}
+	}
+
+	return context.WithTimeout(db.ctx, db.cfg.Timeout)
+}
+
+// catchPanic executes the specified function. If a panic occurs, it is returned
+// as an error value.
+func catchPanic(f func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Criticalf("Caught unhandled error: %v", r)
+
+			switch data := r.(type) {
+			case error:
+				err = data
+
+			default:
+				err = errors.New(fmt.Sprintf("%v", data))
+			}
+		}
+	}()
+
+	err = f()
+
+	return
+}
+
+// View opens a database read transaction and executes the function f with the
+// transaction passed as a parameter. After f exits, the transaction is rolled
+// back. If f errors, its error is returned, not a rollback error (if any
+// occur). The passed reset function is called before the start of the
+// transaction and can be used to reset intermediate state. As callers may
+// expect retries of the f closure (depending on the database backend used), the
+// reset function will be called before each retry respectively.
+func (db *db) View(f func(tx walletdb.ReadTx) error, reset func()) error {
+	return db.executeTransaction(
+		func(tx walletdb.ReadWriteTx) error {
+			return f(tx.(walletdb.ReadTx))
+		},
+		reset, true,
+	)
+}
+
+// Update opens a database read/write transaction and executes the function f
+// with the transaction passed as a parameter. After f exits, if f did not
+// error, the transaction is committed. Otherwise, if f did error, the
+// transaction is rolled back. If the rollback fails, the original error
+// returned by f is still returned. If the commit fails, the commit error is
+// returned. As callers may expect retries of the f closure, the reset function
+// will be called before each retry respectively.
+func (db *db) Update(f func(tx walletdb.ReadWriteTx) error, reset func()) (err error) {
+	return db.executeTransaction(f, reset, false)
+}
+
+// executeTransaction creates a new read-only or read-write transaction and
+// executes the given function within it.
+func (db *db) executeTransaction(f func(tx walletdb.ReadWriteTx) error,
+	reset func(), readOnly bool) error {
+
+	reset()
+
+	tx, err := newReadWriteTx(db, readOnly)
+	if err != nil {
+		return err
+	}
+
+	err = catchPanic(func() error { return f(tx) })
+	if err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Errorf("Error rolling back tx: %v", rollbackErr)
+		}
+
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SQLDB returns the underlying sqlite database connection.
+//
+// NOTE: This is part of the kvdb.SQLBackend interface.
+func (db *db) SQLDB() *sql.DB {
+	return db.db
+}
+
+// PrintStats returns all collected stats pretty printed into a string.
+func (db *db) PrintStats() string {
+	return "stats not supported by SQLite driver"
+}
+
+// BeginReadWriteTx opens a database read+write transaction.
+func (db *db) BeginReadWriteTx() (walletdb.ReadWriteTx, error) {
+	return newReadWriteTx(db, false)
+}
+
+// BeginReadTx opens a database read transaction.
+func (db *db) BeginReadTx() (walletdb.ReadTx, error) {
+	return newReadWriteTx(db, true)
+}
+
+// Copy writes a copy of the database to the provided writer. The copy is a
+// consistent snapshot of the whole database file, which includes the tables
+// of all namespaces that share the file.
+// This function is part of the walletdb.Db interface implementation.
+func (db *db) Copy(w io.Writer) error {
+	// VACUUM INTO can only write to a file, so we create the snapshot in
+	// a temporary directory next to the database and stream it from
+	// there.
+	tempDir, err := os.MkdirTemp(filepath.Dir(db.dbFile), "copy")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempDir)
+
+	copyFile := filepath.Join(tempDir, filepath.Base(db.dbFile))
+
+	ctx, cancel := db.getTimeoutCtx()
+	defer cancel()
+
+	_, err = db.db.ExecContext(ctx, "VACUUM INTO $1", copyFile)
+	if err != nil {
+		return fmt.Errorf("unable to snapshot database: %w", err)
+	}
+
+	f, err := os.Open(copyFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(w, f)
+
+	return err
+}
+
+// Close cleanly shuts down the database and syncs all data.
+// This function is part of the walletdb.Db interface implementation.
+func (db *db) Close() error {
+	log.Infof("Closing database %v", db.prefix)
+
+	return dbConns.Close(db.dbFile)
+}
@@ -0,0 +1,95 @@
+//go:build kvdb_sqlite
+// +build kvdb_sqlite
+
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+
+	_ "modernc.org/sqlite"
+)
+
+// dbConn stores the actual connection, the write lock that is shared between
+// all users of the same database file and a user count.
+type dbConn struct {
+	db    *sql.DB
+	lock  *sync.Mutex
+	count int
+}
+
+// dbConnSet stores a set of connections keyed by the database file path.
+type dbConnSet struct {
+	dbConn map[string]*dbConn
+
+	sync.Mutex
+}
+
+// newDbConnSet initializes a new set of connections.
+func newDbConnSet() *dbConnSet {
+	return &dbConnSet{
+		dbConn: make(map[string]*dbConn),
+	}
+}
+
+// Open opens a new database connection. If a connection already exists for the
+// given database file, the existing connection and its write lock are
+// returned.
+func (d *dbConnSet) Open(dbFile, dsn string, maxConnections int) (*sql.DB,
+	*sync.Mutex, error) {
+
+	d.Lock()
+	defer d.Unlock()
+
+	if dbConn, ok := d.dbConn[dbFile]; ok {
+		dbConn.count++
+
+		return dbConn.db, dbConn.lock, nil
+	}
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Limit maximum number of open connections. With this client-side
+	// limit in place, broln will wait for a connection to become
+	// available.
+	if maxConnections != 0 {
+		db.SetMaxOpenConns(maxConnections)
+	}
+
+	d.dbConn[dbFile] = &dbConn{
+		db:    db,
+		lock:  &sync.Mutex{},
+		count: 1,
+	}
+
+	return db, d.dbConn[dbFile].lock, nil
+}
+
+// Close closes the connection for the given database file. If there are still
+// other users of the same connection, this function does nothing.
+func (d *dbConnSet) Close(dbFile string) error {
+	d.Lock()
+	defer d.Unlock()
+
+	dbConn, ok := d.dbConn[dbFile]
+	if !ok {
+		return fmt.Errorf("connection not found: %v", dbFile)
+	}
+
+	// Reduce user count.
+	dbConn.count--
+
+	// Do not close if there are other users.
+	if dbConn.count > 0 {
+		return nil
+	}
+
+	// Close connection.
+	delete(d.dbConn, dbFile)
+
+	return dbConn.db.Close()
+}
@@ -0,0 +1,195 @@
+//go:build kvdb_sqlite
+// +build kvdb_sqlite
+
+package sqlite
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/brsuite/bronwallet/walletdb"
+	"github.com/brsuite/bronwallet/walletdb/walletdbtest"
+	"github.com/stretchr/testify/require"
+)
+
+// TestInterface performs all interfaces tests for this database driver.
+func TestInterface(t *testing.T) {
+	// dbType is the database type name for this driver.
+	const dbType = "sqlite"
+
+	ctx := context.Background()
+	cfg := &Config{
+		BusyTimeout: 5 * time.Second,
+	}
+
+	walletdbtest.TestInterface(
+		t, dbType, ctx, cfg, t.TempDir(), "tmp.db", prefix,
+	)
+}
+
+// TestConcurrentRead makes sure that a read transaction can be opened and used
+// while a write transaction on the same database file is still open.
+func TestConcurrentRead(t *testing.T) {
+	f, err := NewFixture(t.TempDir(), "tmp.db")
+	require.NoError(t, err)
+	defer f.Db.Close()
+
+	err = walletdb.Update(f.Db, func(tx walletdb.ReadWriteTx) error {
+		bucket, err := tx.CreateTopLevelBucket([]byte("apple"))
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put([]byte("key"), []byte("val"))
+	})
+	require.NoError(t, err)
+
+	writeTx, err := f.Db.BeginReadWriteTx()
+	require.NoError(t, err)
+
+	bucket := writeTx.ReadWriteBucket([]byte("apple"))
+	require.NotNil(t, bucket)
+	require.NoError(t, bucket.Put([]byte("key"), []byte("new")))
+
+	// The reader must not block on the open writer and must not see its
+	// uncommitted changes.
+	err = walletdb.View(f.Db, func(tx walletdb.ReadTx) error {
+		bucket := tx.ReadBucket([]byte("apple"))
+		require.NotNil(t, bucket)
+		require.Equal(t, []byte("val"), bucket.Get([]byte("key")))
+
+		return nil
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, writeTx.Commit())
+
+	err = walletdb.View(f.Db, func(tx walletdb.ReadTx) error {
+		bucket := tx.ReadBucket([]byte("apple"))
+		require.NotNil(t, bucket)
+		require.Equal(t, []byte("new"), bucket.Get([]byte("key")))
+
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+// TestCopy asserts that Copy writes a snapshot of the database that can be
+// opened as a database of its own.
+func TestCopy(t *testing.T) {
+	f, err := NewFixture(t.TempDir(), "tmp.db")
+	require.NoError(t, err)
+	defer f.Db.Close()
+
+	err = walletdb.Update(f.Db, func(tx walletdb.ReadWriteTx) error {
+		bucket, err := tx.CreateTopLevelBucket([]byte("apple"))
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put([]byte("key"), []byte("val"))
+	})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, f.Db.Copy(&buf))
+
+	copyDir := t.TempDir()
+	err = os.WriteFile(
+		filepath.Join(copyDir, "copy.db"), buf.Bytes(), 0600,
+	)
+	require.NoError(t, err)
+
+	c, err := NewFixture(copyDir, "copy.db")
+	require.NoError(t, err)
+	defer c.Db.Close()
+
+	err = walletdb.View(c.Db, func(tx walletdb.ReadTx) error {
+		bucket := tx.ReadBucket([]byte("apple"))
+		require.NotNil(t, bucket)
+		require.Equal(t, []byte("val"), bucket.Get([]byte("key")))
+
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+// TestTopLevelSequence asserts that accessing the sequence of the top level
+// bucket returns an error instead of panicking.
+func TestTopLevelSequence(t *testing.T) {
+	f, err := NewFixture(t.TempDir(), "tmp.db")
+	require.NoError(t, err)
+	defer f.Db.Close()
+
+	err = walletdb.Update(f.Db, func(tx walletdb.ReadWriteTx) error {
+		root := newReadWriteBucket(tx.(*readWriteTx), nil)
+
+		require.ErrorIs(t, root.SetSequence(1), errTopLevelSequence)
+
+		_, err := root.NextSequence()
+		require.ErrorIs(t, err, errTopLevelSequence)
+
+		return nil
+	})
+	require.NoError(t, err)
+
+	err = walletdb.View(f.Db, func(tx walletdb.ReadTx) error {
+		root := newReadWriteBucket(tx.(*readWriteTx), nil)
+		require.Zero(t, root.Sequence())
+
+		return nil
+	})
+	require.ErrorIs(t, err, errTopLevelSequence)
+}
+
+// TestReadErrorFailsTx asserts that an error in a read that can't return it,
+// fails the transaction instead of being mistaken for a missing key.
+func TestReadErrorFailsTx(t *testing.T) {
+	f, err := NewFixture(t.TempDir(), "tmp.db")
+	require.NoError(t, err)
+	defer f.Db.Close()
+
+	var bucketID int64
+	err = walletdb.Update(f.Db, func(tx walletdb.ReadWriteTx) error {
+		bucket, err := tx.CreateTopLevelBucket([]byte("apple"))
+		if err != nil {
+			return err
+		}
+		bucketID = *bucket.(*readWriteBucket).id
+
+		return bucket.Put([]byte("key"), []byte("val"))
+	})
+	require.NoError(t, err)
+
+	// Point a bucket at a table that doesn't exist, so that every query
+	// on it fails.
+	err = walletdb.Update(f.Db, func(tx walletdb.ReadWriteTx) error {
+		bucket := newReadWriteBucket(tx.(*readWriteTx), &bucketID)
+		bucket.table = "missing"
+
+		require.Nil(t, bucket.Get([]byte("key")))
+
+		k, v := bucket.ReadCursor().First()
+		require.Nil(t, k)
+		require.Nil(t, v)
+
+		return tx.ReadWriteBucket([]byte("apple")).Put(
+			[]byte("key"), []byte("new"),
+		)
+	})
+	require.ErrorContains(t, err, "no such table")
+
+	// The write that followed the failed read must not be committed.
+	err = walletdb.View(f.Db, func(tx walletdb.ReadTx) error {
+		bucket := tx.ReadBucket([]byte("apple"))
+		require.NotNil(t, bucket)
+		require.Equal(t, []byte("val"), bucket.Get([]byte("key")))
+
+		return nil
+	})
+	require.NoError(t, err)
+}
@@ -0,0 +1,43 @@
+//go:build kvdb_sqlite
+// +build kvdb_sqlite
+
+package sqlite
+
+import (
+	"context"
+	"time"
+
+	"github.com/brsuite/bronwallet/walletdb"
+)
+
+const (
+	prefix = "test"
+)
+
+// NewFixture returns a new sqlite test database stored in the given file
+// within the given directory.
+func NewFixture(dbPath, fileName string) (*fixture, error) {
+	db, err := newSqliteBackend(
+		context.Background(),
+		&Config{
+			Timeout:     time.Minute,
+			BusyTimeout: 5 * time.Second,
+		},
+		dbPath, fileName, prefix,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fixture{
+		Db: db,
+	}, nil
+}
+
+type fixture struct {
+	Db walletdb.DB
+}
+
+func (b *fixture) DB() walletdb.DB {
+	return b.Db
+}
@@ -0,0 +1,7 @@
+package sqlite
+
+import "github.com/brsuite/bronwallet/walletdb"
+
+type Fixture interface {
+	DB() walletdb.DB
+}
@@ -0,0 +1,236 @@
+//go:build kvdb_sqlite
+// +build kvdb_sqlite
+
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+
+	"github.com/brsuite/bronwallet/walletdb"
+)
+
+// readWriteTx holds a reference to an open sqlite transaction.
+type readWriteTx struct {
+	db *db
+	tx *sql.Tx
+
+	// onCommit gets called upon commit.
+	onCommit func()
+
+	// active is true if the transaction hasn't been committed yet.
+	active bool
+
+	// locker is a pointer to the per-file write lock. It is nil for
+	// read-only transactions.
+	locker sync.Locker
+
+	// err holds the first error that occurred in a call that cannot
+	// return it to the caller, like a failing query in Get or one of the
+	// cursor methods. A transaction with an error can't be committed.
+	err error
+}
+
+// newReadWriteTx creates an rw transaction using a connection from the
+// specified pool.
+func newReadWriteTx(db *db, readOnly bool) (*readWriteTx, error) {
+	// Obtain the write lock for write transactions. Read transactions
+	// don't need to be serialized, because in WAL mode sqlite gives each
+	// of them a consistent snapshot while a writer is active. Taking the
+	// lock in process avoids spinning on the sqlite busy handler when
+	// multiple writers compete for the database file.
+	var locker sync.Locker
+	if !readOnly {
+		locker = db.lock
+		locker.Lock()
+	}
+
+	// Start the transaction. Don't use the timeout context because it would
+	// be applied to the transaction as a whole. Write transactions are
+	// started with BEGIN IMMEDIATE (see the _txlock dsn option) so that the
+	// database lock is acquired upfront instead of on the first write.
+	tx, err := db.db.BeginTx(
+		context.Background(),
+		&sql.TxOptions{
+			ReadOnly: readOnly,
+		},
+	)
+	if err != nil {
+		if locker != nil {
+			locker.Unlock()
+		}
+		return nil, err
+	}
+
+	return &readWriteTx{
+		db:     db,
+		tx:     tx,
+		active: true,
+		locker: locker,
+	}, nil
+}
+
+// ReadBucket opens the root bucket for read only access.  If the bucket
+// described by the key does not exist, nil is returned.
+func (tx *readWriteTx) ReadBucket(key []byte) walletdb.ReadBucket {
+	return tx.ReadWriteBucket(key)
+}
+
+// ForEachBucket iterates through all top level buckets.
+func (tx *readWriteTx) ForEachBucket(fn func(key []byte) error) error {
+	// Fetch binary top level buckets.
+	bucket := newReadWriteBucket(tx, nil)
+	err := bucket.ForEach(func(k, _ []byte) error {
+		return fn(k)
+	})
+	return err
+}
+
+// Rollback closes the transaction, discarding changes (if any) if the
+// database was modified by a write transaction.
+func (tx *readWriteTx) Rollback() error {
+	// If the transaction has been closed rollback will fail.
+	if !tx.active {
+		return walletdb.ErrTxClosed
+	}
+
+	err := tx.tx.Rollback()
+
+	// Unlock the transaction regardless of the error result.
+	tx.active = false
+	tx.unlock()
+	if err != nil {
+		return err
+	}
+
+	// Read-only transactions are only ever rolled back, so this is where
+	// the errors of their failed reads are surfaced.
+	return tx.err
+}
+
+// ReadWriteBucket opens the root bucket for read/write access.  If the
+// bucket described by the key does not exist, nil is returned.
+func (tx *readWriteTx) ReadWriteBucket(key []byte) walletdb.ReadWriteBucket {
+	if len(key) == 0 {
+		return nil
+	}
+
+	bucket := newReadWriteBucket(tx, nil)
+	return bucket.NestedReadWriteBucket(key)
+}
+
+// CreateTopLevelBucket creates the top level bucket for a key if it
+// does not exist.  The newly-created bucket it returned.
+func (tx *readWriteTx) CreateTopLevelBucket(key []byte) (walletdb.ReadWriteBucket, error) {
+	if len(key) == 0 {
+		return nil, walletdb.ErrBucketNameRequired
+	}
+
+	bucket := newReadWriteBucket(tx, nil)
+	return bucket.CreateBucketIfNotExists(key)
+}
+
+// DeleteTopLevelBucket deletes the top level bucket for a key.  This
+// errors if the bucket can not be found or the key keys a single value
+// instead of a bucket.
+func (tx *readWriteTx) DeleteTopLevelBucket(key []byte) error {
+	// Execute a cascading delete on the key.
+	result, err := tx.Exec(
+		"DELETE FROM "+tx.db.table+" WHERE key=$1 "+
+			"AND parent_id IS NULL",
+		key,
+	)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return walletdb.ErrBucketNotFound
+	}
+
+	return nil
+}
+
+// Commit commits the transaction if not already committed.
+func (tx *readWriteTx) Commit() error {
+	// Commit will fail if the transaction is already committed.
+	if !tx.active {
+		return walletdb.ErrTxClosed
+	}
+
+	// Don't commit changes that were based on a failed read. Rollback
+	// returns the error of the read.
+	if tx.err != nil {
+		return tx.Rollback()
+	}
+
+	// Try committing the transaction.
+	err := tx.tx.Commit()
+	if err == nil && tx.onCommit != nil {
+		tx.onCommit()
+	}
+
+	// Unlock the transaction regardless of the error result.
+	tx.active = false
+	tx.unlock()
+
+	return err
+}
+
+// unlock releases the write lock if this transaction holds it.
+func (tx *readWriteTx) unlock() {
+	if tx.locker != nil {
+		tx.locker.Unlock()
+	}
+}
+
+// setErr records an error that occurred in a call that cannot return it. Only
+// the first error is kept, as later ones are likely caused by it.
+func (tx *readWriteTx) setErr(err error) {
+	if tx.err == nil {
+		tx.err = err
+	}
+}
+
+// OnCommit sets the commit callback (overriding if already set).
+func (tx *readWriteTx) OnCommit(cb func()) {
+	tx.onCommit = cb
+}
+
+// QueryRow executes a QueryRow call with a timeout context.
+func (tx *readWriteTx) QueryRow(query string, args ...interface{}) (*sql.Row,
+	func()) {
+
+	ctx, cancel := tx.db.getTimeoutCtx()
+	return tx.tx.QueryRowContext(ctx, query, args...), cancel
+}
+
+// Query executes a multi-row query call with a timeout context.
+func (tx *readWriteTx) Query(query string, args ...interface{}) (*sql.Rows,
+	func(), error) {
+
+	ctx, cancel := tx.db.getTimeoutCtx()
+	rows, err := tx.tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		cancel()
+
+		return nil, func() {}, err
+	}
+
+	return rows, cancel, nil
+}
+
+// Exec executes a Exec call with a timeout context.
+func (tx *readWriteTx) Exec(query string, args ...interface{}) (sql.Result,
+	error) {
+
+	ctx, cancel := tx.db.getTimeoutCtx()
+	defer cancel()
+
+	return tx.tx.ExecContext(ctx, query, args...)
+}
@@ -0,0 +1,112 @@
+//go:build kvdb_sqlite
+// +build kvdb_sqlite
+
+package kvdb
+
+import (
+	"testing"
+
+	"github.com/brsuite/broln/kvdb/sqlite"
+	"github.com/brsuite/bronwallet/walletdb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSqlite(t *testing.T) {
+	tests := []struct {
+		name string
+		test func(*testing.T, walletdb.DB)
+	}{
+		{
+			name: "read cursor empty interval",
+			test: testReadCursorEmptyInterval,
+		},
+		{
+			name: "read cursor non empty interval",
+			test: testReadCursorNonEmptyInterval,
+		},
+		{
+			name: "read write cursor",
+			test: testReadWriteCursor,
+		},
+		{
+			name: "read write cursor with bucket and value",
+			test: testReadWriteCursorWithBucketAndValue,
+		},
+		{
+			name: "bucket creation",
+			test: testBucketCreation,
+		},
+		{
+			name: "bucket deletion",
+			test: testBucketDeletion,
+		},
+		{
+			name: "bucket for each",
+			test: func(t *testing.T, db walletdb.DB) {
+				testBucketIterator(t, db, func(bucket walletdb.ReadWriteBucket,
+					callback func(key, val []byte) error) error {
+
+					return bucket.ForEach(callback)
+				})
+			},
+		},
+		{
+			name: "bucket for all",
+			test: func(t *testing.T, db walletdb.DB) {
+				testBucketIterator(t, db, func(bucket walletdb.ReadWriteBucket,
+					callback func(key, val []byte) error) error {
+
+					return ForAll(bucket, callback)
+				})
+			},
+		},
+		{
+			name: "bucket for each with error",
+			test: testBucketForEachWithError,
+		},
+		{
+			name: "bucket sequence",
+			test: testBucketSequence,
+		},
+		{
+			name: "key clash",
+			test: testKeyClash,
+		},
+		{
+			name: "bucket create delete",
+			test: testBucketCreateDelete,
+		},
+		{
+			name: "tx manual commit",
+			test: testTxManualCommit,
+		},
+		{
+			name: "tx rollback",
+			test: testTxRollback,
+		},
+		{
+			name: "top level bucket creation",
+			test: testTopLevelBucketCreation,
+		},
+		{
+			name: "bucket operation",
+			test: testBucketOperations,
+		},
+		{
+			name: "sub bucket sequence",
+			test: testSubBucketSequence,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			f, err := sqlite.NewFixture(t.TempDir(), "tmp.db")
+			require.NoError(t, err)
+			defer f.DB().Close()
+
+			test.test(t, f.DB())
+		})
+	}
+}
@@ -68,6 +68,9 @@ const (
 
 	// LabelTypeSweepTransaction is used to label sweeps.
 	LabelTypeSweepTransaction LabelType = "sweep"
+
+	// LabelTypeChannelSplice is used to label channel splices.
+	LabelTypeChannelSplice LabelType = "splicechannel"
 )
 
 // LabelField is used to tag a value within a label.
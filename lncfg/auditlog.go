@@ -0,0 +1,14 @@
+package lncfg
+
+// AuditLog holds the configuration of the audit log of state-changing RPC
+// calls.
+type AuditLog struct {
+	Enable  bool   `long:"enable" description:"Record every state-changing RPC call, such as payments, channel opens and closes, on-chain sends, macaroon bakes and policy updates, in a tamper-evident log."`
+	File    string `long:"file" description:"The path of the audit log file. Defaults to audit.log in the data directory of the active network."`
+	KeyFile string `long:"keyfile" description:"The path of the file holding the key the audit log is authenticated with. It is created if it doesn't exist. Defaults to audit.key in the data directory of the active network."`
+}
+
+// DefaultAuditLog returns the default values of the audit log configuration.
+func DefaultAuditLog() *AuditLog {
+	return &AuditLog{}
+}
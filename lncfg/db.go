@@ -2,12 +2,14 @@ package lncfg
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"time"
 
 	"github.com/brsuite/broln/kvdb"
 	"github.com/brsuite/broln/kvdb/etcd"
 	"github.com/brsuite/broln/kvdb/postgres"
+	"github.com/brsuite/broln/kvdb/sqlite"
 	"github.com/brsuite/broln/lnwallet/btcwallet"
 )
 
@@ -18,12 +20,27 @@ const (
 	towerClientDBName = "wtclient.db"
 	towerServerDBName = "watchtower.db"
 
+	// SqliteChannelDBName is the name of the sqlite database file that
+	// holds the channel state, graph, decayed log and tower client
+	// namespaces.
+	SqliteChannelDBName = "channel.sqlite"
+
+	// SqliteChainDBName is the name of the sqlite database file that holds
+	// the per-network macaroon and wallet namespaces.
+	SqliteChainDBName = "chain.sqlite"
+
+	// SqliteTowerDBName is the name of the sqlite database file that holds
+	// the watchtower server namespace.
+	SqliteTowerDBName = "watchtower.sqlite"
+
 	BoltBackend                = "bolt"
 	EtcdBackend                = "etcd"
 	PostgresBackend            = "postgres"
+	SqliteBackend              = "sqlite"
 	DefaultBatchCommitInterval = 500 * time.Millisecond
 
 	defaultPostgresMaxConnections = 50
+	defaultSqliteBusyTimeout      = 5 * time.Second
 
 	// NSChannelDB is the namespace name that we use for the combined graph
 	// and channel state DB.
@@ -60,7 +77,11 @@ type DB struct {
 
 	Postgres *postgres.Config `group:"postgres" namespace:"postgres" description:"Postgres settings."`
 
+	Sqlite *sqlite.Config `group:"sqlite" namespace:"sqlite" description:"Sqlite settings."`
+
 	NoGraphCache bool `long:"no-graph-cache" description:"Don't use the in-memory graph cache for path finding. Much slower but uses less RAM. Can only be used with a bolt database backend."`
+
+	UseNativeSQL bool `long:"use-native-sql" description:"Use native SQL tables instead of the key-value store for the data that supports it, currently invoices. Existing invoices are migrated on startup and the migration can't be reverted. Can only be used with the postgres or sqlite database backend."`
 }
 
 // DefaultDB creates and returns a new default DB config.
@@ -80,6 +101,9 @@ func DefaultDB() *DB {
 		Postgres: &postgres.Config{
 			MaxConnections: defaultPostgresMaxConnections,
 		},
+		Sqlite: &sqlite.Config{
+			BusyTimeout: defaultSqliteBusyTimeout,
+		},
 	}
 }
 
@@ -92,14 +116,21 @@ func (db *DB) Validate() error {
 			return fmt.Errorf("postgres dsn must be set")
 		}
 
+	case SqliteBackend:
+		if db.Sqlite.BusyTimeout < 0 {
+			return fmt.Errorf("sqlite busy timeout must not be " +
+				"negative")
+		}
+
 	case EtcdBackend:
 		if !db.Etcd.Embedded && db.Etcd.Host == "" {
 			return fmt.Errorf("etcd host must be set")
 		}
 
 	default:
-		return fmt.Errorf("unknown backend, must be either '%v' or "+
-			"'%v'", BoltBackend, EtcdBackend)
+		return fmt.Errorf("unknown backend, must be one of '%v', "+
+			"'%v', '%v' or '%v'", BoltBackend, EtcdBackend,
+			PostgresBackend, SqliteBackend)
 	}
 
 	// The path finding uses a manual read transaction that's open for a
@@ -115,6 +146,15 @@ func (db *DB) Validate() error {
 			"backend '%v'", db.Backend)
 	}
 
+	// Native SQL tables are only available if the backend is actually a
+	// SQL database.
+	if db.UseNativeSQL && db.Backend != PostgresBackend &&
+		db.Backend != SqliteBackend {
+
+		return fmt.Errorf("cannot use use-native-sql with database "+
+			"backend '%v'", db.Backend)
+	}
+
 	return nil
 }
 
@@ -180,8 +220,13 @@ type DatabaseBackends struct {
 	// the underlying wallet database from.
 	WalletDB btcwallet.LoaderOption
 
+	// NativeSQLDB is the SQL database connection of the channel state
+	// backend that is used for the data that is stored in native SQL
+	// tables. This is only set if native SQL tables are enabled.
+	NativeSQLDB *sql.DB
+
 	// Remote indicates whether the database backends are remote, possibly
-	// replicated instances or local bbolt backed databases.
+	// replicated instances or local bbolt or sqlite backed databases.
 	Remote bool
 
 	// CloseFuncs is a map of close functions for each of the initialized
@@ -189,6 +234,22 @@ type DatabaseBackends struct {
 	CloseFuncs map[string]func() error
 }
 
+// nativeSQLDB returns the SQL database connection of the passed backend if
+// native SQL tables are enabled, and nil otherwise.
+func (db *DB) nativeSQLDB(backend kvdb.Backend) (*sql.DB, error) {
+	if !db.UseNativeSQL {
+		return nil, nil
+	}
+
+	sqlBackend, ok := backend.(kvdb.SQLBackend)
+	if !ok {
+		return nil, fmt.Errorf("database backend '%v' doesn't support "+
+			"native SQL tables", db.Backend)
+	}
+
+	return sqlBackend.SQLDB(), nil
+}
+
 // GetBackends returns a set of kvdb.Backends as set in the DB config.
 func (db *DB) GetBackends(ctx context.Context, chanDBPath,
 	walletDBPath, towerServerDBPath string, towerClientEnabled,
@@ -316,6 +377,11 @@ func (db *DB) GetBackends(ctx context.Context, chanDBPath,
 		}
 		closeFuncs[NSChannelDB] = postgresBackend.Close
 
+		nativeSQLDB, err := db.nativeSQLDB(postgresBackend)
+		if err != nil {
+			return nil, err
+		}
+
 		postgresMacaroonBackend, err := kvdb.Open(
 			kvdb.PostgresBackendName, ctx,
 			db.Postgres, NSMacaroonDB,
@@ -383,8 +449,110 @@ func (db *DB) GetBackends(ctx context.Context, chanDBPath,
 			WalletDB: btcwallet.LoaderWithExternalWalletDB(
 				postgresWalletBackend,
 			),
-			Remote:     true,
-			CloseFuncs: closeFuncs,
+			NativeSQLDB: nativeSQLDB,
+			Remote:      true,
+			CloseFuncs:  closeFuncs,
+		}, nil
+
+	case SqliteBackend:
+		// The channel state, graph, height hint, decayed log and tower
+		// client data all live in the same file next to where the bolt
+		// channel.db would be. Each of them gets its own namespace
+		// within that file.
+		sqliteBackend, err := kvdb.Open(
+			kvdb.SqliteBackendName, ctx, db.Sqlite, chanDBPath,
+			SqliteChannelDBName, NSChannelDB,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error opening sqlite graph "+
+				"DB: %v", err)
+		}
+		closeFuncs[NSChannelDB] = sqliteBackend.Close
+
+		nativeSQLDB, err := db.nativeSQLDB(sqliteBackend)
+		if err != nil {
+			return nil, err
+		}
+
+		sqliteMacaroonBackend, err := kvdb.Open(
+			kvdb.SqliteBackendName, ctx, db.Sqlite, walletDBPath,
+			SqliteChainDBName, NSMacaroonDB,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error opening sqlite "+
+				"macaroon DB: %v", err)
+		}
+		closeFuncs[NSMacaroonDB] = sqliteMacaroonBackend.Close
+
+		sqliteDecayedLogBackend, err := kvdb.Open(
+			kvdb.SqliteBackendName, ctx, db.Sqlite, chanDBPath,
+			SqliteChannelDBName, NSDecayedLogDB,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error opening sqlite decayed "+
+				"log DB: %v", err)
+		}
+		closeFuncs[NSDecayedLogDB] = sqliteDecayedLogBackend.Close
+
+		// The tower client is optional and might not be enabled by the
+		// user. We handle it being nil properly in the main server.
+		var sqliteTowerClientBackend kvdb.Backend
+		if towerClientEnabled {
+			sqliteTowerClientBackend, err = kvdb.Open(
+				kvdb.SqliteBackendName, ctx, db.Sqlite,
+				chanDBPath, SqliteChannelDBName,
+				NSTowerClientDB,
+			)
+			if err != nil {
+				return nil, fmt.Errorf("error opening sqlite "+
+					"tower client DB: %v", err)
+			}
+			closeFuncs[NSTowerClientDB] = sqliteTowerClientBackend.Close
+		}
+
+		// The tower server is optional and might not be enabled by the
+		// user. We handle it being nil properly in the main server.
+		var sqliteTowerServerBackend kvdb.Backend
+		if towerServerEnabled {
+			sqliteTowerServerBackend, err = kvdb.Open(
+				kvdb.SqliteBackendName, ctx, db.Sqlite,
+				towerServerDBPath, SqliteTowerDBName,
+				NSTowerServerDB,
+			)
+			if err != nil {
+				return nil, fmt.Errorf("error opening sqlite "+
+					"tower server DB: %v", err)
+			}
+			closeFuncs[NSTowerServerDB] = sqliteTowerServerBackend.Close
+		}
+
+		sqliteWalletBackend, err := kvdb.Open(
+			kvdb.SqliteBackendName, ctx, db.Sqlite, walletDBPath,
+			SqliteChainDBName, NSWalletDB,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error opening sqlite wallet "+
+				"DB: %v", err)
+		}
+		closeFuncs[NSWalletDB] = sqliteWalletBackend.Close
+
+		returnEarly = false
+		return &DatabaseBackends{
+			GraphDB:       sqliteBackend,
+			ChanStateDB:   sqliteBackend,
+			HeightHintDB:  sqliteBackend,
+			MacaroonDB:    sqliteMacaroonBackend,
+			DecayedLogDB:  sqliteDecayedLogBackend,
+			TowerClientDB: sqliteTowerClientBackend,
+			TowerServerDB: sqliteTowerServerBackend,
+			// The wallet loader will attempt to use/create the
+			// wallet in the sqlite chain DB instead of a separate
+			// bbolt wallet.db file.
+			WalletDB: btcwallet.LoaderWithExternalWalletDB(
+				sqliteWalletBackend,
+			),
+			NativeSQLDB: nativeSQLDB,
+			CloseFuncs:  closeFuncs,
 		}, nil
 	}
 
@@ -0,0 +1,83 @@
+package lncfg
+
+import (
+	"fmt"
+
+	"github.com/brsuite/bronutil"
+	"github.com/brsuite/broln/lnwire"
+)
+
+const (
+	// DefaultLeaseFundingWeight is the default weight our inputs and change
+	// output are expected to add to the funding transaction of a leased
+	// channel. It covers two P2WKH inputs and a P2WKH change output.
+	DefaultLeaseFundingWeight = 2*272 + 124
+)
+
+// LiquidityAds holds the configuration options for leasing our liquidity to
+// peers opening dual funded channels with us.
+type LiquidityAds struct {
+	Enable bool `long:"enable" description:"If true, our node will contribute funds to dual funded channels whose opener requests our liquidity, charging the configured lease rates. Requires protocol.dual-funding."`
+
+	LeaseFeeBase uint32 `long:"lease-fee-base" description:"The flat fee in satoshis charged for every lease."`
+
+	LeaseFeeBasis uint16 `long:"lease-fee-basis" description:"The proportional fee charged for a lease, in basis points of the leased amount."`
+
+	FundingWeight uint16 `long:"funding-weight" description:"The weight our inputs and change output are expected to add to the funding transaction. The opener pays for this weight at the funding fee rate."`
+
+	MaxChanFeeBaseMsat uint32 `long:"max-chan-fee-base-msat" description:"The maximum base routing fee in millisatoshis we commit to charge on leased channels."`
+
+	MaxChanFeeProportional uint16 `long:"max-chan-fee-proportional" description:"The maximum proportional routing fee, in thousandths of the base fee, we commit to charge on leased channels."`
+
+	MaxLeaseAmt int64 `long:"max-lease-amt" description:"The maximum amount in satoshis we contribute to a single channel. If zero, the requested amount is contributed in full."`
+}
+
+// DefaultLiquidityAds returns the default configuration for liquidity ads.
+func DefaultLiquidityAds() *LiquidityAds {
+	return &LiquidityAds{
+		FundingWeight: DefaultLeaseFundingWeight,
+	}
+}
+
+// LeaseRates returns the lease rates we advertise, or nil if we don't lease
+// our liquidity.
+func (l *LiquidityAds) LeaseRates() *lnwire.LeaseRates {
+	if !l.Enable {
+		return nil
+	}
+
+	return &lnwire.LeaseRates{
+		FundingWeight:             l.FundingWeight,
+		LeaseFeeBasis:             l.LeaseFeeBasis,
+		ChannelFeeMaxProportional: l.MaxChanFeeProportional,
+		LeaseFeeBaseSat:           l.LeaseFeeBase,
+		ChannelFeeMaxBaseMsat:     l.MaxChanFeeBaseMsat,
+	}
+}
+
+// MaxLeaseAmount returns the maximum amount we contribute to a single channel.
+func (l *LiquidityAds) MaxLeaseAmount() bronutil.Amount {
+	return bronutil.Amount(l.MaxLeaseAmt)
+}
+
+// Validate checks the LiquidityAds configuration to ensure that the input
+// values are sane.
+func (l *LiquidityAds) Validate() error {
+	if l.MaxLeaseAmt < 0 {
+		return fmt.Errorf("max lease amount (%d) must not be "+
+			"negative", l.MaxLeaseAmt)
+	}
+
+	// The lease fee basis is expressed in basis points, so anything above
+	// 100% would charge more than the leased amount.
+	if l.LeaseFeeBasis > 10000 {
+		return fmt.Errorf("lease fee basis (%d) must not exceed 10000",
+			l.LeaseFeeBasis)
+	}
+
+	return nil
+}
+
+// Compile-time constraint to ensure LiquidityAds implements the Validator
+// interface.
+var _ Validator = (*LiquidityAds)(nil)
@@ -10,9 +10,10 @@ type Prometheus struct {
 	// Prometheus server to scrape our metrics.
 	Listen string `long:"listen" description:"the interface we should listen on for Prometheus"`
 
-	// Enable indicates whether to export broln gRPC performance metrics to
-	// Prometheus. Default is false.
-	Enable bool `long:"enable" description:"enable Prometheus exporting of broln gRPC performance metrics."`
+	// Enable indicates whether to export broln gRPC performance metrics,
+	// along with the metrics of the node's channels, forwards and other
+	// subsystems, to Prometheus. Default is false.
+	Enable bool `long:"enable" description:"enable Prometheus exporting of broln gRPC performance and node metrics."`
 }
 
 // DefaultPrometheus is the default configuration for the Prometheus metrics
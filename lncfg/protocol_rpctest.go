@@ -30,6 +30,45 @@ type ProtocolOptions struct {
 	//
 	// TODO: Move to experimental?
 	ScriptEnforcedLease bool `long:"script-enforced-lease" description:"enable support for script enforced lease commitments"`
+
+	// OptionScidAlias should be set if we want to signal the
+	// option-scid-alias feature bit. This allows scid aliases and the
+	// option-scid-alias channel-type.
+	OptionScidAlias bool `long:"option-scid-alias" description:"enable support for option_scid_alias channels"`
+
+	// OptionZeroConf should be set if we want to signal the zero-conf
+	// feature bit.
+	OptionZeroConf bool `long:"zero-conf" description:"enable support for zero-conf channels, must have option-scid-alias set also"`
+
+	// OptionOffers should be set if we want to create and pay BOLT 12
+	// offers. This enables the onion messages invoices are requested
+	// over.
+	OptionOffers bool `long:"offers" description:"enable support for BOLT 12 offers and the onion messages they use"`
+
+	// OptionOnionMessages should be set if we want to send, receive and
+	// forward onion messages.
+	OptionOnionMessages bool `long:"onion-messages" description:"enable sending, receiving and forwarding onion messages"`
+
+	// NoRouteBlindingOption should be set if we don't want to forward
+	// payments within blinded routes.
+	NoRouteBlindingOption bool `long:"no-route-blinding" description:"disable support for forwarding payments within blinded routes"`
+
+	// OptionDualFunding should be set if we want to open and accept dual
+	// funded channels.
+	OptionDualFunding bool `long:"dual-funding" description:"enable support for dual funded channels constructed with the interactive transaction protocol"`
+
+	// OptionSplicing should be set if we want to splice funds into and out
+	// of existing channels.
+	OptionSplicing bool `long:"splicing" description:"enable support for splicing funds into and out of existing channels, must have dual-funding set also"`
+
+	// OptionPeerStorage should be set if we want to store encrypted
+	// channel backups with our channel peers, and store theirs in turn.
+	OptionPeerStorage bool `long:"peer-storage" description:"enable storing encrypted channel backups with channel peers, and storing their backups in return"`
+
+	// OptionRbfCoopClose should be set if we want to close channels
+	// cooperatively with closing transactions that can be replaced by
+	// either party with one paying a higher fee.
+	OptionRbfCoopClose bool `long:"rbf-coop-close" description:"enable cooperative closes whose closing transaction can be fee bumped by either party"`
 }
 
 // Wumbo returns true if broln should permit the creation and acceptance of wumbo
@@ -49,3 +88,54 @@ func (l *ProtocolOptions) NoAnchorCommitments() bool {
 func (l *ProtocolOptions) NoScriptEnforcementLease() bool {
 	return !l.ScriptEnforcedLease
 }
+
+// ScidAlias returns true if we have enabled the option-scid-alias TLV
+// extension.
+func (l *ProtocolOptions) ScidAlias() bool {
+	return l.OptionScidAlias
+}
+
+// ZeroConf returns true if we have enabled the zero-conf feature bit.
+func (l *ProtocolOptions) ZeroConf() bool {
+	return l.OptionZeroConf
+}
+
+// Offers returns true if we have enabled support for BOLT 12 offers.
+func (l *ProtocolOptions) Offers() bool {
+	return l.OptionOffers
+}
+
+// OnionMessages returns true if we have enabled onion messages, either
+// explicitly or because offers depend on them.
+func (l *ProtocolOptions) OnionMessages() bool {
+	return l.OptionOnionMessages || l.OptionOffers
+}
+
+// NoRouteBlinding returns true if forwarding payments within blinded routes
+// has been disabled.
+func (l *ProtocolOptions) NoRouteBlinding() bool {
+	return l.NoRouteBlindingOption
+}
+
+// DualFunding returns true if we have enabled support for dual funded
+// channels.
+func (l *ProtocolOptions) DualFunding() bool {
+	return l.OptionDualFunding
+}
+
+// Splicing returns true if we have enabled support for splicing channels.
+func (l *ProtocolOptions) Splicing() bool {
+	return l.OptionSplicing
+}
+
+// PeerStorage returns true if we have enabled storing encrypted channel
+// backups with our channel peers.
+func (l *ProtocolOptions) PeerStorage() bool {
+	return l.OptionPeerStorage
+}
+
+// RbfCoopClose returns true if we have enabled cooperative closes with
+// replaceable closing transactions.
+func (l *ProtocolOptions) RbfCoopClose() bool {
+	return l.OptionRbfCoopClose
+}
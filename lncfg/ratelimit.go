@@ -0,0 +1,145 @@
+package lncfg
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// DefaultRateLimitRate is the default number of calls per second
+	// allowed for each RPC method and macaroon root key ID.
+	DefaultRateLimitRate = 10
+
+	// DefaultRateLimitBurst is the default number of calls to an RPC
+	// method that can be made at once with the macaroons of a root key ID.
+	DefaultRateLimitBurst = 20
+)
+
+// TokenBucket describes the limit of a token bucket rate limiter.
+type TokenBucket struct {
+	// Rate is the number of calls per second that are allowed on average.
+	Rate float64
+
+	// Burst is the number of calls that can be made at once.
+	Burst int
+}
+
+// RateLimit holds the configuration of the RPC rate limiter.
+type RateLimit struct {
+	Enable  bool     `long:"enable" description:"Enable rate limiting of the gRPC and REST calls, keyed by macaroon root key ID and RPC method."`
+	Rate    float64  `long:"rate" description:"The number of calls per second allowed for each RPC method and macaroon root key ID."`
+	Burst   int      `long:"burst" description:"The number of calls to each RPC method that can be made at once with the macaroons of a root key ID."`
+	Methods []string `long:"method" description:"Override the limit of an RPC method, as <method URI>=<rate>:<burst>, for example /lnrpc.Lightning/DescribeGraph=0.1:2. Can be specified multiple times."`
+	Quotas  []string `long:"quota" description:"Limit the total number of calls made with the macaroons of a root key ID across all RPC methods, as <root key ID>=<rate>:<burst>. Can be specified multiple times."`
+}
+
+// DefaultRateLimit returns the default values of the RPC rate limiter
+// configuration.
+func DefaultRateLimit() *RateLimit {
+	return &RateLimit{
+		Rate:  DefaultRateLimitRate,
+		Burst: DefaultRateLimitBurst,
+	}
+}
+
+// Validate checks the values configured for the RPC rate limiter.
+func (r *RateLimit) Validate() error {
+	if !r.Enable {
+		return nil
+	}
+
+	if err := validateTokenBucket(r.Rate, r.Burst); err != nil {
+		return fmt.Errorf("invalid default rate limit: %v", err)
+	}
+
+	if _, err := r.MethodLimits(); err != nil {
+		return err
+	}
+
+	_, err := r.RootKeyQuotas()
+	return err
+}
+
+// DefaultLimit returns the limit of the RPC methods that don't have a limit
+// of their own.
+func (r *RateLimit) DefaultLimit() TokenBucket {
+	return TokenBucket{
+		Rate:  r.Rate,
+		Burst: r.Burst,
+	}
+}
+
+// MethodLimits returns the limits of the RPC methods overriding the default
+// limit, keyed by method URI.
+func (r *RateLimit) MethodLimits() (map[string]TokenBucket, error) {
+	return parseTokenBuckets(r.Methods)
+}
+
+// RootKeyQuotas returns the limits of the total calls made with the
+// macaroons of a root key ID, keyed by root key ID.
+func (r *RateLimit) RootKeyQuotas() (map[string]TokenBucket, error) {
+	return parseTokenBuckets(r.Quotas)
+}
+
+// parseTokenBuckets parses a list of limits formatted as <key>=<rate>:<burst>.
+func parseTokenBuckets(limits []string) (map[string]TokenBucket, error) {
+	buckets := make(map[string]TokenBucket, len(limits))
+	for _, limit := range limits {
+		// Method URIs don't contain an equals sign, so the last one
+		// separates the key from the limit.
+		idx := strings.LastIndex(limit, "=")
+		if idx <= 0 {
+			return nil, fmt.Errorf("invalid rate limit %q, expected "+
+				"<key>=<rate>:<burst>", limit)
+		}
+		key, value := limit[:idx], limit[idx+1:]
+
+		parts := strings.Split(value, ":")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid rate limit %q, expected "+
+				"<key>=<rate>:<burst>", limit)
+		}
+
+		rate, err := strconv.ParseFloat(parts[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rate of limit %q: %v",
+				limit, err)
+		}
+
+		burst, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid burst of limit %q: %v",
+				limit, err)
+		}
+
+		if err := validateTokenBucket(rate, burst); err != nil {
+			return nil, fmt.Errorf("invalid rate limit %q: %v",
+				limit, err)
+		}
+
+		if _, ok := buckets[key]; ok {
+			return nil, fmt.Errorf("duplicate rate limit for %v",
+				key)
+		}
+
+		buckets[key] = TokenBucket{
+			Rate:  rate,
+			Burst: burst,
+		}
+	}
+
+	return buckets, nil
+}
+
+// validateTokenBucket checks that a token bucket allows at least one call.
+func validateTokenBucket(rate float64, burst int) error {
+	if rate <= 0 {
+		return fmt.Errorf("rate must be positive")
+	}
+	if burst < 1 {
+		return fmt.Errorf("burst must be at least 1")
+	}
+
+	return nil
+}
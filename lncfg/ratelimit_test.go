@@ -0,0 +1,109 @@
+package lncfg_test
+
+import (
+	"testing"
+
+	"github.com/brsuite/broln/lncfg"
+	"github.com/stretchr/testify/require"
+)
+
+// TestValidateRateLimit asserts that validating the RateLimit config only
+// succeeds if all limits are well formed and allow at least one call.
+func TestValidateRateLimit(t *testing.T) {
+	tests := []struct {
+		name    string
+		methods []string
+		quotas  []string
+		rate    float64
+		burst   int
+		valid   bool
+	}{
+		{
+			name:  "defaults",
+			rate:  lncfg.DefaultRateLimitRate,
+			burst: lncfg.DefaultRateLimitBurst,
+			valid: true,
+		},
+		{
+			name: "method and quota limits",
+			methods: []string{
+				"/lnrpc.Lightning/DescribeGraph=0.1:2",
+				"/lnrpc.Lightning/ListInvoices=1:5",
+			},
+			quotas: []string{"0=50:100"},
+			rate:   1,
+			burst:  1,
+			valid:  true,
+		},
+		{
+			name:  "zero rate",
+			rate:  0,
+			burst: 1,
+		},
+		{
+			name:  "zero burst",
+			rate:  1,
+			burst: 0,
+		},
+		{
+			name:    "missing burst",
+			methods: []string{"/lnrpc.Lightning/GetInfo=1"},
+			rate:    1,
+			burst:   1,
+		},
+		{
+			name:    "missing key",
+			methods: []string{"=1:1"},
+			rate:    1,
+			burst:   1,
+		},
+		{
+			name:   "invalid rate",
+			quotas: []string{"0=fast:1"},
+			rate:   1,
+			burst:  1,
+		},
+		{
+			name: "duplicate method",
+			methods: []string{
+				"/lnrpc.Lightning/GetInfo=1:1",
+				"/lnrpc.Lightning/GetInfo=2:2",
+			},
+			rate:  1,
+			burst: 1,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			cfg := &lncfg.RateLimit{
+				Enable:  true,
+				Rate:    test.rate,
+				Burst:   test.burst,
+				Methods: test.methods,
+				Quotas:  test.quotas,
+			}
+
+			err := cfg.Validate()
+			if test.valid {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+
+			// A disabled rate limiter is never validated.
+			cfg.Enable = false
+			require.NoError(t, cfg.Validate())
+		})
+	}
+
+	cfg := &lncfg.RateLimit{
+		Methods: []string{"/lnrpc.Lightning/DescribeGraph=0.1:2"},
+	}
+	limits, err := cfg.MethodLimits()
+	require.NoError(t, err)
+	require.Equal(t, map[string]lncfg.TokenBucket{
+		"/lnrpc.Lightning/DescribeGraph": {Rate: 0.1, Burst: 2},
+	}, limits)
+}
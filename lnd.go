@@ -28,11 +28,13 @@ import (
 	"gopkg.in/macaroon-bakery.v2/bakery"
 	"gopkg.in/macaroon.v2"
 
+	"github.com/brsuite/broln/auditlog"
 	"github.com/brsuite/broln/autopilot"
 	"github.com/brsuite/broln/build"
 	"github.com/brsuite/broln/cert"
 	"github.com/brsuite/broln/chanacceptor"
 	"github.com/brsuite/broln/channeldb"
+	"github.com/brsuite/broln/clock"
 	"github.com/brsuite/broln/keychain"
 	"github.com/brsuite/broln/lncfg"
 	"github.com/brsuite/broln/lnrpc"
@@ -254,7 +256,30 @@ func Main(cfg *Config, lisCfg ListenerCfg, implCfg *ImplementationCfg,
 	// will be used to log the API calls invoked on the GRPC server.
 	interceptorChain := rpcperms.NewInterceptorChain(
 		rpcsLog, cfg.NoMacaroons, cfg.RPCMiddleware.Mandatory,
+		cfg.ActiveNetParams.Params,
 	)
+	if err := interceptorChain.SetRateLimit(cfg.RateLimit); err != nil {
+		return mkErr("error setting up RPC rate limiter: %v", err)
+	}
+
+	// If enabled, open the audit log that state-changing RPC calls are
+	// recorded in before any of them can be made.
+	if cfg.AuditLog.Enable {
+		auditLog, err := auditlog.Open(
+			cfg.AuditLog.File, cfg.AuditLog.KeyFile,
+			clock.NewDefaultClock(),
+		)
+		if err != nil {
+			return mkErr("error opening audit log: %v", err)
+		}
+		defer func() {
+			if err := auditLog.Close(); err != nil {
+				ltndLog.Warnf("error closing audit log: %v", err)
+			}
+		}()
+
+		interceptorChain.AddAuditLog(auditLog)
+	}
 	if err := interceptorChain.Start(); err != nil {
 		return mkErr("error starting interceptor chain: %v", err)
 	}
@@ -508,6 +533,34 @@ func Main(cfg *Config, lisCfg ListenerCfg, implCfg *ImplementationCfg,
 		return mkErr("unable to create server: %v", err)
 	}
 
+	// Requests made with macaroons bound to an off-chain account can only
+	// be served once the service tracking the account balances exists.
+	interceptorChain.AddAccountService(server.accountService)
+
+	// The amounts spent with macaroons carrying a payment budget caveat are
+	// persisted, so the budgets survive restarts.
+	err = interceptorChain.AddPaymentSpendDB(dbs.ChanStateDB)
+	if err != nil {
+		return mkErr("unable to load macaroon payment spends: %v", err)
+	}
+
+	// If Prometheus monitoring is enabled, export the metrics of the
+	// node's subsystems alongside those of the gRPC server.
+	if cfg.Prometheus.Enabled() {
+		err := monitoring.RegisterNodeMetrics(&monitoring.MetricsConfig{
+			Channels:          server.chanStateDB,
+			Graph:             server.graphDB,
+			Switch:            server.htlcSwitch,
+			Sweeper:           server.sweeper,
+			TowerClient:       server.towerClient,
+			AnchorTowerClient: server.anchorTowerClient,
+			MissionControl:    server.missionControl,
+		})
+		if err != nil {
+			return mkErr("unable to register node metrics: %v", err)
+		}
+	}
+
 	// Set up an autopilot manager from the current config. This will be
 	// used to manage the underlying autopilot agent, starting and stopping
 	// it at will.
@@ -9,7 +9,9 @@ import (
 	"math"
 	"time"
 
+	"github.com/brsuite/brond/btcec"
 	"github.com/brsuite/brond/chaincfg"
+	"github.com/brsuite/brond/chaincfg/chainhash"
 	"github.com/brsuite/brond/wire"
 	"github.com/brsuite/bronutil"
 	"github.com/davecgh/go-spew/spew"
@@ -19,6 +21,8 @@ import (
 	"github.com/brsuite/broln/lnwire"
 	"github.com/brsuite/broln/netann"
 	"github.com/brsuite/broln/routing"
+	"github.com/brsuite/broln/routing/blindedpath"
+	"github.com/brsuite/broln/routing/route"
 	"github.com/brsuite/broln/zpay32"
 )
 
@@ -30,6 +34,19 @@ const (
 	// DefaultAMPInvoiceExpiry is the default invoice expiry for new AMP
 	// invoices.
 	DefaultAMPInvoiceExpiry = 30 * 24 * time.Hour
+
+	// blindedPathExpiryGrace is the number of blocks payments over the
+	// blinded path of an invoice may be delayed by on top of the expiry of
+	// the invoice itself, before the path stops accepting them.
+	blindedPathExpiryGrace = 144
+)
+
+var (
+	// ErrNoIntroductionNode is returned when a blinded invoice is requested
+	// but none of our peers is suitable as the introduction node of its
+	// blinded path.
+	ErrNoIntroductionNode = errors.New("no peer suitable as introduction " +
+		"node of a blinded path")
 )
 
 // AddInvoiceConfig contains dependencies for invoice creation.
@@ -67,6 +84,10 @@ type AddInvoiceConfig struct {
 	// GenAmpInvoiceFeatures returns a feature containing feature bits that
 	// should be advertised on freshly generated AMP invoices.
 	GenAmpInvoiceFeatures func() *lnwire.FeatureVector
+
+	// BestHeight returns the current height of the chain. It is used to
+	// limit the time the blinded paths of our invoices accept payments.
+	BestHeight func() (uint32, error)
 }
 
 // AddInvoiceData contains the required data to create a new invoice.
@@ -120,6 +141,10 @@ type AddInvoiceData struct {
 	// RouteHints are optional route hints that can each be individually used
 	// to assist in reaching the invoice's destination.
 	RouteHints [][]zpay32.HopHint
+
+	// Blind signals that the invoice should hide our node behind a blinded
+	// path, and be signed with an ephemeral key instead of our node key.
+	Blind bool
 }
 
 // paymentHashAndPreimage returns the payment hash and preimage for this invoice
@@ -353,6 +378,20 @@ func AddInvoice(ctx context.Context, cfg *AddInvoiceConfig,
 			"maximum of 20")
 	}
 
+	// Route hints would reveal our node, which blinded invoices are meant
+	// to hide. AMP payments can't be received over blinded paths yet.
+	if invoice.Blind {
+		switch {
+		case invoice.Private || len(invoice.RouteHints) > 0:
+			return nil, nil, errors.New("blinded invoices cannot " +
+				"include route hints")
+
+		case invoice.Amp:
+			return nil, nil, errors.New("blinded invoices cannot " +
+				"be AMP invoices")
+		}
+	}
+
 	// We continue by populating the requested routing hints indexing their
 	// corresponding channels so we won't duplicate them.
 	forcedHints := make(map[uint64]struct{})
@@ -425,11 +464,34 @@ func AddInvoice(ctx context.Context, cfg *AddInvoiceConfig,
 		return nil, nil, err
 	}
 
-	payReqString, err := payReq.Encode(zpay32.MessageSigner{
+	signer := zpay32.MessageSigner{
 		SignCompact: func(msg []byte) ([]byte, error) {
 			return cfg.NodeSigner.SignMessageCompact(msg, false)
 		},
-	})
+	}
+
+	// If requested, hide our node behind a blinded path. The invoice can't
+	// be signed by our node key then, as the signature would reveal us
+	// right away, so we use a key that is thrown away afterwards instead.
+	if invoice.Blind {
+		path, err := blindedPaymentPath(
+			cfg, amtMSat, paymentAddr,
+			uint16(payReq.MinFinalCLTVExpiry()), payReq.Expiry(),
+		)
+		if err != nil {
+			return nil, nil, err
+		}
+		payReq.BlindedPaymentPaths = append(
+			payReq.BlindedPaymentPaths, path,
+		)
+
+		signer, err = ephemeralSigner()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	payReqString, err := payReq.Encode(signer)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -530,6 +592,209 @@ func chanCanBeHopHint(channel *channeldb.OpenChannel, cfg *AddInvoiceConfig) (
 	return remotePolicy, true
 }
 
+// chanCanBeIntroduction returns true if the peer of the target channel is
+// eligible as the introduction node of our blinded paths. Along with it, the
+// policy the peer applies to the HTLCs it forwards to us is returned.
+func chanCanBeIntroduction(channel *channeldb.OpenChannel,
+	cfg *AddInvoiceConfig) (*channeldb.ChannelEdgePolicy, bool) {
+
+	// Senders need to be able to find a route to the introduction node,
+	// so we only consider our public channels.
+	isPublic := channel.ChannelFlags&lnwire.FFAnnounceChannel != 0
+	if !isPublic {
+		return nil, false
+	}
+
+	chanPoint := lnwire.NewChanIDFromOutPoint(&channel.FundingOutpoint)
+	if !cfg.IsChannelActive(chanPoint) {
+		return nil, false
+	}
+
+	// The introduction node needs to understand the blinded hop we leave
+	// for it in the onion.
+	remotePub := route.NewVertex(channel.IdentityPub)
+	features, err := cfg.Graph.FetchNodeFeatures(remotePub)
+	if err != nil {
+		log.Errorf("Unable to fetch features of node %v: %v",
+			remotePub, err)
+		return nil, false
+	}
+	if !features.HasFeature(lnwire.RouteBlindingOptional) {
+		log.Debugf("Skipping channel %v due to counterparty %v not "+
+			"supporting route blinding", chanPoint, remotePub)
+		return nil, false
+	}
+
+	chanID := channel.ShortChanID().ToUint64()
+	info, p1, p2, err := cfg.Graph.FetchChannelEdgesByID(chanID)
+	if err != nil {
+		log.Errorf("Unable to fetch the routing policies for the "+
+			"edges of the channel %v: %v", chanPoint, err)
+		return nil, false
+	}
+
+	var remotePolicy *channeldb.ChannelEdgePolicy
+	if bytes.Equal(remotePub[:], info.NodeKey1Bytes[:]) {
+		remotePolicy = p1
+	} else {
+		remotePolicy = p2
+	}
+
+	return remotePolicy, remotePolicy != nil
+}
+
+// blindedPaymentPath creates a blinded path to our node for an invoice of the
+// passed amount. The path starts at the eligible peer we have the most inbound
+// liquidity with, and its final hop carries the payment address of the
+// invoice, so that payments arriving over it are handled like any other
+// multi-path payment.
+func blindedPaymentPath(cfg *AddInvoiceConfig, amt lnwire.MilliSatoshi,
+	paymentAddr [32]byte, finalCltvDelta uint16,
+	expiry time.Duration) (*blindedpath.PaymentPath, error) {
+
+	openChannels, err := cfg.ChanDB.FetchAllChannels()
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch all channels")
+	}
+
+	var (
+		introChan   *channeldb.OpenChannel
+		introPolicy *channeldb.ChannelEdgePolicy
+	)
+	for _, channel := range openChannels {
+		inbound := channel.LocalCommitment.RemoteBalance
+		if inbound < amt {
+			continue
+		}
+
+		if introChan != nil &&
+			inbound <= introChan.LocalCommitment.RemoteBalance {
+
+			continue
+		}
+
+		policy, ok := chanCanBeIntroduction(channel, cfg)
+		if !ok {
+			continue
+		}
+
+		introChan, introPolicy = channel, policy
+	}
+	if introChan == nil {
+		return nil, ErrNoIntroductionNode
+	}
+
+	selfNode, err := cfg.Graph.SourceNode()
+	if err != nil {
+		return nil, err
+	}
+	selfPub, err := selfNode.PubKey()
+	if err != nil {
+		return nil, err
+	}
+
+	// Payments are only expected to arrive over the path while the invoice
+	// is valid, so the path stops accepting them a while after.
+	bestHeight, err := cfg.BestHeight()
+	if err != nil {
+		return nil, err
+	}
+	expiryBlocks := uint32(expiry / (10 * time.Minute))
+	maxCltvExpiry := bestHeight + expiryBlocks + blindedPathExpiryGrace +
+		uint32(finalCltvDelta)
+
+	shortChanID := introChan.ShortChanID()
+	introData := &blindedpath.EncryptedData{
+		ShortChannelID: &shortChanID,
+		PaymentRelay: &blindedpath.PaymentRelay{
+			CltvExpiryDelta: introPolicy.TimeLockDelta,
+			FeeProportionalMillionths: uint32(
+				introPolicy.FeeProportionalMillionths,
+			),
+			FeeBaseMsat: uint32(introPolicy.FeeBaseMSat),
+		},
+		PaymentConstraints: &blindedpath.PaymentConstraints{
+			MaxCltvExpiry: maxCltvExpiry +
+				uint32(introPolicy.TimeLockDelta),
+			HtlcMinimumMsat: introPolicy.MinHTLC,
+		},
+	}
+	introPlainText, err := introData.Encode()
+	if err != nil {
+		return nil, err
+	}
+
+	finalData := &blindedpath.EncryptedData{
+		PathID: paymentAddr[:],
+		PaymentConstraints: &blindedpath.PaymentConstraints{
+			MaxCltvExpiry: maxCltvExpiry,
+		},
+	}
+	finalPlainText, err := finalData.Encode()
+	if err != nil {
+		return nil, err
+	}
+
+	sessionKey, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		return nil, err
+	}
+
+	path, err := blindedpath.BuildBlindedPath(
+		sessionKey, []*blindedpath.HopInfo{
+			{
+				NodePub:   introChan.IdentityPub,
+				PlainText: introPlainText,
+			},
+			{
+				NodePub:   selfPub,
+				PlainText: finalPlainText,
+			},
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// With only the introduction node forwarding over the path, its policy
+	// is all the sender needs to account for.
+	maxHtlc := lnwire.NewMSatFromSatoshis(introChan.Capacity)
+	if introPolicy.MessageFlags.HasMaxHtlc() {
+		maxHtlc = introPolicy.MaxHTLC
+	}
+	payInfo := &blindedpath.PayInfo{
+		FeeBaseMsat: uint32(introPolicy.FeeBaseMSat),
+		FeeProportionalMillionths: uint32(
+			introPolicy.FeeProportionalMillionths,
+		),
+		CltvExpiryDelta: introPolicy.TimeLockDelta,
+		HtlcMinimumMsat: introPolicy.MinHTLC,
+		HtlcMaximumMsat: maxHtlc,
+		Features:        lnwire.NewRawFeatureVector(),
+	}
+
+	return &blindedpath.PaymentPath{
+		PayInfo: payInfo,
+		Path:    path,
+	}, nil
+}
+
+// ephemeralSigner returns a signer for invoices backed by a freshly generated
+// key, which is used to sign invoices that shouldn't reveal our node.
+func ephemeralSigner() (zpay32.MessageSigner, error) {
+	key, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		return zpay32.MessageSigner{}, err
+	}
+
+	return zpay32.MessageSigner{
+		SignCompact: func(msg []byte) ([]byte, error) {
+			hash := chainhash.HashB(msg)
+			return btcec.SignCompact(btcec.S256(), key, hash, true)
+		},
+	}, nil
+}
+
 // addHopHint creates a hop hint out of the passed channel and channel policy.
 // The new hop hint is appended to the passed slice.
 func addHopHint(hopHints *[]func(*zpay32.Invoice),
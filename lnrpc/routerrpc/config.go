@@ -2,6 +2,7 @@ package routerrpc
 
 import (
 	"github.com/brsuite/broln/macaroons"
+	"github.com/brsuite/broln/offers"
 	"github.com/brsuite/broln/routing"
 )
 
@@ -37,19 +38,35 @@ type Config struct {
 	// RouterBackend contains shared logic between this sub server and the
 	// main rpc server.
 	RouterBackend *RouterBackend
+
+	// OffersManager creates BOLT 12 offers and fetches invoices for them.
+	// It is nil if offers aren't enabled.
+	OffersManager *offers.Manager
+
+	// Prober probes destinations to estimate the amount that can be sent
+	// to them.
+	Prober *routing.Prober
 }
 
 // DefaultConfig defines the config defaults.
 func DefaultConfig() *Config {
 	defaultRoutingConfig := RoutingConfig{
-		AprioriHopProbability: routing.DefaultAprioriHopProbability,
-		AprioriWeight:         routing.DefaultAprioriWeight,
-		MinRouteProbability:   routing.DefaultMinRouteProbability,
-		PenaltyHalfLife:       routing.DefaultPenaltyHalfLife,
-		AttemptCost:           routing.DefaultAttemptCost.ToSatoshis(),
-		AttemptCostPPM:        routing.DefaultAttemptCostPPM,
-		MaxMcHistory:          routing.DefaultMaxMcHistory,
-		McFlushInterval:       routing.DefaultMcFlushInterval,
+		ProbabilityEstimatorType: routing.DefaultEstimator,
+		AprioriHopProbability:    routing.DefaultAprioriHopProbability,
+		AprioriWeight:            routing.DefaultAprioriWeight,
+		MinRouteProbability:      routing.DefaultMinRouteProbability,
+		PenaltyHalfLife:          routing.DefaultPenaltyHalfLife,
+		AttemptCost:              routing.DefaultAttemptCost.ToSatoshis(),
+		AttemptCostPPM:           routing.DefaultAttemptCostPPM,
+		MaxMcHistory:             routing.DefaultMaxMcHistory,
+		McFlushInterval:          routing.DefaultMcFlushInterval,
+		McImportStrategy:         routing.MergeKeepNewer.String(),
+		MaxProbeConcurrency:      routing.DefaultMaxProbeConcurrency,
+		BimodalConfig: &BimodalConfig{
+			Scale:      int64(routing.DefaultBimodalScaleMsat),
+			NodeWeight: routing.DefaultBimodalNodeWeight,
+			DecayTime:  routing.DefaultBimodalDecayTime,
+		},
 	}
 
 	return &Config{
@@ -60,13 +77,23 @@ func DefaultConfig() *Config {
 // GetRoutingConfig returns the routing config based on this sub server config.
 func GetRoutingConfig(cfg *Config) *RoutingConfig {
 	return &RoutingConfig{
-		AprioriHopProbability: cfg.AprioriHopProbability,
-		AprioriWeight:         cfg.AprioriWeight,
-		MinRouteProbability:   cfg.MinRouteProbability,
-		AttemptCost:           cfg.AttemptCost,
-		AttemptCostPPM:        cfg.AttemptCostPPM,
-		PenaltyHalfLife:       cfg.PenaltyHalfLife,
-		MaxMcHistory:          cfg.MaxMcHistory,
-		McFlushInterval:       cfg.McFlushInterval,
+		ProbabilityEstimatorType: cfg.ProbabilityEstimatorType,
+		AprioriHopProbability:    cfg.AprioriHopProbability,
+		AprioriWeight:            cfg.AprioriWeight,
+		MinRouteProbability:      cfg.MinRouteProbability,
+		AttemptCost:              cfg.AttemptCost,
+		AttemptCostPPM:           cfg.AttemptCostPPM,
+		PenaltyHalfLife:          cfg.PenaltyHalfLife,
+		MaxMcHistory:             cfg.MaxMcHistory,
+		McFlushInterval:          cfg.McFlushInterval,
+		McImportFile:             cfg.McImportFile,
+		McImportStrategy:         cfg.McImportStrategy,
+		MaxProbeConcurrency:      cfg.MaxProbeConcurrency,
+		NodeLocationFile:         cfg.NodeLocationFile,
+		BimodalConfig: &BimodalConfig{
+			Scale:      cfg.BimodalConfig.Scale,
+			NodeWeight: cfg.BimodalConfig.NodeWeight,
+			DecayTime:  cfg.BimodalConfig.DecayTime,
+		},
 	}
 }
@@ -188,11 +188,31 @@ func (r *forwardInterceptor) resolveFromClient(
 	if !ok {
 		return ErrFwdNotExists
 	}
+
 	delete(r.holdForwards, circuitKey)
 
 	switch in.Action {
 	case ResolveHoldForwardAction_RESUME:
 		return interceptedForward.Resume()
+	case ResolveHoldForwardAction_RESUME_MODIFIED:
+		err := interceptedForward.ResumeModified(
+			lnwire.NewShortChanIDFromInt(in.OutgoingChanId),
+			lnwire.MilliSatoshi(in.OutAmountMsat),
+			in.OutWireCustomRecords,
+		)
+		if err == nil {
+			return nil
+		}
+
+		// The client has no way of learning that its modification was
+		// refused, so rather than holding the htlc until it expires,
+		// we fail it backwards.
+		if failErr := interceptedForward.Fail(); failErr != nil {
+			return fmt.Errorf("unable to fail htlc after refused "+
+				"modification (%v): %v", err, failErr)
+		}
+		return fmt.Errorf("modification refused, htlc failed: %v",
+			err)
 	case ResolveHoldForwardAction_FAIL:
 		return interceptedForward.Fail()
 	case ResolveHoldForwardAction_SETTLE:
@@ -209,18 +229,16 @@ func (r *forwardInterceptor) resolveFromClient(
 	}
 }
 
-// onDisconnect removes all previousely held forwards from
-// the store. Before they are removed it ensure to resume as the default
-// behavior.
+// onDisconnect removes all previousely held forwards from the store. The
+// switch takes care of the forwards once the interceptor is unset, either
+// resuming them as the default behavior or holding them until another
+// interceptor connects.
 func (r *forwardInterceptor) onDisconnect() {
 	// Then close the channel so all go routine will exit.
 	close(r.quit)
 
-	log.Infof("RPC interceptor disconnected, resolving held packets")
-	for key, forward := range r.holdForwards {
-		if err := forward.Resume(); err != nil {
-			log.Errorf("failed to resume hold forward %v", err)
-		}
+	log.Infof("RPC interceptor disconnected, releasing held packets")
+	for key := range r.holdForwards {
 		delete(r.holdForwards, key)
 	}
 	r.wg.Wait()
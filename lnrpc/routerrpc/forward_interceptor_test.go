@@ -0,0 +1,111 @@
+package routerrpc
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/brsuite/broln/channeldb"
+	"github.com/brsuite/broln/htlcswitch"
+	"github.com/brsuite/broln/lntypes"
+	"github.com/brsuite/broln/lnwire"
+	"github.com/stretchr/testify/require"
+)
+
+// mockForward is a mock implementation of htlcswitch.InterceptedForward
+// that records how it was resolved.
+type mockForward struct {
+	resumeModifiedErr error
+
+	resumedModified bool
+	failed          bool
+}
+
+func (m *mockForward) Packet() htlcswitch.InterceptedPacket {
+	return htlcswitch.InterceptedPacket{}
+}
+
+func (m *mockForward) Resume() error {
+	return nil
+}
+
+func (m *mockForward) ResumeModified(lnwire.ShortChannelID,
+	lnwire.MilliSatoshi, lnwire.CustomRecords) error {
+
+	if m.resumeModifiedErr != nil {
+		return m.resumeModifiedErr
+	}
+
+	m.resumedModified = true
+	return nil
+}
+
+func (m *mockForward) Settle(lntypes.Preimage) error {
+	return nil
+}
+
+func (m *mockForward) Fail() error {
+	m.failed = true
+	return nil
+}
+
+// TestResolveModifiedFromClient asserts that a modified resume of a held
+// forward resumes it, and that the forward is failed backwards if the
+// modification is refused.
+func TestResolveModifiedFromClient(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name              string
+		resumeModifiedErr error
+	}{
+		{
+			name: "modification accepted",
+		},
+		{
+			name:              "modification refused",
+			resumeModifiedErr: errors.New("unknown outgoing link"),
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			circuitKey := channeldb.CircuitKey{
+				ChanID: lnwire.NewShortChanIDFromInt(1),
+				HtlcID: 2,
+			}
+			forward := &mockForward{
+				resumeModifiedErr: testCase.resumeModifiedErr,
+			}
+			interceptor := newForwardInterceptor(nil, nil)
+			interceptor.holdForwards[circuitKey] = forward
+
+			action := ResolveHoldForwardAction_RESUME_MODIFIED
+			err := interceptor.resolveFromClient(
+				&ForwardHtlcInterceptResponse{
+					IncomingCircuitKey: &CircuitKey{
+						ChanId: 1,
+						HtlcId: 2,
+					},
+					Action:         action,
+					OutgoingChanId: 3,
+				},
+			)
+
+			refused := testCase.resumeModifiedErr != nil
+			if refused {
+				require.ErrorContains(
+					t, err, "modification refused",
+				)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, !refused, forward.resumedModified)
+			require.Equal(t, refused, forward.failed)
+			require.Empty(t, interceptor.holdForwards)
+		})
+	}
+}
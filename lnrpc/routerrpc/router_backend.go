@@ -21,6 +21,7 @@ import (
 	"github.com/brsuite/broln/lnwire"
 	"github.com/brsuite/broln/record"
 	"github.com/brsuite/broln/routing"
+	"github.com/brsuite/broln/routing/blindedpath"
 	"github.com/brsuite/broln/routing/route"
 	"github.com/brsuite/broln/subscribe"
 	"github.com/brsuite/broln/zpay32"
@@ -102,7 +103,7 @@ type MissionControl interface {
 	// GetProbability is expected to return the success probability of a
 	// payment from fromNode to toNode.
 	GetProbability(fromNode, toNode route.Vertex,
-		amt lnwire.MilliSatoshi) float64
+		amt lnwire.MilliSatoshi, capacity bronutil.Amount) float64
 
 	// ResetHistory resets the history of MissionControl returning it to a
 	// state as if no payment attempts have been made.
@@ -117,6 +118,13 @@ type MissionControl interface {
 	// persisted across restarts.
 	ImportHistory(snapshot *routing.MissionControlSnapshot, force bool) error
 
+	// MergeHistory merges the mission control snapshot into our internal
+	// state using the given merge strategy, and returns the number of
+	// pairs whose history changed. Unlike imports, merged results are
+	// persisted and survive restarts.
+	MergeHistory(snapshot *routing.MissionControlSnapshot,
+		strategy routing.MergeStrategy) (int, error)
+
 	// GetPairHistorySnapshot returns the stored history for a given node
 	// pair.
 	GetPairHistorySnapshot(fromNode,
@@ -257,7 +265,8 @@ func (r *RouterBackend) QueryRoutes(ctx context.Context,
 	restrictions := &routing.RestrictParams{
 		FeeLimit: feeLimit,
 		ProbabilitySource: func(fromNode, toNode route.Vertex,
-			amt lnwire.MilliSatoshi) float64 {
+			amt lnwire.MilliSatoshi,
+			capacity bronutil.Amount) float64 {
 
 			if _, ok := ignoredNodes[fromNode]; ok {
 				return 0
@@ -276,7 +285,7 @@ func (r *RouterBackend) QueryRoutes(ctx context.Context,
 			}
 
 			return r.MissionControl.GetProbability(
-				fromNode, toNode, amt,
+				fromNode, toNode, amt, capacity,
 			)
 		},
 		DestCustomRecords: record.CustomSet(in.DestCustomRecords),
@@ -360,8 +369,16 @@ func (r *RouterBackend) getSuccessProbability(rt *route.Route) float64 {
 	for _, hop := range rt.Hops {
 		toNode := hop.PubKeyBytes
 
+		// If the channel isn't known, for example because it is a
+		// private channel from a route hint, its capacity is left
+		// unknown.
+		capacity, err := r.FetchChannelCapacity(hop.ChannelID)
+		if err != nil {
+			capacity = 0
+		}
+
 		probability := r.MissionControl.GetProbability(
-			fromNode, toNode, amtToFwd,
+			fromNode, toNode, amtToFwd, capacity,
 		)
 
 		successProb *= probability
@@ -585,6 +602,47 @@ func (r *RouterBackend) extractIntentFromSendRequest(
 		payIntent.LastHop = &lastHop
 	}
 
+	// Pass along the first hop restriction and node exclusions, which
+	// apply to every attempt of the payment.
+	for _, firstHopPubKey := range rpcPayReq.FirstHopPubkeys {
+		firstHop, err := route.NewVertexFromBytes(firstHopPubKey)
+		if err != nil {
+			return nil, err
+		}
+		payIntent.FirstHops = append(payIntent.FirstHops, firstHop)
+	}
+
+	for _, excludedPubKey := range rpcPayReq.ExcludedNodes {
+		excluded, err := route.NewVertexFromBytes(excludedPubKey)
+		if err != nil {
+			return nil, err
+		}
+		payIntent.ExcludedNodes = append(
+			payIntent.ExcludedNodes, excluded,
+		)
+	}
+
+	for _, country := range rpcPayReq.ExcludedCountries {
+		if len(country) != 2 {
+			return nil, fmt.Errorf("invalid country code %q, "+
+				"expected ISO 3166-1 alpha-2", country)
+		}
+	}
+	payIntent.ExcludedCountries = rpcPayReq.ExcludedCountries
+	payIntent.ExcludedASNs = rpcPayReq.ExcludedAsns
+
+	// Excluding unlocated nodes only has an effect when excluding nodes by
+	// their location.
+	if rpcPayReq.ExcludeUnlocatedNodes &&
+		len(rpcPayReq.ExcludedCountries) == 0 &&
+		len(rpcPayReq.ExcludedAsns) == 0 {
+
+		return nil, errors.New("exclude_unlocated_nodes requires " +
+			"excluded_countries or excluded_asns to be set")
+	}
+	payIntent.ExcludeUnlocatedNodes = rpcPayReq.ExcludeUnlocatedNodes
+	payIntent.MaxHops = rpcPayReq.MaxHops
+
 	// Take the CLTV limit from the request if set, otherwise use the max.
 	cltvLimit, err := ValidateCLTVLimit(
 		uint32(rpcPayReq.CltvLimit), r.MaxTotalTimelock,
@@ -618,6 +676,12 @@ func (r *RouterBackend) extractIntentFromSendRequest(
 	if err != nil {
 		return nil, err
 	}
+	if rpcPayReq.FeeLimitPpm > routing.MaxFeeLimitPPM {
+		return nil, fmt.Errorf("fee_limit_ppm must not exceed %v",
+			routing.MaxFeeLimitPPM)
+	}
+	payIntent.FeeLimitPPM = rpcPayReq.FeeLimitPpm
+	payIntent.MaxHopFee = lnwire.MilliSatoshi(rpcPayReq.MaxHopFeeMsat)
 
 	// Set payment attempt timeout.
 	if rpcPayReq.TimeoutSeconds == 0 {
@@ -752,6 +816,28 @@ func (r *RouterBackend) extractIntentFromSendRequest(
 		destKey := payReq.Destination.SerializeCompressed()
 		copy(payIntent.Target[:], destKey)
 
+		// The destination of invoices with blinded paths is just an
+		// ephemeral key, so we pay to the introduction node of one of
+		// the paths instead, and let it take us the rest of the way.
+		if len(payReq.BlindedPaymentPaths) > 0 {
+			if payReq.Features.HasFeature(lnwire.AMPOptional) {
+				return nil, errors.New("AMP payments over " +
+					"blinded paths are not supported")
+			}
+
+			path, err := SelectBlindedPath(
+				payReq.BlindedPaymentPaths, payIntent.Amount,
+			)
+			if err != nil {
+				return nil, err
+			}
+
+			payIntent.Target = route.NewVertex(
+				path.Path.IntroductionPoint,
+			)
+			payIntent.BlindedPath = path
+		}
+
 		payIntent.FinalCLTVDelta = uint16(payReq.MinFinalCLTVExpiry())
 		payIntent.RouteHints = append(
 			payIntent.RouteHints, payReq.RouteHints...,
@@ -897,6 +983,37 @@ func (r *RouterBackend) extractIntentFromSendRequest(
 	return payIntent, nil
 }
 
+// SelectBlindedPath picks the blinded path that is the cheapest to pay the
+// passed amount over, out of those that accept it.
+func SelectBlindedPath(paths []*blindedpath.PaymentPath,
+	amt lnwire.MilliSatoshi) (*blindedpath.PaymentPath, error) {
+
+	var (
+		bestPath *blindedpath.PaymentPath
+		bestFee  lnwire.MilliSatoshi
+	)
+	for _, path := range paths {
+		info := path.PayInfo
+		if amt < info.HtlcMinimumMsat || amt > info.HtlcMaximumMsat {
+			continue
+		}
+
+		fee := info.Fee(amt)
+		if bestPath != nil && fee >= bestFee {
+			continue
+		}
+
+		bestPath, bestFee = path, fee
+	}
+
+	if bestPath == nil {
+		return nil, fmt.Errorf("no blinded path accepts payments of %v",
+			amt)
+	}
+
+	return bestPath, nil
+}
+
 // unmarshallRouteHints unmarshalls a list of route hints.
 func unmarshallRouteHints(rpcRouteHints []*lnrpc.RouteHint) (
 	[][]zpay32.HopHint, error) {
@@ -58,7 +58,8 @@ type RouterClient interface {
 	//XImportMissionControl is an experimental API that imports the state provided
 	//to the internal mission control's state, using all results which are more
 	//recent than our existing values. These values will only be imported
-	//in-memory, and will not be persisted across restarts.
+	//in-memory, and will not be persisted across restarts. Results merged with
+	//a merge_strategy set are persisted and survive restarts.
 	XImportMissionControl(ctx context.Context, in *XImportMissionControlRequest, opts ...grpc.CallOption) (*XImportMissionControlResponse, error)
 	//
 	//GetMissionControlConfig returns mission control's current config.
@@ -104,6 +105,37 @@ type RouterClient interface {
 	//channel to stay disabled until a subsequent manual request of either
 	//"enable" or "auto".
 	UpdateChanStatus(ctx context.Context, in *UpdateChanStatusRequest, opts ...grpc.CallOption) (*UpdateChanStatusResponse, error)
+	//
+	//CreateOffer creates a BOLT 12 offer, a static payment code payers can use
+	//to request invoices from us over onion messages. Offers aren't stored, so
+	//they stay valid until they expire.
+	CreateOffer(ctx context.Context, in *CreateOfferRequest, opts ...grpc.CallOption) (*CreateOfferResponse, error)
+	//
+	//DecodeOffer decodes a BOLT 12 offer.
+	DecodeOffer(ctx context.Context, in *DecodeOfferRequest, opts ...grpc.CallOption) (*DecodeOfferResponse, error)
+	//
+	//PayOffer requests an invoice for a BOLT 12 offer from its issuer over onion
+	//messages and pays it. The call returns a stream of payment updates.
+	PayOffer(ctx context.Context, in *PayOfferRequest, opts ...grpc.CallOption) (Router_PayOfferClient, error)
+	//
+	//ProbeDestinations estimates the amount that can currently be sent to each
+	//of a list of destinations. Every destination is probed with the given
+	//amounts in ascending order, using payments with a random payment hash that
+	//the destination can't settle. The results of all probes feed into mission
+	//control. The call blocks until all probes have completed.
+	ProbeDestinations(ctx context.Context, in *ProbeDestinationsRequest, opts ...grpc.CallOption) (*ProbeDestinationsResponse, error)
+	//
+	//AddProbeSchedule adds a schedule that repeats a ProbeDestinations request
+	//at a fixed interval in the background. Schedules aren't persisted and are
+	//removed when broln shuts down.
+	AddProbeSchedule(ctx context.Context, in *AddProbeScheduleRequest, opts ...grpc.CallOption) (*AddProbeScheduleResponse, error)
+	//
+	//ListProbeSchedules returns all probe schedules together with the results
+	//of their last run.
+	ListProbeSchedules(ctx context.Context, in *ListProbeSchedulesRequest, opts ...grpc.CallOption) (*ListProbeSchedulesResponse, error)
+	//
+	//RemoveProbeSchedule stops and removes a probe schedule.
+	RemoveProbeSchedule(ctx context.Context, in *RemoveProbeScheduleRequest, opts ...grpc.CallOption) (*RemoveProbeScheduleResponse, error)
 }
 
 type routerClient struct {
@@ -407,6 +439,92 @@ func (c *routerClient) UpdateChanStatus(ctx context.Context, in *UpdateChanStatu
 	return out, nil
 }
 
+func (c *routerClient) CreateOffer(ctx context.Context, in *CreateOfferRequest, opts ...grpc.CallOption) (*CreateOfferResponse, error) {
+	out := new(CreateOfferResponse)
+	err := c.cc.Invoke(ctx, "/routerrpc.Router/CreateOffer", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *routerClient) DecodeOffer(ctx context.Context, in *DecodeOfferRequest, opts ...grpc.CallOption) (*DecodeOfferResponse, error) {
+	out := new(DecodeOfferResponse)
+	err := c.cc.Invoke(ctx, "/routerrpc.Router/DecodeOffer", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *routerClient) PayOffer(ctx context.Context, in *PayOfferRequest, opts ...grpc.CallOption) (Router_PayOfferClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Router_ServiceDesc.Streams[6], "/routerrpc.Router/PayOffer", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &routerPayOfferClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Router_PayOfferClient interface {
+	Recv() (*lnrpc.Payment, error)
+	grpc.ClientStream
+}
+
+type routerPayOfferClient struct {
+	grpc.ClientStream
+}
+
+func (x *routerPayOfferClient) Recv() (*lnrpc.Payment, error) {
+	m := new(lnrpc.Payment)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *routerClient) ProbeDestinations(ctx context.Context, in *ProbeDestinationsRequest, opts ...grpc.CallOption) (*ProbeDestinationsResponse, error) {
+	out := new(ProbeDestinationsResponse)
+	err := c.cc.Invoke(ctx, "/routerrpc.Router/ProbeDestinations", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *routerClient) AddProbeSchedule(ctx context.Context, in *AddProbeScheduleRequest, opts ...grpc.CallOption) (*AddProbeScheduleResponse, error) {
+	out := new(AddProbeScheduleResponse)
+	err := c.cc.Invoke(ctx, "/routerrpc.Router/AddProbeSchedule", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *routerClient) ListProbeSchedules(ctx context.Context, in *ListProbeSchedulesRequest, opts ...grpc.CallOption) (*ListProbeSchedulesResponse, error) {
+	out := new(ListProbeSchedulesResponse)
+	err := c.cc.Invoke(ctx, "/routerrpc.Router/ListProbeSchedules", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *routerClient) RemoveProbeSchedule(ctx context.Context, in *RemoveProbeScheduleRequest, opts ...grpc.CallOption) (*RemoveProbeScheduleResponse, error) {
+	out := new(RemoveProbeScheduleResponse)
+	err := c.cc.Invoke(ctx, "/routerrpc.Router/RemoveProbeSchedule", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // RouterServer is the server API for Router service.
 // All implementations must embed UnimplementedRouterServer
 // for forward compatibility
@@ -450,7 +568,8 @@ type RouterServer interface {
 	//XImportMissionControl is an experimental API that imports the state provided
 	//to the internal mission control's state, using all results which are more
 	//recent than our existing values. These values will only be imported
-	//in-memory, and will not be persisted across restarts.
+	//in-memory, and will not be persisted across restarts. Results merged with
+	//a merge_strategy set are persisted and survive restarts.
 	XImportMissionControl(context.Context, *XImportMissionControlRequest) (*XImportMissionControlResponse, error)
 	//
 	//GetMissionControlConfig returns mission control's current config.
@@ -496,6 +615,37 @@ type RouterServer interface {
 	//channel to stay disabled until a subsequent manual request of either
 	//"enable" or "auto".
 	UpdateChanStatus(context.Context, *UpdateChanStatusRequest) (*UpdateChanStatusResponse, error)
+	//
+	//CreateOffer creates a BOLT 12 offer, a static payment code payers can use
+	//to request invoices from us over onion messages. Offers aren't stored, so
+	//they stay valid until they expire.
+	CreateOffer(context.Context, *CreateOfferRequest) (*CreateOfferResponse, error)
+	//
+	//DecodeOffer decodes a BOLT 12 offer.
+	DecodeOffer(context.Context, *DecodeOfferRequest) (*DecodeOfferResponse, error)
+	//
+	//PayOffer requests an invoice for a BOLT 12 offer from its issuer over onion
+	//messages and pays it. The call returns a stream of payment updates.
+	PayOffer(*PayOfferRequest, Router_PayOfferServer) error
+	//
+	//ProbeDestinations estimates the amount that can currently be sent to each
+	//of a list of destinations. Every destination is probed with the given
+	//amounts in ascending order, using payments with a random payment hash that
+	//the destination can't settle. The results of all probes feed into mission
+	//control. The call blocks until all probes have completed.
+	ProbeDestinations(context.Context, *ProbeDestinationsRequest) (*ProbeDestinationsResponse, error)
+	//
+	//AddProbeSchedule adds a schedule that repeats a ProbeDestinations request
+	//at a fixed interval in the background. Schedules aren't persisted and are
+	//removed when broln shuts down.
+	AddProbeSchedule(context.Context, *AddProbeScheduleRequest) (*AddProbeScheduleResponse, error)
+	//
+	//ListProbeSchedules returns all probe schedules together with the results
+	//of their last run.
+	ListProbeSchedules(context.Context, *ListProbeSchedulesRequest) (*ListProbeSchedulesResponse, error)
+	//
+	//RemoveProbeSchedule stops and removes a probe schedule.
+	RemoveProbeSchedule(context.Context, *RemoveProbeScheduleRequest) (*RemoveProbeScheduleResponse, error)
 	mustEmbedUnimplementedRouterServer()
 }
 
@@ -554,6 +704,27 @@ func (UnimplementedRouterServer) HtlcInterceptor(Router_HtlcInterceptorServer) e
 func (UnimplementedRouterServer) UpdateChanStatus(context.Context, *UpdateChanStatusRequest) (*UpdateChanStatusResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method UpdateChanStatus not implemented")
 }
+func (UnimplementedRouterServer) CreateOffer(context.Context, *CreateOfferRequest) (*CreateOfferResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateOffer not implemented")
+}
+func (UnimplementedRouterServer) DecodeOffer(context.Context, *DecodeOfferRequest) (*DecodeOfferResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DecodeOffer not implemented")
+}
+func (UnimplementedRouterServer) PayOffer(*PayOfferRequest, Router_PayOfferServer) error {
+	return status.Errorf(codes.Unimplemented, "method PayOffer not implemented")
+}
+func (UnimplementedRouterServer) ProbeDestinations(context.Context, *ProbeDestinationsRequest) (*ProbeDestinationsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ProbeDestinations not implemented")
+}
+func (UnimplementedRouterServer) AddProbeSchedule(context.Context, *AddProbeScheduleRequest) (*AddProbeScheduleResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddProbeSchedule not implemented")
+}
+func (UnimplementedRouterServer) ListProbeSchedules(context.Context, *ListProbeSchedulesRequest) (*ListProbeSchedulesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListProbeSchedules not implemented")
+}
+func (UnimplementedRouterServer) RemoveProbeSchedule(context.Context, *RemoveProbeScheduleRequest) (*RemoveProbeScheduleResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RemoveProbeSchedule not implemented")
+}
 func (UnimplementedRouterServer) mustEmbedUnimplementedRouterServer() {}
 
 // UnsafeRouterServer may be embedded to opt out of forward compatibility for this service.
@@ -896,6 +1067,135 @@ func _Router_UpdateChanStatus_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Router_CreateOffer_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateOfferRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RouterServer).CreateOffer(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/routerrpc.Router/CreateOffer",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RouterServer).CreateOffer(ctx, req.(*CreateOfferRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Router_DecodeOffer_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DecodeOfferRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RouterServer).DecodeOffer(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/routerrpc.Router/DecodeOffer",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RouterServer).DecodeOffer(ctx, req.(*DecodeOfferRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Router_PayOffer_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(PayOfferRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(RouterServer).PayOffer(m, &routerPayOfferServer{stream})
+}
+
+type Router_PayOfferServer interface {
+	Send(*lnrpc.Payment) error
+	grpc.ServerStream
+}
+
+type routerPayOfferServer struct {
+	grpc.ServerStream
+}
+
+func (x *routerPayOfferServer) Send(m *lnrpc.Payment) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Router_ProbeDestinations_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ProbeDestinationsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RouterServer).ProbeDestinations(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/routerrpc.Router/ProbeDestinations",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RouterServer).ProbeDestinations(ctx, req.(*ProbeDestinationsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Router_AddProbeSchedule_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddProbeScheduleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RouterServer).AddProbeSchedule(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/routerrpc.Router/AddProbeSchedule",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RouterServer).AddProbeSchedule(ctx, req.(*AddProbeScheduleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Router_ListProbeSchedules_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListProbeSchedulesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RouterServer).ListProbeSchedules(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/routerrpc.Router/ListProbeSchedules",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RouterServer).ListProbeSchedules(ctx, req.(*ListProbeSchedulesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Router_RemoveProbeSchedule_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RemoveProbeScheduleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RouterServer).RemoveProbeSchedule(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/routerrpc.Router/RemoveProbeSchedule",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RouterServer).RemoveProbeSchedule(ctx, req.(*RemoveProbeScheduleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Router_ServiceDesc is the grpc.ServiceDesc for Router service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -947,6 +1247,30 @@ var Router_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "UpdateChanStatus",
 			Handler:    _Router_UpdateChanStatus_Handler,
 		},
+		{
+			MethodName: "CreateOffer",
+			Handler:    _Router_CreateOffer_Handler,
+		},
+		{
+			MethodName: "DecodeOffer",
+			Handler:    _Router_DecodeOffer_Handler,
+		},
+		{
+			MethodName: "ProbeDestinations",
+			Handler:    _Router_ProbeDestinations_Handler,
+		},
+		{
+			MethodName: "AddProbeSchedule",
+			Handler:    _Router_AddProbeSchedule_Handler,
+		},
+		{
+			MethodName: "ListProbeSchedules",
+			Handler:    _Router_ListProbeSchedules_Handler,
+		},
+		{
+			MethodName: "RemoveProbeSchedule",
+			Handler:    _Router_RemoveProbeSchedule_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -980,6 +1304,11 @@ var Router_ServiceDesc = grpc.ServiceDesc{
 			ServerStreams: true,
 			ClientStreams: true,
 		},
+		{
+			StreamName:    "PayOffer",
+			Handler:       _Router_PayOffer_Handler,
+			ServerStreams: true,
+		},
 	},
 	Metadata: "routerrpc/router.proto",
 }
@@ -18,7 +18,9 @@ import (
 	"github.com/brsuite/broln/lntypes"
 	"github.com/brsuite/broln/lnwire"
 	"github.com/brsuite/broln/macaroons"
+	"github.com/brsuite/broln/offers"
 	"github.com/brsuite/broln/routing"
+	"github.com/brsuite/broln/routing/blindedpath"
 	"github.com/brsuite/broln/routing/route"
 
 	"google.golang.org/grpc"
@@ -34,9 +36,20 @@ const (
 	subServerName = "RouterRPC"
 )
 
+const (
+	// defaultOfferFetchTimeout is the time we wait for the issuer of an
+	// offer to reply with an invoice if the caller doesn't specify one.
+	defaultOfferFetchTimeout = time.Minute
+)
+
 var (
 	errServerShuttingDown = errors.New("routerrpc server shutting down")
 
+	// errOffersDisabled is returned by the offer RPCs if offers aren't
+	// enabled.
+	errOffersDisabled = errors.New("offers not enabled, enable them " +
+		"with --protocol.offers")
+
 	// ErrInterceptorAlreadyExists is an error returned when the a new stream
 	// is opened and there is already one active interceptor.
 	// The user must disconnect prior to open another stream.
@@ -125,6 +138,34 @@ var (
 			Entity: "offchain",
 			Action: "write",
 		}},
+		"/routerrpc.Router/CreateOffer": {{
+			Entity: "invoices",
+			Action: "write",
+		}},
+		"/routerrpc.Router/DecodeOffer": {{
+			Entity: "offchain",
+			Action: "read",
+		}},
+		"/routerrpc.Router/PayOffer": {{
+			Entity: "offchain",
+			Action: "write",
+		}},
+		"/routerrpc.Router/ProbeDestinations": {{
+			Entity: "offchain",
+			Action: "write",
+		}},
+		"/routerrpc.Router/AddProbeSchedule": {{
+			Entity: "offchain",
+			Action: "write",
+		}},
+		"/routerrpc.Router/ListProbeSchedules": {{
+			Entity: "offchain",
+			Action: "read",
+		}},
+		"/routerrpc.Router/RemoveProbeSchedule": {{
+			Entity: "offchain",
+			Action: "write",
+		}},
 	}
 
 	// DefaultRouterMacFilename is the default name of the router macaroon
@@ -445,39 +486,134 @@ func (s *Server) GetMissionControlConfig(ctx context.Context,
 	req *GetMissionControlConfigRequest) (*GetMissionControlConfigResponse,
 	error) {
 
+	// Query the current mission control config.
 	cfg := s.cfg.RouterBackend.MissionControl.GetConfig()
-	return &GetMissionControlConfigResponse{
+	resp := &GetMissionControlConfigResponse{
 		Config: &MissionControlConfig{
-			HalfLifeSeconds:             uint64(cfg.PenaltyHalfLife.Seconds()),
-			HopProbability:              float32(cfg.AprioriHopProbability),
-			Weight:                      float32(cfg.AprioriWeight),
 			MaximumPaymentResults:       uint32(cfg.MaxMcHistory),
 			MinimumFailureRelaxInterval: uint64(cfg.MinFailureRelaxInterval.Seconds()),
 		},
-	}, nil
+	}
+
+	// We only populate fields based on the current estimator.
+	switch v := cfg.Estimator.Config().(type) {
+	case routing.AprioriConfig:
+		resp.Config.Model = MissionControlConfig_APRIORI
+		aCfg := AprioriParameters{
+			HalfLifeSeconds: uint64(v.PenaltyHalfLife.Seconds()),
+			HopProbability:  v.AprioriHopProbability,
+			Weight:          v.AprioriWeight,
+		}
+
+		// Populate deprecated fields.
+		resp.Config.HalfLifeSeconds = uint64(
+			v.PenaltyHalfLife.Seconds(),
+		)
+		resp.Config.HopProbability = float32(v.AprioriHopProbability)
+		resp.Config.Weight = float32(v.AprioriWeight)
+
+		resp.Config.EstimatorConfig = &MissionControlConfig_Apriori{
+			Apriori: &aCfg,
+		}
+
+	case routing.BimodalConfig:
+		resp.Config.Model = MissionControlConfig_BIMODAL
+		bCfg := BimodalParameters{
+			NodeWeight: v.BimodalNodeWeight,
+			ScaleMsat:  uint64(v.BimodalScaleMsat),
+			DecayTime:  uint64(v.BimodalDecayTime.Seconds()),
+		}
+
+		resp.Config.EstimatorConfig = &MissionControlConfig_Bimodal{
+			Bimodal: &bCfg,
+		}
+
+	default:
+		return nil, fmt.Errorf("unknown estimator config type %T", v)
+	}
+
+	return resp, nil
 }
 
-// SetMissionControlConfig returns our current mission control config.
+// SetMissionControlConfig sets parameters in the mission control config.
 func (s *Server) SetMissionControlConfig(ctx context.Context,
 	req *SetMissionControlConfigRequest) (*SetMissionControlConfigResponse,
 	error) {
 
-	cfg := &routing.MissionControlConfig{
-		ProbabilityEstimatorCfg: routing.ProbabilityEstimatorCfg{
-			PenaltyHalfLife: time.Duration(
-				req.Config.HalfLifeSeconds,
-			) * time.Second,
-			AprioriHopProbability: float64(req.Config.HopProbability),
-			AprioriWeight:         float64(req.Config.Weight),
-		},
+	if req.Config == nil {
+		return nil, errors.New("mission control config required")
+	}
+
+	mcCfg := &routing.MissionControlConfig{
 		MaxMcHistory: int(req.Config.MaximumPaymentResults),
 		MinFailureRelaxInterval: time.Duration(
 			req.Config.MinimumFailureRelaxInterval,
 		) * time.Second,
 	}
 
+	switch req.Config.Model {
+	case MissionControlConfig_APRIORI:
+		var aCfg routing.AprioriConfig
+
+		// Determine the parameters either from the new oneof field or
+		// from the deprecated fields, which older clients still set.
+		if req.Config.GetApriori() != nil {
+			params := req.Config.GetApriori()
+			aCfg = routing.AprioriConfig{
+				PenaltyHalfLife: time.Duration(
+					params.HalfLifeSeconds,
+				) * time.Second,
+				AprioriHopProbability: params.HopProbability,
+				AprioriWeight:         params.Weight,
+			}
+		} else {
+			aCfg = routing.AprioriConfig{
+				PenaltyHalfLife: time.Duration(
+					req.Config.HalfLifeSeconds,
+				) * time.Second,
+				AprioriHopProbability: float64(
+					req.Config.HopProbability,
+				),
+				AprioriWeight: float64(req.Config.Weight),
+			}
+		}
+
+		estimator, err := routing.NewAprioriEstimator(aCfg)
+		if err != nil {
+			return nil, err
+		}
+		mcCfg.Estimator = estimator
+
+	case MissionControlConfig_BIMODAL:
+		params := req.Config.GetBimodal()
+		if params == nil {
+			return nil, errors.New("bimodal estimator requires " +
+				"bimodal parameters")
+		}
+
+		bCfg := routing.BimodalConfig{
+			BimodalNodeWeight: params.NodeWeight,
+			BimodalScaleMsat: lnwire.MilliSatoshi(
+				params.ScaleMsat,
+			),
+			BimodalDecayTime: time.Duration(
+				params.DecayTime,
+			) * time.Second,
+		}
+
+		estimator, err := routing.NewBimodalEstimator(bCfg)
+		if err != nil {
+			return nil, err
+		}
+		mcCfg.Estimator = estimator
+
+	default:
+		return nil, fmt.Errorf("unknown estimator type %v",
+			req.Config.Model)
+	}
+
 	return &SetMissionControlConfigResponse{},
-		s.cfg.RouterBackend.MissionControl.SetConfig(cfg)
+		s.cfg.RouterBackend.MissionControl.SetConfig(mcCfg)
 }
 
 // QueryMissionControl exposes the internal mission control state to callers. It
@@ -529,7 +665,8 @@ func toRPCPairData(data *routing.TimedPairResult) *PairData {
 }
 
 // XImportMissionControl imports the state provided to our internal mission
-// control. Only entries that are fresher than our existing state will be used.
+// control. Without a merge strategy, only entries that are fresher than our
+// existing state will be used.
 func (s *Server) XImportMissionControl(ctx context.Context,
 	req *XImportMissionControlRequest) (*XImportMissionControlResponse,
 	error) {
@@ -544,8 +681,47 @@ func (s *Server) XImportMissionControl(ctx context.Context,
 		),
 	}
 
+	var (
+		mc       = s.cfg.RouterBackend.MissionControl
+		strategy routing.MergeStrategy
+	)
+	switch req.MergeStrategy {
+	case MergeStrategy_IMPORT_DEFAULT:
+		for i, pairResult := range req.Pairs {
+			pairSnapshot, err := toPairSnapshot(pairResult, false)
+			if err != nil {
+				return nil, err
+			}
+
+			snapshot.Pairs[i] = *pairSnapshot
+		}
+
+		err := mc.ImportHistory(snapshot, req.Force)
+		if err != nil {
+			return nil, err
+		}
+
+		return &XImportMissionControlResponse{}, nil
+
+	case MergeStrategy_OVERWRITE:
+		strategy = routing.MergeOverwrite
+
+	case MergeStrategy_KEEP_NEWER:
+		strategy = routing.MergeKeepNewer
+
+	case MergeStrategy_KEEP_STRONGER_FAILURE:
+		strategy = routing.MergeKeepStrongerFailure
+
+	default:
+		return nil, fmt.Errorf("unknown merge strategy %v",
+			req.MergeStrategy)
+	}
+
+	// Merged pairs typically come from an export of another node's mission
+	// control, which may contain failures that are independent of the
+	// amount, so we allow a zero failure amount here.
 	for i, pairResult := range req.Pairs {
-		pairSnapshot, err := toPairSnapshot(pairResult)
+		pairSnapshot, err := toPairSnapshot(pairResult, true)
 		if err != nil {
 			return nil, err
 		}
@@ -553,18 +729,18 @@ func (s *Server) XImportMissionControl(ctx context.Context,
 		snapshot.Pairs[i] = *pairSnapshot
 	}
 
-	err := s.cfg.RouterBackend.MissionControl.ImportHistory(
-		snapshot, req.Force,
-	)
+	merged, err := mc.MergeHistory(snapshot, strategy)
 	if err != nil {
 		return nil, err
 	}
 
-	return &XImportMissionControlResponse{}, nil
+	return &XImportMissionControlResponse{
+		NumMergedPairs: uint64(merged),
+	}, nil
 }
 
-func toPairSnapshot(pairResult *PairHistory) (*routing.MissionControlPairSnapshot,
-	error) {
+func toPairSnapshot(pairResult *PairHistory,
+	allowZeroFailAmt bool) (*routing.MissionControlPairSnapshot, error) {
 
 	from, err := route.NewVertexFromBytes(pairResult.NodeFrom)
 	if err != nil {
@@ -586,7 +762,7 @@ func toPairSnapshot(pairResult *PairHistory) (*routing.MissionControlPairSnapsho
 	failAmt, failTime, err := getPair(
 		lnwire.MilliSatoshi(pairResult.History.FailAmtMsat),
 		bronutil.Amount(pairResult.History.FailAmtSat),
-		pairResult.History.FailTime,
+		pairResult.History.FailTime, allowZeroFailAmt,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("%v invalid failure: %v", pairPrefix,
@@ -596,14 +772,14 @@ func toPairSnapshot(pairResult *PairHistory) (*routing.MissionControlPairSnapsho
 	successAmt, successTime, err := getPair(
 		lnwire.MilliSatoshi(pairResult.History.SuccessAmtMsat),
 		bronutil.Amount(pairResult.History.SuccessAmtSat),
-		pairResult.History.SuccessTime,
+		pairResult.History.SuccessTime, false,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("%v invalid success: %v", pairPrefix,
 			err)
 	}
 
-	if successAmt == 0 && failAmt == 0 {
+	if successTime.IsZero() && failTime.IsZero() {
 		return nil, fmt.Errorf("%v: either success or failure result "+
 			"required", pairPrefix)
 	}
@@ -625,8 +801,10 @@ func toPairSnapshot(pairResult *PairHistory) (*routing.MissionControlPairSnapsho
 
 // getPair validates the values provided for a mission control result and
 // returns the msat amount and timestamp for it.
+// If allowZeroAmt is set, a result with a timestamp may have a zero amount.
 func getPair(amtMsat lnwire.MilliSatoshi, amtSat bronutil.Amount,
-	timestamp int64) (lnwire.MilliSatoshi, time.Time, error) {
+	timestamp int64, allowZeroAmt bool) (lnwire.MilliSatoshi, time.Time,
+	error) {
 
 	amt, err := getMsatPairValue(amtMsat, amtSat)
 	if err != nil {
@@ -642,6 +820,9 @@ func getPair(amtMsat lnwire.MilliSatoshi, amtSat bronutil.Amount,
 	case timeSet && amountSet:
 		return amt, time.Unix(timestamp, 0), nil
 
+	case timeSet && allowZeroAmt:
+		return amt, time.Unix(timestamp, 0), nil
+
 	case timeSet && !amountSet:
 		return 0, time.Time{}, errors.New("non-zero timestamp " +
 			"requires non-zero amount")
@@ -700,8 +881,20 @@ func (s *Server) QueryProbability(ctx context.Context,
 
 	amt := lnwire.MilliSatoshi(req.AmtMsat)
 
+	// The capacity of the pair is unknown if there is no channel between
+	// the nodes in the graph, which doesn't prevent us from returning an
+	// estimate.
+	capacity, err := s.cfg.Router.FetchPairCapacity(fromNode, toNode)
+	switch {
+	case errors.Is(err, routing.ErrNoPairChannel):
+		capacity = 0
+
+	case err != nil:
+		return nil, err
+	}
+
 	mc := s.cfg.RouterBackend.MissionControl
-	prob := mc.GetProbability(fromNode, toNode, amt)
+	prob := mc.GetProbability(fromNode, toNode, amt, capacity)
 	history := mc.GetPairHistorySnapshot(fromNode, toNode)
 
 	return &QueryProbabilityResponse{
@@ -934,3 +1127,438 @@ func (s *Server) UpdateChanStatus(ctx context.Context,
 	}
 	return &UpdateChanStatusResponse{}, nil
 }
+
+// CreateOffer creates a BOLT 12 offer that payers can request invoices for.
+func (s *Server) CreateOffer(ctx context.Context,
+	req *CreateOfferRequest) (*CreateOfferResponse, error) {
+
+	if s.cfg.OffersManager == nil {
+		return nil, errOffersDisabled
+	}
+
+	offer := &offers.Offer{
+		Amount:      req.AmountMsat,
+		Description: req.Description,
+		Issuer:      req.Issuer,
+	}
+	if req.AbsoluteExpiry != 0 {
+		offer.AbsoluteExpiry = time.Unix(req.AbsoluteExpiry, 0)
+	}
+	if req.AllowQuantity {
+		quantityMax := req.QuantityMax
+		offer.QuantityMax = &quantityMax
+	} else if req.QuantityMax != 0 {
+		return nil, errors.New("quantity_max requires allow_quantity")
+	}
+
+	encoded, err := s.cfg.OffersManager.CreateOffer(offer)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CreateOfferResponse{
+		Offer: encoded,
+	}, nil
+}
+
+// DecodeOffer decodes a BOLT 12 offer.
+func (s *Server) DecodeOffer(ctx context.Context,
+	req *DecodeOfferRequest) (*DecodeOfferResponse, error) {
+
+	offer, err := offers.DecodeOffer(req.Offer)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	resp := &DecodeOfferResponse{
+		Metadata:    offer.Metadata,
+		Currency:    offer.Currency,
+		Amount:      offer.Amount,
+		Description: offer.Description,
+		NumPaths:    uint32(len(offer.Paths)),
+		Issuer:      offer.Issuer,
+	}
+	for _, chain := range offer.Chains {
+		chain := chain
+		resp.Chains = append(resp.Chains, chain[:])
+	}
+	if offer.Features != nil {
+		resp.Features = marshallFeatures(lnwire.NewFeatureVector(
+			offer.Features, lnwire.Features,
+		))
+	}
+	if !offer.AbsoluteExpiry.IsZero() {
+		resp.AbsoluteExpiry = offer.AbsoluteExpiry.Unix()
+	}
+	if offer.QuantityMax != nil {
+		resp.AllowQuantity = true
+		resp.QuantityMax = *offer.QuantityMax
+	}
+	if offer.IssuerID != nil {
+		resp.IssuerId = offer.IssuerID.SerializeCompressed()
+	}
+
+	return resp, nil
+}
+
+// PayOffer fetches an invoice for a BOLT 12 offer from its issuer and pays it.
+func (s *Server) PayOffer(req *PayOfferRequest,
+	stream Router_PayOfferServer) error {
+
+	if s.cfg.OffersManager == nil {
+		return errOffersDisabled
+	}
+
+	offer, err := offers.DecodeOffer(req.Offer)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if req.AmtMsat < 0 {
+		return status.Error(codes.InvalidArgument, "negative amount")
+	}
+
+	fetchTimeout := defaultOfferFetchTimeout
+	if req.FetchTimeoutSeconds != 0 {
+		fetchTimeout = time.Duration(req.FetchTimeoutSeconds) *
+			time.Second
+	}
+
+	invoice, err := s.cfg.OffersManager.FetchInvoice(
+		&offers.FetchInvoiceRequest{
+			Offer:     offer,
+			Amount:    lnwire.MilliSatoshi(req.AmtMsat),
+			Quantity:  req.Quantity,
+			PayerNote: req.PayerNote,
+			Timeout:   fetchTimeout,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("unable to fetch invoice: %v", err)
+	}
+
+	// If the issuer handed out blinded paths, it may not be reachable
+	// under its node ID, so we pay to the introduction node of one of the
+	// paths instead.
+	dest := invoice.NodeID
+	var blindedPath *blindedpath.PaymentPath
+	if len(invoice.Paths) > 0 {
+		paths := make([]*blindedpath.PaymentPath, len(invoice.Paths))
+		for i, path := range invoice.Paths {
+			paths[i] = &blindedpath.PaymentPath{
+				PayInfo: invoice.BlindedPayInfo[i],
+				Path:    path,
+			}
+		}
+
+		blindedPath, err = SelectBlindedPath(paths, invoice.Amount)
+		if err != nil {
+			return err
+		}
+		dest = blindedPath.Path.IntroductionPoint
+	}
+
+	payAddr, err := invoice.PaymentAddr()
+	if err != nil {
+		return err
+	}
+
+	payReq, err := invoice.Encode()
+	if err != nil {
+		return err
+	}
+
+	sendReq := &SendPaymentRequest{
+		Dest:             dest.SerializeCompressed(),
+		AmtMsat:          int64(invoice.Amount),
+		PaymentHash:      invoice.PaymentHash[:],
+		FinalCltvDelta:   offers.FinalCltvDelta,
+		PaymentAddr:      payAddr[:],
+		TimeoutSeconds:   req.TimeoutSeconds,
+		FeeLimitSat:      req.FeeLimitSat,
+		FeeLimitMsat:     req.FeeLimitMsat,
+		OutgoingChanIds:  req.OutgoingChanIds,
+		LastHopPubkey:    req.LastHopPubkey,
+		CltvLimit:        req.CltvLimit,
+		MaxParts:         req.MaxParts,
+		MaxShardSizeMsat: req.MaxShardSizeMsat,
+		DestFeatures: []lnrpc.FeatureBit{
+			lnrpc.FeatureBit_TLV_ONION_OPT,
+			lnrpc.FeatureBit_PAYMENT_ADDR_OPT,
+			lnrpc.FeatureBit_MPP_OPT,
+		},
+	}
+
+	payment, err := s.cfg.RouterBackend.extractIntentFromSendRequest(
+		sendReq,
+	)
+	if err != nil {
+		return err
+	}
+	payment.PaymentRequest = []byte(payReq)
+	payment.BlindedPath = blindedPath
+
+	err = s.cfg.Router.SendPaymentAsync(payment)
+	if err != nil {
+		log.Errorf("PayOffer async error for payment %x: %v",
+			payment.Identifier(), err)
+
+		return err
+	}
+
+	return s.trackPayment(
+		payment.Identifier(), stream, req.NoInflightUpdates,
+	)
+}
+
+// ProbeDestinations probes a list of destinations with a set of amounts and
+// returns the largest amount that reached each of them.
+func (s *Server) ProbeDestinations(ctx context.Context,
+	req *ProbeDestinationsRequest) (*ProbeDestinationsResponse, error) {
+
+	probeReq, err := s.unmarshallProbeRequest(req)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	results, err := s.cfg.Prober.Probe(probeReq, ctx.Done())
+	if err != nil {
+		return nil, err
+	}
+
+	rpcResults, err := marshallProbeResults(results)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ProbeDestinationsResponse{
+		Results: rpcResults,
+	}, nil
+}
+
+// AddProbeSchedule adds a schedule that repeats a probe request at a fixed
+// interval.
+func (s *Server) AddProbeSchedule(ctx context.Context,
+	req *AddProbeScheduleRequest) (*AddProbeScheduleResponse, error) {
+
+	if req.Probes == nil {
+		return nil, status.Error(codes.InvalidArgument,
+			"probes required")
+	}
+
+	probeReq, err := s.unmarshallProbeRequest(req.Probes)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	id, err := s.cfg.Prober.AddSchedule(
+		probeReq, time.Duration(req.IntervalSeconds)*time.Second,
+	)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return &AddProbeScheduleResponse{
+		ScheduleId: id,
+	}, nil
+}
+
+// ListProbeSchedules returns all probe schedules together with the results of
+// their last run.
+func (s *Server) ListProbeSchedules(ctx context.Context,
+	req *ListProbeSchedulesRequest) (*ListProbeSchedulesResponse, error) {
+
+	schedules := s.cfg.Prober.Schedules()
+
+	resp := &ListProbeSchedulesResponse{
+		Schedules: make([]*ProbeSchedule, 0, len(schedules)),
+	}
+	for _, schedule := range schedules {
+		lastResults, err := marshallProbeResults(schedule.LastResults)
+		if err != nil {
+			return nil, err
+		}
+
+		var lastRun int64
+		if !schedule.LastRun.IsZero() {
+			lastRun = schedule.LastRun.Unix()
+		}
+
+		resp.Schedules = append(resp.Schedules, &ProbeSchedule{
+			ScheduleId:      schedule.ID,
+			Probes:          marshallProbeRequest(&schedule.Request),
+			IntervalSeconds: uint32(schedule.Interval.Seconds()),
+			LastRun:         lastRun,
+			LastResults:     lastResults,
+		})
+	}
+
+	return resp, nil
+}
+
+// RemoveProbeSchedule stops and removes a probe schedule.
+func (s *Server) RemoveProbeSchedule(ctx context.Context,
+	req *RemoveProbeScheduleRequest) (*RemoveProbeScheduleResponse, error) {
+
+	err := s.cfg.Prober.RemoveSchedule(req.ScheduleId)
+	if errors.Is(err, routing.ErrUnknownProbeSchedule) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &RemoveProbeScheduleResponse{}, nil
+}
+
+// unmarshallProbeRequest converts an RPC probe request into the form used by
+// the prober, filling in defaults for the time lock parameters.
+func (s *Server) unmarshallProbeRequest(
+	req *ProbeDestinationsRequest) (*routing.ProbeRequest, error) {
+
+	if req.FeeLimitMsat < 0 {
+		return nil, errors.New("negative fee limit")
+	}
+
+	if req.TimeoutSeconds < 0 {
+		return nil, errors.New("negative timeout")
+	}
+
+	backend := s.cfg.RouterBackend
+
+	cltvLimit, err := ValidateCLTVLimit(
+		uint32(req.CltvLimit), backend.MaxTotalTimelock,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	finalCLTVDelta := backend.DefaultFinalCltvDelta
+	if req.FinalCltvDelta != 0 {
+		finalCLTVDelta = uint16(req.FinalCltvDelta)
+	}
+
+	err = routing.ValidateCLTVLimit(cltvLimit, finalCLTVDelta, true)
+	if err != nil {
+		return nil, err
+	}
+
+	probeReq := &routing.ProbeRequest{
+		Destinations: make([]route.Vertex, 0, len(req.Destinations)),
+		Amounts: make(
+			[]lnwire.MilliSatoshi, 0, len(req.AmtsMsat),
+		),
+		FeeLimit:       lnwire.MilliSatoshi(req.FeeLimitMsat),
+		CltvLimit:      cltvLimit,
+		FinalCLTVDelta: finalCLTVDelta,
+		Timeout:        time.Duration(req.TimeoutSeconds) * time.Second,
+	}
+
+	for _, dest := range req.Destinations {
+		vertex, err := route.NewVertexFromBytes(dest)
+		if err != nil {
+			return nil, fmt.Errorf("invalid destination: %v", err)
+		}
+
+		probeReq.Destinations = append(probeReq.Destinations, vertex)
+	}
+
+	for _, amt := range req.AmtsMsat {
+		probeReq.Amounts = append(
+			probeReq.Amounts, lnwire.MilliSatoshi(amt),
+		)
+	}
+
+	return probeReq, nil
+}
+
+// marshallProbeRequest converts a probe request of the prober into its RPC
+// representation.
+func marshallProbeRequest(
+	req *routing.ProbeRequest) *ProbeDestinationsRequest {
+
+	rpcReq := &ProbeDestinationsRequest{
+		Destinations:   make([][]byte, 0, len(req.Destinations)),
+		AmtsMsat:       make([]uint64, 0, len(req.Amounts)),
+		FeeLimitMsat:   int64(req.FeeLimit),
+		CltvLimit:      int32(req.CltvLimit),
+		FinalCltvDelta: int32(req.FinalCLTVDelta),
+		TimeoutSeconds: int32(req.Timeout.Seconds()),
+	}
+
+	for _, dest := range req.Destinations {
+		dest := dest
+		rpcReq.Destinations = append(rpcReq.Destinations, dest[:])
+	}
+
+	for _, amt := range req.Amounts {
+		rpcReq.AmtsMsat = append(rpcReq.AmtsMsat, uint64(amt))
+	}
+
+	return rpcReq
+}
+
+// marshallProbeResults converts the results of a probe run into their RPC
+// representation.
+func marshallProbeResults(results []*routing.DestinationProbeResult) (
+	[]*DestinationProbeResult, error) {
+
+	rpcResults := make([]*DestinationProbeResult, 0, len(results))
+	for _, result := range results {
+		dest := result.Destination
+
+		rpcResult := &DestinationProbeResult{
+			Destination:     dest[:],
+			MaxSendableMsat: uint64(result.MaxSendable),
+			Probes: make(
+				[]*AmountProbeResult, 0, len(result.Probes),
+			),
+			Timestamp: result.Timestamp.Unix(),
+		}
+
+		for _, probe := range result.Probes {
+			rpcProbe := &AmountProbeResult{
+				AmtMsat: uint64(probe.Amount),
+				Reached: probe.Reached,
+			}
+
+			var reason channeldb.FailureReason
+			switch {
+			case probe.Err == nil:
+
+			case errors.As(probe.Err, &reason):
+				rpcReason, err := marshallPaymentFailureReason(
+					&reason,
+				)
+				if err != nil {
+					return nil, err
+				}
+				rpcProbe.FailureReason = rpcReason
+
+			default:
+				rpcProbe.Error = probe.Err.Error()
+			}
+
+			rpcResult.Probes = append(rpcResult.Probes, rpcProbe)
+		}
+
+		rpcResults = append(rpcResults, rpcResult)
+	}
+
+	return rpcResults, nil
+}
+
+// marshallFeatures converts a feature vector into its RPC representation.
+func marshallFeatures(fv *lnwire.FeatureVector) map[uint32]*lnrpc.Feature {
+	features := fv.Features()
+	rpcFeatures := make(map[uint32]*lnrpc.Feature, len(features))
+	for bit := range features {
+		rpcFeatures[uint32(bit)] = &lnrpc.Feature{
+			Name:       fv.Name(bit),
+			IsRequired: bit.IsRequired(),
+			IsKnown:    fv.IsKnown(bit),
+		}
+	}
+
+	return rpcFeatures
+}
@@ -12,6 +12,9 @@ type RoutingConfig struct {
 	// to attempt the payment.
 	MinRouteProbability float64 `long:"minrtprob" description:"Minimum required route success probability to attempt the payment"`
 
+	// ProbabilityEstimatorType sets the estimator to use.
+	ProbabilityEstimatorType string `long:"estimator" choice:"apriori" choice:"bimodal" description:"Probability estimator used for pathfinding."`
+
 	// AprioriHopProbability is the assumed success probability of a hop in
 	// a route when no other information is available.
 	AprioriHopProbability float64 `long:"apriorihopprob" description:"Assumed success probability of a hop in a route when no other information is available."`
@@ -29,6 +32,10 @@ type RoutingConfig struct {
 	// channel is back at 50% probability.
 	PenaltyHalfLife time.Duration `long:"penaltyhalflife" description:"Defines the duration after which a penalized node or channel is back at 50% probability"`
 
+	// BimodalConfig defines parameters for the bimodal probability
+	// estimator.
+	BimodalConfig *BimodalConfig `group:"bimodal" namespace:"bimodal"`
+
 	// AttemptCost is the fixed virtual cost in path finding of a failed
 	// payment attempt. It is used to trade off potentially better routes
 	// against their probability of succeeding.
@@ -47,4 +54,35 @@ type RoutingConfig struct {
 	// McFlushInterval defines the timer interval to use to flush mission
 	// control state to the DB.
 	McFlushInterval time.Duration `long:"mcflushinterval" description:"the timer interval to use to flush mission control state to the DB"`
+
+	// McImportFile is the path to a mission control export that is merged
+	// into mission control at startup.
+	McImportFile string `long:"mcimportfile" description:"Path to a mission control export (as written by brolncli exportmc) that is merged into mission control at startup. Useful to share routing knowledge between nodes that replace each other."`
+
+	// McImportStrategy is the strategy used to merge McImportFile into
+	// mission control.
+	McImportStrategy string `long:"mcimportstrategy" choice:"overwrite" choice:"keep-newer" choice:"keep-stronger-failure" description:"The strategy used to merge mcimportfile into mission control."`
+
+	// MaxProbeConcurrency is the maximum number of probes that are in
+	// flight at the same time.
+	MaxProbeConcurrency int `long:"maxprobeconcurrency" description:"The maximum number of probes sent by ProbeDestinations and probe schedules that are in flight at the same time."`
+
+	// NodeLocationFile is the path to a table of IP prefixes that is used
+	// to locate nodes by their advertised addresses.
+	NodeLocationFile string `long:"nodelocationfile" description:"Path to a table of IP prefixes with their country and autonomous system number, one prefix,country,asn record per line. Required to exclude countries or autonomous systems from the routes of payments."`
+}
+
+// BimodalConfig defines configuration for the bimodal probability estimator.
+type BimodalConfig struct {
+	// NodeWeight defines how strongly non-routed channels should be taken
+	// into account for probability estimation. Valid values are in [0,1].
+	NodeWeight float64 `long:"nodeweight" description:"Defines how strongly non-routed channels of forwarders should be taken into account for probability estimation. Valid values are in [0, 1]."`
+
+	// Scale is a parameter that describes how the liquidity is
+	// distributed in the network on each channel.
+	Scale int64 `long:"scale" description:"Defines the unbalancedness assumed for the network, the amount defined in msat."`
+
+	// DecayTime is the scale for the exponential information decay over
+	// time for previous successes or failures.
+	DecayTime time.Duration `long:"decaytime" description:"Describes the information decay of knowledge about previous successes and failures in channels."`
 }
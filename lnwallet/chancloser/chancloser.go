@@ -35,6 +35,15 @@ var (
 	// shutdown script previously set for that party.
 	ErrUpfrontShutdownScriptMismatch = fmt.Errorf("shutdown script does not " +
 		"match upfront shutdown script")
+
+	// ErrFeeRangesDontOverlap is returned when the fee range sent by the
+	// remote party doesn't overlap with the range of fees we're willing to
+	// accept for the closing transaction.
+	ErrFeeRangesDontOverlap = fmt.Errorf("fee ranges don't overlap")
+
+	// ErrFeeOutsideOfRange is returned when the remote party proposes a
+	// fee that lies outside of the fee range it sent along with it.
+	ErrFeeOutsideOfRange = fmt.Errorf("proposed fee outside of fee range")
 )
 
 // closeState represents all the possible states the channel closer state
@@ -71,6 +80,14 @@ const (
 	// transaction to the network. During this phase, the closing transaction
 	// becomes available for examination.
 	closeFinished
+
+	// closeRbfNegotiation is the state an RbfChanCloser enters once both
+	// parties sent and received a shutdown message. In this state, either
+	// party may propose a closing transaction for which it pays the full
+	// fee with a closing_complete message at any time, replacing any
+	// previous closing transaction. The state machine stays in this state
+	// until the channel's funding output is spent.
+	closeRbfNegotiation
 )
 
 // ChanCloseCfg holds all the items that a ChanCloser requires to carry out its
@@ -122,6 +139,11 @@ type ChanCloser struct {
 	// offer when starting negotiation. This will be used as a baseline.
 	idealFeeSat bronutil.Amount
 
+	// feeRange is the range of fees we're willing to accept for the
+	// closing transaction. It's sent along with each of our proposals, so
+	// that the remote party can settle on a fee within a single round trip.
+	feeRange *lnwire.FeeRange
+
 	// lastFeeProposal is the last fee that we proposed to the remote party.
 	// We'll use this as a pivot point to ratchet our next offer up, down, or
 	// simply accept the remote party's prior offer.
@@ -156,8 +178,10 @@ type ChanCloser struct {
 }
 
 // NewChanCloser creates a new instance of the channel closure given the passed
-// configuration, and delivery+fee preference. The final argument should only
-// be populated iff, we're the initiator of this closing request.
+// configuration, and delivery+fee preference. The closeReq argument should
+// only be populated iff, we're the initiator of this closing request. If it
+// specifies a minimum or maximum fee rate, the fees we accept during the
+// negotiation are bounded accordingly.
 func NewChanCloser(cfg ChanCloseCfg, deliveryScript []byte,
 	idealFeePerKw chainfee.SatPerKWeight, negotiationHeight uint32,
 	closeReq *htlcswitch.ChanClose, locallyInitiated bool) *ChanCloser {
@@ -181,8 +205,23 @@ func NewChanCloser(cfg ChanCloseCfg, deliveryScript []byte,
 		idealFeeSat = channelCommitFee
 	}
 
-	chancloserLog.Infof("Ideal fee for closure of ChannelPoint(%v) is: %v sat",
-		cfg.Channel.ChannelPoint(), int64(idealFeeSat))
+	feeRange := newFeeRange(
+		cfg.Channel.CalcFee, idealFeeSat, channelCommitFee, closeReq,
+	)
+
+	// Our ideal fee must lie within the range of fees we accept.
+	switch {
+	case idealFeeSat < feeRange.MinFeeSats:
+		idealFeeSat = feeRange.MinFeeSats
+
+	case idealFeeSat > feeRange.MaxFeeSats:
+		idealFeeSat = feeRange.MaxFeeSats
+	}
+
+	chancloserLog.Infof("Ideal fee for closure of ChannelPoint(%v) is: %v "+
+		"sat, accepting fees between %v and %v sat",
+		cfg.Channel.ChannelPoint(), int64(idealFeeSat),
+		int64(feeRange.MinFeeSats), int64(feeRange.MaxFeeSats))
 
 	cid := lnwire.NewChanIDFromOutPoint(cfg.Channel.ChannelPoint())
 	return &ChanCloser{
@@ -193,12 +232,44 @@ func NewChanCloser(cfg ChanCloseCfg, deliveryScript []byte,
 		cfg:                 cfg,
 		negotiationHeight:   negotiationHeight,
 		idealFeeSat:         idealFeeSat,
+		feeRange:            feeRange,
 		localDeliveryScript: deliveryScript,
 		priorFeeOffers:      make(map[bronutil.Amount]*lnwire.ClosingSigned),
 		locallyInitiated:    locallyInitiated,
 	}
 }
 
+// newFeeRange returns the range of fees we accept during the fee negotiation.
+// Unless bounded by the close request, we'll accept any fee between the fee
+// floor and our ideal fee or the commitment fee, whichever is greater. If the
+// close request only specifies a minimum fee rate that exceeds this default
+// maximum, the maximum is raised to the minimum.
+func newFeeRange(calcFee func(chainfee.SatPerKWeight) bronutil.Amount,
+	idealFeeSat, commitFee bronutil.Amount,
+	closeReq *htlcswitch.ChanClose) *lnwire.FeeRange {
+
+	feeRange := &lnwire.FeeRange{
+		MinFeeSats: calcFee(chainfee.FeePerKwFloor),
+		MaxFeeSats: commitFee,
+	}
+	if idealFeeSat > feeRange.MaxFeeSats {
+		feeRange.MaxFeeSats = idealFeeSat
+	}
+	if closeReq != nil && closeReq.MinFeePerKw != 0 {
+		feeRange.MinFeeSats = calcFee(closeReq.MinFeePerKw)
+	}
+
+	switch {
+	case closeReq != nil && closeReq.MaxFeePerKw != 0:
+		feeRange.MaxFeeSats = calcFee(closeReq.MaxFeePerKw)
+
+	case feeRange.MinFeeSats > feeRange.MaxFeeSats:
+		feeRange.MaxFeeSats = feeRange.MinFeeSats
+	}
+
+	return feeRange
+}
+
 // initChanShutdown begins the shutdown process by un-registering the channel,
 // and creating a valid shutdown message to our target delivery address.
 func (c *ChanCloser) initChanShutdown() (*lnwire.Shutdown, error) {
@@ -292,6 +363,28 @@ func (c *ChanCloser) NegotiationHeight() uint32 {
 	return c.negotiationHeight
 }
 
+// checkFrozenClose returns an error if the remote party attempts to close a
+// frozen channel we didn't initiate, before its thaw height is reached.
+func checkFrozenClose(channel *lnwallet.LightningChannel,
+	height uint32) error {
+
+	if channel.IsInitiator() {
+		return nil
+	}
+
+	absoluteThawHeight, err := channel.State().AbsoluteThawHeight()
+	if err != nil {
+		return err
+	}
+	if height < absoluteThawHeight {
+		return fmt.Errorf("initiator attempting to co-op close frozen "+
+			"ChannelPoint(%v) (current_height=%v, thaw_height=%v)",
+			channel.ChannelPoint(), height, absoluteThawHeight)
+	}
+
+	return nil
+}
+
 // maybeMatchScript attempts to match the script provided in our peer's
 // shutdown message with the upfront shutdown script we have on record. If no
 // upfront shutdown script was set, we do not need to enforce option upfront
@@ -353,19 +446,9 @@ func (c *ChanCloser) ProcessCloseMsg(msg lnwire.Message) ([]lnwire.Message,
 		// initiator of the channel opening, then we'll deny their close
 		// attempt.
 		chanInitiator := c.cfg.Channel.IsInitiator()
-		chanState := c.cfg.Channel.State()
-		if !chanInitiator {
-			absoluteThawHeight, err := chanState.AbsoluteThawHeight()
-			if err != nil {
-				return nil, false, err
-			}
-			if c.negotiationHeight < absoluteThawHeight {
-				return nil, false, fmt.Errorf("initiator "+
-					"attempting to co-op close frozen "+
-					"ChannelPoint(%v) (current_height=%v, "+
-					"thaw_height=%v)", c.chanPoint,
-					c.negotiationHeight, absoluteThawHeight)
-			}
+		err := checkFrozenClose(c.cfg.Channel, c.negotiationHeight)
+		if err != nil {
+			return nil, false, err
 		}
 
 		// If the remote node opened the channel with option upfront shutdown
@@ -478,12 +561,32 @@ func (c *ChanCloser) ProcessCloseMsg(msg lnwire.Message) ([]lnwire.Message,
 		// we'll attempt to ratchet the fee closer to
 		remoteProposedFee := closeSignedMsg.FeeSatoshis
 		if _, ok := c.priorFeeOffers[remoteProposedFee]; !ok {
-			// We'll now attempt to ratchet towards a fee deemed acceptable by
-			// both parties, factoring in our ideal fee rate, and the last
-			// proposed fee by both sides.
-			feeProposal := calcCompromiseFee(c.chanPoint, c.idealFeeSat,
-				c.lastFeeProposal, remoteProposedFee,
+			// If the remote party sent its range of acceptable fees,
+			// we'll either accept its fee, or settle on a fee within
+			// the overlap of both ranges right away.
+			var (
+				feeProposal bronutil.Amount
+				err         error
 			)
+			if closeSignedMsg.FeeRange != nil {
+				feeProposal, err = calcFeeRangeProposal(
+					c.idealFeeSat, c.feeRange,
+					remoteProposedFee,
+					closeSignedMsg.FeeRange,
+				)
+				if err != nil {
+					return nil, false, err
+				}
+			} else {
+				// Otherwise, we'll attempt to ratchet towards a
+				// fee deemed acceptable by both parties,
+				// factoring in our ideal fee rate, and the last
+				// proposed fee by both sides.
+				feeProposal = calcCompromiseFee(
+					c.chanPoint, c.idealFeeSat,
+					c.lastFeeProposal, remoteProposedFee,
+				)
+			}
 
 			// With our new fee proposal calculated, we'll craft a new close
 			// signed signature to send to the other party so we can continue
@@ -608,6 +711,13 @@ func (c *ChanCloser) proposeCloseSigned(fee bronutil.Amount) (*lnwire.ClosingSig
 	// closure process.
 	closeSignedMsg := lnwire.NewClosingSigned(c.cid, fee, parsedSig)
 
+	// We'll attach the range of fees we're willing to accept, so that a
+	// remote party supporting it can settle on a fee right away.
+	closeSignedMsg.FeeRange = &lnwire.FeeRange{
+		MinFeeSats: c.feeRange.MinFeeSats,
+		MaxFeeSats: c.feeRange.MaxFeeSats,
+	}
+
 	// We'll also save this close signed, in the case that the remote party
 	// accepts our offer. This way, we don't have to re-sign.
 	c.priorFeeOffers[fee] = closeSignedMsg
@@ -709,6 +819,47 @@ func calcCompromiseFee(chanPoint wire.OutPoint, ourIdealFee, lastSentFee,
 	}
 }
 
+// calcFeeRangeProposal computes our response to a fee proposal of the remote
+// party that was sent along with its range of acceptable fees. If the proposed
+// fee lies within our own range, it is accepted as is. Otherwise, we propose
+// the fee closest to our ideal fee within the overlap of both ranges. An error
+// is returned if the ranges don't overlap.
+func calcFeeRangeProposal(ourIdealFee bronutil.Amount,
+	ourRange *lnwire.FeeRange, remoteFee bronutil.Amount,
+	remoteRange *lnwire.FeeRange) (bronutil.Amount, error) {
+
+	if !remoteRange.Contains(remoteFee) {
+		return 0, fmt.Errorf("%w: fee=%v, min=%v, max=%v",
+			ErrFeeOutsideOfRange, remoteFee, remoteRange.MinFeeSats,
+			remoteRange.MaxFeeSats)
+	}
+
+	// If the fee proposed by the remote party is acceptable to us, we'll
+	// simply accept it, ending the negotiation.
+	if ourRange.Contains(remoteFee) {
+		return remoteFee, nil
+	}
+
+	overlap, ok := ourRange.Overlap(remoteRange)
+	if !ok {
+		return 0, fmt.Errorf("%w: ours=[%v, %v], theirs=[%v, %v]",
+			ErrFeeRangesDontOverlap, ourRange.MinFeeSats,
+			ourRange.MaxFeeSats, remoteRange.MinFeeSats,
+			remoteRange.MaxFeeSats)
+	}
+
+	switch {
+	case ourIdealFee < overlap.MinFeeSats:
+		return overlap.MinFeeSats, nil
+
+	case ourIdealFee > overlap.MaxFeeSats:
+		return overlap.MaxFeeSats, nil
+
+	default:
+		return ourIdealFee, nil
+	}
+}
+
 // ParseUpfrontShutdownAddress attempts to parse an upfront shutdown address.
 // If the address is empty, it returns nil. If it successfully decoded the
 // address, it returns a script that pays out to the address.
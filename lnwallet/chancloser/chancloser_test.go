@@ -4,7 +4,11 @@ import (
 	"crypto/rand"
 	"testing"
 
+	"github.com/brsuite/bronutil"
+	"github.com/brsuite/broln/htlcswitch"
+	"github.com/brsuite/broln/lnwallet/chainfee"
 	"github.com/brsuite/broln/lnwire"
+	"github.com/stretchr/testify/require"
 )
 
 // randDeliveryAddress generates a random delivery address for testing.
@@ -74,3 +78,173 @@ func TestMaybeMatchScript(t *testing.T) {
 		})
 	}
 }
+
+// TestCalcFeeRangeProposal tests that we either accept the fee proposed by the
+// remote party, or settle on a fee within the overlap of both fee ranges.
+func TestCalcFeeRangeProposal(t *testing.T) {
+	ourRange := &lnwire.FeeRange{MinFeeSats: 1000, MaxFeeSats: 5000}
+
+	tests := []struct {
+		name        string
+		idealFee    bronutil.Amount
+		remoteFee   bronutil.Amount
+		remoteRange *lnwire.FeeRange
+		expectedFee bronutil.Amount
+		expectedErr error
+	}{
+		{
+			name:      "remote fee in our range",
+			idealFee:  2000,
+			remoteFee: 4000,
+			remoteRange: &lnwire.FeeRange{
+				MinFeeSats: 3000, MaxFeeSats: 8000,
+			},
+			expectedFee: 4000,
+		},
+		{
+			name:      "ideal fee in overlap",
+			idealFee:  4000,
+			remoteFee: 7000,
+			remoteRange: &lnwire.FeeRange{
+				MinFeeSats: 3000, MaxFeeSats: 8000,
+			},
+			expectedFee: 4000,
+		},
+		{
+			name:      "ideal fee below overlap",
+			idealFee:  2000,
+			remoteFee: 7000,
+			remoteRange: &lnwire.FeeRange{
+				MinFeeSats: 3000, MaxFeeSats: 8000,
+			},
+			expectedFee: 3000,
+		},
+		{
+			name:      "ideal fee above overlap",
+			idealFee:  4000,
+			remoteFee: 500,
+			remoteRange: &lnwire.FeeRange{
+				MinFeeSats: 200, MaxFeeSats: 2000,
+			},
+			expectedFee: 2000,
+		},
+		{
+			name:      "ranges don't overlap",
+			idealFee:  2000,
+			remoteFee: 7000,
+			remoteRange: &lnwire.FeeRange{
+				MinFeeSats: 6000, MaxFeeSats: 8000,
+			},
+			expectedErr: ErrFeeRangesDontOverlap,
+		},
+		{
+			name:      "remote fee outside of remote range",
+			idealFee:  2000,
+			remoteFee: 2000,
+			remoteRange: &lnwire.FeeRange{
+				MinFeeSats: 3000, MaxFeeSats: 8000,
+			},
+			expectedErr: ErrFeeOutsideOfRange,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			fee, err := calcFeeRangeProposal(
+				test.idealFee, ourRange, test.remoteFee,
+				test.remoteRange,
+			)
+			require.ErrorIs(t, err, test.expectedErr)
+			require.Equal(t, test.expectedFee, fee)
+		})
+	}
+}
+
+// TestNewFeeRange tests that the range of fees we accept during the fee
+// negotiation is bounded by the close request, and never ends up empty if
+// the request only specifies a minimum fee rate.
+func TestNewFeeRange(t *testing.T) {
+	t.Parallel()
+
+	// Fees are calculated for a closing transaction of 1000 weight units,
+	// so the fee in satoshis equals the fee rate in sat/kw.
+	calcFee := func(feeRate chainfee.SatPerKWeight) bronutil.Amount {
+		return feeRate.FeeForWeight(1000)
+	}
+	floorFee := calcFee(chainfee.FeePerKwFloor)
+
+	tests := []struct {
+		name          string
+		idealFee      bronutil.Amount
+		commitFee     bronutil.Amount
+		closeReq      *htlcswitch.ChanClose
+		expectedRange *lnwire.FeeRange
+	}{
+		{
+			name:      "no close request",
+			idealFee:  1000,
+			commitFee: 2000,
+			expectedRange: &lnwire.FeeRange{
+				MinFeeSats: floorFee, MaxFeeSats: 2000,
+			},
+		},
+		{
+			name:      "ideal fee above commit fee",
+			idealFee:  3000,
+			commitFee: 2000,
+			closeReq:  &htlcswitch.ChanClose{},
+			expectedRange: &lnwire.FeeRange{
+				MinFeeSats: floorFee, MaxFeeSats: 3000,
+			},
+		},
+		{
+			name:      "bounded by close request",
+			idealFee:  1000,
+			commitFee: 2000,
+			closeReq: &htlcswitch.ChanClose{
+				MinFeePerKw: 500, MaxFeePerKw: 5000,
+			},
+			expectedRange: &lnwire.FeeRange{
+				MinFeeSats: 500, MaxFeeSats: 5000,
+			},
+		},
+		{
+			name:      "minimum below default maximum",
+			idealFee:  1000,
+			commitFee: 2000,
+			closeReq: &htlcswitch.ChanClose{
+				MinFeePerKw: 1500,
+			},
+			expectedRange: &lnwire.FeeRange{
+				MinFeeSats: 1500, MaxFeeSats: 2000,
+			},
+		},
+		{
+			name:      "minimum above default maximum",
+			idealFee:  1000,
+			commitFee: 2000,
+			closeReq: &htlcswitch.ChanClose{
+				MinFeePerKw: 4000,
+			},
+			expectedRange: &lnwire.FeeRange{
+				MinFeeSats: 4000, MaxFeeSats: 4000,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			feeRange := newFeeRange(
+				calcFee, test.idealFee, test.commitFee,
+				test.closeReq,
+			)
+			require.Equal(t, test.expectedRange, feeRange)
+		})
+	}
+}
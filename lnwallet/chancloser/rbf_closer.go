@@ -0,0 +1,644 @@
+package chancloser
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+
+	"github.com/brsuite/brond/wire"
+	"github.com/brsuite/bronutil"
+	"github.com/davecgh/go-spew/spew"
+	"github.com/brsuite/broln/htlcswitch"
+	"github.com/brsuite/broln/input"
+	"github.com/brsuite/broln/labels"
+	"github.com/brsuite/broln/lnwallet"
+	"github.com/brsuite/broln/lnwallet/chainfee"
+	"github.com/brsuite/broln/lnwire"
+)
+
+var (
+	// ErrCloseFeeNotIncreased is returned when a new closing transaction
+	// is requested with a fee that doesn't exceed the fee of our previous
+	// proposal, so it wouldn't be able to replace it.
+	ErrCloseFeeNotIncreased = fmt.Errorf("closing fee must exceed the " +
+		"fee of the previous proposal")
+)
+
+// rbfProposal is a closing transaction we proposed to the remote party with a
+// closing_complete message, awaiting its closing_sig.
+type rbfProposal struct {
+	// close describes the proposed closing transaction.
+	close *lnwallet.SimpleClose
+
+	// sig is our signature for the proposed closing transaction.
+	sig input.Signature
+}
+
+// RbfChanCloser is a state machine that handles the cooperative closure of a
+// channel using replaceable closing transactions. After both parties
+// exchanged shutdown messages, either party may propose a closing transaction
+// for which it pays the full fee, to which the other party responds with its
+// signature. Each party may propose a new closing transaction with a higher
+// fee at any time, replacing any previous one, until one of them confirms.
+type RbfChanCloser struct {
+	// state is the current state of the state machine.
+	state closeState
+
+	// cfg holds the configuration for this RbfChanCloser instance.
+	cfg ChanCloseCfg
+
+	// chanPoint is the full channel point of the target channel.
+	chanPoint wire.OutPoint
+
+	// cid is the full channel ID of the target channel.
+	cid lnwire.ChannelID
+
+	// negotiationHeight is the height that the close negotiation begun
+	// at. It's used as the lock time of the closing transactions we
+	// propose.
+	negotiationHeight uint32
+
+	// idealFeeRate is the fee rate of the next closing transaction we'll
+	// propose.
+	idealFeeRate chainfee.SatPerKWeight
+
+	// lastLocalFee is the fee of the last closing transaction we proposed.
+	// Any new proposal must pay a higher fee.
+	lastLocalFee bronutil.Amount
+
+	// pendingProposal is our latest proposal still awaiting the signature
+	// of the remote party, if any.
+	pendingProposal *rbfProposal
+
+	// closingTx is the latest fully signed closing transaction that was
+	// broadcast, if any.
+	closingTx *wire.MsgTx
+
+	// localDeliveryScript is the script that we'll send our settled
+	// channel funds to.
+	localDeliveryScript []byte
+
+	// remoteDeliveryScript is the script that we'll send the remote
+	// party's settled channel funds to.
+	remoteDeliveryScript []byte
+
+	// locallyInitiated is true if we initiated the channel close.
+	locallyInitiated bool
+
+	// closeReqs are all local requests to close the channel, and
+	// notified marks those that were already notified of a broadcast
+	// closing transaction. They're guarded by reqMtx, as they're read by
+	// the caller once the channel closed.
+	closeReqs []*htlcswitch.ChanClose
+	notified  map[*htlcswitch.ChanClose]struct{}
+	reqMtx    sync.Mutex
+}
+
+// NewRbfChanCloser creates a new instance of the RBF channel closer given the
+// passed configuration, and delivery+fee preference. The closeReq should only
+// be populated iff we're the initiator of this closing request.
+func NewRbfChanCloser(cfg ChanCloseCfg, deliveryScript []byte,
+	idealFeePerKw chainfee.SatPerKWeight, negotiationHeight uint32,
+	closeReq *htlcswitch.ChanClose, locallyInitiated bool) *RbfChanCloser {
+
+	cid := lnwire.NewChanIDFromOutPoint(cfg.Channel.ChannelPoint())
+	c := &RbfChanCloser{
+		state:               closeIdle,
+		cfg:                 cfg,
+		chanPoint:           *cfg.Channel.ChannelPoint(),
+		cid:                 cid,
+		negotiationHeight:   negotiationHeight,
+		idealFeeRate:        idealFeePerKw,
+		localDeliveryScript: deliveryScript,
+		locallyInitiated:    locallyInitiated,
+		notified:            make(map[*htlcswitch.ChanClose]struct{}),
+	}
+	if closeReq != nil {
+		c.closeReqs = append(c.closeReqs, closeReq)
+	}
+
+	return c
+}
+
+// initChanShutdown begins the shutdown process by disabling the channel, and
+// creating a valid shutdown message to our target delivery address.
+func (c *RbfChanCloser) initChanShutdown() (*lnwire.Shutdown, error) {
+	shutdown := lnwire.NewShutdown(c.cid, c.localDeliveryScript)
+
+	if err := c.cfg.DisableChannel(c.chanPoint); err != nil {
+		chancloserLog.Warnf("Unable to disable channel %v on close: %v",
+			c.chanPoint, err)
+	}
+
+	// As with the legacy close flow, we mark the channel as cooperatively
+	// closed with a nil txn, so the channel is reported as being shut
+	// down by the time the closing request returns.
+	err := c.cfg.Channel.MarkCoopBroadcasted(nil, c.locallyInitiated)
+	if err != nil {
+		return nil, err
+	}
+
+	chancloserLog.Infof("ChannelPoint(%v): sending shutdown message",
+		c.chanPoint)
+
+	return shutdown, nil
+}
+
+// ShutdownChan is the first method that's to be called by the initiator of the
+// cooperative channel closure. This message returns the shutdown message to
+// send to the remote party. Upon completion, we enter the
+// closeShutdownInitiated phase as we await a response.
+func (c *RbfChanCloser) ShutdownChan() (*lnwire.Shutdown, error) {
+	if c.state != closeIdle {
+		return nil, ErrChanAlreadyClosing
+	}
+
+	chancloserLog.Infof("ChannelPoint(%v): initiating RBF shutdown",
+		c.chanPoint)
+
+	shutdownMsg, err := c.initChanShutdown()
+	if err != nil {
+		return nil, err
+	}
+
+	c.state = closeShutdownInitiated
+
+	return shutdownMsg, nil
+}
+
+// BumpFee handles a new local request to close the channel with the given fee
+// rate. If shutdown messages were already exchanged, a closing_complete
+// message proposing a closing transaction at the new fee rate is returned,
+// which replaces any previous closing transaction. Otherwise, the fee rate is
+// used for our first proposal once the remote party's shutdown arrives.
+func (c *RbfChanCloser) BumpFee(feeRate chainfee.SatPerKWeight,
+	closeReq *htlcswitch.ChanClose) (*lnwire.ClosingComplete, error) {
+
+	switch c.state {
+	case closeShutdownInitiated:
+		c.idealFeeRate = feeRate
+		c.addCloseRequest(closeReq)
+
+		return nil, nil
+
+	case closeRbfNegotiation:
+		closingComplete, err := c.proposeClose(feeRate)
+		if err != nil {
+			return nil, err
+		}
+		c.idealFeeRate = feeRate
+		c.addCloseRequest(closeReq)
+
+		return closingComplete, nil
+
+	default:
+		return nil, ErrInvalidState
+	}
+}
+
+// addCloseRequest records a new local request to close the channel.
+func (c *RbfChanCloser) addCloseRequest(closeReq *htlcswitch.ChanClose) {
+	if closeReq == nil {
+		return
+	}
+
+	c.reqMtx.Lock()
+	c.closeReqs = append(c.closeReqs, closeReq)
+	c.reqMtx.Unlock()
+}
+
+// CloseRequests returns all local requests to close the channel.
+//
+// NOTE: This method is safe for concurrent use.
+func (c *RbfChanCloser) CloseRequests() []*htlcswitch.ChanClose {
+	c.reqMtx.Lock()
+	defer c.reqMtx.Unlock()
+
+	reqs := make([]*htlcswitch.ChanClose, len(c.closeReqs))
+	copy(reqs, c.closeReqs)
+
+	return reqs
+}
+
+// UnnotifiedRequests returns the local requests to close the channel that
+// haven't been notified of a broadcast closing transaction yet, and marks them
+// as notified. This ensures each request receives a single pending update.
+func (c *RbfChanCloser) UnnotifiedRequests() []*htlcswitch.ChanClose {
+	c.reqMtx.Lock()
+	defer c.reqMtx.Unlock()
+
+	var reqs []*htlcswitch.ChanClose
+	for _, req := range c.closeReqs {
+		if _, ok := c.notified[req]; ok {
+			continue
+		}
+		c.notified[req] = struct{}{}
+		reqs = append(reqs, req)
+	}
+
+	return reqs
+}
+
+// ClosingTx returns the latest fully signed closing transaction that was
+// broadcast, or nil if there's none yet.
+func (c *RbfChanCloser) ClosingTx() *wire.MsgTx {
+	return c.closingTx
+}
+
+// DeliveryScript returns the script our funds are paid out to.
+func (c *RbfChanCloser) DeliveryScript() []byte {
+	return c.localDeliveryScript
+}
+
+// Channel returns the channel stored in the config.
+func (c *RbfChanCloser) Channel() *lnwallet.LightningChannel {
+	return c.cfg.Channel
+}
+
+// NegotiationHeight returns the negotiation height.
+func (c *RbfChanCloser) NegotiationHeight() uint32 {
+	return c.negotiationHeight
+}
+
+// ProcessCloseMsg attempts to process the next message in the closing series.
+// This method will update the state accordingly and return two primary values:
+// the next set of messages to be sent, and a bool indicating if a new closing
+// transaction was broadcast. Unlike the ChanCloser, the state machine must be
+// kept around after a closing transaction was broadcast, as either party may
+// still replace it.
+func (c *RbfChanCloser) ProcessCloseMsg(msg lnwire.Message) ([]lnwire.Message,
+	bool, error) {
+
+	switch c.state {
+
+	// If we're in the close idle state, and we're receiving a channel
+	// closure related message, then this indicates that we're on the
+	// receiving side of an initiated cooperative channel closure.
+	case closeIdle:
+		shutdownMsg, ok := msg.(*lnwire.Shutdown)
+		if !ok {
+			return nil, false, fmt.Errorf("expected lnwire.Shutdown, "+
+				"instead have %v", spew.Sdump(msg))
+		}
+
+		err := checkFrozenClose(c.cfg.Channel, c.negotiationHeight)
+		if err != nil {
+			return nil, false, err
+		}
+
+		if err := maybeMatchScript(
+			c.cfg.Disconnect, c.cfg.Channel.RemoteUpfrontShutdownScript(),
+			shutdownMsg.Address,
+		); err != nil {
+			return nil, false, err
+		}
+		c.remoteDeliveryScript = shutdownMsg.Address
+
+		localShutdown, err := c.initChanShutdown()
+		if err != nil {
+			return nil, false, err
+		}
+
+		chancloserLog.Infof("ChannelPoint(%v): responding to shutdown, "+
+			"awaiting closing transactions", c.chanPoint)
+
+		// As we didn't request the close, we leave it to the remote
+		// party to propose a closing transaction.
+		c.state = closeRbfNegotiation
+
+		return []lnwire.Message{localShutdown}, false, nil
+
+	// If we just initiated a channel shutdown, and we receive the remote
+	// party's shutdown, we'll propose our first closing transaction.
+	case closeShutdownInitiated:
+		shutdownMsg, ok := msg.(*lnwire.Shutdown)
+		if !ok {
+			return nil, false, fmt.Errorf("expected lnwire.Shutdown, "+
+				"instead have %v", spew.Sdump(msg))
+		}
+
+		if err := maybeMatchScript(c.cfg.Disconnect,
+			c.cfg.Channel.RemoteUpfrontShutdownScript(), shutdownMsg.Address,
+		); err != nil {
+			return nil, false, err
+		}
+		c.remoteDeliveryScript = shutdownMsg.Address
+
+		c.state = closeRbfNegotiation
+
+		chancloserLog.Infof("ChannelPoint(%v): shutdown response "+
+			"received, proposing closing transaction", c.chanPoint)
+
+		closingComplete, err := c.proposeClose(c.idealFeeRate)
+		if err != nil {
+			return nil, false, err
+		}
+
+		return []lnwire.Message{closingComplete}, false, nil
+
+	// Once shutdown messages were exchanged, the remote party may either
+	// propose a closing transaction, or respond to one of ours.
+	case closeRbfNegotiation:
+		switch msg := msg.(type) {
+		case *lnwire.ClosingComplete:
+			closingSig, err := c.acceptClose(msg)
+			if err != nil {
+				return nil, false, err
+			}
+
+			return []lnwire.Message{closingSig}, true, nil
+
+		case *lnwire.ClosingSig:
+			broadcast, err := c.completeClose(msg)
+			if err != nil {
+				return nil, false, err
+			}
+
+			return nil, broadcast, nil
+
+		default:
+			return nil, false, fmt.Errorf("expected "+
+				"lnwire.ClosingComplete or lnwire.ClosingSig, "+
+				"instead have %v", spew.Sdump(msg))
+		}
+
+	// Otherwise, we're in an unknown state, and can't proceed.
+	default:
+		return nil, false, ErrInvalidState
+	}
+}
+
+// closeFee returns the fee of a closing transaction paying out to both
+// parties at the given fee rate.
+func (c *RbfChanCloser) closeFee(
+	feeRate chainfee.SatPerKWeight) bronutil.Amount {
+
+	var weightEstimate input.TxWeightEstimator
+	weightEstimate.AddWitnessInput(input.MultiSigWitnessSize)
+	weightEstimate.AddTxOutput(&wire.TxOut{
+		PkScript: c.localDeliveryScript,
+	})
+	weightEstimate.AddTxOutput(&wire.TxOut{
+		PkScript: c.remoteDeliveryScript,
+	})
+
+	return feeRate.FeeForWeight(int64(weightEstimate.Weight()))
+}
+
+// proposeClose signs a closing transaction at the given fee rate, for which
+// we pay the full fee, and returns the closing_complete message proposing it
+// to the remote party.
+func (c *RbfChanCloser) proposeClose(
+	feeRate chainfee.SatPerKWeight) (*lnwire.ClosingComplete, error) {
+
+	fee := c.closeFee(feeRate)
+	if fee <= c.lastLocalFee {
+		return nil, ErrCloseFeeNotIncreased
+	}
+
+	ourBalance, theirBalance, err := c.cfg.Channel.SimpleCloseBalance(
+		fee, true,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Any output below the dust limit of its owner is omitted, its value
+	// going to fees.
+	chanState := c.cfg.Channel.State()
+	simpleClose := &lnwallet.SimpleClose{
+		Fee:            fee,
+		LockTime:       c.negotiationHeight,
+		LocalIsCloser:  true,
+		LocalScript:    c.localDeliveryScript,
+		RemoteScript:   c.remoteDeliveryScript,
+		NoCloserOutput: ourBalance < chanState.LocalChanCfg.DustLimit,
+		NoCloseeOutput: theirBalance < chanState.RemoteChanCfg.DustLimit,
+	}
+
+	rawSig, err := c.cfg.Channel.SignSimpleClose(simpleClose)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := lnwire.NewSigFromSignature(rawSig)
+	if err != nil {
+		return nil, err
+	}
+
+	closingComplete := &lnwire.ClosingComplete{
+		ChannelID:    c.cid,
+		CloserScript: c.localDeliveryScript,
+		CloseeScript: c.remoteDeliveryScript,
+		FeeSatoshis:  fee,
+		LockTime:     simpleClose.LockTime,
+	}
+	switch {
+	case simpleClose.NoCloserOutput:
+		closingComplete.ClosingSigs.NoCloserClosee = &sig
+
+	case simpleClose.NoCloseeOutput:
+		closingComplete.ClosingSigs.CloserNoClosee = &sig
+
+	default:
+		closingComplete.ClosingSigs.CloserAndClosee = &sig
+	}
+
+	c.lastLocalFee = fee
+	c.pendingProposal = &rbfProposal{
+		close: simpleClose,
+		sig:   rawSig,
+	}
+
+	chancloserLog.Infof("ChannelPoint(%v): proposing closing transaction "+
+		"with fee of %v sat", c.chanPoint, int64(fee))
+
+	return closingComplete, nil
+}
+
+// acceptClose signs and broadcasts the closing transaction proposed by the
+// remote party, returning the closing_sig message carrying our signature.
+func (c *RbfChanCloser) acceptClose(
+	msg *lnwire.ClosingComplete) (*lnwire.ClosingSig, error) {
+
+	if !bytes.Equal(msg.CloseeScript, c.localDeliveryScript) {
+		return nil, fmt.Errorf("closing transaction doesn't pay out "+
+			"to our script %x", c.localDeliveryScript)
+	}
+
+	// The remote party may change its own script, as long as it sticks
+	// to the upfront shutdown script, if any.
+	if err := maybeMatchScript(c.cfg.Disconnect,
+		c.cfg.Channel.RemoteUpfrontShutdownScript(), msg.CloserScript,
+	); err != nil {
+		return nil, err
+	}
+
+	simpleClose := &lnwallet.SimpleClose{
+		Fee:          msg.FeeSatoshis,
+		LockTime:     msg.LockTime,
+		LocalScript:  c.localDeliveryScript,
+		RemoteScript: msg.CloserScript,
+	}
+
+	// We'll prefer the variant that pays out to both parties. The remote
+	// party may only omit our output if it's dust.
+	var remoteSig *lnwire.Sig
+	sigs := msg.ClosingSigs
+	switch {
+	case sigs.CloserAndClosee != nil:
+		remoteSig = sigs.CloserAndClosee
+
+	case sigs.NoCloserClosee != nil:
+		simpleClose.NoCloserOutput = true
+		remoteSig = sigs.NoCloserClosee
+
+	case sigs.CloserNoClosee != nil:
+		_, ourBalance, err := c.cfg.Channel.SimpleCloseBalance(
+			msg.FeeSatoshis, false,
+		)
+		if err != nil {
+			return nil, err
+		}
+		dustLimit := c.cfg.Channel.State().LocalChanCfg.DustLimit
+		if ourBalance >= dustLimit {
+			return nil, fmt.Errorf("closing transaction omits our "+
+				"output of %v", ourBalance)
+		}
+
+		simpleClose.NoCloseeOutput = true
+		remoteSig = sigs.CloserNoClosee
+
+	default:
+		return nil, fmt.Errorf("closing_complete has no signature")
+	}
+
+	parsedRemoteSig, err := remoteSig.ToSignature()
+	if err != nil {
+		return nil, err
+	}
+
+	localSig, err := c.cfg.Channel.SignSimpleClose(simpleClose)
+	if err != nil {
+		return nil, err
+	}
+
+	closeTx, err := c.cfg.Channel.CompleteSimpleClose(
+		simpleClose, localSig, parsedRemoteSig,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.broadcast(closeTx); err != nil {
+		return nil, err
+	}
+
+	sig, err := lnwire.NewSigFromSignature(localSig)
+	if err != nil {
+		return nil, err
+	}
+
+	closingSig := &lnwire.ClosingSig{
+		ChannelID:    c.cid,
+		CloserScript: msg.CloserScript,
+		CloseeScript: msg.CloseeScript,
+		FeeSatoshis:  msg.FeeSatoshis,
+		LockTime:     msg.LockTime,
+	}
+	switch {
+	case simpleClose.NoCloserOutput:
+		closingSig.ClosingSigs.NoCloserClosee = &sig
+
+	case simpleClose.NoCloseeOutput:
+		closingSig.ClosingSigs.CloserNoClosee = &sig
+
+	default:
+		closingSig.ClosingSigs.CloserAndClosee = &sig
+	}
+
+	chancloserLog.Infof("ChannelPoint(%v): accepted closing transaction "+
+		"with fee of %v sat", c.chanPoint, int64(msg.FeeSatoshis))
+
+	return closingSig, nil
+}
+
+// completeClose completes and broadcasts our pending closing transaction with
+// the signature of the remote party. False is returned if the message doesn't
+// match our pending proposal, e.g. as it was replaced in the meantime.
+func (c *RbfChanCloser) completeClose(msg *lnwire.ClosingSig) (bool, error) {
+	proposal := c.pendingProposal
+	if proposal == nil || msg.FeeSatoshis != proposal.close.Fee ||
+		msg.LockTime != proposal.close.LockTime {
+
+		chancloserLog.Debugf("ChannelPoint(%v): ignoring closing_sig "+
+			"for fee of %v sat not matching pending proposal",
+			c.chanPoint, int64(msg.FeeSatoshis))
+
+		return false, nil
+	}
+
+	var remoteSig *lnwire.Sig
+	sigs := msg.ClosingSigs
+	switch {
+	case proposal.close.NoCloserOutput:
+		remoteSig = sigs.NoCloserClosee
+
+	case proposal.close.NoCloseeOutput:
+		remoteSig = sigs.CloserNoClosee
+
+	default:
+		remoteSig = sigs.CloserAndClosee
+	}
+	if remoteSig == nil {
+		return false, fmt.Errorf("closing_sig lacks signature for " +
+			"proposed closing transaction")
+	}
+
+	parsedRemoteSig, err := remoteSig.ToSignature()
+	if err != nil {
+		return false, err
+	}
+
+	closeTx, err := c.cfg.Channel.CompleteSimpleClose(
+		proposal.close, proposal.sig, parsedRemoteSig,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	if err := c.broadcast(closeTx); err != nil {
+		return false, err
+	}
+	c.pendingProposal = nil
+
+	return true, nil
+}
+
+// broadcast persists and broadcasts the given closing transaction, replacing
+// any closing transaction broadcast before.
+func (c *RbfChanCloser) broadcast(closeTx *wire.MsgTx) error {
+	// Before publishing the closing tx, we persist it to the database,
+	// such that it can be republished if something goes wrong.
+	err := c.cfg.Channel.MarkCoopBroadcasted(closeTx, c.locallyInitiated)
+	if err != nil {
+		return err
+	}
+
+	chancloserLog.Infof("Broadcasting cooperative close tx: %v",
+		newLogClosure(func() string {
+			return spew.Sdump(closeTx)
+		}),
+	)
+
+	chanID := c.cfg.Channel.ShortChanID()
+	closeLabel := labels.MakeLabel(
+		labels.LabelTypeChannelClose, &chanID,
+	)
+	if err := c.cfg.BroadcastTx(closeTx, closeLabel); err != nil {
+		return err
+	}
+
+	c.closingTx = closeTx
+
+	return nil
+}
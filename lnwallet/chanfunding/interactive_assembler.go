@@ -0,0 +1,316 @@
+package chanfunding
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/brsuite/brond/txscript"
+	"github.com/brsuite/brond/wire"
+	"github.com/brsuite/bronutil"
+	"github.com/brsuite/broln/input"
+)
+
+// InteractiveIntent is an intent for a dual funded channel whose funding
+// transaction is constructed interactively with the remote party. Our part of
+// the funding transaction is selected from the internal wallet just like for
+// the FullIntent, while the remote party adds its inputs and outputs through
+// the interactive transaction session.
+//
+// Steps to final channel provisioning:
+//  1. Call BindKeys to notify the intent which keys to use when constructing
+//  the multi-sig output.
+//  2. Call AddLocalContribution to add our inputs and outputs to the
+//  session, and announce them to the remote party.
+//  3. Add the remote party's inputs and outputs to the session.
+//  4. Call CompileFundingTx once both parties completed the construction to
+//  obtain the funding transaction with our inputs signed.
+//  5. Call AddRemoteWitnesses once the remote party sent the witnesses for
+//  its inputs to obtain the final funding transaction.
+//
+// If any of these steps fail, then the Cancel method MUST be called.
+type InteractiveIntent struct {
+	FullIntent
+
+	// session is the interactive construction of the funding
+	// transaction.
+	session *InteractiveTx
+
+	// prevTxs maps the outpoints of our selected coins to the
+	// transactions that created them. The remote party needs them to
+	// verify the value and script of our inputs.
+	prevTxs map[wire.OutPoint]*wire.MsgTx
+
+	// fundingTx is the funding transaction compiled from the session.
+	fundingTx *wire.MsgTx
+}
+
+// Session returns the interactive construction of the funding transaction.
+func (i *InteractiveIntent) Session() *InteractiveTx {
+	return i.session
+}
+
+// SetRemoteFundingAmt sets the amount the remote party contributes to the
+// funding output. This is only known to the initiator once the remote party
+// accepted the channel.
+func (i *InteractiveIntent) SetRemoteFundingAmt(amt bronutil.Amount) {
+	i.remoteFundingAmt = amt
+}
+
+// AddLocalContribution adds our coins and change outputs to the session. If
+// we're the initiator, the funding output is added as well. This must be
+// called after BindKeys.
+func (i *InteractiveIntent) AddLocalContribution() error {
+	if err := i.AddLocalInputs(); err != nil {
+		return err
+	}
+
+	if !i.session.Initiator() {
+		return nil
+	}
+
+	_, fundingOutput, err := i.FundingOutput()
+	if err != nil {
+		return err
+	}
+	_, err = i.session.AddLocalOutput(fundingOutput)
+
+	return err
+}
+
+// AddLocalInputs adds our coins and change outputs to the session, without
+// the funding output. This is used when splicing an existing channel, where
+// the new funding output is added by the caller.
+func (i *InteractiveIntent) AddLocalInputs() error {
+	for _, coin := range i.InputCoins {
+		prevTx, ok := i.prevTxs[coin.OutPoint]
+		if !ok {
+			return fmt.Errorf("missing previous transaction for "+
+				"input %v", coin.OutPoint)
+		}
+
+		_, err := i.session.AddLocalInput(prevTx, coin.OutPoint.Index)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, changeOutput := range i.ChangeOutputs {
+		if _, err := i.session.AddLocalOutput(changeOutput); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SignLocalInputs signs the inputs of the passed transaction, which must
+// have been built from the session, that spend our coins. The shared input
+// of a splice is left unsigned.
+func (i *InteractiveIntent) SignLocalInputs(tx *wire.MsgTx) error {
+	inputs := i.session.Inputs()
+	if len(inputs) != len(tx.TxIn) {
+		return fmt.Errorf("expected %v inputs, got %v", len(inputs),
+			len(tx.TxIn))
+	}
+
+	return i.signInputs(tx, func(idx int) bool {
+		return inputs[idx].Local && !inputs[idx].IsShared()
+	})
+}
+
+// CompileFundingTx builds the funding transaction from the completed session
+// and signs all inputs we contributed. The remote party's inputs are left
+// unsigned until AddRemoteWitnesses is called.
+func (i *InteractiveIntent) CompileFundingTx() (*wire.MsgTx, error) {
+	_, fundingOutput, err := i.FundingOutput()
+	if err != nil {
+		return nil, err
+	}
+
+	fundingTx := i.session.Tx()
+	found, multiSigIndex := input.FindScriptOutputIndex(
+		fundingTx, fundingOutput.PkScript,
+	)
+	if !found {
+		return nil, errors.New("funding output not found in " +
+			"interactive tx")
+	}
+	if fundingTx.TxOut[multiSigIndex].Value != fundingOutput.Value {
+		return nil, fmt.Errorf("funding output has value %v, "+
+			"expected %v", fundingTx.TxOut[multiSigIndex].Value,
+			fundingOutput.Value)
+	}
+
+	// The transaction must not spend more than its inputs are worth.
+	var inputAmt, outputAmt int64
+	inputs := i.session.Inputs()
+	for _, in := range inputs {
+		inputAmt += in.PrevOut().Value
+	}
+	for _, out := range fundingTx.TxOut {
+		outputAmt += out.Value
+	}
+	if outputAmt > inputAmt {
+		return nil, fmt.Errorf("interactive tx outputs (%v) exceed "+
+			"its inputs (%v)", outputAmt, inputAmt)
+	}
+
+	// Only sign the inputs we added ourselves. The remote party could
+	// otherwise trick us into signing a coin of ours we never meant to
+	// contribute.
+	err = i.signInputs(fundingTx, func(idx int) bool {
+		return inputs[idx].Local
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	i.fundingTx = fundingTx
+	i.chanPoint = &wire.OutPoint{
+		Hash:  fundingTx.TxHash(),
+		Index: multiSigIndex,
+	}
+
+	return fundingTx, nil
+}
+
+// LocalWitnesses returns the witnesses of our inputs, ordered by their serial
+// IDs. They are only available after CompileFundingTx has been called.
+func (i *InteractiveIntent) LocalWitnesses() []wire.TxWitness {
+	var witnesses []wire.TxWitness
+	for idx, in := range i.session.Inputs() {
+		if in.Local {
+			witnesses = append(witnesses, i.fundingTx.TxIn[idx].Witness)
+		}
+	}
+
+	return witnesses
+}
+
+// AddRemoteWitnesses attaches the remote party's witnesses, ordered by the
+// serial IDs of its inputs, to the funding transaction, and verifies them.
+// The fully signed funding transaction is returned.
+func (i *InteractiveIntent) AddRemoteWitnesses(
+	witnesses []wire.TxWitness) (*wire.MsgTx, error) {
+
+	if i.fundingTx == nil {
+		return nil, errors.New("funding tx not compiled yet")
+	}
+
+	inputs := i.session.Inputs()
+	var remoteIdxs []int
+	for idx, in := range inputs {
+		if !in.Local {
+			remoteIdxs = append(remoteIdxs, idx)
+		}
+	}
+	if len(remoteIdxs) != len(witnesses) {
+		return nil, fmt.Errorf("expected %v witnesses, got %v",
+			len(remoteIdxs), len(witnesses))
+	}
+
+	fundingTx := i.fundingTx.Copy()
+	for j, idx := range remoteIdxs {
+		fundingTx.TxIn[idx].Witness = witnesses[j]
+	}
+
+	hashCache := txscript.NewTxSigHashes(fundingTx)
+	for _, idx := range remoteIdxs {
+		err := verifyWitness(
+			fundingTx, idx, inputs[idx].PrevOut(), hashCache,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("invalid witness for input "+
+				"%d: %v", idx, err)
+		}
+	}
+
+	i.fundingTx = fundingTx
+
+	return fundingTx, nil
+}
+
+// A compile-time check to ensure InteractiveIntent meets the Intent
+// interface.
+var _ Intent = (*InteractiveIntent)(nil)
+
+// InteractiveConfig is the main config of the InteractiveAssembler.
+type InteractiveConfig struct {
+	WalletConfig
+
+	// Initiator should be set if we initiate the construction of the
+	// funding transaction, which is the case if we open the channel.
+	Initiator bool
+
+	// LockTime is the lock time of the funding transaction, as set by the
+	// initiator.
+	LockTime uint32
+
+	// FetchPrevTx returns the transaction that created the given output
+	// of our wallet.
+	FetchPrevTx func(wire.OutPoint) (*wire.MsgTx, error)
+}
+
+// InteractiveAssembler is an instance of the Assembler interface that
+// produces intents for dual funded channels. It selects our coins from the
+// internal wallet by reusing the WalletAssembler, and hands out an intent that
+// builds the funding transaction together with the remote party.
+type InteractiveAssembler struct {
+	cfg    InteractiveConfig
+	wallet *WalletAssembler
+}
+
+// NewInteractiveAssembler creates a new instance of the InteractiveAssembler
+// from a fully populated config.
+func NewInteractiveAssembler(cfg InteractiveConfig) *InteractiveAssembler {
+	return &InteractiveAssembler{
+		cfg:    cfg,
+		wallet: NewWalletAssembler(cfg.WalletConfig),
+	}
+}
+
+// ProvisionChannel selects the coins we contribute to the channel and
+// returns an InteractiveIntent that allows the caller to construct the
+// funding transaction with the remote party.
+//
+// NOTE: To cancel the funding flow the Cancel() method on the returned Intent,
+// MUST be called.
+//
+// NOTE: This is a part of the chanfunding.Assembler interface.
+func (i *InteractiveAssembler) ProvisionChannel(r *Request) (Intent, error) {
+	intent, err := i.wallet.ProvisionChannel(r)
+	if err != nil {
+		return nil, err
+	}
+	fullIntent := intent.(*FullIntent)
+
+	prevTxs := make(map[wire.OutPoint]*wire.MsgTx)
+	for _, coin := range fullIntent.InputCoins {
+		prevTx, err := i.cfg.FetchPrevTx(coin.OutPoint)
+		if err != nil {
+			fullIntent.Cancel()
+			return nil, fmt.Errorf("unable to fetch previous "+
+				"transaction of %v: %v", coin.OutPoint, err)
+		}
+		prevTxs[coin.OutPoint] = prevTx
+	}
+
+	return &InteractiveIntent{
+		FullIntent: *fullIntent,
+		session: NewInteractiveTx(
+			i.cfg.Initiator, i.cfg.LockTime, i.cfg.DustLimit,
+		),
+		prevTxs: prevTxs,
+	}, nil
+}
+
+// FundingTxAvailable is an empty method that an assembler can implement to
+// signal to callers that its able to provide the funding transaction for the
+// channel via the intent it returns.
+//
+// NOTE: This method is a part of the FundingTxAssembler interface.
+func (i *InteractiveAssembler) FundingTxAvailable() {}
+
+// A compile-time assertion to ensure the InteractiveAssembler meets the
+// FundingTxAssembler interface.
+var _ FundingTxAssembler = (*InteractiveAssembler)(nil)
@@ -0,0 +1,652 @@
+package chanfunding
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/brsuite/brond/chaincfg/chainhash"
+	"github.com/brsuite/brond/txscript"
+	"github.com/brsuite/brond/wire"
+	"github.com/brsuite/bronutil"
+	"github.com/brsuite/bronutil/psbt"
+	"github.com/brsuite/broln/input"
+	"github.com/brsuite/broln/lnwallet/chainfee"
+)
+
+const (
+	// MaxInteractiveInputs is the maximum number of inputs each party may
+	// add to an interactively constructed transaction.
+	MaxInteractiveInputs = 252
+
+	// MaxInteractiveOutputs is the maximum number of outputs each party
+	// may add to an interactively constructed transaction.
+	MaxInteractiveOutputs = 252
+
+	// MaxInteractiveMessages is the maximum number of tx_add_* and
+	// tx_remove_* messages we accept from the remote party before failing
+	// the construction.
+	MaxInteractiveMessages = 4096
+
+	// maxInteractiveSequence is the largest sequence number an input of
+	// an interactively constructed transaction may have. Inputs must
+	// signal replaceability so the transaction can be fee bumped.
+	maxInteractiveSequence = wire.MaxTxInSequenceNum - 2
+)
+
+var (
+	// ErrWrongSerialIDParity is returned when the remote party adds an
+	// input or output using a serial ID with the parity reserved for us.
+	ErrWrongSerialIDParity = errors.New("serial id has wrong parity")
+
+	// ErrDuplicateSerialID is returned when the remote party adds an
+	// input or output with a serial ID that's already in use.
+	ErrDuplicateSerialID = errors.New("duplicate serial id")
+
+	// ErrUnknownSerialID is returned when the remote party tries to
+	// remove an input or output that doesn't exist or wasn't added by
+	// it.
+	ErrUnknownSerialID = errors.New("unknown serial id")
+
+	// ErrDuplicateInput is returned when an outpoint is spent twice in
+	// the transaction.
+	ErrDuplicateInput = errors.New("outpoint spent twice")
+
+	// ErrTooManyInputs is returned when a party adds more than
+	// MaxInteractiveInputs inputs.
+	ErrTooManyInputs = errors.New("too many inputs")
+
+	// ErrTooManyOutputs is returned when a party adds more than
+	// MaxInteractiveOutputs outputs.
+	ErrTooManyOutputs = errors.New("too many outputs")
+
+	// ErrTooManyMessages is returned once the remote party sent more than
+	// MaxInteractiveMessages messages.
+	ErrTooManyMessages = errors.New("too many interactive tx messages")
+
+	// ErrDustOutput is returned when an output below the dust limit is
+	// added.
+	ErrDustOutput = errors.New("output below dust limit")
+
+	// ErrNonReplaceableInput is returned when an input doesn't signal
+	// replaceability.
+	ErrNonReplaceableInput = errors.New("input doesn't signal rbf")
+
+	// ErrUnknownSharedInput is returned when the remote party adds a
+	// shared input that isn't the funding output of the channel being
+	// spliced.
+	ErrUnknownSharedInput = errors.New("unknown shared input")
+
+	// ErrInsufficientContribution is returned when the inputs of the
+	// remote party don't cover its outputs, its share of the funding
+	// output and the fees for its part of the transaction.
+	ErrInsufficientContribution = errors.New("remote contribution doesn't " +
+		"cover its outputs and fees")
+)
+
+// InteractiveInput is an input of an interactively constructed transaction.
+type InteractiveInput struct {
+	// SerialID is the serial ID the input was added with.
+	SerialID uint64
+
+	// PrevTx is the transaction that contains the spent output. It is nil
+	// for the shared input of a splice.
+	PrevTx *wire.MsgTx
+
+	// PrevTxOut is the index of the spent output within PrevTx.
+	PrevTxOut uint32
+
+	// Sequence is the sequence number of the input.
+	Sequence uint32
+
+	// Local is true if the input was added by us.
+	Local bool
+
+	// SharedOutPoint is the funding outpoint of the channel being spliced
+	// if this is the shared input of a splice.
+	SharedOutPoint *wire.OutPoint
+
+	// SharedPrevOut is the funding output of the channel being spliced if
+	// this is the shared input of a splice.
+	SharedPrevOut *wire.TxOut
+}
+
+// IsShared returns true if the input spends the funding output of the
+// channel being spliced, which requires the signatures of both parties.
+func (i *InteractiveInput) IsShared() bool {
+	return i.SharedOutPoint != nil
+}
+
+// OutPoint returns the outpoint spent by the input.
+func (i *InteractiveInput) OutPoint() wire.OutPoint {
+	if i.IsShared() {
+		return *i.SharedOutPoint
+	}
+
+	return wire.OutPoint{
+		Hash:  i.PrevTx.TxHash(),
+		Index: i.PrevTxOut,
+	}
+}
+
+// PrevOut returns the output spent by the input.
+func (i *InteractiveInput) PrevOut() *wire.TxOut {
+	if i.IsShared() {
+		return i.SharedPrevOut
+	}
+
+	return i.PrevTx.TxOut[i.PrevTxOut]
+}
+
+// InteractiveOutput is an output of an interactively constructed
+// transaction.
+type InteractiveOutput struct {
+	// SerialID is the serial ID the output was added with.
+	SerialID uint64
+
+	// TxOut is the output itself.
+	TxOut *wire.TxOut
+
+	// Local is true if the output was added by us.
+	Local bool
+}
+
+// InteractiveTx tracks the state of a transaction that is constructed
+// interactively by two parties, as done for dual funded channels. Both
+// parties add inputs and outputs identified by serial IDs, which also
+// determine their position in the final transaction. The initiator of the
+// construction uses even serial IDs, the other party odd ones.
+//
+// NOTE: InteractiveTx is not safe for concurrent use.
+type InteractiveTx struct {
+	initiator bool
+	lockTime  uint32
+	dustLimit bronutil.Amount
+
+	inputs  map[uint64]*InteractiveInput
+	outputs map[uint64]*InteractiveOutput
+
+	// sharedOutPoint and sharedPrevOut are the funding outpoint and
+	// output of the channel being spliced, if any.
+	sharedOutPoint *wire.OutPoint
+	sharedPrevOut  *wire.TxOut
+
+	nextSerialID  uint64
+	numRemoteMsgs int
+}
+
+// NewInteractiveTx creates a new interactive transaction construction
+// session. The lock time is set by the initiator of the construction.
+func NewInteractiveTx(initiator bool, lockTime uint32,
+	dustLimit bronutil.Amount) *InteractiveTx {
+
+	nextSerialID := uint64(1)
+	if initiator {
+		nextSerialID = 0
+	}
+
+	return &InteractiveTx{
+		initiator:    initiator,
+		lockTime:     lockTime,
+		dustLimit:    dustLimit,
+		inputs:       make(map[uint64]*InteractiveInput),
+		outputs:      make(map[uint64]*InteractiveOutput),
+		nextSerialID: nextSerialID,
+	}
+}
+
+// Initiator returns true if we initiated the construction.
+func (t *InteractiveTx) Initiator() bool {
+	return t.initiator
+}
+
+// LockTime returns the lock time of the constructed transaction.
+func (t *InteractiveTx) LockTime() uint32 {
+	return t.lockTime
+}
+
+// SetSharedInput marks the session as the construction of a splice
+// transaction that spends the given funding output of an existing channel.
+// The shared input can then be added by the initiator of the splice.
+func (t *InteractiveTx) SetSharedInput(outPoint wire.OutPoint,
+	prevOut *wire.TxOut) {
+
+	t.sharedOutPoint = &outPoint
+	t.sharedPrevOut = prevOut
+}
+
+// SharedInput returns the shared input of a splice, or nil if it hasn't been
+// added.
+func (t *InteractiveTx) SharedInput() *InteractiveInput {
+	for _, in := range t.inputs {
+		if in.IsShared() {
+			return in
+		}
+	}
+
+	return nil
+}
+
+// isLocalSerialID returns true if the serial ID has the parity reserved for
+// our inputs and outputs.
+func (t *InteractiveTx) isLocalSerialID(serialID uint64) bool {
+	return (serialID%2 == 0) == t.initiator
+}
+
+// countInputs returns the number of inputs added by either us or the remote
+// party.
+func (t *InteractiveTx) countInputs(local bool) int {
+	var n int
+	for _, in := range t.inputs {
+		if in.Local == local {
+			n++
+		}
+	}
+
+	return n
+}
+
+// countOutputs returns the number of outputs added by either us or the
+// remote party.
+func (t *InteractiveTx) countOutputs(local bool) int {
+	var n int
+	for _, out := range t.outputs {
+		if out.Local == local {
+			n++
+		}
+	}
+
+	return n
+}
+
+// addInput validates and adds a new input to the transaction.
+func (t *InteractiveTx) addInput(in *InteractiveInput) error {
+	if _, ok := t.inputs[in.SerialID]; ok {
+		return ErrDuplicateSerialID
+	}
+	if t.countInputs(in.Local) >= MaxInteractiveInputs {
+		return ErrTooManyInputs
+	}
+	if !in.IsShared() &&
+		(in.PrevTx == nil || int(in.PrevTxOut) >= len(in.PrevTx.TxOut)) {
+
+		return fmt.Errorf("input %d spends unknown output",
+			in.SerialID)
+	}
+	if in.Sequence > maxInteractiveSequence {
+		return ErrNonReplaceableInput
+	}
+
+	outPoint := in.OutPoint()
+	for _, other := range t.inputs {
+		if other.OutPoint() == outPoint {
+			return ErrDuplicateInput
+		}
+	}
+
+	// Only SegWit inputs are allowed, as otherwise the txid of the
+	// transaction could be malleated before it confirms. The shared input
+	// always spends a P2WSH funding output.
+	if !in.IsShared() {
+		txIns := []*wire.TxIn{{PreviousOutPoint: outPoint}}
+		pIns := []psbt.PInput{{NonWitnessUtxo: in.PrevTx}}
+		err := verifyAllInputsSegWit(txIns, pIns)
+		if err != nil {
+			return err
+		}
+	}
+
+	t.inputs[in.SerialID] = in
+
+	return nil
+}
+
+// addOutput validates and adds a new output to the transaction.
+func (t *InteractiveTx) addOutput(out *InteractiveOutput) error {
+	if _, ok := t.outputs[out.SerialID]; ok {
+		return ErrDuplicateSerialID
+	}
+	if t.countOutputs(out.Local) >= MaxInteractiveOutputs {
+		return ErrTooManyOutputs
+	}
+	if bronutil.Amount(out.TxOut.Value) < t.dustLimit {
+		return ErrDustOutput
+	}
+
+	t.outputs[out.SerialID] = out
+
+	return nil
+}
+
+// AddLocalInput adds one of our inputs to the transaction, returning the
+// serial ID it was assigned.
+func (t *InteractiveTx) AddLocalInput(prevTx *wire.MsgTx,
+	prevTxOut uint32) (*InteractiveInput, error) {
+
+	in := &InteractiveInput{
+		SerialID:  t.nextSerialID,
+		PrevTx:    prevTx,
+		PrevTxOut: prevTxOut,
+		Sequence:  maxInteractiveSequence,
+		Local:     true,
+	}
+	if err := t.addInput(in); err != nil {
+		return nil, err
+	}
+
+	t.nextSerialID += 2
+
+	return in, nil
+}
+
+// AddLocalSharedInput adds the funding output of the channel being spliced
+// to the transaction, returning the input it was assigned. SetSharedInput
+// must have been called before.
+func (t *InteractiveTx) AddLocalSharedInput() (*InteractiveInput, error) {
+	if t.sharedOutPoint == nil {
+		return nil, ErrUnknownSharedInput
+	}
+
+	in := &InteractiveInput{
+		SerialID:       t.nextSerialID,
+		Sequence:       maxInteractiveSequence,
+		Local:          true,
+		SharedOutPoint: t.sharedOutPoint,
+		SharedPrevOut:  t.sharedPrevOut,
+	}
+	if err := t.addInput(in); err != nil {
+		return nil, err
+	}
+
+	t.nextSerialID += 2
+
+	return in, nil
+}
+
+// AddLocalOutput adds one of our outputs to the transaction, returning the
+// serial ID it was assigned.
+func (t *InteractiveTx) AddLocalOutput(
+	txOut *wire.TxOut) (*InteractiveOutput, error) {
+
+	out := &InteractiveOutput{
+		SerialID: t.nextSerialID,
+		TxOut:    txOut,
+		Local:    true,
+	}
+	if err := t.addOutput(out); err != nil {
+		return nil, err
+	}
+
+	t.nextSerialID += 2
+
+	return out, nil
+}
+
+// countRemoteMsg accounts for a new message received from the remote party.
+func (t *InteractiveTx) countRemoteMsg() error {
+	t.numRemoteMsgs++
+	if t.numRemoteMsgs > MaxInteractiveMessages {
+		return ErrTooManyMessages
+	}
+
+	return nil
+}
+
+// AddRemoteInput adds an input sent by the remote party to the transaction.
+func (t *InteractiveTx) AddRemoteInput(serialID uint64, prevTx *wire.MsgTx,
+	prevTxOut, sequence uint32) error {
+
+	if err := t.countRemoteMsg(); err != nil {
+		return err
+	}
+	if t.isLocalSerialID(serialID) {
+		return ErrWrongSerialIDParity
+	}
+
+	return t.addInput(&InteractiveInput{
+		SerialID:  serialID,
+		PrevTx:    prevTx,
+		PrevTxOut: prevTxOut,
+		Sequence:  sequence,
+	})
+}
+
+// AddRemoteSharedInput adds the funding output of the channel being spliced
+// to the transaction, as sent by the remote party. The remote party only
+// sends the txid of the funding transaction, which must match the one passed
+// to SetSharedInput.
+func (t *InteractiveTx) AddRemoteSharedInput(serialID uint64,
+	txid chainhash.Hash, prevTxOut, sequence uint32) error {
+
+	if err := t.countRemoteMsg(); err != nil {
+		return err
+	}
+	if t.isLocalSerialID(serialID) {
+		return ErrWrongSerialIDParity
+	}
+
+	outPoint := wire.OutPoint{Hash: txid, Index: prevTxOut}
+	if t.sharedOutPoint == nil || *t.sharedOutPoint != outPoint {
+		return fmt.Errorf("%w: %v", ErrUnknownSharedInput, outPoint)
+	}
+
+	return t.addInput(&InteractiveInput{
+		SerialID:       serialID,
+		Sequence:       sequence,
+		SharedOutPoint: t.sharedOutPoint,
+		SharedPrevOut:  t.sharedPrevOut,
+	})
+}
+
+// AddRemoteOutput adds an output sent by the remote party to the
+// transaction.
+func (t *InteractiveTx) AddRemoteOutput(serialID uint64,
+	amt bronutil.Amount, pkScript []byte) error {
+
+	if err := t.countRemoteMsg(); err != nil {
+		return err
+	}
+	if t.isLocalSerialID(serialID) {
+		return ErrWrongSerialIDParity
+	}
+
+	return t.addOutput(&InteractiveOutput{
+		SerialID: serialID,
+		TxOut:    wire.NewTxOut(int64(amt), pkScript),
+	})
+}
+
+// RemoveRemoteInput removes an input previously added by the remote party.
+func (t *InteractiveTx) RemoveRemoteInput(serialID uint64) error {
+	if err := t.countRemoteMsg(); err != nil {
+		return err
+	}
+
+	in, ok := t.inputs[serialID]
+	if !ok || in.Local {
+		return ErrUnknownSerialID
+	}
+	delete(t.inputs, serialID)
+
+	return nil
+}
+
+// RemoveRemoteOutput removes an output previously added by the remote
+// party.
+func (t *InteractiveTx) RemoveRemoteOutput(serialID uint64) error {
+	if err := t.countRemoteMsg(); err != nil {
+		return err
+	}
+
+	out, ok := t.outputs[serialID]
+	if !ok || out.Local {
+		return ErrUnknownSerialID
+	}
+	delete(t.outputs, serialID)
+
+	return nil
+}
+
+// Inputs returns all inputs of the transaction ordered by serial ID.
+func (t *InteractiveTx) Inputs() []*InteractiveInput {
+	inputs := make([]*InteractiveInput, 0, len(t.inputs))
+	for _, in := range t.inputs {
+		inputs = append(inputs, in)
+	}
+	sort.Slice(inputs, func(i, j int) bool {
+		return inputs[i].SerialID < inputs[j].SerialID
+	})
+
+	return inputs
+}
+
+// Outputs returns all outputs of the transaction ordered by serial ID.
+func (t *InteractiveTx) Outputs() []*InteractiveOutput {
+	outputs := make([]*InteractiveOutput, 0, len(t.outputs))
+	for _, out := range t.outputs {
+		outputs = append(outputs, out)
+	}
+	sort.Slice(outputs, func(i, j int) bool {
+		return outputs[i].SerialID < outputs[j].SerialID
+	})
+
+	return outputs
+}
+
+// InputAmount returns the total value of the inputs added by either us or
+// the remote party.
+func (t *InteractiveTx) InputAmount(local bool) bronutil.Amount {
+	var amt bronutil.Amount
+	for _, in := range t.inputs {
+		if in.Local == local {
+			amt += bronutil.Amount(in.PrevOut().Value)
+		}
+	}
+
+	return amt
+}
+
+// Tx returns the unsigned transaction constructed so far, with its inputs
+// and outputs ordered by serial ID.
+func (t *InteractiveTx) Tx() *wire.MsgTx {
+	tx := wire.NewMsgTx(2)
+	tx.LockTime = t.lockTime
+	for _, in := range t.Inputs() {
+		tx.AddTxIn(&wire.TxIn{
+			PreviousOutPoint: in.OutPoint(),
+			Sequence:         in.Sequence,
+		})
+	}
+	for _, out := range t.Outputs() {
+		tx.AddTxOut(out.TxOut)
+	}
+
+	return tx
+}
+
+// VerifyRemoteContribution checks that the inputs of the remote party cover
+// the outputs it added, its share of the funding output and the fees for its
+// inputs and outputs at the given fee rate. If the remote party initiated
+// the construction, it also pays for the common transaction fields and the
+// funding output, identified by its script.
+func (t *InteractiveTx) VerifyRemoteContribution(
+	feeRate chainfee.SatPerKWeight, fundingScript []byte,
+	remoteFundingAmt bronutil.Amount) error {
+
+	var (
+		base, weight input.TxWeightEstimator
+		remoteOut    bronutil.Amount
+	)
+	for _, in := range t.inputs {
+		if in.Local {
+			continue
+		}
+
+		// The witness of the shared input is a 2-of-2 multisig
+		// spend.
+		if in.IsShared() {
+			weight.AddWitnessInput(input.MultiSigWitnessSize)
+			continue
+		}
+
+		// We can't know the size of the witness of the remote inputs
+		// in advance, so we'll assume the smallest witness we expect
+		// to see, a P2WKH spend.
+		weight.AddWitnessInput(input.P2WKHWitnessSize)
+	}
+
+	var sawFundingOutput bool
+	for _, out := range t.outputs {
+		if out.Local {
+			continue
+		}
+
+		weight.AddTxOutput(out.TxOut)
+
+		// The funding output itself doesn't count towards the
+		// outputs of the remote party, only its share does. It's
+		// always added by the initiator.
+		isFunding := !t.initiator &&
+			string(out.TxOut.PkScript) == string(fundingScript)
+		if isFunding && !sawFundingOutput {
+			sawFundingOutput = true
+			continue
+		}
+
+		remoteOut += bronutil.Amount(out.TxOut.Value)
+	}
+
+	// The weight of the common transaction fields is only paid by the
+	// initiator.
+	remoteWeight := int64(weight.Weight())
+	if t.initiator {
+		remoteWeight -= int64(base.Weight())
+	}
+	fee := feeRate.FeeForWeight(remoteWeight)
+
+	remoteIn := t.InputAmount(false)
+	if remoteIn < remoteOut+remoteFundingAmt+fee {
+		return fmt.Errorf("%w: inputs=%v, outputs=%v, funding=%v, "+
+			"fee=%v", ErrInsufficientContribution, remoteIn,
+			remoteOut, remoteFundingAmt, fee)
+	}
+
+	return nil
+}
+
+// VerifyWitnesses checks that the witnesses of all inputs of the passed
+// transaction, which must have been built from the session, are valid.
+func (t *InteractiveTx) VerifyWitnesses(tx *wire.MsgTx) error {
+	inputs := t.Inputs()
+	if len(inputs) != len(tx.TxIn) {
+		return fmt.Errorf("expected %v inputs, got %v", len(inputs),
+			len(tx.TxIn))
+	}
+
+	hashCache := txscript.NewTxSigHashes(tx)
+	for idx, in := range inputs {
+		err := verifyWitness(tx, idx, in.PrevOut(), hashCache)
+		if err != nil {
+			return fmt.Errorf("invalid witness for input %d: %v",
+				idx, err)
+		}
+	}
+
+	return nil
+}
+
+// verifyWitness checks that the witness of the input at the passed index is
+// valid.
+func verifyWitness(tx *wire.MsgTx, idx int, prevOut *wire.TxOut,
+	hashCache *txscript.TxSigHashes) error {
+
+	vm, err := txscript.NewEngine(
+		prevOut.PkScript, tx, idx, txscript.StandardVerifyFlags, nil,
+		hashCache, prevOut.Value,
+	)
+	if err != nil {
+		return err
+	}
+
+	return vm.Execute()
+}
@@ -0,0 +1,408 @@
+package chanfunding
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/brsuite/brond/btcec"
+	"github.com/brsuite/brond/chaincfg"
+	"github.com/brsuite/brond/chaincfg/chainhash"
+	"github.com/brsuite/brond/txscript"
+	"github.com/brsuite/brond/wire"
+	"github.com/brsuite/bronutil"
+	"github.com/brsuite/broln/input"
+	"github.com/brsuite/broln/keychain"
+	"github.com/brsuite/broln/lnwallet/chainfee"
+	"github.com/stretchr/testify/require"
+)
+
+// mockCoinSource is a CoinSource backed by a static set of coins.
+type mockCoinSource struct {
+	coins []Coin
+}
+
+func (m *mockCoinSource) ListCoins(_, _ int32) ([]Coin, error) {
+	return m.coins, nil
+}
+
+func (m *mockCoinSource) CoinFromOutPoint(op wire.OutPoint) (*Coin, error) {
+	for _, coin := range m.coins {
+		if coin.OutPoint == op {
+			coin := coin
+			return &coin, nil
+		}
+	}
+
+	return nil, errors.New("coin not found")
+}
+
+// mockCoinLocker implements the CoinSelectionLocker and OutpointLocker
+// interfaces without any actual locking.
+type mockCoinLocker struct{}
+
+func (m *mockCoinLocker) WithCoinSelectLock(f func() error) error {
+	return f()
+}
+
+func (m *mockCoinLocker) LockOutpoint(wire.OutPoint) {}
+
+func (m *mockCoinLocker) UnlockOutpoint(wire.OutPoint) {}
+
+// newP2WKHTx creates a transaction paying the given amount to a P2WKH output
+// of a freshly generated key.
+func newP2WKHTx(t *testing.T, amt int64) (*wire.MsgTx, *btcec.PrivateKey) {
+	t.Helper()
+
+	priv, err := btcec.NewPrivateKey(btcec.S256())
+	require.NoError(t, err)
+
+	pkScript, err := txscript.NewScriptBuilder().
+		AddOp(txscript.OP_0).
+		AddData(bronutil.Hash160(priv.PubKey().SerializeCompressed())).
+		Script()
+	require.NoError(t, err)
+
+	tx := wire.NewMsgTx(2)
+	tx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Hash: chainhash.Hash{byte(amt)}},
+	})
+	tx.AddTxOut(wire.NewTxOut(amt, pkScript))
+
+	return tx, priv
+}
+
+// TestInteractiveTxRules tests that the interactive transaction session
+// rejects invalid inputs and outputs added by the remote party.
+func TestInteractiveTxRules(t *testing.T) {
+	t.Parallel()
+
+	const dustLimit = 354
+	session := NewInteractiveTx(true, 100, dustLimit)
+
+	prevTx, _ := newP2WKHTx(t, 100_000)
+	seq := uint32(maxInteractiveSequence)
+
+	// We're the initiator, so the remote party must use odd serial IDs.
+	err := session.AddRemoteInput(2, prevTx, 0, seq)
+	require.ErrorIs(t, err, ErrWrongSerialIDParity)
+
+	require.NoError(t, session.AddRemoteInput(1, prevTx, 0, seq))
+
+	err = session.AddRemoteInput(1, prevTx, 0, seq)
+	require.ErrorIs(t, err, ErrDuplicateSerialID)
+
+	err = session.AddRemoteInput(3, prevTx, 0, seq)
+	require.ErrorIs(t, err, ErrDuplicateInput)
+
+	// Inputs spending an output that doesn't exist, or that don't signal
+	// replaceability are rejected.
+	otherTx, _ := newP2WKHTx(t, 200_000)
+	require.Error(t, session.AddRemoteInput(3, otherTx, 1, seq))
+
+	err = session.AddRemoteInput(3, otherTx, 0, wire.MaxTxInSequenceNum)
+	require.ErrorIs(t, err, ErrNonReplaceableInput)
+
+	// Non-SegWit inputs are rejected as well.
+	p2pkhTx := otherTx.Copy()
+	p2pkhTx.TxOut[0].PkScript = append(
+		[]byte{txscript.OP_DUP, txscript.OP_HASH160, 0x14},
+		make([]byte, 22)...,
+	)
+	require.Error(t, session.AddRemoteInput(3, p2pkhTx, 0, seq))
+
+	// Dust outputs are rejected.
+	pkScript := prevTx.TxOut[0].PkScript
+	err = session.AddRemoteOutput(5, dustLimit-1, pkScript)
+	require.ErrorIs(t, err, ErrDustOutput)
+	require.NoError(t, session.AddRemoteOutput(5, dustLimit, pkScript))
+
+	// The remote party can only remove its own inputs and outputs.
+	localIn, err := session.AddLocalInput(otherTx, 0)
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), localIn.SerialID)
+
+	err = session.RemoveRemoteInput(localIn.SerialID)
+	require.ErrorIs(t, err, ErrUnknownSerialID)
+	require.ErrorIs(t, session.RemoveRemoteOutput(7), ErrUnknownSerialID)
+
+	// The inputs of the transaction are ordered by serial ID.
+	tx := session.Tx()
+	require.Len(t, tx.TxIn, 2)
+	require.Equal(t, otherTx.TxHash(), tx.TxIn[0].PreviousOutPoint.Hash)
+	require.Equal(t, prevTx.TxHash(), tx.TxIn[1].PreviousOutPoint.Hash)
+	require.Equal(t, uint32(100), tx.LockTime)
+
+	require.NoError(t, session.RemoveRemoteInput(1))
+	require.NoError(t, session.RemoveRemoteOutput(5))
+	require.Len(t, session.Tx().TxIn, 1)
+	require.Len(t, session.Tx().TxOut, 0)
+
+	// Finally, the number of messages the remote party can send is
+	// bounded.
+	for i := session.numRemoteMsgs; i < MaxInteractiveMessages; i++ {
+		_ = session.RemoveRemoteOutput(5)
+	}
+	err = session.AddRemoteOutput(5, dustLimit, pkScript)
+	require.ErrorIs(t, err, ErrTooManyMessages)
+}
+
+// TestInteractiveTxVerifyRemoteContribution tests that we make sure the
+// remote party pays for its contribution to the funding transaction.
+func TestInteractiveTxVerifyRemoteContribution(t *testing.T) {
+	t.Parallel()
+
+	const feeRate = chainfee.SatPerKWeight(1000)
+
+	prevTx, _ := newP2WKHTx(t, 100_000)
+	pkScript := prevTx.TxOut[0].PkScript
+	fundingScript := append([]byte{0x00, 0x20}, make([]byte, 32)...)
+
+	// As the initiator, the remote party only needs to pay for its input
+	// and change output.
+	session := NewInteractiveTx(true, 0, 354)
+	seq := uint32(maxInteractiveSequence)
+	require.NoError(t, session.AddRemoteInput(1, prevTx, 0, seq))
+	require.NoError(t, session.AddRemoteOutput(3, 40_000, pkScript))
+
+	err := session.VerifyRemoteContribution(feeRate, fundingScript, 59_000)
+	require.NoError(t, err)
+
+	err = session.VerifyRemoteContribution(feeRate, fundingScript, 60_000)
+	require.ErrorIs(t, err, ErrInsufficientContribution)
+
+	// If the remote party is the initiator, the funding output isn't
+	// counted as one of its outputs, but it pays for the common fields.
+	session = NewInteractiveTx(false, 0, 354)
+	require.NoError(t, session.AddRemoteInput(0, prevTx, 0, seq))
+	require.NoError(t, session.AddRemoteOutput(2, 40_000, pkScript))
+	require.NoError(t, session.AddRemoteOutput(4, 90_000, fundingScript))
+
+	err = session.VerifyRemoteContribution(feeRate, fundingScript, 59_000)
+	require.NoError(t, err)
+
+	err = session.VerifyRemoteContribution(feeRate, fundingScript, 59_800)
+	require.ErrorIs(t, err, ErrInsufficientContribution)
+}
+
+// TestInteractiveTxSharedInput tests that the funding output of a channel
+// being spliced can be added as the shared input, and is accounted for as
+// part of the contribution of the splice initiator.
+func TestInteractiveTxSharedInput(t *testing.T) {
+	t.Parallel()
+
+	const feeRate = chainfee.SatPerKWeight(1000)
+
+	fundingScript := append([]byte{0x00, 0x20}, make([]byte, 32)...)
+	fundingPoint := wire.OutPoint{Hash: chainhash.Hash{0x01}, Index: 1}
+	fundingOut := wire.NewTxOut(100_000, fundingScript)
+	seq := uint32(maxInteractiveSequence)
+
+	// The shared input can't be added before it's known.
+	session := NewInteractiveTx(true, 0, 354)
+	_, err := session.AddLocalSharedInput()
+	require.ErrorIs(t, err, ErrUnknownSharedInput)
+
+	session.SetSharedInput(fundingPoint, fundingOut)
+	in, err := session.AddLocalSharedInput()
+	require.NoError(t, err)
+	require.True(t, in.IsShared())
+	require.Equal(t, fundingPoint, in.OutPoint())
+	require.Equal(t, fundingOut, in.PrevOut())
+	require.Equal(t, in, session.SharedInput())
+
+	_, err = session.AddLocalSharedInput()
+	require.ErrorIs(t, err, ErrDuplicateInput)
+
+	// The remote party may only add the funding output of the channel as
+	// shared input.
+	session = NewInteractiveTx(false, 0, 354)
+	session.SetSharedInput(fundingPoint, fundingOut)
+	err = session.AddRemoteSharedInput(0, chainhash.Hash{0x02}, 1, seq)
+	require.ErrorIs(t, err, ErrUnknownSharedInput)
+	err = session.AddRemoteSharedInput(0, fundingPoint.Hash, 0, seq)
+	require.ErrorIs(t, err, ErrUnknownSharedInput)
+
+	err = session.AddRemoteSharedInput(
+		0, fundingPoint.Hash, fundingPoint.Index, seq,
+	)
+	require.NoError(t, err)
+	require.Equal(t, fundingPoint, session.Tx().TxIn[0].PreviousOutPoint)
+
+	// Splicing out 40k sat, the initiator pays the fees from the channel
+	// balance.
+	prevTx, _ := newP2WKHTx(t, 1)
+	pkScript := prevTx.TxOut[0].PkScript
+	require.NoError(t, session.AddRemoteOutput(2, 40_000, pkScript))
+	require.NoError(t, session.AddRemoteOutput(4, 59_000, fundingScript))
+
+	err = session.VerifyRemoteContribution(feeRate, fundingScript, 59_000)
+	require.NoError(t, err)
+
+	err = session.VerifyRemoteContribution(feeRate, fundingScript, 59_800)
+	require.ErrorIs(t, err, ErrInsufficientContribution)
+}
+
+// interactiveParty bundles the state of one of the parties of an interactive
+// funding flow in tests.
+type interactiveParty struct {
+	intent *InteractiveIntent
+	key    *keychain.KeyDescriptor
+}
+
+// newInteractiveParty provisions a new interactive intent backed by a single
+// coin.
+func newInteractiveParty(t *testing.T, initiator bool, localAmt,
+	remoteAmt bronutil.Amount) *interactiveParty {
+
+	t.Helper()
+
+	prevTx, priv := newP2WKHTx(t, 1_000_000)
+	coin := Coin{
+		TxOut:    *prevTx.TxOut[0],
+		OutPoint: wire.OutPoint{Hash: prevTx.TxHash()},
+	}
+
+	multiSigPriv, err := btcec.NewPrivateKey(btcec.S256())
+	require.NoError(t, err)
+
+	locker := &mockCoinLocker{}
+	assembler := NewInteractiveAssembler(InteractiveConfig{
+		WalletConfig: WalletConfig{
+			CoinSource:       &mockCoinSource{coins: []Coin{coin}},
+			CoinSelectLocker: locker,
+			CoinLocker:       locker,
+			Signer: &input.MockSigner{
+				Privkeys:  []*btcec.PrivateKey{priv},
+				NetParams: &chaincfg.RegressionNetParams,
+			},
+			DustLimit: 354,
+		},
+		Initiator: initiator,
+		LockTime:  100,
+		FetchPrevTx: func(op wire.OutPoint) (*wire.MsgTx, error) {
+			require.Equal(t, coin.OutPoint, op)
+			return prevTx, nil
+		},
+	})
+
+	intent, err := assembler.ProvisionChannel(&Request{
+		LocalAmt:  localAmt,
+		RemoteAmt: remoteAmt,
+		FeeRate:   1000,
+		ChangeAddr: func() (bronutil.Address, error) {
+			return bronutil.NewAddressWitnessPubKeyHash(
+				make([]byte, 20), &chaincfg.RegressionNetParams,
+			)
+		},
+	})
+	require.NoError(t, err)
+
+	return &interactiveParty{
+		intent: intent.(*InteractiveIntent),
+		key:    &keychain.KeyDescriptor{PubKey: multiSigPriv.PubKey()},
+	}
+}
+
+// sendContribution adds the local inputs and outputs of one party to the
+// session of the other.
+func sendContribution(t *testing.T, from, to *interactiveParty) {
+	t.Helper()
+
+	for _, in := range from.intent.Session().Inputs() {
+		if !in.Local {
+			continue
+		}
+		err := to.intent.Session().AddRemoteInput(
+			in.SerialID, in.PrevTx, in.PrevTxOut, in.Sequence,
+		)
+		require.NoError(t, err)
+	}
+	for _, out := range from.intent.Session().Outputs() {
+		if !out.Local {
+			continue
+		}
+		err := to.intent.Session().AddRemoteOutput(
+			out.SerialID, bronutil.Amount(out.TxOut.Value),
+			out.TxOut.PkScript,
+		)
+		require.NoError(t, err)
+	}
+}
+
+// TestInteractiveIntent tests the happy path of constructing a dual funded
+// channel's funding transaction between two interactive intents.
+func TestInteractiveIntent(t *testing.T) {
+	t.Parallel()
+
+	const (
+		aliceAmt = bronutil.Amount(500_000)
+		bobAmt   = bronutil.Amount(300_000)
+	)
+
+	// The initiator doesn't know the remote contribution when
+	// provisioning the channel.
+	alice := newInteractiveParty(t, true, aliceAmt, 0)
+	bob := newInteractiveParty(t, false, bobAmt, aliceAmt)
+	alice.intent.SetRemoteFundingAmt(bobAmt)
+
+	alice.intent.BindKeys(alice.key, bob.key.PubKey)
+	bob.intent.BindKeys(bob.key, alice.key.PubKey)
+
+	require.NoError(t, alice.intent.AddLocalContribution())
+	require.NoError(t, bob.intent.AddLocalContribution())
+
+	sendContribution(t, alice, bob)
+	sendContribution(t, bob, alice)
+
+	// Both parties must pay for their contribution.
+	fundingScript, fundingOutput, err := alice.intent.FundingOutput()
+	require.NoError(t, err)
+	require.Equal(t, int64(aliceAmt+bobAmt), fundingOutput.Value)
+
+	err = alice.intent.Session().VerifyRemoteContribution(
+		1000, fundingOutput.PkScript, bobAmt,
+	)
+	require.NoError(t, err)
+	err = bob.intent.Session().VerifyRemoteContribution(
+		1000, fundingOutput.PkScript, aliceAmt,
+	)
+	require.NoError(t, err)
+	require.NotEmpty(t, fundingScript)
+
+	// Both parties should arrive at the same funding transaction.
+	aliceTx, err := alice.intent.CompileFundingTx()
+	require.NoError(t, err)
+	bobTx, err := bob.intent.CompileFundingTx()
+	require.NoError(t, err)
+	require.Equal(t, aliceTx.TxHash(), bobTx.TxHash())
+
+	aliceChanPoint, err := alice.intent.ChanPoint()
+	require.NoError(t, err)
+	bobChanPoint, err := bob.intent.ChanPoint()
+	require.NoError(t, err)
+	require.Equal(t, aliceChanPoint, bobChanPoint)
+
+	// Invalid witnesses are rejected.
+	_, err = alice.intent.AddRemoteWitnesses(alice.intent.LocalWitnesses())
+	require.Error(t, err)
+	_, err = alice.intent.AddRemoteWitnesses(nil)
+	require.Error(t, err)
+
+	// After exchanging the witnesses, both parties should have the same,
+	// fully signed transaction.
+	aliceTx, err = alice.intent.AddRemoteWitnesses(
+		bob.intent.LocalWitnesses(),
+	)
+	require.NoError(t, err)
+	bobTx, err = bob.intent.AddRemoteWitnesses(
+		alice.intent.LocalWitnesses(),
+	)
+	require.NoError(t, err)
+	require.Equal(t, aliceTx, bobTx)
+
+	hashCache := txscript.NewTxSigHashes(aliceTx)
+	for idx, in := range alice.intent.Session().Inputs() {
+		err := verifyWitness(aliceTx, idx, in.PrevOut(), hashCache)
+		require.NoError(t, err)
+	}
+}
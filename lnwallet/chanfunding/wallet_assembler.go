@@ -107,11 +107,33 @@ func (f *FullIntent) CompileFundingTx(extraInputs []*wire.TxIn,
 
 	// Next, sign all inputs that are ours, collecting the signatures in
 	// order of the inputs.
+	if err := f.signInputs(fundingTx, nil); err != nil {
+		return nil, err
+	}
+
+	// Finally, we'll populate the chanPoint now that we've fully
+	// constructed the funding transaction.
+	f.chanPoint = &wire.OutPoint{
+		Hash:  fundingTx.TxHash(),
+		Index: multiSigIndex,
+	}
+
+	return fundingTx, nil
+}
+
+// signInputs signs all inputs of the passed transaction that are known by the
+// backing CoinSource, populating their witness and sigScript (if needed). If
+// a filter is passed, only the inputs at indexes it accepts are signed.
+func (f *FullIntent) signInputs(tx *wire.MsgTx, filter func(int) bool) error {
 	signDesc := input.SignDescriptor{
 		HashType:  txscript.SigHashAll,
-		SigHashes: txscript.NewTxSigHashes(fundingTx),
+		SigHashes: txscript.NewTxSigHashes(tx),
 	}
-	for i, txIn := range fundingTx.TxIn {
+	for i, txIn := range tx.TxIn {
+		if filter != nil && !filter(i) {
+			continue
+		}
+
 		// We can only sign this input if it's ours, so we'll ask the
 		// coin source if it can map this outpoint into a coin we own.
 		// If not, then we'll continue as it isn't our input.
@@ -132,25 +154,16 @@ func (f *FullIntent) CompileFundingTx(extraInputs []*wire.TxIn,
 
 		// Finally, we'll sign the input as is, and populate the input
 		// with the witness and sigScript (if needed).
-		inputScript, err := f.signer.ComputeInputScript(
-			fundingTx, &signDesc,
-		)
+		inputScript, err := f.signer.ComputeInputScript(tx, &signDesc)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
 		txIn.SignatureScript = inputScript.SigScript
 		txIn.Witness = inputScript.Witness
 	}
 
-	// Finally, we'll populate the chanPoint now that we've fully
-	// constructed the funding transaction.
-	f.chanPoint = &wire.OutPoint{
-		Hash:  fundingTx.TxHash(),
-		Index: multiSigIndex,
-	}
-
-	return fundingTx, nil
+	return nil
 }
 
 // Inputs returns all inputs to the final funding transaction that we
@@ -331,6 +331,17 @@ type PaymentDescriptor struct {
 	// NOTE: Populated only in payment descriptor with MalformedFail type.
 	ShaOnionBlob [sha256.Size]byte
 
+	// BlindingPoint is the blinding point sent along with the HTLC if it
+	// is forwarded within a blinded route.
+	//
+	// NOTE: Populated only on add payment descriptor entry types.
+	BlindingPoint *btcec.PublicKey
+
+	// CustomRecords are the custom TLV records sent along with the HTLC.
+	//
+	// NOTE: Populated only on add payment descriptor entry types.
+	CustomRecords lnwire.CustomRecords
+
 	// FailReason stores the reason why a particular payment was canceled.
 	//
 	// NOTE: Populate only in fail payment descriptor entry types.
@@ -407,6 +418,8 @@ func PayDescsFromRemoteLogUpdates(chanID lnwire.ShortChannelID, height uint64,
 			}
 			pd.OnionBlob = make([]byte, len(wireMsg.OnionBlob))
 			copy(pd.OnionBlob[:], wireMsg.OnionBlob[:])
+			pd.BlindingPoint = wireMsg.BlindingPoint
+			pd.CustomRecords = wireMsg.CustomRecords
 
 		case *lnwire.UpdateFulfillHTLC:
 			pd = PaymentDescriptor{
@@ -695,7 +708,9 @@ func (c *commitment) populateHtlcIndexes(chanType channeldb.ChannelType,
 
 // toDiskCommit converts the target commitment into a format suitable to be
 // written to disk after an accepted state transition.
-func (c *commitment) toDiskCommit(ourCommit bool) *channeldb.ChannelCommitment {
+func (c *commitment) toDiskCommit(
+	ourCommit bool) (*channeldb.ChannelCommitment, error) {
+
 	numHtlcs := len(c.outgoingHTLCs) + len(c.incomingHTLCs)
 
 	commit := &channeldb.ChannelCommitment{
@@ -731,6 +746,12 @@ func (c *commitment) toDiskCommit(ourCommit bool) *channeldb.ChannelCommitment {
 		h.OnionBlob = make([]byte, len(htlc.OnionBlob))
 		copy(h.OnionBlob[:], htlc.OnionBlob)
 
+		extraData, err := htlcExtraData(&htlc)
+		if err != nil {
+			return nil, err
+		}
+		h.ExtraData = extraData
+
 		if ourCommit && htlc.sig != nil {
 			h.Signature = htlc.sig.Serialize()
 		}
@@ -756,6 +777,12 @@ func (c *commitment) toDiskCommit(ourCommit bool) *channeldb.ChannelCommitment {
 		h.OnionBlob = make([]byte, len(htlc.OnionBlob))
 		copy(h.OnionBlob[:], htlc.OnionBlob)
 
+		extraData, err := htlcExtraData(&htlc)
+		if err != nil {
+			return nil, err
+		}
+		h.ExtraData = extraData
+
 		if ourCommit && htlc.sig != nil {
 			h.Signature = htlc.sig.Serialize()
 		}
@@ -763,7 +790,25 @@ func (c *commitment) toDiskCommit(ourCommit bool) *channeldb.ChannelCommitment {
 		commit.Htlcs = append(commit.Htlcs, h)
 	}
 
-	return commit
+	return commit, nil
+}
+
+// htlcExtraData encodes the TLV records of the update_add_htlc message that
+// need to be stored along with the HTLC in order to be able to process it
+// after a restart.
+func htlcExtraData(pd *PaymentDescriptor) (lnwire.ExtraOpaqueData, error) {
+	if pd.BlindingPoint == nil {
+		return nil, nil
+	}
+
+	var extraData lnwire.ExtraOpaqueData
+	blindingPoint := lnwire.BlindingPoint(*pd.BlindingPoint)
+	err := lnwire.EncodeMessageExtraData(&extraData, &blindingPoint)
+	if err != nil {
+		return nil, err
+	}
+
+	return extraData, nil
 }
 
 // diskHtlcToPayDesc converts an HTLC previously written to disk within a
@@ -851,6 +896,11 @@ func (lc *LightningChannel) diskHtlcToPayDesc(feeRate chainfee.SatPerKWeight,
 		theirWitnessScript: theirWitnessScript,
 	}
 
+	pd.BlindingPoint, err = htlc.BlindingPoint()
+	if err != nil {
+		return pd, err
+	}
+
 	return pd, nil
 }
 
@@ -1247,6 +1297,10 @@ type LightningChannel struct {
 
 	status channelState
 
+	// splicing is true while the channel is frozen for a splice. No new
+	// updates are accepted in this state.
+	splicing bool
+
 	// ChanPoint is the funding outpoint of this channel.
 	ChanPoint *wire.OutPoint
 
@@ -1435,6 +1489,8 @@ func (lc *LightningChannel) logUpdateToPayDesc(logUpdate *channeldb.LogUpdate,
 		}
 		pd.OnionBlob = make([]byte, len(wireMsg.OnionBlob))
 		copy(pd.OnionBlob[:], wireMsg.OnionBlob[:])
+		pd.BlindingPoint = wireMsg.BlindingPoint
+		pd.CustomRecords = wireMsg.CustomRecords
 
 		isDustRemote := HtlcIsDust(
 			lc.channelState.ChanType, false, false, feeRate,
@@ -1634,6 +1690,8 @@ func (lc *LightningChannel) remoteLogUpdateToPayDesc(logUpdate *channeldb.LogUpd
 		}
 		pd.OnionBlob = make([]byte, len(wireMsg.OnionBlob))
 		copy(pd.OnionBlob, wireMsg.OnionBlob[:])
+		pd.BlindingPoint = wireMsg.BlindingPoint
+		pd.CustomRecords = wireMsg.CustomRecords
 
 		// We don't need to generate an htlc script yet. This will be
 		// done once we sign our remote commitment.
@@ -3163,11 +3221,13 @@ func (lc *LightningChannel) createCommitDiff(
 		switch pd.EntryType {
 		case Add:
 			htlc := &lnwire.UpdateAddHTLC{
-				ChanID:      chanID,
-				ID:          pd.HtlcIndex,
-				Amount:      pd.Amount,
-				Expiry:      pd.Timeout,
-				PaymentHash: pd.RHash,
+				ChanID:        chanID,
+				ID:            pd.HtlcIndex,
+				Amount:        pd.Amount,
+				Expiry:        pd.Timeout,
+				PaymentHash:   pd.RHash,
+				BlindingPoint: pd.BlindingPoint,
+				CustomRecords: pd.CustomRecords,
 			}
 			copy(htlc.OnionBlob[:], pd.OnionBlob)
 			logUpdate.UpdateMsg = htlc
@@ -3237,7 +3297,10 @@ func (lc *LightningChannel) createCommitDiff(
 	// With the set of log updates mapped into wire messages, we'll now
 	// convert the in-memory commit into a format suitable for writing to
 	// disk.
-	diskCommit := newCommit.toDiskCommit(false)
+	diskCommit, err := newCommit.toDiskCommit(false)
+	if err != nil {
+		return nil, err
+	}
 
 	return &channeldb.CommitDiff{
 		Commitment: *diskCommit,
@@ -3300,11 +3363,13 @@ func (lc *LightningChannel) getUnsignedAckedUpdates() []channeldb.LogUpdate {
 		switch pd.EntryType {
 		case Add:
 			htlc := &lnwire.UpdateAddHTLC{
-				ChanID:      chanID,
-				ID:          pd.HtlcIndex,
-				Amount:      pd.Amount,
-				Expiry:      pd.Timeout,
-				PaymentHash: pd.RHash,
+				ChanID:        chanID,
+				ID:            pd.HtlcIndex,
+				Amount:        pd.Amount,
+				Expiry:        pd.Timeout,
+				PaymentHash:   pd.RHash,
+				BlindingPoint: pd.BlindingPoint,
+				CustomRecords: pd.CustomRecords,
 			}
 			copy(htlc.OnionBlob[:], pd.OnionBlob)
 			logUpdate.UpdateMsg = htlc
@@ -4499,6 +4564,12 @@ func (lc *LightningChannel) IsChannelClean() bool {
 	lc.RLock()
 	defer lc.RUnlock()
 
+	return lc.isChannelClean()
+}
+
+// isChannelClean is the lock-free version of IsChannelClean. The caller must
+// hold the channel lock.
+func (lc *LightningChannel) isChannelClean() bool {
 	// Check whether we have a pending commitment for our local state.
 	if lc.localCommitChain.hasUnackedCommitment() {
 		return false
@@ -4635,7 +4706,10 @@ func (lc *LightningChannel) RevokeCurrentCommitment() (*lnwire.RevokeAndAck, []c
 	// Additionally, generate a channel delta for this state transition for
 	// persistent storage.
 	chainTail := lc.localCommitChain.tail()
-	newCommitment := chainTail.toDiskCommit(true)
+	newCommitment, err := chainTail.toDiskCommit(true)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	// Get the unsigned acked remotes updates that are currently in memory.
 	// We need them after a restart to sync our remote commitment with what
@@ -4821,11 +4895,13 @@ func (lc *LightningChannel) ReceiveRevocation(revMsg *lnwire.RevokeAndAck) (
 		switch pd.EntryType {
 		case Add:
 			htlc := &lnwire.UpdateAddHTLC{
-				ChanID:      chanID,
-				ID:          pd.HtlcIndex,
-				Amount:      pd.Amount,
-				Expiry:      pd.Timeout,
-				PaymentHash: pd.RHash,
+				ChanID:        chanID,
+				ID:            pd.HtlcIndex,
+				Amount:        pd.Amount,
+				Expiry:        pd.Timeout,
+				PaymentHash:   pd.RHash,
+				BlindingPoint: pd.BlindingPoint,
+				CustomRecords: pd.CustomRecords,
 			}
 			copy(htlc.OnionBlob[:], pd.OnionBlob)
 			logUpdate.UpdateMsg = htlc
@@ -4983,6 +5059,10 @@ func (lc *LightningChannel) AddHTLC(htlc *lnwire.UpdateAddHTLC,
 	lc.Lock()
 	defer lc.Unlock()
 
+	if lc.splicing {
+		return 0, ErrSplicePending
+	}
+
 	pd := lc.htlcAddDescriptor(htlc, openKey)
 	if err := lc.validateAddHtlc(pd); err != nil {
 		return 0, err
@@ -5114,6 +5194,8 @@ func (lc *LightningChannel) htlcAddDescriptor(htlc *lnwire.UpdateAddHTLC,
 		HtlcIndex:      lc.localUpdateLog.htlcCounter,
 		OnionBlob:      htlc.OnionBlob[:],
 		OpenCircuitKey: openKey,
+		BlindingPoint:  htlc.BlindingPoint,
+		CustomRecords:  htlc.CustomRecords,
 	}
 }
 
@@ -5156,19 +5238,25 @@ func (lc *LightningChannel) ReceiveHTLC(htlc *lnwire.UpdateAddHTLC) (uint64, err
 	lc.Lock()
 	defer lc.Unlock()
 
+	if lc.splicing {
+		return 0, ErrSplicePending
+	}
+
 	if htlc.ID != lc.remoteUpdateLog.htlcCounter {
 		return 0, fmt.Errorf("ID %d on HTLC add does not match expected next "+
 			"ID %d", htlc.ID, lc.remoteUpdateLog.htlcCounter)
 	}
 
 	pd := &PaymentDescriptor{
-		EntryType: Add,
-		RHash:     PaymentHash(htlc.PaymentHash),
-		Timeout:   htlc.Expiry,
-		Amount:    htlc.Amount,
-		LogIndex:  lc.remoteUpdateLog.logIndex,
-		HtlcIndex: lc.remoteUpdateLog.htlcCounter,
-		OnionBlob: htlc.OnionBlob[:],
+		EntryType:     Add,
+		RHash:         PaymentHash(htlc.PaymentHash),
+		Timeout:       htlc.Expiry,
+		Amount:        htlc.Amount,
+		LogIndex:      lc.remoteUpdateLog.logIndex,
+		HtlcIndex:     lc.remoteUpdateLog.htlcCounter,
+		OnionBlob:     htlc.OnionBlob[:],
+		BlindingPoint: htlc.BlindingPoint,
+		CustomRecords: htlc.CustomRecords,
 	}
 
 	localACKedIndex := lc.remoteCommitChain.tail().ourMessageIndex
@@ -6894,6 +6982,10 @@ func (lc *LightningChannel) UpdateFee(feePerKw chainfee.SatPerKWeight) error {
 	lc.Lock()
 	defer lc.Unlock()
 
+	if lc.splicing {
+		return ErrSplicePending
+	}
+
 	// Only initiator can send fee update, so trying to send one as
 	// non-initiator will fail.
 	if !lc.channelState.IsInitiator {
@@ -6922,6 +7014,10 @@ func (lc *LightningChannel) ReceiveUpdateFee(feePerKw chainfee.SatPerKWeight) er
 	lc.Lock()
 	defer lc.Unlock()
 
+	if lc.splicing {
+		return ErrSplicePending
+	}
+
 	// Only initiator can send fee update, and we must fail if we receive
 	// fee update as initiator
 	if lc.channelState.IsInitiator {
@@ -212,7 +212,8 @@ func NewChannelReservation(capacity, localFundingAmt bronutil.Amount,
 	id uint64, pushMSat lnwire.MilliSatoshi, chainHash *chainhash.Hash,
 	flags lnwire.FundingFlag, commitType CommitmentType,
 	fundingAssembler chanfunding.Assembler,
-	pendingChanID [32]byte, thawHeight uint32) (*ChannelReservation, error) {
+	pendingChanID [32]byte, thawHeight uint32,
+	dualFundRole DualFundRole) (*ChannelReservation, error) {
 
 	var (
 		ourBalance   lnwire.MilliSatoshi
@@ -243,10 +244,41 @@ func NewChannelReservation(capacity, localFundingAmt bronutil.Amount,
 	// Used to cut down on verbosity.
 	defaultDust := wallet.Cfg.DefaultConstraints.DustLimit
 
+	switch {
+	// If we open a dual funded channel, we pay all the initial fees
+	// within the commitment transaction, and push the lease fee, if any,
+	// to the remote party.
+	case dualFundRole == DualFundOpener:
+		ourBalance = localFundingMSat - feeMSat - pushMSat
+		theirBalance = capacityMSat - localFundingMSat + pushMSat
+		initiator = true
+
+		if int64(ourBalance) < 0 {
+			return nil, ErrFunderBalanceDust(
+				int64(commitFee), int64(ourBalance.ToSatoshis()),
+				int64(2*defaultDust),
+			)
+		}
+
+	// If we accept a dual funded channel, the remote party pays all the
+	// initial fees and pushes the lease fee, if any, to us.
+	case dualFundRole == DualFundAccepter:
+		ourBalance = localFundingMSat + pushMSat
+		theirBalance = capacityMSat - localFundingMSat - feeMSat -
+			pushMSat
+		initiator = false
+
+		if int64(theirBalance) < 0 {
+			return nil, ErrFunderBalanceDust(
+				int64(commitFee), int64(theirBalance.ToSatoshis()),
+				int64(2*defaultDust),
+			)
+		}
+
 	// If we're the responder to a single-funder reservation, then we have
 	// no initial balance in the channel unless the remote party is pushing
 	// some funds to us within the first commitment state.
-	if localFundingAmt == 0 {
+	case localFundingAmt == 0:
 		ourBalance = pushMSat
 		theirBalance = capacityMSat - feeMSat - pushMSat
 		initiator = false
@@ -259,7 +291,8 @@ func NewChannelReservation(capacity, localFundingAmt bronutil.Amount,
 				int64(2*defaultDust),
 			)
 		}
-	} else {
+
+	default:
 		// TODO(roasbeef): need to rework fee structure in general and
 		// also when we "unlock" dual funder within the daemon
 
@@ -317,10 +350,20 @@ func NewChannelReservation(capacity, localFundingAmt bronutil.Amount,
 	// the balances/push amount within the channel.
 	var chanType channeldb.ChannelType
 
+	switch {
+	// The funding transaction of an interactively dual funded channel is
+	// always known to both parties. Such channels may carry a push amount
+	// to pay for leased liquidity.
+	case dualFundRole != NotDualFunded:
+		chanType |= channeldb.DualFunderBit
+		if commitType.HasStaticRemoteKey() {
+			chanType |= channeldb.SingleFunderTweaklessBit
+		}
+
 	// If either of the balances are zero at this point, or we have a
 	// non-zero push amt (there's no pushing for dual funder), then this is
 	// a single-funder channel.
-	if ourBalance == 0 || theirBalance == 0 || pushMSat != 0 {
+	case ourBalance == 0 || theirBalance == 0 || pushMSat != 0:
 		// Both the tweakless type and the anchor type is tweakless,
 		// hence set the bit.
 		if commitType.HasStaticRemoteKey() {
@@ -350,7 +393,7 @@ func NewChannelReservation(capacity, localFundingAmt bronutil.Amount,
 			chanType |= channeldb.NoFundingTxBit
 		}
 
-	} else {
+	default:
 		// Otherwise, this is a dual funder channel, and no side is
 		// technically the "initiator"
 		initiator = false
@@ -427,6 +470,15 @@ func (r *ChannelReservation) SetNumConfsRequired(numConfs uint16) {
 	r.partialState.NumConfsRequired = numConfs
 }
 
+// IsZeroConf returns if the reservation's underlying channel type is
+// zero-conf.
+func (r *ChannelReservation) IsZeroConf() bool {
+	r.RLock()
+	defer r.RUnlock()
+
+	return r.partialState.ChanType.HasZeroConf()
+}
+
 // CommitConstraints takes the constraints that the remote party specifies for
 // the type of commitments that we can generate for them. These constraints
 // include several parameters that serve as flow control restricting the amount
@@ -604,6 +656,91 @@ func (r *ChannelReservation) RemoteCanceled() {
 	psbtIntent.RemoteCanceled()
 }
 
+// DualFundIntent returns the interactive funding intent of a dual funded
+// reservation. The second return value is false if the reservation isn't dual
+// funded.
+func (r *ChannelReservation) DualFundIntent() (*chanfunding.InteractiveIntent,
+	bool) {
+
+	intent, ok := r.fundingIntent.(*chanfunding.InteractiveIntent)
+	return intent, ok
+}
+
+// StartInteractiveTx binds the funding keys of both parties to the interactive
+// funding intent of a dual funded reservation, and adds our inputs and
+// outputs to the interactive construction of the funding transaction. The
+// returned session is used to track the contribution of the remote party.
+func (r *ChannelReservation) StartInteractiveTx(
+	theirFundingKey *btcec.PublicKey) (*chanfunding.InteractiveTx, error) {
+
+	r.Lock()
+	defer r.Unlock()
+
+	intent, ok := r.fundingIntent.(*chanfunding.InteractiveIntent)
+	if !ok {
+		return nil, errors.New("reservation is not dual funded")
+	}
+
+	intent.BindKeys(&r.ourContribution.MultiSigKey, theirFundingKey)
+	if err := intent.AddLocalContribution(); err != nil {
+		return nil, err
+	}
+
+	return intent.Session(), nil
+}
+
+// SetRemoteFunding updates a dual funded reservation we opened with the
+// amount the remote party contributes to the channel, and the amount we push
+// to the remote party as lease fee. The channel capacity and the initial
+// balances are updated accordingly.
+func (r *ChannelReservation) SetRemoteFunding(remoteAmt,
+	push bronutil.Amount) error {
+
+	r.Lock()
+	defer r.Unlock()
+
+	intent, ok := r.fundingIntent.(*chanfunding.InteractiveIntent)
+	if !ok || !r.partialState.IsInitiator {
+		return errors.New("reservation is not a dual funded channel " +
+			"we opened")
+	}
+
+	localAmt := intent.LocalFundingAmt()
+	capacity := localAmt + remoteAmt
+
+	fee := r.partialState.LocalCommitment.CommitFee
+	if r.partialState.ChanType.HasAnchors() {
+		fee += 2 * anchorSize
+	}
+
+	ourBalance := localAmt - fee - push
+	if ourBalance <= 2*r.wallet.Cfg.DefaultConstraints.DustLimit {
+		return ErrFunderBalanceDust(
+			int64(r.partialState.LocalCommitment.CommitFee),
+			int64(ourBalance),
+			int64(2*r.wallet.Cfg.DefaultConstraints.DustLimit),
+		)
+	}
+	theirBalance := remoteAmt + push
+
+	intent.SetRemoteFundingAmt(remoteAmt)
+
+	r.pushMSat = lnwire.NewMSatFromSatoshis(push)
+	r.ourContribution.FundingAmount = ourBalance
+	r.theirContribution.FundingAmount = theirBalance
+	r.partialState.Capacity = capacity
+
+	for _, commit := range []*channeldb.ChannelCommitment{
+		&r.partialState.LocalCommitment,
+		&r.partialState.RemoteCommitment,
+	} {
+		commit.LocalBalance = lnwire.NewMSatFromSatoshis(ourBalance)
+		commit.RemoteBalance = lnwire.NewMSatFromSatoshis(theirBalance)
+	}
+
+	return nil
+}
+
 // ProcessSingleContribution verifies, and records the initiator's contribution
 // to this pending single funder channel. Internally, no further action is
 // taken other than recording the initiator's contribution to the single funder
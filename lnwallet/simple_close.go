@@ -0,0 +1,238 @@
+package lnwallet
+
+import (
+	"fmt"
+
+	"github.com/brsuite/brond/blockchain"
+	"github.com/brsuite/brond/txscript"
+	"github.com/brsuite/brond/wire"
+	"github.com/brsuite/bronutil"
+	"github.com/brsuite/bronutil/txsort"
+	"github.com/brsuite/broln/channeldb"
+	"github.com/brsuite/broln/input"
+)
+
+// RbfCloseSequence is the sequence of the funding input of closing
+// transactions proposed with closing_complete messages. It signals
+// replaceability, and can't be confused with the sequence of a commitment
+// transaction, which encodes the obfuscated state number.
+const RbfCloseSequence = wire.MaxTxInSequenceNum - 2
+
+// SimpleClose describes a closing transaction proposed within the RBF
+// cooperative close flow. The party that proposes the transaction, the
+// closer, pays its full fee, and may omit either output if it's dust.
+type SimpleClose struct {
+	// Fee is the absolute fee of the closing transaction, which is
+	// deducted from the balance of the closer.
+	Fee bronutil.Amount
+
+	// LockTime is the lock time of the closing transaction, chosen by the
+	// closer.
+	LockTime uint32
+
+	// LocalIsCloser is true if we're the party proposing the closing
+	// transaction.
+	LocalIsCloser bool
+
+	// LocalScript is the script our funds are paid out to.
+	LocalScript []byte
+
+	// RemoteScript is the script the funds of the remote party are paid
+	// out to.
+	RemoteScript []byte
+
+	// NoCloserOutput signals that the output of the closer is omitted
+	// from the transaction, its balance going to fees.
+	NoCloserOutput bool
+
+	// NoCloseeOutput signals that the output of the closee is omitted
+	// from the transaction, its balance going to fees.
+	NoCloseeOutput bool
+}
+
+// SimpleCloseBalance returns the final balances that should be used to create
+// a closing transaction of the RBF cooperative close flow. Unlike
+// CoopCloseBalance, the fee is paid by the closer rather than by the
+// initiator of the channel.
+func SimpleCloseBalance(chanType channeldb.ChannelType, isInitiator,
+	localIsCloser bool, fee bronutil.Amount,
+	localCommit channeldb.ChannelCommitment) (bronutil.Amount,
+	bronutil.Amount, error) {
+
+	ourBalance, theirBalance, err := CoopCloseBalance(
+		chanType, isInitiator, 0, localCommit,
+	)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if localIsCloser {
+		ourBalance -= fee
+	} else {
+		theirBalance -= fee
+	}
+
+	if ourBalance < 0 || theirBalance < 0 {
+		return 0, 0, fmt.Errorf("closer cannot afford proposed close "+
+			"fee of %v", fee)
+	}
+
+	return ourBalance, theirBalance, nil
+}
+
+// SimpleCloseBalance returns the final balances of both parties should we
+// propose, or accept, a closing transaction with the given fee.
+func (lc *LightningChannel) SimpleCloseBalance(fee bronutil.Amount,
+	localIsCloser bool) (bronutil.Amount, bronutil.Amount, error) {
+
+	lc.RLock()
+	defer lc.RUnlock()
+
+	return SimpleCloseBalance(
+		lc.channelState.ChanType, lc.channelState.IsInitiator,
+		localIsCloser, fee, lc.channelState.LocalCommitment,
+	)
+}
+
+// createSimpleCloseTx assembles the closing transaction described by the
+// passed SimpleClose.
+//
+// NOTE: This method MUST be called with the channel's lock held.
+func (lc *LightningChannel) createSimpleCloseTx(
+	c *SimpleClose) (*wire.MsgTx, error) {
+
+	ourBalance, theirBalance, err := SimpleCloseBalance(
+		lc.channelState.ChanType, lc.channelState.IsInitiator,
+		c.LocalIsCloser, c.Fee, lc.channelState.LocalCommitment,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	omitLocal, omitRemote := c.NoCloserOutput, c.NoCloseeOutput
+	if !c.LocalIsCloser {
+		omitLocal, omitRemote = omitRemote, omitLocal
+	}
+	if omitLocal && omitRemote {
+		return nil, fmt.Errorf("closing transaction must have at " +
+			"least one output")
+	}
+
+	fundingIn := fundingTxIn(lc.channelState)
+	fundingIn.Sequence = RbfCloseSequence
+
+	closeTx := wire.NewMsgTx(2)
+	closeTx.LockTime = c.LockTime
+	closeTx.AddTxIn(&fundingIn)
+
+	if !omitLocal {
+		if ourBalance == 0 {
+			return nil, fmt.Errorf("local output has no value")
+		}
+		closeTx.AddTxOut(&wire.TxOut{
+			PkScript: c.LocalScript,
+			Value:    int64(ourBalance),
+		})
+	}
+	if !omitRemote {
+		if theirBalance == 0 {
+			return nil, fmt.Errorf("remote output has no value")
+		}
+		closeTx.AddTxOut(&wire.TxOut{
+			PkScript: c.RemoteScript,
+			Value:    int64(theirBalance),
+		})
+	}
+
+	txsort.InPlaceSort(closeTx)
+
+	// Ensure that the transaction doesn't explicitly violate any
+	// consensus rules such as being too big, or having any value with a
+	// negative output.
+	tx := bronutil.NewTx(closeTx)
+	if err := blockchain.CheckTransactionSanity(tx); err != nil {
+		return nil, err
+	}
+
+	return closeTx, nil
+}
+
+// SignSimpleClose creates our signature for the closing transaction described
+// by the passed SimpleClose. Upon completion, the channel will shift into the
+// "closing" state, which indicates that all incoming/outgoing HTLC requests
+// should be rejected.
+func (lc *LightningChannel) SignSimpleClose(
+	c *SimpleClose) (input.Signature, error) {
+
+	lc.Lock()
+	defer lc.Unlock()
+
+	if lc.status == channelClosed {
+		return nil, ErrChanClosing
+	}
+
+	closeTx, err := lc.createSimpleCloseTx(c)
+	if err != nil {
+		return nil, err
+	}
+
+	lc.signDesc.SigHashes = txscript.NewTxSigHashes(closeTx)
+	sig, err := lc.Signer.SignOutputRaw(closeTx, lc.signDesc)
+	if err != nil {
+		return nil, err
+	}
+
+	lc.status = channelClosing
+
+	return sig, nil
+}
+
+// CompleteSimpleClose returns the fully signed closing transaction described
+// by the passed SimpleClose, verifying the signature of the remote party.
+// Unlike CompleteCooperativeClose, the channel is left in the "closing" state,
+// as either party may still replace the closing transaction with one that
+// pays a higher fee.
+//
+// NOTE: The passed local and remote sigs are expected to be fully complete
+// signatures including the proper sighash byte.
+func (lc *LightningChannel) CompleteSimpleClose(c *SimpleClose,
+	localSig, remoteSig input.Signature) (*wire.MsgTx, error) {
+
+	lc.Lock()
+	defer lc.Unlock()
+
+	if lc.status == channelClosed {
+		return nil, ErrChanClosing
+	}
+
+	closeTx, err := lc.createSimpleCloseTx(c)
+	if err != nil {
+		return nil, err
+	}
+	hashCache := txscript.NewTxSigHashes(closeTx)
+
+	// Construct the witness stack minding the order of the pubkeys+sigs
+	// on the stack.
+	ourKey := lc.channelState.LocalChanCfg.MultiSigKey.PubKey.
+		SerializeCompressed()
+	theirKey := lc.channelState.RemoteChanCfg.MultiSigKey.PubKey.
+		SerializeCompressed()
+	closeTx.TxIn[0].Witness = input.SpendMultiSig(
+		lc.signDesc.WitnessScript, ourKey, localSig, theirKey,
+		remoteSig,
+	)
+
+	// Validate the finalized transaction to ensure the output script is
+	// properly met, and that the remote peer supplied a valid signature.
+	prevOut := lc.signDesc.Output
+	vm, err := txscript.NewEngine(prevOut.PkScript, closeTx, 0,
+		txscript.StandardVerifyFlags, nil, hashCache, prevOut.Value)
+	if err != nil {
+		return nil, err
+	}
+	if err := vm.Execute(); err != nil {
+		return nil, err
+	}
+
+	return closeTx, nil
+}
@@ -0,0 +1,110 @@
+package lnwallet
+
+import (
+	"testing"
+
+	"github.com/brsuite/brond/wire"
+	"github.com/brsuite/bronutil"
+	"github.com/brsuite/broln/channeldb"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSimpleCooperativeClose asserts that both parties arrive at the same
+// replaceable closing transaction when the closer pays the full fee, and that
+// a closing transaction can be replaced by one paying a higher fee.
+func TestSimpleCooperativeClose(t *testing.T) {
+	t.Parallel()
+
+	aliceChannel, bobChannel, cleanUp, err := CreateTestChannels(
+		channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err)
+	defer cleanUp()
+
+	aliceDeliveryScript := bobsPrivKey[:]
+	bobDeliveryScript := testHdSeed[:]
+
+	// closeWithFee has Bob propose a closing transaction with the given
+	// fee, which is signed by Alice in turn. Bob isn't the initiator of
+	// the channel, so the fee is paid from his balance.
+	closeWithFee := func(fee bronutil.Amount, lockTime uint32,
+		noCloseeOutput bool) (*wire.MsgTx, *wire.MsgTx) {
+
+		bobClose := &SimpleClose{
+			Fee:            fee,
+			LockTime:       lockTime,
+			LocalIsCloser:  true,
+			LocalScript:    bobDeliveryScript,
+			RemoteScript:   aliceDeliveryScript,
+			NoCloseeOutput: noCloseeOutput,
+		}
+		aliceClose := &SimpleClose{
+			Fee:            fee,
+			LockTime:       lockTime,
+			LocalScript:    aliceDeliveryScript,
+			RemoteScript:   bobDeliveryScript,
+			NoCloseeOutput: noCloseeOutput,
+		}
+
+		bobSig, err := bobChannel.SignSimpleClose(bobClose)
+		require.NoError(t, err)
+
+		aliceSig, err := aliceChannel.SignSimpleClose(aliceClose)
+		require.NoError(t, err)
+
+		aliceTx, err := aliceChannel.CompleteSimpleClose(
+			aliceClose, aliceSig, bobSig,
+		)
+		require.NoError(t, err)
+
+		bobTx, err := bobChannel.CompleteSimpleClose(
+			bobClose, bobSig, aliceSig,
+		)
+		require.NoError(t, err)
+
+		return aliceTx, bobTx
+	}
+
+	aliceTx, bobTx := closeWithFee(1000, 100, false)
+	require.Equal(t, aliceTx.TxHash(), bobTx.TxHash())
+	require.Equal(t, uint32(100), aliceTx.LockTime)
+	require.Equal(t, uint32(RbfCloseSequence), aliceTx.TxIn[0].Sequence)
+	require.Len(t, aliceTx.TxOut, 2)
+
+	// Bob pays the fee, while Alice receives her full balance, including
+	// the commitment fee she paid as the initiator.
+	aliceCommit := aliceChannel.channelState.LocalCommitment
+	bobCommit := bobChannel.channelState.LocalCommitment
+	expAlice := aliceCommit.LocalBalance.ToSatoshis() + aliceCommit.CommitFee
+	expBob := bobCommit.LocalBalance.ToSatoshis() - 1000
+	for _, txOut := range aliceTx.TxOut {
+		switch string(txOut.PkScript) {
+		case string(aliceDeliveryScript):
+			require.EqualValues(t, expAlice, txOut.Value)
+
+		case string(bobDeliveryScript):
+			require.EqualValues(t, expBob, txOut.Value)
+
+		default:
+			t.Fatalf("unexpected output script %x", txOut.PkScript)
+		}
+	}
+
+	// Both channels stay in the closing state, so Bob can replace the
+	// transaction with one that pays a higher fee. This time, he omits
+	// Alice's output.
+	aliceTx, bobTx = closeWithFee(2000, 101, true)
+	require.Equal(t, aliceTx.TxHash(), bobTx.TxHash())
+	require.Len(t, aliceTx.TxOut, 1)
+	require.Equal(t, bobDeliveryScript, aliceTx.TxOut[0].PkScript)
+	require.EqualValues(t, expBob-1000, aliceTx.TxOut[0].Value)
+
+	// Bob can't propose a fee that exceeds his balance.
+	_, err = bobChannel.SignSimpleClose(&SimpleClose{
+		Fee:           bobCommit.LocalBalance.ToSatoshis() + 1,
+		LocalIsCloser: true,
+		LocalScript:   bobDeliveryScript,
+		RemoteScript:  aliceDeliveryScript,
+	})
+	require.Error(t, err)
+}
@@ -0,0 +1,420 @@
+package lnwallet
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/brsuite/brond/blockchain"
+	"github.com/brsuite/brond/txscript"
+	"github.com/brsuite/brond/wire"
+	"github.com/brsuite/bronutil"
+	"github.com/brsuite/broln/channeldb"
+	"github.com/brsuite/broln/input"
+	"github.com/brsuite/broln/lnwallet/chainfee"
+	"github.com/brsuite/broln/lnwallet/chanfunding"
+	"github.com/brsuite/broln/lnwire"
+)
+
+var (
+	// ErrSplicePending is returned when an update is offered on a channel
+	// that is being spliced. The channel must stay quiescent until the
+	// splice transaction confirmed.
+	ErrSplicePending = errors.New("channel is being spliced, operation " +
+		"disallowed")
+
+	// ErrChannelNotClean is returned when a splice is requested for a
+	// channel that has pending updates or HTLCs.
+	ErrChannelNotClean = errors.New("channel has pending updates or HTLCs")
+)
+
+// SpliceFee returns the fee the initiator of a splice pays for the parts of
+// the splice transaction that aren't covered by the coin selection of its
+// wallet: the shared input and the splice-out output, if any. If the
+// initiator doesn't add any coins, the fee also covers the common
+// transaction fields and the new funding output, as it's taken from the
+// channel balance instead.
+func SpliceFee(feeRate chainfee.SatPerKWeight, spliceOutScript []byte,
+	withCoins bool) bronutil.Amount {
+
+	var weightEstimate input.TxWeightEstimator
+	weightEstimate.AddWitnessInput(input.MultiSigWitnessSize)
+	if len(spliceOutScript) != 0 {
+		weightEstimate.AddTxOutput(&wire.TxOut{PkScript: spliceOutScript})
+	}
+
+	if !withCoins {
+		weightEstimate.AddP2WSHOutput()
+
+		return feeRate.FeeForWeight(int64(weightEstimate.Weight()))
+	}
+
+	// The coin selection already accounts for the common transaction
+	// fields, so we only pay for the weight added on top of them.
+	var base input.TxWeightEstimator
+	weight := weightEstimate.Weight() - base.Weight()
+
+	return feeRate.FeeForWeight(int64(weight))
+}
+
+// ProvisionSplice selects the coins of our wallet we add to the splice
+// transaction of a channel when splicing funds into it. The returned intent
+// must be canceled if the splice fails.
+func (l *LightningWallet) ProvisionSplice(amt bronutil.Amount,
+	feeRate chainfee.SatPerKWeight, minConfs int32,
+	lockTime uint32) (*chanfunding.InteractiveIntent, error) {
+
+	assembler := l.interactiveAssembler(true, lockTime)
+	intent, err := assembler.ProvisionChannel(&chanfunding.Request{
+		LocalAmt: amt,
+		MinConfs: minConfs,
+		FeeRate:  feeRate,
+		ChangeAddr: func() (bronutil.Address, error) {
+			return l.NewAddress(
+				WitnessPubKey, true, DefaultAccountName,
+			)
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return intent.(*chanfunding.InteractiveIntent), nil
+}
+
+// MarkSplicing freezes the channel for a splice. The channel must be clean,
+// i.e. have no HTLCs or pending updates. Until ClearSplicing is called, no
+// new HTLCs or fee updates are accepted.
+func (lc *LightningChannel) MarkSplicing() error {
+	lc.Lock()
+	defer lc.Unlock()
+
+	if lc.status == channelClosing || lc.status == channelClosed {
+		return ErrChanClosing
+	}
+	if !lc.isChannelClean() {
+		return ErrChannelNotClean
+	}
+
+	lc.splicing = true
+
+	return nil
+}
+
+// ClearSplicing unfreezes a channel previously frozen by MarkSplicing.
+func (lc *LightningChannel) ClearSplicing() {
+	lc.Lock()
+	defer lc.Unlock()
+
+	lc.splicing = false
+}
+
+// IsSplicing returns true if the channel is frozen for a splice.
+func (lc *LightningChannel) IsSplicing() bool {
+	lc.RLock()
+	defer lc.RUnlock()
+
+	return lc.splicing
+}
+
+// FundingOutput returns the current funding output of the channel, which is
+// spent by the shared input of a splice.
+func (lc *LightningChannel) FundingOutput() *wire.TxOut {
+	lc.RLock()
+	defer lc.RUnlock()
+
+	return &wire.TxOut{
+		Value:    lc.signDesc.Output.Value,
+		PkScript: lc.signDesc.Output.PkScript,
+	}
+}
+
+// SignSpliceCommitment builds the commitments of both parties spending the
+// new funding output created by the passed splice transaction, and signs the
+// remote party's commitment. The commitments have the same height as the
+// current ones, and the change in capacity is entirely attributed to the
+// initiator of the splice. The returned candidate must be passed to
+// ReceiveSpliceCommitSig once the remote party's signature is known.
+func (lc *LightningChannel) SignSpliceCommitment(spliceTx *wire.MsgTx,
+	localSplice bool) (*channeldb.SpliceCandidate, lnwire.Sig, error) {
+
+	lc.Lock()
+	defer lc.Unlock()
+
+	if !lc.splicing {
+		return nil, lnwire.Sig{}, errors.New("channel not marked as " +
+			"splicing")
+	}
+
+	// The new funding output uses the same multisig keys as the current
+	// one, so we'll look for an output with the same script.
+	fundingScript := lc.signDesc.Output.PkScript
+	found, outputIndex := input.FindScriptOutputIndex(
+		spliceTx, fundingScript,
+	)
+	if !found {
+		return nil, lnwire.Sig{}, errors.New("splice tx has no " +
+			"funding output")
+	}
+	capacity := bronutil.Amount(spliceTx.TxOut[outputIndex].Value)
+	chanPoint := wire.OutPoint{
+		Hash:  spliceTx.TxHash(),
+		Index: outputIndex,
+	}
+
+	// The channel is quiescent, so both commitments carry the same
+	// balances. The difference in capacity is added to or removed from
+	// the balance of the splice initiator.
+	chanState := lc.channelState
+	localCommit := chanState.LocalCommitment
+	remoteCommit := chanState.RemoteCommitment
+
+	delta := int64(capacity) - int64(chanState.Capacity)
+	deltaMSat := lnwire.NewMSatFromSatoshis(bronutil.Amount(abs(delta)))
+
+	ourBalance := localCommit.LocalBalance
+	theirBalance := localCommit.RemoteBalance
+	splicerBalance, splicerReserve := &theirBalance,
+		chanState.RemoteChanCfg.ChanReserve
+	if localSplice {
+		splicerBalance, splicerReserve = &ourBalance,
+			chanState.LocalChanCfg.ChanReserve
+	}
+
+	switch {
+	case delta >= 0:
+		*splicerBalance += deltaMSat
+
+	case *splicerBalance < deltaMSat:
+		return nil, lnwire.Sig{}, fmt.Errorf("splice removes %v from "+
+			"the channel, exceeding the balance of %v",
+			deltaMSat.ToSatoshis(), splicerBalance.ToSatoshis())
+
+	default:
+		*splicerBalance -= deltaMSat
+	}
+
+	if splicerBalance.ToSatoshis() < splicerReserve {
+		return nil, lnwire.Sig{}, fmt.Errorf("splice leaves a "+
+			"balance of %v, below the channel reserve of %v",
+			splicerBalance.ToSatoshis(), splicerReserve)
+	}
+
+	// With the balances known, we'll now build both commitments at the
+	// current commitment heights.
+	commitSecret, err := chanState.RevocationProducer.AtIndex(
+		localCommit.CommitHeight,
+	)
+	if err != nil {
+		return nil, lnwire.Sig{}, err
+	}
+	localCommitPoint := input.ComputeCommitmentPoint(commitSecret[:])
+	remoteCommitPoint := chanState.RemoteCurrentRevocation
+
+	var leaseExpiry uint32
+	if chanState.ChanType.HasLeaseExpiration() {
+		leaseExpiry = chanState.ThawHeight
+	}
+
+	ourCommitTx, theirCommitTx, err := CreateCommitmentTxns(
+		ourBalance.ToSatoshis(), theirBalance.ToSatoshis(),
+		&chanState.LocalChanCfg, &chanState.RemoteChanCfg,
+		localCommitPoint, remoteCommitPoint,
+		*wire.NewTxIn(&chanPoint, nil, nil), chanState.ChanType,
+		chanState.IsInitiator, leaseExpiry,
+	)
+	if err != nil {
+		return nil, lnwire.Sig{}, err
+	}
+
+	err = SetStateNumHint(
+		ourCommitTx, localCommit.CommitHeight,
+		lc.commitBuilder.obfuscator,
+	)
+	if err != nil {
+		return nil, lnwire.Sig{}, err
+	}
+	err = SetStateNumHint(
+		theirCommitTx, remoteCommit.CommitHeight,
+		lc.commitBuilder.obfuscator,
+	)
+	if err != nil {
+		return nil, lnwire.Sig{}, err
+	}
+
+	InPlaceCommitSort(ourCommitTx, make([]uint32, len(ourCommitTx.TxOut)))
+	InPlaceCommitSort(
+		theirCommitTx, make([]uint32, len(theirCommitTx.TxOut)),
+	)
+
+	// Finally, we'll sign the remote party's commitment, spending the new
+	// funding output.
+	signDesc := *lc.signDesc
+	signDesc.Output = &wire.TxOut{
+		Value:    int64(capacity),
+		PkScript: fundingScript,
+	}
+	signDesc.SigHashes = txscript.NewTxSigHashes(theirCommitTx)
+	signDesc.InputIndex = 0
+	sig, err := lc.Signer.SignOutputRaw(theirCommitTx, &signDesc)
+	if err != nil {
+		return nil, lnwire.Sig{}, err
+	}
+	commitSig, err := lnwire.NewSigFromSignature(sig)
+	if err != nil {
+		return nil, lnwire.Sig{}, err
+	}
+
+	localCommit.LocalBalance = ourBalance
+	localCommit.RemoteBalance = theirBalance
+	localCommit.CommitTx = ourCommitTx
+	localCommit.CommitSig = nil
+
+	remoteCommit.LocalBalance = ourBalance
+	remoteCommit.RemoteBalance = theirBalance
+	remoteCommit.CommitTx = theirCommitTx
+	remoteCommit.CommitSig = commitSig.ToSignatureBytes()
+
+	candidate := &channeldb.SpliceCandidate{
+		SpliceTx:         spliceTx,
+		FundingOutpoint:  chanPoint,
+		Capacity:         capacity,
+		LocalCommitment:  localCommit,
+		RemoteCommitment: remoteCommit,
+	}
+
+	return candidate, commitSig, nil
+}
+
+// ReceiveSpliceCommitSig verifies the remote party's signature for our
+// commitment of the given splice candidate, and adds it to the candidate.
+func (lc *LightningChannel) ReceiveSpliceCommitSig(
+	candidate *channeldb.SpliceCandidate, commitSig lnwire.Sig) error {
+
+	lc.RLock()
+	defer lc.RUnlock()
+
+	commitTx := candidate.LocalCommitment.CommitTx
+	hashCache := txscript.NewTxSigHashes(commitTx)
+	sigHash, err := txscript.CalcWitnessSigHash(
+		lc.signDesc.WitnessScript, hashCache, txscript.SigHashAll,
+		commitTx, 0, int64(candidate.Capacity),
+	)
+	if err != nil {
+		return err
+	}
+
+	sig, err := commitSig.ToSignature()
+	if err != nil {
+		return err
+	}
+	if !sig.Verify(sigHash, lc.channelState.RemoteChanCfg.MultiSigKey.PubKey) {
+		return errors.New("invalid splice commitment signature")
+	}
+
+	candidate.LocalCommitment.CommitSig = commitSig.ToSignatureBytes()
+
+	return nil
+}
+
+// spliceInputIndex returns the index of the input of the splice transaction
+// that spends the current funding output of the channel.
+func (lc *LightningChannel) spliceInputIndex(spliceTx *wire.MsgTx) (int,
+	error) {
+
+	for i, txIn := range spliceTx.TxIn {
+		if txIn.PreviousOutPoint == *lc.ChanPoint {
+			return i, nil
+		}
+	}
+
+	return 0, errors.New("splice tx doesn't spend the funding output")
+}
+
+// SignSpliceInput signs the input of the splice transaction that spends the
+// current funding output of the channel.
+func (lc *LightningChannel) SignSpliceInput(
+	spliceTx *wire.MsgTx) (lnwire.Sig, error) {
+
+	lc.RLock()
+	defer lc.RUnlock()
+
+	inputIndex, err := lc.spliceInputIndex(spliceTx)
+	if err != nil {
+		return lnwire.Sig{}, err
+	}
+
+	signDesc := *lc.signDesc
+	signDesc.SigHashes = txscript.NewTxSigHashes(spliceTx)
+	signDesc.InputIndex = inputIndex
+	sig, err := lc.Signer.SignOutputRaw(spliceTx, &signDesc)
+	if err != nil {
+		return lnwire.Sig{}, err
+	}
+
+	return lnwire.NewSigFromSignature(sig)
+}
+
+// AddSpliceInputWitness adds the witness spending the current funding output
+// of the channel to the splice transaction, given the signatures of both
+// parties. The witness is verified before it's added.
+func (lc *LightningChannel) AddSpliceInputWitness(spliceTx *wire.MsgTx,
+	localSig, remoteSig lnwire.Sig) error {
+
+	lc.RLock()
+	defer lc.RUnlock()
+
+	inputIndex, err := lc.spliceInputIndex(spliceTx)
+	if err != nil {
+		return err
+	}
+
+	ourSig, err := localSig.ToSignature()
+	if err != nil {
+		return err
+	}
+	theirSig, err := remoteSig.ToSignature()
+	if err != nil {
+		return err
+	}
+
+	// Construct the witness stack minding the order of the pubkeys+sigs
+	// on the stack.
+	ourKey := lc.channelState.LocalChanCfg.MultiSigKey.PubKey.
+		SerializeCompressed()
+	theirKey := lc.channelState.RemoteChanCfg.MultiSigKey.PubKey.
+		SerializeCompressed()
+	witness := input.SpendMultiSig(
+		lc.signDesc.WitnessScript, ourKey, ourSig, theirKey, theirSig,
+	)
+
+	// Validate the witness to ensure the remote party supplied a valid
+	// signature before we hand out the transaction.
+	signedTx := spliceTx.Copy()
+	signedTx.TxIn[inputIndex].Witness = witness
+
+	prevOut := lc.signDesc.Output
+	vm, err := txscript.NewEngine(
+		prevOut.PkScript, signedTx, inputIndex,
+		txscript.StandardVerifyFlags, nil,
+		txscript.NewTxSigHashes(signedTx), prevOut.Value,
+	)
+	if err != nil {
+		return err
+	}
+	if err := vm.Execute(); err != nil {
+		return fmt.Errorf("invalid splice input signature: %v", err)
+	}
+
+	spliceTx.TxIn[inputIndex].Witness = witness
+
+	return blockchain.CheckTransactionSanity(bronutil.NewTx(spliceTx))
+}
+
+// abs returns the absolute value of x.
+func abs(x int64) int64 {
+	if x < 0 {
+		return -x
+	}
+
+	return x
+}
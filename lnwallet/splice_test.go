@@ -0,0 +1,251 @@
+package lnwallet
+
+import (
+	"testing"
+
+	"github.com/brsuite/brond/chaincfg/chainhash"
+	"github.com/brsuite/brond/txscript"
+	"github.com/brsuite/brond/wire"
+	"github.com/brsuite/bronutil"
+	"github.com/brsuite/broln/channeldb"
+	"github.com/brsuite/broln/input"
+	"github.com/brsuite/broln/lnwire"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestSpliceTx returns a splice transaction spending the funding output of
+// the given channel into a new funding output of the given capacity. Funds
+// spliced in are taken from a wallet input, funds spliced out are paid to a
+// separate output.
+func newTestSpliceTx(lc *LightningChannel,
+	capacity bronutil.Amount) *wire.MsgTx {
+
+	spliceTx := wire.NewMsgTx(2)
+	spliceTx.AddTxIn(wire.NewTxIn(lc.ChanPoint, nil, nil))
+	spliceTx.AddTxOut(&wire.TxOut{
+		Value:    int64(capacity),
+		PkScript: lc.signDesc.Output.PkScript,
+	})
+
+	oldCapacity := lc.channelState.Capacity
+	switch {
+	case capacity > oldCapacity:
+		walletInput := wire.OutPoint{Hash: chainhash.Hash{1}}
+		spliceTx.AddTxIn(wire.NewTxIn(&walletInput, nil, nil))
+
+	case capacity < oldCapacity:
+		spliceTx.AddTxOut(&wire.TxOut{
+			Value:    int64(oldCapacity - capacity),
+			PkScript: testHdSeed[:],
+		})
+	}
+
+	return spliceTx
+}
+
+// assertSpliceCommitment asserts that the local commitment of the given
+// splice candidate, signed by the channel's own key and the passed signature
+// of the remote party, validly spends the new funding output.
+func assertSpliceCommitment(t *testing.T, lc *LightningChannel,
+	candidate *channeldb.SpliceCandidate, remoteSig lnwire.Sig) {
+
+	t.Helper()
+
+	commitTx := candidate.LocalCommitment.CommitTx
+	require.Equal(
+		t, candidate.FundingOutpoint, commitTx.TxIn[0].PreviousOutPoint,
+	)
+
+	fundingOutput := &wire.TxOut{
+		Value:    int64(candidate.Capacity),
+		PkScript: lc.signDesc.Output.PkScript,
+	}
+	signDesc := *lc.signDesc
+	signDesc.Output = fundingOutput
+	signDesc.SigHashes = txscript.NewTxSigHashes(commitTx)
+	signDesc.InputIndex = 0
+	ourSig, err := lc.Signer.SignOutputRaw(commitTx, &signDesc)
+	require.NoError(t, err)
+
+	theirSig, err := remoteSig.ToSignature()
+	require.NoError(t, err)
+
+	ourKey := lc.channelState.LocalChanCfg.MultiSigKey.PubKey
+	theirKey := lc.channelState.RemoteChanCfg.MultiSigKey.PubKey
+	signedTx := commitTx.Copy()
+	signedTx.TxIn[0].Witness = input.SpendMultiSig(
+		lc.signDesc.WitnessScript, ourKey.SerializeCompressed(), ourSig,
+		theirKey.SerializeCompressed(), theirSig,
+	)
+
+	vm, err := txscript.NewEngine(
+		fundingOutput.PkScript, signedTx, 0,
+		txscript.StandardVerifyFlags, nil,
+		txscript.NewTxSigHashes(signedTx), fundingOutput.Value,
+	)
+	require.NoError(t, err)
+	require.NoError(t, vm.Execute())
+}
+
+// TestSpliceCommitments asserts that both parties build and sign matching
+// commitments spending the new funding output of a splice, attributing the
+// change in capacity to the splice initiator, and that the shared input of
+// the splice transaction is signed by both.
+func TestSpliceCommitments(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name  string
+		delta int64
+	}{
+		{
+			name:  "splice in",
+			delta: 1_000_000,
+		},
+		{
+			name:  "splice out",
+			delta: -1_000_000,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			testSpliceCommitments(t, testCase.delta)
+		})
+	}
+}
+
+func testSpliceCommitments(t *testing.T, delta int64) {
+	aliceChannel, bobChannel, cleanUp, err := CreateTestChannels(
+		channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err)
+	defer cleanUp()
+
+	require.NoError(t, aliceChannel.MarkSplicing())
+	require.NoError(t, bobChannel.MarkSplicing())
+
+	oldCapacity := aliceChannel.channelState.Capacity
+	capacity := bronutil.Amount(int64(oldCapacity) + delta)
+	spliceTx := newTestSpliceTx(aliceChannel, capacity)
+
+	// Alice initiates the splice, so the change in capacity is added to
+	// or taken from her balance on both commitments.
+	aliceCandidate, aliceSig, err := aliceChannel.SignSpliceCommitment(
+		spliceTx, true,
+	)
+	require.NoError(t, err)
+	bobCandidate, bobSig, err := bobChannel.SignSpliceCommitment(
+		spliceTx, false,
+	)
+	require.NoError(t, err)
+
+	require.Equal(t, capacity, aliceCandidate.Capacity)
+	require.Equal(
+		t, aliceCandidate.FundingOutpoint, bobCandidate.FundingOutpoint,
+	)
+
+	aliceCommit := aliceChannel.channelState.LocalCommitment
+	deltaMSat := lnwire.MilliSatoshi(delta * 1000)
+	require.Equal(
+		t, aliceCommit.LocalBalance+deltaMSat,
+		aliceCandidate.LocalCommitment.LocalBalance,
+	)
+	require.Equal(
+		t, aliceCommit.RemoteBalance,
+		aliceCandidate.LocalCommitment.RemoteBalance,
+	)
+
+	// Both parties must have built the same commitments, so each of them
+	// is able to verify the signature of the other.
+	require.Equal(
+		t, aliceCandidate.LocalCommitment.CommitTx.TxHash(),
+		bobCandidate.RemoteCommitment.CommitTx.TxHash(),
+	)
+	require.Equal(
+		t, bobCandidate.LocalCommitment.CommitTx.TxHash(),
+		aliceCandidate.RemoteCommitment.CommitTx.TxHash(),
+	)
+
+	require.NoError(t, aliceChannel.ReceiveSpliceCommitSig(
+		aliceCandidate, bobSig,
+	))
+	require.NoError(t, bobChannel.ReceiveSpliceCommitSig(
+		bobCandidate, aliceSig,
+	))
+
+	assertSpliceCommitment(t, aliceChannel, aliceCandidate, bobSig)
+	assertSpliceCommitment(t, bobChannel, bobCandidate, aliceSig)
+
+	// A signature for the wrong commitment must be rejected.
+	err = aliceChannel.ReceiveSpliceCommitSig(aliceCandidate, aliceSig)
+	require.Error(t, err)
+
+	// Finally, both parties sign the shared input of the splice
+	// transaction, which is only accepted with both signatures in place.
+	aliceInputSig, err := aliceChannel.SignSpliceInput(spliceTx)
+	require.NoError(t, err)
+	bobInputSig, err := bobChannel.SignSpliceInput(spliceTx)
+	require.NoError(t, err)
+
+	err = aliceChannel.AddSpliceInputWitness(
+		spliceTx, aliceInputSig, aliceInputSig,
+	)
+	require.ErrorContains(t, err, "invalid splice input signature")
+	require.Nil(t, spliceTx.TxIn[0].Witness)
+
+	require.NoError(t, aliceChannel.AddSpliceInputWitness(
+		spliceTx, aliceInputSig, bobInputSig,
+	))
+	require.NotNil(t, spliceTx.TxIn[0].Witness)
+}
+
+// TestSpliceCommitmentReserve asserts that a splice can't take more funds out
+// of the channel than the initiator is able to spend without violating its
+// channel reserve.
+func TestSpliceCommitmentReserve(t *testing.T) {
+	t.Parallel()
+
+	aliceChannel, bobChannel, cleanUp, err := CreateTestChannels(
+		channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err)
+	defer cleanUp()
+
+	require.NoError(t, aliceChannel.MarkSplicing())
+	require.NoError(t, bobChannel.MarkSplicing())
+
+	chanState := aliceChannel.channelState
+	balance := chanState.LocalCommitment.LocalBalance.ToSatoshis()
+	reserve := chanState.LocalChanCfg.ChanReserve
+
+	// Splicing out all but the reserve is fine.
+	spliceTx := newTestSpliceTx(
+		aliceChannel, chanState.Capacity-(balance-reserve),
+	)
+	_, _, err = aliceChannel.SignSpliceCommitment(spliceTx, true)
+	require.NoError(t, err)
+	_, _, err = bobChannel.SignSpliceCommitment(spliceTx, false)
+	require.NoError(t, err)
+
+	// Taking a single satoshi more out of the channel leaves Alice below
+	// her reserve, which both parties must refuse.
+	spliceTx = newTestSpliceTx(
+		aliceChannel, chanState.Capacity-(balance-reserve)-1,
+	)
+	_, _, err = aliceChannel.SignSpliceCommitment(spliceTx, true)
+	require.ErrorContains(t, err, "below the channel reserve")
+	_, _, err = bobChannel.SignSpliceCommitment(spliceTx, false)
+	require.ErrorContains(t, err, "below the channel reserve")
+
+	// So does taking out more than her entire balance.
+	spliceTx = newTestSpliceTx(
+		aliceChannel, chanState.Capacity-balance-1,
+	)
+	_, _, err = aliceChannel.SignSpliceCommitment(spliceTx, true)
+	require.ErrorContains(t, err, "exceeding the balance")
+}
@@ -78,6 +78,25 @@ func (p *PsbtFundingRequired) Error() string {
 	return ErrPsbtFundingRequired.Error()
 }
 
+// DualFundRole denotes which part we play in a dual funded channel whose
+// funding transaction is constructed interactively with the remote party.
+type DualFundRole uint8
+
+const (
+	// NotDualFunded is the role for channels that are opened using the
+	// single funder workflow.
+	NotDualFunded DualFundRole = iota
+
+	// DualFundOpener is the role of the party that opens a dual funded
+	// channel. The opener pays the fees of the initial commitment
+	// transaction.
+	DualFundOpener
+
+	// DualFundAccepter is the role of the party that accepts a dual
+	// funded channel.
+	DualFundAccepter
+)
+
 // InitFundingReserveMsg is the first message sent to initiate the workflow
 // required to open a payment channel with a remote peer. The initial required
 // parameters are configurable across channels. These parameters are to be
@@ -153,6 +172,28 @@ type InitFundingReserveMsg struct {
 	// used.
 	ChanFunder chanfunding.Assembler
 
+	// ZeroConf is a boolean that is true if a zero-conf channel was
+	// negotiated.
+	ZeroConf bool
+
+	// OptionScidAlias is a boolean that is true if an option-scid-alias
+	// channel type was explicitly negotiated.
+	OptionScidAlias bool
+
+	// ScidAliasFeature is true if the option-scid-alias feature bit was
+	// negotiated.
+	ScidAliasFeature bool
+
+	// DualFundRole is set if the funding transaction of the channel is
+	// constructed interactively with the remote party, both contributing
+	// funds. If set, ChanFunder must be nil, as our contribution is always
+	// selected from the internal wallet.
+	DualFundRole DualFundRole
+
+	// FundingLockTime is the lock time of the interactively constructed
+	// funding transaction. It is only used if DualFundRole is set.
+	FundingLockTime uint32
+
 	// err is a channel in which all errors will be sent across. Will be
 	// nil if this initial set is successful.
 	//
@@ -712,6 +753,22 @@ func (l *LightningWallet) handleFundingReserveRequest(req *InitFundingReserveMsg
 	// The enforcement still happens at the last step - in PsbtFundingVerify
 	enforceNewReservedValue := true
 
+	// If this is a dual funded channel, then our contribution is selected
+	// from the internal wallet, and the funding transaction is constructed
+	// together with the remote party.
+	if req.DualFundRole != NotDualFunded {
+		if req.ChanFunder != nil {
+			req.err <- fmt.Errorf("custom channel funder not " +
+				"supported for dual funded channels")
+			req.resp <- nil
+			return
+		}
+
+		req.ChanFunder = l.interactiveAssembler(
+			req.DualFundRole == DualFundOpener, req.FundingLockTime,
+		)
+	}
+
 	// If no chanFunder was provided, then we'll assume the default
 	// assembler, which is backed by the wallet's internal coin selection.
 	if req.ChanFunder == nil {
@@ -848,7 +905,7 @@ func (l *LightningWallet) handleFundingReserveRequest(req *InitFundingReserveMsg
 		capacity, localFundingAmt, req.CommitFeePerKw, l, id,
 		req.PushMSat, l.Cfg.NetParams.GenesisHash, req.Flags,
 		req.CommitType, req.ChanFunder, req.PendingChanID,
-		thawHeight,
+		thawHeight, req.DualFundRole,
 	)
 	if err != nil {
 		fundingIntent.Cancel()
@@ -858,6 +915,18 @@ func (l *LightningWallet) handleFundingReserveRequest(req *InitFundingReserveMsg
 		return
 	}
 
+	// Mark the channel type with any of the negotiated zero-conf and
+	// scid-alias bits, so they're persisted along with the channel.
+	if req.ZeroConf {
+		reservation.partialState.ChanType |= channeldb.ZeroConfBit
+	}
+	if req.OptionScidAlias {
+		reservation.partialState.ChanType |= channeldb.ScidAliasChanBit
+	}
+	if req.ScidAliasFeature {
+		reservation.partialState.ChanType |= channeldb.ScidAliasFeatureBit
+	}
+
 	err = l.initOurContribution(
 		reservation, fundingIntent, req.NodeAddr, req.NodeID, keyRing,
 	)
@@ -1249,6 +1318,37 @@ func (l *LightningWallet) handleFundingCancelRequest(req *fundingReserveCancelMs
 	req.err <- nil
 }
 
+// interactiveAssembler returns an assembler that selects our coins from the
+// internal wallet for a transaction constructed interactively with the remote
+// party, such as the funding transaction of a dual funded channel or a splice.
+func (l *LightningWallet) interactiveAssembler(initiator bool,
+	lockTime uint32) *chanfunding.InteractiveAssembler {
+
+	return chanfunding.NewInteractiveAssembler(chanfunding.InteractiveConfig{
+		WalletConfig: chanfunding.WalletConfig{
+			CoinSource:       &CoinSource{l},
+			CoinSelectLocker: l,
+			CoinLocker:       l,
+			Signer:           l.Cfg.Signer,
+			DustLimit:        DustLimitForSize(input.P2WSHSize),
+		},
+		Initiator: initiator,
+		LockTime:  lockTime,
+		FetchPrevTx: func(op wire.OutPoint) (*wire.MsgTx, error) {
+			utxo, err := l.FetchInputInfo(&op)
+			if err != nil {
+				return nil, err
+			}
+			if utxo.PrevTx == nil {
+				return nil, fmt.Errorf("previous tx of %v not "+
+					"found", op)
+			}
+
+			return utxo.PrevTx, nil
+		},
+	})
+}
+
 // CreateCommitmentTxns is a helper function that creates the initial
 // commitment transaction for both parties. This function is used during the
 // initial funding workflow as both sides must generate a signature for the
@@ -1392,6 +1492,30 @@ func (l *LightningWallet) handleContributionMsg(req *addContributionMsg) {
 		}
 		return
 
+	// The funding transaction of a dual funded channel was constructed
+	// interactively with the remote party, which already required both
+	// keys to be bound. We only need to sign our inputs, the remote
+	// party's witnesses are exchanged once the commitments are signed.
+	case *chanfunding.InteractiveIntent:
+		fundingTx, err := fundingIntent.CompileFundingTx()
+		if err != nil {
+			req.err <- fmt.Errorf("unable to construct funding "+
+				"tx: %v", err)
+			return
+		}
+		chanPoint, err = fundingIntent.ChanPoint()
+		if err != nil {
+			req.err <- fmt.Errorf("unable to obtain chan "+
+				"point: %v", err)
+			return
+		}
+
+		pendingReservation.fundingTx = fundingTx
+		pendingReservation.partialState.FundingOutpoint = *chanPoint
+
+		walletLog.Tracef("Interactive funding tx for ChannelPoint(%v) "+
+			"compiled: %v", chanPoint, spew.Sdump(fundingTx))
+
 	case *chanfunding.FullIntent:
 		// Now that we know their public key, we can bind theirs as
 		// well as ours to the funding intent.
@@ -0,0 +1,110 @@
+package lnwire
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/brsuite/bronutil"
+)
+
+// ClosingComplete is sent by either party of a channel once shutdown messages
+// have been exchanged, to propose a closing transaction for which the sender,
+// the closer, pays the full fee. The closer may send a new ClosingComplete at
+// any time to propose a closing transaction with a higher fee, replacing any
+// previous one.
+type ClosingComplete struct {
+	// ChannelID serves to identify which channel is to be closed.
+	ChannelID ChannelID
+
+	// CloserScript is the script to which the funds of the closer will be
+	// paid.
+	CloserScript DeliveryAddress
+
+	// CloseeScript is the script to which the funds of the closee will be
+	// paid.
+	CloseeScript DeliveryAddress
+
+	// FeeSatoshis is the total fee in satoshis that the closer pays for
+	// the closing transaction.
+	FeeSatoshis bronutil.Amount
+
+	// LockTime is the lock time of the closing transaction.
+	LockTime uint32
+
+	// ClosingSigs holds the signatures of the closer for the variants of
+	// the closing transaction it proposes. They are carried as optional
+	// TLV records within ExtraData.
+	ClosingSigs ClosingSigs
+
+	// ExtraData is the set of data that was appended to this message to
+	// fill out the full maximum transport message size. These fields can
+	// be used to specify optional data such as custom TLV fields.
+	ExtraData ExtraOpaqueData
+}
+
+// A compile time check to ensure ClosingComplete implements the
+// lnwire.Message interface.
+var _ Message = (*ClosingComplete)(nil)
+
+// Decode deserializes a serialized ClosingComplete message stored in the
+// passed io.Reader observing the specified protocol version.
+//
+// This is part of the lnwire.Message interface.
+func (c *ClosingComplete) Decode(r io.Reader, pver uint32) error {
+	var tlvRecords ExtraOpaqueData
+	err := ReadElements(
+		r, &c.ChannelID, &c.CloserScript, &c.CloseeScript,
+		&c.FeeSatoshis, &c.LockTime, &tlvRecords,
+	)
+	if err != nil {
+		return err
+	}
+
+	if err := c.ClosingSigs.decode(tlvRecords); err != nil {
+		return err
+	}
+
+	c.ExtraData = tlvRecords
+
+	return nil
+}
+
+// Encode serializes the target ClosingComplete into the passed io.Writer
+// observing the protocol version specified.
+//
+// This is part of the lnwire.Message interface.
+func (c *ClosingComplete) Encode(w *bytes.Buffer, pver uint32) error {
+	if err := WriteChannelID(w, c.ChannelID); err != nil {
+		return err
+	}
+
+	if err := WriteDeliveryAddress(w, c.CloserScript); err != nil {
+		return err
+	}
+
+	if err := WriteDeliveryAddress(w, c.CloseeScript); err != nil {
+		return err
+	}
+
+	if err := WriteSatoshi(w, c.FeeSatoshis); err != nil {
+		return err
+	}
+
+	if err := WriteUint32(w, c.LockTime); err != nil {
+		return err
+	}
+
+	if err := c.ClosingSigs.encode(&c.ExtraData); err != nil {
+		return err
+	}
+
+	return WriteBytes(w, c.ExtraData)
+}
+
+// MsgType returns the integer uniquely identifying this message type on the
+// wire.
+//
+// This is part of the lnwire.Message interface.
+func (c *ClosingComplete) MsgType() MessageType {
+	return MsgClosingComplete
+}
@@ -29,6 +29,11 @@ type ClosingSigned struct {
 	// Signature is for the proposed channel close transaction.
 	Signature Sig
 
+	// FeeRange is the range of fees the sender is willing to accept for the
+	// closing transaction. It is carried as an optional TLV record within
+	// ExtraData. If it isn't set, the legacy fee negotiation is used.
+	FeeRange *FeeRange
+
 	// ExtraData is the set of data that was appended to this message to
 	// fill out the full maximum transport message size. These fields can
 	// be used to specify optional data such as custom TLV fields.
@@ -55,9 +60,29 @@ var _ Message = (*ClosingSigned)(nil)
 //
 // This is part of the lnwire.Message interface.
 func (c *ClosingSigned) Decode(r io.Reader, pver uint32) error {
-	return ReadElements(
-		r, &c.ChannelID, &c.FeeSatoshis, &c.Signature, &c.ExtraData,
+	var tlvRecords ExtraOpaqueData
+	err := ReadElements(
+		r, &c.ChannelID, &c.FeeSatoshis, &c.Signature, &tlvRecords,
 	)
+	if err != nil {
+		return err
+	}
+
+	var feeRange FeeRange
+	typeMap, err := tlvRecords.ExtractRecords(&feeRange)
+	if err != nil {
+		return err
+	}
+
+	// We'll only set FeeRange if the corresponding TLV type was included
+	// in the stream.
+	if val, ok := typeMap[FeeRangeRecordType]; ok && val == nil {
+		c.FeeRange = &feeRange
+	}
+
+	c.ExtraData = tlvRecords
+
+	return nil
 }
 
 // Encode serializes the target ClosingSigned into the passed io.Writer
@@ -77,6 +102,14 @@ func (c *ClosingSigned) Encode(w *bytes.Buffer, pver uint32) error {
 		return err
 	}
 
+	// We'll only encode the FeeRange in a TLV segment if it exists.
+	if c.FeeRange != nil {
+		err := EncodeMessageExtraData(&c.ExtraData, c.FeeRange)
+		if err != nil {
+			return err
+		}
+	}
+
 	return WriteBytes(w, c.ExtraData)
 }
 
@@ -0,0 +1,140 @@
+package lnwire
+
+import (
+	"io"
+
+	"github.com/brsuite/broln/tlv"
+)
+
+const (
+	// CloserNoCloseeRecordType is the type of the record carrying a
+	// signature for a closing transaction that only pays out to the
+	// closer.
+	CloserNoCloseeRecordType tlv.Type = 1
+
+	// NoCloserCloseeRecordType is the type of the record carrying a
+	// signature for a closing transaction that only pays out to the
+	// closee.
+	NoCloserCloseeRecordType tlv.Type = 2
+
+	// CloserAndCloseeRecordType is the type of the record carrying a
+	// signature for a closing transaction that pays out to both parties.
+	CloserAndCloseeRecordType tlv.Type = 3
+)
+
+// ClosingSigs houses the signatures sent within a closing_complete or
+// closing_sig message. Each signature covers a different variant of the
+// closing transaction, which differ in whether the output of either party is
+// omitted as it's below the dust limit. Each signature is carried as an
+// optional TLV record.
+type ClosingSigs struct {
+	// CloserNoClosee is a signature for a closing transaction that omits
+	// the output of the closee.
+	CloserNoClosee *Sig
+
+	// NoCloserClosee is a signature for a closing transaction that omits
+	// the output of the closer.
+	NoCloserClosee *Sig
+
+	// CloserAndClosee is a signature for a closing transaction that pays
+	// out to both parties.
+	CloserAndClosee *Sig
+}
+
+// closingSigRecord is a record producer for a single signature of a
+// ClosingSigs set.
+type closingSigRecord struct {
+	recordType tlv.Type
+	sig        *Sig
+}
+
+// Record returns a TLV record that can be used to encode/decode the
+// signature from a given TLV stream.
+func (c *closingSigRecord) Record() tlv.Record {
+	return tlv.MakeStaticRecord(
+		c.recordType, c.sig, 64, closingSigEncoder, closingSigDecoder,
+	)
+}
+
+// closingSigEncoder is a custom TLV encoder for a closing signature record.
+func closingSigEncoder(w io.Writer, val interface{}, buf *[8]byte) error {
+	if v, ok := val.(*Sig); ok {
+		bytes := [64]byte(*v)
+		return tlv.EBytes64(w, &bytes, buf)
+	}
+
+	return tlv.NewTypeForEncodingErr(val, "lnwire.Sig")
+}
+
+// closingSigDecoder is a custom TLV decoder for a closing signature record.
+func closingSigDecoder(r io.Reader, val interface{}, buf *[8]byte,
+	l uint64) error {
+
+	if v, ok := val.(*Sig); ok {
+		var bytes [64]byte
+		if err := tlv.DBytes64(r, &bytes, buf, l); err != nil {
+			return err
+		}
+		*v = Sig(bytes)
+		return nil
+	}
+
+	return tlv.NewTypeForDecodingErr(val, "lnwire.Sig", l, 64)
+}
+
+// encode packs the signatures that are set into the given extra data. If no
+// signature is set, the extra data is left untouched.
+func (c *ClosingSigs) encode(extraData *ExtraOpaqueData) error {
+	var producers []tlv.RecordProducer
+	if c.CloserNoClosee != nil {
+		producers = append(producers, &closingSigRecord{
+			recordType: CloserNoCloseeRecordType,
+			sig:        c.CloserNoClosee,
+		})
+	}
+	if c.NoCloserClosee != nil {
+		producers = append(producers, &closingSigRecord{
+			recordType: NoCloserCloseeRecordType,
+			sig:        c.NoCloserClosee,
+		})
+	}
+	if c.CloserAndClosee != nil {
+		producers = append(producers, &closingSigRecord{
+			recordType: CloserAndCloseeRecordType,
+			sig:        c.CloserAndClosee,
+		})
+	}
+
+	if len(producers) == 0 {
+		return nil
+	}
+
+	return EncodeMessageExtraData(extraData, producers...)
+}
+
+// decode extracts the signatures contained within the given TLV records.
+// Signatures whose type isn't included in the stream are left unset.
+func (c *ClosingSigs) decode(tlvRecords ExtraOpaqueData) error {
+	var closerNoClosee, noCloserClosee, closerAndClosee Sig
+	typeMap, err := tlvRecords.ExtractRecords(
+		&closingSigRecord{CloserNoCloseeRecordType, &closerNoClosee},
+		&closingSigRecord{NoCloserCloseeRecordType, &noCloserClosee},
+		&closingSigRecord{CloserAndCloseeRecordType, &closerAndClosee},
+	)
+	if err != nil {
+		return err
+	}
+
+	*c = ClosingSigs{}
+	if val, ok := typeMap[CloserNoCloseeRecordType]; ok && val == nil {
+		c.CloserNoClosee = &closerNoClosee
+	}
+	if val, ok := typeMap[NoCloserCloseeRecordType]; ok && val == nil {
+		c.NoCloserClosee = &noCloserClosee
+	}
+	if val, ok := typeMap[CloserAndCloseeRecordType]; ok && val == nil {
+		c.CloserAndClosee = &closerAndClosee
+	}
+
+	return nil
+}
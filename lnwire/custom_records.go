@@ -0,0 +1,74 @@
+package lnwire
+
+import (
+	"fmt"
+
+	"github.com/brsuite/broln/tlv"
+)
+
+const (
+	// MinCustomRecordsTlvType is the start of the custom TLV type range as
+	// defined in BOLT 01. Records within this range are free to be used by
+	// applications built on top of the protocol.
+	MinCustomRecordsTlvType = 65536
+)
+
+// CustomRecords stores a set of custom key/value pairs that are carried
+// within the extra data of a message.
+type CustomRecords map[uint64][]byte
+
+// Validate checks that all custom records are in the custom type range.
+func (c CustomRecords) Validate() error {
+	for key := range c {
+		if key < MinCustomRecordsTlvType {
+			return fmt.Errorf("no custom records with types below "+
+				"%v allowed", MinCustomRecordsTlvType)
+		}
+	}
+
+	return nil
+}
+
+// RecordProducers returns a record producer for each of the custom records,
+// so they can be packed into the extra data of a message.
+func (c CustomRecords) RecordProducers() []tlv.RecordProducer {
+	producers := make([]tlv.RecordProducer, 0, len(c))
+	for key, value := range c {
+		producers = append(producers, &customRecordProducer{
+			typ:   tlv.Type(key),
+			value: value,
+		})
+	}
+
+	return producers
+}
+
+// ParseCustomRecords extracts the custom records from the type map returned
+// when decoding the extra data of a message. Nil is returned if the message
+// carried no custom records.
+func ParseCustomRecords(typeMap tlv.TypeMap) CustomRecords {
+	var records CustomRecords
+	for typ, value := range typeMap {
+		if typ < MinCustomRecordsTlvType || value == nil {
+			continue
+		}
+
+		if records == nil {
+			records = make(CustomRecords)
+		}
+		records[uint64(typ)] = value
+	}
+
+	return records
+}
+
+// customRecordProducer is a tlv.RecordProducer for a single custom record.
+type customRecordProducer struct {
+	typ   tlv.Type
+	value []byte
+}
+
+// Record returns a TLV record that can be used to encode the custom record.
+func (c *customRecordProducer) Record() tlv.Record {
+	return tlv.MakePrimitiveRecord(c.typ, &c.value)
+}
@@ -0,0 +1,47 @@
+package lnwire
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestCustomRecordsEncodeDecode tests that we're able to properly encode and
+// decode custom records within TLV streams, and that records outside of the
+// custom type range are refused.
+func TestCustomRecordsEncodeDecode(t *testing.T) {
+	t.Parallel()
+
+	records := CustomRecords{
+		MinCustomRecordsTlvType:     []byte{1, 2, 3},
+		MinCustomRecordsTlvType + 1: []byte{4, 5, 6},
+	}
+	require.NoError(t, records.Validate())
+
+	// Custom records should be packed alongside any other records.
+	pubKey, err := randPubKey()
+	require.NoError(t, err)
+	blindingPoint := BlindingPoint(*pubKey)
+
+	var extraData ExtraOpaqueData
+	require.NoError(t, extraData.PackRecords(
+		append(records.RecordProducers(), &blindingPoint)...,
+	))
+
+	var blindingPoint2 BlindingPoint
+	tlvs, err := extraData.ExtractRecords(&blindingPoint2)
+	require.NoError(t, err)
+
+	require.Equal(t, records, ParseCustomRecords(tlvs))
+	require.Equal(t, blindingPoint, blindingPoint2)
+
+	// A stream without custom records should result in no records at all.
+	require.NoError(t, extraData.PackRecords(&blindingPoint))
+	tlvs, err = extraData.ExtractRecords(&blindingPoint2)
+	require.NoError(t, err)
+	require.Nil(t, ParseCustomRecords(tlvs))
+
+	// Finally, records below the custom type range are invalid.
+	records[MinCustomRecordsTlvType-1] = []byte{7}
+	require.Error(t, records.Validate())
+}
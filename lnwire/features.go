@@ -129,6 +129,26 @@ const (
 	// transactions, which also imply anchor commitments.
 	AnchorsZeroFeeHtlcTxOptional FeatureBit = 23
 
+	// RouteBlindingRequired is a required feature bit that signals that
+	// the node is able to forward payments within blinded routes, as
+	// defined in BOLT 04.
+	RouteBlindingRequired FeatureBit = 24
+
+	// RouteBlindingOptional is an optional feature bit that signals that
+	// the node is able to forward payments within blinded routes, as
+	// defined in BOLT 04.
+	RouteBlindingOptional FeatureBit = 25
+
+	// DualFundRequired is a required feature bit that signals that the
+	// node is able to open dual funded channels, constructing the funding
+	// transaction interactively with the remote party.
+	DualFundRequired FeatureBit = 28
+
+	// DualFundOptional is an optional feature bit that signals that the
+	// node is able to open dual funded channels, constructing the funding
+	// transaction interactively with the remote party.
+	DualFundOptional FeatureBit = 29
+
 	// AMPRequired is a required feature bit that signals that the receiver
 	// of a payment supports accepts spontaneous payments, i.e.
 	// sender-generated preimages according to BOLT XX.
@@ -139,6 +159,26 @@ const (
 	// sender-generated preimages according to BOLT XX.
 	AMPOptional FeatureBit = 31
 
+	// OnionMessagesRequired is a required feature bit that signals that
+	// the node is able to forward onion messages which aren't tied to an
+	// HTLC, as defined in BOLT 04.
+	OnionMessagesRequired FeatureBit = 38
+
+	// OnionMessagesOptional is an optional feature bit that signals that
+	// the node is able to forward onion messages which aren't tied to an
+	// HTLC, as defined in BOLT 04.
+	OnionMessagesOptional FeatureBit = 39
+
+	// ProvideStorageRequired is a required feature bit that signals that
+	// the node stores small encrypted backups on behalf of its channel
+	// peers, and hands them back when they reconnect.
+	ProvideStorageRequired FeatureBit = 42
+
+	// ProvideStorageOptional is an optional feature bit that signals that
+	// the node stores small encrypted backups on behalf of its channel
+	// peers, and hands them back when they reconnect.
+	ProvideStorageOptional FeatureBit = 43
+
 	// ExplicitChannelTypeRequired is a required bit that denotes that a
 	// connection established with this node is to use explicit channel
 	// commitment types for negotiation instead of the existing implicit
@@ -155,6 +195,46 @@ const (
 	// TODO: Decide on actual feature bit value.
 	ExplicitChannelTypeOptional = 45
 
+	// ScidAliasRequired is a required feature bit that signals that the
+	// node requires understanding of ShortChannelID aliases in the TLV
+	// segment of the funding_locked message.
+	ScidAliasRequired FeatureBit = 46
+
+	// ScidAliasOptional is an optional feature bit that signals that the
+	// node understands ShortChannelID aliases in the TLV segment of the
+	// funding_locked message.
+	ScidAliasOptional FeatureBit = 47
+
+	// ZeroConfRequired is a required feature bit that signals that the
+	// node requires understanding of the zero-conf channel_type.
+	ZeroConfRequired FeatureBit = 50
+
+	// ZeroConfOptional is an optional feature bit that signals that the
+	// node understands the zero-conf channel type, allowing a channel to
+	// be used before its funding transaction has confirmed.
+	ZeroConfOptional FeatureBit = 51
+
+	// SimpleCloseRequired is a required feature bit that signals that the
+	// node requires closing channels cooperatively using the
+	// closing_complete and closing_sig messages, in which each party pays
+	// the fee of the closing transactions it proposes.
+	SimpleCloseRequired FeatureBit = 60
+
+	// SimpleCloseOptional is an optional feature bit that signals that
+	// the node supports closing channels cooperatively using replaceable
+	// closing transactions proposed with the closing_complete and
+	// closing_sig messages.
+	SimpleCloseOptional FeatureBit = 61
+
+	// SpliceRequired is a required feature bit that signals that the node
+	// requires support for splicing funds into and out of existing
+	// channels.
+	SpliceRequired FeatureBit = 62
+
+	// SpliceOptional is an optional feature bit that signals that the node
+	// supports splicing funds into and out of existing channels.
+	SpliceOptional FeatureBit = 63
+
 	// ScriptEnforcedLeaseOptional is an optional feature bit that signals
 	// that the node requires channels having zero-fee second-level HTLC
 	// transactions, which also imply anchor commitments, along with an
@@ -214,14 +294,30 @@ var Features = map[FeatureBit]string{
 	AnchorsOptional:               "anchor-commitments",
 	AnchorsZeroFeeHtlcTxRequired:  "anchors-zero-fee-htlc-tx",
 	AnchorsZeroFeeHtlcTxOptional:  "anchors-zero-fee-htlc-tx",
+	RouteBlindingRequired:         "route-blinding",
+	RouteBlindingOptional:         "route-blinding",
+	DualFundRequired:              "dual-fund",
+	DualFundOptional:              "dual-fund",
 	WumboChannelsRequired:         "wumbo-channels",
 	WumboChannelsOptional:         "wumbo-channels",
 	AMPRequired:                   "amp",
 	AMPOptional:                   "amp",
+	OnionMessagesRequired:         "onion-messages",
+	OnionMessagesOptional:         "onion-messages",
+	ProvideStorageRequired:        "provide-storage",
+	ProvideStorageOptional:        "provide-storage",
 	ExplicitChannelTypeOptional:   "explicit-commitment-type",
 	ExplicitChannelTypeRequired:   "explicit-commitment-type",
 	ScriptEnforcedLeaseRequired:   "script-enforced-lease",
 	ScriptEnforcedLeaseOptional:   "script-enforced-lease",
+	ScidAliasRequired:             "scid-alias",
+	ScidAliasOptional:             "scid-alias",
+	ZeroConfRequired:              "zero-conf",
+	ZeroConfOptional:              "zero-conf",
+	SimpleCloseRequired:           "simple-close",
+	SimpleCloseOptional:           "simple-close",
+	SpliceRequired:                "splice",
+	SpliceOptional:                "splice",
 }
 
 // RawFeatureVector represents a set of feature bits as defined in BOLT-09.  A
@@ -0,0 +1,233 @@
+package lnwire
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+
+	"github.com/brsuite/brond/btcec"
+	"github.com/brsuite/bronutil"
+	"github.com/brsuite/broln/tlv"
+)
+
+const (
+	// RequestFundsRecordType is the type of the experimental record used
+	// within the OpenChannel2 message to ask the remote party to
+	// contribute funds to the channel.
+	//
+	// TODO: Decide on actual TLV type once liquidity ads are in the spec.
+	RequestFundsRecordType tlv.Type = 1337
+
+	// WillFundRecordType is the type of the experimental record used
+	// within the AcceptChannel2 message to commit to the lease rates the
+	// remote party will be charged for our contribution.
+	WillFundRecordType tlv.Type = 1337
+
+	// leaseRatesSize is the size of an encoded LeaseRates in bytes.
+	leaseRatesSize = 14
+
+	// requestFundsSize is the size of an encoded RequestFunds in bytes.
+	requestFundsSize = 12
+
+	// willFundSize is the size of an encoded WillFund in bytes.
+	willFundSize = 64 + leaseRatesSize
+
+	// willFundSigTag is the tag that is prepended to the message signed
+	// by the node committing to a set of lease rates.
+	willFundSigTag = "option_will_fund"
+)
+
+// LeaseRates describes the terms under which a node is willing to contribute
+// funds to a dual funded channel opened by a remote party. This is the
+// liquidity ad a node advertises.
+type LeaseRates struct {
+	// FundingWeight is the weight the leasing party's inputs and change
+	// output are expected to add to the funding transaction. The opener
+	// pays for this weight at the negotiated funding fee rate.
+	FundingWeight uint16
+
+	// LeaseFeeBasis is the proportional lease fee expressed in basis
+	// points of the requested amount.
+	LeaseFeeBasis uint16
+
+	// ChannelFeeMaxProportional is the maximum proportional routing fee,
+	// in thousandths of the base fee, the leasing party commits to charge
+	// on the channel.
+	ChannelFeeMaxProportional uint16
+
+	// LeaseFeeBaseSat is the flat lease fee charged for every lease.
+	LeaseFeeBaseSat uint32
+
+	// ChannelFeeMaxBaseMsat is the maximum base routing fee the leasing
+	// party commits to charge on the channel.
+	ChannelFeeMaxBaseMsat uint32
+}
+
+// LeaseFee returns the fee the opener of a channel pays for having the passed
+// amount leased to it, given the funding transaction's fee rate in sat/kw.
+func (l *LeaseRates) LeaseFee(requested bronutil.Amount,
+	feePerKw uint32) bronutil.Amount {
+
+	fee := bronutil.Amount(l.LeaseFeeBaseSat)
+	fee += requested * bronutil.Amount(l.LeaseFeeBasis) / 10000
+	fee += bronutil.Amount(l.FundingWeight) * bronutil.Amount(feePerKw) /
+		1000
+
+	return fee
+}
+
+// encode writes the serialized lease rates to the passed writer.
+func (l *LeaseRates) encode(w io.Writer) error {
+	var b [leaseRatesSize]byte
+	binary.BigEndian.PutUint16(b[0:2], l.FundingWeight)
+	binary.BigEndian.PutUint16(b[2:4], l.LeaseFeeBasis)
+	binary.BigEndian.PutUint16(b[4:6], l.ChannelFeeMaxProportional)
+	binary.BigEndian.PutUint32(b[6:10], l.LeaseFeeBaseSat)
+	binary.BigEndian.PutUint32(b[10:14], l.ChannelFeeMaxBaseMsat)
+
+	_, err := w.Write(b[:])
+	return err
+}
+
+// decode reads serialized lease rates from the passed reader.
+func (l *LeaseRates) decode(r io.Reader) error {
+	var b [leaseRatesSize]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return err
+	}
+
+	l.FundingWeight = binary.BigEndian.Uint16(b[0:2])
+	l.LeaseFeeBasis = binary.BigEndian.Uint16(b[2:4])
+	l.ChannelFeeMaxProportional = binary.BigEndian.Uint16(b[4:6])
+	l.LeaseFeeBaseSat = binary.BigEndian.Uint32(b[6:10])
+	l.ChannelFeeMaxBaseMsat = binary.BigEndian.Uint32(b[10:14])
+
+	return nil
+}
+
+// SigDigest returns the digest the leasing party signs to commit to these
+// lease rates for a lease that starts at the given block height and whose
+// funds are locked to the given funding key.
+func (l *LeaseRates) SigDigest(fundingKey *btcec.PublicKey,
+	blockHeight uint32) [32]byte {
+
+	var b bytes.Buffer
+	b.WriteString(willFundSigTag)
+	b.Write(fundingKey.SerializeCompressed())
+
+	var height [4]byte
+	binary.BigEndian.PutUint32(height[:], blockHeight)
+	b.Write(height[:])
+
+	// Writing to a bytes.Buffer never fails.
+	_ = l.encode(&b)
+
+	return sha256.Sum256(b.Bytes())
+}
+
+// RequestFunds is the record the opener of a dual funded channel attaches to
+// its OpenChannel2 message to lease liquidity from the remote party.
+type RequestFunds struct {
+	// RequestedSats is the amount the opener would like the remote party
+	// to contribute to the channel.
+	RequestedSats bronutil.Amount
+
+	// BlockHeight is the height the lease starts at. The remote party
+	// commits to its lease rates at this height.
+	BlockHeight uint32
+}
+
+// Record returns a TLV record that can be used to encode/decode the
+// RequestFunds type from a given TLV stream.
+func (r *RequestFunds) Record() tlv.Record {
+	return tlv.MakeStaticRecord(
+		RequestFundsRecordType, r, requestFundsSize,
+		requestFundsEncoder, requestFundsDecoder,
+	)
+}
+
+// requestFundsEncoder is a custom TLV encoder for the RequestFunds record.
+func requestFundsEncoder(w io.Writer, val interface{}, buf *[8]byte) error {
+	if v, ok := val.(*RequestFunds); ok {
+		err := tlv.EUint64T(w, uint64(v.RequestedSats), buf)
+		if err != nil {
+			return err
+		}
+
+		return tlv.EUint32T(w, v.BlockHeight, buf)
+	}
+
+	return tlv.NewTypeForEncodingErr(val, "lnwire.RequestFunds")
+}
+
+// requestFundsDecoder is a custom TLV decoder for the RequestFunds record.
+func requestFundsDecoder(r io.Reader, val interface{}, buf *[8]byte,
+	l uint64) error {
+
+	if v, ok := val.(*RequestFunds); ok && l == requestFundsSize {
+		var sats uint64
+		if err := tlv.DUint64(r, &sats, buf, 8); err != nil {
+			return err
+		}
+		if err := tlv.DUint32(r, &v.BlockHeight, buf, 4); err != nil {
+			return err
+		}
+		v.RequestedSats = bronutil.Amount(sats)
+
+		return nil
+	}
+
+	return tlv.NewTypeForDecodingErr(
+		val, "lnwire.RequestFunds", l, requestFundsSize,
+	)
+}
+
+// WillFund is the record the accepting party of a dual funded channel
+// attaches to its AcceptChannel2 message when it contributes funds in
+// response to a RequestFunds record.
+type WillFund struct {
+	// Signature is the signature of the accepting party's node key over
+	// the lease rates, see LeaseRates.SigDigest.
+	Signature Sig
+
+	// LeaseRates are the rates the opener is charged for the lease.
+	LeaseRates LeaseRates
+}
+
+// Record returns a TLV record that can be used to encode/decode the WillFund
+// type from a given TLV stream.
+func (w *WillFund) Record() tlv.Record {
+	return tlv.MakeStaticRecord(
+		WillFundRecordType, w, willFundSize, willFundEncoder,
+		willFundDecoder,
+	)
+}
+
+// willFundEncoder is a custom TLV encoder for the WillFund record.
+func willFundEncoder(w io.Writer, val interface{}, _ *[8]byte) error {
+	if v, ok := val.(*WillFund); ok {
+		if _, err := w.Write(v.Signature[:]); err != nil {
+			return err
+		}
+
+		return v.LeaseRates.encode(w)
+	}
+
+	return tlv.NewTypeForEncodingErr(val, "lnwire.WillFund")
+}
+
+// willFundDecoder is a custom TLV decoder for the WillFund record.
+func willFundDecoder(r io.Reader, val interface{}, _ *[8]byte,
+	l uint64) error {
+
+	if v, ok := val.(*WillFund); ok && l == willFundSize {
+		if _, err := io.ReadFull(r, v.Signature[:]); err != nil {
+			return err
+		}
+
+		return v.LeaseRates.decode(r)
+	}
+
+	return tlv.NewTypeForDecodingErr(val, "lnwire.WillFund", l, willFundSize)
+}
@@ -0,0 +1,81 @@
+package lnwire
+
+import (
+	"testing"
+
+	"github.com/brsuite/brond/btcec"
+	"github.com/brsuite/bronutil"
+	"github.com/stretchr/testify/require"
+)
+
+// TestLiquidityAdsEncodeDecode tests that we're able to properly encode and
+// decode the liquidity ad records within TLV streams.
+func TestLiquidityAdsEncodeDecode(t *testing.T) {
+	t.Parallel()
+
+	requestFunds := RequestFunds{
+		RequestedSats: 1_000_000,
+		BlockHeight:   700_000,
+	}
+	willFund := WillFund{
+		LeaseRates: LeaseRates{
+			FundingWeight:             444,
+			LeaseFeeBasis:             65,
+			ChannelFeeMaxProportional: 10,
+			LeaseFeeBaseSat:           2000,
+			ChannelFeeMaxBaseMsat:     1000,
+		},
+	}
+	willFund.Signature[0] = 0x01
+
+	var extraData ExtraOpaqueData
+	require.NoError(t, extraData.PackRecords(&requestFunds))
+
+	var requestFunds2 RequestFunds
+	tlvs, err := extraData.ExtractRecords(&requestFunds2)
+	require.NoError(t, err)
+	require.Contains(t, tlvs, RequestFundsRecordType)
+	require.Equal(t, requestFunds, requestFunds2)
+
+	require.NoError(t, extraData.PackRecords(&willFund))
+
+	var willFund2 WillFund
+	tlvs, err = extraData.ExtractRecords(&willFund2)
+	require.NoError(t, err)
+	require.Contains(t, tlvs, WillFundRecordType)
+	require.Equal(t, willFund, willFund2)
+}
+
+// TestLeaseFee tests the computation of the fee charged for a lease.
+func TestLeaseFee(t *testing.T) {
+	t.Parallel()
+
+	rates := LeaseRates{
+		FundingWeight:   500,
+		LeaseFeeBasis:   100,
+		LeaseFeeBaseSat: 1000,
+	}
+
+	// The base fee, one percent of the requested amount and the fee for
+	// 500 weight units at 2000 sat/kw.
+	fee := rates.LeaseFee(500_000, 2000)
+	require.Equal(t, bronutil.Amount(1000+5000+1000), fee)
+}
+
+// TestLeaseRatesSigDigest tests that the digest signed to commit to a set of
+// lease rates covers all of its inputs.
+func TestLeaseRatesSigDigest(t *testing.T) {
+	t.Parallel()
+
+	priv, err := btcec.NewPrivateKey(btcec.S256())
+	require.NoError(t, err)
+
+	rates := LeaseRates{LeaseFeeBasis: 100, LeaseFeeBaseSat: 1000}
+	digest := rates.SigDigest(priv.PubKey(), 100)
+
+	require.Equal(t, digest, rates.SigDigest(priv.PubKey(), 100))
+	require.NotEqual(t, digest, rates.SigDigest(priv.PubKey(), 101))
+
+	rates.LeaseFeeBaseSat++
+	require.NotEqual(t, digest, rates.SigDigest(priv.PubKey(), 100))
+}
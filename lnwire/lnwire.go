@@ -202,6 +202,16 @@ func WriteElement(w *bytes.Buffer, element interface{}) error {
 			return err
 		}
 
+		if _, err := w.Write(e[:]); err != nil {
+			return err
+		}
+	case PeerStorageBlob:
+		var l [2]byte
+		binary.BigEndian.PutUint16(l[:], uint16(len(e)))
+		if _, err := w.Write(l[:]); err != nil {
+			return err
+		}
+
 		if _, err := w.Write(e[:]); err != nil {
 			return err
 		}
@@ -601,6 +611,17 @@ func ReadElement(r io.Reader, element interface{}) error {
 		if _, err := io.ReadFull(r, *e); err != nil {
 			return err
 		}
+	case *PeerStorageBlob:
+		var l [2]byte
+		if _, err := io.ReadFull(r, l[:]); err != nil {
+			return err
+		}
+		blobLen := binary.BigEndian.Uint16(l[:])
+
+		*e = PeerStorageBlob(make([]byte, blobLen))
+		if _, err := io.ReadFull(r, *e); err != nil {
+			return err
+		}
 	case *PingPayload:
 		var l [2]byte
 		if _, err := io.ReadFull(r, l[:]); err != nil {
@@ -76,6 +76,28 @@ func randDeliveryAddress(r *rand.Rand) (DeliveryAddress, error) {
 	return da, err
 }
 
+// randClosingSigs returns a set of closing signatures in which each signature
+// is set with a probability of one half.
+func randClosingSigs(r *rand.Rand) (ClosingSigs, error) {
+	var sigs ClosingSigs
+	for _, sig := range []**Sig{
+		&sigs.CloserNoClosee, &sigs.NoCloserClosee,
+		&sigs.CloserAndClosee,
+	} {
+		if r.Intn(2) == 0 {
+			continue
+		}
+
+		var s Sig
+		if _, err := r.Read(s[:]); err != nil {
+			return sigs, err
+		}
+		*sig = &s
+	}
+
+	return sigs, nil
+}
+
 func randRawFeatureVector(r *rand.Rand) *RawFeatureVector {
 	featureVec := NewRawFeatureVector()
 	for i := 0; i < 10000; i++ {
@@ -456,6 +478,252 @@ func TestLightningWireProtocol(t *testing.T) {
 
 			v[0] = reflect.ValueOf(req)
 		},
+		MsgOpenChannel2: func(v []reflect.Value, r *rand.Rand) {
+			req := OpenChannel2{
+				FundingFeePerKWeight: uint32(r.Int31()),
+				CommitFeePerKWeight:  uint32(r.Int31()),
+				FundingAmount:        bronutil.Amount(r.Int63()),
+				DustLimit:            bronutil.Amount(r.Int63()),
+				MaxValueInFlight:     MilliSatoshi(r.Int63()),
+				HtlcMinimum:          MilliSatoshi(r.Int31()),
+				CsvDelay:             uint16(r.Int31()),
+				MaxAcceptedHTLCs:     uint16(r.Int31()),
+				LockTime:             uint32(r.Int31()),
+				ChannelFlags:         FundingFlag(uint8(r.Int31())),
+			}
+
+			if _, err := r.Read(req.ChainHash[:]); err != nil {
+				t.Fatalf("unable to generate chain hash: %v", err)
+				return
+			}
+			if _, err := r.Read(req.PendingChannelID[:]); err != nil {
+				t.Fatalf("unable to generate pending chan id: %v", err)
+				return
+			}
+
+			keys := []**btcec.PublicKey{
+				&req.FundingKey, &req.RevocationPoint,
+				&req.PaymentPoint, &req.DelayedPaymentPoint,
+				&req.HtlcPoint, &req.FirstCommitmentPoint,
+			}
+			for _, key := range keys {
+				var err error
+				*key, err = randPubKey()
+				if err != nil {
+					t.Fatalf("unable to generate key: %v", err)
+					return
+				}
+			}
+
+			// 1/2 chance empty TLV records.
+			if r.Intn(2) == 0 {
+				var err error
+				req.UpfrontShutdownScript, err = randDeliveryAddress(r)
+				if err != nil {
+					t.Fatalf("unable to generate delivery address: %v", err)
+					return
+				}
+
+				req.ChannelType = new(ChannelType)
+				*req.ChannelType = ChannelType(*randRawFeatureVector(r))
+
+				req.RequestFunds = &RequestFunds{
+					RequestedSats: bronutil.Amount(r.Int63()),
+					BlockHeight:   uint32(r.Int31()),
+				}
+			} else {
+				req.UpfrontShutdownScript = []byte{}
+			}
+
+			v[0] = reflect.ValueOf(req)
+		},
+		MsgAcceptChannel2: func(v []reflect.Value, r *rand.Rand) {
+			req := AcceptChannel2{
+				FundingAmount:    bronutil.Amount(r.Int63()),
+				DustLimit:        bronutil.Amount(r.Int63()),
+				MaxValueInFlight: MilliSatoshi(r.Int63()),
+				HtlcMinimum:      MilliSatoshi(r.Int31()),
+				MinAcceptDepth:   uint32(r.Int31()),
+				CsvDelay:         uint16(r.Int31()),
+				MaxAcceptedHTLCs: uint16(r.Int31()),
+			}
+
+			if _, err := r.Read(req.PendingChannelID[:]); err != nil {
+				t.Fatalf("unable to generate pending chan id: %v", err)
+				return
+			}
+
+			keys := []**btcec.PublicKey{
+				&req.FundingKey, &req.RevocationPoint,
+				&req.PaymentPoint, &req.DelayedPaymentPoint,
+				&req.HtlcPoint, &req.FirstCommitmentPoint,
+			}
+			for _, key := range keys {
+				var err error
+				*key, err = randPubKey()
+				if err != nil {
+					t.Fatalf("unable to generate key: %v", err)
+					return
+				}
+			}
+
+			// 1/2 chance empty TLV records.
+			if r.Intn(2) == 0 {
+				var err error
+				req.UpfrontShutdownScript, err = randDeliveryAddress(r)
+				if err != nil {
+					t.Fatalf("unable to generate delivery address: %v", err)
+					return
+				}
+
+				req.ChannelType = new(ChannelType)
+				*req.ChannelType = ChannelType(*randRawFeatureVector(r))
+
+				req.WillFund = &WillFund{
+					LeaseRates: LeaseRates{
+						FundingWeight:             uint16(r.Int31()),
+						LeaseFeeBasis:             uint16(r.Int31()),
+						ChannelFeeMaxProportional: uint16(r.Int31()),
+						LeaseFeeBaseSat:           uint32(r.Int31()),
+						ChannelFeeMaxBaseMsat:     uint32(r.Int31()),
+					},
+				}
+				req.WillFund.Signature, err = NewSigFromSignature(
+					testSig,
+				)
+				if err != nil {
+					t.Fatalf("unable to parse sig: %v", err)
+					return
+				}
+			} else {
+				req.UpfrontShutdownScript = []byte{}
+			}
+
+			v[0] = reflect.ValueOf(req)
+		},
+		MsgTxAddInput: func(v []reflect.Value, r *rand.Rand) {
+			req := TxAddInput{
+				SerialID:  uint64(r.Int63()),
+				PrevTxOut: uint32(r.Int31()),
+				Sequence:  uint32(r.Int31()),
+				ExtraData: make([]byte, 0),
+			}
+
+			if _, err := r.Read(req.ChanID[:]); err != nil {
+				t.Fatalf("unable to generate chan id: %v", err)
+				return
+			}
+
+			// Serialize a random transaction and parse it back, so
+			// the generated transaction is in its canonical decoded
+			// form.
+			prevTx := wire.NewMsgTx(2)
+			prevTx.AddTxIn(&wire.TxIn{
+				Witness: wire.TxWitness{[]byte{0x01, 0x02}},
+			})
+			prevTx.AddTxOut(wire.NewTxOut(r.Int63(), []byte{0x00}))
+
+			var b bytes.Buffer
+			if err := prevTx.Serialize(&b); err != nil {
+				t.Fatalf("unable to serialize tx: %v", err)
+				return
+			}
+			req.PrevTx = wire.NewMsgTx(2)
+			if err := req.PrevTx.Deserialize(&b); err != nil {
+				t.Fatalf("unable to deserialize tx: %v", err)
+				return
+			}
+
+			v[0] = reflect.ValueOf(req)
+		},
+		MsgTxSignatures: func(v []reflect.Value, r *rand.Rand) {
+			req := TxSignatures{
+				ExtraData: make([]byte, 0),
+			}
+
+			if _, err := r.Read(req.ChanID[:]); err != nil {
+				t.Fatalf("unable to generate chan id: %v", err)
+				return
+			}
+			if _, err := r.Read(req.TxHash[:]); err != nil {
+				t.Fatalf("unable to generate tx hash: %v", err)
+				return
+			}
+
+			numWitnesses := r.Intn(5)
+			for i := 0; i < numWitnesses; i++ {
+				witness := make(wire.TxWitness, r.Intn(4))
+				for j := range witness {
+					witness[j] = make([]byte, r.Intn(100))
+					if _, err := r.Read(witness[j]); err != nil {
+						t.Fatalf("unable to generate "+
+							"witness: %v", err)
+						return
+					}
+				}
+				req.Witnesses = append(req.Witnesses, witness)
+			}
+
+			v[0] = reflect.ValueOf(req)
+		},
+		MsgSpliceInit: func(v []reflect.Value, r *rand.Rand) {
+			req := SpliceInit{
+				FundingContribution:  r.Int63() - r.Int63(),
+				FundingFeePerKWeight: uint32(r.Int31()),
+				LockTime:             uint32(r.Int31()),
+				ExtraData:            make([]byte, 0),
+			}
+
+			if _, err := r.Read(req.ChanID[:]); err != nil {
+				t.Fatalf("unable to generate chan id: %v", err)
+				return
+			}
+
+			var err error
+			req.FundingPubKey, err = randPubKey()
+			if err != nil {
+				t.Fatalf("unable to generate key: %v", err)
+				return
+			}
+
+			v[0] = reflect.ValueOf(req)
+		},
+		MsgSpliceAck: func(v []reflect.Value, r *rand.Rand) {
+			req := SpliceAck{
+				FundingContribution: r.Int63() - r.Int63(),
+				ExtraData:           make([]byte, 0),
+			}
+
+			if _, err := r.Read(req.ChanID[:]); err != nil {
+				t.Fatalf("unable to generate chan id: %v", err)
+				return
+			}
+
+			var err error
+			req.FundingPubKey, err = randPubKey()
+			if err != nil {
+				t.Fatalf("unable to generate key: %v", err)
+				return
+			}
+
+			v[0] = reflect.ValueOf(req)
+		},
+		MsgSpliceLocked: func(v []reflect.Value, r *rand.Rand) {
+			req := SpliceLocked{
+				ExtraData: make([]byte, 0),
+			}
+
+			if _, err := r.Read(req.ChanID[:]); err != nil {
+				t.Fatalf("unable to generate chan id: %v", err)
+				return
+			}
+			if _, err := r.Read(req.SpliceTxid[:]); err != nil {
+				t.Fatalf("unable to generate txid: %v", err)
+				return
+			}
+
+			v[0] = reflect.ValueOf(req)
+		},
 		MsgFundingCreated: func(v []reflect.Value, r *rand.Rand) {
 			req := FundingCreated{
 				ExtraData: make([]byte, 0),
@@ -517,6 +785,12 @@ func TestLightningWireProtocol(t *testing.T) {
 
 			req := NewFundingLocked(ChannelID(c), pubKey)
 
+			// 1/2 chance of an alias SCID TLV record.
+			if r.Intn(2) == 0 {
+				scid := NewShortChanIDFromInt(uint64(r.Int63()))
+				req.AliasScid = &scid
+			}
+
 			v[0] = reflect.ValueOf(*req)
 		},
 		MsgClosingSigned: func(v []reflect.Value, r *rand.Rand) {
@@ -536,6 +810,129 @@ func TestLightningWireProtocol(t *testing.T) {
 				return
 			}
 
+			if r.Intn(2) == 0 {
+				req.FeeRange = &FeeRange{
+					MinFeeSats: bronutil.Amount(r.Int63()),
+					MaxFeeSats: bronutil.Amount(r.Int63()),
+				}
+			}
+
+			v[0] = reflect.ValueOf(req)
+		},
+		MsgClosingComplete: func(v []reflect.Value, r *rand.Rand) {
+			req := ClosingComplete{
+				FeeSatoshis: bronutil.Amount(r.Int63()),
+				LockTime:    r.Uint32(),
+				ExtraData:   make([]byte, 0),
+			}
+
+			if _, err := r.Read(req.ChannelID[:]); err != nil {
+				t.Fatalf("unable to generate chan id: %v", err)
+				return
+			}
+
+			var err error
+			req.CloserScript, err = randDeliveryAddress(r)
+			if err != nil {
+				t.Fatalf("unable to generate script: %v", err)
+				return
+			}
+			req.CloseeScript, err = randDeliveryAddress(r)
+			if err != nil {
+				t.Fatalf("unable to generate script: %v", err)
+				return
+			}
+
+			req.ClosingSigs, err = randClosingSigs(r)
+			if err != nil {
+				t.Fatalf("unable to generate sigs: %v", err)
+				return
+			}
+
+			v[0] = reflect.ValueOf(req)
+		},
+		MsgClosingSig: func(v []reflect.Value, r *rand.Rand) {
+			req := ClosingSig{
+				FeeSatoshis: bronutil.Amount(r.Int63()),
+				LockTime:    r.Uint32(),
+				ExtraData:   make([]byte, 0),
+			}
+
+			if _, err := r.Read(req.ChannelID[:]); err != nil {
+				t.Fatalf("unable to generate chan id: %v", err)
+				return
+			}
+
+			var err error
+			req.CloserScript, err = randDeliveryAddress(r)
+			if err != nil {
+				t.Fatalf("unable to generate script: %v", err)
+				return
+			}
+			req.CloseeScript, err = randDeliveryAddress(r)
+			if err != nil {
+				t.Fatalf("unable to generate script: %v", err)
+				return
+			}
+
+			req.ClosingSigs, err = randClosingSigs(r)
+			if err != nil {
+				t.Fatalf("unable to generate sigs: %v", err)
+				return
+			}
+
+			v[0] = reflect.ValueOf(req)
+		},
+		MsgUpdateAddHTLC: func(v []reflect.Value, r *rand.Rand) {
+			req := UpdateAddHTLC{
+				ID:        r.Uint64(),
+				Amount:    MilliSatoshi(r.Int63()),
+				Expiry:    r.Uint32(),
+				ExtraData: make([]byte, 0),
+			}
+
+			if _, err := r.Read(req.ChanID[:]); err != nil {
+				t.Fatalf("unable to generate chan id: %v", err)
+				return
+			}
+
+			if _, err := r.Read(req.PaymentHash[:]); err != nil {
+				t.Fatalf("unable to generate payment hash: %v",
+					err)
+				return
+			}
+
+			if _, err := r.Read(req.OnionBlob[:]); err != nil {
+				t.Fatalf("unable to generate onion blob: %v", err)
+				return
+			}
+
+			// 1/2 chance of a blinding point TLV record.
+			if r.Intn(2) == 0 {
+				var err error
+				req.BlindingPoint, err = randPubKey()
+				if err != nil {
+					t.Fatalf("unable to generate key: %v", err)
+					return
+				}
+			}
+
+			// 1/2 chance of custom TLV records.
+			if r.Intn(2) == 0 {
+				value := make([]byte, 32)
+				if _, err := r.Read(value); err != nil {
+					t.Fatalf("unable to generate custom "+
+						"record: %v", err)
+					return
+				}
+
+				recordType := MinCustomRecordsTlvType +
+					uint64(r.Intn(1000))
+				req.CustomRecords = CustomRecords{
+					recordType: value,
+				}
+			}
+
 			v[0] = reflect.ValueOf(req)
 		},
 		MsgCommitSig: func(v []reflect.Value, r *rand.Rand) {
@@ -880,6 +1277,54 @@ func TestLightningWireProtocol(t *testing.T) {
 				PaddingBytes: paddingBytes,
 			}
 
+			v[0] = reflect.ValueOf(req)
+		},
+		MsgPeerStorage: func(v []reflect.Value, r *rand.Rand) {
+			req := PeerStorage{
+				Blob:      make([]byte, r.Intn(MaxPeerStorageBytes)),
+				ExtraData: make([]byte, 0),
+			}
+
+			if _, err := r.Read(req.Blob); err != nil {
+				t.Fatalf("unable to generate blob: %v", err)
+				return
+			}
+
+			v[0] = reflect.ValueOf(req)
+		},
+		MsgYourPeerStorage: func(v []reflect.Value, r *rand.Rand) {
+			req := YourPeerStorage{
+				Blob:      make([]byte, r.Intn(MaxPeerStorageBytes)),
+				ExtraData: make([]byte, 0),
+			}
+
+			if _, err := r.Read(req.Blob); err != nil {
+				t.Fatalf("unable to generate blob: %v", err)
+				return
+			}
+
+			v[0] = reflect.ValueOf(req)
+		},
+		MsgOnionMessage: func(v []reflect.Value, r *rand.Rand) {
+			pathKey, err := randPubKey()
+			if err != nil {
+				t.Fatalf("unable to generate key: %v", err)
+				return
+			}
+
+			// The onion blob is length prefixed by two bytes, and
+			// shares the message body with the 33 byte path key.
+			onion := make([]byte, r.Intn(MaxMsgBody-35))
+			if _, err := r.Read(onion); err != nil {
+				t.Fatalf("unable to generate onion: %v", err)
+				return
+			}
+
+			req := OnionMessage{
+				PathKey:   pathKey,
+				OnionBlob: onion,
+			}
+
 			v[0] = reflect.ValueOf(req)
 		},
 	}
@@ -917,6 +1362,18 @@ func TestLightningWireProtocol(t *testing.T) {
 				return mainScenario(&m)
 			},
 		},
+		{
+			msgType: MsgPeerStorage,
+			scenario: func(m PeerStorage) bool {
+				return mainScenario(&m)
+			},
+		},
+		{
+			msgType: MsgYourPeerStorage,
+			scenario: func(m YourPeerStorage) bool {
+				return mainScenario(&m)
+			},
+		},
 		{
 			msgType: MsgOpenChannel,
 			scenario: func(m OpenChannel) bool {
@@ -929,6 +1386,78 @@ func TestLightningWireProtocol(t *testing.T) {
 				return mainScenario(&m)
 			},
 		},
+		{
+			msgType: MsgOpenChannel2,
+			scenario: func(m OpenChannel2) bool {
+				return mainScenario(&m)
+			},
+		},
+		{
+			msgType: MsgAcceptChannel2,
+			scenario: func(m AcceptChannel2) bool {
+				return mainScenario(&m)
+			},
+		},
+		{
+			msgType: MsgTxAddInput,
+			scenario: func(m TxAddInput) bool {
+				return mainScenario(&m)
+			},
+		},
+		{
+			msgType: MsgTxAddOutput,
+			scenario: func(m TxAddOutput) bool {
+				return mainScenario(&m)
+			},
+		},
+		{
+			msgType: MsgTxRemoveInput,
+			scenario: func(m TxRemoveInput) bool {
+				return mainScenario(&m)
+			},
+		},
+		{
+			msgType: MsgTxRemoveOutput,
+			scenario: func(m TxRemoveOutput) bool {
+				return mainScenario(&m)
+			},
+		},
+		{
+			msgType: MsgTxComplete,
+			scenario: func(m TxComplete) bool {
+				return mainScenario(&m)
+			},
+		},
+		{
+			msgType: MsgTxSignatures,
+			scenario: func(m TxSignatures) bool {
+				return mainScenario(&m)
+			},
+		},
+		{
+			msgType: MsgTxAbort,
+			scenario: func(m TxAbort) bool {
+				return mainScenario(&m)
+			},
+		},
+		{
+			msgType: MsgSpliceInit,
+			scenario: func(m SpliceInit) bool {
+				return mainScenario(&m)
+			},
+		},
+		{
+			msgType: MsgSpliceAck,
+			scenario: func(m SpliceAck) bool {
+				return mainScenario(&m)
+			},
+		},
+		{
+			msgType: MsgSpliceLocked,
+			scenario: func(m SpliceLocked) bool {
+				return mainScenario(&m)
+			},
+		},
 		{
 			msgType: MsgFundingCreated,
 			scenario: func(m FundingCreated) bool {
@@ -959,6 +1488,18 @@ func TestLightningWireProtocol(t *testing.T) {
 				return mainScenario(&m)
 			},
 		},
+		{
+			msgType: MsgClosingComplete,
+			scenario: func(m ClosingComplete) bool {
+				return mainScenario(&m)
+			},
+		},
+		{
+			msgType: MsgClosingSig,
+			scenario: func(m ClosingSig) bool {
+				return mainScenario(&m)
+			},
+		},
 		{
 			msgType: MsgUpdateAddHTLC,
 			scenario: func(m UpdateAddHTLC) bool {
@@ -1062,6 +1603,12 @@ func TestLightningWireProtocol(t *testing.T) {
 				return mainScenario(&m)
 			},
 		},
+		{
+			msgType: MsgOnionMessage,
+			scenario: func(m OnionMessage) bool {
+				return mainScenario(&m)
+			},
+		},
 	}
 	for _, test := range tests {
 		var config *quick.Config
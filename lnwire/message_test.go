@@ -14,6 +14,7 @@ import (
 	"testing"
 
 	"github.com/brsuite/brond/btcec"
+	"github.com/brsuite/brond/wire"
 	"github.com/brsuite/bronutil"
 	"github.com/brsuite/broln/lnwire"
 	"github.com/brsuite/broln/tor"
@@ -267,6 +268,8 @@ func makeAllMessages(t testing.TB, r *rand.Rand) []lnwire.Message {
 	msgAll = append(msgAll, newMsgError(t, r))
 	msgAll = append(msgAll, newMsgPing(t, r))
 	msgAll = append(msgAll, newMsgPong(t, r))
+	msgAll = append(msgAll, newMsgPeerStorage(t, r))
+	msgAll = append(msgAll, newMsgYourPeerStorage(t, r))
 	msgAll = append(msgAll, newMsgOpenChannel(t, r))
 	msgAll = append(msgAll, newMsgAcceptChannel(t, r))
 	msgAll = append(msgAll, newMsgFundingCreated(t, r))
@@ -274,6 +277,8 @@ func makeAllMessages(t testing.TB, r *rand.Rand) []lnwire.Message {
 	msgAll = append(msgAll, newMsgFundingLocked(t, r))
 	msgAll = append(msgAll, newMsgShutdown(t, r))
 	msgAll = append(msgAll, newMsgClosingSigned(t, r))
+	msgAll = append(msgAll, newMsgClosingComplete(t, r))
+	msgAll = append(msgAll, newMsgClosingSig(t, r))
 	msgAll = append(msgAll, newMsgUpdateAddHTLC(t, r))
 	msgAll = append(msgAll, newMsgUpdateFulfillHTLC(t, r))
 	msgAll = append(msgAll, newMsgUpdateFailHTLC(t, r))
@@ -293,6 +298,16 @@ func makeAllMessages(t testing.TB, r *rand.Rand) []lnwire.Message {
 	msgAll = append(msgAll, newMsgGossipTimestampRange(t, r))
 	msgAll = append(msgAll, newMsgQueryShortChanIDsZlib(t, r))
 	msgAll = append(msgAll, newMsgReplyChannelRangeZlib(t, r))
+	msgAll = append(msgAll, newMsgOnionMessage(t, r))
+	msgAll = append(msgAll, newMsgOpenChannel2(t, r))
+	msgAll = append(msgAll, newMsgAcceptChannel2(t, r))
+	msgAll = append(msgAll, newMsgTxAddInput(t, r))
+	msgAll = append(msgAll, newMsgTxAddOutput(t, r))
+	msgAll = append(msgAll, newMsgTxComplete(t, r))
+	msgAll = append(msgAll, newMsgTxSignatures(t, r))
+	msgAll = append(msgAll, newMsgSpliceInit(t, r))
+	msgAll = append(msgAll, newMsgSpliceAck(t, r))
+	msgAll = append(msgAll, newMsgSpliceLocked(t, r))
 
 	return msgAll
 }
@@ -396,6 +411,26 @@ func newMsgPong(t testing.TB, r io.Reader) *lnwire.Pong {
 	}
 }
 
+func newMsgPeerStorage(t testing.TB, r io.Reader) *lnwire.PeerStorage {
+	t.Helper()
+
+	return &lnwire.PeerStorage{
+		Blob:      createExtraData(t, r),
+		ExtraData: createExtraData(t, r),
+	}
+}
+
+func newMsgYourPeerStorage(t testing.TB,
+	r io.Reader) *lnwire.YourPeerStorage {
+
+	t.Helper()
+
+	return &lnwire.YourPeerStorage{
+		Blob:      createExtraData(t, r),
+		ExtraData: createExtraData(t, r),
+	}
+}
+
 func newMsgFundingCreated(t testing.TB, r *rand.Rand) *lnwire.FundingCreated {
 	t.Helper()
 
@@ -468,7 +503,53 @@ func newMsgClosingSigned(t testing.TB, r *rand.Rand) *lnwire.ClosingSigned {
 	msg := &lnwire.ClosingSigned{
 		FeeSatoshis: bronutil.Amount(r.Int63()),
 		Signature:   testNodeSig,
-		ExtraData:   createExtraData(t, r),
+		FeeRange: &lnwire.FeeRange{
+			MinFeeSats: bronutil.Amount(r.Int63()),
+			MaxFeeSats: bronutil.Amount(r.Int63()),
+		},
+		ExtraData: createExtraData(t, r),
+	}
+
+	_, err := r.Read(msg.ChannelID[:])
+	require.NoError(t, err, "unable to generate chan id")
+
+	return msg
+}
+
+func newMsgClosingComplete(t testing.TB, r *rand.Rand) *lnwire.ClosingComplete {
+	t.Helper()
+
+	sig := testNodeSig
+	msg := &lnwire.ClosingComplete{
+		CloserScript: randDeliveryAddress(t, r),
+		CloseeScript: randDeliveryAddress(t, r),
+		FeeSatoshis:  bronutil.Amount(r.Int63()),
+		LockTime:     uint32(r.Int31()),
+		ClosingSigs: lnwire.ClosingSigs{
+			CloserAndClosee: &sig,
+		},
+		ExtraData: createExtraData(t, r),
+	}
+
+	_, err := r.Read(msg.ChannelID[:])
+	require.NoError(t, err, "unable to generate chan id")
+
+	return msg
+}
+
+func newMsgClosingSig(t testing.TB, r *rand.Rand) *lnwire.ClosingSig {
+	t.Helper()
+
+	sig := testNodeSig
+	msg := &lnwire.ClosingSig{
+		CloserScript: randDeliveryAddress(t, r),
+		CloseeScript: randDeliveryAddress(t, r),
+		FeeSatoshis:  bronutil.Amount(r.Int63()),
+		LockTime:     uint32(r.Int31()),
+		ClosingSigs: lnwire.ClosingSigs{
+			CloserAndClosee: &sig,
+		},
+		ExtraData: createExtraData(t, r),
 	}
 
 	_, err := r.Read(msg.ChannelID[:])
@@ -481,10 +562,10 @@ func newMsgUpdateAddHTLC(t testing.TB, r *rand.Rand) *lnwire.UpdateAddHTLC {
 	t.Helper()
 
 	msg := &lnwire.UpdateAddHTLC{
-		ID:        r.Uint64(),
-		Amount:    lnwire.MilliSatoshi(r.Int63()),
-		Expiry:    r.Uint32(),
-		ExtraData: createExtraData(t, r),
+		ID:            r.Uint64(),
+		Amount:        lnwire.MilliSatoshi(r.Int63()),
+		Expiry:        r.Uint32(),
+		BlindingPoint: randPubKey(t),
 	}
 
 	_, err := r.Read(msg.ChanID[:])
@@ -496,6 +577,14 @@ func newMsgUpdateAddHTLC(t testing.TB, r *rand.Rand) *lnwire.UpdateAddHTLC {
 	_, err = r.Read(msg.OnionBlob[:])
 	require.NoError(t, err, "unable to generate onion blob")
 
+	customRecord := make([]byte, 32)
+	_, err = r.Read(customRecord)
+	require.NoError(t, err, "unable to generate custom record")
+
+	msg.CustomRecords = lnwire.CustomRecords{
+		lnwire.MinCustomRecordsTlvType: customRecord,
+	}
+
 	return msg
 }
 
@@ -861,6 +950,206 @@ func newMsgGossipTimestampRange(t testing.TB,
 	return msg
 }
 
+func newMsgOnionMessage(t testing.TB, r *rand.Rand) *lnwire.OnionMessage {
+	t.Helper()
+
+	onion := make([]byte, 1366)
+	_, err := r.Read(onion)
+	require.NoError(t, err, "unable to generate onion")
+
+	return lnwire.NewOnionMessage(randPubKey(t), onion)
+}
+
+func newMsgOpenChannel2(t testing.TB, r *rand.Rand) *lnwire.OpenChannel2 {
+	t.Helper()
+
+	msg := &lnwire.OpenChannel2{
+		FundingFeePerKWeight: uint32(r.Int31()),
+		CommitFeePerKWeight:  uint32(r.Int31()),
+		FundingAmount:        bronutil.Amount(r.Int63()),
+		DustLimit:            bronutil.Amount(r.Int63()),
+		MaxValueInFlight:     lnwire.MilliSatoshi(r.Int63()),
+		HtlcMinimum:          lnwire.MilliSatoshi(r.Int63()),
+		CsvDelay:             uint16(r.Intn(1 << 16)),
+		MaxAcceptedHTLCs:     uint16(r.Intn(1 << 16)),
+		LockTime:             uint32(r.Int31()),
+		ChannelFlags:         lnwire.FundingFlag(uint8(r.Intn(1 << 8))),
+		FundingKey:           randPubKey(t),
+		RevocationPoint:      randPubKey(t),
+		PaymentPoint:         randPubKey(t),
+		DelayedPaymentPoint:  randPubKey(t),
+		HtlcPoint:            randPubKey(t),
+		FirstCommitmentPoint: randPubKey(t),
+		RequestFunds: &lnwire.RequestFunds{
+			RequestedSats: bronutil.Amount(r.Int63()),
+			BlockHeight:   uint32(r.Int31()),
+		},
+	}
+
+	_, err := r.Read(msg.ChainHash[:])
+	require.NoError(t, err, "unable to read bytes for ChainHash")
+
+	_, err = r.Read(msg.PendingChannelID[:])
+	require.NoError(t, err, "unable to read bytes for PendingChannelID")
+
+	return msg
+}
+
+func newMsgAcceptChannel2(t testing.TB,
+	r *rand.Rand) *lnwire.AcceptChannel2 {
+
+	t.Helper()
+
+	msg := &lnwire.AcceptChannel2{
+		FundingAmount:         bronutil.Amount(r.Int63()),
+		DustLimit:             bronutil.Amount(r.Int63()),
+		MaxValueInFlight:      lnwire.MilliSatoshi(r.Int63()),
+		MinAcceptDepth:        uint32(r.Int31()),
+		HtlcMinimum:           lnwire.MilliSatoshi(r.Int63()),
+		CsvDelay:              uint16(r.Intn(1 << 16)),
+		MaxAcceptedHTLCs:      uint16(r.Intn(1 << 16)),
+		FundingKey:            randPubKey(t),
+		RevocationPoint:       randPubKey(t),
+		PaymentPoint:          randPubKey(t),
+		DelayedPaymentPoint:   randPubKey(t),
+		HtlcPoint:             randPubKey(t),
+		FirstCommitmentPoint:  randPubKey(t),
+		UpfrontShutdownScript: randDeliveryAddress(t, r),
+		WillFund: &lnwire.WillFund{
+			Signature: testNodeSig,
+			LeaseRates: lnwire.LeaseRates{
+				LeaseFeeBasis:   uint16(r.Intn(1 << 16)),
+				LeaseFeeBaseSat: uint32(r.Int31()),
+			},
+		},
+	}
+	_, err := r.Read(msg.PendingChannelID[:])
+	require.NoError(t, err, "unable to generate pending chan id")
+
+	return msg
+}
+
+func newMsgTxAddInput(t testing.TB, r *rand.Rand) *lnwire.TxAddInput {
+	t.Helper()
+
+	prevTx := wire.NewMsgTx(2)
+	prevTx.AddTxIn(&wire.TxIn{})
+	prevTx.AddTxOut(wire.NewTxOut(r.Int63(), make([]byte, 34)))
+
+	msg := &lnwire.TxAddInput{
+		SerialID:  uint64(r.Int63()),
+		PrevTx:    prevTx,
+		PrevTxOut: 0,
+		Sequence:  wire.MaxTxInSequenceNum - 2,
+		ExtraData: createExtraData(t, r),
+	}
+
+	_, err := r.Read(msg.ChanID[:])
+	require.NoError(t, err, "unable to generate chan id")
+
+	return msg
+}
+
+func newMsgTxAddOutput(t testing.TB, r *rand.Rand) *lnwire.TxAddOutput {
+	t.Helper()
+
+	msg := &lnwire.TxAddOutput{
+		SerialID:  uint64(r.Int63()),
+		Amount:    bronutil.Amount(r.Int63()),
+		PkScript:  make([]byte, 34),
+		ExtraData: createExtraData(t, r),
+	}
+
+	_, err := r.Read(msg.ChanID[:])
+	require.NoError(t, err, "unable to generate chan id")
+
+	_, err = r.Read(msg.PkScript)
+	require.NoError(t, err, "unable to generate pk script")
+
+	return msg
+}
+
+func newMsgTxComplete(t testing.TB, r *rand.Rand) *lnwire.TxComplete {
+	t.Helper()
+
+	msg := &lnwire.TxComplete{
+		ExtraData: createExtraData(t, r),
+	}
+
+	_, err := r.Read(msg.ChanID[:])
+	require.NoError(t, err, "unable to generate chan id")
+
+	return msg
+}
+
+func newMsgTxSignatures(t testing.TB, r *rand.Rand) *lnwire.TxSignatures {
+	t.Helper()
+
+	key := randRawKey(t)
+	msg := &lnwire.TxSignatures{
+		Witnesses: []wire.TxWitness{
+			{testNodeSig[:], key[:]},
+		},
+		ExtraData: createExtraData(t, r),
+	}
+
+	_, err := r.Read(msg.ChanID[:])
+	require.NoError(t, err, "unable to generate chan id")
+
+	_, err = r.Read(msg.TxHash[:])
+	require.NoError(t, err, "unable to generate tx hash")
+
+	return msg
+}
+
+func newMsgSpliceInit(t testing.TB, r *rand.Rand) *lnwire.SpliceInit {
+	t.Helper()
+
+	msg := &lnwire.SpliceInit{
+		FundingContribution:  r.Int63() - r.Int63(),
+		FundingFeePerKWeight: uint32(r.Int31()),
+		LockTime:             uint32(r.Int31()),
+		FundingPubKey:        randPubKey(t),
+		ExtraData:            createExtraData(t, r),
+	}
+
+	_, err := r.Read(msg.ChanID[:])
+	require.NoError(t, err, "unable to generate chan id")
+
+	return msg
+}
+
+func newMsgSpliceAck(t testing.TB, r *rand.Rand) *lnwire.SpliceAck {
+	t.Helper()
+
+	msg := &lnwire.SpliceAck{
+		FundingContribution: r.Int63() - r.Int63(),
+		FundingPubKey:       randPubKey(t),
+		ExtraData:           createExtraData(t, r),
+	}
+
+	_, err := r.Read(msg.ChanID[:])
+	require.NoError(t, err, "unable to generate chan id")
+
+	return msg
+}
+
+func newMsgSpliceLocked(t testing.TB, r *rand.Rand) *lnwire.SpliceLocked {
+	t.Helper()
+
+	msg := &lnwire.SpliceLocked{
+		ExtraData: createExtraData(t, r),
+	}
+
+	_, err := r.Read(msg.ChanID[:])
+	require.NoError(t, err, "unable to generate chan id")
+
+	_, err = r.Read(msg.SpliceTxid[:])
+	require.NoError(t, err, "unable to generate txid")
+
+	return msg
+}
+
 func randRawKey(t testing.TB) [33]byte {
 	t.Helper()
 
@@ -0,0 +1,80 @@
+package lnwire
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/brsuite/brond/btcec"
+)
+
+// OnionMessage is a message that carries an onion routed payload which isn't
+// tied to an HTLC. Each node along the route peels a layer of the onion,
+// learning only the next node the message should be relayed to. Onion
+// messages are always sent along blinded paths, so the path key used to
+// derive the blinded node ID of the receiver is carried alongside the onion.
+type OnionMessage struct {
+	// PathKey is the ephemeral point used by the receiver of this message
+	// to unblind its hop of the blinded path and to decrypt the data the
+	// creator of the path left for it.
+	PathKey *btcec.PublicKey
+
+	// OnionBlob is the serialized onion message packet. Unlike the onion
+	// of an HTLC, its size isn't fixed, though all nodes should use one of
+	// a small set of sizes to avoid leaking information.
+	OnionBlob []byte
+}
+
+// NewOnionMessage creates a new OnionMessage carrying the passed path key and
+// onion packet.
+func NewOnionMessage(pathKey *btcec.PublicKey, onion []byte) *OnionMessage {
+	return &OnionMessage{
+		PathKey:   pathKey,
+		OnionBlob: onion,
+	}
+}
+
+// A compile time check to ensure OnionMessage implements the lnwire.Message
+// interface.
+var _ Message = (*OnionMessage)(nil)
+
+// Decode deserializes a serialized OnionMessage stored in the passed
+// io.Reader observing the specified protocol version.
+//
+// This is part of the lnwire.Message interface.
+func (o *OnionMessage) Decode(r io.Reader, pver uint32) error {
+	if err := ReadElement(r, &o.PathKey); err != nil {
+		return err
+	}
+
+	var l [2]byte
+	if _, err := io.ReadFull(r, l[:]); err != nil {
+		return err
+	}
+	onionLen := binary.BigEndian.Uint16(l[:])
+
+	o.OnionBlob = make([]byte, onionLen)
+	_, err := io.ReadFull(r, o.OnionBlob)
+
+	return err
+}
+
+// Encode serializes the target OnionMessage into the passed io.Writer
+// observing the protocol version specified.
+//
+// This is part of the lnwire.Message interface.
+func (o *OnionMessage) Encode(w *bytes.Buffer, pver uint32) error {
+	if err := WritePublicKey(w, o.PathKey); err != nil {
+		return err
+	}
+
+	return writeDataWithLength(w, o.OnionBlob)
+}
+
+// MsgType returns the integer uniquely identifying this message type on the
+// wire.
+//
+// This is part of the lnwire.Message interface.
+func (o *OnionMessage) MsgType() MessageType {
+	return MsgOnionMessage
+}
@@ -0,0 +1,103 @@
+package lnwire
+
+import (
+	"bytes"
+	"io"
+)
+
+// MaxPeerStorageBytes is the largest blob that can be stored with a peer. It
+// is the largest message body, minus the two byte length prefix of the blob.
+const MaxPeerStorageBytes = MaxMsgBody - 2
+
+// PeerStorageBlob is an opaque, encrypted blob of data a node asks its peer
+// to store on its behalf.
+type PeerStorageBlob []byte
+
+// PeerStorage is sent by a node to ask its peer to store the attached blob,
+// replacing any blob stored for the node before. The blob is handed back to
+// the node within a YourPeerStorage message each time it reconnects.
+type PeerStorage struct {
+	// Blob is the encrypted data to be stored by the peer.
+	Blob PeerStorageBlob
+
+	// ExtraData is the set of data that was appended to this message to
+	// fill out the full maximum transport message size. These fields can
+	// be used to specify optional data such as custom TLV fields.
+	ExtraData ExtraOpaqueData
+}
+
+// A compile time check to ensure PeerStorage implements the lnwire.Message
+// interface.
+var _ Message = (*PeerStorage)(nil)
+
+// Encode serializes the target PeerStorage into the passed io.Writer
+// observing the protocol version specified.
+//
+// This is part of the lnwire.Message interface.
+func (p *PeerStorage) Encode(w *bytes.Buffer, pver uint32) error {
+	if err := WritePeerStorageBlob(w, p.Blob); err != nil {
+		return err
+	}
+
+	return WriteBytes(w, p.ExtraData)
+}
+
+// Decode deserializes a serialized PeerStorage message stored in the passed
+// io.Reader observing the specified protocol version.
+//
+// This is part of the lnwire.Message interface.
+func (p *PeerStorage) Decode(r io.Reader, pver uint32) error {
+	return ReadElements(r, &p.Blob, &p.ExtraData)
+}
+
+// MsgType returns the integer uniquely identifying this message type on the
+// wire.
+//
+// This is part of the lnwire.Message interface.
+func (p *PeerStorage) MsgType() MessageType {
+	return MsgPeerStorage
+}
+
+// YourPeerStorage is sent by a node upon reconnection to hand back the latest
+// blob its peer asked it to store.
+type YourPeerStorage struct {
+	// Blob is the encrypted data that was stored on behalf of the peer.
+	Blob PeerStorageBlob
+
+	// ExtraData is the set of data that was appended to this message to
+	// fill out the full maximum transport message size. These fields can
+	// be used to specify optional data such as custom TLV fields.
+	ExtraData ExtraOpaqueData
+}
+
+// A compile time check to ensure YourPeerStorage implements the
+// lnwire.Message interface.
+var _ Message = (*YourPeerStorage)(nil)
+
+// Encode serializes the target YourPeerStorage into the passed io.Writer
+// observing the protocol version specified.
+//
+// This is part of the lnwire.Message interface.
+func (y *YourPeerStorage) Encode(w *bytes.Buffer, pver uint32) error {
+	if err := WritePeerStorageBlob(w, y.Blob); err != nil {
+		return err
+	}
+
+	return WriteBytes(w, y.ExtraData)
+}
+
+// Decode deserializes a serialized YourPeerStorage message stored in the
+// passed io.Reader observing the specified protocol version.
+//
+// This is part of the lnwire.Message interface.
+func (y *YourPeerStorage) Decode(r io.Reader, pver uint32) error {
+	return ReadElements(r, &y.Blob, &y.ExtraData)
+}
+
+// MsgType returns the integer uniquely identifying this message type on the
+// wire.
+//
+// This is part of the lnwire.Message interface.
+func (y *YourPeerStorage) MsgType() MessageType {
+	return MsgYourPeerStorage
+}
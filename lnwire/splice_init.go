@@ -0,0 +1,101 @@
+package lnwire
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/brsuite/brond/btcec"
+)
+
+// SpliceInit is sent by the party that wishes to splice funds into or out of
+// an existing channel. The channel must be quiescent, i.e. have no pending
+// updates, when the message is sent. Once the remote party replied with a
+// SpliceAck, the splice transaction is constructed interactively, spending
+// the current funding output of the channel.
+type SpliceInit struct {
+	// ChanID identifies the channel being spliced.
+	ChanID ChannelID
+
+	// FundingContribution is the amount the sender adds to (if positive)
+	// or removes from (if negative) the channel capacity.
+	FundingContribution int64
+
+	// FundingFeePerKWeight is the fee rate in sat/kw the sender wishes to
+	// use for the splice transaction.
+	FundingFeePerKWeight uint32
+
+	// LockTime is the locktime of the splice transaction.
+	LockTime uint32
+
+	// FundingPubKey is the multisig key of the sender for the new funding
+	// output.
+	FundingPubKey *btcec.PublicKey
+
+	// ExtraData is the set of data that was appended to this message to
+	// fill out the full maximum transport message size. These fields can
+	// be used to specify optional data such as custom TLV fields.
+	ExtraData ExtraOpaqueData
+}
+
+// A compile time check to ensure SpliceInit implements the lnwire.Message
+// interface.
+var _ Message = (*SpliceInit)(nil)
+
+// Encode serializes the target SpliceInit into the passed io.Writer observing
+// the protocol version specified.
+//
+// This is part of the lnwire.Message interface.
+func (s *SpliceInit) Encode(w *bytes.Buffer, pver uint32) error {
+	if err := WriteChannelID(w, s.ChanID); err != nil {
+		return err
+	}
+
+	if err := WriteUint64(w, uint64(s.FundingContribution)); err != nil {
+		return err
+	}
+
+	if err := WriteUint32(w, s.FundingFeePerKWeight); err != nil {
+		return err
+	}
+
+	if err := WriteUint32(w, s.LockTime); err != nil {
+		return err
+	}
+
+	if err := WritePublicKey(w, s.FundingPubKey); err != nil {
+		return err
+	}
+
+	return WriteBytes(w, s.ExtraData)
+}
+
+// Decode deserializes a serialized SpliceInit message stored in the passed
+// io.Reader observing the specified protocol version.
+//
+// This is part of the lnwire.Message interface.
+func (s *SpliceInit) Decode(r io.Reader, pver uint32) error {
+	var contribution uint64
+	err := ReadElements(r,
+		&s.ChanID,
+		&contribution,
+		&s.FundingFeePerKWeight,
+		&s.LockTime,
+		&s.FundingPubKey,
+		&s.ExtraData,
+	)
+	if err != nil {
+		return err
+	}
+
+	s.FundingContribution = int64(contribution)
+
+	return nil
+}
+
+// MsgType returns the integer uniquely identifying this message type on the
+// wire.
+//
+// This is part of the lnwire.Message interface.
+func (s *SpliceInit) MsgType() MessageType {
+	return MsgSpliceInit
+}
@@ -0,0 +1,64 @@
+package lnwire
+
+import (
+	"bytes"
+	"io"
+)
+
+// TxAbort is sent by either party to abort the interactive construction of a
+// funding transaction. Contrary to an Error, it doesn't cause the channel to
+// be failed if the transaction is built for an already open channel.
+type TxAbort struct {
+	// ChanID identifies the channel whose funding transaction is being
+	// constructed.
+	ChanID ChannelID
+
+	// Data is the attached reason for aborting the construction.
+	Data ErrorData
+
+	// ExtraData is the set of data that was appended to this message to
+	// fill out the full maximum transport message size. These fields can
+	// be used to specify optional data such as custom TLV fields.
+	ExtraData ExtraOpaqueData
+}
+
+// A compile time check to ensure TxAbort implements the lnwire.Message
+// interface.
+var _ Message = (*TxAbort)(nil)
+
+// Error returns the string representation of the abort reason.
+func (t *TxAbort) Error() string {
+	return string(t.Data)
+}
+
+// Encode serializes the target TxAbort into the passed io.Writer observing
+// the protocol version specified.
+//
+// This is part of the lnwire.Message interface.
+func (t *TxAbort) Encode(w *bytes.Buffer, pver uint32) error {
+	if err := WriteChannelID(w, t.ChanID); err != nil {
+		return err
+	}
+
+	if err := WriteErrorData(w, t.Data); err != nil {
+		return err
+	}
+
+	return WriteBytes(w, t.ExtraData)
+}
+
+// Decode deserializes a serialized TxAbort message stored in the passed
+// io.Reader observing the specified protocol version.
+//
+// This is part of the lnwire.Message interface.
+func (t *TxAbort) Decode(r io.Reader, pver uint32) error {
+	return ReadElements(r, &t.ChanID, &t.Data, &t.ExtraData)
+}
+
+// MsgType returns the integer uniquely identifying this message type on the
+// wire.
+//
+// This is part of the lnwire.Message interface.
+func (t *TxAbort) MsgType() MessageType {
+	return MsgTxAbort
+}
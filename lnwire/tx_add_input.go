@@ -0,0 +1,153 @@
+package lnwire
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/brsuite/brond/wire"
+)
+
+// TxAddInput is sent by either party during the interactive construction of
+// a funding transaction to add one of its inputs to the transaction.
+type TxAddInput struct {
+	// ChanID identifies the channel whose funding transaction is being
+	// constructed. During the channel establishment this is the pending
+	// channel ID of the funding flow.
+	ChanID ChannelID
+
+	// SerialID uniquely identifies the input within the transaction. It
+	// also determines the input's position in the final transaction. The
+	// initiator of the construction must use even serial IDs, the other
+	// party odd ones.
+	SerialID uint64
+
+	// PrevTx is the transaction the spent output belongs to. The full
+	// transaction is required so the receiver can verify the value and
+	// script of the spent output. It is nil if SharedInputTxid is set.
+	PrevTx *wire.MsgTx
+
+	// PrevTxOut is the index of the spent output within PrevTx.
+	PrevTxOut uint32
+
+	// Sequence is the sequence number of the input.
+	Sequence uint32
+
+	// SharedInputTxid, if set, signals that the input spends the funding
+	// output of the channel being spliced, which is found in the
+	// transaction with this txid. It is carried as an optional TLV record
+	// within ExtraData.
+	SharedInputTxid *SharedInputTxid
+
+	// ExtraData is the set of data that was appended to this message to
+	// fill out the full maximum transport message size. These fields can
+	// be used to specify optional data such as custom TLV fields.
+	ExtraData ExtraOpaqueData
+}
+
+// A compile time check to ensure TxAddInput implements the lnwire.Message
+// interface.
+var _ Message = (*TxAddInput)(nil)
+
+// Encode serializes the target TxAddInput into the passed io.Writer observing
+// the protocol version specified.
+//
+// This is part of the lnwire.Message interface.
+func (t *TxAddInput) Encode(w *bytes.Buffer, pver uint32) error {
+	if err := WriteChannelID(w, t.ChanID); err != nil {
+		return err
+	}
+
+	if err := WriteUint64(w, t.SerialID); err != nil {
+		return err
+	}
+
+	// The previous transaction of a shared input is known to both
+	// parties, so it is left empty.
+	var prevTx bytes.Buffer
+	if t.PrevTx != nil {
+		if err := t.PrevTx.Serialize(&prevTx); err != nil {
+			return err
+		}
+	}
+	if err := writeDataWithLength(w, prevTx.Bytes()); err != nil {
+		return err
+	}
+
+	if err := WriteUint32(w, t.PrevTxOut); err != nil {
+		return err
+	}
+
+	if err := WriteUint32(w, t.Sequence); err != nil {
+		return err
+	}
+
+	// We'll only encode the SharedInputTxid in a TLV segment if it exists.
+	if t.SharedInputTxid != nil {
+		err := EncodeMessageExtraData(&t.ExtraData, t.SharedInputTxid)
+		if err != nil {
+			return err
+		}
+	}
+
+	return WriteBytes(w, t.ExtraData)
+}
+
+// Decode deserializes a serialized TxAddInput message stored in the passed
+// io.Reader observing the specified protocol version.
+//
+// This is part of the lnwire.Message interface.
+func (t *TxAddInput) Decode(r io.Reader, pver uint32) error {
+	if err := ReadElements(r, &t.ChanID, &t.SerialID); err != nil {
+		return err
+	}
+
+	var l [2]byte
+	if _, err := io.ReadFull(r, l[:]); err != nil {
+		return err
+	}
+	prevTx := make([]byte, binary.BigEndian.Uint16(l[:]))
+	if _, err := io.ReadFull(r, prevTx); err != nil {
+		return err
+	}
+
+	t.PrevTx = nil
+	if len(prevTx) != 0 {
+		t.PrevTx = wire.NewMsgTx(2)
+		err := t.PrevTx.Deserialize(bytes.NewReader(prevTx))
+		if err != nil {
+			return err
+		}
+	}
+
+	var tlvRecords ExtraOpaqueData
+	err := ReadElements(r, &t.PrevTxOut, &t.Sequence, &tlvRecords)
+	if err != nil {
+		return err
+	}
+
+	// We'll only set SharedInputTxid if the corresponding TLV type was
+	// included in the stream.
+	var sharedInputTxid SharedInputTxid
+	typeMap, err := tlvRecords.ExtractRecords(&sharedInputTxid)
+	if err != nil {
+		return err
+	}
+
+	t.SharedInputTxid = nil
+	if val, ok := typeMap[SharedInputTxidRecordType]; ok && val == nil {
+		t.SharedInputTxid = &sharedInputTxid
+	}
+
+	t.ExtraData = tlvRecords
+
+	return nil
+}
+
+// MsgType returns the integer uniquely identifying this message type on the
+// wire.
+//
+// This is part of the lnwire.Message interface.
+func (t *TxAddInput) MsgType() MessageType {
+	return MsgTxAddInput
+}
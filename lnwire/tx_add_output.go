@@ -0,0 +1,90 @@
+package lnwire
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/brsuite/bronutil"
+)
+
+// TxAddOutput is sent by either party during the interactive construction of
+// a funding transaction to add an output to the transaction.
+type TxAddOutput struct {
+	// ChanID identifies the channel whose funding transaction is being
+	// constructed.
+	ChanID ChannelID
+
+	// SerialID uniquely identifies the output within the transaction. It
+	// also determines the output's position in the final transaction.
+	SerialID uint64
+
+	// Amount is the value of the output.
+	Amount bronutil.Amount
+
+	// PkScript is the script the output pays to.
+	PkScript []byte
+
+	// ExtraData is the set of data that was appended to this message to
+	// fill out the full maximum transport message size. These fields can
+	// be used to specify optional data such as custom TLV fields.
+	ExtraData ExtraOpaqueData
+}
+
+// A compile time check to ensure TxAddOutput implements the lnwire.Message
+// interface.
+var _ Message = (*TxAddOutput)(nil)
+
+// Encode serializes the target TxAddOutput into the passed io.Writer
+// observing the protocol version specified.
+//
+// This is part of the lnwire.Message interface.
+func (t *TxAddOutput) Encode(w *bytes.Buffer, pver uint32) error {
+	if err := WriteChannelID(w, t.ChanID); err != nil {
+		return err
+	}
+
+	if err := WriteUint64(w, t.SerialID); err != nil {
+		return err
+	}
+
+	if err := WriteSatoshi(w, t.Amount); err != nil {
+		return err
+	}
+
+	if err := writeDataWithLength(w, t.PkScript); err != nil {
+		return err
+	}
+
+	return WriteBytes(w, t.ExtraData)
+}
+
+// Decode deserializes a serialized TxAddOutput message stored in the passed
+// io.Reader observing the specified protocol version.
+//
+// This is part of the lnwire.Message interface.
+func (t *TxAddOutput) Decode(r io.Reader, pver uint32) error {
+	err := ReadElements(r, &t.ChanID, &t.SerialID, &t.Amount)
+	if err != nil {
+		return err
+	}
+
+	var l [2]byte
+	if _, err := io.ReadFull(r, l[:]); err != nil {
+		return err
+	}
+	t.PkScript = make([]byte, binary.BigEndian.Uint16(l[:]))
+	if _, err := io.ReadFull(r, t.PkScript); err != nil {
+		return err
+	}
+
+	return ReadElements(r, &t.ExtraData)
+}
+
+// MsgType returns the integer uniquely identifying this message type on the
+// wire.
+//
+// This is part of the lnwire.Message interface.
+func (t *TxAddOutput) MsgType() MessageType {
+	return MsgTxAddOutput
+}
@@ -0,0 +1,166 @@
+package lnwire
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/brsuite/brond/chaincfg/chainhash"
+	"github.com/brsuite/brond/wire"
+)
+
+// TxSignatures is sent by either party once the commitment transactions
+// spending an interactively constructed funding transaction have been
+// signed. It carries the witnesses for all inputs the sender contributed.
+type TxSignatures struct {
+	// ChanID identifies the channel whose funding transaction is being
+	// signed.
+	ChanID ChannelID
+
+	// TxHash is the txid of the transaction being signed.
+	TxHash chainhash.Hash
+
+	// Witnesses are the witnesses for the sender's inputs, ordered by the
+	// serial IDs of the inputs.
+	Witnesses []wire.TxWitness
+
+	// SharedInputSig, if set, is the sender's signature for the funding
+	// output of the channel being spliced. It is carried as an optional
+	// TLV record within ExtraData.
+	SharedInputSig *SharedInputSig
+
+	// ExtraData is the set of data that was appended to this message to
+	// fill out the full maximum transport message size. These fields can
+	// be used to specify optional data such as custom TLV fields.
+	ExtraData ExtraOpaqueData
+}
+
+// A compile time check to ensure TxSignatures implements the lnwire.Message
+// interface.
+var _ Message = (*TxSignatures)(nil)
+
+// Encode serializes the target TxSignatures into the passed io.Writer
+// observing the protocol version specified.
+//
+// This is part of the lnwire.Message interface.
+func (t *TxSignatures) Encode(w *bytes.Buffer, pver uint32) error {
+	if err := WriteChannelID(w, t.ChanID); err != nil {
+		return err
+	}
+
+	if err := WriteBytes(w, t.TxHash[:]); err != nil {
+		return err
+	}
+
+	if err := WriteUint16(w, uint16(len(t.Witnesses))); err != nil {
+		return err
+	}
+
+	// Each witness is written in its transaction serialization format,
+	// prefixed by its total length.
+	for _, witness := range t.Witnesses {
+		var b bytes.Buffer
+		err := wire.WriteVarInt(&b, 0, uint64(len(witness)))
+		if err != nil {
+			return err
+		}
+		for _, item := range witness {
+			if err := wire.WriteVarBytes(&b, 0, item); err != nil {
+				return err
+			}
+		}
+
+		if err := writeDataWithLength(w, b.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	// We'll only encode the SharedInputSig in a TLV segment if it exists.
+	if t.SharedInputSig != nil {
+		err := EncodeMessageExtraData(&t.ExtraData, t.SharedInputSig)
+		if err != nil {
+			return err
+		}
+	}
+
+	return WriteBytes(w, t.ExtraData)
+}
+
+// Decode deserializes a serialized TxSignatures message stored in the passed
+// io.Reader observing the specified protocol version.
+//
+// This is part of the lnwire.Message interface.
+func (t *TxSignatures) Decode(r io.Reader, pver uint32) error {
+	var numWitnesses uint16
+	err := ReadElements(r, &t.ChanID, t.TxHash[:], &numWitnesses)
+	if err != nil {
+		return err
+	}
+
+	t.Witnesses = nil
+	for i := 0; i < int(numWitnesses); i++ {
+		var l [2]byte
+		if _, err := io.ReadFull(r, l[:]); err != nil {
+			return err
+		}
+		witnessBytes := make([]byte, binary.BigEndian.Uint16(l[:]))
+		if _, err := io.ReadFull(r, witnessBytes); err != nil {
+			return err
+		}
+
+		witnessReader := bytes.NewReader(witnessBytes)
+		numItems, err := wire.ReadVarInt(witnessReader, 0)
+		if err != nil {
+			return err
+		}
+
+		// Every item takes up at least one byte, so we can bound the
+		// number of items by the size of the witness.
+		if numItems > uint64(len(witnessBytes)) {
+			return io.ErrUnexpectedEOF
+		}
+
+		witness := make(wire.TxWitness, numItems)
+		for j := range witness {
+			witness[j], err = wire.ReadVarBytes(
+				witnessReader, 0, uint32(len(witnessBytes)),
+				"witness item",
+			)
+			if err != nil {
+				return err
+			}
+		}
+
+		t.Witnesses = append(t.Witnesses, witness)
+	}
+
+	var tlvRecords ExtraOpaqueData
+	if err := ReadElements(r, &tlvRecords); err != nil {
+		return err
+	}
+
+	// We'll only set SharedInputSig if the corresponding TLV type was
+	// included in the stream.
+	var sharedInputSig SharedInputSig
+	typeMap, err := tlvRecords.ExtractRecords(&sharedInputSig)
+	if err != nil {
+		return err
+	}
+
+	t.SharedInputSig = nil
+	if val, ok := typeMap[SharedInputSigRecordType]; ok && val == nil {
+		t.SharedInputSig = &sharedInputSig
+	}
+
+	t.ExtraData = tlvRecords
+
+	return nil
+}
+
+// MsgType returns the integer uniquely identifying this message type on the
+// wire.
+//
+// This is part of the lnwire.Message interface.
+func (t *TxSignatures) MsgType() MessageType {
+	return MsgTxSignatures
+}
@@ -0,0 +1,56 @@
+package lnwire
+
+import (
+	"io"
+
+	"github.com/brsuite/brond/btcec"
+	"github.com/brsuite/broln/tlv"
+)
+
+const (
+	// BlindingPointRecordType is the type of the update_add_htlc record
+	// that carries the blinding point of an HTLC that is forwarded within
+	// a blinded route.
+	BlindingPointRecordType tlv.Type = 0
+)
+
+// BlindingPoint is the ephemeral public key the receiver of an HTLC within a
+// blinded route uses to derive its blinded node ID and to decrypt the data
+// the recipient left for it.
+type BlindingPoint btcec.PublicKey
+
+// Record returns a TLV record that can be used to encode/decode the
+// BlindingPoint type from a given TLV stream.
+func (b *BlindingPoint) Record() tlv.Record {
+	return tlv.MakeStaticRecord(
+		BlindingPointRecordType, b, 33, blindingPointEncoder,
+		blindingPointDecoder,
+	)
+}
+
+// blindingPointEncoder is a custom TLV encoder for the BlindingPoint record.
+func blindingPointEncoder(w io.Writer, val interface{}, buf *[8]byte) error {
+	if v, ok := val.(*BlindingPoint); ok {
+		key := (*btcec.PublicKey)(v)
+		return tlv.EPubKey(w, &key, buf)
+	}
+
+	return tlv.NewTypeForEncodingErr(val, "lnwire.BlindingPoint")
+}
+
+// blindingPointDecoder is a custom TLV decoder for the BlindingPoint record.
+func blindingPointDecoder(r io.Reader, val interface{}, buf *[8]byte,
+	l uint64) error {
+
+	if v, ok := val.(*BlindingPoint); ok {
+		var key *btcec.PublicKey
+		if err := tlv.DPubKey(r, &key, buf, l); err != nil {
+			return err
+		}
+		*v = BlindingPoint(*key)
+
+		return nil
+	}
+
+	return tlv.NewTypeForEncodingErr(val, "lnwire.BlindingPoint")
+}
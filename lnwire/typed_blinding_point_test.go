@@ -0,0 +1,27 @@
+package lnwire
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestBlindingPointEncodeDecode tests that we're able to properly encode and
+// decode blinding points within TLV streams.
+func TestBlindingPointEncodeDecode(t *testing.T) {
+	t.Parallel()
+
+	pubKey, err := randPubKey()
+	require.NoError(t, err)
+	blindingPoint := BlindingPoint(*pubKey)
+
+	var extraData ExtraOpaqueData
+	require.NoError(t, extraData.PackRecords(&blindingPoint))
+
+	var blindingPoint2 BlindingPoint
+	tlvs, err := extraData.ExtractRecords(&blindingPoint2)
+	require.NoError(t, err)
+
+	require.Contains(t, tlvs, BlindingPointRecordType)
+	require.Equal(t, blindingPoint, blindingPoint2)
+}
@@ -0,0 +1,96 @@
+package lnwire
+
+import (
+	"io"
+
+	"github.com/brsuite/bronutil"
+	"github.com/brsuite/broln/tlv"
+)
+
+const (
+	// FeeRangeRecordType is the type of the record used to communicate the
+	// range of fees a party is willing to pay for a cooperative closing
+	// transaction within a closing_signed message.
+	FeeRangeRecordType tlv.Type = 1
+)
+
+// FeeRange is the range of absolute fees, in satoshis, that the sender of a
+// closing_signed message is willing to accept for the closing transaction.
+// It allows both parties to agree on a fee within a single round trip when
+// their ranges overlap.
+type FeeRange struct {
+	// MinFeeSats is the lowest fee the sender is willing to accept.
+	MinFeeSats bronutil.Amount
+
+	// MaxFeeSats is the highest fee the sender is willing to accept.
+	MaxFeeSats bronutil.Amount
+}
+
+// Contains returns true if the passed fee lies within the fee range.
+func (f *FeeRange) Contains(fee bronutil.Amount) bool {
+	return fee >= f.MinFeeSats && fee <= f.MaxFeeSats
+}
+
+// Overlap returns the intersection of both fee ranges. The second return
+// value is false if the fee ranges don't overlap.
+func (f *FeeRange) Overlap(other *FeeRange) (*FeeRange, bool) {
+	overlap := &FeeRange{
+		MinFeeSats: f.MinFeeSats,
+		MaxFeeSats: f.MaxFeeSats,
+	}
+	if other.MinFeeSats > overlap.MinFeeSats {
+		overlap.MinFeeSats = other.MinFeeSats
+	}
+	if other.MaxFeeSats < overlap.MaxFeeSats {
+		overlap.MaxFeeSats = other.MaxFeeSats
+	}
+
+	if overlap.MinFeeSats > overlap.MaxFeeSats {
+		return nil, false
+	}
+
+	return overlap, true
+}
+
+// Record returns a TLV record that can be used to encode/decode the FeeRange
+// type from a given TLV stream.
+func (f *FeeRange) Record() tlv.Record {
+	return tlv.MakeStaticRecord(
+		FeeRangeRecordType, f, 16, feeRangeEncoder, feeRangeDecoder,
+	)
+}
+
+// feeRangeEncoder is a custom TLV encoder for the FeeRange record.
+func feeRangeEncoder(w io.Writer, val interface{}, buf *[8]byte) error {
+	if v, ok := val.(*FeeRange); ok {
+		minFee := uint64(v.MinFeeSats)
+		if err := tlv.EUint64T(w, minFee, buf); err != nil {
+			return err
+		}
+
+		return tlv.EUint64T(w, uint64(v.MaxFeeSats), buf)
+	}
+
+	return tlv.NewTypeForEncodingErr(val, "lnwire.FeeRange")
+}
+
+// feeRangeDecoder is a custom TLV decoder for the FeeRange record.
+func feeRangeDecoder(r io.Reader, val interface{}, buf *[8]byte,
+	l uint64) error {
+
+	if v, ok := val.(*FeeRange); ok && l == 16 {
+		var minFee, maxFee uint64
+		if err := tlv.DUint64(r, &minFee, buf, 8); err != nil {
+			return err
+		}
+		if err := tlv.DUint64(r, &maxFee, buf, 8); err != nil {
+			return err
+		}
+
+		v.MinFeeSats = bronutil.Amount(minFee)
+		v.MaxFeeSats = bronutil.Amount(maxFee)
+		return nil
+	}
+
+	return tlv.NewTypeForDecodingErr(val, "lnwire.FeeRange", l, 16)
+}
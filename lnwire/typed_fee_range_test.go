@@ -0,0 +1,47 @@
+package lnwire
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestFeeRangeEncodeDecode tests that we're able to properly encode and decode
+// fee ranges within TLV streams.
+func TestFeeRangeEncodeDecode(t *testing.T) {
+	t.Parallel()
+
+	feeRange := FeeRange{
+		MinFeeSats: 1000,
+		MaxFeeSats: 5000,
+	}
+
+	var extraData ExtraOpaqueData
+	require.NoError(t, extraData.PackRecords(&feeRange))
+
+	var feeRange2 FeeRange
+	tlvs, err := extraData.ExtractRecords(&feeRange2)
+	require.NoError(t, err)
+
+	require.Contains(t, tlvs, FeeRangeRecordType)
+	require.Equal(t, feeRange, feeRange2)
+}
+
+// TestFeeRangeOverlap tests that the overlap of two fee ranges is computed
+// correctly.
+func TestFeeRangeOverlap(t *testing.T) {
+	t.Parallel()
+
+	feeRange := &FeeRange{MinFeeSats: 1000, MaxFeeSats: 5000}
+
+	overlap, ok := feeRange.Overlap(
+		&FeeRange{MinFeeSats: 3000, MaxFeeSats: 8000},
+	)
+	require.True(t, ok)
+	require.Equal(t, &FeeRange{MinFeeSats: 3000, MaxFeeSats: 5000}, overlap)
+	require.True(t, overlap.Contains(3000))
+	require.False(t, overlap.Contains(5001))
+
+	_, ok = feeRange.Overlap(&FeeRange{MinFeeSats: 6000, MaxFeeSats: 8000})
+	require.False(t, ok)
+}
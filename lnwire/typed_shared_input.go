@@ -0,0 +1,101 @@
+package lnwire
+
+import (
+	"io"
+
+	"github.com/brsuite/brond/chaincfg/chainhash"
+	"github.com/brsuite/broln/tlv"
+)
+
+const (
+	// SharedInputTxidRecordType is the type of the record used within a
+	// tx_add_input message to signal that the added input is the funding
+	// output of the channel being spliced.
+	SharedInputTxidRecordType tlv.Type = 0
+
+	// SharedInputSigRecordType is the type of the record used within a
+	// tx_signatures message to carry the sender's signature for the
+	// funding output of the channel being spliced.
+	SharedInputSigRecordType tlv.Type = 0
+)
+
+// SharedInputTxid is the txid of the funding transaction of a channel being
+// spliced. When present within a tx_add_input message, the previous
+// transaction of the input is omitted as both parties already know it.
+type SharedInputTxid chainhash.Hash
+
+// Record returns a TLV record that can be used to encode/decode the
+// SharedInputTxid type from a given TLV stream.
+func (s *SharedInputTxid) Record() tlv.Record {
+	return tlv.MakeStaticRecord(
+		SharedInputTxidRecordType, s, 32, sharedInputTxidEncoder,
+		sharedInputTxidDecoder,
+	)
+}
+
+// sharedInputTxidEncoder is a custom TLV encoder for the SharedInputTxid
+// record.
+func sharedInputTxidEncoder(w io.Writer, val interface{}, buf *[8]byte) error {
+	if v, ok := val.(*SharedInputTxid); ok {
+		bytes := [32]byte(*v)
+		return tlv.EBytes32(w, &bytes, buf)
+	}
+
+	return tlv.NewTypeForEncodingErr(val, "lnwire.SharedInputTxid")
+}
+
+// sharedInputTxidDecoder is a custom TLV decoder for the SharedInputTxid
+// record.
+func sharedInputTxidDecoder(r io.Reader, val interface{}, buf *[8]byte,
+	l uint64) error {
+
+	if v, ok := val.(*SharedInputTxid); ok {
+		var bytes [32]byte
+		if err := tlv.DBytes32(r, &bytes, buf, l); err != nil {
+			return err
+		}
+		*v = SharedInputTxid(bytes)
+		return nil
+	}
+
+	return tlv.NewTypeForDecodingErr(val, "lnwire.SharedInputTxid", l, 32)
+}
+
+// SharedInputSig is the signature of the sender for the funding output of a
+// channel being spliced, carried within a tx_signatures message.
+type SharedInputSig Sig
+
+// Record returns a TLV record that can be used to encode/decode the
+// SharedInputSig type from a given TLV stream.
+func (s *SharedInputSig) Record() tlv.Record {
+	return tlv.MakeStaticRecord(
+		SharedInputSigRecordType, s, 64, sharedInputSigEncoder,
+		sharedInputSigDecoder,
+	)
+}
+
+// sharedInputSigEncoder is a custom TLV encoder for the SharedInputSig record.
+func sharedInputSigEncoder(w io.Writer, val interface{}, buf *[8]byte) error {
+	if v, ok := val.(*SharedInputSig); ok {
+		bytes := [64]byte(*v)
+		return tlv.EBytes64(w, &bytes, buf)
+	}
+
+	return tlv.NewTypeForEncodingErr(val, "lnwire.SharedInputSig")
+}
+
+// sharedInputSigDecoder is a custom TLV decoder for the SharedInputSig record.
+func sharedInputSigDecoder(r io.Reader, val interface{}, buf *[8]byte,
+	l uint64) error {
+
+	if v, ok := val.(*SharedInputSig); ok {
+		var bytes [64]byte
+		if err := tlv.DBytes64(r, &bytes, buf, l); err != nil {
+			return err
+		}
+		*v = SharedInputSig(bytes)
+		return nil
+	}
+
+	return tlv.NewTypeForDecodingErr(val, "lnwire.SharedInputSig", l, 64)
+}
@@ -3,6 +3,9 @@ package lnwire
 import (
 	"bytes"
 	"io"
+
+	"github.com/brsuite/brond/btcec"
+	"github.com/brsuite/broln/tlv"
 )
 
 // OnionPacketSize is the size of the serialized Sphinx onion packet included
@@ -54,6 +57,16 @@ type UpdateAddHTLC struct {
 	// used in the subsequent UpdateAddHTLC message.
 	OnionBlob [OnionPacketSize]byte
 
+	// BlindingPoint is the ephemeral public key the receiver uses to
+	// process the onion of an HTLC that is forwarded within a blinded
+	// route. It is nil for all other HTLCs.
+	BlindingPoint *btcec.PublicKey
+
+	// CustomRecords is the set of custom TLV records attached to the HTLC,
+	// all of which must be within the custom type range. It is nil if no
+	// custom records are attached.
+	CustomRecords CustomRecords
+
 	// ExtraData is the set of data that was appended to this message to
 	// fill out the full maximum transport message size. These fields can
 	// be used to specify optional data such as custom TLV fields.
@@ -74,7 +87,7 @@ var _ Message = (*UpdateAddHTLC)(nil)
 //
 // This is part of the lnwire.Message interface.
 func (c *UpdateAddHTLC) Decode(r io.Reader, pver uint32) error {
-	return ReadElements(r,
+	err := ReadElements(r,
 		&c.ChanID,
 		&c.ID,
 		&c.Amount,
@@ -83,6 +96,24 @@ func (c *UpdateAddHTLC) Decode(r io.Reader, pver uint32) error {
 		c.OnionBlob[:],
 		&c.ExtraData,
 	)
+	if err != nil {
+		return err
+	}
+
+	var blindingPoint BlindingPoint
+	typeMap, err := c.ExtraData.ExtractRecords(&blindingPoint)
+	if err != nil {
+		return err
+	}
+
+	if val, ok := typeMap[BlindingPointRecordType]; ok && val == nil {
+		key := btcec.PublicKey(blindingPoint)
+		c.BlindingPoint = &key
+	}
+
+	c.CustomRecords = ParseCustomRecords(typeMap)
+
+	return nil
 }
 
 // Encode serializes the target UpdateAddHTLC into the passed io.Writer
@@ -90,6 +121,29 @@ func (c *UpdateAddHTLC) Decode(r io.Reader, pver uint32) error {
 //
 // This is part of the lnwire.Message interface.
 func (c *UpdateAddHTLC) Encode(w *bytes.Buffer, pver uint32) error {
+	var recordProducers []tlv.RecordProducer
+	if c.BlindingPoint != nil {
+		blindingPoint := BlindingPoint(*c.BlindingPoint)
+		recordProducers = append(recordProducers, &blindingPoint)
+	}
+
+	if len(c.CustomRecords) > 0 {
+		if err := c.CustomRecords.Validate(); err != nil {
+			return err
+		}
+
+		recordProducers = append(
+			recordProducers, c.CustomRecords.RecordProducers()...,
+		)
+	}
+
+	if len(recordProducers) > 0 {
+		err := EncodeMessageExtraData(&c.ExtraData, recordProducers...)
+		if err != nil {
+			return err
+		}
+	}
+
 	if err := WriteChannelID(w, c.ChanID); err != nil {
 		return err
 	}
@@ -266,6 +266,11 @@ func WriteErrorData(buf *bytes.Buffer, data ErrorData) error {
 	return writeDataWithLength(buf, data)
 }
 
+// WritePeerStorageBlob appends the blob to the provided buffer.
+func WritePeerStorageBlob(buf *bytes.Buffer, blob PeerStorageBlob) error {
+	return writeDataWithLength(buf, blob)
+}
+
 // WriteOpaqueReason appends the reason to the provided buffer.
 func WriteOpaqueReason(buf *bytes.Buffer, reason OpaqueReason) error {
 	return writeDataWithLength(buf, reason)
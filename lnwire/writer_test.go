@@ -319,6 +319,20 @@ func TestWriteErrorData(t *testing.T) {
 	require.Equal(t, expectedBytes, buf.Bytes())
 }
 
+func TestWritePeerStorageBlob(t *testing.T) {
+	buf := new(bytes.Buffer)
+	data := PeerStorageBlob{1, 1, 1}
+	expectedBytes := []byte{
+		0, 3, // First two bytes encode the length.
+		1, 1, 1, // The actual data.
+	}
+
+	err := WritePeerStorageBlob(buf, data)
+
+	require.NoError(t, err)
+	require.Equal(t, expectedBytes, buf.Bytes())
+}
+
 func TestWriteOpaqueReason(t *testing.T) {
 	buf := new(bytes.Buffer)
 	data := OpaqueReason{1, 1, 1}
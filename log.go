@@ -5,6 +5,8 @@ import (
 	"github.com/btcsuite/btclog"
 	"github.com/brsuite/neutrino"
 	sphinx "github.com/brsuite/lightning-onion"
+	"github.com/brsuite/broln/accounts"
+	"github.com/brsuite/broln/auditlog"
 	"github.com/brsuite/broln/autopilot"
 	"github.com/brsuite/broln/build"
 	"github.com/brsuite/broln/chainntnfs"
@@ -35,6 +37,8 @@ import (
 	"github.com/brsuite/broln/lnwallet/rpcwallet"
 	"github.com/brsuite/broln/monitoring"
 	"github.com/brsuite/broln/netann"
+	"github.com/brsuite/broln/offers"
+	"github.com/brsuite/broln/onionmsg"
 	"github.com/brsuite/broln/peer"
 	"github.com/brsuite/broln/peernotifier"
 	"github.com/brsuite/broln/routing"
@@ -153,6 +157,10 @@ func SetupLoggers(root *build.RotatingLogWriter, interceptor signal.Interceptor)
 	AddSubLogger(root, "CHFD", interceptor, chanfunding.UseLogger)
 	AddSubLogger(root, "PEER", interceptor, peer.UseLogger)
 	AddSubLogger(root, "CHCL", interceptor, chancloser.UseLogger)
+	AddSubLogger(root, "OMSG", interceptor, onionmsg.UseLogger)
+	AddSubLogger(root, "OFRS", interceptor, offers.UseLogger)
+	AddSubLogger(root, "ACCT", interceptor, accounts.UseLogger)
+	AddSubLogger(root, "AUDT", interceptor, auditlog.UseLogger)
 
 	AddSubLogger(root, routing.Subsystem, interceptor, routing.UseLogger, localchans.UseLogger)
 	AddSubLogger(root, routerrpc.Subsystem, interceptor, routerrpc.UseLogger)
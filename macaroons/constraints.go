@@ -3,11 +3,15 @@ package macaroons
 import (
 	"bytes"
 	"context"
+	"encoding/hex"
 	"fmt"
 	"net"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/brsuite/brond/btcec"
+	"github.com/brsuite/bronutil"
 	"google.golang.org/grpc/peer"
 
 	"gopkg.in/macaroon-bakery.v2/bakery/checkers"
@@ -22,6 +26,30 @@ const (
 	// in the serialized macaroon. We choose a single space as the delimiter
 	// between the because that is also used by the macaroon bakery library.
 	CondbrolnCustom = "broln-custom"
+
+	// CondPaymentMaxAmt is the first party caveat condition name that
+	// restricts the amount, in satoshis, of any single payment made with a
+	// macaroon.
+	CondPaymentMaxAmt = "payment-max-amt"
+
+	// CondPaymentBudget is the first party caveat condition name that
+	// restricts the total amount, in satoshis, that may be spent with a
+	// macaroon within a rolling PaymentBudgetPeriod.
+	CondPaymentBudget = "payment-budget"
+
+	// CondPaymentDest is the first party caveat condition name that
+	// restricts the destinations that may be paid with a macaroon to a
+	// comma separated list of hex encoded node public keys.
+	CondPaymentDest = "payment-dest"
+
+	// CondPaymentMemoPrefix is the first party caveat condition name that
+	// restricts payments made with a macaroon to invoices whose memo
+	// starts with the given prefix.
+	CondPaymentMemoPrefix = "payment-memo-prefix"
+
+	// PaymentBudgetPeriod is the rolling period over which the spending
+	// of a macaroon with a payment budget caveat is accounted.
+	PaymentBudgetPeriod = 24 * time.Hour
 )
 
 // CustomCaveatAcceptor is an interface that contains a single method for
@@ -250,3 +278,218 @@ func GetCustomCaveatCondition(mac *macaroon.Macaroon,
 	// We didn't find a condition for the given custom caveat name.
 	return ""
 }
+
+// PaymentMaxAmtConstraint restricts the amount of any single payment made with
+// the macaroon to the given number of satoshis.
+func PaymentMaxAmtConstraint(
+	maxAmt bronutil.Amount) func(*macaroon.Macaroon) error {
+
+	return func(mac *macaroon.Macaroon) error {
+		if maxAmt <= 0 {
+			return fmt.Errorf("maximum payment amount must be " +
+				"positive")
+		}
+
+		caveat := checkers.Condition(
+			CondPaymentMaxAmt, strconv.FormatInt(int64(maxAmt), 10),
+		)
+		return mac.AddFirstPartyCaveat([]byte(caveat))
+	}
+}
+
+// PaymentBudgetConstraint restricts the total amount spent with the macaroon
+// within a rolling PaymentBudgetPeriod to the given number of satoshis.
+func PaymentBudgetConstraint(
+	budget bronutil.Amount) func(*macaroon.Macaroon) error {
+
+	return func(mac *macaroon.Macaroon) error {
+		if budget <= 0 {
+			return fmt.Errorf("payment budget must be positive")
+		}
+
+		caveat := checkers.Condition(
+			CondPaymentBudget, strconv.FormatInt(int64(budget), 10),
+		)
+		return mac.AddFirstPartyCaveat([]byte(caveat))
+	}
+}
+
+// PaymentDestConstraint restricts the destinations that can be paid with the
+// macaroon to the given hex encoded node public keys.
+func PaymentDestConstraint(pubKeys ...string) func(*macaroon.Macaroon) error {
+	return func(mac *macaroon.Macaroon) error {
+		arg := strings.Join(pubKeys, ",")
+		if _, err := parsePaymentDests(arg); err != nil {
+			return err
+		}
+
+		caveat := checkers.Condition(CondPaymentDest, arg)
+		return mac.AddFirstPartyCaveat([]byte(caveat))
+	}
+}
+
+// PaymentMemoPrefixConstraint restricts payments made with the macaroon to
+// invoices whose memo starts with the given prefix.
+func PaymentMemoPrefixConstraint(prefix string) func(*macaroon.Macaroon) error {
+	return func(mac *macaroon.Macaroon) error {
+		if prefix == "" {
+			return fmt.Errorf("memo prefix cannot be empty")
+		}
+
+		caveat := checkers.Condition(CondPaymentMemoPrefix, prefix)
+		return mac.AddFirstPartyCaveat([]byte(caveat))
+	}
+}
+
+// PaymentCheckers returns the Checkers of all payment caveat conditions. They
+// only validate the format of a condition, as whether a request satisfies it
+// can only be decided once the request itself is known. This is done by the
+// RPC interceptor, based on the PaymentConstraints of the macaroon.
+func PaymentCheckers() []Checker {
+	checker := func(name string, parse func(string) error) Checker {
+		return func() (string, checkers.Func) {
+			return name, func(_ context.Context, _, arg string) error {
+				return parse(arg)
+			}
+		}
+	}
+
+	parseAmt := func(arg string) error {
+		_, err := parsePaymentAmt(arg)
+		return err
+	}
+	parseDests := func(arg string) error {
+		_, err := parsePaymentDests(arg)
+		return err
+	}
+	parseMemoPrefix := func(arg string) error {
+		if arg == "" {
+			return fmt.Errorf("memo prefix cannot be empty")
+		}
+		return nil
+	}
+
+	return []Checker{
+		checker(CondPaymentMaxAmt, parseAmt),
+		checker(CondPaymentBudget, parseAmt),
+		checker(CondPaymentDest, parseDests),
+		checker(CondPaymentMemoPrefix, parseMemoPrefix),
+	}
+}
+
+// PaymentConstraints holds all payment restrictions of a macaroon. As each
+// caveat of a macaroon can only tighten its restrictions, a request must
+// satisfy all of them.
+type PaymentConstraints struct {
+	// MaxAmts holds the maximum amounts of any single payment.
+	MaxAmts []bronutil.Amount
+
+	// Budgets holds the budgets that may be spent within a rolling
+	// PaymentBudgetPeriod.
+	Budgets []bronutil.Amount
+
+	// Dests holds the sets of destinations that may be paid. A destination
+	// must be part of each set.
+	Dests []map[[33]byte]struct{}
+
+	// MemoPrefixes holds the prefixes the memo of a paid invoice must
+	// start with.
+	MemoPrefixes []string
+}
+
+// Empty returns true if no payment restriction is set.
+func (p *PaymentConstraints) Empty() bool {
+	return len(p.MaxAmts) == 0 && len(p.Budgets) == 0 &&
+		len(p.Dests) == 0 && len(p.MemoPrefixes) == 0
+}
+
+// GetPaymentConstraints extracts the payment restrictions from the caveats of
+// the given macaroon.
+func GetPaymentConstraints(mac *macaroon.Macaroon) (*PaymentConstraints,
+	error) {
+
+	constraints := &PaymentConstraints{}
+	if mac == nil {
+		return constraints, nil
+	}
+
+	for _, caveat := range mac.Caveats() {
+		// Third party caveats can't carry payment restrictions.
+		if len(caveat.VerificationId) != 0 {
+			continue
+		}
+
+		cond, arg, err := checkers.ParseCaveat(string(caveat.Id))
+		if err != nil {
+			continue
+		}
+
+		switch cond {
+		case CondPaymentMaxAmt:
+			amt, err := parsePaymentAmt(arg)
+			if err != nil {
+				return nil, err
+			}
+			constraints.MaxAmts = append(constraints.MaxAmts, amt)
+
+		case CondPaymentBudget:
+			amt, err := parsePaymentAmt(arg)
+			if err != nil {
+				return nil, err
+			}
+			constraints.Budgets = append(constraints.Budgets, amt)
+
+		case CondPaymentDest:
+			dests, err := parsePaymentDests(arg)
+			if err != nil {
+				return nil, err
+			}
+			constraints.Dests = append(constraints.Dests, dests)
+
+		case CondPaymentMemoPrefix:
+			constraints.MemoPrefixes = append(
+				constraints.MemoPrefixes, arg,
+			)
+		}
+	}
+
+	return constraints, nil
+}
+
+// parsePaymentAmt parses the positive satoshi amount of a payment caveat.
+func parsePaymentAmt(arg string) (bronutil.Amount, error) {
+	amt, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid payment amount %q: %v", arg, err)
+	}
+	if amt <= 0 {
+		return 0, fmt.Errorf("payment amount must be positive")
+	}
+
+	return bronutil.Amount(amt), nil
+}
+
+// parsePaymentDests parses the comma separated list of hex encoded node
+// public keys of a payment destination caveat.
+func parsePaymentDests(arg string) (map[[33]byte]struct{}, error) {
+	dests := make(map[[33]byte]struct{})
+	for _, pubKeyStr := range strings.Split(arg, ",") {
+		pubKeyBytes, err := hex.DecodeString(pubKeyStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid destination %q: %v",
+				pubKeyStr, err)
+		}
+
+		pubKey, err := btcec.ParsePubKey(pubKeyBytes, btcec.S256())
+		if err != nil {
+			return nil, fmt.Errorf("invalid destination %q: %v",
+				pubKeyStr, err)
+		}
+
+		var dest [33]byte
+		copy(dest[:], pubKey.SerializeCompressed())
+		dests[dest] = struct{}{}
+	}
+
+	return dests, nil
+}
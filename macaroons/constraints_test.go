@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/brsuite/bronutil"
 	"github.com/stretchr/testify/require"
 
 	"github.com/brsuite/broln/macaroons"
@@ -155,3 +156,57 @@ func TestCustomConstraint(t *testing.T) {
 	)
 	require.Equal(t, customCaveatCondition, "")
 }
+
+// TestPaymentConstraints tests that payment caveats are added to a macaroon
+// and can be extracted from it again.
+func TestPaymentConstraints(t *testing.T) {
+	t.Parallel()
+
+	const (
+		dest1 = "02eec7245d6b7d2ccb30380bfbe2a3648cd7a942653f5aa340e" +
+			"dcea1f283686619"
+		dest2 = "0324653eac434488002cc06bbfb7f10fe18991e35f9fe4302db" +
+			"ea6d2353dc0ab1c"
+	)
+
+	testMacaroon, err := macaroons.AddConstraints(
+		createDummyMacaroon(t), macaroons.PaymentMaxAmtConstraint(1000),
+		macaroons.PaymentBudgetConstraint(5000),
+		macaroons.PaymentDestConstraint(dest1, dest2),
+		macaroons.PaymentMemoPrefixConstraint("coffee"),
+	)
+	require.NoError(t, err)
+
+	require.Equal(
+		t, []byte("payment-max-amt 1000"), testMacaroon.Caveats()[0].Id,
+	)
+	require.Equal(
+		t, []byte("payment-budget 5000"), testMacaroon.Caveats()[1].Id,
+	)
+
+	constraints, err := macaroons.GetPaymentConstraints(testMacaroon)
+	require.NoError(t, err)
+	require.False(t, constraints.Empty())
+	require.Equal(t, []bronutil.Amount{1000}, constraints.MaxAmts)
+	require.Equal(t, []bronutil.Amount{5000}, constraints.Budgets)
+	require.Equal(t, []string{"coffee"}, constraints.MemoPrefixes)
+	require.Len(t, constraints.Dests, 1)
+	require.Len(t, constraints.Dests[0], 2)
+
+	// A macaroon without any payment caveats has no payment constraints.
+	constraints, err = macaroons.GetPaymentConstraints(
+		createDummyMacaroon(t),
+	)
+	require.NoError(t, err)
+	require.True(t, constraints.Empty())
+
+	// Invalid values are rejected when the caveats are added.
+	testMacaroon = createDummyMacaroon(t)
+	require.Error(t, macaroons.PaymentMaxAmtConstraint(0)(testMacaroon))
+	require.Error(t, macaroons.PaymentBudgetConstraint(-1)(testMacaroon))
+	require.Error(t, macaroons.PaymentDestConstraint("00")(testMacaroon))
+	require.Error(
+		t, macaroons.PaymentMemoPrefixConstraint("")(testMacaroon),
+	)
+	require.Empty(t, testMacaroon.Caveats())
+}
@@ -0,0 +1,91 @@
+package monitoring
+
+import (
+	"github.com/brsuite/brond/wire"
+	"github.com/brsuite/broln/channeldb"
+	"github.com/brsuite/broln/htlcswitch"
+	"github.com/brsuite/broln/routing"
+	"github.com/brsuite/broln/routing/route"
+	"github.com/brsuite/broln/sweep"
+	"github.com/brsuite/broln/watchtower/wtclient"
+)
+
+// ChannelSource is an interface that allows the node metrics to fetch the
+// set of open channels along with their balances and pending HTLCs.
+type ChannelSource interface {
+	// FetchAllOpenChannels returns all channels that are currently open,
+	// pending ones excluded.
+	FetchAllOpenChannels() ([]*channeldb.OpenChannel, error)
+}
+
+// GraphSource is an interface that allows the node metrics to walk the
+// channel graph in order to determine its size.
+type GraphSource interface {
+	// ForEachNodeCached iterates through all the stored vertices/nodes in
+	// the graph, executing the passed callback with each node encountered
+	// along with its channels.
+	ForEachNodeCached(cb func(node route.Vertex,
+		chans map[uint64]*channeldb.DirectedChannel) error) error
+}
+
+// ForwardingSource is an interface that allows the node metrics to fetch
+// the running totals of the HTLCs forwarded by the switch.
+type ForwardingSource interface {
+	// ForwardingStats returns the running totals of the HTLCs forwarded
+	// since the switch was started.
+	ForwardingStats() htlcswitch.ForwardingStats
+}
+
+// SweeperSource is an interface that allows the node metrics to fetch the
+// set of inputs that are waiting to be swept.
+type SweeperSource interface {
+	// PendingInputs returns the set of inputs that the sweeper is
+	// currently attempting to sweep.
+	PendingInputs() (map[wire.OutPoint]*sweep.PendingInput, error)
+}
+
+// TowerClientSource is an interface that allows the node metrics to fetch
+// the backup statistics of a watchtower client.
+type TowerClientSource interface {
+	// Stats returns the in-memory statistics of the client since startup.
+	Stats() wtclient.ClientStats
+}
+
+// MissionControlSource is an interface that allows the node metrics to
+// fetch the payment history mission control keeps track of.
+type MissionControlSource interface {
+	// GetHistorySnapshot takes a snapshot from the current mission
+	// control state and actual probability estimates.
+	GetHistorySnapshot() *routing.MissionControlSnapshot
+}
+
+// MetricsConfig houses the set of sources the node level Prometheus metrics
+// are collected from. Any source left nil is skipped, so no metrics are
+// exported for the subsystem it represents.
+type MetricsConfig struct {
+	// Channels is used to fetch the balances and pending HTLCs of our
+	// open channels.
+	Channels ChannelSource
+
+	// Graph is used to determine the size of the channel graph.
+	Graph GraphSource
+
+	// Switch is used to fetch the forwarding success and failure counts
+	// along with the fees earned.
+	Switch ForwardingSource
+
+	// Sweeper is used to fetch the number of inputs pending to be swept.
+	Sweeper SweeperSource
+
+	// TowerClient is used to fetch the backup queue of the legacy
+	// watchtower client.
+	TowerClient TowerClientSource
+
+	// AnchorTowerClient is used to fetch the backup queue of the anchor
+	// watchtower client.
+	AnchorTowerClient TowerClientSource
+
+	// MissionControl is used to fetch the number of node pairs mission
+	// control has recorded results for.
+	MissionControl MissionControlSource
+}
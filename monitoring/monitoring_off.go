@@ -24,3 +24,10 @@ func ExportPrometheusMetrics(_ *grpc.Server, _ lncfg.Prometheus) error {
 	return fmt.Errorf("broln must be built with the monitoring tag to " +
 		"enable exporting Prometheus metrics")
 }
+
+// RegisterNodeMetrics is required for broln to compile so that Prometheus
+// metric exporting can be hidden behind a build tag.
+func RegisterNodeMetrics(_ *MetricsConfig) error {
+	return fmt.Errorf("broln must be built with the monitoring tag to " +
+		"enable exporting Prometheus metrics")
+}
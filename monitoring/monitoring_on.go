@@ -11,6 +11,7 @@ import (
 
 	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
 	"github.com/brsuite/broln/lncfg"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
@@ -44,3 +45,10 @@ func ExportPrometheusMetrics(grpcServer *grpc.Server, cfg lncfg.Prometheus) erro
 
 	return nil
 }
+
+// RegisterNodeMetrics registers the collector exporting the node level
+// metrics, such as channel balances and forwarding totals, sampled from the
+// sources of the given config.
+func RegisterNodeMetrics(cfg *MetricsConfig) error {
+	return prometheus.Register(newNodeCollector(cfg))
+}
@@ -0,0 +1,355 @@
+//go:build monitoring
+// +build monitoring
+
+package monitoring
+
+import (
+	"encoding/hex"
+
+	"github.com/brsuite/broln/channeldb"
+	"github.com/brsuite/broln/routing/route"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// metricsNamespace is the namespace all node level metrics are exported
+// under.
+const metricsNamespace = "broln"
+
+// nodeCollector is a prometheus.Collector that exports the domain level
+// metrics of the node, such as its channel balances, forwarding totals and
+// the size of the channel graph. All values are sampled from their source
+// each time the metrics are scraped.
+type nodeCollector struct {
+	cfg *MetricsConfig
+
+	// Channel metrics, labeled by channel point and peer.
+	chanLocalBalanceDesc  *prometheus.Desc
+	chanRemoteBalanceDesc *prometheus.Desc
+	chanCapacityDesc      *prometheus.Desc
+	chanPendingHTLCsDesc  *prometheus.Desc
+
+	// Forwarding metrics.
+	fwdSettledDesc *prometheus.Desc
+	fwdFailedDesc  *prometheus.Desc
+	fwdFeesDesc    *prometheus.Desc
+
+	// Graph metrics.
+	graphNodesDesc    *prometheus.Desc
+	graphChannelsDesc *prometheus.Desc
+
+	// Sweeper metrics.
+	sweeperPendingDesc *prometheus.Desc
+
+	// Watchtower client metrics, labeled by client type.
+	towerTasksPendingDesc    *prometheus.Desc
+	towerTasksAcceptedDesc   *prometheus.Desc
+	towerTasksIneligibleDesc *prometheus.Desc
+	towerSessionsDesc        *prometheus.Desc
+
+	// Mission control metrics.
+	mcPairsDesc          *prometheus.Desc
+	mcPairsFailedDesc    *prometheus.Desc
+	mcPairsSucceededDesc *prometheus.Desc
+}
+
+// A compile time check to ensure nodeCollector implements the
+// prometheus.Collector interface.
+var _ prometheus.Collector = (*nodeCollector)(nil)
+
+// newNodeCollector creates a new node collector sampling the sources of the
+// given config.
+func newNodeCollector(cfg *MetricsConfig) *nodeCollector {
+	chanLabels := []string{"chan_point", "peer"}
+	towerLabels := []string{"client"}
+
+	newDesc := func(name, help string, labels []string) *prometheus.Desc {
+		return prometheus.NewDesc(
+			prometheus.BuildFQName(metricsNamespace, "", name),
+			help, labels, nil,
+		)
+	}
+
+	return &nodeCollector{
+		cfg: cfg,
+		chanLocalBalanceDesc: newDesc(
+			"channel_local_balance_sat",
+			"local balance of the channel in satoshis", chanLabels,
+		),
+		chanRemoteBalanceDesc: newDesc(
+			"channel_remote_balance_sat",
+			"remote balance of the channel in satoshis", chanLabels,
+		),
+		chanCapacityDesc: newDesc(
+			"channel_capacity_sat",
+			"capacity of the channel in satoshis", chanLabels,
+		),
+		chanPendingHTLCsDesc: newDesc(
+			"channel_pending_htlcs",
+			"number of HTLCs pending on the local commitment of "+
+				"the channel", chanLabels,
+		),
+		fwdSettledDesc: newDesc(
+			"forwards_settled_total",
+			"number of forwarded HTLCs settled since startup", nil,
+		),
+		fwdFailedDesc: newDesc(
+			"forwards_failed_total",
+			"number of forwarded HTLCs failed since startup", nil,
+		),
+		fwdFeesDesc: newDesc(
+			"forwarding_fees_msat_total",
+			"fees earned by settled forwards since startup in "+
+				"millisatoshis", nil,
+		),
+		graphNodesDesc: newDesc(
+			"graph_nodes",
+			"number of nodes in the channel graph", nil,
+		),
+		graphChannelsDesc: newDesc(
+			"graph_channels",
+			"number of channels in the channel graph", nil,
+		),
+		sweeperPendingDesc: newDesc(
+			"sweeper_pending_inputs",
+			"number of inputs the sweeper is attempting to sweep",
+			nil,
+		),
+		towerTasksPendingDesc: newDesc(
+			"wtclient_tasks_pending",
+			"number of backups pending to be acknowledged by "+
+				"watchtowers", towerLabels,
+		),
+		towerTasksAcceptedDesc: newDesc(
+			"wtclient_tasks_accepted_total",
+			"number of backups accepted by watchtowers since "+
+				"startup", towerLabels,
+		),
+		towerTasksIneligibleDesc: newDesc(
+			"wtclient_tasks_ineligible_total",
+			"number of backups watchtowers failed to acknowledge "+
+				"since startup", towerLabels,
+		),
+		towerSessionsDesc: newDesc(
+			"wtclient_sessions_acquired_total",
+			"number of watchtower sessions acquired since startup",
+			towerLabels,
+		),
+		mcPairsDesc: newDesc(
+			"mission_control_pairs",
+			"number of node pairs mission control has results for",
+			nil,
+		),
+		mcPairsFailedDesc: newDesc(
+			"mission_control_pairs_failed",
+			"number of node pairs with a recorded failure", nil,
+		),
+		mcPairsSucceededDesc: newDesc(
+			"mission_control_pairs_succeeded",
+			"number of node pairs with a recorded success", nil,
+		),
+	}
+}
+
+// Describe sends the super-set of all possible descriptors of metrics
+// collected by this Collector to the provided channel.
+//
+// NOTE: Part of the prometheus.Collector interface.
+func (c *nodeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.chanLocalBalanceDesc
+	ch <- c.chanRemoteBalanceDesc
+	ch <- c.chanCapacityDesc
+	ch <- c.chanPendingHTLCsDesc
+	ch <- c.fwdSettledDesc
+	ch <- c.fwdFailedDesc
+	ch <- c.fwdFeesDesc
+	ch <- c.graphNodesDesc
+	ch <- c.graphChannelsDesc
+	ch <- c.sweeperPendingDesc
+	ch <- c.towerTasksPendingDesc
+	ch <- c.towerTasksAcceptedDesc
+	ch <- c.towerTasksIneligibleDesc
+	ch <- c.towerSessionsDesc
+	ch <- c.mcPairsDesc
+	ch <- c.mcPairsFailedDesc
+	ch <- c.mcPairsSucceededDesc
+}
+
+// Collect is called by the Prometheus registry when collecting metrics.
+// Failing to sample a source only causes its metrics to be skipped, so the
+// remaining metrics are still exported.
+//
+// NOTE: Part of the prometheus.Collector interface.
+func (c *nodeCollector) Collect(ch chan<- prometheus.Metric) {
+	if c.cfg.Channels != nil {
+		c.collectChannels(ch)
+	}
+	if c.cfg.Switch != nil {
+		c.collectForwards(ch)
+	}
+	if c.cfg.Graph != nil {
+		c.collectGraph(ch)
+	}
+	if c.cfg.Sweeper != nil {
+		c.collectSweeper(ch)
+	}
+	if c.cfg.TowerClient != nil {
+		c.collectTowerClient(ch, c.cfg.TowerClient, "legacy")
+	}
+	if c.cfg.AnchorTowerClient != nil {
+		c.collectTowerClient(ch, c.cfg.AnchorTowerClient, "anchor")
+	}
+	if c.cfg.MissionControl != nil {
+		c.collectMissionControl(ch)
+	}
+}
+
+// collectChannels exports the balances and pending HTLCs of each of our
+// open channels.
+func (c *nodeCollector) collectChannels(ch chan<- prometheus.Metric) {
+	channels, err := c.cfg.Channels.FetchAllOpenChannels()
+	if err != nil {
+		log.Errorf("Unable to fetch open channels: %v", err)
+		return
+	}
+
+	for _, channel := range channels {
+		chanPoint := channel.FundingOutpoint.String()
+		peer := hex.EncodeToString(
+			channel.IdentityPub.SerializeCompressed(),
+		)
+		commitment := channel.LocalCommitment
+
+		ch <- prometheus.MustNewConstMetric(
+			c.chanLocalBalanceDesc, prometheus.GaugeValue,
+			float64(commitment.LocalBalance.ToSatoshis()),
+			chanPoint, peer,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.chanRemoteBalanceDesc, prometheus.GaugeValue,
+			float64(commitment.RemoteBalance.ToSatoshis()),
+			chanPoint, peer,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.chanCapacityDesc, prometheus.GaugeValue,
+			float64(channel.Capacity), chanPoint, peer,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.chanPendingHTLCsDesc, prometheus.GaugeValue,
+			float64(len(commitment.Htlcs)), chanPoint, peer,
+		)
+	}
+}
+
+// collectForwards exports the forwarding totals of the switch.
+func (c *nodeCollector) collectForwards(ch chan<- prometheus.Metric) {
+	stats := c.cfg.Switch.ForwardingStats()
+
+	ch <- prometheus.MustNewConstMetric(
+		c.fwdSettledDesc, prometheus.CounterValue,
+		float64(stats.NumSettled),
+	)
+	ch <- prometheus.MustNewConstMetric(
+		c.fwdFailedDesc, prometheus.CounterValue,
+		float64(stats.NumFailed),
+	)
+	ch <- prometheus.MustNewConstMetric(
+		c.fwdFeesDesc, prometheus.CounterValue,
+		float64(stats.FeesEarned),
+	)
+}
+
+// collectGraph exports the number of nodes and channels in the channel
+// graph.
+func (c *nodeCollector) collectGraph(ch chan<- prometheus.Metric) {
+	var numNodes int
+	chanIDs := make(map[uint64]struct{})
+	err := c.cfg.Graph.ForEachNodeCached(func(_ route.Vertex,
+		chans map[uint64]*channeldb.DirectedChannel) error {
+
+		numNodes++
+		for chanID := range chans {
+			chanIDs[chanID] = struct{}{}
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Errorf("Unable to walk channel graph: %v", err)
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(
+		c.graphNodesDesc, prometheus.GaugeValue, float64(numNodes),
+	)
+	ch <- prometheus.MustNewConstMetric(
+		c.graphChannelsDesc, prometheus.GaugeValue,
+		float64(len(chanIDs)),
+	)
+}
+
+// collectSweeper exports the number of inputs pending to be swept.
+func (c *nodeCollector) collectSweeper(ch chan<- prometheus.Metric) {
+	inputs, err := c.cfg.Sweeper.PendingInputs()
+	if err != nil {
+		log.Errorf("Unable to fetch pending sweeps: %v", err)
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(
+		c.sweeperPendingDesc, prometheus.GaugeValue,
+		float64(len(inputs)),
+	)
+}
+
+// collectTowerClient exports the backup queue and session statistics of the
+// given watchtower client.
+func (c *nodeCollector) collectTowerClient(ch chan<- prometheus.Metric,
+	client TowerClientSource, clientType string) {
+
+	stats := client.Stats()
+
+	ch <- prometheus.MustNewConstMetric(
+		c.towerTasksPendingDesc, prometheus.GaugeValue,
+		float64(stats.NumTasksPending), clientType,
+	)
+	ch <- prometheus.MustNewConstMetric(
+		c.towerTasksAcceptedDesc, prometheus.CounterValue,
+		float64(stats.NumTasksAccepted), clientType,
+	)
+	ch <- prometheus.MustNewConstMetric(
+		c.towerTasksIneligibleDesc, prometheus.CounterValue,
+		float64(stats.NumTasksIneligible), clientType,
+	)
+	ch <- prometheus.MustNewConstMetric(
+		c.towerSessionsDesc, prometheus.CounterValue,
+		float64(stats.NumSessionsAcquired), clientType,
+	)
+}
+
+// collectMissionControl exports the number of node pairs mission control
+// has recorded payment results for.
+func (c *nodeCollector) collectMissionControl(ch chan<- prometheus.Metric) {
+	snapshot := c.cfg.MissionControl.GetHistorySnapshot()
+
+	var numFailed, numSucceeded int
+	for _, pair := range snapshot.Pairs {
+		if !pair.FailTime.IsZero() {
+			numFailed++
+		}
+		if !pair.SuccessTime.IsZero() {
+			numSucceeded++
+		}
+	}
+
+	ch <- prometheus.MustNewConstMetric(
+		c.mcPairsDesc, prometheus.GaugeValue,
+		float64(len(snapshot.Pairs)),
+	)
+	ch <- prometheus.MustNewConstMetric(
+		c.mcPairsFailedDesc, prometheus.GaugeValue, float64(numFailed),
+	)
+	ch <- prometheus.MustNewConstMetric(
+		c.mcPairsSucceededDesc, prometheus.GaugeValue,
+		float64(numSucceeded),
+	)
+}
@@ -0,0 +1,141 @@
+//go:build monitoring
+// +build monitoring
+
+package monitoring
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/brsuite/brond/btcec"
+	"github.com/brsuite/brond/wire"
+	"github.com/brsuite/broln/channeldb"
+	"github.com/brsuite/broln/htlcswitch"
+	"github.com/brsuite/broln/lnwire"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+const testPeer = "0286098b97bc843372b4426d4b276cea9aa2f48f0428d6f5b66ae101" +
+	"befc14f8b4"
+
+// mockChannelSource is a ChannelSource returning a fixed set of channels.
+type mockChannelSource struct {
+	channels []*channeldb.OpenChannel
+	err      error
+}
+
+func (m *mockChannelSource) FetchAllOpenChannels() ([]*channeldb.OpenChannel,
+	error) {
+
+	return m.channels, m.err
+}
+
+// mockForwardingSource is a ForwardingSource returning fixed totals.
+type mockForwardingSource struct {
+	stats htlcswitch.ForwardingStats
+}
+
+func (m *mockForwardingSource) ForwardingStats() htlcswitch.ForwardingStats {
+	return m.stats
+}
+
+// newTestChannel returns a channel with the given balances and number of
+// pending HTLCs.
+func newTestChannel(t *testing.T, local, remote lnwire.MilliSatoshi,
+	numHtlcs int) *channeldb.OpenChannel {
+
+	peerBytes, err := hex.DecodeString(testPeer)
+	require.NoError(t, err)
+	peer, err := btcec.ParsePubKey(peerBytes, btcec.S256())
+	require.NoError(t, err)
+
+	return &channeldb.OpenChannel{
+		FundingOutpoint: wire.OutPoint{Index: 1},
+		IdentityPub:     peer,
+		Capacity:        (local + remote).ToSatoshis(),
+		LocalCommitment: channeldb.ChannelCommitment{
+			LocalBalance:  local,
+			RemoteBalance: remote,
+			Htlcs:         make([]channeldb.HTLC, numHtlcs),
+		},
+	}
+}
+
+// TestNodeCollector asserts that the channel and forwarding metrics are
+// collected from their sources, and that all of them are described.
+func TestNodeCollector(t *testing.T) {
+	t.Parallel()
+
+	collector := newNodeCollector(&MetricsConfig{
+		Channels: &mockChannelSource{
+			channels: []*channeldb.OpenChannel{
+				newTestChannel(t, 300_000_000, 200_000_000, 2),
+			},
+		},
+		Switch: &mockForwardingSource{
+			stats: htlcswitch.ForwardingStats{
+				NumSettled: 7,
+				NumFailed:  3,
+				FeesEarned: 1500,
+			},
+		},
+	})
+
+	// The pedantic registry fails the collection if a metric is collected
+	// that wasn't described.
+	registry := prometheus.NewPedanticRegistry()
+	require.NoError(t, registry.Register(collector))
+
+	labels := `{chan_point="` + strings.Repeat("0", 64) + `:1",peer="` +
+		testPeer + `"}`
+	expected := `
+# HELP broln_channel_local_balance_sat local balance of the channel in satoshis
+# TYPE broln_channel_local_balance_sat gauge
+broln_channel_local_balance_sat` + labels + ` 300000
+# HELP broln_channel_remote_balance_sat remote balance of the channel in satoshis
+# TYPE broln_channel_remote_balance_sat gauge
+broln_channel_remote_balance_sat` + labels + ` 200000
+# HELP broln_channel_capacity_sat capacity of the channel in satoshis
+# TYPE broln_channel_capacity_sat gauge
+broln_channel_capacity_sat` + labels + ` 500000
+# HELP broln_channel_pending_htlcs number of HTLCs pending on the local commitment of the channel
+# TYPE broln_channel_pending_htlcs gauge
+broln_channel_pending_htlcs` + labels + ` 2
+# HELP broln_forwards_settled_total number of forwarded HTLCs settled since startup
+# TYPE broln_forwards_settled_total counter
+broln_forwards_settled_total 7
+# HELP broln_forwards_failed_total number of forwarded HTLCs failed since startup
+# TYPE broln_forwards_failed_total counter
+broln_forwards_failed_total 3
+# HELP broln_forwarding_fees_msat_total fees earned by settled forwards since startup in millisatoshis
+# TYPE broln_forwarding_fees_msat_total counter
+broln_forwarding_fees_msat_total 1500
+`
+	err := testutil.GatherAndCompare(registry, strings.NewReader(expected))
+	require.NoError(t, err)
+
+	// Every metric the collector can export must be described.
+	descs := make(chan *prometheus.Desc, 32)
+	collector.Describe(descs)
+	close(descs)
+	require.Len(t, descs, 17)
+}
+
+// TestNodeCollectorSourceError asserts that a source that fails to be
+// sampled only causes its own metrics to be skipped.
+func TestNodeCollectorSourceError(t *testing.T) {
+	t.Parallel()
+
+	collector := newNodeCollector(&MetricsConfig{
+		Channels: &mockChannelSource{
+			err: errors.New("channel db unavailable"),
+		},
+		Switch: &mockForwardingSource{},
+	})
+
+	require.Equal(t, 3, testutil.CollectAndCount(collector))
+}
@@ -0,0 +1,98 @@
+package offers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/brsuite/bronutil/bech32"
+)
+
+const (
+	// OfferHRP is the human readable part of encoded offers.
+	OfferHRP = "lno"
+
+	// InvoiceRequestHRP is the human readable part of encoded invoice
+	// requests.
+	InvoiceRequestHRP = "lnr"
+
+	// InvoiceHRP is the human readable part of encoded invoices.
+	InvoiceHRP = "lni"
+
+	// charset is the bech32 character set.
+	charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+)
+
+var (
+	// ErrInvalidEncoding is returned when a string doesn't follow the
+	// encoding of BOLT 12 messages.
+	ErrInvalidEncoding = errors.New("invalid bolt 12 encoding")
+)
+
+// encodeBech32 encodes the passed data using the bech32 character set without
+// a checksum, which is how BOLT 12 messages are represented as strings. Unlike
+// BOLT 11 invoices, they aren't limited in length, and their signature
+// protects them from any modification already.
+func encodeBech32(hrp string, data []byte) (string, error) {
+	converted, err := bech32.ConvertBits(data, 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	b.WriteString(hrp)
+	b.WriteString("1")
+	for _, c := range converted {
+		b.WriteByte(charset[c])
+	}
+
+	return b.String(), nil
+}
+
+// decodeBech32 decodes a BOLT 12 string with the expected human readable part.
+// The string may be split into several parts joined by a '+' followed by
+// optional whitespace, which allows long offers to be spread over several
+// lines.
+func decodeBech32(expectedHRP, s string) ([]byte, error) {
+	// All characters must be of the same case.
+	lower := strings.ToLower(s)
+	if s != lower && s != strings.ToUpper(s) {
+		return nil, fmt.Errorf("%w: mixed case", ErrInvalidEncoding)
+	}
+
+	parts := strings.Split(lower, "+")
+	for i, part := range parts {
+		if i > 0 {
+			part = strings.TrimLeft(part, " \t\r\n")
+		}
+		if part == "" {
+			return nil, fmt.Errorf("%w: empty part",
+				ErrInvalidEncoding)
+		}
+		parts[i] = part
+	}
+	joined := strings.Join(parts, "")
+
+	sep := strings.LastIndexByte(joined, '1')
+	if sep < 1 {
+		return nil, fmt.Errorf("%w: missing separator",
+			ErrInvalidEncoding)
+	}
+	if hrp := joined[:sep]; hrp != expectedHRP {
+		return nil, fmt.Errorf("%w: expected prefix %v, got %v",
+			ErrInvalidEncoding, expectedHRP, hrp)
+	}
+
+	dataChars := joined[sep+1:]
+	data := make([]byte, len(dataChars))
+	for i := 0; i < len(dataChars); i++ {
+		index := strings.IndexByte(charset, dataChars[i])
+		if index < 0 {
+			return nil, fmt.Errorf("%w: invalid character %q",
+				ErrInvalidEncoding, dataChars[i])
+		}
+		data[i] = byte(index)
+	}
+
+	return bech32.ConvertBits(data, 5, 8, false)
+}
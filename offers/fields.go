@@ -0,0 +1,97 @@
+package offers
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/brsuite/brond/chaincfg/chainhash"
+	"github.com/brsuite/broln/lnwire"
+	"github.com/brsuite/broln/routing/blindedpath"
+)
+
+// encodeChains serializes a list of chain hashes.
+func encodeChains(chains []chainhash.Hash) []byte {
+	b := make([]byte, 0, len(chains)*chainhash.HashSize)
+	for _, chain := range chains {
+		b = append(b, chain[:]...)
+	}
+
+	return b
+}
+
+// decodeChains parses a list of chain hashes.
+func decodeChains(b []byte) ([]chainhash.Hash, error) {
+	if len(b)%chainhash.HashSize != 0 {
+		return nil, fmt.Errorf("invalid chains length %d", len(b))
+	}
+
+	chains := make([]chainhash.Hash, len(b)/chainhash.HashSize)
+	for i := range chains {
+		copy(chains[i][:], b[i*chainhash.HashSize:])
+	}
+
+	return chains, nil
+}
+
+// decodeChain parses a single chain hash.
+func decodeChain(b []byte) (chainhash.Hash, error) {
+	var chain chainhash.Hash
+	if len(b) != chainhash.HashSize {
+		return chain, fmt.Errorf("invalid chain length %d", len(b))
+	}
+	copy(chain[:], b)
+
+	return chain, nil
+}
+
+// encodeFeatures serializes a feature vector without a length prefix.
+func encodeFeatures(features *lnwire.RawFeatureVector) []byte {
+	var b bytes.Buffer
+	_ = features.EncodeBase256(&b)
+
+	return b.Bytes()
+}
+
+// decodeFeatures parses a feature vector that takes up all passed bytes.
+func decodeFeatures(b []byte) (*lnwire.RawFeatureVector, error) {
+	features := lnwire.NewRawFeatureVector()
+	err := features.DecodeBase256(bytes.NewReader(b), len(b))
+	if err != nil {
+		return nil, err
+	}
+
+	return features, nil
+}
+
+// encodePaths serializes a list of blinded paths.
+func encodePaths(paths []*blindedpath.BlindedPath) ([]byte, error) {
+	var b bytes.Buffer
+	for _, path := range paths {
+		if err := path.Encode(&b); err != nil {
+			return nil, err
+		}
+	}
+
+	return b.Bytes(), nil
+}
+
+// decodePaths parses a non-empty list of blinded paths.
+func decodePaths(b []byte) ([]*blindedpath.BlindedPath, error) {
+	var (
+		r     = bytes.NewReader(b)
+		paths []*blindedpath.BlindedPath
+	)
+	for r.Len() > 0 {
+		path := &blindedpath.BlindedPath{}
+		if err := path.Decode(r); err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("empty list of blinded paths")
+	}
+
+	return paths, nil
+}
@@ -0,0 +1,396 @@
+package offers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/brsuite/brond/btcec"
+	"github.com/brsuite/broln/lntypes"
+	"github.com/brsuite/broln/lnwire"
+	"github.com/brsuite/broln/routing/blindedpath"
+)
+
+const (
+	// InvoicePathsType is the type of the record holding the blinded
+	// paths the invoice can be paid over.
+	InvoicePathsType = 160
+
+	// InvoiceBlindedPayType is the type of the record holding the payment
+	// relay information of each of the invoice paths.
+	InvoiceBlindedPayType = 162
+
+	// InvoiceCreatedAtType is the type of the record holding the unix time
+	// the invoice was created at.
+	InvoiceCreatedAtType = 164
+
+	// InvoiceRelativeExpiryType is the type of the record holding the
+	// number of seconds after creation the invoice expires.
+	InvoiceRelativeExpiryType = 166
+
+	// InvoicePaymentHashType is the type of the record holding the
+	// payment hash of the invoice.
+	InvoicePaymentHashType = 168
+
+	// InvoiceAmountType is the type of the record holding the amount of
+	// the invoice.
+	InvoiceAmountType = 170
+
+	// InvoiceFallbacksType is the type of the record holding on-chain
+	// fallback addresses.
+	InvoiceFallbacksType = 172
+
+	// InvoiceFeaturesType is the type of the record holding the features
+	// of the invoice.
+	InvoiceFeaturesType = 174
+
+	// InvoiceNodeIDType is the type of the record holding the key the
+	// invoice is signed with.
+	InvoiceNodeIDType = 176
+
+	// invoiceTypeStart is the first type of the range of invoice records.
+	invoiceTypeStart = 160
+
+	// invoiceTypeEnd is the last type of the range of invoice records.
+	invoiceTypeEnd = 239
+
+	// invoiceMessageName is the name invoice signatures commit to.
+	invoiceMessageName = "invoice"
+
+	// DefaultRelativeExpiry is the expiry of invoices that don't specify
+	// one.
+	DefaultRelativeExpiry = 2 * time.Hour
+)
+
+var (
+	// ErrInvoiceExpired is returned when paying an expired invoice.
+	ErrInvoiceExpired = errors.New("invoice expired")
+
+	// knownInvoiceTypes are the invoice record types we understand, apart
+	// from the invoice request records.
+	knownInvoiceTypes = map[uint64]struct{}{
+		InvoicePathsType:          {},
+		InvoiceBlindedPayType:     {},
+		InvoiceCreatedAtType:      {},
+		InvoiceRelativeExpiryType: {},
+		InvoicePaymentHashType:    {},
+		InvoiceAmountType:         {},
+		InvoiceFallbacksType:      {},
+		InvoiceFeaturesType:       {},
+		InvoiceNodeIDType:         {},
+	}
+)
+
+// Invoice is a BOLT 12 invoice, which the issuer of an offer creates in
+// response to an invoice request.
+type Invoice struct {
+	// InvoiceRequest is the request the invoice was created for.
+	InvoiceRequest *InvoiceRequest
+
+	// Paths are blinded paths to the recipient the invoice can be paid
+	// over. If empty, the invoice is paid to NodeID directly.
+	Paths []*blindedpath.BlindedPath
+
+	// BlindedPayInfo holds the payment relay information of each path.
+	BlindedPayInfo []*blindedpath.PayInfo
+
+	// CreatedAt is the time the invoice was created at.
+	CreatedAt time.Time
+
+	// RelativeExpiry is the time after creation the invoice expires. If
+	// zero, the invoice expires after DefaultRelativeExpiry.
+	RelativeExpiry time.Duration
+
+	// PaymentHash is the payment hash of the invoice.
+	PaymentHash lntypes.Hash
+
+	// Amount is the amount that is to be paid.
+	Amount lnwire.MilliSatoshi
+
+	// Fallbacks are the encoded on-chain fallback addresses.
+	Fallbacks []byte
+
+	// Features are the features of the invoice.
+	Features *lnwire.RawFeatureVector
+
+	// NodeID is the key the invoice is signed with.
+	NodeID *btcec.PublicKey
+
+	// Signature is the BIP-340 signature of NodeID over the invoice.
+	Signature []byte
+
+	// ExtraRecords are invoice records of unknown odd types.
+	ExtraRecords map[uint64][]byte
+
+	// raw are the records the invoice was decoded from, which its
+	// signature commits to.
+	raw tlvRecords
+}
+
+// records returns the TLV records of the invoice, including the ones of the
+// invoice request it mirrors.
+func (i *Invoice) records() (tlvRecords, error) {
+	if i.raw != nil {
+		records := make(tlvRecords)
+		for typ, value := range i.raw {
+			records[typ] = value
+		}
+
+		return records, nil
+	}
+
+	invReqRecords, err := i.InvoiceRequest.records()
+	if err != nil {
+		return nil, err
+	}
+
+	// The signature of the invoice request isn't mirrored.
+	records := invReqRecords.inRange(0, invReqTypeEnd)
+	for typ, value := range i.ExtraRecords {
+		records[typ] = value
+	}
+
+	if len(i.Paths) > 0 {
+		paths, err := encodePaths(i.Paths)
+		if err != nil {
+			return nil, err
+		}
+		records[InvoicePathsType] = paths
+
+		var payInfo bytes.Buffer
+		for _, info := range i.BlindedPayInfo {
+			if err := info.Encode(&payInfo); err != nil {
+				return nil, err
+			}
+		}
+		records[InvoiceBlindedPayType] = payInfo.Bytes()
+	}
+
+	records[InvoiceCreatedAtType] = encodeTU64(uint64(i.CreatedAt.Unix()))
+	if i.RelativeExpiry != 0 {
+		records[InvoiceRelativeExpiryType] = encodeTU64(
+			uint64(i.RelativeExpiry / time.Second),
+		)
+	}
+	records[InvoicePaymentHashType] = i.PaymentHash[:]
+	records[InvoiceAmountType] = encodeTU64(uint64(i.Amount))
+	if len(i.Fallbacks) > 0 {
+		records[InvoiceFallbacksType] = i.Fallbacks
+	}
+	if i.Features != nil && i.Features.SerializeSize() > 0 {
+		records[InvoiceFeaturesType] = encodeFeatures(i.Features)
+	}
+	if i.NodeID != nil {
+		records[InvoiceNodeIDType] = i.NodeID.SerializeCompressed()
+	}
+	if len(i.Signature) > 0 {
+		records[SignatureType] = i.Signature
+	}
+
+	return records, nil
+}
+
+// Sign signs the invoice with the passed signer, which must sign with the
+// private key of NodeID.
+func (i *Invoice) Sign(sign SignFunc) error {
+	if i.raw != nil {
+		return errors.New("decoded invoice can't be signed")
+	}
+
+	i.Signature = nil
+	records, err := i.records()
+	if err != nil {
+		return err
+	}
+
+	if err := signRecords(invoiceMessageName, records, sign); err != nil {
+		return err
+	}
+	i.Signature = records[SignatureType]
+
+	return nil
+}
+
+// Verify checks the signature of the invoice.
+func (i *Invoice) Verify() error {
+	records, err := i.records()
+	if err != nil {
+		return err
+	}
+
+	return verifyRecords(invoiceMessageName, records, i.NodeID)
+}
+
+// Serialize returns the TLV encoding of the invoice.
+func (i *Invoice) Serialize() ([]byte, error) {
+	records, err := i.records()
+	if err != nil {
+		return nil, err
+	}
+
+	return records.encode(), nil
+}
+
+// Encode returns the string representation of the invoice.
+func (i *Invoice) Encode() (string, error) {
+	b, err := i.Serialize()
+	if err != nil {
+		return "", err
+	}
+
+	return encodeBech32(InvoiceHRP, b)
+}
+
+// ExpiresAt returns the time the invoice expires at.
+func (i *Invoice) ExpiresAt() time.Time {
+	expiry := i.RelativeExpiry
+	if expiry == 0 {
+		expiry = DefaultRelativeExpiry
+	}
+
+	return i.CreatedAt.Add(expiry)
+}
+
+// PaymentAddr returns the payment address HTLCs paying the invoice must carry
+// when paying the recipient directly. As BOLT 12 invoices don't have a
+// payment address of their own, it is derived from the signed content of the
+// invoice, which only the payer and recipient know.
+func (i *Invoice) PaymentAddr() ([32]byte, error) {
+	records, err := i.records()
+	if err != nil {
+		return [32]byte{}, err
+	}
+
+	root := merkleRoot(records)
+	tag := []byte("lightning" + invoiceMessageName + "payment_addr")
+
+	return taggedHash(tag, root[:]), nil
+}
+
+// DeserializeInvoice parses the TLV encoding of an invoice. The signature is
+// not verified.
+func DeserializeInvoice(b []byte) (*Invoice, error) {
+	records, err := decodeRecords(b)
+	if err != nil {
+		return nil, err
+	}
+
+	for typ := range records {
+		if typ > invoiceTypeEnd && typ != SignatureType {
+			return nil, fmt.Errorf("invoice contains type %d "+
+				"outside of invoice range", typ)
+		}
+	}
+
+	invReq, err := invReqFromRecords(records.inRange(0, invReqTypeEnd))
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkUnknownTypes(
+		records, invoiceTypeStart, invoiceTypeEnd, knownInvoiceTypes,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	i := &Invoice{
+		InvoiceRequest: invReq,
+		ExtraRecords:   make(map[uint64][]byte),
+		raw:            records,
+	}
+
+	var payInfo []byte
+	for typ, value := range records.inRange(invoiceTypeStart, signatureTypeEnd) {
+		switch typ {
+		case InvoicePathsType:
+			i.Paths, err = decodePaths(value)
+
+		case InvoiceBlindedPayType:
+			payInfo = value
+
+		case InvoiceCreatedAtType:
+			var createdAt uint64
+			createdAt, err = decodeTU64(value)
+			i.CreatedAt = time.Unix(int64(createdAt), 0)
+
+		case InvoiceRelativeExpiryType:
+			var expiry uint64
+			expiry, err = decodeTU64(value)
+			i.RelativeExpiry = time.Duration(expiry) * time.Second
+
+		case InvoicePaymentHashType:
+			i.PaymentHash, err = lntypes.MakeHash(value)
+
+		case InvoiceAmountType:
+			var amt uint64
+			amt, err = decodeTU64(value)
+			i.Amount = lnwire.MilliSatoshi(amt)
+
+		case InvoiceFallbacksType:
+			i.Fallbacks = value
+
+		case InvoiceFeaturesType:
+			i.Features, err = decodeFeatures(value)
+
+		case InvoiceNodeIDType:
+			i.NodeID, err = decodePubKey(value)
+
+		case SignatureType:
+			i.Signature = value
+
+		default:
+			i.ExtraRecords[typ] = value
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid invoice record %d: %v",
+				typ, err)
+		}
+	}
+
+	r := bytes.NewReader(payInfo)
+	for r.Len() > 0 {
+		info := &blindedpath.PayInfo{}
+		if err := info.Decode(r); err != nil {
+			return nil, fmt.Errorf("invalid blinded pay info: %v",
+				err)
+		}
+		i.BlindedPayInfo = append(i.BlindedPayInfo, info)
+	}
+
+	_, hasCreatedAt := records[InvoiceCreatedAtType]
+	_, hasPaymentHash := records[InvoicePaymentHashType]
+	_, hasAmount := records[InvoiceAmountType]
+
+	switch {
+	case len(i.BlindedPayInfo) != len(i.Paths):
+		return nil, errors.New("invoice blinded pay info doesn't " +
+			"match paths")
+
+	case !hasCreatedAt:
+		return nil, errors.New("invoice without creation time")
+
+	case !hasPaymentHash:
+		return nil, errors.New("invoice without payment hash")
+
+	case !hasAmount:
+		return nil, errors.New("invoice without amount")
+
+	case i.NodeID == nil:
+		return nil, errors.New("invoice without node id")
+	}
+
+	return i, nil
+}
+
+// DecodeInvoice parses the string representation of an invoice. The signature
+// is not verified.
+func DecodeInvoice(s string) (*Invoice, error) {
+	b, err := decodeBech32(InvoiceHRP, s)
+	if err != nil {
+		return nil, err
+	}
+
+	return DeserializeInvoice(b)
+}
@@ -0,0 +1,104 @@
+package offers
+
+import (
+	"fmt"
+)
+
+const (
+	// InvoiceErrorErroneousFieldType is the type of the record holding the
+	// type of the record that caused the error.
+	InvoiceErrorErroneousFieldType = 1
+
+	// InvoiceErrorSuggestedValueType is the type of the record holding a
+	// value for the erroneous field that would have been accepted.
+	InvoiceErrorSuggestedValueType = 3
+
+	// InvoiceErrorErrorType is the type of the record holding the error
+	// message.
+	InvoiceErrorErrorType = 5
+)
+
+// InvoiceError is sent in reply to an invoice request or invoice that can't be
+// processed.
+type InvoiceError struct {
+	// ErroneousField is the type of the record that caused the error, if
+	// any.
+	ErroneousField *uint64
+
+	// SuggestedValue is a value for the erroneous field that would have
+	// been accepted.
+	SuggestedValue []byte
+
+	// Message is a human readable description of the error.
+	Message string
+}
+
+// NewInvoiceError creates an invoice error with the given message.
+func NewInvoiceError(format string, args ...interface{}) *InvoiceError {
+	return &InvoiceError{
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// Error returns the error message.
+//
+// NOTE: This is part of the error interface.
+func (e *InvoiceError) Error() string {
+	if e.ErroneousField != nil {
+		return fmt.Sprintf("invoice error in field %d: %v",
+			*e.ErroneousField, e.Message)
+	}
+
+	return fmt.Sprintf("invoice error: %v", e.Message)
+}
+
+// Serialize returns the TLV encoding of the invoice error.
+func (e *InvoiceError) Serialize() []byte {
+	records := make(tlvRecords)
+	if e.ErroneousField != nil {
+		records[InvoiceErrorErroneousFieldType] = encodeTU64(
+			*e.ErroneousField,
+		)
+	}
+	if len(e.SuggestedValue) > 0 {
+		records[InvoiceErrorSuggestedValueType] = e.SuggestedValue
+	}
+	records[InvoiceErrorErrorType] = []byte(e.Message)
+
+	return records.encode()
+}
+
+// DeserializeInvoiceError parses the TLV encoding of an invoice error.
+func DeserializeInvoiceError(b []byte) (*InvoiceError, error) {
+	records, err := decodeRecords(b)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &InvoiceError{}
+	for typ, value := range records {
+		switch typ {
+		case InvoiceErrorErroneousFieldType:
+			var field uint64
+			field, err = decodeTU64(value)
+			e.ErroneousField = &field
+
+		case InvoiceErrorSuggestedValueType:
+			e.SuggestedValue = value
+
+		case InvoiceErrorErrorType:
+			e.Message = string(value)
+
+		default:
+			if typ%2 == 0 {
+				err = fmt.Errorf("unknown required type %d", typ)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid invoice error record "+
+				"%d: %v", typ, err)
+		}
+	}
+
+	return e, nil
+}
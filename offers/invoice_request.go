@@ -0,0 +1,300 @@
+package offers
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/brsuite/brond/btcec"
+	"github.com/brsuite/brond/chaincfg/chainhash"
+	"github.com/brsuite/broln/lnwire"
+)
+
+const (
+	// InvReqMetadataType is the type of the record holding the payer's
+	// metadata, which makes each invoice request unique.
+	InvReqMetadataType = 0
+
+	// InvReqChainType is the type of the record holding the chain the
+	// payer wants to pay on.
+	InvReqChainType = 80
+
+	// InvReqAmountType is the type of the record holding the amount the
+	// payer wants to pay.
+	InvReqAmountType = 82
+
+	// InvReqFeaturesType is the type of the record holding the features of
+	// the invoice request.
+	InvReqFeaturesType = 84
+
+	// InvReqQuantityType is the type of the record holding the number of
+	// items the payer requests.
+	InvReqQuantityType = 86
+
+	// InvReqPayerIDType is the type of the record holding the key the
+	// payer signs the invoice request with.
+	InvReqPayerIDType = 88
+
+	// InvReqPayerNoteType is the type of the record holding a note of the
+	// payer to the issuer.
+	InvReqPayerNoteType = 89
+
+	// invReqTypeEnd is the last type of the range of invoice request
+	// records.
+	invReqTypeEnd = 159
+
+	// invReqMessageName is the name invoice request signatures commit to.
+	invReqMessageName = "invoice_request"
+)
+
+var (
+	// knownInvReqTypes are the invoice request record types we understand,
+	// apart from the offer records.
+	knownInvReqTypes = map[uint64]struct{}{
+		InvReqMetadataType:  {},
+		InvReqChainType:     {},
+		InvReqAmountType:    {},
+		InvReqFeaturesType:  {},
+		InvReqQuantityType:  {},
+		InvReqPayerIDType:   {},
+		InvReqPayerNoteType: {},
+	}
+)
+
+// InvoiceRequest is a request for an invoice for an offer, which the payer
+// sends to the issuer of the offer.
+type InvoiceRequest struct {
+	// Offer is the offer the invoice is requested for.
+	Offer *Offer
+
+	// Metadata is random data of the payer that makes the request unique.
+	Metadata []byte
+
+	// Chain is the chain the payer wants to pay on. If nil, the request
+	// is for main net.
+	Chain *chainhash.Hash
+
+	// Amount is the amount the payer wants to pay. If zero, the payer
+	// pays the amount of the offer.
+	Amount lnwire.MilliSatoshi
+
+	// Features are the features of the invoice request.
+	Features *lnwire.RawFeatureVector
+
+	// Quantity is the number of items requested, which is only set if the
+	// offer supports quantities.
+	Quantity *uint64
+
+	// PayerID is the key the invoice request is signed with.
+	PayerID *btcec.PublicKey
+
+	// PayerNote is an optional note of the payer to the issuer.
+	PayerNote string
+
+	// Signature is the BIP-340 signature of PayerID over the request.
+	Signature []byte
+
+	// ExtraRecords are invoice request records of unknown odd types.
+	ExtraRecords map[uint64][]byte
+
+	// raw are the records the request was decoded from, which invoices
+	// must mirror exactly.
+	raw tlvRecords
+}
+
+// records returns the TLV records of the invoice request, including the ones
+// of the offer.
+func (r *InvoiceRequest) records() (tlvRecords, error) {
+	records := make(tlvRecords)
+	if r.raw != nil {
+		for typ, value := range r.raw {
+			records[typ] = value
+		}
+
+		return records, nil
+	}
+
+	offerRecords, err := r.Offer.records()
+	if err != nil {
+		return nil, err
+	}
+	for typ, value := range offerRecords {
+		records[typ] = value
+	}
+	for typ, value := range r.ExtraRecords {
+		records[typ] = value
+	}
+
+	records[InvReqMetadataType] = r.Metadata
+	if r.Chain != nil {
+		records[InvReqChainType] = r.Chain[:]
+	}
+	if r.Amount != 0 {
+		records[InvReqAmountType] = encodeTU64(uint64(r.Amount))
+	}
+	if r.Features != nil && r.Features.SerializeSize() > 0 {
+		records[InvReqFeaturesType] = encodeFeatures(r.Features)
+	}
+	if r.Quantity != nil {
+		records[InvReqQuantityType] = encodeTU64(*r.Quantity)
+	}
+	if r.PayerID != nil {
+		records[InvReqPayerIDType] = r.PayerID.SerializeCompressed()
+	}
+	if r.PayerNote != "" {
+		records[InvReqPayerNoteType] = []byte(r.PayerNote)
+	}
+	if len(r.Signature) > 0 {
+		records[SignatureType] = r.Signature
+	}
+
+	return records, nil
+}
+
+// Sign signs the invoice request with the passed signer, which must sign with
+// the private key of PayerID.
+func (r *InvoiceRequest) Sign(sign SignFunc) error {
+	if r.raw != nil {
+		return errors.New("decoded invoice request can't be signed")
+	}
+
+	r.Signature = nil
+	records, err := r.records()
+	if err != nil {
+		return err
+	}
+
+	if err := signRecords(invReqMessageName, records, sign); err != nil {
+		return err
+	}
+	r.Signature = records[SignatureType]
+
+	return nil
+}
+
+// Verify checks the signature of the invoice request.
+func (r *InvoiceRequest) Verify() error {
+	records, err := r.records()
+	if err != nil {
+		return err
+	}
+
+	return verifyRecords(invReqMessageName, records, r.PayerID)
+}
+
+// Serialize returns the TLV encoding of the invoice request.
+func (r *InvoiceRequest) Serialize() ([]byte, error) {
+	records, err := r.records()
+	if err != nil {
+		return nil, err
+	}
+
+	return records.encode(), nil
+}
+
+// ChainHash returns the chain the invoice request is for.
+func (r *InvoiceRequest) ChainHash(mainNet chainhash.Hash) chainhash.Hash {
+	if r.Chain != nil {
+		return *r.Chain
+	}
+
+	return mainNet
+}
+
+// DeserializeInvoiceRequest parses the TLV encoding of an invoice request.
+// The signature is not verified.
+func DeserializeInvoiceRequest(b []byte) (*InvoiceRequest, error) {
+	records, err := decodeRecords(b)
+	if err != nil {
+		return nil, err
+	}
+
+	for typ := range records {
+		if typ > invReqTypeEnd && typ != SignatureType {
+			return nil, fmt.Errorf("invoice request contains type "+
+				"%d outside of request range", typ)
+		}
+	}
+
+	return invReqFromRecords(records)
+}
+
+// invReqFromRecords parses the invoice request records of a TLV stream, which
+// may also be the request part of an invoice.
+func invReqFromRecords(allRecords tlvRecords) (*InvoiceRequest, error) {
+	records := allRecords.inRange(0, invReqTypeEnd)
+	if sig, ok := allRecords[SignatureType]; ok {
+		records[SignatureType] = sig
+	}
+
+	offer, err := offerFromRecords(records)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkUnknownTypes(
+		records, offerTypeEnd+1, invReqTypeEnd, knownInvReqTypes,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &InvoiceRequest{
+		Offer:        offer,
+		ExtraRecords: make(map[uint64][]byte),
+		raw:          records,
+	}
+	for typ, value := range records {
+		if typ >= offerTypeStart && typ <= offerTypeEnd {
+			continue
+		}
+
+		switch typ {
+		case InvReqMetadataType:
+			r.Metadata = value
+
+		case InvReqChainType:
+			var chain chainhash.Hash
+			chain, err = decodeChain(value)
+			r.Chain = &chain
+
+		case InvReqAmountType:
+			var amt uint64
+			amt, err = decodeTU64(value)
+			r.Amount = lnwire.MilliSatoshi(amt)
+
+		case InvReqFeaturesType:
+			r.Features, err = decodeFeatures(value)
+
+		case InvReqQuantityType:
+			var quantity uint64
+			quantity, err = decodeTU64(value)
+			r.Quantity = &quantity
+
+		case InvReqPayerIDType:
+			r.PayerID, err = decodePubKey(value)
+
+		case InvReqPayerNoteType:
+			r.PayerNote = string(value)
+
+		case SignatureType:
+			r.Signature = value
+
+		default:
+			r.ExtraRecords[typ] = value
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid invoice request record "+
+				"%d: %v", typ, err)
+		}
+	}
+
+	switch {
+	case len(r.Metadata) == 0:
+		return nil, errors.New("invoice request without metadata")
+
+	case r.PayerID == nil:
+		return nil, errors.New("invoice request without payer id")
+	}
+
+	return r, nil
+}
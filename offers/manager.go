@@ -0,0 +1,654 @@
+package offers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/brsuite/brond/btcec"
+	"github.com/brsuite/brond/chaincfg"
+	"github.com/brsuite/brond/chaincfg/chainhash"
+	"github.com/brsuite/broln/channeldb"
+	"github.com/brsuite/broln/clock"
+	"github.com/brsuite/broln/lntypes"
+	"github.com/brsuite/broln/lnwire"
+	"github.com/brsuite/broln/onionmsg"
+	"github.com/brsuite/broln/routing/blindedpath"
+	"github.com/brsuite/broln/subscribe"
+)
+
+const (
+	// FinalCltvDelta is the final CLTV delta of the invoices we create for
+	// offers. BOLT 12 invoices paid to the recipient directly don't carry
+	// a final CLTV delta, so payers use this value as well.
+	FinalCltvDelta = 40
+
+	// pathIDSize is the size of the random path IDs we use to match
+	// replies to our invoice requests.
+	pathIDSize = 32
+
+	// payerMetadataSize is the size of the random metadata of our invoice
+	// requests.
+	payerMetadataSize = 32
+)
+
+var (
+	// ErrManagerShuttingDown is returned when the manager is asked to do
+	// something while shutting down.
+	ErrManagerShuttingDown = errors.New("offers manager shutting down")
+
+	// ErrFetchTimeout is returned when the issuer of an offer doesn't
+	// reply to our invoice request in time.
+	ErrFetchTimeout = errors.New("timeout waiting for invoice")
+
+	// ErrCurrencyUnsupported is returned for offers with an amount in a
+	// currency other than millisatoshis.
+	ErrCurrencyUnsupported = errors.New("offers in other currencies are " +
+		"not supported")
+)
+
+// Messenger sends and receives the onion messages invoice requests and
+// invoices are exchanged over.
+type Messenger interface {
+	// SendMessage sends an onion message with the passed payload along
+	// the given blinded path.
+	SendMessage(path *blindedpath.BlindedPath,
+		payload *onionmsg.Payload) error
+
+	// ReplyPath creates a blinded path to our node that delivers the
+	// passed path ID along with the messages sent over it.
+	ReplyPath(pathID []byte) (*blindedpath.BlindedPath, error)
+
+	// SubscribeMessages subscribes to the onion messages we receive.
+	SubscribeMessages() (*subscribe.Client, error)
+}
+
+// Config houses the dependencies of the offers Manager.
+type Config struct {
+	// ChainHash is the genesis hash of the chain we operate on.
+	ChainHash chainhash.Hash
+
+	// NodePubKey is the public key of our node, which we sign invoices
+	// with.
+	NodePubKey *btcec.PublicKey
+
+	// MetadataKey is the secret key we authenticate the metadata of our
+	// offers with.
+	MetadataKey [32]byte
+
+	// SignInvoice signs the digest of an invoice with our node key.
+	SignInvoice SignFunc
+
+	// Messenger is used to exchange invoice requests and invoices.
+	Messenger Messenger
+
+	// AddInvoice adds an invoice to the invoice registry.
+	AddInvoice func(invoice *channeldb.Invoice,
+		paymentHash lntypes.Hash) (uint64, error)
+
+	// InvoiceFeatures returns the features of the invoices we add to the
+	// invoice registry.
+	InvoiceFeatures func() *lnwire.FeatureVector
+
+	// Clock is the time source of the manager.
+	Clock clock.Clock
+}
+
+// FetchInvoiceRequest describes the invoice a payer requests for an offer.
+type FetchInvoiceRequest struct {
+	// Offer is the offer the invoice is requested for.
+	Offer *Offer
+
+	// Amount is the amount the payer wants to pay. It is required if the
+	// offer doesn't specify an amount.
+	Amount lnwire.MilliSatoshi
+
+	// Quantity is the number of items requested. It must be set if and
+	// only if the offer supports quantities.
+	Quantity uint64
+
+	// PayerNote is an optional note to the issuer.
+	PayerNote string
+
+	// Timeout is the time to wait for the issuer's reply.
+	Timeout time.Duration
+}
+
+// Manager creates offers, answers the invoice requests payers send for them
+// and requests invoices for the offers of others.
+type Manager struct {
+	started sync.Once
+	stopped sync.Once
+
+	cfg *Config
+
+	// pending maps the path IDs of the reply paths of our outstanding
+	// invoice requests to the channel the reply is delivered on.
+	pending    map[string]chan *onionmsg.Payload
+	pendingMtx sync.Mutex
+
+	wg   sync.WaitGroup
+	quit chan struct{}
+}
+
+// NewManager creates a new offers Manager from the passed config.
+func NewManager(cfg *Config) *Manager {
+	return &Manager{
+		cfg:     cfg,
+		pending: make(map[string]chan *onionmsg.Payload),
+		quit:    make(chan struct{}),
+	}
+}
+
+// Start subscribes to incoming onion messages and starts processing them.
+func (m *Manager) Start() error {
+	var err error
+	m.started.Do(func() {
+		log.Info("Offers manager starting")
+
+		var client *subscribe.Client
+		client, err = m.cfg.Messenger.SubscribeMessages()
+		if err != nil {
+			return
+		}
+
+		m.wg.Add(1)
+		go m.messageHandler(client)
+	})
+
+	return err
+}
+
+// Stop signals the Manager for a graceful shutdown.
+func (m *Manager) Stop() error {
+	m.stopped.Do(func() {
+		log.Info("Offers manager shutting down")
+
+		close(m.quit)
+		m.wg.Wait()
+	})
+
+	return nil
+}
+
+// messageHandler dispatches the onion messages we receive.
+//
+// NOTE: This MUST be run as a goroutine.
+func (m *Manager) messageHandler(client *subscribe.Client) {
+	defer m.wg.Done()
+	defer client.Cancel()
+
+	for {
+		select {
+		case update := <-client.Updates():
+			msg, ok := update.(*onionmsg.ReceivedMessage)
+			if !ok {
+				continue
+			}
+
+			switch {
+			case len(msg.Payload.InvoiceRequest) > 0:
+				// Replying may require connecting to the
+				// introduction node of the reply path, so we
+				// don't want to block other messages on it.
+				m.wg.Add(1)
+				go func() {
+					defer m.wg.Done()
+
+					m.handleInvoiceRequest(msg.Payload)
+				}()
+
+			case len(msg.Payload.Invoice) > 0 ||
+				len(msg.Payload.InvoiceError) > 0:
+
+				m.deliverReply(msg)
+			}
+
+		case <-client.Quit():
+			return
+
+		case <-m.quit:
+			return
+		}
+	}
+}
+
+// CreateOffer completes the passed offer with our node's details and the
+// metadata that allows us to recognize it, returning its encoding. Offers
+// aren't stored anywhere, so they can't be revoked other than by their
+// expiry.
+func (m *Manager) CreateOffer(offer *Offer) (string, error) {
+	if offer.Currency != "" {
+		return "", ErrCurrencyUnsupported
+	}
+
+	if m.cfg.ChainHash != *chaincfg.MainNetParams.GenesisHash {
+		offer.Chains = []chainhash.Hash{m.cfg.ChainHash}
+	}
+	offer.IssuerID = m.cfg.NodePubKey
+
+	if err := offer.Validate(); err != nil {
+		return "", err
+	}
+
+	if err := setOfferMetadata(m.cfg.MetadataKey, offer); err != nil {
+		return "", err
+	}
+
+	return offer.Encode()
+}
+
+// handleInvoiceRequest answers an invoice request for one of our offers with
+// either an invoice or an invoice error.
+func (m *Manager) handleInvoiceRequest(payload *onionmsg.Payload) {
+	if payload.ReplyPath == nil {
+		log.Debugf("Ignoring invoice request without reply path")
+		return
+	}
+
+	reply := &onionmsg.Payload{}
+	invoice, err := m.createInvoice(payload.InvoiceRequest)
+	switch e := err.(type) {
+	case nil:
+		reply.Invoice, err = invoice.Serialize()
+		if err != nil {
+			log.Errorf("Unable to serialize invoice: %v", err)
+			return
+		}
+
+	case *InvoiceError:
+		log.Debugf("Rejecting invoice request: %v", e)
+		reply.InvoiceError = e.Serialize()
+
+	default:
+		log.Debugf("Rejecting invoice request: %v", err)
+		reply.InvoiceError = NewInvoiceError(
+			"%v", err,
+		).Serialize()
+	}
+
+	err = m.cfg.Messenger.SendMessage(payload.ReplyPath, reply)
+	if err != nil {
+		log.Debugf("Unable to reply to invoice request: %v", err)
+	}
+}
+
+// createInvoice validates an invoice request for one of our offers and adds an
+// invoice for it to the invoice registry.
+func (m *Manager) createInvoice(rawInvReq []byte) (*Invoice, error) {
+	invReq, err := DeserializeInvoiceRequest(rawInvReq)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := invReq.Verify(); err != nil {
+		return nil, err
+	}
+
+	offer := invReq.Offer
+	if err := verifyOfferMetadata(m.cfg.MetadataKey, offer); err != nil {
+		return nil, err
+	}
+
+	now := m.cfg.Clock.Now()
+	if offer.IsExpired(now) {
+		return nil, ErrOfferExpired
+	}
+
+	chain := invReq.ChainHash(*chaincfg.MainNetParams.GenesisHash)
+	if chain != m.cfg.ChainHash || !offer.SupportsChain(chain) {
+		return nil, ErrUnsupportedChain
+	}
+
+	amount, err := requestAmount(
+		offer, invReq.Amount, invReq.Quantity,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var preimage lntypes.Preimage
+	if _, err := rand.Read(preimage[:]); err != nil {
+		return nil, err
+	}
+	paymentHash := preimage.Hash()
+
+	invoice := &Invoice{
+		InvoiceRequest: invReq,
+		CreatedAt:      time.Unix(now.Unix(), 0),
+		PaymentHash:    paymentHash,
+		Amount:         amount,
+		NodeID:         m.cfg.NodePubKey,
+	}
+	if err := invoice.Sign(m.cfg.SignInvoice); err != nil {
+		return nil, err
+	}
+
+	payReq, err := invoice.Encode()
+	if err != nil {
+		return nil, err
+	}
+
+	payAddr, err := invoice.PaymentAddr()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = m.cfg.AddInvoice(&channeldb.Invoice{
+		Memo:           []byte(offer.Description),
+		PaymentRequest: []byte(payReq),
+		CreationDate:   invoice.CreatedAt,
+		Terms: channeldb.ContractTerm{
+			FinalCltvDelta:  FinalCltvDelta,
+			Expiry:          DefaultRelativeExpiry,
+			PaymentPreimage: &preimage,
+			Value:           amount,
+			PaymentAddr:     payAddr,
+			Features:        m.cfg.InvoiceFeatures(),
+		},
+	}, paymentHash)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debugf("Created invoice %v for offer %x", paymentHash,
+		offer.Metadata)
+
+	return invoice, nil
+}
+
+// requestAmount returns the amount that is to be paid for the given quantity
+// of the offer, checking it against the amount the payer offered to pay.
+func requestAmount(offer *Offer, payerAmt lnwire.MilliSatoshi,
+	quantity *uint64) (lnwire.MilliSatoshi, error) {
+
+	var field uint64
+	invalid := func(format string, args ...interface{}) *InvoiceError {
+		invErr := NewInvoiceError(format, args...)
+		invErr.ErroneousField = &field
+
+		return invErr
+	}
+
+	numItems := uint64(1)
+	switch {
+	case offer.QuantityMax == nil && quantity != nil:
+		field = InvReqQuantityType
+		return 0, invalid("offer doesn't support quantities")
+
+	case offer.QuantityMax != nil && quantity == nil:
+		field = InvReqQuantityType
+		return 0, invalid("quantity required")
+
+	case quantity != nil:
+		numItems = *quantity
+		if numItems == 0 || (*offer.QuantityMax != 0 &&
+			numItems > *offer.QuantityMax) {
+
+			field = InvReqQuantityType
+			return 0, invalid("invalid quantity %d", numItems)
+		}
+	}
+
+	if offer.Amount == 0 {
+		if payerAmt == 0 {
+			field = InvReqAmountType
+			return 0, invalid("amount required")
+		}
+
+		return payerAmt, nil
+	}
+
+	// Make sure the total amount doesn't overflow.
+	if numItems > ^uint64(0)/offer.Amount {
+		field = InvReqQuantityType
+		return 0, invalid("quantity too large")
+	}
+
+	expected := lnwire.MilliSatoshi(offer.Amount * numItems)
+	if payerAmt == 0 {
+		return expected, nil
+	}
+
+	if payerAmt < expected {
+		field = InvReqAmountType
+		return 0, invalid("amount %v below offer amount %v",
+			payerAmt, expected)
+	}
+
+	return payerAmt, nil
+}
+
+// deliverReply passes a reply to one of our invoice requests on to the
+// request waiting for it.
+func (m *Manager) deliverReply(msg *onionmsg.ReceivedMessage) {
+	m.pendingMtx.Lock()
+	replyChan, ok := m.pending[string(msg.PathID)]
+	delete(m.pending, string(msg.PathID))
+	m.pendingMtx.Unlock()
+
+	if !ok {
+		log.Debugf("Ignoring reply over unknown path %x", msg.PathID)
+		return
+	}
+
+	// The channel is buffered and only ever receives a single reply.
+	replyChan <- msg.Payload
+}
+
+// FetchInvoice requests an invoice for an offer from its issuer and waits for
+// the reply. The returned invoice is fully validated against the offer and
+// our request.
+func (m *Manager) FetchInvoice(req *FetchInvoiceRequest) (*Invoice, error) {
+	offer := req.Offer
+
+	switch {
+	case offer.Currency != "":
+		return nil, ErrCurrencyUnsupported
+
+	case !offer.SupportsChain(m.cfg.ChainHash):
+		return nil, ErrUnsupportedChain
+
+	case offer.IsExpired(m.cfg.Clock.Now()):
+		return nil, ErrOfferExpired
+	}
+
+	var quantity *uint64
+	if req.Quantity != 0 {
+		quantity = &req.Quantity
+	}
+
+	// Check the request the same way the issuer will.
+	if _, err := requestAmount(offer, req.Amount, quantity); err != nil {
+		return nil, err
+	}
+
+	// We sign each request with a fresh key, so that our requests can't
+	// be linked to each other or our node.
+	payerKey, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := make([]byte, payerMetadataSize)
+	if _, err := rand.Read(metadata); err != nil {
+		return nil, err
+	}
+
+	invReq := &InvoiceRequest{
+		Offer:     offer,
+		Metadata:  metadata,
+		Amount:    req.Amount,
+		Quantity:  quantity,
+		PayerID:   payerKey.PubKey(),
+		PayerNote: req.PayerNote,
+	}
+	if m.cfg.ChainHash != *chaincfg.MainNetParams.GenesisHash {
+		chain := m.cfg.ChainHash
+		invReq.Chain = &chain
+	}
+
+	if err := invReq.Sign(PrivKeySigner(payerKey)); err != nil {
+		return nil, err
+	}
+
+	rawInvReq, err := invReq.Serialize()
+	if err != nil {
+		return nil, err
+	}
+
+	pathID := make([]byte, pathIDSize)
+	if _, err := rand.Read(pathID); err != nil {
+		return nil, err
+	}
+
+	replyPath, err := m.cfg.Messenger.ReplyPath(pathID)
+	if err != nil {
+		return nil, err
+	}
+
+	replyChan := make(chan *onionmsg.Payload, 1)
+	m.pendingMtx.Lock()
+	m.pending[string(pathID)] = replyChan
+	m.pendingMtx.Unlock()
+
+	defer func() {
+		m.pendingMtx.Lock()
+		delete(m.pending, string(pathID))
+		m.pendingMtx.Unlock()
+	}()
+
+	err = m.sendInvoiceRequest(offer, &onionmsg.Payload{
+		ReplyPath:      replyPath,
+		InvoiceRequest: rawInvReq,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var reply *onionmsg.Payload
+	select {
+	case reply = <-replyChan:
+
+	case <-m.cfg.Clock.TickAfter(req.Timeout):
+		return nil, ErrFetchTimeout
+
+	case <-m.quit:
+		return nil, ErrManagerShuttingDown
+	}
+
+	if len(reply.Invoice) == 0 {
+		invErr, err := DeserializeInvoiceError(reply.InvoiceError)
+		if err != nil {
+			return nil, fmt.Errorf("invalid invoice error: %v", err)
+		}
+
+		return nil, invErr
+	}
+
+	invoice, err := DeserializeInvoice(reply.Invoice)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.validateInvoice(invReq, invoice); err != nil {
+		return nil, err
+	}
+
+	return invoice, nil
+}
+
+// sendInvoiceRequest sends an invoice request to the issuer of the offer,
+// trying each of its paths in turn.
+func (m *Manager) sendInvoiceRequest(offer *Offer,
+	payload *onionmsg.Payload) error {
+
+	paths := offer.Paths
+	if len(paths) == 0 {
+		path, err := onionmsg.DirectPath(offer.IssuerID)
+		if err != nil {
+			return err
+		}
+		paths = []*blindedpath.BlindedPath{path}
+	}
+
+	var err error
+	for _, path := range paths {
+		err = m.cfg.Messenger.SendMessage(path, payload)
+		if err == nil {
+			return nil
+		}
+
+		log.Debugf("Unable to send invoice request over path with "+
+			"introduction node %x: %v",
+			path.IntroductionPoint.SerializeCompressed(), err)
+	}
+
+	return fmt.Errorf("unable to send invoice request: %v", err)
+}
+
+// validateInvoice checks that an invoice we received matches the request we
+// sent for it.
+func (m *Manager) validateInvoice(invReq *InvoiceRequest,
+	invoice *Invoice) error {
+
+	if err := invoice.Verify(); err != nil {
+		return err
+	}
+
+	// The invoice must mirror our request exactly.
+	invReqRecords, err := invReq.records()
+	if err != nil {
+		return err
+	}
+	invoiceRecords, err := invoice.records()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(
+		invReqRecords.inRange(0, invReqTypeEnd).encode(),
+		invoiceRecords.inRange(0, invReqTypeEnd).encode(),
+	) {
+
+		return errors.New("invoice doesn't match request")
+	}
+
+	// The invoice must be signed by the issuer of the offer, which is
+	// either given by its ID or as the recipient of its paths.
+	offer := invReq.Offer
+	if len(offer.Paths) == 0 {
+		if !invoice.NodeID.IsEqual(offer.IssuerID) {
+			return errors.New("invoice not signed by issuer")
+		}
+	} else {
+		var signedByIssuer bool
+		for _, path := range offer.Paths {
+			recipient := path.FinalHop().BlindedNodePub
+			if invoice.NodeID.IsEqual(recipient) {
+				signedByIssuer = true
+				break
+			}
+		}
+		if !signedByIssuer {
+			return errors.New("invoice not signed by issuer")
+		}
+	}
+
+	expected, err := requestAmount(offer, invReq.Amount, invReq.Quantity)
+	if err != nil {
+		return err
+	}
+	if invoice.Amount != expected {
+		return fmt.Errorf("invoice amount %v doesn't match requested "+
+			"amount %v", invoice.Amount, expected)
+	}
+
+	if !m.cfg.Clock.Now().Before(invoice.ExpiresAt()) {
+		return ErrInvoiceExpired
+	}
+
+	return nil
+}
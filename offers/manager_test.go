@@ -0,0 +1,156 @@
+package offers
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/brsuite/brond/btcec"
+	"github.com/brsuite/brond/chaincfg"
+	"github.com/brsuite/broln/channeldb"
+	"github.com/brsuite/broln/clock"
+	"github.com/brsuite/broln/keychain"
+	"github.com/brsuite/broln/lntypes"
+	"github.com/brsuite/broln/lnwire"
+	"github.com/brsuite/broln/onionmsg"
+	"github.com/stretchr/testify/require"
+)
+
+// testNode is an offers manager along with the onion messenger it uses.
+type testNode struct {
+	manager   *Manager
+	messenger *onionmsg.Messenger
+	pubKey    [33]byte
+
+	// peer is the node the onion messages we send are delivered to.
+	peer *testNode
+
+	invoicesMtx sync.Mutex
+	invoices    map[lntypes.Hash]*channeldb.Invoice
+}
+
+// newTestNode creates and starts an offers manager with a fresh node key.
+func newTestNode(t *testing.T) *testNode {
+	t.Helper()
+
+	priv, err := btcec.NewPrivateKey(btcec.S256())
+	require.NoError(t, err)
+
+	node := &testNode{
+		invoices: make(map[lntypes.Hash]*channeldb.Invoice),
+	}
+	copy(node.pubKey[:], priv.PubKey().SerializeCompressed())
+
+	node.messenger = onionmsg.New(&onionmsg.Config{
+		NodeKey: &keychain.PrivKeyECDH{PrivKey: priv},
+		SendToPeer: func(_ [33]byte, msg *lnwire.OnionMessage) error {
+			node.peer.messenger.HandleMessage(node.pubKey, msg)
+			return nil
+		},
+	})
+
+	node.manager = NewManager(&Config{
+		ChainHash:   *chaincfg.RegressionNetParams.GenesisHash,
+		NodePubKey:  priv.PubKey(),
+		MetadataKey: [32]byte{node.pubKey[1]},
+		SignInvoice: PrivKeySigner(priv),
+		Messenger:   node.messenger,
+		AddInvoice: func(invoice *channeldb.Invoice,
+			hash lntypes.Hash) (uint64, error) {
+
+			node.invoicesMtx.Lock()
+			defer node.invoicesMtx.Unlock()
+
+			node.invoices[hash] = invoice
+			return uint64(len(node.invoices)), nil
+		},
+		InvoiceFeatures: func() *lnwire.FeatureVector {
+			return lnwire.EmptyFeatureVector()
+		},
+		Clock: clock.NewDefaultClock(),
+	})
+
+	require.NoError(t, node.messenger.Start())
+	require.NoError(t, node.manager.Start())
+	t.Cleanup(func() {
+		require.NoError(t, node.manager.Stop())
+		require.NoError(t, node.messenger.Stop())
+	})
+
+	return node
+}
+
+// connectNodes delivers the onion messages each node sends to the other one.
+func connectNodes(a, b *testNode) {
+	a.peer = b
+	b.peer = a
+}
+
+// TestFetchInvoice asserts that a payer is able to fetch an invoice for an
+// offer, and that the issuer adds a matching invoice to its registry.
+func TestFetchInvoice(t *testing.T) {
+	t.Parallel()
+
+	alice := newTestNode(t)
+	bob := newTestNode(t)
+	connectNodes(alice, bob)
+
+	quantityMax := uint64(10)
+	encoded, err := alice.manager.CreateOffer(&Offer{
+		Amount:      1000,
+		Description: "sticker",
+		QuantityMax: &quantityMax,
+	})
+	require.NoError(t, err)
+
+	offer, err := DecodeOffer(encoded)
+	require.NoError(t, err)
+
+	invoice, err := bob.manager.FetchInvoice(&FetchInvoiceRequest{
+		Offer:     offer,
+		Quantity:  3,
+		PayerNote: "for my laptop",
+		Timeout:   5 * time.Second,
+	})
+	require.NoError(t, err)
+	require.Equal(t, lnwire.MilliSatoshi(3000), invoice.Amount)
+	require.Equal(t, "for my laptop", invoice.InvoiceRequest.PayerNote)
+
+	alice.invoicesMtx.Lock()
+	dbInvoice, ok := alice.invoices[invoice.PaymentHash]
+	alice.invoicesMtx.Unlock()
+	require.True(t, ok)
+	require.Equal(t, invoice.Amount, dbInvoice.Terms.Value)
+	require.Equal(t, "sticker", string(dbInvoice.Memo))
+
+	payAddr, err := invoice.PaymentAddr()
+	require.NoError(t, err)
+	require.Equal(t, payAddr, dbInvoice.Terms.PaymentAddr)
+
+	// Requests the payer already knows to be invalid aren't sent.
+	_, err = bob.manager.FetchInvoice(&FetchInvoiceRequest{
+		Offer:   offer,
+		Timeout: 5 * time.Second,
+	})
+	require.IsType(t, &InvoiceError{}, err)
+
+	// Requests for offers the issuer doesn't know are answered with an
+	// invoice error.
+	encoded, err = bob.manager.CreateOffer(&Offer{
+		Amount:      1000,
+		Description: "sticker",
+	})
+	require.NoError(t, err)
+
+	forged, err := DecodeOffer(encoded)
+	require.NoError(t, err)
+	forged.raw[OfferIssuerIDType] = alice.pubKey[:]
+	forged.IssuerID = alice.manager.cfg.NodePubKey
+
+	_, err = bob.manager.FetchInvoice(&FetchInvoiceRequest{
+		Offer:   forged,
+		Timeout: 5 * time.Second,
+	})
+	require.IsType(t, &InvoiceError{}, err)
+	require.Contains(t, err.Error(), ErrUnknownOffer.Error())
+}
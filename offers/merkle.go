@@ -0,0 +1,173 @@
+package offers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+
+	"github.com/brsuite/brond/btcec"
+	"github.com/brsuite/brond/chaincfg/chainhash"
+	"github.com/brsuite/broln/input"
+	"github.com/brsuite/broln/tlv"
+)
+
+const (
+	// signatureTypeStart is the first type of the range of records that
+	// hold signatures, which aren't covered by the merkle root.
+	signatureTypeStart = 240
+
+	// signatureTypeEnd is the last type of the signature range.
+	signatureTypeEnd = 1000
+
+	// SignatureType is the type of the record holding the signature of an
+	// invoice request or invoice.
+	SignatureType = 240
+)
+
+var (
+	// ErrInvalidSignature is returned when the signature of an invoice
+	// request or invoice doesn't match its content.
+	ErrInvalidSignature = errors.New("invalid bolt 12 signature")
+)
+
+// taggedHash returns the BIP-340 style hash of the message under the given
+// tag, SHA256(SHA256(tag) || SHA256(tag) || msg).
+func taggedHash(tag []byte, msg ...[]byte) chainhash.Hash {
+	tagHash := sha256.Sum256(tag)
+
+	h := sha256.New()
+	h.Write(tagHash[:])
+	h.Write(tagHash[:])
+	for _, m := range msg {
+		h.Write(m)
+	}
+
+	var hash chainhash.Hash
+	copy(hash[:], h.Sum(nil))
+
+	return hash
+}
+
+// branchHash combines two nodes of the merkle tree, hashing the lesser one
+// first.
+func branchHash(a, b chainhash.Hash) chainhash.Hash {
+	if bytes.Compare(a[:], b[:]) > 0 {
+		a, b = b, a
+	}
+
+	return taggedHash([]byte("LnBranch"), a[:], b[:])
+}
+
+// merkleRoot computes the merkle root of the passed records, as defined in
+// BOLT 12. Records in the signature range aren't part of the tree.
+func merkleRoot(records tlvRecords) chainhash.Hash {
+	types := records.types()
+
+	var (
+		leaves   []chainhash.Hash
+		nonceTag []byte
+		buf      [8]byte
+	)
+	for _, typ := range types {
+		if typ >= signatureTypeStart && typ <= signatureTypeEnd {
+			continue
+		}
+
+		var record bytes.Buffer
+		_ = writeRecord(&record, typ, records[typ])
+
+		// Each leaf is paired with a nonce leaf derived from the first
+		// record, which prevents revealing the content of a record
+		// when only proving the presence of another one.
+		if nonceTag == nil {
+			nonceTag = append([]byte("LnNonce"), record.Bytes()...)
+		}
+
+		var encodedType bytes.Buffer
+		_ = tlv.WriteVarInt(&encodedType, typ, &buf)
+
+		leaf := taggedHash([]byte("LnLeaf"), record.Bytes())
+		nonce := taggedHash(nonceTag, encodedType.Bytes())
+		leaves = append(leaves, branchHash(leaf, nonce))
+	}
+
+	if len(leaves) == 0 {
+		return chainhash.Hash{}
+	}
+
+	// Combine the leaves pairwise until only the root is left. A node
+	// without a sibling is carried up to the next level, so that the
+	// tree is deepest at its lowest order leaves.
+	for len(leaves) > 1 {
+		var next []chainhash.Hash
+		for i := 0; i < len(leaves); i += 2 {
+			if i+1 == len(leaves) {
+				next = append(next, leaves[i])
+				continue
+			}
+
+			next = append(next, branchHash(leaves[i], leaves[i+1]))
+		}
+		leaves = next
+	}
+
+	return leaves[0]
+}
+
+// signatureDigest returns the digest that is signed for the message of the
+// given name, which is either "invoice_request" or "invoice".
+func signatureDigest(messageName string, records tlvRecords) chainhash.Hash {
+	root := merkleRoot(records)
+	tag := []byte("lightning" + messageName + "signature")
+
+	return taggedHash(tag, root[:])
+}
+
+// signRecords signs the records of the named message with the passed signer
+// and adds the signature record.
+func signRecords(messageName string, records tlvRecords,
+	sign SignFunc) error {
+
+	digest := signatureDigest(messageName, records)
+	sig, err := sign(digest)
+	if err != nil {
+		return err
+	}
+
+	records[SignatureType] = sig.Serialize()
+
+	return nil
+}
+
+// verifyRecords checks that the signature record of the named message is a
+// valid signature of the given key.
+func verifyRecords(messageName string, records tlvRecords,
+	pubKey *btcec.PublicKey) error {
+
+	sigBytes, ok := records[SignatureType]
+	if !ok {
+		return ErrInvalidSignature
+	}
+
+	sig, err := input.ParseSchnorrSignature(sigBytes)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	digest := signatureDigest(messageName, records)
+	if !sig.Verify(digest[:], pubKey) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+// SignFunc creates a BIP-340 signature of the passed digest.
+type SignFunc func(digest chainhash.Hash) (*input.SchnorrSignature, error)
+
+// PrivKeySigner returns a SignFunc that signs with the passed private key.
+func PrivKeySigner(privKey *btcec.PrivateKey) SignFunc {
+	return func(digest chainhash.Hash) (*input.SchnorrSignature, error) {
+		return input.SchnorrSign(privKey, digest[:])
+	}
+}
@@ -0,0 +1,80 @@
+package offers
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+)
+
+const (
+	// metadataNonceSize is the size of the random nonce at the start of
+	// the metadata of our offers.
+	metadataNonceSize = 16
+
+	// metadataSize is the total size of the metadata of our offers.
+	metadataSize = metadataNonceSize + sha256.Size
+)
+
+var (
+	// ErrUnknownOffer is returned when receiving an invoice request for an
+	// offer we didn't create.
+	ErrUnknownOffer = errors.New("unknown offer")
+)
+
+// offerMAC computes the authentication code of the offer's records apart from
+// its metadata under the given key and nonce.
+func offerMAC(key [32]byte, nonce []byte, offer *Offer) ([]byte, error) {
+	records, err := offer.records()
+	if err != nil {
+		return nil, err
+	}
+	delete(records, OfferMetadataType)
+
+	mac := hmac.New(sha256.New, key[:])
+	mac.Write(nonce)
+	mac.Write(records.encode())
+
+	return mac.Sum(nil), nil
+}
+
+// setOfferMetadata sets the metadata of the passed offer to a random nonce
+// followed by an authentication code of the offer under the given key. This
+// allows us to recognize our offers when receiving invoice requests for them,
+// without having to store them.
+func setOfferMetadata(key [32]byte, offer *Offer) error {
+	nonce := make([]byte, metadataNonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	offer.Metadata = nil
+	mac, err := offerMAC(key, nonce, offer)
+	if err != nil {
+		return err
+	}
+
+	offer.Metadata = append(nonce, mac...)
+
+	return nil
+}
+
+// verifyOfferMetadata checks that the passed offer was created by us with the
+// given key and wasn't modified since.
+func verifyOfferMetadata(key [32]byte, offer *Offer) error {
+	if len(offer.Metadata) != metadataSize {
+		return ErrUnknownOffer
+	}
+
+	nonce := offer.Metadata[:metadataNonceSize]
+	mac, err := offerMAC(key, nonce, offer)
+	if err != nil {
+		return err
+	}
+
+	if !hmac.Equal(mac, offer.Metadata[metadataNonceSize:]) {
+		return ErrUnknownOffer
+	}
+
+	return nil
+}
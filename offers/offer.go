@@ -0,0 +1,358 @@
+package offers
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"github.com/brsuite/brond/btcec"
+	"github.com/brsuite/brond/chaincfg"
+	"github.com/brsuite/brond/chaincfg/chainhash"
+	"github.com/brsuite/broln/lnwire"
+	"github.com/brsuite/broln/routing/blindedpath"
+)
+
+const (
+	// OfferChainsType is the type of the record listing the chains an
+	// offer can be paid on.
+	OfferChainsType = 2
+
+	// OfferMetadataType is the type of the record holding data the issuer
+	// of an offer can use to recognize it.
+	OfferMetadataType = 4
+
+	// OfferCurrencyType is the type of the record holding the ISO 4217
+	// currency code the offer amount is denominated in.
+	OfferCurrencyType = 6
+
+	// OfferAmountType is the type of the record holding the amount of the
+	// offer.
+	OfferAmountType = 8
+
+	// OfferDescriptionType is the type of the record holding the
+	// description of what is offered.
+	OfferDescriptionType = 10
+
+	// OfferFeaturesType is the type of the record holding the features of
+	// the offer.
+	OfferFeaturesType = 12
+
+	// OfferAbsoluteExpiryType is the type of the record holding the unix
+	// time after which the offer expires.
+	OfferAbsoluteExpiryType = 14
+
+	// OfferPathsType is the type of the record holding the blinded paths
+	// invoice requests can be sent over.
+	OfferPathsType = 16
+
+	// OfferIssuerType is the type of the record holding the name of the
+	// issuer of the offer.
+	OfferIssuerType = 18
+
+	// OfferQuantityMaxType is the type of the record holding the maximum
+	// number of items that can be requested at once.
+	OfferQuantityMaxType = 20
+
+	// OfferIssuerIDType is the type of the record holding the public key
+	// of the issuer of the offer.
+	OfferIssuerIDType = 22
+
+	// offerTypeStart is the first type of the range of offer records.
+	offerTypeStart = 1
+
+	// offerTypeEnd is the last type of the range of offer records.
+	offerTypeEnd = 79
+)
+
+var (
+	// ErrOfferExpired is returned when an offer is used after its expiry.
+	ErrOfferExpired = errors.New("offer expired")
+
+	// ErrUnsupportedChain is returned when an offer can't be paid on our
+	// chain.
+	ErrUnsupportedChain = errors.New("offer not valid for our chain")
+
+	// ErrNoDestination is returned for offers that neither have an issuer
+	// ID nor any paths, which leaves no way to request invoices.
+	ErrNoDestination = errors.New("offer has neither issuer id nor paths")
+
+	// knownOfferTypes are the offer record types we understand.
+	knownOfferTypes = map[uint64]struct{}{
+		OfferChainsType:         {},
+		OfferMetadataType:       {},
+		OfferCurrencyType:       {},
+		OfferAmountType:         {},
+		OfferDescriptionType:    {},
+		OfferFeaturesType:       {},
+		OfferAbsoluteExpiryType: {},
+		OfferPathsType:          {},
+		OfferIssuerType:         {},
+		OfferQuantityMaxType:    {},
+		OfferIssuerIDType:       {},
+	}
+)
+
+// Offer is a BOLT 12 offer, a reusable payment code that payers use to request
+// invoices from the issuer.
+type Offer struct {
+	// Chains are the chains the offer can be paid on. If empty, the offer
+	// can only be paid on main net.
+	Chains []chainhash.Hash
+
+	// Metadata is opaque data the issuer uses to recognize the offer.
+	Metadata []byte
+
+	// Currency is the ISO 4217 code of the currency the amount is
+	// denominated in. If empty, the amount is in millisatoshis.
+	Currency string
+
+	// Amount is the expected amount per item. If zero, the payer chooses
+	// the amount.
+	Amount uint64
+
+	// Description describes what is offered.
+	Description string
+
+	// Features are the features of the offer.
+	Features *lnwire.RawFeatureVector
+
+	// AbsoluteExpiry is the time after which the offer expires. It is the
+	// zero time if the offer never expires.
+	AbsoluteExpiry time.Time
+
+	// Paths are blinded paths to the issuer that invoice requests can be
+	// sent over.
+	Paths []*blindedpath.BlindedPath
+
+	// Issuer is a human readable name of the issuer.
+	Issuer string
+
+	// QuantityMax is the maximum number of items that can be requested at
+	// once, zero meaning no limit. If nil, the offer doesn't support
+	// requesting a quantity.
+	QuantityMax *uint64
+
+	// IssuerID is the public key invoice requests are sent to if the
+	// offer doesn't have any paths.
+	IssuerID *btcec.PublicKey
+
+	// ExtraRecords are offer records of unknown odd types.
+	ExtraRecords map[uint64][]byte
+
+	// raw are the records the offer was decoded from. Invoice requests
+	// and invoices must mirror the offer exactly, so these are used
+	// instead of the fields when encoding a decoded offer again.
+	raw tlvRecords
+}
+
+// records returns the TLV records of the offer.
+func (o *Offer) records() (tlvRecords, error) {
+	records := make(tlvRecords)
+	if o.raw != nil {
+		for typ, value := range o.raw {
+			records[typ] = value
+		}
+
+		return records, nil
+	}
+
+	for typ, value := range o.ExtraRecords {
+		records[typ] = value
+	}
+
+	if len(o.Chains) > 0 {
+		records[OfferChainsType] = encodeChains(o.Chains)
+	}
+	if len(o.Metadata) > 0 {
+		records[OfferMetadataType] = o.Metadata
+	}
+	if o.Currency != "" {
+		records[OfferCurrencyType] = []byte(o.Currency)
+	}
+	if o.Amount != 0 {
+		records[OfferAmountType] = encodeTU64(o.Amount)
+	}
+	if o.Description != "" {
+		records[OfferDescriptionType] = []byte(o.Description)
+	}
+	if o.Features != nil && o.Features.SerializeSize() > 0 {
+		records[OfferFeaturesType] = encodeFeatures(o.Features)
+	}
+	if !o.AbsoluteExpiry.IsZero() {
+		records[OfferAbsoluteExpiryType] = encodeTU64(
+			uint64(o.AbsoluteExpiry.Unix()),
+		)
+	}
+	if len(o.Paths) > 0 {
+		paths, err := encodePaths(o.Paths)
+		if err != nil {
+			return nil, err
+		}
+		records[OfferPathsType] = paths
+	}
+	if o.Issuer != "" {
+		records[OfferIssuerType] = []byte(o.Issuer)
+	}
+	if o.QuantityMax != nil {
+		records[OfferQuantityMaxType] = encodeTU64(*o.QuantityMax)
+	}
+	if o.IssuerID != nil {
+		records[OfferIssuerIDType] = o.IssuerID.SerializeCompressed()
+	}
+
+	return records, nil
+}
+
+// Encode returns the string representation of the offer.
+func (o *Offer) Encode() (string, error) {
+	if err := o.Validate(); err != nil {
+		return "", err
+	}
+
+	records, err := o.records()
+	if err != nil {
+		return "", err
+	}
+
+	return encodeBech32(OfferHRP, records.encode())
+}
+
+// Validate checks that the offer is well formed.
+func (o *Offer) Validate() error {
+	switch {
+	case o.Currency != "" && o.Amount == 0:
+		return errors.New("offer currency without amount")
+
+	case o.Amount != 0 && o.Description == "":
+		return errors.New("offer amount without description")
+
+	case o.IssuerID == nil && len(o.Paths) == 0:
+		return ErrNoDestination
+	}
+
+	for _, s := range []string{o.Currency, o.Description, o.Issuer} {
+		if !utf8.ValidString(s) {
+			return errors.New("offer contains invalid utf-8 string")
+		}
+	}
+
+	return nil
+}
+
+// IsExpired returns true if the offer has expired at the given time.
+func (o *Offer) IsExpired(now time.Time) bool {
+	return !o.AbsoluteExpiry.IsZero() && now.After(o.AbsoluteExpiry)
+}
+
+// SupportsChain returns true if the offer can be paid on the given chain.
+func (o *Offer) SupportsChain(chain chainhash.Hash) bool {
+	if len(o.Chains) == 0 {
+		return chain == *chaincfg.MainNetParams.GenesisHash
+	}
+
+	for _, c := range o.Chains {
+		if c == chain {
+			return true
+		}
+	}
+
+	return false
+}
+
+// DecodeOffer parses the string representation of an offer.
+func DecodeOffer(s string) (*Offer, error) {
+	b, err := decodeBech32(OfferHRP, s)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := decodeRecords(b)
+	if err != nil {
+		return nil, err
+	}
+
+	for typ := range records {
+		if typ < offerTypeStart || typ > offerTypeEnd {
+			return nil, fmt.Errorf("offer contains type %d outside "+
+				"of offer range", typ)
+		}
+	}
+
+	offer, err := offerFromRecords(records)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := offer.Validate(); err != nil {
+		return nil, err
+	}
+
+	return offer, nil
+}
+
+// offerFromRecords parses the offer records of a TLV stream, which may also
+// be the offer part of an invoice request or invoice.
+func offerFromRecords(allRecords tlvRecords) (*Offer, error) {
+	records := allRecords.inRange(offerTypeStart, offerTypeEnd)
+	err := checkUnknownTypes(
+		records, offerTypeStart, offerTypeEnd, knownOfferTypes,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	o := &Offer{
+		ExtraRecords: make(map[uint64][]byte),
+		raw:          records,
+	}
+	for typ, value := range records {
+		switch typ {
+		case OfferChainsType:
+			o.Chains, err = decodeChains(value)
+
+		case OfferMetadataType:
+			o.Metadata = value
+
+		case OfferCurrencyType:
+			o.Currency = string(value)
+
+		case OfferAmountType:
+			o.Amount, err = decodeTU64(value)
+
+		case OfferDescriptionType:
+			o.Description = string(value)
+
+		case OfferFeaturesType:
+			o.Features, err = decodeFeatures(value)
+
+		case OfferAbsoluteExpiryType:
+			var expiry uint64
+			expiry, err = decodeTU64(value)
+			o.AbsoluteExpiry = time.Unix(int64(expiry), 0)
+
+		case OfferPathsType:
+			o.Paths, err = decodePaths(value)
+
+		case OfferIssuerType:
+			o.Issuer = string(value)
+
+		case OfferQuantityMaxType:
+			var quantityMax uint64
+			quantityMax, err = decodeTU64(value)
+			o.QuantityMax = &quantityMax
+
+		case OfferIssuerIDType:
+			o.IssuerID, err = decodePubKey(value)
+
+		default:
+			o.ExtraRecords[typ] = value
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid offer record %d: %v",
+				typ, err)
+		}
+	}
+
+	return o, nil
+}
@@ -0,0 +1,218 @@
+package offers
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brsuite/brond/btcec"
+	"github.com/brsuite/brond/chaincfg"
+	"github.com/brsuite/brond/chaincfg/chainhash"
+	"github.com/brsuite/broln/lnwire"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestOffer returns an offer with all fields set.
+func newTestOffer(t *testing.T) *Offer {
+	t.Helper()
+
+	issuer, err := btcec.NewPrivateKey(btcec.S256())
+	require.NoError(t, err)
+
+	quantityMax := uint64(5)
+	features := lnwire.NewRawFeatureVector(lnwire.MPPOptional)
+
+	return &Offer{
+		Chains: []chainhash.Hash{
+			*chaincfg.TestNet3Params.GenesisHash,
+		},
+		Metadata:       []byte{1, 2, 3},
+		Amount:         100_000,
+		Description:    "coffee",
+		Features:       features,
+		AbsoluteExpiry: time.Unix(1_900_000_000, 0),
+		Issuer:         "broln",
+		QuantityMax:    &quantityMax,
+		IssuerID:       issuer.PubKey(),
+		ExtraRecords: map[uint64][]byte{
+			77: {0xff},
+		},
+	}
+}
+
+// TestOfferEncoding asserts that offers survive a round trip through their
+// string representation.
+func TestOfferEncoding(t *testing.T) {
+	t.Parallel()
+
+	offer := newTestOffer(t)
+	encoded, err := offer.Encode()
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(encoded, OfferHRP+"1"))
+
+	decoded, err := DecodeOffer(encoded)
+	require.NoError(t, err)
+	require.Equal(t, offer.Chains, decoded.Chains)
+	require.Equal(t, offer.Metadata, decoded.Metadata)
+	require.Equal(t, offer.Amount, decoded.Amount)
+	require.Equal(t, offer.Description, decoded.Description)
+	require.Equal(t, offer.Features, decoded.Features)
+	require.Equal(t, offer.AbsoluteExpiry, decoded.AbsoluteExpiry)
+	require.Equal(t, offer.Issuer, decoded.Issuer)
+	require.Equal(t, offer.QuantityMax, decoded.QuantityMax)
+	require.True(t, offer.IssuerID.IsEqual(decoded.IssuerID))
+	require.Equal(t, offer.ExtraRecords, decoded.ExtraRecords)
+
+	// Re-encoding a decoded offer must result in the same string.
+	reencoded, err := decoded.Encode()
+	require.NoError(t, err)
+	require.Equal(t, encoded, reencoded)
+
+	// Offers may be split over several parts, and be all upper case.
+	split := strings.ToUpper(encoded[:20] + "+\n  " + encoded[20:])
+	decoded, err = DecodeOffer(split)
+	require.NoError(t, err)
+	require.Equal(t, offer.Description, decoded.Description)
+
+	// Mixed case isn't allowed though.
+	_, err = DecodeOffer(encoded[:20] + strings.ToUpper(encoded[20:]))
+	require.ErrorIs(t, err, ErrInvalidEncoding)
+
+	// Neither are dangling joins.
+	_, err = DecodeOffer(encoded + "+")
+	require.ErrorIs(t, err, ErrInvalidEncoding)
+}
+
+// TestOfferValidation asserts that malformed offers are rejected.
+func TestOfferValidation(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		modify func(*Offer)
+	}{
+		{
+			name: "amount without description",
+			modify: func(o *Offer) {
+				o.Description = ""
+			},
+		},
+		{
+			name: "currency without amount",
+			modify: func(o *Offer) {
+				o.Currency = "USD"
+				o.Amount = 0
+			},
+		},
+		{
+			name: "no destination",
+			modify: func(o *Offer) {
+				o.IssuerID = nil
+			},
+		},
+		{
+			name: "unknown even type",
+			modify: func(o *Offer) {
+				o.ExtraRecords = map[uint64][]byte{78: {}}
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			offer := newTestOffer(t)
+			test.modify(offer)
+
+			// Encode the records directly, so that we can also
+			// create offers that don't pass validation.
+			records, err := offer.records()
+			require.NoError(t, err)
+			encoded, err := encodeBech32(OfferHRP, records.encode())
+			require.NoError(t, err)
+
+			_, err = DecodeOffer(encoded)
+			require.Error(t, err)
+		})
+	}
+}
+
+// TestInvoiceSignature asserts that invoice requests and invoices mirror the
+// records they are based on and that their signatures cover all of them.
+func TestInvoiceSignature(t *testing.T) {
+	t.Parallel()
+
+	payerKey, err := btcec.NewPrivateKey(btcec.S256())
+	require.NoError(t, err)
+	nodeKey, err := btcec.NewPrivateKey(btcec.S256())
+	require.NoError(t, err)
+
+	quantity := uint64(2)
+	invReq := &InvoiceRequest{
+		Offer:     newTestOffer(t),
+		Metadata:  []byte{4, 5, 6},
+		Amount:    200_000,
+		Quantity:  &quantity,
+		PayerID:   payerKey.PubKey(),
+		PayerNote: "thanks",
+	}
+	require.NoError(t, invReq.Sign(PrivKeySigner(payerKey)))
+	require.NoError(t, invReq.Verify())
+
+	rawInvReq, err := invReq.Serialize()
+	require.NoError(t, err)
+
+	decodedInvReq, err := DeserializeInvoiceRequest(rawInvReq)
+	require.NoError(t, err)
+	require.NoError(t, decodedInvReq.Verify())
+	require.Equal(t, invReq.PayerNote, decodedInvReq.PayerNote)
+	require.Equal(t, invReq.Quantity, decodedInvReq.Quantity)
+	require.Equal(t, invReq.Offer.Description,
+		decodedInvReq.Offer.Description)
+
+	// Tampering with any of the offer records invalidates the signature.
+	tampered := *decodedInvReq
+	tampered.raw = decodedInvReq.raw.inRange(0, invReqTypeEnd)
+	tampered.raw[SignatureType] = decodedInvReq.Signature
+	tampered.raw[OfferDescriptionType] = []byte("tea")
+	require.ErrorIs(t, tampered.Verify(), ErrInvalidSignature)
+
+	invoice := &Invoice{
+		InvoiceRequest: decodedInvReq,
+		CreatedAt:      time.Unix(1_700_000_000, 0),
+		RelativeExpiry: time.Hour,
+		PaymentHash:    [32]byte{1},
+		Amount:         200_000,
+		NodeID:         nodeKey.PubKey(),
+	}
+	require.NoError(t, invoice.Sign(PrivKeySigner(nodeKey)))
+
+	encoded, err := invoice.Encode()
+	require.NoError(t, err)
+
+	decoded, err := DecodeInvoice(encoded)
+	require.NoError(t, err)
+	require.NoError(t, decoded.Verify())
+	require.Equal(t, invoice.CreatedAt, decoded.CreatedAt)
+	require.Equal(t, invoice.RelativeExpiry, decoded.RelativeExpiry)
+	require.Equal(t, invoice.PaymentHash, decoded.PaymentHash)
+	require.Equal(t, invoice.Amount, decoded.Amount)
+	require.Equal(t, invoice.ExpiresAt(), decoded.ExpiresAt())
+
+	// The invoice mirrors the request apart from its signature.
+	require.Nil(t, decoded.InvoiceRequest.Signature)
+	require.Equal(t, decodedInvReq.Metadata,
+		decoded.InvoiceRequest.Metadata)
+
+	// Both sides must derive the same payment address.
+	addr, err := invoice.PaymentAddr()
+	require.NoError(t, err)
+	decodedAddr, err := decoded.PaymentAddr()
+	require.NoError(t, err)
+	require.Equal(t, addr, decodedAddr)
+
+	// An invoice signed by another key is rejected.
+	decoded.NodeID = payerKey.PubKey()
+	require.ErrorIs(t, decoded.Verify(), ErrInvalidSignature)
+}
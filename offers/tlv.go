@@ -0,0 +1,167 @@
+package offers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/brsuite/brond/btcec"
+	"github.com/brsuite/broln/tlv"
+)
+
+var (
+	// ErrRecordsOutOfOrder is returned when the records of a TLV stream
+	// aren't sorted by strictly increasing type.
+	ErrRecordsOutOfOrder = errors.New("tlv records out of order")
+)
+
+// tlvRecords is the raw content of a TLV stream, mapping each record type to
+// its value. Offers, invoice requests and invoices mirror each other's records
+// and are signed over their exact encoding, so we keep all records around in
+// this form rather than decoding them into their fields only.
+type tlvRecords map[uint64][]byte
+
+// types returns the record types of the stream in ascending order.
+func (t tlvRecords) types() []uint64 {
+	types := make([]uint64, 0, len(t))
+	for typ := range t {
+		types = append(types, typ)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		return types[i] < types[j]
+	})
+
+	return types
+}
+
+// encode serializes the records in ascending type order.
+func (t tlvRecords) encode() []byte {
+	var b bytes.Buffer
+	for _, typ := range t.types() {
+		// Writing to a bytes.Buffer can't fail.
+		_ = writeRecord(&b, typ, t[typ])
+	}
+
+	return b.Bytes()
+}
+
+// inRange returns the records with a type in the inclusive range [start, end].
+func (t tlvRecords) inRange(start, end uint64) tlvRecords {
+	records := make(tlvRecords)
+	for typ, value := range t {
+		if typ >= start && typ <= end {
+			records[typ] = value
+		}
+	}
+
+	return records
+}
+
+// writeRecord writes a single TLV record to the passed io.Writer.
+func writeRecord(w io.Writer, typ uint64, value []byte) error {
+	var buf [8]byte
+	if err := tlv.WriteVarInt(w, typ, &buf); err != nil {
+		return err
+	}
+	if err := tlv.WriteVarInt(w, uint64(len(value)), &buf); err != nil {
+		return err
+	}
+
+	_, err := w.Write(value)
+	return err
+}
+
+// decodeRecords parses a TLV stream into its raw records. The records must be
+// sorted by strictly increasing type.
+func decodeRecords(b []byte) (tlvRecords, error) {
+	var (
+		r        = bytes.NewReader(b)
+		records  = make(tlvRecords)
+		buf      [8]byte
+		lastType uint64
+	)
+	for r.Len() > 0 {
+		typ, err := tlv.ReadVarInt(r, &buf)
+		if err != nil {
+			return nil, err
+		}
+		if len(records) > 0 && typ <= lastType {
+			return nil, ErrRecordsOutOfOrder
+		}
+		lastType = typ
+
+		length, err := tlv.ReadVarInt(r, &buf)
+		if err != nil {
+			return nil, err
+		}
+		if length > uint64(r.Len()) {
+			return nil, fmt.Errorf("record of type %d exceeds stream",
+				typ)
+		}
+
+		value := make([]byte, length)
+		if _, err := io.ReadFull(r, value); err != nil {
+			return nil, err
+		}
+		records[typ] = value
+	}
+
+	return records, nil
+}
+
+// checkUnknownTypes makes sure that all records in the given range that
+// aren't part of the known types are odd, since we aren't able to process the
+// message otherwise.
+func checkUnknownTypes(records tlvRecords, start, end uint64,
+	known map[uint64]struct{}) error {
+
+	for typ := range records {
+		if typ < start || typ > end {
+			continue
+		}
+		if _, ok := known[typ]; ok {
+			continue
+		}
+		if typ%2 == 0 {
+			return fmt.Errorf("unknown required type %d", typ)
+		}
+	}
+
+	return nil
+}
+
+// encodeTU64 returns the truncated encoding of the passed integer.
+func encodeTU64(v uint64) []byte {
+	var (
+		b   bytes.Buffer
+		buf [8]byte
+	)
+	_ = tlv.ETUint64T(&b, v, &buf)
+
+	return b.Bytes()
+}
+
+// decodeTU64 parses a truncated integer, which must be minimally encoded.
+func decodeTU64(b []byte) (uint64, error) {
+	var (
+		v   uint64
+		buf [8]byte
+	)
+	err := tlv.DTUint64(bytes.NewReader(b), &v, &buf, uint64(len(b)))
+	if err != nil {
+		return 0, err
+	}
+
+	return v, nil
+}
+
+// decodePubKey parses a compressed public key.
+func decodePubKey(b []byte) (*btcec.PublicKey, error) {
+	if len(b) != btcec.PubKeyBytesLenCompressed {
+		return nil, fmt.Errorf("invalid public key length %d", len(b))
+	}
+
+	return btcec.ParsePubKey(b, btcec.S256())
+}
@@ -0,0 +1,454 @@
+package onionmsg
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/brsuite/brond/btcec"
+	"github.com/brsuite/broln/keychain"
+	"github.com/brsuite/broln/lnwire"
+	"github.com/brsuite/broln/routing/blindedpath"
+	"github.com/brsuite/broln/subscribe"
+)
+
+const (
+	// incomingQueueSize is the number of incoming onion messages that are
+	// buffered before further messages are dropped.
+	incomingQueueSize = 100
+)
+
+var (
+	// ErrMessengerShuttingDown is returned when the messenger is asked to
+	// do something while shutting down.
+	ErrMessengerShuttingDown = errors.New("onion messenger shutting down")
+
+	// ErrMissingEncryptedData is returned when an onion message doesn't
+	// carry any encrypted data for our hop, which every hop of an onion
+	// message must receive.
+	ErrMissingEncryptedData = errors.New("onion message without " +
+		"encrypted data")
+
+	// ErrForwardingUnsupported is returned when we receive an onion
+	// message that we would need to forward to another node, while
+	// forwarding isn't enabled.
+	ErrForwardingUnsupported = errors.New("forwarding onion messages " +
+		"is not supported")
+
+	// ErrNoNextHop is returned when an onion message needs to be
+	// forwarded, but the encrypted data for our hop doesn't tell us
+	// where to.
+	ErrNoNextHop = errors.New("onion message without next hop")
+
+	// ErrUnexpectedNextHop is returned when we are the final hop of an
+	// onion message, though the encrypted data for our hop instructs us
+	// to forward it.
+	ErrUnexpectedNextHop = errors.New("final onion message hop with " +
+		"forwarding instructions")
+
+	// ErrFinalPayloadForwarded is returned when an onion message that
+	// needs to be forwarded carries records that are only meant for the
+	// final hop.
+	ErrFinalPayloadForwarded = errors.New("intermediate onion message " +
+		"hop with final hop records")
+)
+
+// Config houses the dependencies of the onion Messenger.
+type Config struct {
+	// NodeKey is the ECDH key of our node, which onion messages sent to
+	// us are encrypted to.
+	NodeKey keychain.SingleKeyECDH
+
+	// SendToPeer sends an onion message to the connected peer with the
+	// given public key.
+	SendToPeer func(peer [33]byte, msg *lnwire.OnionMessage) error
+
+	// ForwardToPeer passes on an onion message we received to the next
+	// hop. Unlike SendToPeer, it must only send the message if we are
+	// already connected to the peer. If nil, messages we'd need to
+	// forward are dropped.
+	ForwardToPeer func(peer [33]byte, msg *lnwire.OnionMessage) error
+
+	// LookupChannelPeer returns the public key of the peer we have the
+	// channel with the given short channel ID with. It is used to forward
+	// messages to hops that are identified by channel. If nil, such
+	// messages are dropped.
+	LookupChannelPeer func(scid lnwire.ShortChannelID) ([33]byte, error)
+
+	// FindIntroductionNode returns a node that is able to forward onion
+	// messages to us, which is used as the introduction node of our reply
+	// paths. If nil, or if no such node is found, our reply paths start
+	// at our own node.
+	FindIntroductionNode func() (*btcec.PublicKey, error)
+}
+
+// ReceivedMessage is the notification sent to subscribers whenever we receive
+// an onion message we are the final hop of.
+type ReceivedMessage struct {
+	// PathID is the path ID we left for ourselves when creating the
+	// blinded path the message arrived over. It is nil if the sender
+	// created the path to us.
+	PathID []byte
+
+	// Payload is the payload of the final hop.
+	Payload *Payload
+}
+
+// incomingMessage is an onion message received from one of our peers that is
+// waiting to be processed.
+type incomingMessage struct {
+	peer [33]byte
+	msg  *lnwire.OnionMessage
+}
+
+// Messenger sends onion messages and processes the ones our peers send us.
+// Messages we are the final hop of are delivered to its subscribers.
+type Messenger struct {
+	started sync.Once
+	stopped sync.Once
+
+	cfg *Config
+
+	ntfnServer *subscribe.Server
+
+	incoming chan *incomingMessage
+
+	wg   sync.WaitGroup
+	quit chan struct{}
+}
+
+// New creates a new onion Messenger from the passed config.
+func New(cfg *Config) *Messenger {
+	return &Messenger{
+		cfg:        cfg,
+		ntfnServer: subscribe.NewServer(),
+		incoming:   make(chan *incomingMessage, incomingQueueSize),
+		quit:       make(chan struct{}),
+	}
+}
+
+// Start starts the Messenger's subscription server and message processing.
+func (m *Messenger) Start() error {
+	var err error
+	m.started.Do(func() {
+		log.Info("Onion messenger starting")
+
+		err = m.ntfnServer.Start()
+		if err != nil {
+			return
+		}
+
+		m.wg.Add(1)
+		go m.messageHandler()
+	})
+
+	return err
+}
+
+// Stop signals the Messenger for a graceful shutdown.
+func (m *Messenger) Stop() error {
+	var err error
+	m.stopped.Do(func() {
+		log.Info("Onion messenger shutting down")
+
+		close(m.quit)
+		m.wg.Wait()
+
+		err = m.ntfnServer.Stop()
+	})
+
+	return err
+}
+
+// SubscribeMessages returns a subscribe.Client that will receive a
+// ReceivedMessage for each onion message we are the final hop of.
+func (m *Messenger) SubscribeMessages() (*subscribe.Client, error) {
+	return m.ntfnServer.Subscribe()
+}
+
+// HandleMessage queues an onion message received from the given peer for
+// processing. Messages are dropped if too many of them are waiting to be
+// processed already.
+func (m *Messenger) HandleMessage(peer [33]byte, msg *lnwire.OnionMessage) {
+	select {
+	case m.incoming <- &incomingMessage{peer: peer, msg: msg}:
+	case <-m.quit:
+	default:
+		log.Debugf("Dropping onion message from %x, queue full", peer)
+	}
+}
+
+// messageHandler processes the onion messages received from our peers.
+//
+// NOTE: This MUST be run as a goroutine.
+func (m *Messenger) messageHandler() {
+	defer m.wg.Done()
+
+	for {
+		select {
+		case incoming := <-m.incoming:
+			err := m.processMessage(incoming.msg)
+			if err != nil {
+				log.Debugf("Unable to process onion message "+
+					"from %x: %v", incoming.peer, err)
+			}
+
+		case <-m.quit:
+			return
+		}
+	}
+}
+
+// processMessage peels our layer of the passed onion message and delivers
+// its payload to our subscribers if we are the final hop.
+func (m *Messenger) processMessage(msg *lnwire.OnionMessage) error {
+	// Onion messages are always encrypted to our blinded node ID, which
+	// we derive from the path key that came along with the message.
+	blindedKey, err := blindedpath.NewBlindedNodeECDH(
+		m.cfg.NodeKey, msg.PathKey,
+	)
+	if err != nil {
+		return err
+	}
+
+	packet, err := DecodePacket(msg.OnionBlob)
+	if err != nil {
+		return err
+	}
+
+	processed, err := packet.Process(blindedKey)
+	if err != nil {
+		return err
+	}
+
+	payload, err := DecodePayload(bytes.NewReader(processed.Payload))
+	if err != nil {
+		return err
+	}
+
+	if len(payload.EncryptedData) == 0 {
+		return ErrMissingEncryptedData
+	}
+
+	plainText, nextPathKey, err := blindedpath.DecryptBlindedHopData(
+		m.cfg.NodeKey, msg.PathKey, payload.EncryptedData,
+	)
+	if err != nil {
+		return fmt.Errorf("unable to decrypt data: %v", err)
+	}
+
+	data, err := blindedpath.DecodeEncryptedData(plainText)
+	if err != nil {
+		return err
+	}
+
+	if processed.NextPacket != nil {
+		// The creator of the path may have concatenated it with
+		// another one, in which case it tells us the blinding point
+		// of the next hop.
+		if data.NextBlindingOverride != nil {
+			nextPathKey = data.NextBlindingOverride
+		}
+
+		return m.forwardMessage(
+			payload, data, nextPathKey, processed.NextPacket,
+		)
+	}
+
+	if data.NextNodeID != nil || data.ShortChannelID != nil {
+		return ErrUnexpectedNextHop
+	}
+
+	log.Tracef("Received onion message with path ID %x", data.PathID)
+
+	return m.ntfnServer.SendUpdate(&ReceivedMessage{
+		PathID:  data.PathID,
+		Payload: payload,
+	})
+}
+
+// forwardMessage passes the next packet of an onion message on to the hop
+// the creator of the path instructed us to send it to.
+func (m *Messenger) forwardMessage(payload *Payload,
+	data *blindedpath.EncryptedData, nextPathKey *btcec.PublicKey,
+	nextPacket *Packet) error {
+
+	if m.cfg.ForwardToPeer == nil {
+		return ErrForwardingUnsupported
+	}
+
+	if payload.IsFinal() {
+		return ErrFinalPayloadForwarded
+	}
+
+	var nextPeer [33]byte
+	switch {
+	case data.NextNodeID != nil:
+		copy(nextPeer[:], data.NextNodeID.SerializeCompressed())
+
+	case data.ShortChannelID != nil && m.cfg.LookupChannelPeer != nil:
+		var err error
+		nextPeer, err = m.cfg.LookupChannelPeer(*data.ShortChannelID)
+		if err != nil {
+			return fmt.Errorf("unable to find peer of channel %v: "+
+				"%v", data.ShortChannelID, err)
+		}
+
+	default:
+		return ErrNoNextHop
+	}
+
+	var b bytes.Buffer
+	if err := nextPacket.Encode(&b); err != nil {
+		return err
+	}
+
+	log.Tracef("Forwarding onion message to %x", nextPeer)
+
+	return m.cfg.ForwardToPeer(
+		nextPeer, lnwire.NewOnionMessage(nextPathKey, b.Bytes()),
+	)
+}
+
+// SendMessage sends an onion message with the passed payload along the given
+// blinded path. The introduction node of the path must be one of our peers.
+func (m *Messenger) SendMessage(path *blindedpath.BlindedPath,
+	payload *Payload) error {
+
+	sessionKey, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		return err
+	}
+
+	msg, err := NewMessage(sessionKey, path, payload)
+	if err != nil {
+		return err
+	}
+
+	var peer [33]byte
+	copy(peer[:], path.IntroductionPoint.SerializeCompressed())
+
+	return m.cfg.SendToPeer(peer, msg)
+}
+
+// ReplyPath creates a blinded path to our node that others can send onion
+// messages to. The passed path ID is delivered along with each message that
+// arrives over it, which allows us to tell which messages are replies to the
+// ones we sent. If possible, the path starts at one of our peers, so that it
+// doesn't reveal our node.
+func (m *Messenger) ReplyPath(pathID []byte) (*blindedpath.BlindedPath,
+	error) {
+
+	ourKey := m.cfg.NodeKey.PubKey()
+
+	data := &blindedpath.EncryptedData{
+		PathID: pathID,
+	}
+	plainText, err := data.Encode()
+	if err != nil {
+		return nil, err
+	}
+
+	hops := []*blindedpath.HopInfo{
+		{
+			NodePub:   ourKey,
+			PlainText: plainText,
+		},
+	}
+
+	if m.cfg.FindIntroductionNode != nil {
+		introNode, err := m.cfg.FindIntroductionNode()
+		switch {
+		case err == ErrNoIntroductionNode:
+			log.Debugf("No introduction node found, creating " +
+				"reply path from our node")
+
+		case err != nil:
+			return nil, err
+
+		default:
+			introData := &blindedpath.EncryptedData{
+				NextNodeID: ourKey,
+			}
+			introPlainText, err := introData.Encode()
+			if err != nil {
+				return nil, err
+			}
+
+			hops = append([]*blindedpath.HopInfo{{
+				NodePub:   introNode,
+				PlainText: introPlainText,
+			}}, hops...)
+		}
+	}
+
+	sessionKey, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		return nil, err
+	}
+
+	return blindedpath.BuildBlindedPath(sessionKey, hops)
+}
+
+// DirectPath creates a blinded path that consists of the destination node
+// only, which is used to send onion messages to nodes that didn't give us a
+// blinded path to them.
+func DirectPath(destination *btcec.PublicKey) (*blindedpath.BlindedPath,
+	error) {
+
+	sessionKey, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		return nil, err
+	}
+
+	return blindedpath.BuildBlindedPath(sessionKey, []*blindedpath.HopInfo{
+		{
+			NodePub: destination,
+		},
+	})
+}
+
+// NewMessage creates an onion message that is routed along the passed blinded
+// path, delivering the payload to its final hop.
+func NewMessage(sessionKey *btcec.PrivateKey, path *blindedpath.BlindedPath,
+	finalPayload *Payload) (*lnwire.OnionMessage, error) {
+
+	if len(path.BlindedHops) == 0 {
+		return nil, blindedpath.ErrNoHops
+	}
+
+	var (
+		numHops  = len(path.BlindedHops)
+		hopKeys  = make([]*btcec.PublicKey, numHops)
+		payloads = make([][]byte, numHops)
+	)
+	for i, hop := range path.BlindedHops {
+		hopKeys[i] = hop.BlindedNodePub
+
+		payload := &Payload{}
+		if i == numHops-1 {
+			final := *finalPayload
+			payload = &final
+		}
+		payload.EncryptedData = hop.CipherText
+
+		var err error
+		payloads[i], err = payload.Encode()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	packet, err := NewPacket(sessionKey, hopKeys, payloads)
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	if err := packet.Encode(&b); err != nil {
+		return nil, err
+	}
+
+	return lnwire.NewOnionMessage(path.BlindingPoint, b.Bytes()), nil
+}
@@ -0,0 +1,231 @@
+package onionmsg
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/brsuite/brond/btcec"
+	"github.com/brsuite/broln/keychain"
+	"github.com/brsuite/broln/lnwire"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestMessenger creates and starts a messenger with a fresh node key.
+func newTestMessenger(t *testing.T) *Messenger {
+	t.Helper()
+
+	priv, err := btcec.NewPrivateKey(btcec.S256())
+	require.NoError(t, err)
+
+	m := New(&Config{
+		NodeKey: &keychain.PrivKeyECDH{PrivKey: priv},
+	})
+	require.NoError(t, m.Start())
+	t.Cleanup(func() {
+		require.NoError(t, m.Stop())
+	})
+
+	return m
+}
+
+// connect makes the sender deliver the messages it sends to the receiver.
+func connect(sender, receiver *Messenger) {
+	var senderPub [33]byte
+	copy(senderPub[:], sender.cfg.NodeKey.PubKey().SerializeCompressed())
+
+	sender.cfg.SendToPeer = func(peer [33]byte,
+		msg *lnwire.OnionMessage) error {
+
+		receiver.HandleMessage(senderPub, msg)
+		return nil
+	}
+}
+
+// receiveMessage waits for the subscription to deliver a message.
+func receiveMessage(t *testing.T, updates <-chan interface{}) *ReceivedMessage {
+	t.Helper()
+
+	select {
+	case update := <-updates:
+		msg, ok := update.(*ReceivedMessage)
+		require.True(t, ok)
+
+		return msg
+
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no message received")
+	}
+
+	return nil
+}
+
+// TestMessengerRequestReply asserts that an onion message can be sent to a
+// node directly, and that the node is able to reply over the reply path that
+// came along with it.
+func TestMessengerRequestReply(t *testing.T) {
+	t.Parallel()
+
+	alice := newTestMessenger(t)
+	bob := newTestMessenger(t)
+	connect(alice, bob)
+	connect(bob, alice)
+
+	aliceSub, err := alice.SubscribeMessages()
+	require.NoError(t, err)
+	defer aliceSub.Cancel()
+
+	bobSub, err := bob.SubscribeMessages()
+	require.NoError(t, err)
+	defer bobSub.Cancel()
+
+	// Alice sends a request to Bob, including a path Bob can reply over.
+	pathID := []byte{1, 2, 3, 4}
+	replyPath, err := alice.ReplyPath(pathID)
+	require.NoError(t, err)
+
+	bobPath, err := DirectPath(bob.cfg.NodeKey.PubKey())
+	require.NoError(t, err)
+
+	err = alice.SendMessage(bobPath, &Payload{
+		ReplyPath:      replyPath,
+		InvoiceRequest: []byte("request"),
+	})
+	require.NoError(t, err)
+
+	request := receiveMessage(t, bobSub.Updates())
+	require.Nil(t, request.PathID)
+	require.Equal(t, []byte("request"), request.Payload.InvoiceRequest)
+	require.NotNil(t, request.Payload.ReplyPath)
+
+	// Bob replies, and Alice should be able to tell the reply belongs to
+	// her request.
+	err = bob.SendMessage(request.Payload.ReplyPath, &Payload{
+		Invoice: []byte("invoice"),
+	})
+	require.NoError(t, err)
+
+	reply := receiveMessage(t, aliceSub.Updates())
+	require.Equal(t, pathID, reply.PathID)
+	require.Equal(t, []byte("invoice"), reply.Payload.Invoice)
+}
+
+// TestMessengerForward asserts that messages are forwarded along blinded
+// paths, and that reply paths start at the introduction node if one is found.
+func TestMessengerForward(t *testing.T) {
+	t.Parallel()
+
+	alice := newTestMessenger(t)
+	bob := newTestMessenger(t)
+	carol := newTestMessenger(t)
+	connect(alice, bob)
+	connect(carol, bob)
+
+	// Bob forwards the messages he receives to whoever is next.
+	var bobPub [33]byte
+	copy(bobPub[:], bob.cfg.NodeKey.PubKey().SerializeCompressed())
+	bob.cfg.ForwardToPeer = func(peer [33]byte,
+		msg *lnwire.OnionMessage) error {
+
+		for _, m := range []*Messenger{alice, carol} {
+			pub := m.cfg.NodeKey.PubKey().SerializeCompressed()
+			if bytes.Equal(pub, peer[:]) {
+				m.HandleMessage(bobPub, msg)
+				return nil
+			}
+		}
+
+		return fmt.Errorf("unknown peer %x", peer)
+	}
+
+	// The reply paths of both Alice and Carol start at Bob.
+	findBob := func() (*btcec.PublicKey, error) {
+		return bob.cfg.NodeKey.PubKey(), nil
+	}
+	alice.cfg.FindIntroductionNode = findBob
+	carol.cfg.FindIntroductionNode = findBob
+
+	aliceSub, err := alice.SubscribeMessages()
+	require.NoError(t, err)
+	defer aliceSub.Cancel()
+
+	carolSub, err := carol.SubscribeMessages()
+	require.NoError(t, err)
+	defer carolSub.Cancel()
+
+	carolPathID := []byte{5, 6, 7}
+	carolPath, err := carol.ReplyPath(carolPathID)
+	require.NoError(t, err)
+	require.True(t, carolPath.IntroductionPoint.IsEqual(
+		bob.cfg.NodeKey.PubKey(),
+	))
+	require.Len(t, carolPath.BlindedHops, 2)
+
+	// Alice sends a message over Carol's path, which Bob forwards.
+	alicePathID := []byte{8, 9}
+	alicePath, err := alice.ReplyPath(alicePathID)
+	require.NoError(t, err)
+
+	err = alice.SendMessage(carolPath, &Payload{
+		ReplyPath: alicePath,
+		CustomRecords: map[uint64][]byte{
+			65537: []byte("ping"),
+		},
+	})
+	require.NoError(t, err)
+
+	msg := receiveMessage(t, carolSub.Updates())
+	require.Equal(t, carolPathID, msg.PathID)
+	require.Equal(t, []byte("ping"), msg.Payload.CustomRecords[65537])
+
+	// Carol replies over the path Alice gave her, which Bob forwards as
+	// well.
+	err = carol.SendMessage(msg.Payload.ReplyPath, &Payload{
+		CustomRecords: map[uint64][]byte{
+			65537: []byte("pong"),
+		},
+	})
+	require.NoError(t, err)
+
+	reply := receiveMessage(t, aliceSub.Updates())
+	require.Equal(t, alicePathID, reply.PathID)
+	require.Equal(t, []byte("pong"), reply.Payload.CustomRecords[65537])
+}
+
+// TestMessengerRejectForward asserts that messages we can't forward are
+// dropped.
+func TestMessengerRejectForward(t *testing.T) {
+	t.Parallel()
+
+	bob := newTestMessenger(t)
+
+	priv, err := btcec.NewPrivateKey(btcec.S256())
+	require.NoError(t, err)
+	carolPath, err := DirectPath(priv.PubKey())
+	require.NoError(t, err)
+
+	// Prepend Bob to the path to Carol, so Bob would have to forward the
+	// message. The encrypted data of Bob's hop doesn't tell him where to
+	// though.
+	bobPath, err := DirectPath(bob.cfg.NodeKey.PubKey())
+	require.NoError(t, err)
+	bobPath.BlindedHops = append(
+		bobPath.BlindedHops, carolPath.BlindedHops...,
+	)
+
+	msg, err := NewMessage(priv, bobPath, &Payload{
+		Invoice: []byte("invoice"),
+	})
+	require.NoError(t, err)
+
+	// Without a way to forward messages, they are dropped.
+	err = bob.processMessage(msg)
+	require.ErrorIs(t, err, ErrForwardingUnsupported)
+
+	bob.cfg.ForwardToPeer = func([33]byte, *lnwire.OnionMessage) error {
+		return nil
+	}
+	err = bob.processMessage(msg)
+	require.ErrorIs(t, err, ErrNoNextHop)
+}
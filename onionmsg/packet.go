@@ -0,0 +1,385 @@
+package onionmsg
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/brsuite/brond/btcec"
+	"github.com/brsuite/broln/keychain"
+	"github.com/brsuite/broln/routing/blindedpath"
+	"github.com/brsuite/broln/tlv"
+	"golang.org/x/crypto/chacha20"
+)
+
+const (
+	// packetVersion is the only onion packet version currently defined.
+	packetVersion = 0
+
+	// hmacSize is the size of the HMAC that authenticates each layer of
+	// the onion.
+	hmacSize = 32
+
+	// SmallPayloadSize is the size of the hop payloads of a regular onion
+	// message packet, which matches the one of an HTLC onion.
+	SmallPayloadSize = 1300
+
+	// LargePayloadSize is the size of the hop payloads of an onion
+	// message packet that doesn't fit into a regular one.
+	LargePayloadSize = 32768
+
+	// packetOverhead is the number of bytes of a packet that aren't part
+	// of the hop payloads.
+	packetOverhead = 1 + 33 + hmacSize
+)
+
+var (
+	// ErrInvalidPacketVersion is returned when processing a packet of an
+	// unknown version.
+	ErrInvalidPacketVersion = errors.New("invalid onion packet version")
+
+	// ErrInvalidPacketHMAC is returned when the HMAC of a packet doesn't
+	// match, either because it was tampered with or because it wasn't
+	// meant for us.
+	ErrInvalidPacketHMAC = errors.New("invalid onion packet hmac")
+
+	// ErrPayloadTooLarge is returned when the hop payloads of a message
+	// don't fit into the largest packet size.
+	ErrPayloadTooLarge = errors.New("onion message payloads too large")
+)
+
+// Packet is an onion message packet as defined in BOLT 04. It uses the same
+// construction as the onion of an HTLC, though the size of its hop payloads
+// varies with the size of the message.
+type Packet struct {
+	// Version is the version of the packet.
+	Version byte
+
+	// EphemeralKey is the key the receiver uses to derive the secret it
+	// shares with the sender.
+	EphemeralKey *btcec.PublicKey
+
+	// HopPayloads are the encrypted payloads of all remaining hops.
+	HopPayloads []byte
+
+	// HMAC authenticates the hop payloads for the receiver.
+	HMAC [hmacSize]byte
+}
+
+// Encode serializes the packet into the passed io.Writer.
+func (p *Packet) Encode(w io.Writer) error {
+	if _, err := w.Write([]byte{p.Version}); err != nil {
+		return err
+	}
+	if _, err := w.Write(p.EphemeralKey.SerializeCompressed()); err != nil {
+		return err
+	}
+	if _, err := w.Write(p.HopPayloads); err != nil {
+		return err
+	}
+
+	_, err := w.Write(p.HMAC[:])
+	return err
+}
+
+// DecodePacket parses an onion message packet from its serialization.
+func DecodePacket(b []byte) (*Packet, error) {
+	if len(b) <= packetOverhead {
+		return nil, fmt.Errorf("onion packet of %d bytes too short",
+			len(b))
+	}
+
+	p := &Packet{
+		Version:     b[0],
+		HopPayloads: b[34 : len(b)-hmacSize],
+	}
+	if p.Version != packetVersion {
+		return nil, ErrInvalidPacketVersion
+	}
+
+	var err error
+	p.EphemeralKey, err = btcec.ParsePubKey(b[1:34], btcec.S256())
+	if err != nil {
+		return nil, err
+	}
+	copy(p.HMAC[:], b[len(b)-hmacSize:])
+
+	return p, nil
+}
+
+// ProcessedPacket is the outcome of peeling a layer of an onion message
+// packet.
+type ProcessedPacket struct {
+	// Payload is the plain text payload that was left for us.
+	Payload []byte
+
+	// NextPacket is the packet that should be passed on to the next hop.
+	// It is nil if we are the final hop.
+	NextPacket *Packet
+}
+
+// hopPayloadSize returns the number of bytes a hop payload takes up in the
+// packet, including its length prefix and HMAC.
+func hopPayloadSize(payload []byte) int {
+	return int(tlv.VarIntSize(uint64(len(payload)))) + len(payload) +
+		hmacSize
+}
+
+// NewPacket creates an onion message packet that is routed along the passed
+// hops, each of which receives its respective payload. The hop keys are the
+// keys the nodes process the onion with, which for onion messages are their
+// blinded node IDs.
+func NewPacket(sessionKey *btcec.PrivateKey, hops []*btcec.PublicKey,
+	payloads [][]byte) (*Packet, error) {
+
+	if len(hops) == 0 || len(hops) != len(payloads) {
+		return nil, fmt.Errorf("need one payload per hop, got %d hops "+
+			"and %d payloads", len(hops), len(payloads))
+	}
+
+	// Pick the smallest packet size all payloads fit into.
+	var totalSize int
+	for _, payload := range payloads {
+		totalSize += hopPayloadSize(payload)
+	}
+
+	var payloadsSize int
+	switch {
+	case totalSize <= SmallPayloadSize:
+		payloadsSize = SmallPayloadSize
+
+	case totalSize <= LargePayloadSize:
+		payloadsSize = LargePayloadSize
+
+	default:
+		return nil, ErrPayloadTooLarge
+	}
+
+	// Derive the secret we share with each of the hops. The ephemeral
+	// key is blinded after each hop, so that the hops can't link the
+	// packet they receive to the one they pass on.
+	var (
+		numHops       = len(hops)
+		sharedSecrets = make([][32]byte, numHops)
+		ephemeralKey  = sessionKey
+	)
+	for i, hop := range hops {
+		ecdh := &keychain.PrivKeyECDH{PrivKey: ephemeralKey}
+		sharedSecret, err := ecdh.ECDH(hop)
+		if err != nil {
+			return nil, err
+		}
+		sharedSecrets[i] = sharedSecret
+
+		ephemeralKey = blindedpath.NextEphemeralKey(
+			ephemeralKey, sharedSecret,
+		)
+	}
+
+	filler := generateFiller(payloadsSize, payloads, sharedSecrets)
+
+	// The hop payloads are initialized with pseudo random bytes, so that
+	// the unused space doesn't reveal the length of the route.
+	var sessionKeyBytes [32]byte
+	copy(sessionKeyBytes[:], sessionKey.Serialize())
+	mixHeader := generateCipherStream(
+		generateKey("pad", sessionKeyBytes), payloadsSize,
+	)
+
+	// Now wrap the payloads from the final hop to the first one, each
+	// time authenticating the result for the respective hop.
+	var nextHMAC [hmacSize]byte
+	for i := numHops - 1; i >= 0; i-- {
+		var (
+			hopPayload bytes.Buffer
+			buf        [8]byte
+		)
+		err := tlv.WriteVarInt(
+			&hopPayload, uint64(len(payloads[i])), &buf,
+		)
+		if err != nil {
+			return nil, err
+		}
+		hopPayload.Write(payloads[i])
+		hopPayload.Write(nextHMAC[:])
+
+		shift := hopPayload.Len()
+		copy(mixHeader[shift:], mixHeader[:payloadsSize-shift])
+		copy(mixHeader, hopPayload.Bytes())
+
+		rho := generateKey("rho", sharedSecrets[i])
+		xor(mixHeader, generateCipherStream(rho, payloadsSize))
+
+		if i == numHops-1 {
+			copy(mixHeader[len(mixHeader)-len(filler):], filler)
+		}
+
+		mu := generateKey("mu", sharedSecrets[i])
+		nextHMAC = calcMac(mu, mixHeader)
+	}
+
+	return &Packet{
+		Version:      packetVersion,
+		EphemeralKey: sessionKey.PubKey(),
+		HopPayloads:  mixHeader,
+		HMAC:         nextHMAC,
+	}, nil
+}
+
+// Process peels the layer of the packet that is encrypted to the passed key,
+// returning our payload and the packet that should be passed on to the next
+// hop, if any.
+func (p *Packet) Process(nodeKey keychain.SingleKeyECDH) (*ProcessedPacket,
+	error) {
+
+	if p.Version != packetVersion {
+		return nil, ErrInvalidPacketVersion
+	}
+
+	sharedSecret, err := nodeKey.ECDH(p.EphemeralKey)
+	if err != nil {
+		return nil, err
+	}
+
+	mu := generateKey("mu", sharedSecret)
+	expectedMac := calcMac(mu, p.HopPayloads)
+	if !hmac.Equal(expectedMac[:], p.HMAC[:]) {
+		return nil, ErrInvalidPacketHMAC
+	}
+
+	// Decrypt the hop payloads, extended by as many zero bytes, so that
+	// the next packet is of the same size again.
+	payloadsSize := len(p.HopPayloads)
+	decrypted := make([]byte, 2*payloadsSize)
+	copy(decrypted, p.HopPayloads)
+	rho := generateKey("rho", sharedSecret)
+	xor(decrypted, generateCipherStream(rho, 2*payloadsSize))
+
+	r := bytes.NewReader(decrypted)
+	var buf [8]byte
+	payloadLen, err := tlv.ReadVarInt(r, &buf)
+	if err != nil {
+		return nil, err
+	}
+	if payloadLen > uint64(payloadsSize) {
+		return nil, fmt.Errorf("hop payload of %d bytes exceeds packet",
+			payloadLen)
+	}
+
+	payload := make([]byte, payloadLen)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+
+	var nextHMAC [hmacSize]byte
+	if _, err := io.ReadFull(r, nextHMAC[:]); err != nil {
+		return nil, err
+	}
+
+	processed := &ProcessedPacket{
+		Payload: payload,
+	}
+
+	// An all zero HMAC signals that there is no packet to pass on.
+	if nextHMAC == [hmacSize]byte{} {
+		return processed, nil
+	}
+
+	consumed := hopPayloadSize(payload)
+	processed.NextPacket = &Packet{
+		Version: packetVersion,
+		EphemeralKey: blindedpath.NextBlindingPoint(
+			p.EphemeralKey, sharedSecret,
+		),
+		HopPayloads: decrypted[consumed : consumed+payloadsSize],
+		HMAC:        nextHMAC,
+	}
+
+	return processed, nil
+}
+
+// generateFiller generates the bytes the final hop finds at the end of its
+// hop payloads, which are the result of all previous hops shifting in their
+// decryption stream. They're added to the packet when wrapping the final hop,
+// so that the HMACs of all hops cover them.
+func generateFiller(payloadsSize int, payloads [][]byte,
+	sharedSecrets [][32]byte) []byte {
+
+	numHops := len(payloads)
+
+	var fillerSize int
+	for _, payload := range payloads[:numHops-1] {
+		fillerSize += hopPayloadSize(payload)
+	}
+
+	// The filler dangles off the end of the hop payloads, starting where
+	// the payloads of all previous hops have been shifted out.
+	filler := make([]byte, fillerSize)
+	fillerStart := payloadsSize
+	for i := 0; i < numHops-1; i++ {
+		hopSize := hopPayloadSize(payloads[i])
+		fillerEnd := payloadsSize + hopSize
+
+		rho := generateKey("rho", sharedSecrets[i])
+		stream := generateCipherStream(rho, 2*payloadsSize)
+		xor(filler, stream[fillerStart:fillerEnd])
+
+		fillerStart -= hopSize
+	}
+
+	return filler
+}
+
+// generateKey derives a key of the given type from a shared secret.
+func generateKey(keyType string, sharedSecret [32]byte) [32]byte {
+	var key [32]byte
+	copy(key[:], calcHMAC([]byte(keyType), sharedSecret[:]))
+
+	return key
+}
+
+// calcMac computes the HMAC the passed data is authenticated with.
+func calcMac(key [32]byte, data []byte) [hmacSize]byte {
+	var mac [hmacSize]byte
+	copy(mac[:], calcHMAC(key[:], data))
+
+	return mac
+}
+
+// calcHMAC returns the HMAC-SHA256 of the data under the passed key.
+func calcHMAC(key, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+
+	return mac.Sum(nil)
+}
+
+// generateCipherStream returns the given number of bytes of the ChaCha20
+// stream keyed with the passed key.
+func generateCipherStream(key [32]byte, numBytes int) []byte {
+	var nonce [chacha20.NonceSize]byte
+
+	// The key and nonce have the correct sizes, so this can't fail.
+	cipher, _ := chacha20.NewUnauthenticatedCipher(key[:], nonce[:])
+
+	stream := make([]byte, numBytes)
+	cipher.XORKeyStream(stream, stream)
+
+	return stream
+}
+
+// xor sets dst to the exclusive or of dst and src, up to the length of the
+// shorter one.
+func xor(dst, src []byte) {
+	n := len(dst)
+	if len(src) < n {
+		n = len(src)
+	}
+
+	for i := 0; i < n; i++ {
+		dst[i] ^= src[i]
+	}
+}
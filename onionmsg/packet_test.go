@@ -0,0 +1,126 @@
+package onionmsg
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/brsuite/brond/btcec"
+	"github.com/brsuite/broln/keychain"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPacketRoundTrip asserts that each hop of an onion message packet is
+// able to peel its layer and obtain its payload, both for regular and large
+// packets.
+func TestPacketRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		payloadSize  int
+		expectedSize int
+	}{
+		{
+			name:         "small packet",
+			payloadSize:  100,
+			expectedSize: SmallPayloadSize,
+		},
+		{
+			name:         "large packet",
+			payloadSize:  2000,
+			expectedSize: LargePayloadSize,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			const numHops = 5
+
+			var (
+				keys     []*btcec.PrivateKey
+				hopKeys  []*btcec.PublicKey
+				payloads [][]byte
+			)
+			for i := 0; i < numHops; i++ {
+				priv, err := btcec.NewPrivateKey(btcec.S256())
+				require.NoError(t, err)
+
+				keys = append(keys, priv)
+				hopKeys = append(hopKeys, priv.PubKey())
+				payloads = append(payloads, bytes.Repeat(
+					[]byte{byte(i)}, test.payloadSize/numHops,
+				))
+			}
+
+			sessionKey, err := btcec.NewPrivateKey(btcec.S256())
+			require.NoError(t, err)
+
+			packet, err := NewPacket(sessionKey, hopKeys, payloads)
+			require.NoError(t, err)
+			require.Len(t, packet.HopPayloads, test.expectedSize)
+
+			// Run the packet through its serialization, as each
+			// hop would receive it.
+			for i := 0; i < numHops; i++ {
+				var b bytes.Buffer
+				require.NoError(t, packet.Encode(&b))
+
+				packet, err = DecodePacket(b.Bytes())
+				require.NoError(t, err)
+
+				processed, err := packet.Process(
+					&keychain.PrivKeyECDH{PrivKey: keys[i]},
+				)
+				require.NoError(t, err)
+				require.Equal(t, payloads[i], processed.Payload)
+
+				if i == numHops-1 {
+					require.Nil(t, processed.NextPacket)
+					break
+				}
+
+				require.NotNil(t, processed.NextPacket)
+				packet = processed.NextPacket
+			}
+		})
+	}
+}
+
+// TestPacketInvalid asserts that packets that were tampered with or weren't
+// meant for us are rejected.
+func TestPacketInvalid(t *testing.T) {
+	t.Parallel()
+
+	nodeKey, err := btcec.NewPrivateKey(btcec.S256())
+	require.NoError(t, err)
+	sessionKey, err := btcec.NewPrivateKey(btcec.S256())
+	require.NoError(t, err)
+
+	packet, err := NewPacket(
+		sessionKey, []*btcec.PublicKey{nodeKey.PubKey()},
+		[][]byte{{1, 2, 3}},
+	)
+	require.NoError(t, err)
+
+	// A different node isn't able to process the packet.
+	otherKey, err := btcec.NewPrivateKey(btcec.S256())
+	require.NoError(t, err)
+	_, err = packet.Process(&keychain.PrivKeyECDH{PrivKey: otherKey})
+	require.ErrorIs(t, err, ErrInvalidPacketHMAC)
+
+	// Neither are we if the payloads were modified.
+	packet.HopPayloads[10] ^= 0x01
+	_, err = packet.Process(&keychain.PrivKeyECDH{PrivKey: nodeKey})
+	require.ErrorIs(t, err, ErrInvalidPacketHMAC)
+
+	// Payloads exceeding the largest packet can't be sent at all.
+	_, err = NewPacket(
+		sessionKey, []*btcec.PublicKey{nodeKey.PubKey()},
+		[][]byte{make([]byte, LargePayloadSize)},
+	)
+	require.ErrorIs(t, err, ErrPayloadTooLarge)
+}
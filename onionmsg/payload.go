@@ -0,0 +1,202 @@
+package onionmsg
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/brsuite/broln/routing/blindedpath"
+	"github.com/brsuite/broln/tlv"
+)
+
+const (
+	// ReplyPathType is the type of the record holding the blinded path
+	// the final hop can reply to the message over.
+	ReplyPathType tlv.Type = 2
+
+	// EncryptedDataType is the type of the record holding the data the
+	// creator of the blinded path left for the hop.
+	EncryptedDataType tlv.Type = 4
+
+	// InvoiceRequestType is the type of the record holding a BOLT 12
+	// invoice request.
+	InvoiceRequestType tlv.Type = 64
+
+	// InvoiceType is the type of the record holding a BOLT 12 invoice.
+	InvoiceType tlv.Type = 66
+
+	// InvoiceErrorType is the type of the record holding a BOLT 12 invoice
+	// error.
+	InvoiceErrorType tlv.Type = 68
+
+	// finalHopTypeStart is the first type of the range reserved for
+	// records that are only meant for the final hop.
+	finalHopTypeStart tlv.Type = 64
+)
+
+var (
+	// ErrInvalidCustomRecord is returned when encoding a payload with a
+	// custom record that is either outside of the final hop range or of
+	// a type we already know.
+	ErrInvalidCustomRecord = errors.New("custom onion message records " +
+		"must be of an unknown type of at least 64")
+)
+
+// Payload is the payload of a single hop of an onion message.
+type Payload struct {
+	// ReplyPath is the blinded path the final hop can reply over.
+	ReplyPath *blindedpath.BlindedPath
+
+	// EncryptedData is the encrypted data the creator of the blinded path
+	// left for this hop.
+	EncryptedData []byte
+
+	// InvoiceRequest is a serialized BOLT 12 invoice request.
+	InvoiceRequest []byte
+
+	// Invoice is a serialized BOLT 12 invoice.
+	Invoice []byte
+
+	// InvoiceError is a serialized BOLT 12 invoice error.
+	InvoiceError []byte
+
+	// CustomRecords are the records of the final hop range we don't know
+	// about, which are used by applications to exchange their own
+	// messages.
+	CustomRecords map[uint64][]byte
+}
+
+// IsFinal returns true if the payload carries any records that are only
+// meant for the final hop.
+func (p *Payload) IsFinal() bool {
+	return p.ReplyPath != nil || len(p.InvoiceRequest) > 0 ||
+		len(p.Invoice) > 0 || len(p.InvoiceError) > 0 ||
+		len(p.CustomRecords) > 0
+}
+
+// isKnownType returns true if the passed type is one of the payload records
+// we know about.
+func isKnownType(typ tlv.Type) bool {
+	switch typ {
+	case ReplyPathType, EncryptedDataType, InvoiceRequestType,
+		InvoiceType, InvoiceErrorType:
+
+		return true
+
+	default:
+		return false
+	}
+}
+
+// Encode serializes the payload as a TLV stream.
+func (p *Payload) Encode() ([]byte, error) {
+	var records []tlv.Record
+
+	if p.ReplyPath != nil {
+		var b bytes.Buffer
+		if err := p.ReplyPath.Encode(&b); err != nil {
+			return nil, err
+		}
+
+		replyPath := b.Bytes()
+		records = append(records, tlv.MakePrimitiveRecord(
+			ReplyPathType, &replyPath,
+		))
+	}
+
+	optionalBytes := []struct {
+		typ   tlv.Type
+		value *[]byte
+	}{
+		{EncryptedDataType, &p.EncryptedData},
+		{InvoiceRequestType, &p.InvoiceRequest},
+		{InvoiceType, &p.Invoice},
+		{InvoiceErrorType, &p.InvoiceError},
+	}
+	for _, field := range optionalBytes {
+		if len(*field.value) == 0 {
+			continue
+		}
+
+		records = append(records, tlv.MakePrimitiveRecord(
+			field.typ, field.value,
+		))
+	}
+
+	for typ := range p.CustomRecords {
+		if tlv.Type(typ) < finalHopTypeStart ||
+			isKnownType(tlv.Type(typ)) {
+
+			return nil, ErrInvalidCustomRecord
+		}
+	}
+	records = append(records, tlv.MapToRecords(p.CustomRecords)...)
+	tlv.SortRecords(records)
+
+	stream, err := tlv.NewStream(records...)
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	if err := stream.Encode(&b); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+// DecodePayload parses the payload of a single hop of an onion message.
+func DecodePayload(r io.Reader) (*Payload, error) {
+	var (
+		p         Payload
+		replyPath []byte
+	)
+
+	stream, err := tlv.NewStream(
+		tlv.MakePrimitiveRecord(ReplyPathType, &replyPath),
+		tlv.MakePrimitiveRecord(EncryptedDataType, &p.EncryptedData),
+		tlv.MakePrimitiveRecord(InvoiceRequestType, &p.InvoiceRequest),
+		tlv.MakePrimitiveRecord(InvoiceType, &p.Invoice),
+		tlv.MakePrimitiveRecord(InvoiceErrorType, &p.InvoiceError),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	parsedTypes, err := stream.DecodeWithParsedTypes(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unknown records in the final hop range are meant for applications
+	// and are passed on to them, though we can't process any other
+	// unknown even type.
+	for typ, value := range parsedTypes {
+		switch {
+		case value == nil:
+			continue
+
+		case typ >= finalHopTypeStart:
+			if p.CustomRecords == nil {
+				p.CustomRecords = make(map[uint64][]byte)
+			}
+			p.CustomRecords[uint64(typ)] = value
+
+		case typ%2 == 0:
+			return nil, fmt.Errorf("unknown required type %d in "+
+				"onion message payload", typ)
+		}
+	}
+
+	if _, ok := parsedTypes[ReplyPathType]; ok {
+		p.ReplyPath = &blindedpath.BlindedPath{}
+		err := p.ReplyPath.Decode(bytes.NewReader(replyPath))
+		if err != nil {
+			return nil, fmt.Errorf("invalid reply path: %v", err)
+		}
+	}
+
+	return &p, nil
+}
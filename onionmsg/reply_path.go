@@ -0,0 +1,82 @@
+package onionmsg
+
+import (
+	"errors"
+
+	"github.com/brsuite/brond/btcec"
+	"github.com/brsuite/broln/channeldb"
+	"github.com/brsuite/broln/kvdb"
+	"github.com/brsuite/broln/lnwire"
+	"github.com/brsuite/broln/routing/route"
+	"github.com/brsuite/bronutil"
+)
+
+var (
+	// ErrNoIntroductionNode is returned when none of our peers is suitable
+	// as the introduction node of our reply paths.
+	ErrNoIntroductionNode = errors.New("no introduction node found")
+)
+
+// ChannelGraph is the view of the channel graph we need to pick the
+// introduction node of our reply paths.
+type ChannelGraph interface {
+	// ForEachNodeChannel iterates through all channels of the given node.
+	ForEachNodeChannel(tx kvdb.RTx, node route.Vertex,
+		cb func(channel *channeldb.DirectedChannel) error) error
+
+	// FetchNodeFeatures returns the features the given node announced.
+	FetchNodeFeatures(node route.Vertex) (*lnwire.FeatureVector, error)
+}
+
+// FindIntroductionNode picks the peer we use as the introduction node of our
+// reply paths. Senders need to be able to find the introduction node, so only
+// peers that announced themselves along with their support for onion messages
+// qualify. Out of those that we are currently connected to, we pick the one we
+// share the most capacity with, as it is the least likely to go away.
+func FindIntroductionNode(graph ChannelGraph, self route.Vertex,
+	isOnline func(route.Vertex) bool) (*btcec.PublicKey, error) {
+
+	capacities := make(map[route.Vertex]bronutil.Amount)
+	err := graph.ForEachNodeChannel(nil, self,
+		func(channel *channeldb.DirectedChannel) error {
+			capacities[channel.OtherNode] += channel.Capacity
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		bestNode     *route.Vertex
+		bestCapacity bronutil.Amount
+	)
+	for node, capacity := range capacities {
+		node := node
+
+		if bestNode != nil && capacity <= bestCapacity {
+			continue
+		}
+
+		if !isOnline(node) {
+			continue
+		}
+
+		features, err := graph.FetchNodeFeatures(node)
+		if err != nil {
+			return nil, err
+		}
+		if !features.HasFeature(lnwire.OnionMessagesOptional) {
+			continue
+		}
+
+		bestNode = &node
+		bestCapacity = capacity
+	}
+
+	if bestNode == nil {
+		return nil, ErrNoIntroductionNode
+	}
+
+	return btcec.ParsePubKey(bestNode[:], btcec.S256())
+}
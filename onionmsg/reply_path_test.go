@@ -0,0 +1,92 @@
+package onionmsg
+
+import (
+	"testing"
+
+	"github.com/brsuite/brond/btcec"
+	"github.com/brsuite/broln/channeldb"
+	"github.com/brsuite/broln/kvdb"
+	"github.com/brsuite/broln/lnwire"
+	"github.com/brsuite/broln/routing/route"
+	"github.com/stretchr/testify/require"
+)
+
+// mockGraph is a ChannelGraph that holds the channels of a single node.
+type mockGraph struct {
+	channels []*channeldb.DirectedChannel
+	features map[route.Vertex]*lnwire.FeatureVector
+}
+
+// ForEachNodeChannel iterates through all channels of the given node.
+func (m *mockGraph) ForEachNodeChannel(_ kvdb.RTx, _ route.Vertex,
+	cb func(channel *channeldb.DirectedChannel) error) error {
+
+	for _, channel := range m.channels {
+		if err := cb(channel); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// FetchNodeFeatures returns the features the given node announced.
+func (m *mockGraph) FetchNodeFeatures(
+	node route.Vertex) (*lnwire.FeatureVector, error) {
+
+	features, ok := m.features[node]
+	if !ok {
+		return lnwire.EmptyFeatureVector(), nil
+	}
+
+	return features, nil
+}
+
+// TestFindIntroductionNode asserts that we pick the online peer that supports
+// onion messages that we share the most capacity with.
+func TestFindIntroductionNode(t *testing.T) {
+	t.Parallel()
+
+	var peers [4]route.Vertex
+	for i := range peers {
+		priv, err := btcec.NewPrivateKey(btcec.S256())
+		require.NoError(t, err)
+		peers[i] = route.NewVertex(priv.PubKey())
+	}
+
+	onionMessages := lnwire.NewFeatureVector(
+		lnwire.NewRawFeatureVector(lnwire.OnionMessagesOptional),
+		lnwire.Features,
+	)
+
+	// The first peer has the most capacity, but doesn't support onion
+	// messages. The second one is offline and the third one has two
+	// smaller channels that add up to more than the fourth one.
+	graph := &mockGraph{
+		channels: []*channeldb.DirectedChannel{
+			{OtherNode: peers[0], Capacity: 10_000_000},
+			{OtherNode: peers[1], Capacity: 5_000_000},
+			{OtherNode: peers[2], Capacity: 1_500_000},
+			{OtherNode: peers[2], Capacity: 1_500_000},
+			{OtherNode: peers[3], Capacity: 2_000_000},
+		},
+		features: map[route.Vertex]*lnwire.FeatureVector{
+			peers[1]: onionMessages,
+			peers[2]: onionMessages,
+			peers[3]: onionMessages,
+		},
+	}
+	offline := peers[1]
+	isOnline := func(node route.Vertex) bool {
+		return node != offline
+	}
+
+	introNode, err := FindIntroductionNode(graph, route.Vertex{}, isOnline)
+	require.NoError(t, err)
+	require.Equal(t, peers[2], route.NewVertex(introNode))
+
+	// Without any suitable peer, we can't find an introduction node.
+	graph.channels = graph.channels[:2]
+	_, err = FindIntroductionNode(graph, route.Vertex{}, isOnline)
+	require.ErrorIs(t, err, ErrNoIntroductionNode)
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/brsuite/brond/txscript"
 	"github.com/brsuite/brond/wire"
 	"github.com/davecgh/go-spew/spew"
+	"golang.org/x/time/rate"
 
 	"github.com/brsuite/broln/buffer"
 	"github.com/brsuite/broln/chainntnfs"
@@ -68,6 +69,16 @@ const (
 
 	// ErrorBufferSize is the number of historic peer errors that we store.
 	ErrorBufferSize = 10
+
+	// onionMessageRate is the number of onion messages per second we
+	// accept from a peer on average. Onion messages don't cost their
+	// sender anything, so we limit how many of them a peer can make us
+	// process and forward.
+	onionMessageRate = 10
+
+	// onionMessageBurst is the number of onion messages we accept from a
+	// peer in quick succession before its rate limit kicks in.
+	onionMessageBurst = 50
 )
 
 var (
@@ -327,6 +338,16 @@ type Config struct {
 	// from the peer.
 	HandleCustomMessage func(peer [33]byte, msg *lnwire.Custom) error
 
+	// HandleOnionMessage is called whenever an onion message is received
+	// from the peer, unless the peer exceeds its onion message rate limit.
+	// If nil, onion messages are ignored.
+	HandleOnionMessage func(peer [33]byte, msg *lnwire.OnionMessage)
+
+	// HandlePeerBackup is called whenever the peer hands back the
+	// encrypted channel backup we asked it to store on our behalf. It
+	// must not block. If nil, backups handed back to us are ignored.
+	HandlePeerBackup func(peer [33]byte, backup []byte)
+
 	// PongBuf is a slice we'll reuse instead of allocating memory on the
 	// heap. Since only reads will occur and no writes, there is no need
 	// for any synchronization primitives. As a result, it's safe to share
@@ -423,6 +444,13 @@ type Brontide struct {
 	// the state machine will be deleted from the map.
 	activeChanCloses map[lnwire.ChannelID]*chancloser.ChanCloser
 
+	// activeRbfCloses is a map that keeps track of all the active
+	// cooperative channel closures using replaceable closing transactions.
+	// As either party may replace the closing transaction, the state
+	// machines are kept around until the channel's funding output is
+	// spent.
+	activeRbfCloses map[lnwire.ChannelID]*chancloser.RbfChanCloser
+
 	// localCloseChanReqs is a channel in which any local requests to close
 	// a particular channel are sent over.
 	localCloseChanReqs chan *htlcswitch.ChanClose
@@ -433,7 +461,8 @@ type Brontide struct {
 
 	// chanCloseMsgs is a channel that any message related to channel
 	// closures are sent over. This includes lnwire.Shutdown message as
-	// well as lnwire.ClosingSigned messages.
+	// well as lnwire.ClosingSigned, lnwire.ClosingComplete and
+	// lnwire.ClosingSig messages.
 	chanCloseMsgs chan *closeMsg
 
 	// remoteFeatures is the feature vector received from the peer during
@@ -446,6 +475,22 @@ type Brontide struct {
 	// peer's chansync message with its own over and over again.
 	resentChanSyncMsg map[lnwire.ChannelID]struct{}
 
+	// onionMsgLimiter limits the rate at which we accept onion messages
+	// from the peer.
+	onionMsgLimiter *rate.Limiter
+
+	// spliceMtx protects access to the spliceNegotiations and spliceLocks
+	// maps.
+	spliceMtx sync.Mutex
+
+	// spliceNegotiations tracks the splices of our channels whose
+	// transaction is being constructed with the peer.
+	spliceNegotiations map[lnwire.ChannelID]*spliceNegotiation
+
+	// spliceLocks tracks the splices of our channels that are waiting to
+	// be locked in.
+	spliceLocks map[lnwire.ChannelID]*spliceLock
+
 	queueQuit chan struct{}
 	quit      chan struct{}
 	wg        sync.WaitGroup
@@ -467,12 +512,20 @@ func NewBrontide(cfg Config) *Brontide {
 
 		activeMsgStreams:   make(map[lnwire.ChannelID]*msgStream),
 		activeChanCloses:   make(map[lnwire.ChannelID]*chancloser.ChanCloser),
+		activeRbfCloses:    make(map[lnwire.ChannelID]*chancloser.RbfChanCloser),
 		localCloseChanReqs: make(chan *htlcswitch.ChanClose),
 		linkFailures:       make(chan linkFailureReport),
 		chanCloseMsgs:      make(chan *closeMsg),
 		resentChanSyncMsg:  make(map[lnwire.ChannelID]struct{}),
 		queueQuit:          make(chan struct{}),
 		quit:               make(chan struct{}),
+
+		onionMsgLimiter: rate.NewLimiter(
+			onionMessageRate, onionMessageBurst,
+		),
+
+		spliceNegotiations: make(map[lnwire.ChannelID]*spliceNegotiation),
+		spliceLocks:        make(map[lnwire.ChannelID]*spliceLock),
 	}
 
 	return p
@@ -588,6 +641,10 @@ func (p *Brontide) Start() error {
 	// Signal to any external processes that the peer is now active.
 	close(p.activeSignal)
 
+	// If the peer asked us to store a backup on its behalf, we'll hand it
+	// back now so it's able to recover any data it lost.
+	p.sendYourPeerStorage()
+
 	// Now that the peer has started up, we send any channel sync messages
 	// that must be resent for borked channels.
 	if len(msgs) > 0 {
@@ -781,6 +838,13 @@ func (p *Brontide) loadActiveChannels(chans []*channeldb.OpenChannel) (
 		p.activeChanMtx.Lock()
 		p.activeChannels[chanID] = lnChan
 		p.activeChanMtx.Unlock()
+
+		// If the channel has unconfirmed splices, it stays frozen until
+		// one of them is locked in.
+		if err := p.resumeSplice(lnChan); err != nil {
+			return nil, fmt.Errorf("unable to resume splice of "+
+				"%v: %v", chanPoint, err)
+		}
 	}
 
 	return msgs, nil
@@ -1403,11 +1467,37 @@ out:
 			pong := lnwire.NewPong(p.cfg.PongBuf[0:msg.NumPongBytes])
 			p.queueMsg(pong, nil)
 
+		case *lnwire.SpliceInit,
+			*lnwire.SpliceAck,
+			*lnwire.SpliceLocked:
+
+			p.handleSpliceMsg(msg)
+
+		// The interactive construction of a transaction is used both
+		// to fund new channels and to splice existing ones. Splices
+		// are referenced by the ID of the channel being spliced.
+		case *lnwire.TxAddInput,
+			*lnwire.TxAddOutput,
+			*lnwire.TxRemoveInput,
+			*lnwire.TxRemoveOutput,
+			*lnwire.TxComplete,
+			*lnwire.TxSignatures,
+			*lnwire.TxAbort:
+
+			if p.isSpliceMsg(msg) {
+				p.handleSpliceMsg(msg)
+				break
+			}
+
+			p.cfg.FundingManager.ProcessFundingMsg(msg, p)
+
 		case *lnwire.OpenChannel,
 			*lnwire.AcceptChannel,
 			*lnwire.FundingCreated,
 			*lnwire.FundingSigned,
-			*lnwire.FundingLocked:
+			*lnwire.FundingLocked,
+			*lnwire.OpenChannel2,
+			*lnwire.AcceptChannel2:
 
 			p.cfg.FundingManager.ProcessFundingMsg(msg, p)
 
@@ -1423,6 +1513,18 @@ out:
 			case <-p.quit:
 				break out
 			}
+		case *lnwire.ClosingComplete:
+			select {
+			case p.chanCloseMsgs <- &closeMsg{msg.ChannelID, msg}:
+			case <-p.quit:
+				break out
+			}
+		case *lnwire.ClosingSig:
+			select {
+			case p.chanCloseMsgs <- &closeMsg{msg.ChannelID, msg}:
+			case <-p.quit:
+				break out
+			}
 
 		case *lnwire.Error:
 			targetChan = msg.ChanID
@@ -1447,10 +1549,33 @@ out:
 				}
 			}
 
+		// The first commitment signature of a dual funded channel is
+		// exchanged while the channel is still pending, so it's
+		// handled by the funding manager. The same goes for the
+		// signatures of the new commitments of a splice, which are
+		// part of its negotiation.
+		case *lnwire.CommitSig:
+			if p.isSpliceMsg(msg) {
+				p.handleSpliceMsg(msg)
+				break
+			}
+
+			if p.cfg.FundingManager.IsPendingChannel(msg.ChanID, p) {
+				p.cfg.FundingManager.ProcessFundingMsg(msg, p)
+				break
+			}
+
+			targetChan = msg.ChanID
+			isLinkUpdate = p.isActiveChannel(targetChan)
+
 		case LinkUpdater:
 			targetChan = msg.TargetChanID()
 			isLinkUpdate = p.isActiveChannel(targetChan)
 
+			// An update of a channel we're about to splice means
+			// the channel is no longer quiescent.
+			p.maybeAbortSplice(targetChan, nextMsg)
+
 		case *lnwire.ChannelUpdate,
 			*lnwire.ChannelAnnouncement,
 			*lnwire.NodeAnnouncement,
@@ -1470,6 +1595,31 @@ out:
 				peerLog.Errorf("peer: %v, %v", p, err)
 			}
 
+		case *lnwire.OnionMessage:
+			// Onion messages are an optional feature, so peers
+			// sending them to us even if we don't support them
+			// isn't an error.
+			if p.cfg.HandleOnionMessage == nil {
+				break
+			}
+
+			// Peers exceeding their rate limit have their
+			// messages dropped, as onion messages are unreliable
+			// anyway.
+			if !p.onionMsgLimiter.Allow() {
+				peerLog.Debugf("Dropping onion message from "+
+					"%v, rate limit exceeded", p)
+				break
+			}
+
+			p.cfg.HandleOnionMessage(p.PubKey(), msg)
+
+		case *lnwire.PeerStorage:
+			p.handlePeerStorage(msg)
+
+		case *lnwire.YourPeerStorage:
+			p.handleYourPeerStorage(msg)
+
 		default:
 			// If the message we received is unknown to us, store
 			// the type to track the failure.
@@ -1633,6 +1783,14 @@ func messageSummary(msg lnwire.Message) string {
 		return fmt.Sprintf("chan_id=%v, fee_sat=%v", msg.ChannelID,
 			msg.FeeSatoshis)
 
+	case *lnwire.ClosingComplete:
+		return fmt.Sprintf("chan_id=%v, fee_sat=%v, locktime=%v",
+			msg.ChannelID, msg.FeeSatoshis, msg.LockTime)
+
+	case *lnwire.ClosingSig:
+		return fmt.Sprintf("chan_id=%v, fee_sat=%v, locktime=%v",
+			msg.ChannelID, msg.FeeSatoshis, msg.LockTime)
+
 	case *lnwire.UpdateAddHTLC:
 		return fmt.Sprintf("chan_id=%v, id=%v, amt=%v, expiry=%v, hash=%x",
 			msg.ChanID, msg.ID, msg.Amount, msg.Expiry, msg.PaymentHash[:])
@@ -1685,6 +1843,16 @@ func messageSummary(msg lnwire.Message) string {
 	case *lnwire.Pong:
 		return fmt.Sprintf("pong_bytes=%x", msg.PongBytes[:])
 
+	case *lnwire.OnionMessage:
+		return fmt.Sprintf("path_key=%x, onion_len=%v",
+			msg.PathKey.SerializeCompressed(), len(msg.OnionBlob))
+
+	case *lnwire.PeerStorage:
+		return fmt.Sprintf("blob_len=%v", len(msg.Blob))
+
+	case *lnwire.YourPeerStorage:
+		return fmt.Sprintf("blob_len=%v", len(msg.Blob))
+
 	case *lnwire.UpdateFee:
 		return fmt.Sprintf("chan_id=%v, fee_update_sat=%v",
 			msg.ChanID, int64(msg.FeePerKw))
@@ -2483,17 +2651,7 @@ func (p *Brontide) fetchActiveChanCloser(chanID lnwire.ChannelID) (
 		}
 
 		chanCloser = chancloser.NewChanCloser(
-			chancloser.ChanCloseCfg{
-				Channel:     channel,
-				BroadcastTx: p.cfg.Wallet.PublishTransaction,
-				DisableChannel: func(chanPoint wire.OutPoint) error {
-					return p.cfg.ChanStatusMgr.RequestDisable(chanPoint, false)
-				},
-				Disconnect: func() error {
-					return p.cfg.DisconnectPeer(p.IdentityKey())
-				},
-				Quit: p.quit,
-			},
+			p.chanCloseCfg(channel),
 			deliveryScript,
 			feePerKw,
 			uint32(startingHeight),
@@ -2506,6 +2664,24 @@ func (p *Brontide) fetchActiveChanCloser(chanID lnwire.ChannelID) (
 	return chanCloser, nil
 }
 
+// chanCloseCfg returns the configuration of a channel closer for the given
+// channel.
+func (p *Brontide) chanCloseCfg(
+	channel *lnwallet.LightningChannel) chancloser.ChanCloseCfg {
+
+	return chancloser.ChanCloseCfg{
+		Channel:     channel,
+		BroadcastTx: p.cfg.Wallet.PublishTransaction,
+		DisableChannel: func(chanPoint wire.OutPoint) error {
+			return p.cfg.ChanStatusMgr.RequestDisable(chanPoint, false)
+		},
+		Disconnect: func() error {
+			return p.cfg.DisconnectPeer(p.IdentityKey())
+		},
+		Quit: p.quit,
+	}
+}
+
 // chooseDeliveryScript takes two optionally set shutdown scripts and returns
 // a suitable script to close out to. This may be nil if neither script is
 // set. If both scripts are set, this function will error if they do not match.
@@ -2562,6 +2738,18 @@ func (p *Brontide) handleLocalCloseReq(req *htlcswitch.ChanClose) {
 	// out this channel on-chain, so we execute the cooperative channel
 	// closure workflow.
 	case contractcourt.CloseRegular:
+		// If the channel is already being closed with replaceable
+		// closing transactions, the request bumps the fee of the
+		// pending close.
+		if chanCloser, ok := p.activeRbfCloses[chanID]; ok {
+			p.bumpRbfClose(chanCloser, req)
+			return
+		}
+		if _, ok := p.activeChanCloses[chanID]; ok {
+			req.Err <- chancloser.ErrChanAlreadyClosing
+			return
+		}
+
 		// First, we'll choose a delivery address that we'll use to send the
 		// funds to in the case of a successful negotiation.
 
@@ -2607,18 +2795,18 @@ func (p *Brontide) handleLocalCloseReq(req *htlcswitch.ChanClose) {
 			return
 		}
 
+		// If both we and the remote peer support replaceable closing
+		// transactions, we'll use them to close the channel.
+		if p.supportsRbfClose() {
+			p.startRbfClose(
+				channel, deliveryScript, uint32(startingHeight),
+				req,
+			)
+			return
+		}
+
 		chanCloser := chancloser.NewChanCloser(
-			chancloser.ChanCloseCfg{
-				Channel:     channel,
-				BroadcastTx: p.cfg.Wallet.PublishTransaction,
-				DisableChannel: func(chanPoint wire.OutPoint) error {
-					return p.cfg.ChanStatusMgr.RequestDisable(chanPoint, false)
-				},
-				Disconnect: func() error {
-					return p.cfg.DisconnectPeer(p.IdentityKey())
-				},
-				Quit: p.quit,
-			},
+			p.chanCloseCfg(channel),
 			deliveryScript,
 			req.TargetFeePerKw,
 			uint32(startingHeight),
@@ -3161,6 +3349,13 @@ func (p *Brontide) StartTime() time.Time {
 // message is received from the remote peer. We'll use this message to advance
 // the chan closer state machine.
 func (p *Brontide) handleCloseMsg(msg *closeMsg) {
+	// Closures using replaceable closing transactions are handled by
+	// their own state machine.
+	if p.isRbfClose(msg) {
+		p.handleRbfCloseMsg(msg)
+		return
+	}
+
 	// We'll now fetch the matching closing state machine in order to continue,
 	// or finalize the channel closure process.
 	chanCloser, err := p.fetchActiveChanCloser(msg.cid)
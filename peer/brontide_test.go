@@ -8,6 +8,7 @@ import (
 
 	"github.com/brsuite/brond/btcec"
 	"github.com/brsuite/brond/chaincfg"
+	"github.com/brsuite/brond/chaincfg/chainhash"
 	"github.com/brsuite/brond/txscript"
 	"github.com/brsuite/brond/wire"
 	"github.com/brsuite/bronutil"
@@ -15,8 +16,12 @@ import (
 	"github.com/brsuite/broln/channeldb"
 	"github.com/brsuite/broln/contractcourt"
 	"github.com/brsuite/broln/htlcswitch"
+	"github.com/brsuite/broln/input"
 	"github.com/brsuite/broln/lntest/mock"
+	"github.com/brsuite/broln/lnwallet"
+	"github.com/brsuite/broln/lnwallet/chainfee"
 	"github.com/brsuite/broln/lnwallet/chancloser"
+	"github.com/brsuite/broln/lnwallet/chanfunding"
 	"github.com/brsuite/broln/lnwire"
 	"github.com/brsuite/broln/pool"
 	"github.com/stretchr/testify/assert"
@@ -676,6 +681,341 @@ func TestPeerChannelClosureFeeNegotiationsInitiator(t *testing.T) {
 	notifier.ConfChan <- &chainntnfs.TxConfirmation{}
 }
 
+// TestPeerChannelClosureFeeRange tests that the responder of a shutdown
+// immediately accepts a fee within its fee range, if the initiator of the
+// shutdown sends its own fee range along with its proposal.
+func TestPeerChannelClosureFeeRange(t *testing.T) {
+	t.Parallel()
+
+	notifier := &mock.ChainNotifier{
+		SpendChan: make(chan *chainntnfs.SpendDetail),
+		EpochChan: make(chan *chainntnfs.BlockEpoch),
+		ConfChan:  make(chan *chainntnfs.TxConfirmation),
+	}
+	broadcastTxChan := make(chan *wire.MsgTx)
+
+	mockSwitch := &mockMessageSwitch{}
+
+	alicePeer, bobChan, cleanUp, err := createTestPeer(
+		notifier, broadcastTxChan, noUpdate, mockSwitch,
+	)
+	require.NoError(t, err)
+	defer cleanUp()
+
+	chanID := lnwire.NewChanIDFromOutPoint(bobChan.ChannelPoint())
+	mockLink := newMockUpdateHandler(chanID)
+	mockSwitch.links = append(mockSwitch.links, mockLink)
+
+	// nextMsg pulls the next message off of Alice's outgoing queue.
+	nextMsg := func() lnwire.Message {
+		t.Helper()
+
+		select {
+		case outMsg := <-alicePeer.outgoingQueue:
+			return outMsg.msg
+		case <-time.After(timeout):
+			t.Fatalf("did not receive message")
+			return nil
+		}
+	}
+
+	// We send a shutdown request to Alice, which responds with her own
+	// shutdown, followed by her fee proposal as the channel initiator.
+	alicePeer.chanCloseMsgs <- &closeMsg{
+		cid: chanID,
+		msg: lnwire.NewShutdown(chanID, dummyDeliveryScript),
+	}
+
+	shutdownMsg, ok := nextMsg().(*lnwire.Shutdown)
+	require.True(t, ok, "expected Shutdown message")
+
+	aliceClosingSigned, ok := nextMsg().(*lnwire.ClosingSigned)
+	require.True(t, ok, "expected ClosingSigned message")
+
+	// Alice's proposal should carry her fee range, which includes the fee
+	// she proposed.
+	aliceRange := aliceClosingSigned.FeeRange
+	require.NotNil(t, aliceRange)
+	require.True(t, aliceRange.Contains(aliceClosingSigned.FeeSatoshis))
+	require.Less(t, aliceRange.MinFeeSats, aliceClosingSigned.FeeSatoshis)
+
+	// We propose a lower fee that is still within Alice's range, along
+	// with our own fee range.
+	bobFee := aliceRange.MinFeeSats
+	bobSig, _, _, err := bobChan.CreateCloseProposal(
+		bobFee, dummyDeliveryScript, shutdownMsg.Address,
+	)
+	require.NoError(t, err)
+
+	parsedSig, err := lnwire.NewSigFromSignature(bobSig)
+	require.NoError(t, err)
+
+	closingSigned := lnwire.NewClosingSigned(chanID, bobFee, parsedSig)
+	closingSigned.FeeRange = &lnwire.FeeRange{
+		MinFeeSats: bobFee,
+		MaxFeeSats: aliceClosingSigned.FeeSatoshis,
+	}
+	alicePeer.chanCloseMsgs <- &closeMsg{
+		cid: chanID,
+		msg: closingSigned,
+	}
+
+	// Alice should accept our fee right away, broadcast the closing
+	// transaction and echo our fee.
+	select {
+	case <-broadcastTxChan:
+	case <-time.After(timeout):
+		t.Fatalf("closing tx not broadcast")
+	}
+
+	aliceAccept, ok := nextMsg().(*lnwire.ClosingSigned)
+	require.True(t, ok, "expected ClosingSigned message")
+	require.Equal(t, bobFee, aliceAccept.FeeSatoshis)
+
+	// Alice should be waiting in a goroutine for a confirmation.
+	notifier.ConfChan <- &chainntnfs.TxConfirmation{}
+}
+
+// TestPeerChannelClosureRbf tests that a channel closed with replaceable
+// closing transactions can be fee bumped by both parties, and that all local
+// close requests are notified once the funding output is spent.
+func TestPeerChannelClosureRbf(t *testing.T) {
+	t.Parallel()
+
+	notifier := &mock.ChainNotifier{
+		SpendChan: make(chan *chainntnfs.SpendDetail),
+		EpochChan: make(chan *chainntnfs.BlockEpoch),
+		ConfChan:  make(chan *chainntnfs.TxConfirmation),
+	}
+	broadcastTxChan := make(chan *wire.MsgTx)
+
+	mockSwitch := &mockMessageSwitch{}
+
+	alicePeer, bobChan, cleanUp, err := createTestPeer(
+		notifier, broadcastTxChan, noUpdate, mockSwitch,
+	)
+	require.NoError(t, err)
+	defer cleanUp()
+
+	features := lnwire.NewFeatureVector(
+		lnwire.NewRawFeatureVector(lnwire.SimpleCloseOptional),
+		lnwire.Features,
+	)
+	alicePeer.cfg.Features = features
+	alicePeer.remoteFeatures = features
+
+	chanID := lnwire.NewChanIDFromOutPoint(bobChan.ChannelPoint())
+	mockLink := newMockUpdateHandler(chanID)
+	mockSwitch.links = append(mockSwitch.links, mockLink)
+
+	// nextMsg pulls the next message off of Alice's outgoing queue.
+	nextMsg := func() lnwire.Message {
+		t.Helper()
+
+		select {
+		case outMsg := <-alicePeer.outgoingQueue:
+			return outMsg.msg
+		case <-time.After(timeout):
+			t.Fatalf("did not receive message")
+			return nil
+		}
+	}
+
+	// assertBroadcast asserts that Alice broadcasts a closing transaction.
+	assertBroadcast := func() *wire.MsgTx {
+		t.Helper()
+
+		select {
+		case tx := <-broadcastTxChan:
+			return tx
+		case <-time.After(timeout):
+			t.Fatalf("closing tx not broadcast")
+			return nil
+		}
+	}
+
+	// closeReq sends a local request to close the channel at the given
+	// fee rate to Alice.
+	closeReq := func(feeRate chainfee.SatPerKWeight) *htlcswitch.ChanClose {
+		req := &htlcswitch.ChanClose{
+			CloseType:      contractcourt.CloseRegular,
+			ChanPoint:      bobChan.ChannelPoint(),
+			Updates:        make(chan interface{}, 2),
+			TargetFeePerKw: feeRate,
+			Err:            make(chan error, 1),
+		}
+		alicePeer.localCloseChanReqs <- req
+
+		return req
+	}
+
+	// signAliceProposal has Bob sign the closing transaction Alice
+	// proposed, and sends his closing_sig to Alice, which should broadcast
+	// the closing transaction in turn.
+	signAliceProposal := func(msg *lnwire.ClosingComplete) *wire.MsgTx {
+		t.Helper()
+
+		require.NotNil(t, msg.ClosingSigs.CloserAndClosee)
+
+		bobSig, err := bobChan.SignSimpleClose(&lnwallet.SimpleClose{
+			Fee:          msg.FeeSatoshis,
+			LockTime:     msg.LockTime,
+			LocalScript:  msg.CloseeScript,
+			RemoteScript: msg.CloserScript,
+		})
+		require.NoError(t, err)
+
+		sig, err := lnwire.NewSigFromSignature(bobSig)
+		require.NoError(t, err)
+
+		alicePeer.chanCloseMsgs <- &closeMsg{
+			cid: chanID,
+			msg: &lnwire.ClosingSig{
+				ChannelID:    chanID,
+				CloserScript: msg.CloserScript,
+				CloseeScript: msg.CloseeScript,
+				FeeSatoshis:  msg.FeeSatoshis,
+				LockTime:     msg.LockTime,
+				ClosingSigs: lnwire.ClosingSigs{
+					CloserAndClosee: &sig,
+				},
+			},
+		}
+
+		closeTx := assertBroadcast()
+		require.Equal(
+			t, uint32(lnwallet.RbfCloseSequence),
+			closeTx.TxIn[0].Sequence,
+		)
+
+		return closeTx
+	}
+
+	// assertPending asserts that the request is notified of the given
+	// closing transaction.
+	assertPending := func(req *htlcswitch.ChanClose, tx *wire.MsgTx) {
+		t.Helper()
+
+		select {
+		case update := <-req.Updates:
+			pending, ok := update.(*PendingUpdate)
+			require.True(t, ok, "expected PendingUpdate, got %T",
+				update)
+
+			txid := tx.TxHash()
+			require.Equal(t, txid[:], pending.Txid)
+
+		case err := <-req.Err:
+			t.Fatalf("unexpected error: %v", err)
+
+		case <-time.After(timeout):
+			t.Fatalf("did not receive pending update")
+		}
+	}
+
+	// We make Alice send a shutdown request, to which Bob responds with
+	// his own.
+	req1 := closeReq(12500)
+	shutdownMsg, ok := nextMsg().(*lnwire.Shutdown)
+	require.True(t, ok, "expected Shutdown message")
+
+	alicePeer.chanCloseMsgs <- &closeMsg{
+		cid: chanID,
+		msg: lnwire.NewShutdown(chanID, dummyDeliveryScript),
+	}
+
+	// Alice proposes her first closing transaction, paying the fee.
+	firstProposal, ok := nextMsg().(*lnwire.ClosingComplete)
+	require.True(t, ok, "expected ClosingComplete message")
+	require.Equal(t, shutdownMsg.Address, firstProposal.CloserScript)
+	require.Equal(t, dummyDeliveryScript, []byte(firstProposal.CloseeScript))
+
+	firstTx := signAliceProposal(firstProposal)
+	assertPending(req1, firstTx)
+
+	// A new close request with a higher fee rate makes Alice propose a
+	// closing transaction replacing the first one.
+	req2 := closeReq(25000)
+	secondProposal, ok := nextMsg().(*lnwire.ClosingComplete)
+	require.True(t, ok, "expected ClosingComplete message")
+	require.Greater(
+		t, secondProposal.FeeSatoshis, firstProposal.FeeSatoshis,
+	)
+
+	secondTx := signAliceProposal(secondProposal)
+	assertPending(req2, secondTx)
+
+	// A request that doesn't raise the fee is rejected.
+	req3 := closeReq(12500)
+	select {
+	case err := <-req3.Err:
+		require.ErrorIs(t, err, chancloser.ErrCloseFeeNotIncreased)
+	case <-time.After(timeout):
+		t.Fatalf("expected fee bump to fail")
+	}
+
+	// Bob can replace the closing transaction as well, paying the fee
+	// himself. Alice signs and broadcasts it, replying with her signature.
+	bobClose := &lnwallet.SimpleClose{
+		Fee:           secondProposal.FeeSatoshis * 2,
+		LockTime:      secondProposal.LockTime,
+		LocalIsCloser: true,
+		LocalScript:   dummyDeliveryScript,
+		RemoteScript:  shutdownMsg.Address,
+	}
+	bobSig, err := bobChan.SignSimpleClose(bobClose)
+	require.NoError(t, err)
+	sig, err := lnwire.NewSigFromSignature(bobSig)
+	require.NoError(t, err)
+
+	alicePeer.chanCloseMsgs <- &closeMsg{
+		cid: chanID,
+		msg: &lnwire.ClosingComplete{
+			ChannelID:    chanID,
+			CloserScript: dummyDeliveryScript,
+			CloseeScript: shutdownMsg.Address,
+			FeeSatoshis:  bobClose.Fee,
+			LockTime:     bobClose.LockTime,
+			ClosingSigs: lnwire.ClosingSigs{
+				CloserAndClosee: &sig,
+			},
+		},
+	}
+
+	bobTx := assertBroadcast()
+	closingSig, ok := nextMsg().(*lnwire.ClosingSig)
+	require.True(t, ok, "expected ClosingSig message")
+	require.NotNil(t, closingSig.ClosingSigs.CloserAndClosee)
+
+	aliceSig, err := closingSig.ClosingSigs.CloserAndClosee.ToSignature()
+	require.NoError(t, err)
+	completeTx, err := bobChan.CompleteSimpleClose(
+		bobClose, bobSig, aliceSig,
+	)
+	require.NoError(t, err)
+	require.Equal(t, completeTx.TxHash(), bobTx.TxHash())
+
+	// Once the funding output is spent, all close requests are notified.
+	bobTxid := bobTx.TxHash()
+	notifier.SpendChan <- &chainntnfs.SpendDetail{
+		SpenderTxHash: &bobTxid,
+		SpendingTx:    bobTx,
+	}
+
+	for _, req := range []*htlcswitch.ChanClose{req1, req2} {
+		select {
+		case update := <-req.Updates:
+			closeUpdate, ok := update.(*ChannelCloseUpdate)
+			require.True(t, ok, "expected ChannelCloseUpdate, "+
+				"got %T", update)
+			require.Equal(t, bobTxid[:], closeUpdate.ClosingTxid)
+
+		case <-time.After(timeout):
+			t.Fatalf("did not receive close update")
+		}
+	}
+}
+
 // TestChooseDeliveryScript tests that chooseDeliveryScript correctly errors
 // when upfront and user set scripts that do not match are provided, allows
 // matching values and returns appropriate values in the case where one or none
@@ -1155,3 +1495,321 @@ func TestPeerCustomMessage(t *testing.T) {
 	require.Equal(t, remoteKey, receivedCustom.peer)
 	require.Equal(t, receivedCustomMsg, &receivedCustom.msg)
 }
+
+// TestPeerStorage tests that a peer's blob is only stored if we provide
+// storage and have a channel with the peer, and that the stored blob is handed
+// back to the peer.
+func TestPeerStorage(t *testing.T) {
+	t.Parallel()
+
+	notifier := &mock.ChainNotifier{
+		SpendChan: make(chan *chainntnfs.SpendDetail),
+		EpochChan: make(chan *chainntnfs.BlockEpoch),
+		ConfChan:  make(chan *chainntnfs.TxConfirmation),
+	}
+	broadcastTxChan := make(chan *wire.MsgTx)
+
+	alicePeer, _, cleanUp, err := createTestPeer(
+		notifier, broadcastTxChan, noUpdate, &mockMessageSwitch{},
+	)
+	require.NoError(t, err)
+	defer cleanUp()
+
+	db := alicePeer.cfg.ChannelDB
+
+	// assertNoMsg asserts that Alice doesn't send any message.
+	assertNoMsg := func() {
+		t.Helper()
+
+		select {
+		case outMsg := <-alicePeer.outgoingQueue:
+			t.Fatalf("unexpected message: %T", outMsg.msg)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	// Without the feature, the blob isn't stored and nothing is handed
+	// back.
+	alicePeer.cfg.Features = lnwire.EmptyFeatureVector()
+	alicePeer.handlePeerStorage(&lnwire.PeerStorage{Blob: []byte{1}})
+
+	_, err = db.FetchPeerStorage(alicePeer.PubKey())
+	require.ErrorIs(t, err, channeldb.ErrNoPeerStorage)
+
+	// Once Alice provides storage, the blob is stored.
+	alicePeer.cfg.Features = lnwire.NewFeatureVector(
+		lnwire.NewRawFeatureVector(lnwire.ProvideStorageOptional),
+		lnwire.Features,
+	)
+
+	// Nothing is handed back though as long as nothing is stored.
+	alicePeer.sendYourPeerStorage()
+	assertNoMsg()
+
+	alicePeer.handlePeerStorage(&lnwire.PeerStorage{Blob: []byte{1, 2}})
+
+	blob, err := db.FetchPeerStorage(alicePeer.PubKey())
+	require.NoError(t, err)
+	require.Equal(t, []byte{1, 2}, blob)
+
+	// The stored blob is handed back to the peer.
+	go alicePeer.sendYourPeerStorage()
+
+	select {
+	case outMsg := <-alicePeer.outgoingQueue:
+		yourStorage, ok := outMsg.msg.(*lnwire.YourPeerStorage)
+		require.True(t, ok, "expected YourPeerStorage, got %T",
+			outMsg.msg)
+		require.Equal(t, lnwire.PeerStorageBlob{1, 2}, yourStorage.Blob)
+
+	case <-time.After(timeout):
+		t.Fatalf("did not receive your peer storage message")
+	}
+
+	// Once Alice no longer has a channel with the peer, new blobs are no
+	// longer stored.
+	alicePeer.activeChanMtx.Lock()
+	alicePeer.activeChannels = make(
+		map[lnwire.ChannelID]*lnwallet.LightningChannel,
+	)
+	alicePeer.activeChanMtx.Unlock()
+
+	alicePeer.handlePeerStorage(&lnwire.PeerStorage{Blob: []byte{3}})
+
+	blob, err = db.FetchPeerStorage(alicePeer.PubKey())
+	require.NoError(t, err)
+	require.Equal(t, []byte{1, 2}, blob)
+}
+
+// TestSpliceOutNegotiation tests the negotiation of a splice out initiated by
+// Alice, from splice_init up to the publication of the splice transaction.
+// Bob's side is played with his channel state machine, so both parties must
+// arrive at the same splice transaction and commitments.
+func TestSpliceOutNegotiation(t *testing.T) {
+	t.Parallel()
+
+	notifier := &mock.ChainNotifier{
+		SpendChan: make(chan *chainntnfs.SpendDetail),
+		EpochChan: make(chan *chainntnfs.BlockEpoch),
+		ConfChan:  make(chan *chainntnfs.TxConfirmation),
+	}
+	broadcastTxChan := make(chan *wire.MsgTx, 1)
+
+	// The channel reserves are random by default, so we'll pick ones
+	// that leave room for the splice.
+	setReserves := func(a, b *channeldb.OpenChannel) {
+		a.LocalChanCfg.ChanReserve = 10_000
+		a.RemoteChanCfg.ChanReserve = 10_000
+		b.LocalChanCfg.ChanReserve = 10_000
+		b.RemoteChanCfg.ChanReserve = 10_000
+	}
+
+	alicePeer, bobChan, cleanUp, err := createTestPeer(
+		notifier, broadcastTxChan, setReserves, &mockMessageSwitch{},
+	)
+	require.NoError(t, err)
+	defer cleanUp()
+
+	features := lnwire.NewFeatureVector(
+		lnwire.NewRawFeatureVector(lnwire.SpliceOptional),
+		lnwire.Features,
+	)
+	alicePeer.cfg.Features = features
+	alicePeer.remoteFeatures = features
+
+	chanPoint := *bobChan.ChannelPoint()
+	chanID := lnwire.NewChanIDFromOutPoint(&chanPoint)
+	aliceChan := alicePeer.fetchActiveChannel(chanID)
+	require.NotNil(t, aliceChan)
+
+	// nextMsg pulls the next message off of Alice's outgoing queue,
+	// acknowledging it in case it was sent synchronously.
+	nextMsg := func() lnwire.Message {
+		t.Helper()
+
+		select {
+		case outMsg := <-alicePeer.outgoingQueue:
+			if outMsg.errChan != nil {
+				outMsg.errChan <- nil
+			}
+			return outMsg.msg
+
+		case <-time.After(timeout):
+			t.Fatalf("did not receive message")
+			return nil
+		}
+	}
+
+	// sendToAlice hands a message from Bob to Alice. The returned channel
+	// is closed once Alice processed it.
+	sendToAlice := func(msg lnwire.Message) chan struct{} {
+		done := make(chan struct{})
+		go func() {
+			alicePeer.handleSpliceMsg(msg)
+			close(done)
+		}()
+
+		return done
+	}
+
+	waitDone := func(done chan struct{}) {
+		t.Helper()
+
+		select {
+		case <-done:
+		case <-time.After(timeout):
+			t.Fatalf("message not processed")
+		}
+	}
+
+	spliceOutScript := genScript(t, p2wshAddress)
+	const (
+		spliceOut = bronutil.Amount(100_000_000)
+		feeRate   = chainfee.SatPerKWeight(2_500)
+	)
+
+	type spliceResult struct {
+		txid *chainhash.Hash
+		err  error
+	}
+	resultChan := make(chan spliceResult, 1)
+	go func() {
+		txid, err := alicePeer.SpliceChannel(&SpliceRequest{
+			ChanPoint:       chanPoint,
+			SpliceOut:       spliceOut,
+			SpliceOutScript: spliceOutScript,
+			FeeRate:         feeRate,
+		})
+		resultChan <- spliceResult{txid, err}
+	}()
+
+	// Alice takes the splice out and the fee from the channel.
+	msg := nextMsg()
+	spliceInit, ok := msg.(*lnwire.SpliceInit)
+	require.True(t, ok, "expected SpliceInit, got %T", msg)
+
+	fee := lnwallet.SpliceFee(feeRate, spliceOutScript, false)
+	require.Equal(
+		t, -int64(spliceOut+fee), spliceInit.FundingContribution,
+	)
+	require.True(t, aliceChan.IsSplicing())
+
+	// Bob accepts the splice, and follows the construction of the splice
+	// transaction, answering each of Alice's inputs and outputs with
+	// tx_complete.
+	require.NoError(t, bobChan.MarkSplicing())
+	bobSession := chanfunding.NewInteractiveTx(
+		false, spliceInit.LockTime,
+		lnwallet.DustLimitForSize(input.P2WSHSize),
+	)
+	bobSession.SetSharedInput(chanPoint, bobChan.FundingOutput())
+
+	done := sendToAlice(&lnwire.SpliceAck{
+		ChanID:        chanID,
+		FundingPubKey: bobChan.State().LocalChanCfg.MultiSigKey.PubKey,
+	})
+
+	var aliceCommitSig *lnwire.CommitSig
+	for aliceCommitSig == nil {
+		switch msg := nextMsg().(type) {
+		case *lnwire.TxAddInput:
+			require.NotNil(t, msg.SharedInputTxid)
+			err := bobSession.AddRemoteSharedInput(
+				msg.SerialID,
+				chainhash.Hash(*msg.SharedInputTxid),
+				msg.PrevTxOut, msg.Sequence,
+			)
+			require.NoError(t, err)
+
+		case *lnwire.TxAddOutput:
+			err := bobSession.AddRemoteOutput(
+				msg.SerialID, msg.Amount, msg.PkScript,
+			)
+			require.NoError(t, err)
+
+		// Alice follows up her tx_complete with her signature for
+		// Bob's new commitment.
+		case *lnwire.TxComplete:
+			next := nextMsg()
+			aliceCommitSig, ok = next.(*lnwire.CommitSig)
+			require.True(t, ok, "expected CommitSig, got %T", next)
+
+		default:
+			t.Fatalf("unexpected message %T", msg)
+		}
+		waitDone(done)
+
+		if aliceCommitSig == nil {
+			done = sendToAlice(&lnwire.TxComplete{ChanID: chanID})
+		}
+	}
+
+	// Alice's signature must be valid for the commitment Bob built from
+	// the same splice transaction.
+	spliceTx := bobSession.Tx()
+	require.Len(t, spliceTx.TxIn, 1)
+	require.Len(t, spliceTx.TxOut, 2)
+
+	bobCandidate, bobCommitSig, err := bobChan.SignSpliceCommitment(
+		spliceTx, false,
+	)
+	require.NoError(t, err)
+	require.NoError(t, bobChan.ReceiveSpliceCommitSig(
+		bobCandidate, aliceCommitSig.CommitSig,
+	))
+
+	// Once Alice has Bob's signature, she stores the splice candidate.
+	waitDone(sendToAlice(&lnwire.CommitSig{
+		ChanID:    chanID,
+		CommitSig: bobCommitSig,
+	}))
+
+	candidates, err := aliceChan.State().SpliceCandidates()
+	require.NoError(t, err)
+	require.Len(t, candidates, 1)
+	require.Equal(
+		t, bobCandidate.FundingOutpoint, candidates[0].FundingOutpoint,
+	)
+
+	// As the acceptor, Bob signs the shared input first. Alice answers
+	// with her signature and publishes the splice transaction.
+	bobInputSig, err := bobChan.SignSpliceInput(spliceTx)
+	require.NoError(t, err)
+
+	done = sendToAlice(&lnwire.TxSignatures{
+		ChanID:         chanID,
+		TxHash:         spliceTx.TxHash(),
+		SharedInputSig: (*lnwire.SharedInputSig)(&bobInputSig),
+	})
+
+	msg = nextMsg()
+	txSigs, ok := msg.(*lnwire.TxSignatures)
+	require.True(t, ok, "expected TxSignatures, got %T", msg)
+	require.NotNil(t, txSigs.SharedInputSig)
+	require.Equal(t, spliceTx.TxHash(), txSigs.TxHash)
+
+	var publishedTx *wire.MsgTx
+	select {
+	case publishedTx = <-broadcastTxChan:
+	case <-time.After(timeout):
+		t.Fatalf("splice tx not published")
+	}
+	waitDone(done)
+
+	require.Equal(t, spliceTx.TxHash(), publishedTx.TxHash())
+	require.NoError(t, bobChan.AddSpliceInputWitness(
+		spliceTx, bobInputSig, lnwire.Sig(*txSigs.SharedInputSig),
+	))
+
+	select {
+	case result := <-resultChan:
+		require.NoError(t, result.err)
+		require.Equal(t, spliceTx.TxHash(), *result.txid)
+
+	case <-time.After(timeout):
+		t.Fatalf("splice not completed")
+	}
+
+	// The channel stays frozen until the splice is locked in.
+	require.True(t, aliceChan.IsSplicing())
+}
@@ -0,0 +1,92 @@
+package peer
+
+import (
+	"errors"
+
+	"github.com/brsuite/broln/channeldb"
+	"github.com/brsuite/broln/lnwire"
+)
+
+// providesStorage returns true if we signal support for storing backups on
+// behalf of our peers.
+func (p *Brontide) providesStorage() bool {
+	return p.cfg.Features.HasFeature(lnwire.ProvideStorageOptional)
+}
+
+// hasChannels returns true if we have at least one channel with the peer,
+// pending ones included.
+func (p *Brontide) hasChannels() bool {
+	p.activeChanMtx.RLock()
+	defer p.activeChanMtx.RUnlock()
+
+	return len(p.activeChannels) > 0
+}
+
+// handlePeerStorage stores the blob the peer asked us to keep on its behalf.
+// As the blob takes up space on our side, we only store it for peers we have
+// a channel with.
+func (p *Brontide) handlePeerStorage(msg *lnwire.PeerStorage) {
+	if !p.providesStorage() {
+		peerLog.Debugf("Ignoring peer storage from %v, feature not "+
+			"enabled", p)
+		return
+	}
+
+	if !p.hasChannels() {
+		peerLog.Debugf("Ignoring peer storage from %v, no channels "+
+			"with peer", p)
+		return
+	}
+
+	err := p.cfg.ChannelDB.PutPeerStorage(p.PubKey(), msg.Blob)
+	if err != nil {
+		peerLog.Errorf("Unable to store peer storage of %v: %v", p,
+			err)
+		return
+	}
+
+	peerLog.Debugf("Stored %v byte blob on behalf of %v", len(msg.Blob),
+		p)
+}
+
+// sendYourPeerStorage hands the peer back the latest blob it asked us to
+// store, if any. This is done each time the peer connects, so a peer that
+// lost its data is able to recover it.
+func (p *Brontide) sendYourPeerStorage() {
+	if !p.providesStorage() {
+		return
+	}
+
+	blob, err := p.cfg.ChannelDB.FetchPeerStorage(p.PubKey())
+	switch {
+	case errors.Is(err, channeldb.ErrNoPeerStorage):
+		return
+
+	case err != nil:
+		peerLog.Errorf("Unable to fetch peer storage of %v: %v", p,
+			err)
+		return
+	}
+
+	peerLog.Debugf("Handing %v byte blob back to %v", len(blob), p)
+
+	err = p.SendMessageLazy(false, &lnwire.YourPeerStorage{
+		Blob: blob,
+	})
+	if err != nil {
+		peerLog.Errorf("Unable to send peer storage to %v: %v", p,
+			err)
+	}
+}
+
+// handleYourPeerStorage passes the backup the peer stored on our behalf on to
+// be restored.
+func (p *Brontide) handleYourPeerStorage(msg *lnwire.YourPeerStorage) {
+	// Peers may hand us back a backup even if we no longer ask them to
+	// store one, which isn't an error.
+	if p.cfg.HandlePeerBackup == nil {
+		return
+	}
+
+	p.cfg.HandlePeerBackup(p.PubKey(), msg.Blob)
+}
@@ -0,0 +1,269 @@
+package peer
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/brsuite/broln/chainntnfs"
+	"github.com/brsuite/broln/htlcswitch"
+	"github.com/brsuite/broln/lnwallet"
+	"github.com/brsuite/broln/lnwallet/chancloser"
+	"github.com/brsuite/broln/lnwire"
+)
+
+// supportsRbfClose returns true if both we and the remote peer support
+// cooperative closes with replaceable closing transactions.
+func (p *Brontide) supportsRbfClose() bool {
+	return p.remoteFeatures != nil &&
+		p.remoteFeatures.HasFeature(lnwire.SimpleCloseOptional) &&
+		p.cfg.Features.HasFeature(lnwire.SimpleCloseOptional)
+}
+
+// isRbfClose returns true if the passed closing message should be handled by
+// the RBF closer of its channel.
+func (p *Brontide) isRbfClose(msg *closeMsg) bool {
+	switch msg.msg.(type) {
+	case *lnwire.ClosingComplete, *lnwire.ClosingSig:
+		return true
+	}
+
+	if _, ok := p.activeRbfCloses[msg.cid]; ok {
+		return true
+	}
+	if _, ok := p.activeChanCloses[msg.cid]; ok {
+		return false
+	}
+
+	return p.supportsRbfClose()
+}
+
+// startRbfClose creates a new RBF closer for a channel we want to close, and
+// sends our shutdown message to the remote peer.
+func (p *Brontide) startRbfClose(channel *lnwallet.LightningChannel,
+	deliveryScript []byte, startingHeight uint32,
+	req *htlcswitch.ChanClose) {
+
+	chanID := lnwire.NewChanIDFromOutPoint(channel.ChannelPoint())
+
+	chanCloser := chancloser.NewRbfChanCloser(
+		p.chanCloseCfg(channel), deliveryScript, req.TargetFeePerKw,
+		startingHeight, req, true,
+	)
+	p.activeRbfCloses[chanID] = chanCloser
+
+	shutdownMsg, err := chanCloser.ShutdownChan()
+	if err != nil {
+		peerLog.Errorf(err.Error())
+		req.Err <- err
+		delete(p.activeRbfCloses, chanID)
+
+		// As we were unable to shutdown the channel, we'll return it
+		// back to its normal state.
+		channel.ResetState()
+		return
+	}
+
+	p.queueMsg(shutdownMsg, nil)
+}
+
+// bumpRbfClose handles a local request to close a channel that is already
+// being closed with replaceable closing transactions, by proposing a new
+// closing transaction at the requested fee rate.
+func (p *Brontide) bumpRbfClose(chanCloser *chancloser.RbfChanCloser,
+	req *htlcswitch.ChanClose) {
+
+	// The delivery script was already sent to the remote peer, so it can't
+	// be changed anymore.
+	if len(req.DeliveryScript) != 0 &&
+		!bytes.Equal(req.DeliveryScript, chanCloser.DeliveryScript()) {
+
+		req.Err <- fmt.Errorf("cannot change delivery address of " +
+			"pending close")
+		return
+	}
+
+	closingComplete, err := chanCloser.BumpFee(req.TargetFeePerKw, req)
+	if err != nil {
+		peerLog.Errorf("Unable to bump fee of close of "+
+			"ChannelPoint(%v): %v", req.ChanPoint, err)
+		req.Err <- err
+		return
+	}
+
+	if closingComplete != nil {
+		p.queueMsg(closingComplete, nil)
+	}
+}
+
+// fetchActiveRbfCloser attempts to fetch the active RBF closer for the target
+// channel ID. If the channel isn't active an error is returned. Otherwise,
+// either an existing state machine will be returned, or a new one will be
+// created, as the remote peer initiates the closure.
+func (p *Brontide) fetchActiveRbfCloser(chanID lnwire.ChannelID) (
+	*chancloser.RbfChanCloser, error) {
+
+	p.activeChanMtx.RLock()
+	channel, ok := p.activeChannels[chanID]
+	p.activeChanMtx.RUnlock()
+
+	if !ok || channel == nil {
+		return nil, ErrChannelNotFound
+	}
+
+	if chanCloser, ok := p.activeRbfCloses[chanID]; ok {
+		return chanCloser, nil
+	}
+
+	if err := p.tryLinkShutdown(chanID); err != nil {
+		peerLog.Errorf("failed link shutdown: %v", err)
+		return nil, err
+	}
+
+	deliveryScript := channel.LocalUpfrontShutdownScript()
+	if len(deliveryScript) == 0 {
+		var err error
+		deliveryScript, err = p.genDeliveryScript()
+		if err != nil {
+			peerLog.Errorf("unable to gen delivery script: %v", err)
+			return nil, fmt.Errorf("close addr unavailable")
+		}
+	}
+
+	_, startingHeight, err := p.cfg.ChainIO.GetBestBlock()
+	if err != nil {
+		peerLog.Errorf("unable to obtain best block: %v", err)
+		return nil, fmt.Errorf("cannot obtain best block")
+	}
+
+	// As the remote peer initiated the closure, it'll propose the closing
+	// transaction, so we don't need a fee rate until we're asked to bump
+	// the fee.
+	chanCloser := chancloser.NewRbfChanCloser(
+		p.chanCloseCfg(channel), deliveryScript, 0,
+		uint32(startingHeight), nil, false,
+	)
+	p.activeRbfCloses[chanID] = chanCloser
+
+	return chanCloser, nil
+}
+
+// handleRbfCloseMsg advances the RBF closer of a channel with a closing
+// message received from the remote peer.
+func (p *Brontide) handleRbfCloseMsg(msg *closeMsg) {
+	chanCloser, err := p.fetchActiveRbfCloser(msg.cid)
+	if err != nil {
+		// If the channel is not known to us, we'll simply ignore this
+		// message.
+		if err == ErrChannelNotFound {
+			return
+		}
+
+		peerLog.Errorf("Unable to respond to remote close msg: %v", err)
+
+		errMsg := &lnwire.Error{
+			ChanID: msg.cid,
+			Data:   lnwire.ErrorData(err.Error()),
+		}
+		p.queueMsg(errMsg, nil)
+		return
+	}
+
+	hadClosingTx := chanCloser.ClosingTx() != nil
+
+	msgs, broadcast, err := chanCloser.ProcessCloseMsg(msg.msg)
+	if err != nil {
+		err := fmt.Errorf("unable to process close msg: %v", err)
+		peerLog.Error(err)
+
+		// If a closing transaction was already broadcast, we'll keep
+		// the state machine around, so the closing transaction can
+		// still be replaced.
+		if hadClosingTx {
+			return
+		}
+
+		// Otherwise, we'll reset the channel state machine to ensure
+		// we act to on-chain events as normal.
+		chanCloser.Channel().ResetState()
+
+		for _, req := range chanCloser.CloseRequests() {
+			req.Err <- err
+		}
+		delete(p.activeRbfCloses, msg.cid)
+		return
+	}
+
+	for _, msg := range msgs {
+		p.queueMsg(msg, nil)
+	}
+
+	if !broadcast {
+		return
+	}
+
+	// Notify the local requests about the closing transaction that was
+	// just broadcast.
+	closingTxid := chanCloser.ClosingTx().TxHash()
+	for _, req := range chanCloser.UnnotifiedRequests() {
+		req.Updates <- &PendingUpdate{
+			Txid: closingTxid[:],
+		}
+	}
+
+	// Once the first closing transaction is broadcast, we'll wait for
+	// the funding output to be spent by any of them.
+	if !hadClosingTx {
+		p.wg.Add(1)
+		go p.waitForRbfClose(chanCloser)
+	}
+}
+
+// waitForRbfClose waits for the funding output of a channel being closed with
+// replaceable closing transactions to be spent. Once spent, all local close
+// requests are notified and the channel is removed from all indexes.
+//
+// NOTE: This MUST be run as a goroutine.
+func (p *Brontide) waitForRbfClose(chanCloser *chancloser.RbfChanCloser) {
+	defer p.wg.Done()
+
+	lnChan := chanCloser.Channel()
+	chanPoint := *lnChan.ChannelPoint()
+	fundingScript := lnChan.FundingOutput().PkScript
+
+	spendNtfn, err := p.cfg.ChainNotifier.RegisterSpendNtfn(
+		&chanPoint, fundingScript, chanCloser.NegotiationHeight(),
+	)
+	if err != nil {
+		peerLog.Errorf("Unable to register for spend of "+
+			"ChannelPoint(%v): %v", chanPoint, err)
+		for _, req := range chanCloser.CloseRequests() {
+			req.Err <- err
+		}
+		return
+	}
+	defer spendNtfn.Cancel()
+
+	var spend *chainntnfs.SpendDetail
+	select {
+	case s, ok := <-spendNtfn.Spend:
+		if !ok {
+			return
+		}
+		spend = s
+
+	case <-p.quit:
+		return
+	}
+
+	peerLog.Infof("ChannelPoint(%v) is now closed by tx %v at height %v",
+		chanPoint, spend.SpenderTxHash, spend.SpendingHeight)
+
+	for _, req := range chanCloser.CloseRequests() {
+		req.Updates <- &ChannelCloseUpdate{
+			ClosingTxid: spend.SpenderTxHash[:],
+			Success:     true,
+		}
+	}
+
+	p.WipeChannel(&chanPoint)
+}
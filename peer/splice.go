@@ -0,0 +1,1568 @@
+package peer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/brsuite/brond/chaincfg/chainhash"
+	"github.com/brsuite/brond/wire"
+	"github.com/brsuite/bronutil"
+	"github.com/brsuite/broln/chainntnfs"
+	"github.com/brsuite/broln/channeldb"
+	"github.com/brsuite/broln/htlcswitch"
+	"github.com/brsuite/broln/input"
+	"github.com/brsuite/broln/labels"
+	"github.com/brsuite/broln/lnpeer"
+	"github.com/brsuite/broln/lnwallet"
+	"github.com/brsuite/broln/lnwallet/chainfee"
+	"github.com/brsuite/broln/lnwallet/chanfunding"
+	"github.com/brsuite/broln/lnwire"
+)
+
+var (
+	// ErrSpliceInProgress is returned when a splice is requested for a
+	// channel that is already negotiating one.
+	ErrSpliceInProgress = errors.New("splice already in progress")
+
+	// ErrSpliceNotSupported is returned when a splice is requested, but
+	// either we or the remote peer don't support splicing.
+	ErrSpliceNotSupported = errors.New("splicing not supported")
+
+	// ErrSpliceAnnouncedChannel is returned when a splice is requested for
+	// an announced channel. The channel would need to be announced again
+	// at its new funding outpoint, while its old short channel ID is kept
+	// alive until the splice is deep enough, which isn't supported. Only
+	// private channels can be spliced.
+	ErrSpliceAnnouncedChannel = errors.New("splicing announced channels " +
+		"is not supported")
+
+	// ErrSpliceZeroConfChannel is returned when a splice is requested for
+	// a zero-conf channel. Its splice transaction would need to be used
+	// before it confirmed, which isn't supported.
+	ErrSpliceZeroConfChannel = errors.New("splicing zero-conf channels " +
+		"is not supported")
+)
+
+// SpliceRequest is a request to splice funds into or out of an existing
+// channel with the peer.
+type SpliceRequest struct {
+	// ChanPoint is the current funding outpoint of the channel.
+	ChanPoint wire.OutPoint
+
+	// SpliceIn is the amount our wallet adds to the channel.
+	SpliceIn bronutil.Amount
+
+	// SpliceOut is the amount we remove from the channel, paid to
+	// SpliceOutScript.
+	SpliceOut bronutil.Amount
+
+	// SpliceOutScript is the output script the spliced out funds are
+	// sent to.
+	SpliceOutScript []byte
+
+	// FeeRate is the fee rate of the splice transaction. It must be
+	// higher than the fee rate of any unconfirmed splice of the channel,
+	// which is replaced by fee.
+	FeeRate chainfee.SatPerKWeight
+}
+
+// spliceNegotiation tracks the construction of a splice transaction with the
+// remote peer. It lives until the splice transaction has been signed by both
+// parties, or the negotiation failed.
+type spliceNegotiation struct {
+	// lnChan is the channel being spliced.
+	lnChan *lnwallet.LightningChannel
+
+	// initiator is true if we requested the splice.
+	initiator bool
+
+	// feeRate is the fee rate of the splice transaction.
+	feeRate chainfee.SatPerKWeight
+
+	// lockTime is the lock time of the splice transaction.
+	lockTime uint32
+
+	// capacity is the capacity of the channel after the splice.
+	capacity bronutil.Amount
+
+	// spliceOut is the output paying the spliced out funds, if any.
+	spliceOut *wire.TxOut
+
+	// intent holds the coins of our wallet added to the splice
+	// transaction. It's nil if we don't add any.
+	intent *chanfunding.InteractiveIntent
+
+	// session is the interactive construction of the splice transaction.
+	session *chanfunding.InteractiveTx
+
+	// pendingMsgs are the messages announcing our inputs and outputs that
+	// still need to be sent to the remote peer.
+	pendingMsgs []lnwire.Message
+
+	// ourTurn is true if we're to send the next message of the
+	// interactive construction.
+	ourTurn bool
+
+	// localComplete and remoteComplete are set once the respective party
+	// sent tx_complete.
+	localComplete  bool
+	remoteComplete bool
+
+	// txComplete is set once the construction of the splice transaction
+	// is finished and we signed the remote peer's new commitment.
+	txComplete bool
+
+	// candidate is the splice candidate built from the splice
+	// transaction.
+	candidate *channeldb.SpliceCandidate
+
+	// committed is set once the candidate has been stored, after which
+	// the negotiation can no longer be aborted.
+	committed bool
+
+	// txid receives the txid of the splice transaction once it has been
+	// published. Only set if we initiated the splice.
+	txid chan chainhash.Hash
+
+	// err receives the error that caused the negotiation to fail. Only
+	// set if we initiated the splice.
+	err chan error
+}
+
+// spliceLock tracks the lock-in of a splice of a channel. Once the splice
+// transaction has enough confirmations, the channel moves over to its new
+// funding output and both parties send splice_locked. The channel is frozen
+// until both did. The lock-in state is persisted with the channel, so
+// splice_locked is sent again on every reconnect until the splice is locked
+// in.
+type spliceLock struct {
+	// lnChan is the channel being spliced, as it was loaded. It may
+	// already have moved over to its new funding output.
+	lnChan *lnwallet.LightningChannel
+
+	// oldChanPoint is the funding outpoint of the channel before the
+	// splice. The splice_locked messages are keyed by it.
+	oldChanPoint wire.OutPoint
+
+	// chanState is the state of the channel once it moved over to its
+	// new funding output.
+	chanState *channeldb.OpenChannel
+
+	// localTxid is the txid of the splice transaction we saw confirmed,
+	// and scid the position of its new funding output within the chain.
+	localTxid *chainhash.Hash
+	scid      lnwire.ShortChannelID
+
+	// remoteTxid is the txid sent in the remote peer's splice_locked.
+	remoteTxid *chainhash.Hash
+}
+
+// validateSpliceChannel returns an error if the channel can't be spliced.
+func validateSpliceChannel(c *channeldb.OpenChannel) error {
+	switch {
+	case c.IsPending:
+		return errors.New("channel is pending")
+
+	case c.IsZeroConf():
+		return ErrSpliceZeroConfChannel
+
+	case c.ChannelFlags&lnwire.FFAnnounceChannel != 0:
+		return ErrSpliceAnnouncedChannel
+	}
+
+	// A channel that moved over to the funding output of its splice can
+	// only be spliced again once the splice is locked in.
+	pending, err := c.SpliceLockPending()
+	if err != nil {
+		return err
+	}
+	if pending {
+		return ErrSpliceInProgress
+	}
+
+	return nil
+}
+
+// maxSpliceFeeRate returns the highest fee rate of the unconfirmed splices of
+// the channel, or zero if there are none. A new splice must pay a higher fee
+// rate to replace them.
+func maxSpliceFeeRate(c *channeldb.OpenChannel) (chainfee.SatPerKWeight,
+	error) {
+
+	candidates, err := c.SpliceCandidates()
+	if err != nil {
+		return 0, err
+	}
+
+	var maxFeeRate chainfee.SatPerKWeight
+	for _, candidate := range candidates {
+		feeRate := chainfee.SatPerKWeight(candidate.FeePerKw)
+		if feeRate > maxFeeRate {
+			maxFeeRate = feeRate
+		}
+	}
+
+	return maxFeeRate, nil
+}
+
+// supportsSplicing returns true if both we and the remote peer support
+// splicing.
+func (p *Brontide) supportsSplicing() bool {
+	return p.cfg.Features.HasFeature(lnwire.SpliceOptional) &&
+		p.remoteFeatures != nil &&
+		p.remoteFeatures.HasFeature(lnwire.SpliceOptional)
+}
+
+// fetchActiveChannel returns the active channel with the given channel ID, or
+// nil if it isn't known or still pending.
+func (p *Brontide) fetchActiveChannel(
+	chanID lnwire.ChannelID) *lnwallet.LightningChannel {
+
+	p.activeChanMtx.RLock()
+	defer p.activeChanMtx.RUnlock()
+
+	return p.activeChannels[chanID]
+}
+
+// SpliceChannel negotiates a splice of a channel with the peer, adding funds
+// from our wallet to it and/or removing funds from it. The call blocks until
+// the splice transaction has been published, and returns its txid. The
+// channel is frozen until the splice is locked in by both parties. Once the
+// splice transaction is deep enough, the channel is watched at its new funding
+// output even if the peer is offline. Only private channels that aren't
+// zero-conf can be spliced.
+func (p *Brontide) SpliceChannel(req *SpliceRequest) (*chainhash.Hash, error) {
+	if !p.supportsSplicing() {
+		return nil, ErrSpliceNotSupported
+	}
+
+	switch {
+	case req.SpliceIn == 0 && req.SpliceOut == 0:
+		return nil, errors.New("splice must add or remove funds")
+
+	case req.SpliceOut != 0 && len(req.SpliceOutScript) == 0:
+		return nil, errors.New("splice out requires an output script")
+	}
+
+	chanID := lnwire.NewChanIDFromOutPoint(&req.ChanPoint)
+	lnChan := p.fetchActiveChannel(chanID)
+	if lnChan == nil {
+		return nil, ErrChannelNotFound
+	}
+
+	chanState := lnChan.State()
+	if err := validateSpliceChannel(chanState); err != nil {
+		return nil, err
+	}
+
+	// An unconfirmed splice can only be replaced by one paying a higher
+	// fee rate.
+	minFeeRate, err := maxSpliceFeeRate(chanState)
+	if err != nil {
+		return nil, err
+	}
+	if req.FeeRate <= minFeeRate {
+		return nil, fmt.Errorf("fee rate %v must exceed the fee rate "+
+			"%v of the pending splice", req.FeeRate, minFeeRate)
+	}
+
+	p.spliceMtx.Lock()
+	if _, ok := p.spliceNegotiations[chanID]; ok {
+		p.spliceMtx.Unlock()
+		return nil, ErrSpliceInProgress
+	}
+
+	if err := lnChan.MarkSplicing(); err != nil {
+		p.spliceMtx.Unlock()
+		return nil, err
+	}
+
+	negotiation := &spliceNegotiation{
+		lnChan:    lnChan,
+		initiator: true,
+		feeRate:   req.FeeRate,
+		lockTime:  p.cfg.Switch.BestHeight(),
+		txid:      make(chan chainhash.Hash, 1),
+		err:       make(chan error, 1),
+	}
+	if req.SpliceOut != 0 {
+		negotiation.spliceOut = &wire.TxOut{
+			Value:    int64(req.SpliceOut),
+			PkScript: req.SpliceOutScript,
+		}
+	}
+	p.spliceNegotiations[chanID] = negotiation
+	p.spliceMtx.Unlock()
+
+	// If we add funds to the channel, we'll select the coins from our
+	// wallet, paying for the parts of the splice transaction the coin
+	// selection doesn't account for. Otherwise, the fee is taken from our
+	// channel balance.
+	oldCapacity := chanState.Capacity
+	if req.SpliceIn != 0 {
+		extraFee := lnwallet.SpliceFee(
+			req.FeeRate, req.SpliceOutScript, true,
+		)
+		negotiation.intent, err = p.cfg.Wallet.ProvisionSplice(
+			req.SpliceIn+extraFee, req.FeeRate, 1,
+			negotiation.lockTime,
+		)
+		if err != nil {
+			p.cancelSplice(chanID, negotiation, err)
+			return nil, err
+		}
+
+		negotiation.capacity = oldCapacity + req.SpliceIn -
+			req.SpliceOut
+	} else {
+		fee := lnwallet.SpliceFee(req.FeeRate, req.SpliceOutScript, false)
+		if req.SpliceOut+fee >= oldCapacity {
+			err := fmt.Errorf("splice out of %v with fee %v exceeds "+
+				"the channel capacity of %v", req.SpliceOut,
+				fee, oldCapacity)
+			p.cancelSplice(chanID, negotiation, err)
+			return nil, err
+		}
+
+		negotiation.capacity = oldCapacity - req.SpliceOut - fee
+	}
+
+	peerLog.Infof("Requesting splice of ChannelPoint(%v) with %v, "+
+		"capacity %v -> %v, fee rate %v", req.ChanPoint, p,
+		oldCapacity, negotiation.capacity, req.FeeRate)
+
+	spliceInit := &lnwire.SpliceInit{
+		ChanID: chanID,
+		FundingContribution: int64(negotiation.capacity) -
+			int64(oldCapacity),
+		FundingFeePerKWeight: uint32(req.FeeRate),
+		LockTime:             negotiation.lockTime,
+		FundingPubKey:        chanState.LocalChanCfg.MultiSigKey.PubKey,
+	}
+	if err := p.SendMessage(true, spliceInit); err != nil {
+		p.cancelSplice(chanID, negotiation, err)
+		return nil, err
+	}
+
+	select {
+	case txid := <-negotiation.txid:
+		return &txid, nil
+
+	case err := <-negotiation.err:
+		return nil, err
+
+	case <-p.quit:
+		return nil, lnpeer.ErrPeerExiting
+	}
+}
+
+// isSpliceMsg returns true if the passed message is part of the negotiation
+// of a splice.
+func (p *Brontide) isSpliceMsg(msg lnwire.Message) bool {
+	var chanID lnwire.ChannelID
+	switch msg := msg.(type) {
+	case *lnwire.SpliceInit, *lnwire.SpliceAck, *lnwire.SpliceLocked:
+		return true
+
+	case *lnwire.TxAddInput:
+		chanID = msg.ChanID
+	case *lnwire.TxAddOutput:
+		chanID = msg.ChanID
+	case *lnwire.TxRemoveInput:
+		chanID = msg.ChanID
+	case *lnwire.TxRemoveOutput:
+		chanID = msg.ChanID
+	case *lnwire.TxComplete:
+		chanID = msg.ChanID
+	case *lnwire.TxSignatures:
+		chanID = msg.ChanID
+	case *lnwire.TxAbort:
+		chanID = msg.ChanID
+	case *lnwire.CommitSig:
+		chanID = msg.ChanID
+
+	default:
+		return false
+	}
+
+	p.spliceMtx.Lock()
+	_, ok := p.spliceNegotiations[chanID]
+	p.spliceMtx.Unlock()
+
+	return ok
+}
+
+// handleSpliceMsg processes a message that is part of the negotiation or the
+// lock-in of a splice.
+func (p *Brontide) handleSpliceMsg(msg lnwire.Message) {
+	switch msg := msg.(type) {
+	case *lnwire.SpliceInit:
+		p.handleSpliceInit(msg)
+
+	case *lnwire.SpliceAck:
+		p.handleSpliceAck(msg)
+
+	case *lnwire.SpliceLocked:
+		p.handleSpliceLocked(msg)
+
+	case *lnwire.CommitSig:
+		p.handleSpliceCommitSig(msg)
+
+	case *lnwire.TxSignatures:
+		p.handleSpliceTxSignatures(msg)
+
+	case *lnwire.TxAbort:
+		negotiation := p.fetchSpliceNegotiation(msg.ChanID)
+		if negotiation == nil {
+			return
+		}
+
+		err := fmt.Errorf("splice aborted by peer: %v", msg.Error())
+		p.failSplice(msg.ChanID, negotiation, err)
+
+	default:
+		p.handleSpliceTxMsg(msg)
+	}
+}
+
+// fetchSpliceNegotiation returns the splice negotiation of the channel, or
+// nil if there's none.
+func (p *Brontide) fetchSpliceNegotiation(
+	chanID lnwire.ChannelID) *spliceNegotiation {
+
+	p.spliceMtx.Lock()
+	defer p.spliceMtx.Unlock()
+
+	return p.spliceNegotiations[chanID]
+}
+
+// maybeAbortSplice aborts the splice negotiation of the channel if the remote
+// peer sent an update for it before the splice was committed. This happens if
+// the peer sent the update before it received our splice_init, in which case
+// the channel is no longer quiescent.
+func (p *Brontide) maybeAbortSplice(chanID lnwire.ChannelID,
+	msg lnwire.Message) {
+
+	negotiation := p.fetchSpliceNegotiation(chanID)
+	if negotiation == nil || negotiation.committed {
+		return
+	}
+
+	err := fmt.Errorf("received %v during splice negotiation",
+		msg.MsgType())
+	p.failSplice(chanID, negotiation, err)
+}
+
+// cancelSplice releases the resources held by a splice negotiation, and
+// unfreezes the channel unless an earlier splice is still unconfirmed.
+func (p *Brontide) cancelSplice(chanID lnwire.ChannelID,
+	negotiation *spliceNegotiation, err error) {
+
+	p.spliceMtx.Lock()
+	delete(p.spliceNegotiations, chanID)
+	_, pendingLock := p.spliceLocks[chanID]
+	p.spliceMtx.Unlock()
+
+	if negotiation.intent != nil {
+		negotiation.intent.Cancel()
+	}
+
+	if !pendingLock {
+		negotiation.lnChan.ClearSplicing()
+	}
+
+	if negotiation.err != nil {
+		negotiation.err <- err
+	}
+}
+
+// failSplice aborts a splice negotiation, notifying the remote peer.
+func (p *Brontide) failSplice(chanID lnwire.ChannelID,
+	negotiation *spliceNegotiation, err error) {
+
+	peerLog.Errorf("Splice of ChannelID(%v) with %v failed: %v", chanID,
+		p, err)
+
+	txAbort := &lnwire.TxAbort{
+		ChanID: chanID,
+		Data:   lnwire.ErrorData(err.Error()),
+	}
+	if err := p.SendMessage(false, txAbort); err != nil {
+		peerLog.Errorf("Unable to send tx_abort: %v", err)
+	}
+
+	p.cancelSplice(chanID, negotiation, err)
+}
+
+// sendSpliceError rejects a splice request of the remote peer.
+func (p *Brontide) sendSpliceError(chanID lnwire.ChannelID, err error) {
+	peerLog.Warnf("Rejecting splice of ChannelID(%v) from %v: %v", chanID,
+		p, err)
+
+	txAbort := &lnwire.TxAbort{
+		ChanID: chanID,
+		Data:   lnwire.ErrorData(err.Error()),
+	}
+	if err := p.SendMessage(false, txAbort); err != nil {
+		peerLog.Errorf("Unable to send tx_abort: %v", err)
+	}
+}
+
+// handleSpliceInit processes the request of the remote peer to splice a
+// channel. We don't contribute to the splice ourselves, but sign it if the
+// remote peer pays for all of it.
+func (p *Brontide) handleSpliceInit(msg *lnwire.SpliceInit) {
+	if !p.supportsSplicing() {
+		p.sendSpliceError(msg.ChanID, ErrSpliceNotSupported)
+		return
+	}
+
+	lnChan := p.fetchActiveChannel(msg.ChanID)
+	if lnChan == nil {
+		p.sendSpliceError(msg.ChanID, ErrChannelNotFound)
+		return
+	}
+
+	chanState := lnChan.State()
+	if err := validateSpliceChannel(chanState); err != nil {
+		p.sendSpliceError(msg.ChanID, err)
+		return
+	}
+
+	// The splice keeps the funding keys of the channel.
+	remoteKey := chanState.RemoteChanCfg.MultiSigKey.PubKey
+	if msg.FundingPubKey == nil || !msg.FundingPubKey.IsEqual(remoteKey) {
+		p.sendSpliceError(msg.ChanID, errors.New("splice must not "+
+			"change the funding key"))
+		return
+	}
+
+	feeRate := chainfee.SatPerKWeight(msg.FundingFeePerKWeight)
+	minFeeRate, err := maxSpliceFeeRate(chanState)
+	if err != nil {
+		p.sendSpliceError(msg.ChanID, err)
+		return
+	}
+	if feeRate <= minFeeRate || feeRate < chainfee.FeePerKwFloor {
+		p.sendSpliceError(msg.ChanID, fmt.Errorf("fee rate %v too "+
+			"low", feeRate))
+		return
+	}
+
+	capacity := int64(chanState.Capacity) + msg.FundingContribution
+	if capacity <= 0 {
+		p.sendSpliceError(msg.ChanID, fmt.Errorf("invalid splice "+
+			"contribution %v", msg.FundingContribution))
+		return
+	}
+
+	p.spliceMtx.Lock()
+	if _, ok := p.spliceNegotiations[msg.ChanID]; ok {
+		p.spliceMtx.Unlock()
+		p.sendSpliceError(msg.ChanID, ErrSpliceInProgress)
+		return
+	}
+
+	if err := lnChan.MarkSplicing(); err != nil {
+		p.spliceMtx.Unlock()
+		p.sendSpliceError(msg.ChanID, err)
+		return
+	}
+
+	session := chanfunding.NewInteractiveTx(
+		false, msg.LockTime, lnwallet.DustLimitForSize(input.P2WSHSize),
+	)
+	session.SetSharedInput(*lnChan.ChanPoint, lnChan.FundingOutput())
+
+	negotiation := &spliceNegotiation{
+		lnChan:   lnChan,
+		feeRate:  feeRate,
+		lockTime: msg.LockTime,
+		capacity: bronutil.Amount(capacity),
+		session:  session,
+	}
+	p.spliceNegotiations[msg.ChanID] = negotiation
+	p.spliceMtx.Unlock()
+
+	peerLog.Infof("Accepting splice of ChannelPoint(%v) from %v, "+
+		"capacity %v -> %v, fee rate %v", lnChan.ChanPoint, p,
+		chanState.Capacity, negotiation.capacity, feeRate)
+
+	spliceAck := &lnwire.SpliceAck{
+		ChanID:        msg.ChanID,
+		FundingPubKey: chanState.LocalChanCfg.MultiSigKey.PubKey,
+	}
+	if err := p.SendMessage(true, spliceAck); err != nil {
+		p.cancelSplice(msg.ChanID, negotiation, err)
+	}
+}
+
+// handleSpliceAck processes the remote peer's acceptance of our splice
+// request, and starts the construction of the splice transaction.
+func (p *Brontide) handleSpliceAck(msg *lnwire.SpliceAck) {
+	negotiation := p.fetchSpliceNegotiation(msg.ChanID)
+	if negotiation == nil || !negotiation.initiator ||
+		negotiation.session != nil {
+
+		peerLog.Warnf("Unexpected splice_ack for ChannelID(%v) from "+
+			"%v", msg.ChanID, p)
+		return
+	}
+
+	lnChan := negotiation.lnChan
+	chanState := lnChan.State()
+	remoteKey := chanState.RemoteChanCfg.MultiSigKey.PubKey
+	if msg.FundingPubKey == nil || !msg.FundingPubKey.IsEqual(remoteKey) {
+		p.failSplice(msg.ChanID, negotiation, errors.New("splice "+
+			"must not change the funding key"))
+		return
+	}
+
+	// We don't support the remote peer adding funds to our splice.
+	if msg.FundingContribution != 0 {
+		p.failSplice(msg.ChanID, negotiation, fmt.Errorf("unexpected "+
+			"splice contribution %v", msg.FundingContribution))
+		return
+	}
+
+	// With the splice accepted, we'll add the funding output of the
+	// channel, our coins and change, the new funding output and the
+	// spliced out funds to the splice transaction.
+	var session *chanfunding.InteractiveTx
+	if negotiation.intent != nil {
+		session = negotiation.intent.Session()
+	} else {
+		session = chanfunding.NewInteractiveTx(
+			true, negotiation.lockTime,
+			lnwallet.DustLimitForSize(input.P2WSHSize),
+		)
+	}
+	session.SetSharedInput(*lnChan.ChanPoint, lnChan.FundingOutput())
+
+	err := p.addLocalSpliceContribution(negotiation, session)
+	if err != nil {
+		p.failSplice(msg.ChanID, negotiation, err)
+		return
+	}
+
+	p.startSpliceSession(msg.ChanID, negotiation, session)
+
+	negotiation.ourTurn = true
+	p.sendNextSpliceMsg(msg.ChanID, negotiation)
+}
+
+// addLocalSpliceContribution adds the inputs and outputs of the initiator to
+// the splice transaction.
+func (p *Brontide) addLocalSpliceContribution(negotiation *spliceNegotiation,
+	session *chanfunding.InteractiveTx) error {
+
+	if _, err := session.AddLocalSharedInput(); err != nil {
+		return err
+	}
+
+	if negotiation.intent != nil {
+		if err := negotiation.intent.AddLocalInputs(); err != nil {
+			return err
+		}
+	}
+
+	fundingOutput := negotiation.lnChan.FundingOutput()
+	_, err := session.AddLocalOutput(&wire.TxOut{
+		Value:    int64(negotiation.capacity),
+		PkScript: fundingOutput.PkScript,
+	})
+	if err != nil {
+		return err
+	}
+
+	if negotiation.spliceOut != nil {
+		_, err := session.AddLocalOutput(negotiation.spliceOut)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// startSpliceSession records the interactive construction of the splice
+// transaction, and queues the messages announcing our inputs and outputs.
+func (p *Brontide) startSpliceSession(chanID lnwire.ChannelID,
+	negotiation *spliceNegotiation, session *chanfunding.InteractiveTx) {
+
+	negotiation.session = session
+
+	for _, in := range session.Inputs() {
+		if !in.Local {
+			continue
+		}
+
+		addInput := &lnwire.TxAddInput{
+			ChanID:    chanID,
+			SerialID:  in.SerialID,
+			PrevTx:    in.PrevTx,
+			PrevTxOut: in.PrevTxOut,
+			Sequence:  in.Sequence,
+		}
+
+		// Both parties know the funding transaction of the channel,
+		// so only its txid is sent for the shared input.
+		if in.IsShared() {
+			outPoint := in.OutPoint()
+			txid := lnwire.SharedInputTxid(outPoint.Hash)
+			addInput.PrevTx = nil
+			addInput.PrevTxOut = outPoint.Index
+			addInput.SharedInputTxid = &txid
+		}
+
+		negotiation.pendingMsgs = append(
+			negotiation.pendingMsgs, addInput,
+		)
+	}
+
+	for _, out := range session.Outputs() {
+		if !out.Local {
+			continue
+		}
+
+		negotiation.pendingMsgs = append(
+			negotiation.pendingMsgs, &lnwire.TxAddOutput{
+				ChanID:   chanID,
+				SerialID: out.SerialID,
+				Amount:   bronutil.Amount(out.TxOut.Value),
+				PkScript: out.TxOut.PkScript,
+			},
+		)
+	}
+}
+
+// handleSpliceTxMsg processes a message of the interactive construction of
+// the splice transaction.
+func (p *Brontide) handleSpliceTxMsg(msg lnwire.Message) {
+	var chanID lnwire.ChannelID
+	switch msg := msg.(type) {
+	case *lnwire.TxAddInput:
+		chanID = msg.ChanID
+	case *lnwire.TxAddOutput:
+		chanID = msg.ChanID
+	case *lnwire.TxRemoveInput:
+		chanID = msg.ChanID
+	case *lnwire.TxRemoveOutput:
+		chanID = msg.ChanID
+	case *lnwire.TxComplete:
+		chanID = msg.ChanID
+	}
+
+	negotiation := p.fetchSpliceNegotiation(chanID)
+	if negotiation == nil || negotiation.session == nil {
+		peerLog.Warnf("Unexpected %v for ChannelID(%v) from %v",
+			msg.MsgType(), chanID, p)
+		return
+	}
+
+	if negotiation.ourTurn || negotiation.txComplete {
+		err := fmt.Errorf("unexpected %v message", msg.MsgType())
+		p.failSplice(chanID, negotiation, err)
+		return
+	}
+
+	var (
+		session = negotiation.session
+		err     error
+	)
+	switch msg := msg.(type) {
+	case *lnwire.TxAddInput:
+		if msg.SharedInputTxid != nil {
+			err = session.AddRemoteSharedInput(
+				msg.SerialID, chainhash.Hash(*msg.SharedInputTxid),
+				msg.PrevTxOut, msg.Sequence,
+			)
+			break
+		}
+
+		err = session.AddRemoteInput(
+			msg.SerialID, msg.PrevTx, msg.PrevTxOut, msg.Sequence,
+		)
+
+	case *lnwire.TxAddOutput:
+		err = session.AddRemoteOutput(
+			msg.SerialID, msg.Amount, msg.PkScript,
+		)
+
+	case *lnwire.TxRemoveInput:
+		err = session.RemoveRemoteInput(msg.SerialID)
+
+	case *lnwire.TxRemoveOutput:
+		err = session.RemoveRemoteOutput(msg.SerialID)
+
+	case *lnwire.TxComplete:
+		negotiation.remoteComplete = true
+	}
+	if err != nil {
+		p.failSplice(chanID, negotiation, err)
+		return
+	}
+
+	// Any change to the transaction requires both parties to complete the
+	// construction again.
+	if msg.MsgType() != lnwire.MsgTxComplete {
+		negotiation.localComplete = false
+		negotiation.remoteComplete = false
+	}
+
+	if negotiation.localComplete && negotiation.remoteComplete {
+		p.finishSpliceTx(chanID, negotiation)
+		return
+	}
+
+	negotiation.ourTurn = true
+	p.sendNextSpliceMsg(chanID, negotiation)
+}
+
+// sendNextSpliceMsg announces our next input or output to the remote peer, or
+// signals that we completed our contribution if there's nothing left to
+// announce.
+func (p *Brontide) sendNextSpliceMsg(chanID lnwire.ChannelID,
+	negotiation *spliceNegotiation) {
+
+	var next lnwire.Message
+	if len(negotiation.pendingMsgs) > 0 {
+		next = negotiation.pendingMsgs[0]
+		negotiation.pendingMsgs = negotiation.pendingMsgs[1:]
+
+		negotiation.localComplete = false
+		negotiation.remoteComplete = false
+	} else {
+		next = &lnwire.TxComplete{ChanID: chanID}
+		negotiation.localComplete = true
+	}
+
+	if err := p.SendMessage(true, next); err != nil {
+		p.failSplice(chanID, negotiation, err)
+		return
+	}
+	negotiation.ourTurn = false
+
+	if negotiation.localComplete && negotiation.remoteComplete {
+		p.finishSpliceTx(chanID, negotiation)
+	}
+}
+
+// finishSpliceTx is called once both parties completed the construction of
+// the splice transaction. It verifies the transaction, then signs the remote
+// peer's commitment spending the new funding output.
+func (p *Brontide) finishSpliceTx(chanID lnwire.ChannelID,
+	negotiation *spliceNegotiation) {
+
+	lnChan := negotiation.lnChan
+	session := negotiation.session
+	spliceTx := session.Tx()
+
+	if session.SharedInput() == nil {
+		p.failSplice(chanID, negotiation, errors.New("splice tx "+
+			"doesn't spend the funding output"))
+		return
+	}
+
+	// The splice transaction must create exactly one new funding output,
+	// with the capacity announced in splice_init.
+	fundingScript := lnChan.FundingOutput().PkScript
+	var numFundingOutputs int
+	for _, txOut := range spliceTx.TxOut {
+		if !bytes.Equal(txOut.PkScript, fundingScript) {
+			continue
+		}
+
+		numFundingOutputs++
+		if txOut.Value != int64(negotiation.capacity) {
+			p.failSplice(chanID, negotiation, fmt.Errorf("new "+
+				"funding output has value %v, expected %v",
+				txOut.Value, negotiation.capacity))
+			return
+		}
+	}
+	if numFundingOutputs != 1 {
+		p.failSplice(chanID, negotiation, fmt.Errorf("splice tx has "+
+			"%d funding outputs", numFundingOutputs))
+		return
+	}
+
+	// The initiator pays for the whole splice. If the remote peer is the
+	// initiator, its contribution covers the new funding output.
+	var remoteFundingAmt bronutil.Amount
+	if !negotiation.initiator {
+		remoteFundingAmt = negotiation.capacity
+	}
+	err := session.VerifyRemoteContribution(
+		negotiation.feeRate, fundingScript, remoteFundingAmt,
+	)
+	if err != nil {
+		p.failSplice(chanID, negotiation, err)
+		return
+	}
+
+	candidate, commitSig, err := lnChan.SignSpliceCommitment(
+		spliceTx, negotiation.initiator,
+	)
+	if err != nil {
+		p.failSplice(chanID, negotiation, err)
+		return
+	}
+	candidate.FeePerKw = bronutil.Amount(negotiation.feeRate)
+
+	negotiation.candidate = candidate
+	negotiation.txComplete = true
+
+	peerLog.Infof("Splice tx %v for ChannelPoint(%v) complete, sending "+
+		"CommitSig", spliceTx.TxHash(), lnChan.ChanPoint)
+
+	msg := &lnwire.CommitSig{
+		ChanID:    chanID,
+		CommitSig: commitSig,
+	}
+	if err := p.SendMessage(true, msg); err != nil {
+		p.failSplice(chanID, negotiation, err)
+	}
+}
+
+// handleSpliceCommitSig processes the remote peer's signature for our
+// commitment spending the new funding output. Once verified, the splice
+// candidate is stored, and the signatures for the splice transaction are
+// exchanged.
+func (p *Brontide) handleSpliceCommitSig(msg *lnwire.CommitSig) {
+	negotiation := p.fetchSpliceNegotiation(msg.ChanID)
+	if negotiation == nil {
+		return
+	}
+
+	if !negotiation.txComplete || negotiation.committed {
+		p.failSplice(msg.ChanID, negotiation, errors.New("unexpected "+
+			"commitment signature"))
+		return
+	}
+
+	lnChan := negotiation.lnChan
+	err := lnChan.ReceiveSpliceCommitSig(
+		negotiation.candidate, msg.CommitSig,
+	)
+	if err != nil {
+		p.failSplice(msg.ChanID, negotiation, err)
+		return
+	}
+
+	// Once stored, we're able to force close the channel using any of
+	// the splice candidates, so the splice can no longer be aborted.
+	err = lnChan.State().AddSpliceCandidate(negotiation.candidate)
+	if err != nil {
+		p.failSplice(msg.ChanID, negotiation, err)
+		return
+	}
+	negotiation.committed = true
+
+	p.startSpliceLock(lnChan, *lnChan.ChanPoint)
+
+	// As the acceptor, we don't add any coins to the splice, so we sign
+	// first.
+	if negotiation.initiator {
+		return
+	}
+
+	sharedSig, err := lnChan.SignSpliceInput(negotiation.candidate.SpliceTx)
+	if err != nil {
+		p.failSplice(msg.ChanID, negotiation, err)
+		return
+	}
+
+	txSigs := &lnwire.TxSignatures{
+		ChanID:         msg.ChanID,
+		TxHash:         negotiation.candidate.SpliceTx.TxHash(),
+		SharedInputSig: (*lnwire.SharedInputSig)(&sharedSig),
+	}
+	if err := p.SendMessage(true, txSigs); err != nil {
+		p.failSplice(msg.ChanID, negotiation, err)
+	}
+}
+
+// handleSpliceTxSignatures processes the remote peer's signatures for the
+// splice transaction. Once the transaction is fully signed, we send our own
+// signatures if we haven't yet, and publish it.
+func (p *Brontide) handleSpliceTxSignatures(msg *lnwire.TxSignatures) {
+	negotiation := p.fetchSpliceNegotiation(msg.ChanID)
+	if negotiation == nil {
+		return
+	}
+
+	if !negotiation.committed {
+		p.failSplice(msg.ChanID, negotiation, errors.New("unexpected "+
+			"tx_signatures"))
+		return
+	}
+
+	candidate := negotiation.candidate
+	spliceTx := candidate.SpliceTx.Copy()
+	if msg.TxHash != spliceTx.TxHash() || msg.SharedInputSig == nil {
+		p.failSplice(msg.ChanID, negotiation, fmt.Errorf("invalid "+
+			"tx_signatures for splice tx %v", spliceTx.TxHash()))
+		return
+	}
+
+	// We'll attach the remote peer's witnesses, sign our own inputs and
+	// construct the witness of the shared input.
+	inputs := negotiation.session.Inputs()
+	var remoteIdxs []int
+	for idx, in := range inputs {
+		if !in.Local && !in.IsShared() {
+			remoteIdxs = append(remoteIdxs, idx)
+		}
+	}
+	if len(remoteIdxs) != len(msg.Witnesses) {
+		p.failSplice(msg.ChanID, negotiation, fmt.Errorf("expected %v "+
+			"witnesses for splice tx %v, got %v", len(remoteIdxs),
+			spliceTx.TxHash(), len(msg.Witnesses)))
+		return
+	}
+	for i, idx := range remoteIdxs {
+		spliceTx.TxIn[idx].Witness = msg.Witnesses[i]
+	}
+
+	if negotiation.intent != nil {
+		err := negotiation.intent.SignLocalInputs(spliceTx)
+		if err != nil {
+			p.failSplice(msg.ChanID, negotiation, err)
+			return
+		}
+	}
+
+	lnChan := negotiation.lnChan
+	sharedSig, err := lnChan.SignSpliceInput(spliceTx)
+	if err != nil {
+		p.failSplice(msg.ChanID, negotiation, err)
+		return
+	}
+	err = lnChan.AddSpliceInputWitness(
+		spliceTx, sharedSig, lnwire.Sig(*msg.SharedInputSig),
+	)
+	if err != nil {
+		p.failSplice(msg.ChanID, negotiation, err)
+		return
+	}
+
+	if err := negotiation.session.VerifyWitnesses(spliceTx); err != nil {
+		p.failSplice(msg.ChanID, negotiation, err)
+		return
+	}
+
+	// We'll store the fully signed transaction, so it can be published
+	// again if needed.
+	candidate.SpliceTx = spliceTx
+	if err := lnChan.State().AddSpliceCandidate(candidate); err != nil {
+		p.failSplice(msg.ChanID, negotiation, err)
+		return
+	}
+
+	p.spliceMtx.Lock()
+	delete(p.spliceNegotiations, msg.ChanID)
+	p.spliceMtx.Unlock()
+
+	if negotiation.initiator {
+		var witnesses []wire.TxWitness
+		for idx, in := range inputs {
+			if in.Local && !in.IsShared() {
+				witnesses = append(
+					witnesses, spliceTx.TxIn[idx].Witness,
+				)
+			}
+		}
+
+		txSigs := &lnwire.TxSignatures{
+			ChanID:         msg.ChanID,
+			TxHash:         spliceTx.TxHash(),
+			Witnesses:      witnesses,
+			SharedInputSig: (*lnwire.SharedInputSig)(&sharedSig),
+		}
+		if err := p.SendMessage(true, txSigs); err != nil {
+			peerLog.Errorf("Unable to send tx_signatures: %v", err)
+		}
+	}
+
+	peerLog.Infof("Publishing splice tx %v for ChannelPoint(%v)",
+		spliceTx.TxHash(), lnChan.ChanPoint)
+
+	label := labels.MakeLabel(labels.LabelTypeChannelSplice, nil)
+	err = p.cfg.Wallet.PublishTransaction(spliceTx, label)
+	if err != nil {
+		peerLog.Errorf("Unable to publish splice tx %v: %v",
+			spliceTx.TxHash(), err)
+	}
+
+	if negotiation.txid != nil {
+		negotiation.txid <- spliceTx.TxHash()
+	}
+}
+
+// resumeSplice freezes a channel with unconfirmed splices, or one that moved
+// over to the funding output of its splice which isn't locked in yet, and
+// resumes the lock-in. It's called when the channel is loaded.
+func (p *Brontide) resumeSplice(lnChan *lnwallet.LightningChannel) error {
+	chanState := lnChan.State()
+
+	candidates, err := chanState.SpliceCandidates()
+	if err != nil {
+		return err
+	}
+
+	// If the splice transaction already confirmed, the splice_locked
+	// messages are keyed by the funding outpoint the channel was spliced
+	// from.
+	oldChanPoint := *lnChan.ChanPoint
+	if len(candidates) == 0 {
+		pending, err := chanState.SpliceLockPending()
+		if err != nil || !pending {
+			return err
+		}
+
+		splicedFrom, err := chanState.SplicedFrom()
+		if err != nil {
+			return err
+		}
+		if splicedFrom == nil {
+			return fmt.Errorf("spliced channel %v has no previous "+
+				"funding outpoint", lnChan.ChanPoint)
+		}
+		oldChanPoint = *splicedFrom
+	}
+
+	if err := lnChan.MarkSplicing(); err != nil {
+		return err
+	}
+
+	peerLog.Infof("Resuming splice of ChannelPoint(%v) with %d "+
+		"unconfirmed candidate(s)", lnChan.ChanPoint, len(candidates))
+
+	p.startSpliceLock(lnChan, oldChanPoint)
+
+	return nil
+}
+
+// startSpliceLock launches a goroutine that waits for the splice of the
+// channel to confirm and be locked in, unless there's one already.
+func (p *Brontide) startSpliceLock(lnChan *lnwallet.LightningChannel,
+	oldChanPoint wire.OutPoint) {
+
+	chanID := lnwire.NewChanIDFromOutPoint(&oldChanPoint)
+
+	p.spliceMtx.Lock()
+	defer p.spliceMtx.Unlock()
+
+	if _, ok := p.spliceLocks[chanID]; ok {
+		return
+	}
+
+	lock := &spliceLock{
+		lnChan:       lnChan,
+		oldChanPoint: oldChanPoint,
+	}
+
+	if err := p.storeSpliceEdge(lnChan, oldChanPoint); err != nil {
+		peerLog.Errorf("Unable to snapshot edge of ChannelPoint(%v): "+
+			"%v", oldChanPoint, err)
+	}
+
+	p.spliceLocks[chanID] = lock
+
+	p.wg.Add(1)
+	go p.waitForSpliceConf(chanID, lock)
+}
+
+// storeSpliceEdge stores a snapshot of the channel's edge with the channel,
+// so it can be restored at the new funding outpoint once the splice is locked
+// in. The router prunes the edge once the old funding output is spent, so the
+// snapshot is only taken while the channel is at its old funding outpoint.
+func (p *Brontide) storeSpliceEdge(lnChan *lnwallet.LightningChannel,
+	oldChanPoint wire.OutPoint) error {
+
+	if *lnChan.ChanPoint != oldChanPoint {
+		return nil
+	}
+
+	chanState := lnChan.State()
+	edge, err := chanState.SpliceEdge()
+	if err != nil || edge != nil {
+		return err
+	}
+
+	info, p1, p2, err := p.cfg.ChannelGraph.FetchChannelEdgesByOutpoint(
+		&oldChanPoint,
+	)
+	switch {
+	case err == channeldb.ErrEdgeNotFound:
+		return nil
+
+	case err != nil:
+		return err
+	}
+
+	return chanState.PutSpliceEdge(&channeldb.SpliceEdge{
+		Info:     info,
+		Policies: [2]*channeldb.ChannelEdgePolicy{p1, p2},
+	})
+}
+
+// waitForSpliceConf waits for the splice transaction of a channel to confirm
+// with the number of confirmations required for the channel, then moves the
+// channel over to its new funding output and sends splice_locked to the
+// remote peer.
+func (p *Brontide) waitForSpliceConf(chanID lnwire.ChannelID,
+	lock *spliceLock) {
+
+	defer p.wg.Done()
+
+	lnChan := lock.lnChan
+	chanState := lnChan.State()
+	oldChanPoint := lock.oldChanPoint
+	fundingScript := lnChan.FundingOutput().PkScript
+	heightHint := chanState.FundingBroadcastHeight
+
+	// The splice keeps the funding keys of the channel, so its new
+	// funding output is the one output of the splice transaction paying
+	// to the funding script. If the channel didn't move yet, we'll first
+	// wait for its funding output to be spent by the splice transaction.
+	newChanPoint := *lnChan.ChanPoint
+	if newChanPoint == oldChanPoint {
+		spendNtfn, err := p.cfg.ChainNotifier.RegisterSpendNtfn(
+			&oldChanPoint, fundingScript, heightHint,
+		)
+		if err != nil {
+			peerLog.Errorf("Unable to register for spend of "+
+				"ChannelPoint(%v): %v", oldChanPoint, err)
+			return
+		}
+		defer spendNtfn.Cancel()
+
+		var spend *chainntnfs.SpendDetail
+		select {
+		case s, ok := <-spendNtfn.Spend:
+			if !ok {
+				return
+			}
+			spend = s
+
+		case <-p.quit:
+			return
+		}
+
+		var found bool
+		for idx, txOut := range spend.SpendingTx.TxOut {
+			if bytes.Equal(txOut.PkScript, fundingScript) {
+				newChanPoint = wire.OutPoint{
+					Hash:  *spend.SpenderTxHash,
+					Index: uint32(idx),
+				}
+				found = true
+				break
+			}
+		}
+		if !found {
+			peerLog.Warnf("ChannelPoint(%v) spent by tx %v, which "+
+				"is not a splice", oldChanPoint,
+				spend.SpenderTxHash)
+			return
+		}
+		heightHint = uint32(spend.SpendingHeight)
+	}
+
+	// The new funding output needs the same number of confirmations as
+	// the original one.
+	numConfs := uint32(chanState.NumConfsRequired)
+	if numConfs == 0 {
+		numConfs = 1
+	}
+
+	txid := newChanPoint.Hash
+	confNtfn, err := p.cfg.ChainNotifier.RegisterConfirmationsNtfn(
+		&txid, fundingScript, numConfs, heightHint,
+	)
+	if err != nil {
+		peerLog.Errorf("Unable to register for confirmation of "+
+			"splice tx %v: %v", txid, err)
+		return
+	}
+	defer confNtfn.Cancel()
+
+	var conf *chainntnfs.TxConfirmation
+	select {
+	case c, ok := <-confNtfn.Confirmed:
+		if !ok {
+			return
+		}
+		conf = c
+
+	case <-p.quit:
+		return
+	}
+
+	peerLog.Infof("Splice tx %v of ChannelPoint(%v) confirmed at height "+
+		"%v", txid, oldChanPoint, conf.BlockHeight)
+
+	// The chain watcher of the channel moves it over to its new funding
+	// output as well, so this only returns the spliced channel if it was
+	// faster.
+	scid := lnwire.ShortChannelID{
+		BlockHeight: conf.BlockHeight,
+		TxIndex:     conf.TxIndex,
+		TxPosition:  uint16(newChanPoint.Index),
+	}
+	splicedState, err := p.cfg.ChainArb.CompleteSplice(
+		oldChanPoint, newChanPoint, scid,
+	)
+	if err != nil {
+		peerLog.Errorf("Unable to complete splice of "+
+			"ChannelPoint(%v): %v", oldChanPoint, err)
+		return
+	}
+
+	p.spliceMtx.Lock()
+	lock.chanState = splicedState
+	lock.localTxid = &txid
+	lock.scid = scid
+	locked := lock.remoteTxid != nil
+	p.spliceMtx.Unlock()
+
+	spliceLocked := &lnwire.SpliceLocked{
+		ChanID:     chanID,
+		SpliceTxid: txid,
+	}
+	if err := p.SendMessage(true, spliceLocked); err != nil {
+		peerLog.Errorf("Unable to send splice_locked: %v", err)
+		return
+	}
+
+	if locked {
+		p.completeSplice(chanID, lock)
+	}
+}
+
+// handleSpliceLocked processes the remote peer's splice_locked message. Once
+// both parties sent it, the channel is unfrozen.
+func (p *Brontide) handleSpliceLocked(msg *lnwire.SpliceLocked) {
+	p.spliceMtx.Lock()
+	lock, ok := p.spliceLocks[msg.ChanID]
+	if !ok {
+		p.spliceMtx.Unlock()
+
+		// The remote peer may not have received our splice_locked
+		// before we completed the splice, so we'll send it again.
+		p.resendSpliceLocked(msg)
+		return
+	}
+
+	lock.remoteTxid = &msg.SpliceTxid
+	locked := lock.localTxid != nil
+	p.spliceMtx.Unlock()
+
+	if !locked {
+		return
+	}
+
+	p.completeSplice(msg.ChanID, lock)
+}
+
+// resendSpliceLocked answers the splice_locked message of the remote peer for
+// a splice we already completed.
+func (p *Brontide) resendSpliceLocked(msg *lnwire.SpliceLocked) {
+	p.activeChanMtx.RLock()
+	var splicedChan *lnwallet.LightningChannel
+	for _, lnChan := range p.activeChannels {
+		if lnChan == nil {
+			continue
+		}
+
+		splicedFrom, err := lnChan.State().SplicedFrom()
+		if err != nil || splicedFrom == nil {
+			continue
+		}
+
+		if lnwire.NewChanIDFromOutPoint(splicedFrom) == msg.ChanID {
+			splicedChan = lnChan
+			break
+		}
+	}
+	p.activeChanMtx.RUnlock()
+
+	if splicedChan == nil ||
+		splicedChan.ChanPoint.Hash != msg.SpliceTxid {
+
+		peerLog.Warnf("Unexpected splice_locked for ChannelID(%v) "+
+			"from %v", msg.ChanID, p)
+		return
+	}
+
+	spliceLocked := &lnwire.SpliceLocked{
+		ChanID:     msg.ChanID,
+		SpliceTxid: msg.SpliceTxid,
+	}
+	if err := p.SendMessage(false, spliceLocked); err != nil {
+		peerLog.Errorf("Unable to send splice_locked: %v", err)
+	}
+}
+
+// completeSplice unfreezes a channel whose splice was locked in by both
+// parties. Its edge is restored at the new funding outpoint, and its link is
+// replaced by a new one using the new funding output.
+func (p *Brontide) completeSplice(chanID lnwire.ChannelID, lock *spliceLock) {
+	p.spliceMtx.Lock()
+	delete(p.spliceLocks, chanID)
+	p.spliceMtx.Unlock()
+
+	if *lock.localTxid != *lock.remoteTxid {
+		peerLog.Errorf("Splice of ChannelID(%v) locked in with tx %v, "+
+			"but peer locked in tx %v", chanID, lock.localTxid,
+			lock.remoteTxid)
+		return
+	}
+
+	chanState := lock.chanState
+	newChanPoint := chanState.FundingOutpoint
+	newChanID := lnwire.NewChanIDFromOutPoint(&newChanPoint)
+
+	peerLog.Infof("Splice of ChannelPoint(%v) locked in, channel moved "+
+		"to ChannelPoint(%v)", lock.oldChanPoint, newChanPoint)
+
+	// The frozen link of the channel uses either funding outpoint,
+	// depending on whether the channel moved before it was loaded.
+	p.cfg.Switch.RemoveLink(chanID)
+	p.cfg.Switch.RemoveLink(newChanID)
+
+	edge, err := chanState.SpliceEdge()
+	if err != nil {
+		peerLog.Errorf("Unable to fetch edge of spliced "+
+			"ChannelPoint(%v): %v", newChanPoint, err)
+	}
+
+	if err := p.updateSplicedEdge(chanState, edge); err != nil {
+		peerLog.Errorf("Unable to update edge of spliced "+
+			"ChannelPoint(%v): %v", newChanPoint, err)
+	}
+
+	if err := chanState.ClearSpliceLock(); err != nil {
+		peerLog.Errorf("Unable to clear splice lock of "+
+			"ChannelPoint(%v): %v", newChanPoint, err)
+		return
+	}
+
+	p.activeChanMtx.Lock()
+	delete(p.activeChannels, chanID)
+	delete(p.activeChannels, newChanID)
+	p.activeChanMtx.Unlock()
+
+	if err := p.addSplicedLink(chanState, edge); err != nil {
+		peerLog.Errorf("Unable to add link for spliced "+
+			"ChannelPoint(%v): %v", newChanPoint, err)
+	}
+}
+
+// updateSplicedEdge moves the channel's edge in the graph over to its new
+// funding outpoint, keeping the policies of both parties.
+func (p *Brontide) updateSplicedEdge(chanState *channeldb.OpenChannel,
+	edge *channeldb.SpliceEdge) error {
+
+	if edge == nil {
+		return nil
+	}
+
+	graph := p.cfg.ChannelGraph
+	oldChanID := edge.Info.ChannelID
+
+	// The router may already have pruned the edge once the old funding
+	// output was spent.
+	err := graph.DeleteChannelEdges(false, oldChanID)
+	if err != nil && err != channeldb.ErrEdgeNotFound {
+		return err
+	}
+
+	edgeInfo := *edge.Info
+	edgeInfo.ChannelPoint = chanState.FundingOutpoint
+	edgeInfo.Capacity = chanState.Capacity
+
+	// Channels using an alias keep it, so the edge must no longer be
+	// considered a zombie.
+	if chanState.IsOptionScidAlias() {
+		if err := graph.MarkEdgeLive(oldChanID); err != nil {
+			return err
+		}
+	} else {
+		edgeInfo.ChannelID = chanState.ShortChannelID.ToUint64()
+	}
+
+	// The edge may already exist if we restarted after restoring it.
+	err = graph.AddChannelEdge(&edgeInfo)
+	if err != nil && err != channeldb.ErrEdgeAlreadyExist {
+		return err
+	}
+
+	for _, policy := range edge.Policies {
+		if policy == nil {
+			continue
+		}
+
+		edgePolicy := *policy
+		edgePolicy.ChannelID = edgeInfo.ChannelID
+		if err := graph.UpdateEdgePolicy(&edgePolicy); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addSplicedLink adds a new link for a channel that moved over to the funding
+// output of its splice.
+func (p *Brontide) addSplicedLink(chanState *channeldb.OpenChannel,
+	edge *channeldb.SpliceEdge) error {
+
+	lnChan, err := lnwallet.NewLightningChannel(
+		p.cfg.Signer, chanState, p.cfg.SigPool,
+	)
+	if err != nil {
+		return err
+	}
+
+	chanPoint := &chanState.FundingOutpoint
+	chainEvents, err := p.cfg.ChainArb.SubscribeChannelEvents(*chanPoint)
+	if err != nil {
+		return err
+	}
+
+	// We'll keep forwarding with our policy of the channel, falling back
+	// to the default one if it's unknown.
+	var selfPolicy *channeldb.ChannelEdgePolicy
+	switch {
+	case edge == nil:
+
+	case bytes.Equal(edge.Info.NodeKey1Bytes[:], p.cfg.ServerPubKey[:]):
+		selfPolicy = edge.Policies[0]
+
+	default:
+		selfPolicy = edge.Policies[1]
+	}
+
+	forwardingPolicy := &p.cfg.RoutingPolicy
+	if selfPolicy != nil {
+		forwardingPolicy = &htlcswitch.ForwardingPolicy{
+			MinHTLCOut:    selfPolicy.MinHTLC,
+			MaxHTLC:       selfPolicy.MaxHTLC,
+			BaseFee:       selfPolicy.FeeBaseMSat,
+			FeeRate:       selfPolicy.FeeProportionalMillionths,
+			TimeLockDelta: uint32(selfPolicy.TimeLockDelta),
+		}
+	}
+
+	p.activeChanMtx.Lock()
+	p.activeChannels[lnwire.NewChanIDFromOutPoint(chanPoint)] = lnChan
+	p.activeChanMtx.Unlock()
+
+	return p.addLink(
+		chanPoint, lnChan, forwardingPolicy, chainEvents, false,
+	)
+}
@@ -1,6 +1,7 @@
 package record
 
 import (
+	"github.com/brsuite/brond/btcec"
 	"github.com/brsuite/broln/tlv"
 )
 
@@ -16,6 +17,19 @@ const (
 	// NextHopOnionType is the type used in the onion to reference the ID
 	// of the next hop.
 	NextHopOnionType tlv.Type = 6
+
+	// EncryptedDataOnionType is the type used in the onion to reference
+	// the encrypted data the recipient left for a hop of a blinded route.
+	EncryptedDataOnionType tlv.Type = 10
+
+	// BlindingPointOnionType is the type used in the onion to reference
+	// the blinding point the introduction node of a blinded route uses to
+	// decrypt its encrypted data.
+	BlindingPointOnionType tlv.Type = 12
+
+	// TotalAmtMsatBlindedType is the type used in the onion to reference
+	// the total amount the final hop of a blinded route should receive.
+	TotalAmtMsatBlindedType tlv.Type = 18
 )
 
 // NewAmtToFwdRecord creates a tlv.Record that encodes the amount_to_forward
@@ -45,3 +59,26 @@ func NewLockTimeRecord(lockTime *uint32) tlv.Record {
 func NewNextHopIDRecord(cid *uint64) tlv.Record {
 	return tlv.MakePrimitiveRecord(NextHopOnionType, cid)
 }
+
+// NewEncryptedDataRecord creates a tlv.Record that encodes the
+// encrypted_recipient_data (type 10) for an onion payload.
+func NewEncryptedDataRecord(data *[]byte) tlv.Record {
+	return tlv.MakePrimitiveRecord(EncryptedDataOnionType, data)
+}
+
+// NewBlindingPointRecord creates a tlv.Record that encodes the
+// current_path_key (type 12) for an onion payload.
+func NewBlindingPointRecord(point **btcec.PublicKey) tlv.Record {
+	return tlv.MakePrimitiveRecord(BlindingPointOnionType, point)
+}
+
+// NewTotalAmtMsatBlindedRecord creates a tlv.Record that encodes the
+// total_amount_msat (type 18) for an onion payload.
+func NewTotalAmtMsatBlindedRecord(amt *uint64) tlv.Record {
+	return tlv.MakeDynamicRecord(
+		TotalAmtMsatBlindedType, amt, func() uint64 {
+			return tlv.SizeTUint64(*amt)
+		},
+		tlv.ETUint64, tlv.DTUint64,
+	)
+}
@@ -0,0 +1,193 @@
+package blindedpath
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/brsuite/brond/btcec"
+)
+
+const (
+	// MaxHops is the maximum number of hops a blinded path can carry, as
+	// the hop count is encoded as a single byte.
+	MaxHops = math.MaxUint8
+
+	// MaxEncryptedDataSize is the maximum size of the encrypted data left
+	// for a single hop, as its length is encoded as a two byte integer.
+	MaxEncryptedDataSize = math.MaxUint16
+)
+
+var (
+	// ErrNoHops is returned when a blinded path without any hops is
+	// encoded or decoded.
+	ErrNoHops = errors.New("blinded path has no hops")
+
+	// ErrSCIDIntroductionNode is returned when decoding a blinded path
+	// that identifies its introduction node by short channel ID. Only
+	// introduction nodes identified by their public key are supported.
+	ErrSCIDIntroductionNode = errors.New("blinded paths with a short " +
+		"channel ID introduction node are not supported")
+)
+
+// BlindedHop is a single hop of a blinded path.
+type BlindedHop struct {
+	// BlindedNodePub is the blinded public key of the node at this hop.
+	// Only the creator of the path and the node itself are able to link
+	// it to the node's real identity.
+	BlindedNodePub *btcec.PublicKey
+
+	// CipherText is the data the creator of the path left for this hop,
+	// encrypted to a key only the node itself is able to derive. It
+	// usually instructs the node where to relay the message or payment
+	// to, or lets the final node identify the path.
+	CipherText []byte
+}
+
+// BlindedPath is a route whose hops, except for the first one, are hidden
+// from whoever uses it. The creator of the path picks the nodes along it and
+// leaves encrypted forwarding instructions for each of them, so that the
+// sender of a message or payment only needs to reach the introduction node.
+type BlindedPath struct {
+	// IntroductionPoint is the real public key of the first node of the
+	// path. The sender needs to be able to reach it.
+	IntroductionPoint *btcec.PublicKey
+
+	// BlindingPoint is the ephemeral public key the introduction node
+	// uses to unblind its hop of the path.
+	BlindingPoint *btcec.PublicKey
+
+	// BlindedHops are the hops of the path, starting with the
+	// introduction node.
+	BlindedHops []*BlindedHop
+}
+
+// FinalHop returns the last hop of the blinded path, which belongs to the
+// recipient.
+func (b *BlindedPath) FinalHop() *BlindedHop {
+	if len(b.BlindedHops) == 0 {
+		return nil
+	}
+
+	return b.BlindedHops[len(b.BlindedHops)-1]
+}
+
+// Encode serializes the blinded path into the passed io.Writer using the
+// blinded_path encoding of BOLT 04.
+func (b *BlindedPath) Encode(w io.Writer) error {
+	switch {
+	case len(b.BlindedHops) == 0:
+		return ErrNoHops
+
+	case len(b.BlindedHops) > MaxHops:
+		return fmt.Errorf("blinded path has %d hops, max is %d",
+			len(b.BlindedHops), MaxHops)
+	}
+
+	if _, err := w.Write(b.IntroductionPoint.SerializeCompressed()); err != nil {
+		return err
+	}
+	if _, err := w.Write(b.BlindingPoint.SerializeCompressed()); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte{uint8(len(b.BlindedHops))}); err != nil {
+		return err
+	}
+
+	for _, hop := range b.BlindedHops {
+		if len(hop.CipherText) > MaxEncryptedDataSize {
+			return fmt.Errorf("encrypted data of %d bytes exceeds "+
+				"max of %d", len(hop.CipherText),
+				MaxEncryptedDataSize)
+		}
+
+		_, err := w.Write(hop.BlindedNodePub.SerializeCompressed())
+		if err != nil {
+			return err
+		}
+
+		var l [2]byte
+		binary.BigEndian.PutUint16(l[:], uint16(len(hop.CipherText)))
+		if _, err := w.Write(l[:]); err != nil {
+			return err
+		}
+		if _, err := w.Write(hop.CipherText); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Decode deserializes a blinded path from the passed io.Reader, which is
+// expected to hold the blinded_path encoding of BOLT 04.
+func (b *BlindedPath) Decode(r io.Reader) error {
+	var first [33]byte
+	if _, err := io.ReadFull(r, first[:1]); err != nil {
+		return err
+	}
+
+	// The first byte of the introduction node tells us whether the node
+	// is identified by a short channel ID and direction, or by a public
+	// key.
+	if first[0] == 0x00 || first[0] == 0x01 {
+		return ErrSCIDIntroductionNode
+	}
+	if _, err := io.ReadFull(r, first[1:]); err != nil {
+		return err
+	}
+
+	var err error
+	b.IntroductionPoint, err = btcec.ParsePubKey(first[:], btcec.S256())
+	if err != nil {
+		return err
+	}
+
+	b.BlindingPoint, err = readPubKey(r)
+	if err != nil {
+		return err
+	}
+
+	var numHops [1]byte
+	if _, err := io.ReadFull(r, numHops[:]); err != nil {
+		return err
+	}
+	if numHops[0] == 0 {
+		return ErrNoHops
+	}
+
+	b.BlindedHops = make([]*BlindedHop, numHops[0])
+	for i := range b.BlindedHops {
+		hop := &BlindedHop{}
+		hop.BlindedNodePub, err = readPubKey(r)
+		if err != nil {
+			return err
+		}
+
+		var l [2]byte
+		if _, err := io.ReadFull(r, l[:]); err != nil {
+			return err
+		}
+
+		hop.CipherText = make([]byte, binary.BigEndian.Uint16(l[:]))
+		if _, err := io.ReadFull(r, hop.CipherText); err != nil {
+			return err
+		}
+
+		b.BlindedHops[i] = hop
+	}
+
+	return nil
+}
+
+// readPubKey reads a compressed public key from the passed io.Reader.
+func readPubKey(r io.Reader) (*btcec.PublicKey, error) {
+	var b [33]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return nil, err
+	}
+
+	return btcec.ParsePubKey(b[:], btcec.S256())
+}
@@ -0,0 +1,240 @@
+package blindedpath
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/brsuite/brond/btcec"
+	"github.com/brsuite/broln/keychain"
+	"github.com/brsuite/broln/lnwire"
+	"github.com/stretchr/testify/require"
+)
+
+// TestBlindedPathUnblinding asserts that every node along a blinded path is
+// able to derive its blinded node ID, decrypt the data left for it and derive
+// the blinding point of the next hop.
+func TestBlindedPathUnblinding(t *testing.T) {
+	t.Parallel()
+
+	const numHops = 4
+
+	var (
+		nodeKeys = make([]*btcec.PrivateKey, numHops)
+		hops     = make([]*HopInfo, numHops)
+	)
+	for i := 0; i < numHops; i++ {
+		priv, err := btcec.NewPrivateKey(btcec.S256())
+		require.NoError(t, err)
+
+		nodeKeys[i] = priv
+		hops[i] = &HopInfo{
+			NodePub:   priv.PubKey(),
+			PlainText: bytes.Repeat([]byte{byte(i)}, 10+i),
+		}
+	}
+
+	sessionKey, err := btcec.NewPrivateKey(btcec.S256())
+	require.NoError(t, err)
+
+	path, err := BuildBlindedPath(sessionKey, hops)
+	require.NoError(t, err)
+	require.Len(t, path.BlindedHops, numHops)
+	require.True(t, path.IntroductionPoint.IsEqual(hops[0].NodePub))
+
+	blindingPoint := path.BlindingPoint
+	for i, hop := range path.BlindedHops {
+		nodeKey := &keychain.PrivKeyECDH{PrivKey: nodeKeys[i]}
+
+		// The node must be able to tell that the blinded node ID of
+		// the hop belongs to it.
+		blindedKey, err := NewBlindedNodeECDH(nodeKey, blindingPoint)
+		require.NoError(t, err)
+		require.True(t, blindedKey.PubKey().IsEqual(hop.BlindedNodePub))
+
+		plainText, nextBlinding, err := DecryptBlindedHopData(
+			nodeKey, blindingPoint, hop.CipherText,
+		)
+		require.NoError(t, err)
+		require.Equal(t, hops[i].PlainText, plainText)
+
+		// Other nodes must not be able to read the data.
+		otherKey := &keychain.PrivKeyECDH{
+			PrivKey: nodeKeys[(i+1)%numHops],
+		}
+		_, _, err = DecryptBlindedHopData(
+			otherKey, blindingPoint, hop.CipherText,
+		)
+		require.Error(t, err)
+
+		blindingPoint = nextBlinding
+	}
+}
+
+// TestBlindedNodeECDH asserts that ECDH operations performed with the blinded
+// node key match the ones of the blinded private key.
+func TestBlindedNodeECDH(t *testing.T) {
+	t.Parallel()
+
+	nodeKey, err := btcec.NewPrivateKey(btcec.S256())
+	require.NoError(t, err)
+	blindingKey, err := btcec.NewPrivateKey(btcec.S256())
+	require.NoError(t, err)
+	remoteKey, err := btcec.NewPrivateKey(btcec.S256())
+	require.NoError(t, err)
+
+	blindedKey, err := NewBlindedNodeECDH(
+		&keychain.PrivKeyECDH{PrivKey: nodeKey}, blindingKey.PubKey(),
+	)
+	require.NoError(t, err)
+
+	// Derive the blinded private key directly, which the node itself is
+	// never required to do.
+	sharedSecret, err := (&keychain.PrivKeyECDH{
+		PrivKey: blindingKey,
+	}).ECDH(nodeKey.PubKey())
+	require.NoError(t, err)
+
+	blindedPriv := blindPrivKey(nodeKey, blindingFactor(sharedSecret))
+	require.True(t, blindedPriv.PubKey().IsEqual(blindedKey.PubKey()))
+
+	expected, err := (&keychain.PrivKeyECDH{
+		PrivKey: blindedPriv,
+	}).ECDH(remoteKey.PubKey())
+	require.NoError(t, err)
+
+	actual, err := blindedKey.ECDH(remoteKey.PubKey())
+	require.NoError(t, err)
+	require.Equal(t, expected, actual)
+}
+
+// TestBlindedPathEncoding asserts that blinded paths survive a round trip
+// through their wire encoding.
+func TestBlindedPathEncoding(t *testing.T) {
+	t.Parallel()
+
+	var hops []*HopInfo
+	for i := 0; i < 3; i++ {
+		priv, err := btcec.NewPrivateKey(btcec.S256())
+		require.NoError(t, err)
+
+		hops = append(hops, &HopInfo{
+			NodePub:   priv.PubKey(),
+			PlainText: bytes.Repeat([]byte{0xaa}, i*20),
+		})
+	}
+
+	sessionKey, err := btcec.NewPrivateKey(btcec.S256())
+	require.NoError(t, err)
+
+	path, err := BuildBlindedPath(sessionKey, hops)
+	require.NoError(t, err)
+
+	var b bytes.Buffer
+	require.NoError(t, path.Encode(&b))
+
+	decoded := &BlindedPath{}
+	require.NoError(t, decoded.Decode(&b))
+	require.Equal(t, path.IntroductionPoint.SerializeCompressed(),
+		decoded.IntroductionPoint.SerializeCompressed())
+	require.Equal(t, path.BlindingPoint.SerializeCompressed(),
+		decoded.BlindingPoint.SerializeCompressed())
+	require.Len(t, decoded.BlindedHops, len(path.BlindedHops))
+
+	for i, hop := range path.BlindedHops {
+		require.True(t, hop.BlindedNodePub.IsEqual(
+			decoded.BlindedHops[i].BlindedNodePub,
+		))
+		require.Equal(t, hop.CipherText, decoded.BlindedHops[i].CipherText)
+	}
+
+	// A path without any hops can't be encoded.
+	require.ErrorIs(t, (&BlindedPath{
+		IntroductionPoint: path.IntroductionPoint,
+		BlindingPoint:     path.BlindingPoint,
+	}).Encode(&b), ErrNoHops)
+
+	// Paths with an introduction node given by short channel ID aren't
+	// supported.
+	err = decoded.Decode(bytes.NewReader(make([]byte, 9)))
+	require.ErrorIs(t, err, ErrSCIDIntroductionNode)
+}
+
+// TestEncryptedDataPaymentRecords asserts that the payment relay and
+// constraints records survive a round trip through the encrypted data
+// encoding.
+func TestEncryptedDataPaymentRecords(t *testing.T) {
+	t.Parallel()
+
+	scid := lnwire.NewShortChanIDFromInt(12345)
+	data := &EncryptedData{
+		ShortChannelID: &scid,
+		PaymentRelay: &PaymentRelay{
+			CltvExpiryDelta:           40,
+			FeeProportionalMillionths: 1000,
+			FeeBaseMsat:               1,
+		},
+		PaymentConstraints: &PaymentConstraints{
+			MaxCltvExpiry:   800_000,
+			HtlcMinimumMsat: 1000,
+		},
+	}
+
+	encoded, err := data.Encode()
+	require.NoError(t, err)
+
+	decoded, err := DecodeEncryptedData(encoded)
+	require.NoError(t, err)
+	require.Equal(t, data, decoded)
+}
+
+// TestPaymentRelayForwardAmount asserts that a hop of a blinded path never
+// forwards less than the amount the sender accounted its fee for. Due to
+// rounding, it may forward a single msat more.
+func TestPaymentRelayForwardAmount(t *testing.T) {
+	t.Parallel()
+
+	relay := &PaymentRelay{
+		FeeProportionalMillionths: 2500,
+		FeeBaseMsat:               1000,
+	}
+	payInfo := &PayInfo{
+		FeeBaseMsat:               relay.FeeBaseMsat,
+		FeeProportionalMillionths: relay.FeeProportionalMillionths,
+	}
+
+	for _, amt := range []lnwire.MilliSatoshi{1, 999, 100_000, 123_456_789} {
+		incoming := amt + payInfo.Fee(amt)
+
+		forwarded, err := relay.ForwardAmount(incoming)
+		require.NoError(t, err)
+		require.GreaterOrEqual(t, forwarded, amt)
+		require.LessOrEqual(t, forwarded, amt+1)
+	}
+
+	_, err := relay.ForwardAmount(999)
+	require.Error(t, err)
+}
+
+// TestPayInfoEncoding asserts that payment relay information survives a round
+// trip through its wire encoding.
+func TestPayInfoEncoding(t *testing.T) {
+	t.Parallel()
+
+	info := &PayInfo{
+		FeeBaseMsat:               1000,
+		FeeProportionalMillionths: 100,
+		CltvExpiryDelta:           144,
+		HtlcMinimumMsat:           1,
+		HtlcMaximumMsat:           1_000_000_000,
+		Features: lnwire.NewRawFeatureVector(
+			lnwire.MPPOptional,
+		),
+	}
+
+	var b bytes.Buffer
+	require.NoError(t, info.Encode(&b))
+
+	decoded := &PayInfo{}
+	require.NoError(t, decoded.Decode(&b))
+	require.Equal(t, info, decoded)
+}
@@ -0,0 +1,264 @@
+package blindedpath
+
+import (
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/brsuite/brond/btcec"
+	"github.com/brsuite/broln/keychain"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+var (
+	// blindedNodeIDKey is the HMAC key used to derive the factor a node's
+	// public key is tweaked by to obtain its blinded node ID.
+	blindedNodeIDKey = []byte("blinded_node_id")
+
+	// rhoKey is the HMAC key used to derive the key the data left for a
+	// hop is encrypted with.
+	rhoKey = []byte("rho")
+
+	// ErrNoPathHops is returned when attempting to build a blinded path
+	// without any hops.
+	ErrNoPathHops = errors.New("cannot build blinded path without hops")
+)
+
+// HopInfo describes a hop that should be included in a blinded path.
+type HopInfo struct {
+	// NodePub is the real public key of the node.
+	NodePub *btcec.PublicKey
+
+	// PlainText is the data that should be left for the node, usually a
+	// serialized TLV stream. It is encrypted so that only the node itself
+	// is able to read it.
+	PlainText []byte
+}
+
+// BuildBlindedPath creates a blinded path through the passed hops, using the
+// session key as the first ephemeral blinding key. The first hop becomes the
+// introduction node of the path.
+func BuildBlindedPath(sessionKey *btcec.PrivateKey,
+	hops []*HopInfo) (*BlindedPath, error) {
+
+	if len(hops) == 0 {
+		return nil, ErrNoPathHops
+	}
+	if len(hops) > MaxHops {
+		return nil, fmt.Errorf("blinded path can have at most %d hops, "+
+			"got %d", MaxHops, len(hops))
+	}
+
+	path := &BlindedPath{
+		IntroductionPoint: hops[0].NodePub,
+		BlindingPoint:     sessionKey.PubKey(),
+		BlindedHops:       make([]*BlindedHop, len(hops)),
+	}
+
+	ephemeralKey := sessionKey
+	for i, hop := range hops {
+		ecdh := &keychain.PrivKeyECDH{PrivKey: ephemeralKey}
+		sharedSecret, err := ecdh.ECDH(hop.NodePub)
+		if err != nil {
+			return nil, err
+		}
+
+		cipherText, err := encryptData(sharedSecret, hop.PlainText)
+		if err != nil {
+			return nil, err
+		}
+
+		path.BlindedHops[i] = &BlindedHop{
+			BlindedNodePub: blindPubKey(
+				hop.NodePub, blindingFactor(sharedSecret),
+			),
+			CipherText: cipherText,
+		}
+
+		// The ephemeral key of the next hop is derived from the
+		// current one and the secret we share with the current hop.
+		ephemeralKey = NextEphemeralKey(ephemeralKey, sharedSecret)
+	}
+
+	return path, nil
+}
+
+// DecryptBlindedHopData decrypts the data the creator of a blinded path left
+// for our hop, using the blinding point we received along with it. The
+// blinding point that should be passed on to the next hop is returned along
+// with the plain text.
+func DecryptBlindedHopData(nodeKey keychain.SingleKeyECDH,
+	blindingPoint *btcec.PublicKey, cipherText []byte) ([]byte,
+	*btcec.PublicKey, error) {
+
+	sharedSecret, err := nodeKey.ECDH(blindingPoint)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	plainText, err := decryptData(sharedSecret, cipherText)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return plainText, NextBlindingPoint(blindingPoint, sharedSecret), nil
+}
+
+// NextBlindingPoint derives the blinding point of the next hop of a blinded
+// path, given the blinding point of the current hop and the secret the
+// current hop derived from it.
+func NextBlindingPoint(blindingPoint *btcec.PublicKey,
+	sharedSecret [32]byte) *btcec.PublicKey {
+
+	return blindPubKey(
+		blindingPoint, nextBlindingFactor(blindingPoint, sharedSecret),
+	)
+}
+
+// NextEphemeralKey derives the ephemeral private key of the next hop of a
+// blinded path or onion, given the one of the current hop and the secret it
+// shares with the current hop. It is the private key counterpart of
+// NextBlindingPoint.
+func NextEphemeralKey(ephemeralKey *btcec.PrivateKey,
+	sharedSecret [32]byte) *btcec.PrivateKey {
+
+	return blindPrivKey(
+		ephemeralKey, nextBlindingFactor(
+			ephemeralKey.PubKey(), sharedSecret,
+		),
+	)
+}
+
+// BlindedNodeECDH wraps a node's ECDH key and performs ECDH operations with
+// the blinded private key the node is known by at one hop of a blinded path,
+// without ever requiring access to the raw private key.
+type BlindedNodeECDH struct {
+	nodeKey keychain.SingleKeyECDH
+	factor  []byte
+}
+
+// NewBlindedNodeECDH returns an ECDH key of the blinded node ID the node with
+// the passed key is known by at the hop the blinding point belongs to.
+func NewBlindedNodeECDH(nodeKey keychain.SingleKeyECDH,
+	blindingPoint *btcec.PublicKey) (*BlindedNodeECDH, error) {
+
+	sharedSecret, err := nodeKey.ECDH(blindingPoint)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BlindedNodeECDH{
+		nodeKey: nodeKey,
+		factor:  blindingFactor(sharedSecret),
+	}, nil
+}
+
+// A compile time check to ensure BlindedNodeECDH implements the
+// keychain.SingleKeyECDH interface.
+var _ keychain.SingleKeyECDH = (*BlindedNodeECDH)(nil)
+
+// PubKey returns the blinded node ID.
+//
+// NOTE: This is part of the keychain.SingleKeyECDH interface.
+func (b *BlindedNodeECDH) PubKey() *btcec.PublicKey {
+	return blindPubKey(b.nodeKey.PubKey(), b.factor)
+}
+
+// ECDH performs an ECDH operation between the blinded node key and the passed
+// public key. Since the blinded private key is the node's private key
+// multiplied by the blinding factor, the factor is applied to the remote key
+// instead, which yields the same shared point.
+//
+// NOTE: This is part of the keychain.SingleKeyECDH interface.
+func (b *BlindedNodeECDH) ECDH(pub *btcec.PublicKey) ([32]byte, error) {
+	return b.nodeKey.ECDH(b.BlindRemoteKey(pub))
+}
+
+// BlindRemoteKey applies the blinding factor to the passed remote key. An ECDH
+// operation of the node's real key with the result yields the same secret as
+// one of the blinded key with the passed key. This allows code that only knows
+// about the node's real key, like the sphinx router, to process onions that
+// were created for the blinded node ID.
+func (b *BlindedNodeECDH) BlindRemoteKey(pub *btcec.PublicKey) *btcec.PublicKey {
+	return blindPubKey(pub, b.factor)
+}
+
+// blindingFactor derives the factor a node's key is tweaked by from the
+// secret shared between the node and the creator of the path.
+func blindingFactor(sharedSecret [32]byte) []byte {
+	mac := hmac.New(sha256.New, blindedNodeIDKey)
+	mac.Write(sharedSecret[:])
+
+	return mac.Sum(nil)
+}
+
+// nextBlindingFactor derives the factor the ephemeral blinding key of the
+// current hop is tweaked by to obtain the one of the next hop.
+func nextBlindingFactor(blindingPoint *btcec.PublicKey,
+	sharedSecret [32]byte) []byte {
+
+	h := sha256.New()
+	h.Write(blindingPoint.SerializeCompressed())
+	h.Write(sharedSecret[:])
+
+	return h.Sum(nil)
+}
+
+// blindPubKey multiplies the passed public key by the passed factor.
+func blindPubKey(pub *btcec.PublicKey, factor []byte) *btcec.PublicKey {
+	x, y := btcec.S256().ScalarMult(pub.X, pub.Y, factor)
+
+	return &btcec.PublicKey{
+		Curve: btcec.S256(),
+		X:     x,
+		Y:     y,
+	}
+}
+
+// blindPrivKey multiplies the passed private key by the passed factor.
+func blindPrivKey(priv *btcec.PrivateKey,
+	factor []byte) *btcec.PrivateKey {
+
+	d := new(big.Int).SetBytes(factor)
+	d.Mul(d, priv.D)
+	d.Mod(d, btcec.S256().N)
+
+	blinded, _ := btcec.PrivKeyFromBytes(btcec.S256(), d.Bytes())
+	return blinded
+}
+
+// encryptData encrypts the data left for a hop with the key derived from the
+// secret shared with it.
+func encryptData(sharedSecret [32]byte, plainText []byte) ([]byte, error) {
+	aead, err := newCipher(sharedSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	var nonce [chacha20poly1305.NonceSize]byte
+	return aead.Seal(nil, nonce[:], plainText, nil), nil
+}
+
+// decryptData decrypts the data left for a hop with the key derived from the
+// secret shared with the creator of the path.
+func decryptData(sharedSecret [32]byte, cipherText []byte) ([]byte, error) {
+	aead, err := newCipher(sharedSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	var nonce [chacha20poly1305.NonceSize]byte
+	return aead.Open(nil, nonce[:], cipherText, nil)
+}
+
+// newCipher returns the ChaCha20-Poly1305 cipher keyed with the rho key
+// derived from the passed shared secret.
+func newCipher(sharedSecret [32]byte) (cipher.AEAD, error) {
+	mac := hmac.New(sha256.New, rhoKey)
+	mac.Write(sharedSecret[:])
+
+	return chacha20poly1305.New(mac.Sum(nil))
+}
@@ -0,0 +1,346 @@
+package blindedpath
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/brsuite/brond/btcec"
+	"github.com/brsuite/broln/lnwire"
+	"github.com/brsuite/broln/tlv"
+)
+
+const (
+	// PaddingType is the type of the record used to pad the encrypted
+	// data of all hops of a path to the same length.
+	PaddingType tlv.Type = 1
+
+	// ShortChannelIDType is the type of the record holding the channel
+	// the message or payment should be forwarded over.
+	ShortChannelIDType tlv.Type = 2
+
+	// NextNodeIDType is the type of the record holding the node the
+	// message should be forwarded to.
+	NextNodeIDType tlv.Type = 4
+
+	// PathIDType is the type of the record the creator of the path uses to
+	// recognize messages or payments that arrive over it.
+	PathIDType tlv.Type = 6
+
+	// NextBlindingOverrideType is the type of the record holding the
+	// blinding point that should be passed on to the next hop, instead of
+	// the one derived from the current blinding point.
+	NextBlindingOverrideType tlv.Type = 8
+
+	// PaymentRelayType is the type of the record holding the fees and CLTV
+	// delta a hop applies when forwarding a payment.
+	PaymentRelayType tlv.Type = 10
+
+	// PaymentConstraintsType is the type of the record holding the limits
+	// a hop enforces on the payments it forwards.
+	PaymentConstraintsType tlv.Type = 12
+)
+
+// PaymentRelay holds the fees and CLTV delta a hop of a blinded path applies
+// when forwarding a payment. The amount and expiry of the outgoing HTLC are
+// derived from the incoming one using these values, as the sender doesn't
+// know them.
+type PaymentRelay struct {
+	// CltvExpiryDelta is the CLTV delta the hop applies.
+	CltvExpiryDelta uint16
+
+	// FeeProportionalMillionths is the proportional fee the hop charges.
+	FeeProportionalMillionths uint32
+
+	// FeeBaseMsat is the base fee the hop charges.
+	FeeBaseMsat uint32
+}
+
+// ForwardAmount returns the amount a hop forwards for the passed incoming
+// amount, as defined in BOLT 04.
+func (p *PaymentRelay) ForwardAmount(
+	incoming lnwire.MilliSatoshi) (lnwire.MilliSatoshi, error) {
+
+	base := lnwire.MilliSatoshi(p.FeeBaseMsat)
+	if incoming < base {
+		return 0, fmt.Errorf("incoming amount %v below base fee %v",
+			incoming, base)
+	}
+
+	// The amount is rounded up, as the sender rounds up the fees it
+	// aggregates over the path and the recipient must not receive less
+	// than it expects.
+	prop := uint64(p.FeeProportionalMillionths)
+	numerator := uint64(incoming-base)*1_000_000 + 1_000_000 + prop - 1
+	denominator := 1_000_000 + prop
+
+	return lnwire.MilliSatoshi(numerator / denominator), nil
+}
+
+// PaymentConstraints holds the limits a hop of a blinded path enforces on the
+// payments it forwards, so that the path can't be used for anything but the
+// payments its creator intended.
+type PaymentConstraints struct {
+	// MaxCltvExpiry is the maximum expiry of the incoming HTLC.
+	MaxCltvExpiry uint32
+
+	// HtlcMinimumMsat is the minimum amount of the incoming HTLC.
+	HtlcMinimumMsat lnwire.MilliSatoshi
+}
+
+// EncryptedData is the data the creator of a blinded path leaves for a single
+// hop of the path.
+type EncryptedData struct {
+	// Padding is used to pad the encrypted data of all hops to the same
+	// length, so that their position along the path isn't revealed.
+	Padding []byte
+
+	// ShortChannelID is the channel the hop should forward over, if the
+	// next node is identified by channel instead of public key.
+	ShortChannelID *lnwire.ShortChannelID
+
+	// NextNodeID is the real public key of the next node along the path.
+	NextNodeID *btcec.PublicKey
+
+	// PathID is set on the final hop only and allows the creator of the
+	// path to recognize and authenticate what arrives over it.
+	PathID []byte
+
+	// NextBlindingOverride is the blinding point that should be passed on
+	// to the next hop. It is used to concatenate blinded paths.
+	NextBlindingOverride *btcec.PublicKey
+
+	// PaymentRelay holds the fees and CLTV delta the hop applies when
+	// forwarding a payment.
+	PaymentRelay *PaymentRelay
+
+	// PaymentConstraints holds the limits the hop enforces on the
+	// payments it forwards.
+	PaymentConstraints *PaymentConstraints
+}
+
+// Encode serializes the encrypted data as a TLV stream.
+func (e *EncryptedData) Encode() ([]byte, error) {
+	var records []tlv.Record
+	if len(e.Padding) > 0 {
+		records = append(records, tlv.MakePrimitiveRecord(
+			PaddingType, &e.Padding,
+		))
+	}
+
+	var scid uint64
+	if e.ShortChannelID != nil {
+		scid = e.ShortChannelID.ToUint64()
+		records = append(records, tlv.MakePrimitiveRecord(
+			ShortChannelIDType, &scid,
+		))
+	}
+
+	if e.NextNodeID != nil {
+		records = append(records, tlv.MakePrimitiveRecord(
+			NextNodeIDType, &e.NextNodeID,
+		))
+	}
+
+	if len(e.PathID) > 0 {
+		records = append(records, tlv.MakePrimitiveRecord(
+			PathIDType, &e.PathID,
+		))
+	}
+
+	if e.NextBlindingOverride != nil {
+		records = append(records, tlv.MakePrimitiveRecord(
+			NextBlindingOverrideType, &e.NextBlindingOverride,
+		))
+	}
+
+	if e.PaymentRelay != nil {
+		records = append(records, newPaymentRelayRecord(
+			e.PaymentRelay,
+		))
+	}
+
+	if e.PaymentConstraints != nil {
+		records = append(records, newPaymentConstraintsRecord(
+			e.PaymentConstraints,
+		))
+	}
+
+	stream, err := tlv.NewStream(records...)
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	if err := stream.Encode(&b); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+// DecodeEncryptedData parses the decrypted data left for a hop of a blinded
+// path.
+func DecodeEncryptedData(data []byte) (*EncryptedData, error) {
+	var (
+		e            EncryptedData
+		scid         uint64
+		nextNode     *btcec.PublicKey
+		nextBlinding *btcec.PublicKey
+		relay        PaymentRelay
+		constraints  PaymentConstraints
+	)
+
+	stream, err := tlv.NewStream(
+		tlv.MakePrimitiveRecord(PaddingType, &e.Padding),
+		tlv.MakePrimitiveRecord(ShortChannelIDType, &scid),
+		tlv.MakePrimitiveRecord(NextNodeIDType, &nextNode),
+		tlv.MakePrimitiveRecord(PathIDType, &e.PathID),
+		tlv.MakePrimitiveRecord(
+			NextBlindingOverrideType, &nextBlinding,
+		),
+		newPaymentRelayRecord(&relay),
+		newPaymentConstraintsRecord(&constraints),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	parsedTypes, err := stream.DecodeWithParsedTypes(
+		bytes.NewReader(data),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// We can't act on any instructions we don't understand.
+	for typ, value := range parsedTypes {
+		if value != nil && typ%2 == 0 {
+			return nil, fmt.Errorf("unknown required type %d in "+
+				"encrypted data", typ)
+		}
+	}
+
+	if _, ok := parsedTypes[ShortChannelIDType]; ok {
+		chanID := lnwire.NewShortChanIDFromInt(scid)
+		e.ShortChannelID = &chanID
+	}
+	if _, ok := parsedTypes[NextNodeIDType]; ok {
+		e.NextNodeID = nextNode
+	}
+	if _, ok := parsedTypes[NextBlindingOverrideType]; ok {
+		e.NextBlindingOverride = nextBlinding
+	}
+	if _, ok := parsedTypes[PaymentRelayType]; ok {
+		e.PaymentRelay = &relay
+	}
+	if _, ok := parsedTypes[PaymentConstraintsType]; ok {
+		e.PaymentConstraints = &constraints
+	}
+
+	return &e, nil
+}
+
+// newPaymentRelayRecord returns the record of the payment relay information
+// of a hop.
+func newPaymentRelayRecord(relay *PaymentRelay) tlv.Record {
+	return tlv.MakeDynamicRecord(
+		PaymentRelayType, relay, func() uint64 {
+			return 6 + tlv.SizeTUint32(relay.FeeBaseMsat)
+		}, encodePaymentRelay, decodePaymentRelay,
+	)
+}
+
+// encodePaymentRelay is a tlv.Encoder for PaymentRelay.
+func encodePaymentRelay(w io.Writer, val interface{}, buf *[8]byte) error {
+	if r, ok := val.(*PaymentRelay); ok {
+		err := tlv.EUint16T(w, r.CltvExpiryDelta, buf)
+		if err != nil {
+			return err
+		}
+
+		err = tlv.EUint32T(w, r.FeeProportionalMillionths, buf)
+		if err != nil {
+			return err
+		}
+
+		return tlv.ETUint32T(w, r.FeeBaseMsat, buf)
+	}
+
+	return tlv.NewTypeForEncodingErr(val, "*blindedpath.PaymentRelay")
+}
+
+// decodePaymentRelay is a tlv.Decoder for PaymentRelay.
+func decodePaymentRelay(r io.Reader, val interface{}, buf *[8]byte,
+	l uint64) error {
+
+	if relay, ok := val.(*PaymentRelay); ok && l >= 6 && l <= 10 {
+		err := tlv.DUint16(r, &relay.CltvExpiryDelta, buf, 2)
+		if err != nil {
+			return err
+		}
+
+		err = tlv.DUint32(r, &relay.FeeProportionalMillionths, buf, 4)
+		if err != nil {
+			return err
+		}
+
+		return tlv.DTUint32(r, &relay.FeeBaseMsat, buf, l-6)
+	}
+
+	return tlv.NewTypeForDecodingErr(val, "*blindedpath.PaymentRelay", l, 10)
+}
+
+// newPaymentConstraintsRecord returns the record of the payment constraints
+// of a hop.
+func newPaymentConstraintsRecord(constraints *PaymentConstraints) tlv.Record {
+	return tlv.MakeDynamicRecord(
+		PaymentConstraintsType, constraints, func() uint64 {
+			return 4 + tlv.SizeTUint64(
+				uint64(constraints.HtlcMinimumMsat),
+			)
+		}, encodePaymentConstraints, decodePaymentConstraints,
+	)
+}
+
+// encodePaymentConstraints is a tlv.Encoder for PaymentConstraints.
+func encodePaymentConstraints(w io.Writer, val interface{},
+	buf *[8]byte) error {
+
+	if c, ok := val.(*PaymentConstraints); ok {
+		err := tlv.EUint32T(w, c.MaxCltvExpiry, buf)
+		if err != nil {
+			return err
+		}
+
+		return tlv.ETUint64T(w, uint64(c.HtlcMinimumMsat), buf)
+	}
+
+	return tlv.NewTypeForEncodingErr(
+		val, "*blindedpath.PaymentConstraints",
+	)
+}
+
+// decodePaymentConstraints is a tlv.Decoder for PaymentConstraints.
+func decodePaymentConstraints(r io.Reader, val interface{}, buf *[8]byte,
+	l uint64) error {
+
+	if c, ok := val.(*PaymentConstraints); ok && l >= 4 && l <= 12 {
+		err := tlv.DUint32(r, &c.MaxCltvExpiry, buf, 4)
+		if err != nil {
+			return err
+		}
+
+		var htlcMin uint64
+		if err := tlv.DTUint64(r, &htlcMin, buf, l-4); err != nil {
+			return err
+		}
+		c.HtlcMinimumMsat = lnwire.MilliSatoshi(htlcMin)
+
+		return nil
+	}
+
+	return tlv.NewTypeForDecodingErr(
+		val, "*blindedpath.PaymentConstraints", l, 12,
+	)
+}
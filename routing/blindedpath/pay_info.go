@@ -0,0 +1,101 @@
+package blindedpath
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/brsuite/broln/lnwire"
+)
+
+// PayInfo describes the fees and constraints of paying over a blinded path,
+// aggregated over all of its hops. The recipient hands it out along with the
+// path, so that the sender is able to account for the hidden hops when
+// building the route.
+type PayInfo struct {
+	// FeeBaseMsat is the base fee charged by the path.
+	FeeBaseMsat uint32
+
+	// FeeProportionalMillionths is the proportional fee charged by the
+	// path.
+	FeeProportionalMillionths uint32
+
+	// CltvExpiryDelta is the total CLTV delta of the path.
+	CltvExpiryDelta uint16
+
+	// HtlcMinimumMsat is the minimum HTLC amount the path accepts.
+	HtlcMinimumMsat lnwire.MilliSatoshi
+
+	// HtlcMaximumMsat is the maximum HTLC amount the path accepts.
+	HtlcMaximumMsat lnwire.MilliSatoshi
+
+	// Features are the features of the path.
+	Features *lnwire.RawFeatureVector
+}
+
+// Fee returns the fee charged by the path for delivering the passed amount to
+// the recipient. The proportional part is rounded up, matching the amounts the
+// hops of the path forward.
+func (p *PayInfo) Fee(amt lnwire.MilliSatoshi) lnwire.MilliSatoshi {
+	prop := amt * lnwire.MilliSatoshi(p.FeeProportionalMillionths)
+
+	return lnwire.MilliSatoshi(p.FeeBaseMsat) +
+		(prop+1_000_000-1)/1_000_000
+}
+
+// Encode serializes the payment relay information into the passed io.Writer.
+func (p *PayInfo) Encode(w io.Writer) error {
+	var features bytes.Buffer
+	if p.Features != nil {
+		if err := p.Features.EncodeBase256(&features); err != nil {
+			return err
+		}
+	}
+
+	var b [28]byte
+	binary.BigEndian.PutUint32(b[0:4], p.FeeBaseMsat)
+	binary.BigEndian.PutUint32(b[4:8], p.FeeProportionalMillionths)
+	binary.BigEndian.PutUint16(b[8:10], p.CltvExpiryDelta)
+	binary.BigEndian.PutUint64(b[10:18], uint64(p.HtlcMinimumMsat))
+	binary.BigEndian.PutUint64(b[18:26], uint64(p.HtlcMaximumMsat))
+	binary.BigEndian.PutUint16(b[26:28], uint16(features.Len()))
+	if _, err := w.Write(b[:]); err != nil {
+		return err
+	}
+
+	_, err := w.Write(features.Bytes())
+	return err
+}
+
+// Decode parses the payment relay information from the passed io.Reader.
+func (p *PayInfo) Decode(r io.Reader) error {
+	var b [28]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return err
+	}
+
+	p.FeeBaseMsat = binary.BigEndian.Uint32(b[0:4])
+	p.FeeProportionalMillionths = binary.BigEndian.Uint32(b[4:8])
+	p.CltvExpiryDelta = binary.BigEndian.Uint16(b[8:10])
+	p.HtlcMinimumMsat = lnwire.MilliSatoshi(
+		binary.BigEndian.Uint64(b[10:18]),
+	)
+	p.HtlcMaximumMsat = lnwire.MilliSatoshi(
+		binary.BigEndian.Uint64(b[18:26]),
+	)
+
+	numBytes := int(binary.BigEndian.Uint16(b[26:28]))
+	p.Features = lnwire.NewRawFeatureVector()
+
+	return p.Features.DecodeBase256(r, numBytes)
+}
+
+// PaymentPath is a blinded path to the recipient of a payment, along with the
+// information required to pay over it.
+type PaymentPath struct {
+	// PayInfo holds the aggregated fees and constraints of the path.
+	PayInfo *PayInfo
+
+	// Path is the blinded path to the recipient.
+	Path *BlindedPath
+}
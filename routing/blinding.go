@@ -0,0 +1,66 @@
+package routing
+
+import (
+	"errors"
+
+	"github.com/brsuite/broln/lnwire"
+	"github.com/brsuite/broln/record"
+	"github.com/brsuite/broln/routing/blindedpath"
+	"github.com/brsuite/broln/routing/route"
+)
+
+var (
+	// errLegacyIntroductionNode is returned when the introduction node of
+	// a blinded path doesn't support TLV payloads, which are required to
+	// deliver the encrypted data to it.
+	errLegacyIntroductionNode = errors.New("introduction node of " +
+		"blinded path doesn't support tlv payloads")
+)
+
+// appendBlindedHops extends a route to the introduction node of a blinded path
+// with the hidden hops of the path. The passed route must deliver the amount
+// plus the fees of the path to the introduction node, with an expiry that
+// covers the cltv delta of the path. The amount and expiry the recipient
+// receives are given by amt and finalExpiry.
+func appendBlindedHops(rt *route.Route, path *blindedpath.PaymentPath,
+	amt, totalAmt lnwire.MilliSatoshi, finalExpiry uint32,
+	records record.CustomSet) (*route.Route, error) {
+
+	hops := make([]*route.Hop, 0, len(rt.Hops)+len(path.Path.BlindedHops))
+	for _, hop := range rt.Hops {
+		hops = append(hops, hop.Copy())
+	}
+
+	// The introduction node is the last hop we found a route to. It is
+	// the only hop of the path we know the real identity of, and receives
+	// the blinding point from us instead of along with the HTLC.
+	intro := hops[len(hops)-1]
+	if intro.LegacyPayload {
+		return nil, errLegacyIntroductionNode
+	}
+	intro.EncryptedData = path.Path.BlindedHops[0].CipherText
+	intro.BlindingPoint = path.Path.BlindingPoint
+
+	for _, blindedHop := range path.Path.BlindedHops[1:] {
+		hops = append(hops, &route.Hop{
+			PubKeyBytes:   route.NewVertex(blindedHop.BlindedNodePub),
+			EncryptedData: blindedHop.CipherText,
+		})
+	}
+
+	// All hops of the path report the final amount and expiry, so that
+	// the fees of the whole path are attributed to the introduction node.
+	// Only the final hop actually receives them in its payload.
+	for _, hop := range hops[len(rt.Hops)-1:] {
+		hop.AmtToForward = amt
+		hop.OutgoingTimeLock = finalExpiry
+	}
+
+	finalHop := hops[len(hops)-1]
+	finalHop.TotalAmtMsat = totalAmt
+	finalHop.CustomRecords = records
+
+	return route.NewRouteFromHops(
+		rt.TotalAmount, rt.TotalTimeLock, rt.SourcePubKey, hops,
+	)
+}
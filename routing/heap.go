@@ -44,6 +44,9 @@ type nodeWithDist struct {
 	// routingInfoSize is the total size requirement for the payloads field
 	// in the onion packet from this hop towards the final destination.
 	routingInfoSize uint64
+
+	// hops is the number of channels from this node to the destination.
+	hops uint32
 }
 
 // distanceHeap is a min-distance heap that's used within our path finding
@@ -6,6 +6,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/brsuite/bronutil"
 	"github.com/brsuite/broln/channeldb"
 	"github.com/brsuite/broln/kvdb"
 	"github.com/brsuite/broln/lnwire"
@@ -61,6 +62,10 @@ const (
 	// have passed since the previously recorded failure before the failure
 	// amount may be raised.
 	DefaultMinFailureRelaxInterval = time.Minute
+
+	// DefaultEstimator is the default estimator used for computing
+	// probabilities in pathfinding.
+	DefaultEstimator = AprioriEstimatorName
 )
 
 var (
@@ -72,6 +77,10 @@ var (
 	// ErrInvalidFailureInterval is returned if we get an invalid failure
 	// interval.
 	ErrInvalidFailureInterval = errors.New("failure interval must be >= 0")
+
+	// ErrMissingEstimator is returned if mission control is configured
+	// without a probability estimator.
+	ErrMissingEstimator = errors.New("a probability estimator must be set")
 )
 
 // NodeResults contains previous results from a node to its peers.
@@ -102,7 +111,7 @@ type MissionControl struct {
 
 	// estimator is the probability estimator that is used with the payment
 	// results that mission control collects.
-	estimator *probabilityEstimator
+	estimator Estimator
 
 	sync.Mutex
 
@@ -115,9 +124,9 @@ type MissionControl struct {
 // MissionControlConfig defines parameters that control mission control
 // behaviour.
 type MissionControlConfig struct {
-	// ProbabilityEstimatorConfig is the config we will use for probability
-	// calculations.
-	ProbabilityEstimatorCfg
+	// Estimator gives probability estimates for node pairs. It can be
+	// swapped at runtime with SetConfig.
+	Estimator Estimator
 
 	// MaxMcHistory defines the maximum number of payment results that are
 	// held on disk.
@@ -134,7 +143,11 @@ type MissionControlConfig struct {
 }
 
 func (c *MissionControlConfig) validate() error {
-	if err := c.ProbabilityEstimatorCfg.validate(); err != nil {
+	if c.Estimator == nil {
+		return ErrMissingEstimator
+	}
+
+	if err := c.Estimator.Config().validate(); err != nil {
 		return err
 	}
 
@@ -151,11 +164,9 @@ func (c *MissionControlConfig) validate() error {
 
 // String returns a string representation of a mission control config.
 func (c *MissionControlConfig) String() string {
-	return fmt.Sprintf("Penalty Half Life: %v, Apriori Hop "+
-		"Probablity: %v, Maximum History: %v, Apriori Weight: %v, "+
-		"Minimum Failure Relax Interval: %v", c.PenaltyHalfLife,
-		c.AprioriHopProbability, c.MaxMcHistory, c.AprioriWeight,
-		c.MinFailureRelaxInterval)
+	return fmt.Sprintf("Maximum History: %v, Minimum Failure Relax "+
+		"Interval: %v, Estimator: %v", c.MaxMcHistory,
+		c.MinFailureRelaxInterval, c.Estimator)
 }
 
 // TimedPairResult describes a timestamped pair result.
@@ -224,17 +235,12 @@ func NewMissionControl(db kvdb.Backend, self route.Vertex,
 		return nil, err
 	}
 
-	estimator := &probabilityEstimator{
-		ProbabilityEstimatorCfg: cfg.ProbabilityEstimatorCfg,
-		prevSuccessProbability:  prevSuccessProbability,
-	}
-
 	mc := &MissionControl{
 		state:     newMissionControlState(cfg.MinFailureRelaxInterval),
 		now:       time.Now,
 		selfNode:  self,
 		store:     store,
-		estimator: estimator,
+		estimator: cfg.Estimator,
 	}
 
 	if err := mc.init(); err != nil {
@@ -265,10 +271,37 @@ func (m *MissionControl) init() error {
 		return err
 	}
 
+	pairs, err := m.store.fetchPairs()
+	if err != nil {
+		return err
+	}
+
+	// Merged pair results are restored in between the payment results,
+	// at the time they were merged at, so they neither override more
+	// recent payment results nor are overridden by older ones.
+	restorePair := func(pair *mergedPair) {
+		m.state.mergeSnapshot(&MissionControlSnapshot{
+			Pairs: []MissionControlPairSnapshot{
+				pair.MissionControlPairSnapshot,
+			},
+		}, MergeOverwrite)
+	}
+
 	for _, result := range results {
+		for len(pairs) > 0 &&
+			!pairs[0].mergeTime.After(result.timeReply) {
+
+			restorePair(pairs[0])
+			pairs = pairs[1:]
+		}
+
 		m.applyPaymentResult(result)
 	}
 
+	for _, pair := range pairs {
+		restorePair(pair)
+	}
+
 	log.Debugf("Mission control state reconstruction finished: "+
 		"n=%v, time=%v", len(results), time.Since(start))
 
@@ -276,14 +309,15 @@ func (m *MissionControl) init() error {
 }
 
 // GetConfig returns the config that mission control is currently configured
-// with. All fields are copied by value, so we do not need to worry about
+// with. All fields are copied by value, except for the estimator, which is
+// replaced rather than mutated by SetConfig, so we do not need to worry about
 // mutation.
 func (m *MissionControl) GetConfig() *MissionControlConfig {
 	m.Lock()
 	defer m.Unlock()
 
 	return &MissionControlConfig{
-		ProbabilityEstimatorCfg: m.estimator.ProbabilityEstimatorCfg,
+		Estimator:               m.estimator,
 		MaxMcHistory:            m.store.maxRecords,
 		McFlushInterval:         m.store.flushInterval,
 		MinFailureRelaxInterval: m.state.minFailureRelaxInterval,
@@ -308,7 +342,7 @@ func (m *MissionControl) SetConfig(cfg *MissionControlConfig) error {
 
 	m.store.maxRecords = cfg.MaxMcHistory
 	m.state.minFailureRelaxInterval = cfg.MinFailureRelaxInterval
-	m.estimator.ProbabilityEstimatorCfg = cfg.ProbabilityEstimatorCfg
+	m.estimator = cfg.Estimator
 
 	return nil
 }
@@ -331,9 +365,10 @@ func (m *MissionControl) ResetHistory() error {
 }
 
 // GetProbability is expected to return the success probability of a payment
-// from fromNode along edge.
+// from fromNode along edge. The capacity is the capacity of the connection
+// between the two nodes, which is zero if it is unknown.
 func (m *MissionControl) GetProbability(fromNode, toNode route.Vertex,
-	amt lnwire.MilliSatoshi) float64 {
+	amt lnwire.MilliSatoshi, capacity bronutil.Amount) float64 {
 
 	m.Lock()
 	defer m.Unlock()
@@ -343,10 +378,12 @@ func (m *MissionControl) GetProbability(fromNode, toNode route.Vertex,
 
 	// Use a distinct probability estimation function for local channels.
 	if fromNode == m.selfNode {
-		return m.estimator.getLocalPairProbability(now, results, toNode)
+		return m.estimator.LocalPairProbability(now, results, toNode)
 	}
 
-	return m.estimator.getPairProbability(now, results, toNode, amt)
+	return m.estimator.PairProbability(
+		now, results, toNode, amt, capacity,
+	)
 }
 
 // GetHistorySnapshot takes a snapshot from the current mission control state
@@ -383,6 +420,34 @@ func (m *MissionControl) ImportHistory(history *MissionControlSnapshot,
 	return nil
 }
 
+// MergeHistory merges the mission control results of the given snapshot into
+// our state, resolving conflicts with the existing results of a pair with the
+// given merge strategy. It returns the number of pairs whose results changed.
+// Contrary to imported results, the merged results are persisted, so they
+// survive restarts.
+func (m *MissionControl) MergeHistory(history *MissionControlSnapshot,
+	strategy MergeStrategy) (int, error) {
+
+	if history == nil {
+		return 0, errors.New("cannot merge nil history")
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	log.Infof("Merging history snapshot with %v pairs into mission "+
+		"control using strategy %v", len(history.Pairs), strategy)
+
+	merged := m.state.mergeSnapshot(history, strategy)
+	if err := m.store.storePairs(merged, m.now()); err != nil {
+		return 0, err
+	}
+
+	log.Infof("Merged %v pairs into mission control", len(merged))
+
+	return len(merged), nil
+}
+
 // GetPairHistorySnapshot returns the stored history for a given node pair.
 func (m *MissionControl) GetPairHistorySnapshot(
 	fromNode, toNode route.Vertex) TimedPairResult {
@@ -0,0 +1,197 @@
+package routing
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/brsuite/broln/lnwire"
+	"github.com/brsuite/broln/routing/route"
+)
+
+const (
+	// MissionControlExportVersion is the version of the mission control
+	// export file format that is written by this version of broln.
+	MissionControlExportVersion = 1
+)
+
+var (
+	// ErrUnknownExportVersion is returned when a mission control export
+	// file has a version that we don't know how to read.
+	ErrUnknownExportVersion = errors.New("unknown mission control " +
+		"export version")
+)
+
+// MergeStrategy defines how imported mission control results are merged with
+// the results that mission control already has for a node pair.
+type MergeStrategy uint8
+
+const (
+	// MergeOverwrite replaces the existing results of a pair with the
+	// imported ones.
+	MergeOverwrite MergeStrategy = iota
+
+	// MergeKeepNewer keeps the more recent of the existing and imported
+	// failures, and likewise for successes.
+	MergeKeepNewer
+
+	// MergeKeepStrongerFailure keeps the failure with the lower amount,
+	// which is the more restrictive one, and the more recent success. If
+	// the failure amounts are equal, the more recent failure is kept.
+	MergeKeepStrongerFailure
+)
+
+// String returns the name of the merge strategy, as used in the config and
+// on the command line.
+func (s MergeStrategy) String() string {
+	switch s {
+	case MergeOverwrite:
+		return "overwrite"
+
+	case MergeKeepNewer:
+		return "keep-newer"
+
+	case MergeKeepStrongerFailure:
+		return "keep-stronger-failure"
+
+	default:
+		return fmt.Sprintf("unknown<%d>", uint8(s))
+	}
+}
+
+// ParseMergeStrategy returns the merge strategy with the given name.
+func ParseMergeStrategy(name string) (MergeStrategy, error) {
+	for _, s := range []MergeStrategy{
+		MergeOverwrite, MergeKeepNewer, MergeKeepStrongerFailure,
+	} {
+		if s.String() == name {
+			return s, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown merge strategy %q", name)
+}
+
+// mcExportFile is the serialized form of a mission control snapshot.
+type mcExportFile struct {
+	// Version is the version of the file format.
+	Version uint32 `json:"version"`
+
+	// CreatedAt is the unix timestamp at which the export was created.
+	CreatedAt int64 `json:"created_at"`
+
+	// Pairs holds the results of all node pairs.
+	Pairs []mcExportPair `json:"pairs"`
+}
+
+// mcExportPair is the serialized form of the results of a node pair. Times are
+// unix timestamps in seconds and are zero if there is no such result.
+type mcExportPair struct {
+	From           string `json:"from"`
+	To             string `json:"to"`
+	FailTime       int64  `json:"fail_time,omitempty"`
+	FailAmtMsat    uint64 `json:"fail_amt_msat,omitempty"`
+	SuccessTime    int64  `json:"success_time,omitempty"`
+	SuccessAmtMsat uint64 `json:"success_amt_msat,omitempty"`
+}
+
+// unixTime converts a time to a unix timestamp, mapping the zero time to zero.
+func unixTime(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+
+	return t.Unix()
+}
+
+// fromUnixTime converts a unix timestamp to a time, mapping zero to the zero
+// time.
+func fromUnixTime(ts int64) time.Time {
+	if ts == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(ts, 0)
+}
+
+// WriteMissionControlSnapshot writes the given snapshot to w in the current
+// version of the export file format. Timestamps are stored with a precision of
+// seconds.
+func WriteMissionControlSnapshot(w io.Writer, snapshot *MissionControlSnapshot,
+	now time.Time) error {
+
+	file := mcExportFile{
+		Version:   MissionControlExportVersion,
+		CreatedAt: now.Unix(),
+		Pairs:     make([]mcExportPair, 0, len(snapshot.Pairs)),
+	}
+
+	for _, pair := range snapshot.Pairs {
+		file.Pairs = append(file.Pairs, mcExportPair{
+			From:           pair.Pair.From.String(),
+			To:             pair.Pair.To.String(),
+			FailTime:       unixTime(pair.FailTime),
+			FailAmtMsat:    uint64(pair.FailAmt),
+			SuccessTime:    unixTime(pair.SuccessTime),
+			SuccessAmtMsat: uint64(pair.SuccessAmt),
+		})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "    ")
+
+	return encoder.Encode(&file)
+}
+
+// ReadMissionControlSnapshot reads a snapshot from r that was written by
+// WriteMissionControlSnapshot.
+func ReadMissionControlSnapshot(r io.Reader) (*MissionControlSnapshot, error) {
+	var file mcExportFile
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return nil, fmt.Errorf("unable to decode mission control "+
+			"export: %w", err)
+	}
+
+	if file.Version != MissionControlExportVersion {
+		return nil, fmt.Errorf("%w: %v", ErrUnknownExportVersion,
+			file.Version)
+	}
+
+	snapshot := &MissionControlSnapshot{
+		Pairs: make([]MissionControlPairSnapshot, 0, len(file.Pairs)),
+	}
+	for i, pair := range file.Pairs {
+		from, err := route.NewVertexFromStr(pair.From)
+		if err != nil {
+			return nil, fmt.Errorf("pair %d: invalid from node: %w",
+				i, err)
+		}
+
+		to, err := route.NewVertexFromStr(pair.To)
+		if err != nil {
+			return nil, fmt.Errorf("pair %d: invalid to node: %w",
+				i, err)
+		}
+
+		if pair.FailTime < 0 || pair.SuccessTime < 0 {
+			return nil, fmt.Errorf("pair %d: negative timestamp", i)
+		}
+
+		pairSnapshot := MissionControlPairSnapshot{
+			Pair: NewDirectedNodePair(from, to),
+			TimedPairResult: TimedPairResult{
+				FailTime:    fromUnixTime(pair.FailTime),
+				FailAmt:     lnwire.MilliSatoshi(pair.FailAmtMsat),
+				SuccessTime: fromUnixTime(pair.SuccessTime),
+				SuccessAmt: lnwire.MilliSatoshi(
+					pair.SuccessAmtMsat,
+				),
+			},
+		}
+		snapshot.Pairs = append(snapshot.Pairs, pairSnapshot)
+	}
+
+	return snapshot, nil
+}
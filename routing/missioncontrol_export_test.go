@@ -0,0 +1,89 @@
+package routing
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brsuite/broln/routing/route"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMissionControlExportRoundTrip asserts that a snapshot that is written to
+// an export file is read back unchanged.
+func TestMissionControlExportRoundTrip(t *testing.T) {
+	snapshot := &MissionControlSnapshot{
+		Pairs: []MissionControlPairSnapshot{
+			{
+				Pair: NewDirectedNodePair(
+					route.Vertex{1}, route.Vertex{2},
+				),
+				TimedPairResult: TimedPairResult{
+					FailTime:    testTime,
+					FailAmt:     2000,
+					SuccessTime: testTime.Add(-time.Hour),
+					SuccessAmt:  1000,
+				},
+			},
+			{
+				Pair: NewDirectedNodePair(
+					route.Vertex{2}, route.Vertex{3},
+				),
+				TimedPairResult: TimedPairResult{
+					FailTime: testTime,
+				},
+			},
+		},
+	}
+
+	var b bytes.Buffer
+	err := WriteMissionControlSnapshot(&b, snapshot, testTime)
+	require.NoError(t, err)
+
+	read, err := ReadMissionControlSnapshot(&b)
+	require.NoError(t, err)
+	require.Len(t, read.Pairs, len(snapshot.Pairs))
+
+	for i, pair := range read.Pairs {
+		expected := snapshot.Pairs[i]
+
+		require.Equal(t, expected.Pair, pair.Pair)
+		require.Equal(t, expected.FailAmt, pair.FailAmt)
+		require.Equal(t, expected.SuccessAmt, pair.SuccessAmt)
+		require.True(t, expected.FailTime.Equal(pair.FailTime))
+		require.True(t, expected.SuccessTime.Equal(pair.SuccessTime))
+	}
+}
+
+// TestMissionControlExportInvalid asserts that invalid export files are
+// rejected.
+func TestMissionControlExportInvalid(t *testing.T) {
+	_, err := ReadMissionControlSnapshot(
+		strings.NewReader(`{"version": 2, "pairs": []}`),
+	)
+	require.ErrorIs(t, err, ErrUnknownExportVersion)
+
+	_, err = ReadMissionControlSnapshot(strings.NewReader(
+		`{"version": 1, "pairs": [{"from": "00", "to": "00"}]}`,
+	))
+	require.Error(t, err)
+
+	_, err = ReadMissionControlSnapshot(strings.NewReader(`{`))
+	require.Error(t, err)
+}
+
+// TestParseMergeStrategy asserts that all merge strategies can be parsed from
+// their names.
+func TestParseMergeStrategy(t *testing.T) {
+	for _, strategy := range []MergeStrategy{
+		MergeOverwrite, MergeKeepNewer, MergeKeepStrongerFailure,
+	} {
+		parsed, err := ParseMergeStrategy(strategy.String())
+		require.NoError(t, err)
+		require.Equal(t, strategy, parsed)
+	}
+
+	_, err := ParseMergeStrategy("unknown")
+	require.Error(t, err)
+}
@@ -266,3 +266,114 @@ func (m *missionControlState) importResult(currentTs, importedTs time.Time,
 
 	return 1
 }
+
+// mergeSnapshot merges the results of the given snapshot into our current
+// state using the given merge strategy. It returns the resulting state of the
+// pairs whose results changed.
+func (m *missionControlState) mergeSnapshot(snapshot *MissionControlSnapshot,
+	strategy MergeStrategy) []MissionControlPairSnapshot {
+
+	var merged []MissionControlPairSnapshot
+
+	for _, pair := range snapshot.Pairs {
+		fromNode := pair.Pair.From
+		toNode := pair.Pair.To
+
+		nodePairs, ok := m.lastPairResult[fromNode]
+		if !ok {
+			nodePairs = make(NodeResults)
+			m.lastPairResult[fromNode] = nodePairs
+		}
+
+		current, found := nodePairs[toNode]
+		result := mergePairResults(
+			current, pair.TimedPairResult, strategy,
+		)
+
+		if found && result == current {
+			continue
+		}
+
+		log.Debugf("Merging %v->%v range [%v-%v] into [%v-%v] (%v)",
+			fromNode, toNode, pair.SuccessAmt, pair.FailAmt,
+			current.SuccessAmt, current.FailAmt, strategy)
+
+		nodePairs[toNode] = result
+		merged = append(merged, MissionControlPairSnapshot{
+			Pair:            pair.Pair,
+			TimedPairResult: result,
+		})
+	}
+
+	return merged
+}
+
+// mergePairResults merges an imported result of a pair into the current one
+// using the given merge strategy.
+func mergePairResults(current, imported TimedPairResult,
+	strategy MergeStrategy) TimedPairResult {
+
+	if strategy == MergeOverwrite {
+		return reconcilePairResult(imported)
+	}
+
+	result := current
+
+	// Successes are merged in the same way for all the remaining
+	// strategies, by keeping the more recent one.
+	if imported.SuccessTime.After(current.SuccessTime) {
+		result.SuccessTime = imported.SuccessTime
+		result.SuccessAmt = imported.SuccessAmt
+	}
+
+	var useImportedFail bool
+	switch {
+	// Without an imported failure, there is nothing to merge.
+	case imported.FailTime.IsZero():
+
+	// Without a current failure, the imported one is always used.
+	case current.FailTime.IsZero():
+		useImportedFail = true
+
+	// A failure for a lower amount is the more restrictive one. With equal
+	// amounts, the more recent failure is used.
+	case strategy == MergeKeepStrongerFailure:
+		useImportedFail = imported.FailAmt < current.FailAmt ||
+			(imported.FailAmt == current.FailAmt &&
+				imported.FailTime.After(current.FailTime))
+
+	default:
+		useImportedFail = imported.FailTime.After(current.FailTime)
+	}
+
+	if useImportedFail {
+		result.FailTime = imported.FailTime
+		result.FailAmt = imported.FailAmt
+	}
+
+	return reconcilePairResult(result)
+}
+
+// reconcilePairResult makes sure that the success and failure ranges of a pair
+// result don't overlap. Like for live payment results, the more recent result
+// takes precedence and pushes the range of the older one.
+func reconcilePairResult(result TimedPairResult) TimedPairResult {
+	if result.FailTime.IsZero() || result.SuccessTime.IsZero() ||
+		result.SuccessAmt < result.FailAmt {
+
+		return result
+	}
+
+	switch {
+	case result.SuccessTime.After(result.FailTime):
+		result.FailAmt = result.SuccessAmt + 1
+
+	case result.FailAmt == 0:
+		result.SuccessAmt = 0
+
+	default:
+		result.SuccessAmt = result.FailAmt - 1
+	}
+
+	return result
+}
@@ -92,3 +92,141 @@ func TestMissionControlStateSetLastPairResult(t *testing.T) {
 	require.Equal(t, expected, result[to])
 
 }
+
+// TestMissionControlStateMergeSnapshot tests merging a snapshot into the
+// mission control state using the different merge strategies.
+func TestMissionControlStateMergeSnapshot(t *testing.T) {
+	var (
+		from    = route.Vertex{1}
+		to      = route.Vertex{2}
+		newNode = route.Vertex{3}
+
+		current = TimedPairResult{
+			FailTime:    testTime,
+			FailAmt:     1000,
+			SuccessTime: testTime,
+			SuccessAmt:  500,
+		}
+	)
+
+	tests := []struct {
+		name           string
+		strategy       MergeStrategy
+		imported       TimedPairResult
+		expected       TimedPairResult
+		expectedMerged int
+	}{
+		{
+			name:     "overwrite",
+			strategy: MergeOverwrite,
+			imported: TimedPairResult{
+				FailTime:    testTime.Add(time.Hour),
+				FailAmt:     2000,
+				SuccessTime: testTime.Add(-time.Hour),
+				SuccessAmt:  800,
+			},
+			expected: TimedPairResult{
+				FailTime:    testTime.Add(time.Hour),
+				FailAmt:     2000,
+				SuccessTime: testTime.Add(-time.Hour),
+				SuccessAmt:  800,
+			},
+			expectedMerged: 2,
+		},
+		{
+			name:     "keep newer",
+			strategy: MergeKeepNewer,
+			imported: TimedPairResult{
+				FailTime:    testTime.Add(time.Hour),
+				FailAmt:     2000,
+				SuccessTime: testTime.Add(-time.Hour),
+				SuccessAmt:  800,
+			},
+			expected: TimedPairResult{
+				FailTime:    testTime.Add(time.Hour),
+				FailAmt:     2000,
+				SuccessTime: testTime,
+				SuccessAmt:  500,
+			},
+			expectedMerged: 2,
+		},
+		{
+			name:     "keep newer overlapping ranges",
+			strategy: MergeKeepNewer,
+			imported: TimedPairResult{
+				FailTime: testTime.Add(time.Hour),
+				FailAmt:  400,
+			},
+			expected: TimedPairResult{
+				FailTime:    testTime.Add(time.Hour),
+				FailAmt:     400,
+				SuccessTime: testTime,
+				SuccessAmt:  399,
+			},
+			expectedMerged: 2,
+		},
+		{
+			name:     "keep stronger failure",
+			strategy: MergeKeepStrongerFailure,
+			imported: TimedPairResult{
+				FailTime:    testTime.Add(time.Hour),
+				FailAmt:     2000,
+				SuccessTime: testTime.Add(-time.Hour),
+				SuccessAmt:  800,
+			},
+			expected:       current,
+			expectedMerged: 1,
+		},
+		{
+			name:     "keep stronger amount independent failure",
+			strategy: MergeKeepStrongerFailure,
+			imported: TimedPairResult{
+				FailTime: testTime.Add(-time.Hour),
+				FailAmt:  0,
+			},
+			expected: TimedPairResult{
+				FailTime:    testTime.Add(-time.Hour),
+				FailAmt:     501,
+				SuccessTime: testTime,
+				SuccessAmt:  500,
+			},
+			expectedMerged: 2,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			state := newMissionControlState(time.Minute)
+			state.lastPairResult[from] = NodeResults{to: current}
+
+			snapshot := &MissionControlSnapshot{
+				Pairs: []MissionControlPairSnapshot{
+					{
+						Pair: NewDirectedNodePair(
+							from, to,
+						),
+						TimedPairResult: test.imported,
+					},
+					{
+						Pair: NewDirectedNodePair(
+							from, newNode,
+						),
+						TimedPairResult: test.imported,
+					},
+				},
+			}
+
+			merged := state.mergeSnapshot(snapshot, test.strategy)
+			require.Len(t, merged, test.expectedMerged)
+
+			results, _ := state.getLastPairResult(from)
+			require.Equal(t, test.expected, results[to])
+			require.Equal(
+				t, reconcilePairResult(test.imported),
+				results[newNode],
+			)
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"container/list"
 	"encoding/binary"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
@@ -12,6 +13,7 @@ import (
 	"github.com/brsuite/broln/channeldb"
 	"github.com/brsuite/broln/kvdb"
 	"github.com/brsuite/broln/lnwire"
+	"github.com/brsuite/broln/routing/route"
 )
 
 var (
@@ -19,6 +21,10 @@ var (
 	// stored.
 	resultsKey = []byte("missioncontrol-results")
 
+	// pairsKey is the fixed key under which the pair results merged into
+	// mission control are stored.
+	pairsKey = []byte("missioncontrol-pairs")
+
 	// Big endian is the preferred byte order, due to cursor scans over
 	// integer keys iterating in order.
 	byteOrder = binary.BigEndian
@@ -78,6 +84,11 @@ func newMissionControlStore(db kvdb.Backend, maxRecords int,
 				err)
 		}
 
+		_, err = tx.CreateTopLevelBucket(pairsKey)
+		if err != nil {
+			return fmt.Errorf("cannot create pairs bucket: %v", err)
+		}
+
 		// Collect all keys to be able to quickly calculate the
 		// difference when updating the DB state.
 		c := resultsBucket.ReadCursor()
@@ -106,18 +117,23 @@ func newMissionControlStore(db kvdb.Backend, maxRecords int,
 	}, nil
 }
 
-// clear removes all results from the db.
+// clear removes all results and merged pair results from the db.
 func (b *missionControlStore) clear() error {
 	b.queueMx.Lock()
 	defer b.queueMx.Unlock()
 
 	err := kvdb.Update(b.db, func(tx kvdb.RwTx) error {
-		if err := tx.DeleteTopLevelBucket(resultsKey); err != nil {
-			return err
+		for _, key := range [][]byte{resultsKey, pairsKey} {
+			if err := tx.DeleteTopLevelBucket(key); err != nil {
+				return err
+			}
+
+			if _, err := tx.CreateTopLevelBucket(key); err != nil {
+				return err
+			}
 		}
 
-		_, err := tx.CreateTopLevelBucket(resultsKey)
-		return err
+		return nil
 	}, func() {})
 
 	if err != nil {
@@ -157,6 +173,148 @@ func (b *missionControlStore) fetchAll() ([]*paymentResult, error) {
 	return results, nil
 }
 
+// mergedPair is a pair result that was merged into mission control, along
+// with the time it was merged at.
+type mergedPair struct {
+	MissionControlPairSnapshot
+
+	// mergeTime is the time the pair result was merged at.
+	mergeTime time.Time
+}
+
+// storePairs stores the given pair results that were merged into mission
+// control at the given time, replacing earlier merged results of the same
+// pairs.
+func (b *missionControlStore) storePairs(pairs []MissionControlPairSnapshot,
+	mergeTime time.Time) error {
+
+	return kvdb.Update(b.db, func(tx kvdb.RwTx) error {
+		bucket := tx.ReadWriteBucket(pairsKey)
+
+		for _, pair := range pairs {
+			k, v, err := serializePair(&mergedPair{
+				MissionControlPairSnapshot: pair,
+				mergeTime:                  mergeTime,
+			})
+			if err != nil {
+				return err
+			}
+
+			if err := bucket.Put(k, v); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}, func() {})
+}
+
+// fetchPairs returns all merged pair results currently stored in the
+// database, ordered by the time they were merged at.
+func (b *missionControlStore) fetchPairs() ([]*mergedPair, error) {
+	var pairs []*mergedPair
+
+	err := kvdb.View(b.db, func(tx kvdb.RTx) error {
+		bucket := tx.ReadBucket(pairsKey)
+
+		return bucket.ForEach(func(k, v []byte) error {
+			pair, err := deserializePair(k, v)
+			if err != nil {
+				return err
+			}
+
+			pairs = append(pairs, pair)
+
+			return nil
+		})
+	}, func() {
+		pairs = nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(pairs, func(i, j int) bool {
+		return pairs[i].mergeTime.Before(pairs[j].mergeTime)
+	})
+
+	return pairs, nil
+}
+
+// serializePair serializes a merged pair result and returns a key and value
+// byte slice to insert into the bucket.
+func serializePair(pair *mergedPair) ([]byte, []byte, error) {
+	var b bytes.Buffer
+
+	err := channeldb.WriteElements(
+		&b,
+		timeToUnixNano(pair.mergeTime),
+		timeToUnixNano(pair.FailTime), uint64(pair.FailAmt),
+		timeToUnixNano(pair.SuccessTime), uint64(pair.SuccessAmt),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var key [2 * route.VertexSize]byte
+	copy(key[:], pair.Pair.From[:])
+	copy(key[route.VertexSize:], pair.Pair.To[:])
+
+	return key[:], b.Bytes(), nil
+}
+
+// deserializePair deserializes a merged pair result.
+func deserializePair(k, v []byte) (*mergedPair, error) {
+	if len(k) != 2*route.VertexSize {
+		return nil, fmt.Errorf("invalid pair key length %v", len(k))
+	}
+
+	var (
+		pair                             mergedPair
+		mergeTime, failTime, successTime uint64
+		failAmt, successAmt              uint64
+	)
+
+	copy(pair.Pair.From[:], k[:route.VertexSize])
+	copy(pair.Pair.To[:], k[route.VertexSize:])
+
+	err := channeldb.ReadElements(
+		bytes.NewReader(v), &mergeTime, &failTime, &failAmt,
+		&successTime, &successAmt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	pair.mergeTime = unixNanoToTime(mergeTime)
+	pair.FailTime = unixNanoToTime(failTime)
+	pair.FailAmt = lnwire.MilliSatoshi(failAmt)
+	pair.SuccessTime = unixNanoToTime(successTime)
+	pair.SuccessAmt = lnwire.MilliSatoshi(successAmt)
+
+	return &pair, nil
+}
+
+// timeToUnixNano returns the database encoding of the given time, which maps
+// the zero time to zero.
+func timeToUnixNano(t time.Time) uint64 {
+	if t.IsZero() {
+		return 0
+	}
+
+	return uint64(t.UnixNano())
+}
+
+// unixNanoToTime converts the database encoding of a time back into a time in
+// the local time zone.
+func unixNanoToTime(nanos uint64) time.Time {
+	if nanos == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(0, int64(nanos)).Local()
+}
+
 // serializeResult serializes a payment result and returns a key and value byte
 // slice to insert into the bucket.
 func serializeResult(rp *paymentResult) ([]byte, []byte, error) {
@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/brsuite/bronutil"
 	"github.com/brsuite/broln/kvdb"
 	"github.com/brsuite/broln/lnwire"
 	"github.com/brsuite/broln/routing/route"
@@ -38,6 +39,7 @@ var (
 	testPenaltyHalfLife       = 30 * time.Minute
 	testAprioriHopProbability = 0.9
 	testAprioriWeight         = 0.5
+	testCapacity              = bronutil.Amount(100_000)
 )
 
 type mcTestContext struct {
@@ -84,14 +86,16 @@ func (ctx *mcTestContext) restartMc() {
 		require.NoError(ctx.t, ctx.mc.store.storeResults())
 	}
 
+	estimator, err := NewAprioriEstimator(AprioriConfig{
+		PenaltyHalfLife:       testPenaltyHalfLife,
+		AprioriHopProbability: testAprioriHopProbability,
+		AprioriWeight:         testAprioriWeight,
+	})
+	require.NoError(ctx.t, err)
+
 	mc, err := NewMissionControl(
-		ctx.db, mcTestSelf,
-		&MissionControlConfig{
-			ProbabilityEstimatorCfg: ProbabilityEstimatorCfg{
-				PenaltyHalfLife:       testPenaltyHalfLife,
-				AprioriHopProbability: testAprioriHopProbability,
-				AprioriWeight:         testAprioriWeight,
-			},
+		ctx.db, mcTestSelf, &MissionControlConfig{
+			Estimator: estimator,
 		},
 	)
 	if err != nil {
@@ -112,7 +116,9 @@ func (ctx *mcTestContext) cleanup() {
 func (ctx *mcTestContext) expectP(amt lnwire.MilliSatoshi, expected float64) {
 	ctx.t.Helper()
 
-	p := ctx.mc.GetProbability(mcTestNode1, mcTestNode2, amt)
+	p := ctx.mc.GetProbability(
+		mcTestNode1, mcTestNode2, amt, testCapacity,
+	)
 	if p != expected {
 		ctx.t.Fatalf("expected probability %v but got %v", expected, p)
 	}
@@ -151,7 +157,9 @@ func TestMissionControl(t *testing.T) {
 
 	// For local channels, we expect a higher probability than our a prior
 	// test probability.
-	selfP := ctx.mc.GetProbability(mcTestSelf, mcTestNode1, 100)
+	selfP := ctx.mc.GetProbability(
+		mcTestSelf, mcTestNode1, 100, testCapacity,
+	)
 	if selfP != prevSuccessProbability {
 		t.Fatalf("expected prev success prob for untried local chans")
 	}
@@ -223,3 +231,52 @@ func TestMissionControlChannelUpdate(t *testing.T) {
 	)
 	ctx.expectP(100, 0)
 }
+
+// TestMissionControlMergePersistence tests that pairs merged into mission
+// control survive a restart, without overriding payment results that were
+// reported after the merge.
+func TestMissionControlMergePersistence(t *testing.T) {
+	ctx := createMcTestContext(t)
+	defer ctx.cleanup()
+
+	ctx.now = testTime
+
+	merged, err := ctx.mc.MergeHistory(&MissionControlSnapshot{
+		Pairs: []MissionControlPairSnapshot{{
+			Pair: NewDirectedNodePair(mcTestNode1, mcTestNode2),
+			TimedPairResult: TimedPairResult{
+				FailTime:    testTime.Add(-time.Hour),
+				FailAmt:     2000,
+				SuccessTime: testTime.Add(-2 * time.Hour),
+				SuccessAmt:  500,
+			},
+		}},
+	}, MergeOverwrite)
+	require.NoError(t, err)
+	require.Equal(t, 1, merged)
+
+	// The merged result is expected to be restored after a restart.
+	ctx.restartMc()
+	result := ctx.mc.GetPairHistorySnapshot(mcTestNode1, mcTestNode2)
+	require.True(t, result.FailTime.Equal(testTime.Add(-time.Hour)))
+	require.Equal(t, lnwire.MilliSatoshi(2000), result.FailAmt)
+	require.True(t, result.SuccessTime.Equal(testTime.Add(-2*time.Hour)))
+	require.Equal(t, lnwire.MilliSatoshi(500), result.SuccessAmt)
+
+	// A failure reported after the merge must still take precedence over
+	// the merged result once mission control is restarted.
+	ctx.now = testTime.Add(time.Minute)
+	ctx.reportFailure(1000, lnwire.NewTemporaryChannelFailure(nil))
+
+	ctx.restartMc()
+	result = ctx.mc.GetPairHistorySnapshot(mcTestNode1, mcTestNode2)
+	require.True(t, result.FailTime.Equal(ctx.now))
+	require.Equal(t, lnwire.MilliSatoshi(1000), result.FailAmt)
+
+	// Resetting the history is expected to remove the merged pairs as
+	// well.
+	require.NoError(t, ctx.mc.ResetHistory())
+
+	ctx.restartMc()
+	require.Empty(t, ctx.mc.GetHistorySnapshot().Pairs)
+}
@@ -0,0 +1,121 @@
+package routing
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/brsuite/broln/routing/route"
+)
+
+// nodeExclusions determines which nodes may not be used in the routes of a
+// payment, either because they are excluded explicitly or because of where
+// they are located.
+type nodeExclusions struct {
+	nodes     map[route.Vertex]struct{}
+	countries map[string]struct{}
+	asns      map[uint32]struct{}
+
+	// excludeUnlocated indicates whether nodes without any address of a
+	// known location are excluded when excluding by location.
+	excludeUnlocated bool
+
+	// locateNode returns the locations of the addresses that a node
+	// advertises. It must be set if countries or autonomous systems are
+	// excluded.
+	locateNode func(route.Vertex) ([]*NodeLocation, error)
+
+	// located caches the outcome of locating nodes, so that the graph
+	// only needs to be queried once per node for the whole payment.
+	located map[route.Vertex]bool
+	mu      sync.Mutex
+}
+
+// newNodeExclusions returns the node exclusions of the given payment.
+func newNodeExclusions(p *LightningPayment) *nodeExclusions {
+	e := &nodeExclusions{
+		nodes:     make(map[route.Vertex]struct{}),
+		countries: make(map[string]struct{}),
+		asns:      make(map[uint32]struct{}),
+		located:   make(map[route.Vertex]bool),
+
+		excludeUnlocated: p.ExcludeUnlocatedNodes,
+	}
+
+	for _, node := range p.ExcludedNodes {
+		e.nodes[node] = struct{}{}
+	}
+	for _, country := range p.ExcludedCountries {
+		e.countries[strings.ToUpper(country)] = struct{}{}
+	}
+	for _, asn := range p.ExcludedASNs {
+		e.asns[asn] = struct{}{}
+	}
+
+	return e
+}
+
+// empty returns true if no node is excluded.
+func (e *nodeExclusions) empty() bool {
+	return len(e.nodes) == 0 && !e.byLocation()
+}
+
+// byLocation returns true if nodes are excluded based on their location.
+func (e *nodeExclusions) byLocation() bool {
+	return len(e.countries) > 0 || len(e.asns) > 0
+}
+
+// isExcluded returns true if the node may not be used in a route.
+func (e *nodeExclusions) isExcluded(node route.Vertex) bool {
+	if _, ok := e.nodes[node]; ok {
+		return true
+	}
+
+	if !e.byLocation() {
+		return false
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	excluded, ok := e.located[node]
+	if !ok {
+		excluded = e.excludedByLocation(node)
+		e.located[node] = excluded
+	}
+
+	return excluded
+}
+
+// excludedByLocation returns true if any of the addresses of the node is
+// located in an excluded country or autonomous system. Addresses with an
+// unknown location don't exclude a node, unless none of its addresses has a
+// known location and unlocated nodes are excluded. A node is excluded if its
+// addresses can't be retrieved at all.
+func (e *nodeExclusions) excludedByLocation(node route.Vertex) bool {
+	locations, err := e.locateNode(node)
+	if err != nil {
+		log.Warnf("Excluding node %v from routes, unable to locate "+
+			"it: %v", node, err)
+
+		return true
+	}
+
+	for _, location := range locations {
+		if _, ok := e.countries[location.Country]; ok {
+			return true
+		}
+
+		if _, ok := e.asns[location.ASN]; ok {
+			return true
+		}
+	}
+
+	if len(locations) == 0 && e.excludeUnlocated {
+		log.Debugf("Excluding node %v from routes, none of its "+
+			"addresses has a known location", node)
+
+		return true
+	}
+
+	return false
+}
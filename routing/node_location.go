@@ -0,0 +1,165 @@
+package routing
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// NodeLocation describes where a network address of a node is located.
+type NodeLocation struct {
+	// Country is the upper case ISO 3166-1 alpha-2 code of the country the
+	// address is located in. Empty if unknown.
+	Country string
+
+	// ASN is the number of the autonomous system that announces the
+	// address. Zero if unknown.
+	ASN uint32
+}
+
+// NodeLocator derives locations from the addresses that nodes advertise.
+type NodeLocator interface {
+	// LocateAddr returns the location of the given address, or nil if its
+	// location is unknown.
+	LocateAddr(addr net.Addr) *NodeLocation
+}
+
+// PrefixLocator is a NodeLocator that looks up addresses in a static table of
+// IP prefixes. Addresses that match multiple prefixes are located by the most
+// specific one.
+type PrefixLocator struct {
+	// prefixes maps the string representation of the prefixes to their
+	// location.
+	prefixes map[string]*NodeLocation
+
+	// ipv4Lengths and ipv6Lengths are the prefix lengths that are present
+	// in the table, ordered from most to least specific.
+	ipv4Lengths []int
+	ipv6Lengths []int
+}
+
+// A compile time check to ensure PrefixLocator implements the NodeLocator
+// interface.
+var _ NodeLocator = (*PrefixLocator)(nil)
+
+// NewPrefixLocator reads a prefix table from r. Every record of the table
+// consists of three comma separated fields: an IP prefix in CIDR notation, a
+// country code and an autonomous system number. The country may be left empty
+// and the autonomous system number may be zero if they are unknown. Lines
+// starting with # are ignored.
+func NewPrefixLocator(r io.Reader) (*PrefixLocator, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = 3
+	reader.TrimLeadingSpace = true
+
+	locator := &PrefixLocator{
+		prefixes: make(map[string]*NodeLocation),
+	}
+	ipv4Lengths := make(map[int]struct{})
+	ipv6Lengths := make(map[int]struct{})
+
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		_, prefix, err := net.ParseCIDR(fields[0])
+		if err != nil {
+			return nil, err
+		}
+
+		asn, err := strconv.ParseUint(fields[2], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid asn for prefix %v: %v",
+				prefix, err)
+		}
+
+		locator.prefixes[prefix.String()] = &NodeLocation{
+			Country: strings.ToUpper(fields[1]),
+			ASN:     uint32(asn),
+		}
+
+		ones, bits := prefix.Mask.Size()
+		if bits == net.IPv4len*8 {
+			ipv4Lengths[ones] = struct{}{}
+		} else {
+			ipv6Lengths[ones] = struct{}{}
+		}
+	}
+
+	locator.ipv4Lengths = sortedLengths(ipv4Lengths)
+	locator.ipv6Lengths = sortedLengths(ipv6Lengths)
+
+	return locator, nil
+}
+
+// LoadPrefixLocator reads a prefix table from the file at the given path. See
+// NewPrefixLocator for the format of the file.
+func LoadPrefixLocator(path string) (*PrefixLocator, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	locator, err := NewPrefixLocator(f)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read prefix table %v: %v",
+			path, err)
+	}
+
+	return locator, nil
+}
+
+// LocateAddr returns the location of the most specific prefix that contains
+// the address. Only TCP addresses can be located.
+//
+// NOTE: Part of the NodeLocator interface.
+func (p *PrefixLocator) LocateAddr(addr net.Addr) *NodeLocation {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return nil
+	}
+
+	ip := tcpAddr.IP.To4()
+	lengths, bits := p.ipv4Lengths, net.IPv4len*8
+	if ip == nil {
+		ip = tcpAddr.IP.To16()
+		lengths, bits = p.ipv6Lengths, net.IPv6len*8
+	}
+	if ip == nil {
+		return nil
+	}
+
+	for _, ones := range lengths {
+		mask := net.CIDRMask(ones, bits)
+		prefix := net.IPNet{IP: ip.Mask(mask), Mask: mask}
+
+		if location, ok := p.prefixes[prefix.String()]; ok {
+			return location
+		}
+	}
+
+	return nil
+}
+
+// sortedLengths returns the prefix lengths in descending order.
+func sortedLengths(lengths map[int]struct{}) []int {
+	sorted := make([]int, 0, len(lengths))
+	for length := range lengths {
+		sorted = append(sorted, length)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(sorted)))
+
+	return sorted
+}
@@ -0,0 +1,101 @@
+package routing
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/brsuite/broln/tor"
+	"github.com/stretchr/testify/require"
+)
+
+const testPrefixTable = `# prefix,country,asn
+203.0.113.0/24,nl,64500
+203.0.113.128/25,de,64501
+198.51.100.0/24,,64502
+2001:db8::/32,US,0
+`
+
+// TestPrefixLocator tests that addresses are located by the most specific
+// prefix that contains them.
+func TestPrefixLocator(t *testing.T) {
+	t.Parallel()
+
+	locator, err := NewPrefixLocator(strings.NewReader(testPrefixTable))
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name     string
+		addr     net.Addr
+		expected *NodeLocation
+	}{
+		{
+			name: "ipv4",
+			addr: &net.TCPAddr{IP: net.ParseIP("203.0.113.1")},
+			expected: &NodeLocation{
+				Country: "NL",
+				ASN:     64500,
+			},
+		},
+		{
+			name: "most specific prefix",
+			addr: &net.TCPAddr{IP: net.ParseIP("203.0.113.200")},
+			expected: &NodeLocation{
+				Country: "DE",
+				ASN:     64501,
+			},
+		},
+		{
+			name: "unknown country",
+			addr: &net.TCPAddr{IP: net.ParseIP("198.51.100.7")},
+			expected: &NodeLocation{
+				ASN: 64502,
+			},
+		},
+		{
+			name: "ipv6",
+			addr: &net.TCPAddr{IP: net.ParseIP("2001:db8::1")},
+			expected: &NodeLocation{
+				Country: "US",
+			},
+		},
+		{
+			name: "unknown prefix",
+			addr: &net.TCPAddr{IP: net.ParseIP("192.0.2.1")},
+		},
+		{
+			name: "onion address",
+			addr: &tor.OnionAddr{
+				OnionService: "3g2upl4pq6kufc4m.onion",
+				Port:         9735,
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			require.Equal(
+				t, testCase.expected,
+				locator.LocateAddr(testCase.addr),
+			)
+		})
+	}
+}
+
+// TestPrefixLocatorInvalid tests that invalid prefix tables are rejected.
+func TestPrefixLocatorInvalid(t *testing.T) {
+	t.Parallel()
+
+	invalidTables := []string{
+		"203.0.113.0/24,NL\n",
+		"203.0.113.0,NL,64500\n",
+		"203.0.113.0/24,NL,as64500\n",
+	}
+
+	for _, table := range invalidTables {
+		_, err := NewPrefixLocator(strings.NewReader(table))
+		require.Error(t, err, table)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"math"
 	"time"
 
+	"github.com/brsuite/bronutil"
 	sphinx "github.com/brsuite/lightning-onion"
 	"github.com/brsuite/broln/channeldb"
 	"github.com/brsuite/broln/feature"
@@ -254,6 +255,32 @@ func newRoute(sourceVertex route.Vertex,
 	return newRoute, nil
 }
 
+// validateRouteFees checks that the fees of a route don't exceed the fee limit
+// and that none of its hops charges more than maxHopFee. A zero maxHopFee
+// doesn't limit the fees of individual hops.
+func validateRouteFees(rt *route.Route, feeLimit,
+	maxHopFee lnwire.MilliSatoshi) error {
+
+	if fees := rt.TotalFees(); fees > feeLimit {
+		return fmt.Errorf("route fees of %v exceed fee limit of %v",
+			fees, feeLimit)
+	}
+
+	if maxHopFee == 0 {
+		return nil
+	}
+
+	for i, hop := range rt.Hops {
+		if fee := rt.HopFee(i); fee > maxHopFee {
+			return fmt.Errorf("fee of %v charged by hop %v exceeds "+
+				"max hop fee of %v", fee, hop.PubKeyBytes,
+				maxHopFee)
+		}
+	}
+
+	return nil
+}
+
 // edgeWeight computes the weight of an edge. This value is used when searching
 // for the shortest path within the channel graph between two nodes. Weight is
 // is the fee itself plus a time lock penalty added to it. This benefits
@@ -296,14 +323,19 @@ type graphParams struct {
 // found path must adhere to.
 type RestrictParams struct {
 	// ProbabilitySource is a callback that is expected to return the
-	// success probability of traversing the channel from the node.
+	// success probability of traversing the channel from the node. The
+	// capacity of the connection is passed in, or zero if it is unknown.
 	ProbabilitySource func(route.Vertex, route.Vertex,
-		lnwire.MilliSatoshi) float64
+		lnwire.MilliSatoshi, bronutil.Amount) float64
 
 	// FeeLimit is a maximum fee amount allowed to be used on the path from
 	// the source to the target.
 	FeeLimit lnwire.MilliSatoshi
 
+	// MaxHopFee is the maximum fee that a single node on the path may
+	// charge. If zero, nodes aren't limited individually.
+	MaxHopFee lnwire.MilliSatoshi
+
 	// OutgoingChannelIDs is the list of channels that are allowed for the
 	// first hop. If nil, any channel may be used.
 	OutgoingChannelIDs []uint64
@@ -312,6 +344,19 @@ type RestrictParams struct {
 	// is reached. If nil, any node may be used.
 	LastHop *route.Vertex
 
+	// FirstHops is the set of peers that are allowed as the first hop of
+	// the path. If nil, any peer may be used.
+	FirstHops map[route.Vertex]struct{}
+
+	// NodeExcluded is an optional callback that returns true if the given
+	// node may not be used in the path. It isn't called for the source
+	// and the target of the path.
+	NodeExcluded func(route.Vertex) bool
+
+	// MaxHops is the maximum number of channels in the path. If zero, the
+	// length of the path is only limited by the routing info size.
+	MaxHops uint32
+
 	// CltvLimit is the maximum time lock of the route excluding the final
 	// ctlv. After path finding is complete, the caller needs to increase
 	// all cltv expiry heights with the required final cltv delta.
@@ -355,7 +400,7 @@ type PathFindingConfig struct {
 // channels of the given node. The second return parameters is the total
 // available balance.
 func getOutgoingBalance(node route.Vertex, outgoingChans map[uint64]struct{},
-	bandwidthHints bandwidthHints,
+	firstHops map[route.Vertex]struct{}, bandwidthHints bandwidthHints,
 	g routingGraph) (lnwire.MilliSatoshi, lnwire.MilliSatoshi, error) {
 
 	var max, total lnwire.MilliSatoshi
@@ -373,6 +418,13 @@ func getOutgoingBalance(node route.Vertex, outgoingChans map[uint64]struct{},
 			}
 		}
 
+		// Enforce first hop restriction.
+		if firstHops != nil {
+			if _, ok := firstHops[channel.OtherNode]; !ok {
+				return nil
+			}
+		}
+
 		bandwidth, ok := bandwidthHints.availableChanBandwidth(
 			chanID, 0,
 		)
@@ -489,7 +541,8 @@ func findPath(g *graphParams, r *RestrictParams, cfg *PathFindingConfig,
 
 	if source == self {
 		max, total, err := getOutgoingBalance(
-			self, outgoingChanMap, g.bandwidthHints, g.graph,
+			self, outgoingChanMap, r.FirstHops, g.bandwidthHints,
+			g.graph,
 		)
 		if err != nil {
 			return nil, err
@@ -585,7 +638,8 @@ func findPath(g *graphParams, r *RestrictParams, cfg *PathFindingConfig,
 	// satisfy our specific requirements.
 	processEdge := func(fromVertex route.Vertex,
 		fromFeatures *lnwire.FeatureVector,
-		edge *channeldb.CachedEdgePolicy, toNodeDist *nodeWithDist) {
+		edge *channeldb.CachedEdgePolicy, capacity bronutil.Amount,
+		toNodeDist *nodeWithDist) {
 
 		edgesExpanded++
 
@@ -595,7 +649,7 @@ func findPath(g *graphParams, r *RestrictParams, cfg *PathFindingConfig,
 
 		// Request the success probability for this edge.
 		edgeProbability := r.ProbabilitySource(
-			fromVertex, toNodeDist.node, amountToSend,
+			fromVertex, toNodeDist.node, amountToSend, capacity,
 		)
 
 		log.Trace(newLogClosure(func() string {
@@ -627,6 +681,24 @@ func findPath(g *graphParams, r *RestrictParams, cfg *PathFindingConfig,
 			timeLockDelta = edge.TimeLockDelta
 		}
 
+		// Check that the node doesn't charge more than we are willing
+		// to pay a single hop.
+		if r.MaxHopFee != 0 && fee > r.MaxHopFee {
+			return
+		}
+
+		// Check that the path can't get longer than allowed. Unless
+		// fromVertex is the source, at least one more channel is
+		// needed to reach it.
+		hops := toNodeDist.hops + 1
+		minHops := hops
+		if fromVertex != source {
+			minHops++
+		}
+		if r.MaxHops != 0 && minHops > r.MaxHops {
+			return
+		}
+
 		incomingCltv := toNodeDist.incomingCltv + int32(timeLockDelta)
 
 		// Check that we are within our CLTV limit.
@@ -747,6 +819,7 @@ func findPath(g *graphParams, r *RestrictParams, cfg *PathFindingConfig,
 			probability:     probability,
 			nextHop:         edge,
 			routingInfoSize: routingInfoSize,
+			hops:            hops,
 		}
 		distance[fromVertex] = withDist
 
@@ -839,6 +912,20 @@ func findPath(g *graphParams, r *RestrictParams, cfg *PathFindingConfig,
 				continue
 			}
 
+			// Apply first hop restriction if set.
+			if r.FirstHops != nil && fromNode == source {
+				if _, ok := r.FirstHops[pivot]; !ok {
+					continue
+				}
+			}
+
+			// Skip nodes that are excluded from the path.
+			if fromNode != source && r.NodeExcluded != nil &&
+				r.NodeExcluded(fromNode) {
+
+				continue
+			}
+
 			policy := unifiedPolicy.getPolicy(
 				amtToSend, g.bandwidthHints,
 			)
@@ -860,7 +947,10 @@ func findPath(g *graphParams, r *RestrictParams, cfg *PathFindingConfig,
 
 			// Check if this candidate node is better than what we
 			// already have.
-			processEdge(fromNode, fromFeatures, policy, partialPath)
+			processEdge(
+				fromNode, fromFeatures, policy,
+				unifiedPolicy.capacity(), partialPath,
+			)
 		}
 
 		if nodeHeap.Len() == 0 {
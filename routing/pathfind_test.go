@@ -104,7 +104,8 @@ var (
 
 // noProbabilitySource is used in testing to return the same probability 1 for
 // all edges.
-func noProbabilitySource(route.Vertex, route.Vertex, lnwire.MilliSatoshi) float64 {
+func noProbabilitySource(route.Vertex, route.Vertex, lnwire.MilliSatoshi,
+	bronutil.Amount) float64 {
 	return 1
 }
 
@@ -828,6 +829,12 @@ func TestPathFinding(t *testing.T) {
 	}, {
 		name: "CLTV limit",
 		fn:   runCltvLimit,
+	}, {
+		name: "max hop fee",
+		fn:   runMaxHopFee,
+	}, {
+		name: "route constraints",
+		fn:   runRouteConstraints,
 	}, {
 		name: "probability routing",
 		fn:   runProbabilityRouting,
@@ -2460,6 +2467,63 @@ func TestNewRouteFromEmptyHops(t *testing.T) {
 	}
 }
 
+// TestValidateRouteFees tests that routes exceeding the fee limit or the max
+// hop fee are rejected.
+func TestValidateRouteFees(t *testing.T) {
+	t.Parallel()
+
+	// Construct a route in which the first hop charges 300 msat and the
+	// second hop 200 msat.
+	rt := &route.Route{
+		TotalAmount: 1500,
+		Hops: []*route.Hop{
+			{PubKeyBytes: route.Vertex{1}, AmtToForward: 1200},
+			{PubKeyBytes: route.Vertex{2}, AmtToForward: 1000},
+			{PubKeyBytes: route.Vertex{3}, AmtToForward: 1000},
+		},
+	}
+
+	testCases := []struct {
+		name      string
+		feeLimit  lnwire.MilliSatoshi
+		maxHopFee lnwire.MilliSatoshi
+		valid     bool
+	}{
+		{
+			name:     "within fee limit",
+			feeLimit: 500,
+			valid:    true,
+		},
+		{
+			name:     "fee limit exceeded",
+			feeLimit: 499,
+		},
+		{
+			name:      "within max hop fee",
+			feeLimit:  500,
+			maxHopFee: 300,
+			valid:     true,
+		},
+		{
+			name:      "max hop fee exceeded",
+			feeLimit:  500,
+			maxHopFee: 299,
+		},
+	}
+
+	for _, testCase := range testCases {
+		err := validateRouteFees(
+			rt, testCase.feeLimit, testCase.maxHopFee,
+		)
+		if testCase.valid && err != nil {
+			t.Fatalf("%v: unexpected error: %v", testCase.name, err)
+		}
+		if !testCase.valid && err == nil {
+			t.Fatalf("%v: expected error", testCase.name)
+		}
+	}
+}
+
 // runRestrictOutgoingChannel asserts that a outgoing channel restriction is
 // obeyed by the path finding algorithm.
 func runRestrictOutgoingChannel(t *testing.T, useCache bool) {
@@ -2598,6 +2662,168 @@ func runCltvLimit(t *testing.T, useCache bool) {
 	})
 }
 
+// runMaxHopFee asserts that the path finding algorithm doesn't select nodes
+// that charge more than the max hop fee.
+func runMaxHopFee(t *testing.T, useCache bool) {
+	t.Run("no limit", func(t *testing.T) {
+		testMaxHopFee(t, useCache, 0, 1)
+	})
+	t.Run("force more hops", func(t *testing.T) {
+		testMaxHopFee(t, useCache, 8000, 2)
+	})
+	t.Run("no path", func(t *testing.T) {
+		testMaxHopFee(t, useCache, 5000, 0)
+	})
+}
+
+func testMaxHopFee(t *testing.T, useCache bool, maxHopFee lnwire.MilliSatoshi,
+	expectedChannel uint64) {
+
+	t.Parallel()
+
+	// Set up a test graph with two paths to the target. The path through a
+	// is the lowest cost, but a charges a high fee. The path through b and
+	// c is more expensive, but each of the nodes charges less than a.
+	testChannels := []*testChannel{
+		symmetricTestChannel("roasbeef", "a", 100000, &testChannelPolicy{}, 1),
+		symmetricTestChannel("a", "target", 100000, &testChannelPolicy{
+			Expiry:      144,
+			FeeBaseMsat: 10000,
+			MinHTLC:     1,
+		}),
+		symmetricTestChannel("roasbeef", "b", 100000, &testChannelPolicy{}, 2),
+		symmetricTestChannel("b", "c", 100000, &testChannelPolicy{
+			Expiry:      144,
+			FeeBaseMsat: 6000,
+			MinHTLC:     1,
+		}),
+		symmetricTestChannel("c", "target", 100000, &testChannelPolicy{
+			Expiry:      144,
+			FeeBaseMsat: 6000,
+			MinHTLC:     1,
+		}),
+	}
+
+	ctx := newPathFindingTestContext(t, useCache, testChannels, "roasbeef")
+	defer ctx.cleanup()
+
+	paymentAmt := lnwire.NewMSatFromSatoshis(100)
+	target := ctx.keyFromAlias("target")
+
+	ctx.restrictParams.MaxHopFee = maxHopFee
+	path, err := ctx.findPath(target, paymentAmt)
+	if expectedChannel == 0 {
+		if err != errNoPathFound {
+			t.Fatalf("expected no path to be found, got %v", err)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatalf("unable to find path: %v", err)
+	}
+	if path[0].ChannelID != expectedChannel {
+		t.Fatalf("expected route to pass through channel %v, but "+
+			"channel %v was selected instead", expectedChannel,
+			path[0].ChannelID)
+	}
+}
+
+func runRouteConstraints(t *testing.T, useCache bool) {
+	t.Run("no constraints", func(t *testing.T) {
+		testRouteConstraints(t, useCache, nil, nil, 0, 1)
+	})
+	t.Run("first hop", func(t *testing.T) {
+		testRouteConstraints(t, useCache, []string{"b"}, nil, 0, 2)
+	})
+	t.Run("excluded node", func(t *testing.T) {
+		testRouteConstraints(t, useCache, nil, []string{"a"}, 0, 2)
+	})
+	t.Run("excluded first hop", func(t *testing.T) {
+		testRouteConstraints(
+			t, useCache, []string{"a"}, []string{"a"}, 0, 0,
+		)
+	})
+	t.Run("max hops", func(t *testing.T) {
+		testRouteConstraints(t, useCache, nil, []string{"a"}, 3, 2)
+	})
+	t.Run("max hops exceeded", func(t *testing.T) {
+		testRouteConstraints(t, useCache, []string{"b"}, nil, 2, 0)
+	})
+}
+
+func testRouteConstraints(t *testing.T, useCache bool, firstHops,
+	excluded []string, maxHops uint32, expectedChannel uint64) {
+
+	t.Parallel()
+
+	// Set up a test graph with two paths to the target. The path through a
+	// is the lowest cost. The path through b and c has one more hop.
+	testChannels := []*testChannel{
+		symmetricTestChannel("roasbeef", "a", 100000, &testChannelPolicy{}, 1),
+		symmetricTestChannel("a", "target", 100000, &testChannelPolicy{
+			Expiry:      144,
+			FeeBaseMsat: 10000,
+			MinHTLC:     1,
+		}),
+		symmetricTestChannel("roasbeef", "b", 100000, &testChannelPolicy{}, 2),
+		symmetricTestChannel("b", "c", 100000, &testChannelPolicy{
+			Expiry:      144,
+			FeeBaseMsat: 6000,
+			MinHTLC:     1,
+		}),
+		symmetricTestChannel("c", "target", 100000, &testChannelPolicy{
+			Expiry:      144,
+			FeeBaseMsat: 6000,
+			MinHTLC:     1,
+		}),
+	}
+
+	ctx := newPathFindingTestContext(t, useCache, testChannels, "roasbeef")
+	defer ctx.cleanup()
+
+	paymentAmt := lnwire.NewMSatFromSatoshis(100)
+	target := ctx.keyFromAlias("target")
+
+	if firstHops != nil {
+		ctx.restrictParams.FirstHops = make(map[route.Vertex]struct{})
+		for _, alias := range firstHops {
+			node := ctx.keyFromAlias(alias)
+			ctx.restrictParams.FirstHops[node] = struct{}{}
+		}
+	}
+
+	excludedNodes := make(map[route.Vertex]struct{})
+	for _, alias := range excluded {
+		excludedNodes[ctx.keyFromAlias(alias)] = struct{}{}
+	}
+	ctx.restrictParams.NodeExcluded = func(node route.Vertex) bool {
+		_, ok := excludedNodes[node]
+		return ok
+	}
+
+	ctx.restrictParams.MaxHops = maxHops
+
+	path, err := ctx.findPath(target, paymentAmt)
+	if expectedChannel == 0 {
+		if err != errNoPathFound && err != errInsufficientBalance {
+			t.Fatalf("expected no path to be found, got %v", err)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatalf("unable to find path: %v", err)
+	}
+	if path[0].ChannelID != expectedChannel {
+		t.Fatalf("expected route to pass through channel %v, but "+
+			"channel %v was selected instead", expectedChannel,
+			path[0].ChannelID)
+	}
+	if maxHops != 0 && uint32(len(path)) > maxHops {
+		t.Fatalf("expected at most %v hops, got %v", maxHops,
+			len(path))
+	}
+}
+
 func testCltvLimit(t *testing.T, useCache bool, limit uint32,
 	expectedChannel uint64) {
 
@@ -2810,7 +3036,7 @@ func testProbabilityRouting(t *testing.T, useCache bool,
 
 	// Configure a probability source with the test parameters.
 	ctx.restrictParams.ProbabilitySource = func(fromNode, toNode route.Vertex,
-		amt lnwire.MilliSatoshi) float64 {
+		amt lnwire.MilliSatoshi, capacity bronutil.Amount) float64 {
 
 		if amt == 0 {
 			t.Fatal("expected non-zero amount")
@@ -2891,7 +3117,7 @@ func runEqualCostRouteSelection(t *testing.T, useCache bool) {
 	target := ctx.testGraphInstance.aliasMap["target"]
 
 	ctx.restrictParams.ProbabilitySource = func(fromNode, toNode route.Vertex,
-		amt lnwire.MilliSatoshi) float64 {
+		amt lnwire.MilliSatoshi, capacity bronutil.Amount) float64 {
 
 		switch {
 		case fromNode == alias["source"] && toNode == alias["a"]:
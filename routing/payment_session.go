@@ -168,6 +168,13 @@ type paymentSession struct {
 
 	payment *LightningPayment
 
+	// firstHops is the set of peers that are allowed as the first hop of
+	// routes. If nil, any peer may be used.
+	firstHops map[route.Vertex]struct{}
+
+	// exclusions determines the nodes that may not be used in routes.
+	exclusions *nodeExclusions
+
 	empty bool
 
 	pathFinder pathFinder
@@ -202,12 +209,29 @@ func newPaymentSession(p *LightningPayment,
 		return nil, err
 	}
 
+	// Blinded hops count towards the maximum number of hops of the route,
+	// but aren't part of the path that we search for.
+	if p.MaxHops != 0 && p.MaxHops <= blindedHopCount(p) {
+		return nil, fmt.Errorf("max hops of %v doesn't leave room "+
+			"for a route to the blinded path", p.MaxHops)
+	}
+
+	var firstHops map[route.Vertex]struct{}
+	if len(p.FirstHops) > 0 {
+		firstHops = make(map[route.Vertex]struct{})
+		for _, peer := range p.FirstHops {
+			firstHops[peer] = struct{}{}
+		}
+	}
+
 	logPrefix := fmt.Sprintf("PaymentSession(%x):", p.Identifier())
 
 	return &paymentSession{
 		additionalEdges:   edges,
 		getBandwidthHints: getBandwidthHints,
 		payment:           p,
+		firstHops:         firstHops,
+		exclusions:        newNodeExclusions(p),
 		pathFinder:        findPath,
 		getRoutingGraph:   getRoutingGraph,
 		pathFindingConfig: pathFindingConfig,
@@ -238,10 +262,19 @@ func (p *paymentSession) RequestRoute(maxAmt, feeLimit lnwire.MilliSatoshi,
 	finalCltvDelta := p.payment.FinalCLTVDelta
 	finalCltvDelta += BlockPadding
 
+	// When paying over a blinded path, we only search for a route to its
+	// introduction node. That route needs to cover the cltv delta of the
+	// hidden hops as well.
+	blindedPath := p.payment.BlindedPath
+	pathCltvDelta := finalCltvDelta
+	if blindedPath != nil {
+		pathCltvDelta += blindedPath.PayInfo.CltvExpiryDelta
+	}
+
 	// We need to subtract the final delta before passing it into path
 	// finding. The optimal path is independent of the final cltv delta and
 	// the path finding algorithm is unaware of this value.
-	cltvLimit := p.payment.CltvLimit - uint32(finalCltvDelta)
+	cltvLimit := p.payment.CltvLimit - uint32(pathCltvDelta)
 
 	// TODO(roasbeef): sync logic amongst dist sys
 
@@ -251,15 +284,34 @@ func (p *paymentSession) RequestRoute(maxAmt, feeLimit lnwire.MilliSatoshi,
 	restrictions := &RestrictParams{
 		ProbabilitySource:  p.missionControl.GetProbability,
 		FeeLimit:           feeLimit,
+		MaxHopFee:          p.payment.MaxHopFee,
 		OutgoingChannelIDs: p.payment.OutgoingChannelIDs,
 		LastHop:            p.payment.LastHop,
+		FirstHops:          p.firstHops,
 		CltvLimit:          cltvLimit,
 		DestCustomRecords:  p.payment.DestCustomRecords,
 		DestFeatures:       p.payment.DestFeatures,
 		PaymentAddr:        p.payment.PaymentAddr,
 	}
 
-	finalHtlcExpiry := int32(height) + int32(finalCltvDelta)
+	if !p.exclusions.empty() {
+		restrictions.NodeExcluded = p.exclusions.isExcluded
+	}
+
+	if p.payment.MaxHops != 0 {
+		restrictions.MaxHops = p.payment.MaxHops -
+			blindedHopCount(p.payment)
+	}
+
+	// The introduction node only forwards the payment, so none of the
+	// requirements of the recipient apply to it.
+	if blindedPath != nil {
+		restrictions.DestCustomRecords = nil
+		restrictions.DestFeatures = nil
+		restrictions.PaymentAddr = nil
+	}
+
+	finalHtlcExpiry := int32(height) + int32(pathCltvDelta)
 
 	// Before we enter the loop below, we'll make sure to respect the max
 	// payment shard size (if it's set), which is effectively our
@@ -273,6 +325,14 @@ func (p *paymentSession) RequestRoute(maxAmt, feeLimit lnwire.MilliSatoshi,
 		maxAmt = *p.payment.MaxShardAmt
 	}
 
+	// We also can't send more than the blinded path accepts.
+	if blindedPath != nil {
+		htlcMax := blindedPath.PayInfo.HtlcMaximumMsat
+		if htlcMax != 0 && maxAmt > htlcMax {
+			maxAmt = htlcMax
+		}
+	}
+
 	for {
 		// Get a routing graph.
 		routingGraph, cleanup, err := p.getRoutingGraph()
@@ -295,6 +355,13 @@ func (p *paymentSession) RequestRoute(maxAmt, feeLimit lnwire.MilliSatoshi,
 
 		sourceVertex := routingGraph.sourceNode()
 
+		// The introduction node of a blinded path needs to receive
+		// the fees of the hidden hops on top of the amount.
+		pathAmt := maxAmt
+		if blindedPath != nil {
+			pathAmt += blindedPath.PayInfo.Fee(maxAmt)
+		}
+
 		// Find a route for the current amount.
 		path, err := p.pathFinder(
 			&graphParams{
@@ -304,7 +371,7 @@ func (p *paymentSession) RequestRoute(maxAmt, feeLimit lnwire.MilliSatoshi,
 			},
 			restrictions, &p.pathFindingConfig,
 			sourceVertex, p.payment.Target,
-			maxAmt, finalHtlcExpiry,
+			pathAmt, finalHtlcExpiry,
 		)
 
 		// Close routing graph.
@@ -380,6 +447,13 @@ func (p *paymentSession) RequestRoute(maxAmt, feeLimit lnwire.MilliSatoshi,
 		// With the next candidate path found, we'll attempt to turn
 		// this into a route by applying the time-lock and fee
 		// requirements.
+		if blindedPath != nil {
+			return p.newBlindedRoute(
+				sourceVertex, path, height, maxAmt, pathAmt,
+				feeLimit, finalCltvDelta, pathCltvDelta,
+			)
+		}
+
 		route, err := newRoute(
 			sourceVertex, path, height,
 			finalHopParams{
@@ -394,10 +468,65 @@ func (p *paymentSession) RequestRoute(maxAmt, feeLimit lnwire.MilliSatoshi,
 			return nil, err
 		}
 
+		// Path finding already respects the fee limits, but we make
+		// sure that the constructed route does too before sending out
+		// an htlc along it.
+		err = validateRouteFees(route, feeLimit, p.payment.MaxHopFee)
+		if err != nil {
+			p.log.Warnf("Discarding route: %v", err)
+
+			return nil, errNoPathFound
+		}
+
 		return route, err
 	}
 }
 
+// blindedHopCount returns the number of hops that the blinded path of the
+// payment adds to a route to its introduction node.
+func blindedHopCount(p *LightningPayment) uint32 {
+	if p.BlindedPath == nil || len(p.BlindedPath.Path.BlindedHops) == 0 {
+		return 0
+	}
+
+	return uint32(len(p.BlindedPath.Path.BlindedHops) - 1)
+}
+
+// newBlindedRoute turns a path to the introduction node of the blinded path of
+// the payment into a route to the recipient. The path must be able to carry
+// pathAmt, which includes the fees of the blinded path, while the recipient
+// receives amt. Like in path finding, the fee limits only apply to the route to
+// the introduction node.
+func (p *paymentSession) newBlindedRoute(sourceVertex route.Vertex,
+	path []*channeldb.CachedEdgePolicy, height uint32, amt, pathAmt,
+	feeLimit lnwire.MilliSatoshi, finalCltvDelta,
+	pathCltvDelta uint16) (*route.Route, error) {
+
+	introRoute, err := newRoute(
+		sourceVertex, path, height,
+		finalHopParams{
+			amt:       pathAmt,
+			totalAmt:  pathAmt,
+			cltvDelta: pathCltvDelta,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	err = validateRouteFees(introRoute, feeLimit, p.payment.MaxHopFee)
+	if err != nil {
+		p.log.Warnf("Discarding route: %v", err)
+
+		return nil, errNoPathFound
+	}
+
+	return appendBlindedHops(
+		introRoute, p.payment.BlindedPath, amt, p.payment.Amount,
+		height+uint32(finalCltvDelta), p.payment.DestCustomRecords,
+	)
+}
+
 // UpdateAdditionalEdge updates the channel edge policy for a private edge. It
 // validates the message signature and checks it's up to date, then applies the
 // updates to the supplied policy. It returns a boolean to indicate whether
@@ -1,6 +1,9 @@
 package routing
 
 import (
+	"errors"
+	"fmt"
+
 	"github.com/brsuite/brond/btcec"
 	"github.com/brsuite/broln/channeldb"
 	"github.com/brsuite/broln/lnwire"
@@ -8,6 +11,14 @@ import (
 	"github.com/brsuite/broln/zpay32"
 )
 
+var (
+	// ErrNoNodeLocator is returned when a payment excludes countries or
+	// autonomous systems from its routes, while no NodeLocator is
+	// configured to locate nodes.
+	ErrNoNodeLocator = errors.New("excluding countries or autonomous " +
+		"systems requires a node location table")
+)
+
 // A compile time assertion to ensure MissionControl meets the
 // PaymentSessionSource interface.
 var _ PaymentSessionSource = (*SessionSource)(nil)
@@ -41,6 +52,11 @@ type SessionSource struct {
 	// PathFindingConfig defines global parameters that control the
 	// trade-off in path finding between fees and probabiity.
 	PathFindingConfig PathFindingConfig
+
+	// NodeLocator is used to locate nodes by their advertised addresses
+	// for payments that exclude countries or autonomous systems from
+	// their routes. If nil, such payments are rejected.
+	NodeLocator NodeLocator
 }
 
 // getRoutingGraph returns a routing graph and a clean-up function for
@@ -79,9 +95,49 @@ func (m *SessionSource) NewPaymentSession(p *LightningPayment) (
 		return nil, err
 	}
 
+	if session.exclusions.byLocation() {
+		if m.NodeLocator == nil {
+			return nil, ErrNoNodeLocator
+		}
+		session.exclusions.locateNode = m.locateNode
+	}
+
+	// The exclusions apply to all nodes of the route, so there is no point
+	// in searching for routes to an excluded destination.
+	if session.exclusions.isExcluded(p.Target) {
+		return nil, fmt.Errorf("payment destination %v is excluded "+
+			"from routes", p.Target)
+	}
+
 	return session, nil
 }
 
+// locateNode returns the locations of the addresses that the given node
+// advertises in the graph.
+func (m *SessionSource) locateNode(node route.Vertex) ([]*NodeLocation,
+	error) {
+
+	lightningNode, err := m.Graph.FetchLightningNode(node)
+	switch {
+	// Private nodes don't advertise any addresses.
+	case err == channeldb.ErrGraphNodeNotFound:
+		return nil, nil
+
+	case err != nil:
+		return nil, err
+	}
+
+	var locations []*NodeLocation
+	for _, addr := range lightningNode.Addresses {
+		location := m.NodeLocator.LocateAddr(addr)
+		if location != nil {
+			locations = append(locations, location)
+		}
+	}
+
+	return locations, nil
+}
+
 // NewPaymentSessionEmpty creates a new paymentSession instance that is empty,
 // and will be exhausted immediately. Used for failure reporting to
 // missioncontrol for resumed payment we don't want to make more attempts for.
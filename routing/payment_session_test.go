@@ -1,12 +1,15 @@
 package routing
 
 import (
+	"errors"
 	"testing"
 	"time"
 
+	"github.com/brsuite/brond/btcec"
 	"github.com/brsuite/broln/channeldb"
 	"github.com/brsuite/broln/lntypes"
 	"github.com/brsuite/broln/lnwire"
+	"github.com/brsuite/broln/routing/blindedpath"
 	"github.com/brsuite/broln/routing/route"
 	"github.com/brsuite/broln/zpay32"
 	"github.com/stretchr/testify/require"
@@ -250,6 +253,285 @@ func TestRequestRoute(t *testing.T) {
 	}
 }
 
+// TestRequestBlindedRoute asserts that payment sessions search for a route to
+// the introduction node of a blinded path, and extend it with the hidden hops
+// of the path.
+func TestRequestBlindedRoute(t *testing.T) {
+	const (
+		height         = 10
+		finalCltvDelta = 8
+		pathCltvDelta  = 20
+	)
+
+	blindedNode, err := btcec.NewPrivateKey(btcec.S256())
+	require.NoError(t, err)
+
+	blindedPath := &blindedpath.PaymentPath{
+		PayInfo: &blindedpath.PayInfo{
+			FeeBaseMsat:               100,
+			FeeProportionalMillionths: 1000,
+			CltvExpiryDelta:           pathCltvDelta,
+		},
+		Path: &blindedpath.BlindedPath{
+			IntroductionPoint: blindedNode.PubKey(),
+			BlindingPoint:     blindedNode.PubKey(),
+			BlindedHops: []*blindedpath.BlindedHop{
+				{
+					BlindedNodePub: blindedNode.PubKey(),
+					CipherText:     []byte{1},
+				},
+				{
+					BlindedNodePub: blindedNode.PubKey(),
+					CipherText:     []byte{2},
+				},
+			},
+		},
+	}
+
+	payment := &LightningPayment{
+		Target:         route.NewVertex(blindedNode.PubKey()),
+		CltvLimit:      100,
+		FinalCLTVDelta: finalCltvDelta,
+		Amount:         1000,
+		FeeLimit:       1000,
+		BlindedPath:    blindedPath,
+	}
+	require.NoError(t, payment.SetPaymentHash([32]byte{}))
+
+	session, err := newPaymentSession(
+		payment,
+		func(routingGraph) (bandwidthHints, error) {
+			return &mockBandwidthHints{}, nil
+		},
+		func() (routingGraph, func(), error) {
+			return &sessionGraph{}, func() {}, nil
+		},
+		&MissionControl{},
+		PathFindingConfig{},
+	)
+	require.NoError(t, err)
+
+	// The route to the introduction node must carry the fees of the
+	// blinded path.
+	session.pathFinder = func(
+		g *graphParams, r *RestrictParams, cfg *PathFindingConfig,
+		source, target route.Vertex, amt lnwire.MilliSatoshi,
+		finalHtlcExpiry int32) ([]*channeldb.CachedEdgePolicy, error) {
+
+		require.Equal(t, payment.Target, target)
+		require.Equal(t, lnwire.MilliSatoshi(1101), amt)
+
+		path := []*channeldb.CachedEdgePolicy{
+			{
+				ToNodePubKey: func() route.Vertex {
+					return target
+				},
+				ToNodeFeatures: lnwire.NewFeatureVector(
+					lnwire.NewRawFeatureVector(
+						lnwire.TLVOnionPayloadOptional,
+					), lnwire.Features,
+				),
+			},
+		}
+
+		return path, nil
+	}
+
+	rt, err := session.RequestRoute(
+		payment.Amount, payment.FeeLimit, 0, height,
+	)
+	require.NoError(t, err)
+	require.Len(t, rt.Hops, 2)
+	require.Equal(t, lnwire.MilliSatoshi(1101), rt.TotalAmount)
+	require.Equal(t,
+		uint32(height+finalCltvDelta+BlockPadding+pathCltvDelta),
+		rt.TotalTimeLock,
+	)
+
+	intro := rt.Hops[0]
+	require.Equal(t, []byte{1}, intro.EncryptedData)
+	require.Equal(t, blindedPath.Path.BlindingPoint, intro.BlindingPoint)
+	require.Zero(t, intro.TotalAmtMsat)
+
+	final := rt.Hops[1]
+	require.Equal(t, []byte{2}, final.EncryptedData)
+	require.Nil(t, final.BlindingPoint)
+	require.Equal(t, payment.Amount, final.AmtToForward)
+	require.Equal(t, payment.Amount, final.TotalAmtMsat)
+	require.Equal(t,
+		uint32(height+finalCltvDelta+BlockPadding),
+		final.OutgoingTimeLock,
+	)
+}
+
+// TestRequestRouteConstraints asserts that the route constraints of a payment
+// are passed on to path finding.
+func TestRequestRouteConstraints(t *testing.T) {
+	var (
+		firstHop   = route.Vertex{1}
+		excluded   = route.Vertex{2}
+		inCountry  = route.Vertex{3}
+		inASN      = route.Vertex{4}
+		unlocated  = route.Vertex{5}
+		unknownErr = route.Vertex{6}
+	)
+
+	payment := &LightningPayment{
+		Target:            route.Vertex{9},
+		CltvLimit:         100,
+		Amount:            1000,
+		FeeLimit:          1000,
+		FirstHops:         []route.Vertex{firstHop},
+		ExcludedNodes:     []route.Vertex{excluded},
+		ExcludedCountries: []string{"xx"},
+		ExcludedASNs:      []uint32{64500},
+		MaxHops:           3,
+	}
+	require.NoError(t, payment.SetPaymentHash([32]byte{}))
+
+	session, err := newPaymentSession(
+		payment,
+		func(routingGraph) (bandwidthHints, error) {
+			return &mockBandwidthHints{}, nil
+		},
+		func() (routingGraph, func(), error) {
+			return &sessionGraph{}, func() {}, nil
+		},
+		&MissionControl{},
+		PathFindingConfig{},
+	)
+	require.NoError(t, err)
+
+	locateCalls := make(map[route.Vertex]int)
+	session.exclusions.locateNode = func(node route.Vertex) (
+		[]*NodeLocation, error) {
+
+		locateCalls[node]++
+
+		switch node {
+		case inCountry:
+			return []*NodeLocation{
+				{Country: "YY", ASN: 64501},
+				{Country: "XX", ASN: 64501},
+			}, nil
+
+		case inASN:
+			return []*NodeLocation{{ASN: 64500}}, nil
+
+		case unknownErr:
+			return nil, errors.New("unknown")
+
+		default:
+			return nil, nil
+		}
+	}
+
+	var restrictions *RestrictParams
+	session.pathFinder = func(
+		g *graphParams, r *RestrictParams, cfg *PathFindingConfig,
+		source, target route.Vertex, amt lnwire.MilliSatoshi,
+		finalHtlcExpiry int32) ([]*channeldb.CachedEdgePolicy, error) {
+
+		restrictions = r
+
+		return nil, errNoPathFound
+	}
+
+	_, err = session.RequestRoute(
+		payment.Amount, payment.FeeLimit, 0, 10,
+	)
+	require.Equal(t, errNoPathFound, err)
+
+	require.Equal(t, map[route.Vertex]struct{}{
+		firstHop: {},
+	}, restrictions.FirstHops)
+	require.EqualValues(t, 3, restrictions.MaxHops)
+
+	require.NotNil(t, restrictions.NodeExcluded)
+	require.True(t, restrictions.NodeExcluded(excluded))
+	require.True(t, restrictions.NodeExcluded(inCountry))
+	require.True(t, restrictions.NodeExcluded(inASN))
+	require.True(t, restrictions.NodeExcluded(unknownErr))
+	require.False(t, restrictions.NodeExcluded(unlocated))
+	require.False(t, restrictions.NodeExcluded(firstHop))
+
+	// Nodes are only located once per payment.
+	require.False(t, restrictions.NodeExcluded(unlocated))
+	require.Equal(t, 1, locateCalls[unlocated])
+	require.Zero(t, locateCalls[excluded])
+}
+
+// TestNodeExclusionsUnlocated asserts that nodes without any address of a
+// known location are only excluded if requested.
+func TestNodeExclusionsUnlocated(t *testing.T) {
+	var (
+		located   = route.Vertex{1}
+		unlocated = route.Vertex{2}
+	)
+
+	locateNode := func(node route.Vertex) ([]*NodeLocation, error) {
+		if node == located {
+			return []*NodeLocation{{Country: "YY", ASN: 64501}}, nil
+		}
+
+		return nil, nil
+	}
+
+	for _, excludeUnlocated := range []bool{false, true} {
+		exclusions := newNodeExclusions(&LightningPayment{
+			ExcludedCountries:     []string{"XX"},
+			ExcludeUnlocatedNodes: excludeUnlocated,
+		})
+		exclusions.locateNode = locateNode
+
+		require.False(t, exclusions.isExcluded(located))
+		require.Equal(
+			t, excludeUnlocated, exclusions.isExcluded(unlocated),
+		)
+	}
+}
+
+// TestNewPaymentSessionMaxHops asserts that the maximum number of hops of a
+// payment must leave room for a route to the blinded path.
+func TestNewPaymentSessionMaxHops(t *testing.T) {
+	blindedNode, err := btcec.NewPrivateKey(btcec.S256())
+	require.NoError(t, err)
+
+	payment := &LightningPayment{
+		Target:  route.NewVertex(blindedNode.PubKey()),
+		MaxHops: 2,
+		BlindedPath: &blindedpath.PaymentPath{
+			PayInfo: &blindedpath.PayInfo{},
+			Path: &blindedpath.BlindedPath{
+				BlindedHops: []*blindedpath.BlindedHop{
+					{}, {}, {},
+				},
+			},
+		},
+	}
+	require.NoError(t, payment.SetPaymentHash([32]byte{}))
+
+	newSession := func() error {
+		_, err := newPaymentSession(
+			payment,
+			func(routingGraph) (bandwidthHints, error) {
+				return &mockBandwidthHints{}, nil
+			},
+			func() (routingGraph, func(), error) {
+				return &sessionGraph{}, func() {}, nil
+			},
+			&MissionControl{},
+			PathFindingConfig{},
+		)
+		return err
+	}
+
+	require.Error(t, newSession())
+
+	payment.MaxHops = 3
+	require.NoError(t, newSession())
+}
+
 type sessionGraph struct {
 	routingGraph
 }
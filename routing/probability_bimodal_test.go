@@ -0,0 +1,666 @@
+package routing
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/brsuite/bronutil"
+	"github.com/brsuite/broln/lnwire"
+	"github.com/brsuite/broln/routing/route"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	smallAmount = lnwire.MilliSatoshi(400_000)
+	largeAmount = lnwire.MilliSatoshi(5_000_000)
+	capacity    = lnwire.MilliSatoshi(10_000_000)
+	scale       = lnwire.MilliSatoshi(400_000)
+)
+
+// TestSuccessProbability tests that we get correct probability estimates for
+// the direct channel probability.
+func TestSuccessProbability(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name                string
+		expectedProbability float64
+		tolerance           float64
+		successAmount       lnwire.MilliSatoshi
+		failAmount          lnwire.MilliSatoshi
+		amount              lnwire.MilliSatoshi
+		capacity            lnwire.MilliSatoshi
+	}{
+		// We can't send more than the capacity.
+		{
+			name:                "no info, larger than capacity",
+			capacity:            capacity,
+			successAmount:       0,
+			failAmount:          capacity,
+			amount:              capacity + 1,
+			expectedProbability: 0.0,
+		},
+		// With the current model we don't prefer any channels if the
+		// send amount is large compared to the scale but small compared
+		// to the capacity.
+		{
+			name:                "no info, large amount",
+			capacity:            capacity,
+			successAmount:       0,
+			failAmount:          capacity,
+			amount:              largeAmount,
+			expectedProbability: 0.5,
+		},
+		// We always expect to be able to "send" an amount of 0.
+		{
+			name:                "no info, zero amount",
+			capacity:            capacity,
+			successAmount:       0,
+			failAmount:          capacity,
+			amount:              0,
+			expectedProbability: 1.0,
+		},
+		// We can't send the whole capacity.
+		{
+			name:                "no info, full capacity",
+			capacity:            capacity,
+			successAmount:       0,
+			failAmount:          capacity,
+			amount:              capacity,
+			expectedProbability: 0.0,
+		},
+		// Sending a small amount will have a higher probability to go
+		// through than a large amount.
+		{
+			name:                "no info, small amount",
+			capacity:            capacity,
+			successAmount:       0,
+			failAmount:          capacity,
+			amount:              smallAmount,
+			expectedProbability: 0.684,
+			tolerance:           0.001,
+		},
+		// If we had an unsettled success, we are sure we can send a
+		// lower amount.
+		{
+			name:                "previous success, lower amount",
+			capacity:            capacity,
+			successAmount:       largeAmount,
+			failAmount:          capacity,
+			amount:              smallAmount,
+			expectedProbability: 1.0,
+		},
+		// If we had an unsettled success, we are sure we can send the
+		// same amount.
+		{
+			name:                "previous success, success amount",
+			capacity:            capacity,
+			successAmount:       largeAmount,
+			failAmount:          capacity,
+			amount:              largeAmount,
+			expectedProbability: 1.0,
+		},
+		// If we had an unsettled success with a small amount, we know
+		// with increased probability that we can send a comparable
+		// higher amount.
+		{
+			name:                "previous success, larger amount",
+			capacity:            capacity,
+			successAmount:       smallAmount / 2,
+			failAmount:          capacity,
+			amount:              smallAmount,
+			expectedProbability: 0.851,
+			tolerance:           0.001,
+		},
+		// If we had a large unsettled success before, we know we can
+		// send even larger payments with high probability.
+		{
+			name:                "previous large success, larger amount",
+			capacity:            capacity,
+			successAmount:       largeAmount / 2,
+			failAmount:          capacity,
+			amount:              largeAmount,
+			expectedProbability: 0.998,
+			tolerance:           0.001,
+		},
+		// If we had a failure before, we can't send with the fail
+		// amount.
+		{
+			name:                "previous failure, fail amount",
+			capacity:            capacity,
+			failAmount:          largeAmount,
+			amount:              largeAmount,
+			expectedProbability: 0.0,
+		},
+		// We can't send a higher amount than the fail amount either.
+		{
+			name:                "previous failure, larger fail amount",
+			capacity:            capacity,
+			failAmount:          largeAmount,
+			amount:              largeAmount + smallAmount,
+			expectedProbability: 0.0,
+		},
+		// We expect a diminished non-zero probability if we try to send
+		// an amount that's lower than the last fail amount.
+		{
+			name:                "previous failure, lower than fail amount",
+			capacity:            capacity,
+			failAmount:          largeAmount,
+			amount:              smallAmount,
+			expectedProbability: 0.368,
+			tolerance:           0.001,
+		},
+		// From here on we deal with mixed previous successes and
+		// failures. We expect to be always able to send a tiny amount.
+		{
+			name:                "previous f/s, very small amount",
+			capacity:            capacity,
+			failAmount:          largeAmount,
+			successAmount:       smallAmount,
+			amount:              0,
+			expectedProbability: 1.0,
+		},
+		// We expect to be able to send up to the previous success
+		// amount with certainty.
+		{
+			name:                "previous f/s, success amount",
+			capacity:            capacity,
+			failAmount:          largeAmount,
+			successAmount:       smallAmount,
+			amount:              smallAmount,
+			expectedProbability: 1.0,
+		},
+		// We expect a reduced probability when sending more than the
+		// success amount.
+		{
+			name:                "previous f/s, above success amount",
+			capacity:            capacity,
+			failAmount:          largeAmount,
+			successAmount:       smallAmount,
+			amount:              2 * smallAmount,
+			expectedProbability: 0.368,
+			tolerance:           0.001,
+		},
+		// We expect a vanishing probability when sending an amount in
+		// the middle of the channel, as the liquidity is expected to
+		// be at the edges.
+		{
+			name:                "previous f/s, close to fail amount",
+			capacity:            capacity,
+			failAmount:          largeAmount,
+			successAmount:       smallAmount,
+			amount:              largeAmount - smallAmount,
+			expectedProbability: 0.0,
+			tolerance:           0.001,
+		},
+		// We expect a zero probability if the fail amount isn't larger
+		// than the success amount, which is an inconsistent state.
+		{
+			name:                "previous f/s, inconsistent amounts",
+			capacity:            capacity,
+			failAmount:          smallAmount,
+			successAmount:       largeAmount,
+			amount:              smallAmount / 2,
+			expectedProbability: 0.0,
+		},
+	}
+
+	estimator := BimodalEstimator{
+		BimodalConfig: BimodalConfig{BimodalScaleMsat: scale},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			p, err := estimator.probabilityFormula(
+				test.capacity, test.successAmount,
+				test.failAmount, test.amount,
+			)
+			require.NoError(t, err)
+			require.InDelta(
+				t, test.expectedProbability, p, test.tolerance,
+			)
+		})
+	}
+}
+
+// TestLargeChannels tests that the probability stays sane for channels that
+// are very large compared to the scale, for which the liquidity distribution
+// underflows in the middle of the channel.
+func TestLargeChannels(t *testing.T) {
+	t.Parallel()
+
+	estimator := BimodalEstimator{
+		BimodalConfig: BimodalConfig{BimodalScaleMsat: scale},
+	}
+
+	// A channel of 10 BTC is many orders of magnitude larger than the
+	// scale.
+	largeCapacity := lnwire.MilliSatoshi(10 * 100_000_000_000)
+
+	p, err := estimator.probabilityFormula(
+		largeCapacity, largeCapacity/4, largeCapacity/2,
+		3*largeCapacity/8,
+	)
+	require.NoError(t, err)
+	require.InDelta(t, 0.5, p, 0.001)
+	require.False(t, math.IsNaN(p))
+}
+
+// TestIntegral tests certain limits of the probability distribution integral.
+func TestIntegral(t *testing.T) {
+	t.Parallel()
+
+	defaultScale := lnwire.NewMSatFromSatoshis(300_000)
+
+	tests := []struct {
+		name     string
+		capacity float64
+		lower    float64
+		upper    float64
+		scale    lnwire.MilliSatoshi
+		expected float64
+	}{
+		{
+			name:     "all zero",
+			expected: math.NaN(),
+			scale:    defaultScale,
+		},
+		{
+			name:     "all zero but scale",
+			scale:    defaultScale,
+			expected: math.NaN(),
+		},
+		{
+			name:     "equal limits",
+			capacity: 3_000_000,
+			lower:    0.5,
+			upper:    0.5,
+			scale:    defaultScale,
+			expected: 0,
+		},
+		{
+			name:     "full interval",
+			capacity: 3_000_000,
+			lower:    0,
+			upper:    3_000_000,
+			scale:    defaultScale,
+			expected: 1,
+		},
+		{
+			name:     "first half",
+			capacity: 3_000_000,
+			lower:    0,
+			upper:    1_500_000,
+			scale:    defaultScale,
+			expected: 0.5,
+		},
+		{
+			name:     "second half",
+			capacity: 3_000_000,
+			lower:    1_500_000,
+			upper:    3_000_000,
+			scale:    defaultScale,
+			expected: 0.5,
+		},
+		{
+			name:     "inverted limits",
+			capacity: 3_000_000,
+			lower:    3_000_000,
+			upper:    0,
+			scale:    defaultScale,
+			expected: 0,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			estimator := BimodalEstimator{
+				BimodalConfig: BimodalConfig{
+					BimodalScaleMsat: test.scale,
+				},
+			}
+
+			p := estimator.integral(
+				test.capacity, test.lower, test.upper,
+			)
+
+			if math.IsNaN(test.expected) {
+				require.True(t, math.IsNaN(p))
+				return
+			}
+
+			require.InDelta(t, test.expected, p, 0.001)
+		})
+	}
+}
+
+// TestComputeProbability tests the inclusion of previous forwarding results of
+// other channels of the node into the total probability.
+func TestComputeProbability(t *testing.T) {
+	t.Parallel()
+
+	nodeWeight := 1 / 5.
+	toNode := route.Vertex{10}
+	tolerance := 0.01
+	decayTime := time.Duration(1) * time.Hour * 24
+
+	// makeNodeResults prepares forwarding data for the other channels of
+	// the node.
+	makeNodeResults := func(successes []bool, now time.Time) NodeResults {
+		results := make(NodeResults, len(successes))
+
+		for i, s := range successes {
+			vertex := route.Vertex{byte(i)}
+
+			results[vertex] = TimedPairResult{
+				FailTime: now, FailAmt: 1,
+			}
+			if s {
+				results[vertex] = TimedPairResult{
+					SuccessTime: now, SuccessAmt: 1,
+				}
+			}
+		}
+
+		return results
+	}
+
+	tests := []struct {
+		name                string
+		directProbability   float64
+		otherResults        []bool
+		expectedProbability float64
+		delay               time.Duration
+	}{
+		// If no other information is available, use the direct
+		// probability.
+		{
+			name:                "unknown, only direct",
+			directProbability:   0.5,
+			expectedProbability: 0.5,
+		},
+		// If there was a single success, expect increased success
+		// probability.
+		{
+			name:                "unknown, single success",
+			directProbability:   0.5,
+			otherResults:        []bool{true},
+			expectedProbability: 0.583,
+		},
+		// If there were many successes, expect even higher success
+		// probability.
+		{
+			name:              "unknown, many successes",
+			directProbability: 0.5,
+			otherResults: []bool{
+				true, true, true, true, true,
+			},
+			expectedProbability: 0.75,
+		},
+		// If there was a single failure, we expect a slightly decreased
+		// probability.
+		{
+			name:                "unknown, single failure",
+			directProbability:   0.5,
+			otherResults:        []bool{false},
+			expectedProbability: 0.416,
+		},
+		// If there were many failures, we expect a strongly decreased
+		// probability.
+		{
+			name:              "unknown, many failures",
+			directProbability: 0.5,
+			otherResults: []bool{
+				false, false, false, false, false,
+			},
+			expectedProbability: 0.25,
+		},
+		// A success and a failure neutralize themselves.
+		{
+			name:                "unknown, mixed even",
+			directProbability:   0.5,
+			otherResults:        []bool{true, false},
+			expectedProbability: 0.5,
+		},
+		// A mixed result history leads to increase/decrease of the most
+		// experienced successes/failures.
+		{
+			name:              "unknown, mixed uneven",
+			directProbability: 0.5,
+			otherResults: []bool{
+				true, true, false, false, false,
+			},
+			expectedProbability: 0.45,
+		},
+		// Many successes don't elevate the probability above 1.
+		{
+			name:              "success, successes",
+			directProbability: 1.0,
+			otherResults: []bool{
+				true, true, true, true, true,
+			},
+			expectedProbability: 1.0,
+		},
+		// Five failures on a very certain channel will lower its
+		// success probability to the unknown probability.
+		{
+			name:              "success, failures",
+			directProbability: 1.0,
+			otherResults: []bool{
+				false, false, false, false, false,
+			},
+			expectedProbability: 0.5,
+		},
+		// If we are sure that the channel can send, a single failure
+		// will not decrease the outcome significantly.
+		{
+			name:                "success, single failure",
+			directProbability:   1.0,
+			otherResults:        []bool{false},
+			expectedProbability: 0.8333,
+		},
+		// If we know that the channel can't send, no amount of
+		// successes will help.
+		{
+			name:              "fail, successes",
+			directProbability: 0.0,
+			otherResults: []bool{
+				true, true, true, true, true,
+			},
+			expectedProbability: 0.5,
+		},
+		// Results that are a decay time old only have a minor
+		// influence.
+		{
+			name:              "unknown, decayed successes",
+			directProbability: 0.5,
+			otherResults: []bool{
+				true, true, true, true, true,
+			},
+			delay:               decayTime,
+			expectedProbability: 0.634,
+		},
+		// Very old results don't have any influence anymore.
+		{
+			name:              "unknown, ancient successes",
+			directProbability: 0.5,
+			otherResults: []bool{
+				true, true, true, true, true,
+			},
+			delay:               100 * decayTime,
+			expectedProbability: 0.5,
+		},
+	}
+
+	estimator := BimodalEstimator{
+		BimodalConfig: BimodalConfig{
+			BimodalScaleMsat:  scale,
+			BimodalNodeWeight: nodeWeight,
+			BimodalDecayTime:  decayTime,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			then := time.Unix(0, 0)
+			results := makeNodeResults(test.otherResults, then)
+			now := then.Add(test.delay)
+
+			p := estimator.calculateProbability(
+				test.directProbability, now, results, toNode,
+			)
+
+			require.InDelta(
+				t, test.expectedProbability, p, tolerance,
+			)
+		})
+	}
+}
+
+// TestDirectProbabilityDecay tests that previous results lose their influence
+// on the direct probability over time.
+func TestDirectProbabilityDecay(t *testing.T) {
+	t.Parallel()
+
+	decayTime := 24 * time.Hour
+	now := time.Unix(1000, 0)
+	toNode := route.Vertex{10}
+
+	estimator := BimodalEstimator{
+		BimodalConfig: BimodalConfig{
+			BimodalScaleMsat:  scale,
+			BimodalNodeWeight: 0.2,
+			BimodalDecayTime:  decayTime,
+		},
+	}
+
+	// Without any results, we expect the prior probability.
+	prior := estimator.directProbability(
+		now, NodeResults{}, toNode, largeAmount, capacity,
+	)
+	require.InDelta(t, 0.5, prior, 0.001)
+
+	// A fresh failure with the amount prevents sending it again.
+	results := NodeResults{
+		toNode: TimedPairResult{
+			FailTime: now,
+			FailAmt:  largeAmount,
+		},
+	}
+	p := estimator.directProbability(
+		now, results, toNode, largeAmount, capacity,
+	)
+	require.Zero(t, p)
+
+	// After some time, the failure amount relaxes towards the capacity
+	// and the probability recovers, without exceeding the prior.
+	p = estimator.directProbability(
+		now.Add(decayTime), results, toNode, largeAmount, capacity,
+	)
+	require.Greater(t, p, 0.0)
+	require.Less(t, p, prior)
+
+	// A fresh success with the amount makes sending it again certain.
+	results = NodeResults{
+		toNode: TimedPairResult{
+			SuccessTime: now,
+			SuccessAmt:  largeAmount,
+		},
+	}
+	p = estimator.directProbability(
+		now, results, toNode, largeAmount, capacity,
+	)
+	require.Equal(t, 1.0, p)
+
+	// Long after the success, we are back at the prior.
+	p = estimator.directProbability(
+		now.Add(100*decayTime), results, toNode, largeAmount, capacity,
+	)
+	require.InDelta(t, prior, p, 0.001)
+}
+
+// TestLocalPairProbability tests that failures on local channels reduce the
+// probability only temporarily.
+func TestLocalPairProbability(t *testing.T) {
+	t.Parallel()
+
+	decayTime := time.Hour
+	now := time.Unix(1000, 0)
+	toNode := route.Vertex{1}
+
+	estimator := BimodalEstimator{
+		BimodalConfig: BimodalConfig{
+			BimodalScaleMsat:  scale,
+			BimodalNodeWeight: 0.2,
+			BimodalDecayTime:  decayTime,
+		},
+	}
+
+	// Untried local channels are assumed to work.
+	p := estimator.LocalPairProbability(now, NodeResults{}, toNode)
+	require.Equal(t, 1.0, p)
+
+	results := NodeResults{
+		toNode: TimedPairResult{
+			FailTime: now,
+		},
+	}
+
+	// A fresh failure results in a zero probability, which recovers
+	// after some time.
+	p = estimator.LocalPairProbability(now, results, toNode)
+	require.Zero(t, p)
+
+	p = estimator.LocalPairProbability(
+		now.Add(decayTime), results, toNode,
+	)
+	require.InDelta(t, 1-1/math.E, p, 0.001)
+}
+
+// TestUnknownCapacity tests that a connection of unknown capacity, like the
+// channels of route hints, isn't treated as unusable.
+func TestUnknownCapacity(t *testing.T) {
+	t.Parallel()
+
+	estimator, err := NewBimodalEstimator(DefaultBimodalConfig())
+	require.NoError(t, err)
+
+	p := estimator.PairProbability(
+		time.Unix(1000, 0), NodeResults{}, route.Vertex{1},
+		largeAmount, bronutil.Amount(0),
+	)
+	require.Greater(t, p, 0.5)
+}
+
+// TestNewBimodalEstimator tests that invalid configurations are rejected.
+func TestNewBimodalEstimator(t *testing.T) {
+	t.Parallel()
+
+	cfg := DefaultBimodalConfig()
+	cfg.BimodalNodeWeight = 1.5
+	_, err := NewBimodalEstimator(cfg)
+	require.ErrorIs(t, err, ErrInvalidNodeWeight)
+
+	cfg = DefaultBimodalConfig()
+	cfg.BimodalScaleMsat = 0
+	_, err = NewBimodalEstimator(cfg)
+	require.ErrorIs(t, err, ErrInvalidScale)
+
+	cfg = DefaultBimodalConfig()
+	cfg.BimodalDecayTime = 0
+	_, err = NewBimodalEstimator(cfg)
+	require.ErrorIs(t, err, ErrInvalidDecayTime)
+}
@@ -0,0 +1,538 @@
+package routing
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/brsuite/broln/channeldb"
+	"github.com/brsuite/broln/clock"
+	"github.com/brsuite/broln/lntypes"
+	"github.com/brsuite/broln/lnwire"
+	"github.com/brsuite/broln/routing/route"
+)
+
+const (
+	// DefaultMaxProbeConcurrency is the default maximum number of probes
+	// that are in flight at the same time.
+	DefaultMaxProbeConcurrency = 3
+
+	// DefaultProbeTimeout is the default time after which a single probe
+	// is abandoned if the payment lifecycle hasn't reached a final
+	// outcome yet.
+	DefaultProbeTimeout = time.Minute
+
+	// MinProbeInterval is the minimum interval at which a probe schedule
+	// may be repeated.
+	MinProbeInterval = time.Minute
+)
+
+var (
+	// ErrNoProbeDestinations is returned when a probe request doesn't
+	// contain any destinations.
+	ErrNoProbeDestinations = errors.New("at least one probe destination " +
+		"required")
+
+	// ErrNoProbeAmounts is returned when a probe request doesn't contain
+	// any amounts.
+	ErrNoProbeAmounts = errors.New("at least one probe amount required")
+
+	// ErrProbeIntervalTooShort is returned when a probe schedule is added
+	// with an interval below MinProbeInterval.
+	ErrProbeIntervalTooShort = fmt.Errorf("probe interval must be at "+
+		"least %v", MinProbeInterval)
+
+	// ErrUnknownProbeSchedule is returned when a probe schedule is
+	// referenced that doesn't exist.
+	ErrUnknownProbeSchedule = errors.New("unknown probe schedule")
+
+	// ErrProbeCanceled is returned when a probe run is canceled before all
+	// probes were sent.
+	ErrProbeCanceled = errors.New("probe canceled")
+)
+
+// ProberConfig contains the dependencies of the prober.
+type ProberConfig struct {
+	// SendPayment sends a payment and blocks until the payment lifecycle
+	// has reached a final outcome. The results of all attempts are
+	// reported to mission control as part of the lifecycle.
+	SendPayment func(*LightningPayment) ([32]byte, *route.Route, error)
+
+	// DeletePayment removes the payment with the given hash from the
+	// database once its probe has completed, so that probes don't clutter
+	// the payment history. It is optional.
+	DeletePayment func(lntypes.Hash) error
+
+	// MaxConcurrency is the maximum number of probes that are in flight at
+	// the same time, across all probe runs and schedules.
+	MaxConcurrency int
+
+	// Clock is the clock used to timestamp probe results.
+	Clock clock.Clock
+}
+
+// ProbeRequest describes a set of probes to a list of destinations.
+type ProbeRequest struct {
+	// Destinations are the nodes that are probed.
+	Destinations []route.Vertex
+
+	// Amounts are the amounts that are probed for every destination.
+	// Amounts are probed in ascending order. Once an amount can't be
+	// delivered to a destination, larger amounts aren't probed for it.
+	Amounts []lnwire.MilliSatoshi
+
+	// FeeLimit is the maximum fee that a probe route may have.
+	FeeLimit lnwire.MilliSatoshi
+
+	// CltvLimit is the maximum time lock that a probe route may have.
+	CltvLimit uint32
+
+	// FinalCLTVDelta is the CLTV delta used for the final hop of probes.
+	FinalCLTVDelta uint16
+
+	// Timeout is the time after which a single probe is abandoned. If
+	// zero, DefaultProbeTimeout is used.
+	Timeout time.Duration
+}
+
+// validate checks that the probe request is sane.
+func (r *ProbeRequest) validate() error {
+	if len(r.Destinations) == 0 {
+		return ErrNoProbeDestinations
+	}
+
+	if len(r.Amounts) == 0 {
+		return ErrNoProbeAmounts
+	}
+
+	for _, amt := range r.Amounts {
+		if amt == 0 {
+			return errors.New("probe amounts must be positive")
+		}
+	}
+
+	return nil
+}
+
+// AmountProbeResult is the outcome of probing a destination with a single
+// amount.
+type AmountProbeResult struct {
+	// Amount is the amount that was probed.
+	Amount lnwire.MilliSatoshi
+
+	// Reached indicates whether the probe reached the destination.
+	Reached bool
+
+	// Err is the reason the probe didn't reach the destination. If the
+	// payment lifecycle failed the probe, it is a channeldb.FailureReason.
+	Err error
+}
+
+// DestinationProbeResult is the outcome of probing a destination with all
+// amounts of a probe request.
+type DestinationProbeResult struct {
+	// Destination is the node that was probed.
+	Destination route.Vertex
+
+	// MaxSendable is the largest probed amount that reached the
+	// destination, which is our estimate for the amount that can
+	// currently be sent to it in a single part. It is zero if no probe
+	// reached the destination.
+	MaxSendable lnwire.MilliSatoshi
+
+	// Probes holds the results of the individual probes, in the order in
+	// which they were sent.
+	Probes []AmountProbeResult
+
+	// Timestamp is the time at which the last probe to the destination
+	// completed.
+	Timestamp time.Time
+}
+
+// ProbeSchedule is a probe request that is repeated at a fixed interval.
+type ProbeSchedule struct {
+	// ID is the identifier of the schedule.
+	ID uint64
+
+	// Request is the probe request that is repeated.
+	Request ProbeRequest
+
+	// Interval is the time between the start of two probe runs.
+	Interval time.Duration
+
+	// LastRun is the time at which the last probe run completed. It is the
+	// zero time if no run has completed yet.
+	LastRun time.Time
+
+	// LastResults are the results of the last completed probe run.
+	LastResults []*DestinationProbeResult
+}
+
+// probeSchedule is the internal state of a probe schedule.
+type probeSchedule struct {
+	ProbeSchedule
+
+	quit chan struct{}
+}
+
+// Prober measures the liquidity towards a set of destinations by sending
+// probes, which are payments with a random payment hash that can't be settled
+// by the destination. A probe that fails at the destination with incorrect
+// payment details has reached it. Because probes go through the regular
+// payment lifecycle, all their results feed into mission control.
+type Prober struct {
+	started uint32 // To be used atomically.
+	stopped uint32 // To be used atomically.
+
+	cfg *ProberConfig
+
+	// sem limits the number of probes that are in flight.
+	sem chan struct{}
+
+	// schedules holds all active probe schedules, keyed by their id.
+	schedules    map[uint64]*probeSchedule
+	nextSchedule uint64
+	mu           sync.Mutex
+
+	wg   sync.WaitGroup
+	quit chan struct{}
+}
+
+// NewProber creates a new prober.
+func NewProber(cfg *ProberConfig) (*Prober, error) {
+	if cfg.MaxConcurrency <= 0 {
+		return nil, fmt.Errorf("max probe concurrency must be "+
+			"positive, got %v", cfg.MaxConcurrency)
+	}
+
+	return &Prober{
+		cfg:       cfg,
+		sem:       make(chan struct{}, cfg.MaxConcurrency),
+		schedules: make(map[uint64]*probeSchedule),
+		quit:      make(chan struct{}),
+	}, nil
+}
+
+// Start starts the prober.
+func (p *Prober) Start() error {
+	if !atomic.CompareAndSwapUint32(&p.started, 0, 1) {
+		return nil
+	}
+
+	log.Debugf("Prober starting with max concurrency %v",
+		p.cfg.MaxConcurrency)
+
+	return nil
+}
+
+// Stop stops the prober and all its schedules. It blocks until probes that
+// are in flight have completed.
+func (p *Prober) Stop() error {
+	if !atomic.CompareAndSwapUint32(&p.stopped, 0, 1) {
+		return nil
+	}
+
+	log.Debugf("Prober shutting down")
+
+	close(p.quit)
+	p.wg.Wait()
+
+	return nil
+}
+
+// Probe probes all destinations of the request and blocks until all probes
+// have completed. Destinations are probed concurrently, subject to the
+// concurrency limit of the prober. Closing the cancel channel stops sending
+// new probes, in which case ErrProbeCanceled is returned.
+func (p *Prober) Probe(req *ProbeRequest,
+	cancel <-chan struct{}) ([]*DestinationProbeResult, error) {
+
+	if err := req.validate(); err != nil {
+		return nil, err
+	}
+
+	amounts := make([]lnwire.MilliSatoshi, len(req.Amounts))
+	copy(amounts, req.Amounts)
+	sort.Slice(amounts, func(i, j int) bool {
+		return amounts[i] < amounts[j]
+	})
+
+	var (
+		results = make([]*DestinationProbeResult, len(req.Destinations))
+		errs    = make([]error, len(req.Destinations))
+		wg      sync.WaitGroup
+	)
+	for i, dest := range req.Destinations {
+		wg.Add(1)
+		go func(i int, dest route.Vertex) {
+			defer wg.Done()
+
+			results[i], errs[i] = p.probeDestination(
+				req, dest, amounts, cancel,
+			)
+		}(i, dest)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return results, nil
+}
+
+// probeDestination probes a single destination with the given amounts in
+// ascending order, until an amount doesn't reach the destination.
+func (p *Prober) probeDestination(req *ProbeRequest, dest route.Vertex,
+	amounts []lnwire.MilliSatoshi,
+	cancel <-chan struct{}) (*DestinationProbeResult, error) {
+
+	result := &DestinationProbeResult{
+		Destination: dest,
+	}
+
+	for _, amt := range amounts {
+		if err := p.acquireProbeSlot(cancel); err != nil {
+			return nil, err
+		}
+
+		probeResult := p.sendProbe(req, dest, amt)
+		<-p.sem
+
+		result.Probes = append(result.Probes, probeResult)
+		result.Timestamp = p.cfg.Clock.Now()
+
+		if !probeResult.Reached {
+			break
+		}
+
+		result.MaxSendable = amt
+	}
+
+	log.Debugf("Probed %v: max sendable %v", dest, result.MaxSendable)
+
+	return result, nil
+}
+
+// acquireProbeSlot blocks until the number of probes in flight is below the
+// concurrency limit and reserves a slot for a new probe. The slot must be
+// released by reading from sem.
+func (p *Prober) acquireProbeSlot(cancel <-chan struct{}) error {
+	select {
+	case p.sem <- struct{}{}:
+
+	case <-cancel:
+		return ErrProbeCanceled
+
+	case <-p.quit:
+		return ErrRouterShuttingDown
+	}
+
+	// If the run was canceled while we were waiting, the slot may have
+	// been picked over the cancellation, so we check again to make sure
+	// that no probe is sent after a cancellation.
+	select {
+	case <-cancel:
+		<-p.sem
+		return ErrProbeCanceled
+
+	case <-p.quit:
+		<-p.sem
+		return ErrRouterShuttingDown
+
+	default:
+		return nil
+	}
+}
+
+// sendProbe sends a single probe to the destination and returns its outcome.
+func (p *Prober) sendProbe(req *ProbeRequest, dest route.Vertex,
+	amt lnwire.MilliSatoshi) AmountProbeResult {
+
+	result := AmountProbeResult{
+		Amount: amt,
+	}
+
+	var hash lntypes.Hash
+	if _, err := rand.Read(hash[:]); err != nil {
+		result.Err = err
+		return result
+	}
+
+	timeout := req.Timeout
+	if timeout == 0 {
+		timeout = DefaultProbeTimeout
+	}
+
+	// Probes are restricted to a single part. A multi-part probe would be
+	// failed by the destination as soon as its first part arrives, which
+	// tells us nothing about the full amount.
+	payment := &LightningPayment{
+		Target:            dest,
+		Amount:            amt,
+		FeeLimit:          req.FeeLimit,
+		CltvLimit:         req.CltvLimit,
+		FinalCLTVDelta:    req.FinalCLTVDelta,
+		PayAttemptTimeout: timeout,
+		MaxParts:          1,
+	}
+	if err := payment.SetPaymentHash(hash); err != nil {
+		result.Err = err
+		return result
+	}
+
+	log.Tracef("Sending probe %v to %v for %v", hash, dest, amt)
+
+	_, _, err := p.cfg.SendPayment(payment)
+
+	var (
+		reason    channeldb.FailureReason
+		completed = err == nil || errors.As(err, &reason)
+	)
+	switch {
+	// A probe should never succeed, but if it does, it certainly reached
+	// the destination.
+	case err == nil:
+		log.Warnf("Probe %v to %v unexpectedly succeeded", hash, dest)
+		result.Reached = true
+
+	// The destination doesn't know the random payment hash, so it fails
+	// every probe that reaches it with incorrect payment details.
+	case completed && reason == channeldb.FailureReasonPaymentDetails:
+		result.Reached = true
+
+	default:
+		result.Err = err
+	}
+
+	// If the payment lifecycle completed, the probe is in a final state
+	// and can be removed. Otherwise it may never have been registered with
+	// the control tower.
+	if completed && p.cfg.DeletePayment != nil {
+		if err := p.cfg.DeletePayment(hash); err != nil {
+			log.Warnf("Unable to delete probe %v: %v", hash, err)
+		}
+	}
+
+	return result
+}
+
+// AddSchedule adds a probe schedule that repeats the given probe request at
+// the given interval, starting immediately. It returns the id of the new
+// schedule.
+func (p *Prober) AddSchedule(req *ProbeRequest,
+	interval time.Duration) (uint64, error) {
+
+	if err := req.validate(); err != nil {
+		return 0, err
+	}
+
+	if interval < MinProbeInterval {
+		return 0, ErrProbeIntervalTooShort
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.nextSchedule++
+	schedule := &probeSchedule{
+		ProbeSchedule: ProbeSchedule{
+			ID:       p.nextSchedule,
+			Request:  *req,
+			Interval: interval,
+		},
+		quit: make(chan struct{}),
+	}
+	p.schedules[schedule.ID] = schedule
+
+	log.Infof("Added probe schedule %v for %v destinations every %v",
+		schedule.ID, len(req.Destinations), interval)
+
+	p.wg.Add(1)
+	go p.runSchedule(schedule)
+
+	return schedule.ID, nil
+}
+
+// RemoveSchedule stops and removes the probe schedule with the given id.
+// Probes of the schedule that are in flight aren't interrupted.
+func (p *Prober) RemoveSchedule(id uint64) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	schedule, ok := p.schedules[id]
+	if !ok {
+		return ErrUnknownProbeSchedule
+	}
+
+	close(schedule.quit)
+	delete(p.schedules, id)
+
+	log.Infof("Removed probe schedule %v", id)
+
+	return nil
+}
+
+// Schedules returns a copy of all active probe schedules, ordered by id.
+func (p *Prober) Schedules() []ProbeSchedule {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	schedules := make([]ProbeSchedule, 0, len(p.schedules))
+	for _, schedule := range p.schedules {
+		schedules = append(schedules, schedule.ProbeSchedule)
+	}
+
+	sort.Slice(schedules, func(i, j int) bool {
+		return schedules[i].ID < schedules[j].ID
+	})
+
+	return schedules
+}
+
+// runSchedule runs the probe request of the schedule at its interval until
+// the schedule is removed or the prober is stopped.
+//
+// NOTE: This MUST be run as a goroutine.
+func (p *Prober) runSchedule(schedule *probeSchedule) {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(schedule.Interval)
+	defer ticker.Stop()
+
+	for {
+		results, err := p.Probe(&schedule.Request, schedule.quit)
+		switch {
+		case err == nil:
+			p.mu.Lock()
+			schedule.LastRun = p.cfg.Clock.Now()
+			schedule.LastResults = results
+			p.mu.Unlock()
+
+		case errors.Is(err, ErrProbeCanceled),
+			errors.Is(err, ErrRouterShuttingDown):
+
+			return
+
+		default:
+			log.Errorf("Probe schedule %v failed: %v", schedule.ID,
+				err)
+		}
+
+		select {
+		case <-ticker.C:
+
+		case <-schedule.quit:
+			return
+
+		case <-p.quit:
+			return
+		}
+	}
+}
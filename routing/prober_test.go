@@ -0,0 +1,310 @@
+package routing
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/brsuite/broln/channeldb"
+	"github.com/brsuite/broln/clock"
+	"github.com/brsuite/broln/lntypes"
+	"github.com/brsuite/broln/lnwire"
+	"github.com/brsuite/broln/routing/route"
+	"github.com/stretchr/testify/require"
+)
+
+// mockProbePayer simulates the payment lifecycle for probes. A probe reaches
+// its destination if the amount doesn't exceed the liquidity towards it.
+type mockProbePayer struct {
+	liquidity map[route.Vertex]lnwire.MilliSatoshi
+
+	// block, if set, is waited on by every probe before it returns.
+	block chan struct{}
+
+	mu          sync.Mutex
+	inFlight    int
+	maxInFlight int
+	probes      []*LightningPayment
+	deleted     []lntypes.Hash
+}
+
+func (m *mockProbePayer) SendPayment(payment *LightningPayment) ([32]byte,
+	*route.Route, error) {
+
+	m.mu.Lock()
+	m.inFlight++
+	if m.inFlight > m.maxInFlight {
+		m.maxInFlight = m.inFlight
+	}
+	m.probes = append(m.probes, payment)
+	m.mu.Unlock()
+
+	if m.block != nil {
+		<-m.block
+	}
+
+	m.mu.Lock()
+	m.inFlight--
+	m.mu.Unlock()
+
+	liquidity, ok := m.liquidity[payment.Target]
+	switch {
+	case !ok:
+		return [32]byte{}, nil, errors.New("unknown target")
+
+	case payment.Amount > liquidity:
+		return [32]byte{}, nil, channeldb.FailureReasonNoRoute
+
+	default:
+		return [32]byte{}, nil, channeldb.FailureReasonPaymentDetails
+	}
+}
+
+func (m *mockProbePayer) DeletePayment(hash lntypes.Hash) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.deleted = append(m.deleted, hash)
+
+	return nil
+}
+
+func newTestProber(t *testing.T, payer *mockProbePayer,
+	maxConcurrency int) *Prober {
+
+	prober, err := NewProber(&ProberConfig{
+		SendPayment:    payer.SendPayment,
+		DeletePayment:  payer.DeletePayment,
+		MaxConcurrency: maxConcurrency,
+		Clock:          clock.NewTestClock(testTime),
+	})
+	require.NoError(t, err)
+	require.NoError(t, prober.Start())
+	t.Cleanup(func() {
+		require.NoError(t, prober.Stop())
+	})
+
+	return prober
+}
+
+// TestProberProbe tests that destinations are probed with ascending amounts
+// until an amount doesn't reach them.
+func TestProberProbe(t *testing.T) {
+	var (
+		destA   = route.Vertex{1}
+		destB   = route.Vertex{2}
+		unknown = route.Vertex{3}
+	)
+
+	payer := &mockProbePayer{
+		liquidity: map[route.Vertex]lnwire.MilliSatoshi{
+			destA: 250,
+			destB: 50,
+		},
+	}
+	prober := newTestProber(t, payer, DefaultMaxProbeConcurrency)
+
+	results, err := prober.Probe(&ProbeRequest{
+		Destinations: []route.Vertex{destA, destB, unknown},
+		Amounts:      []lnwire.MilliSatoshi{300, 100, 200},
+		FeeLimit:     10,
+	}, nil)
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+
+	require.Equal(t, &DestinationProbeResult{
+		Destination: destA,
+		MaxSendable: 200,
+		Probes: []AmountProbeResult{
+			{Amount: 100, Reached: true},
+			{Amount: 200, Reached: true},
+			{
+				Amount: 300,
+				Err:    channeldb.FailureReasonNoRoute,
+			},
+		},
+		Timestamp: testTime,
+	}, results[0])
+
+	require.Equal(t, &DestinationProbeResult{
+		Destination: destB,
+		Probes: []AmountProbeResult{
+			{
+				Amount: 100,
+				Err:    channeldb.FailureReasonNoRoute,
+			},
+		},
+		Timestamp: testTime,
+	}, results[1])
+
+	require.Equal(t, unknown, results[2].Destination)
+	require.Zero(t, results[2].MaxSendable)
+	require.Len(t, results[2].Probes, 1)
+	require.Error(t, results[2].Probes[0].Err)
+
+	// All probes must be restricted to a single part, and use distinct
+	// payment hashes.
+	hashes := make(map[lntypes.Hash]struct{})
+	for _, payment := range payer.probes {
+		require.EqualValues(t, 1, payment.MaxParts)
+		require.EqualValues(t, 10, payment.FeeLimit)
+		require.Equal(t, DefaultProbeTimeout, payment.PayAttemptTimeout)
+
+		hashes[payment.Identifier()] = struct{}{}
+	}
+	require.Len(t, hashes, 5)
+
+	// Only the probes that completed the payment lifecycle are deleted.
+	require.Len(t, payer.deleted, 4)
+}
+
+// TestProberConcurrency tests that the number of probes in flight is limited
+// to the configured maximum.
+func TestProberConcurrency(t *testing.T) {
+	const maxConcurrency = 2
+
+	payer := &mockProbePayer{
+		liquidity: make(map[route.Vertex]lnwire.MilliSatoshi),
+		block:     make(chan struct{}),
+	}
+
+	var dests []route.Vertex
+	for i := 0; i < 5; i++ {
+		dest := route.Vertex{byte(i + 1)}
+		payer.liquidity[dest] = 1000
+		dests = append(dests, dest)
+	}
+
+	prober := newTestProber(t, payer, maxConcurrency)
+
+	done := make(chan error)
+	go func() {
+		_, err := prober.Probe(&ProbeRequest{
+			Destinations: dests,
+			Amounts:      []lnwire.MilliSatoshi{100, 200},
+		}, nil)
+		done <- err
+	}()
+
+	// Release the probes one by one, while checking that no more than the
+	// maximum number of probes is in flight.
+	for i := 0; i < len(dests)*2; i++ {
+		require.Eventually(t, func() bool {
+			payer.mu.Lock()
+			defer payer.mu.Unlock()
+
+			return payer.inFlight == maxConcurrency ||
+				len(dests)*2-i < maxConcurrency
+		}, time.Second, time.Millisecond)
+
+		payer.block <- struct{}{}
+	}
+
+	require.NoError(t, <-done)
+	require.Equal(t, maxConcurrency, payer.maxInFlight)
+}
+
+// TestProberCancel tests that a probe run stops sending probes when it is
+// canceled.
+func TestProberCancel(t *testing.T) {
+	dest := route.Vertex{1}
+	payer := &mockProbePayer{
+		liquidity: map[route.Vertex]lnwire.MilliSatoshi{
+			dest: 1000,
+		},
+		block: make(chan struct{}),
+	}
+	prober := newTestProber(t, payer, 1)
+
+	cancel := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		_, err := prober.Probe(&ProbeRequest{
+			Destinations: []route.Vertex{dest, {2}},
+			Amounts:      []lnwire.MilliSatoshi{100},
+		}, cancel)
+		done <- err
+	}()
+
+	// Wait for the first probe to be in flight, which leaves the second
+	// destination waiting for the concurrency limit.
+	require.Eventually(t, func() bool {
+		payer.mu.Lock()
+		defer payer.mu.Unlock()
+
+		return payer.inFlight == 1
+	}, time.Second, time.Millisecond)
+
+	close(cancel)
+	payer.block <- struct{}{}
+
+	require.ErrorIs(t, <-done, ErrProbeCanceled)
+	require.Len(t, payer.probes, 1)
+}
+
+// TestProberValidation tests that invalid probe requests are rejected.
+func TestProberValidation(t *testing.T) {
+	prober := newTestProber(t, &mockProbePayer{}, 1)
+
+	_, err := prober.Probe(&ProbeRequest{
+		Amounts: []lnwire.MilliSatoshi{100},
+	}, nil)
+	require.ErrorIs(t, err, ErrNoProbeDestinations)
+
+	_, err = prober.Probe(&ProbeRequest{
+		Destinations: []route.Vertex{{1}},
+	}, nil)
+	require.ErrorIs(t, err, ErrNoProbeAmounts)
+
+	_, err = prober.Probe(&ProbeRequest{
+		Destinations: []route.Vertex{{1}},
+		Amounts:      []lnwire.MilliSatoshi{0},
+	}, nil)
+	require.Error(t, err)
+
+	_, err = NewProber(&ProberConfig{})
+	require.Error(t, err)
+}
+
+// TestProberSchedule tests adding, listing and removing probe schedules.
+func TestProberSchedule(t *testing.T) {
+	dest := route.Vertex{1}
+	payer := &mockProbePayer{
+		liquidity: map[route.Vertex]lnwire.MilliSatoshi{
+			dest: 150,
+		},
+	}
+	prober := newTestProber(t, payer, 1)
+
+	req := &ProbeRequest{
+		Destinations: []route.Vertex{dest},
+		Amounts:      []lnwire.MilliSatoshi{100, 200},
+	}
+
+	_, err := prober.AddSchedule(req, time.Second)
+	require.ErrorIs(t, err, ErrProbeIntervalTooShort)
+
+	id, err := prober.AddSchedule(req, time.Hour)
+	require.NoError(t, err)
+
+	// The schedule runs immediately after it is added.
+	require.Eventually(t, func() bool {
+		schedules := prober.Schedules()
+
+		return len(schedules) == 1 && !schedules[0].LastRun.IsZero()
+	}, time.Second, time.Millisecond)
+
+	schedule := prober.Schedules()[0]
+	require.Equal(t, id, schedule.ID)
+	require.Equal(t, time.Hour, schedule.Interval)
+	require.Equal(t, *req, schedule.Request)
+	require.Len(t, schedule.LastResults, 1)
+	require.EqualValues(t, 100, schedule.LastResults[0].MaxSendable)
+
+	require.NoError(t, prober.RemoveSchedule(id))
+	require.Empty(t, prober.Schedules())
+
+	err = prober.RemoveSchedule(id)
+	require.ErrorIs(t, err, ErrUnknownProbeSchedule)
+}
@@ -127,6 +127,30 @@ type Hop struct {
 	// understand the new TLV payload, so we must instead use the legacy
 	// payload.
 	LegacyPayload bool
+
+	// EncryptedData is the data the recipient left for this hop if it is
+	// part of a blinded route. Such hops receive their forwarding
+	// instructions from it, instead of from the sender.
+	EncryptedData []byte
+
+	// BlindingPoint is the blinding point of a blinded route. It should
+	// only be set for the introduction node of the route.
+	BlindingPoint *btcec.PublicKey
+
+	// TotalAmtMsat is the total amount the recipient of a payment over a
+	// blinded route receives. It should only be set for the final hop of
+	// a blinded route, where it replaces the MPP record.
+	TotalAmtMsat lnwire.MilliSatoshi
+}
+
+// isBlinded returns true if the hop is part of a blinded route.
+func (h *Hop) isBlinded() bool {
+	return len(h.EncryptedData) > 0
+}
+
+// isFinalBlinded returns true if the hop is the final hop of a blinded route.
+func (h *Hop) isFinalBlinded() bool {
+	return h.isBlinded() && h.TotalAmtMsat != 0
 }
 
 // Copy returns a deep copy of the Hop.
@@ -165,12 +189,39 @@ func (h *Hop) PackHopPayload(w io.Writer, nextChanID uint64) error {
 	// required routing fields, as well as these optional values.
 	var records []tlv.Record
 
-	// Every hop must have an amount to forward and CLTV expiry.
+	// Every hop must have an amount to forward and CLTV expiry, except for
+	// the intermediate hops of a blinded route that derive them from the
+	// incoming HTLC.
 	amt := uint64(h.AmtToForward)
-	records = append(records,
-		record.NewAmtToFwdRecord(&amt),
-		record.NewLockTimeRecord(&h.OutgoingTimeLock),
-	)
+	if !h.isBlinded() || h.isFinalBlinded() {
+		records = append(records,
+			record.NewAmtToFwdRecord(&amt),
+			record.NewLockTimeRecord(&h.OutgoingTimeLock),
+		)
+	}
+
+	// Hops of a blinded route receive the data the recipient left for
+	// them, and the introduction node the blinding point to decrypt it.
+	// The final hop learns about the total amount of the payment from us,
+	// as it can't receive an MPP record.
+	totalAmt := uint64(h.TotalAmtMsat)
+	if h.isBlinded() {
+		records = append(records,
+			record.NewEncryptedDataRecord(&h.EncryptedData),
+		)
+
+		if h.BlindingPoint != nil {
+			records = append(records,
+				record.NewBlindingPointRecord(&h.BlindingPoint),
+			)
+		}
+
+		if h.isFinalBlinded() {
+			records = append(records,
+				record.NewTotalAmtMsatBlindedRecord(&totalAmt),
+			)
+		}
+	}
 
 	// BOLT 04 says the next_hop_id should be omitted for the final hop,
 	// but present for all others.
@@ -235,14 +286,37 @@ func (h *Hop) PayloadSize(nextChanID uint64) uint64 {
 			tlv.VarIntSize(length) + length
 	}
 
-	// Add amount size.
-	addRecord(record.AmtOnionType, tlv.SizeTUint64(uint64(h.AmtToForward)))
+	// Add amount and lock time size, unless they are derived from the
+	// encrypted data.
+	if !h.isBlinded() || h.isFinalBlinded() {
+		addRecord(
+			record.AmtOnionType,
+			tlv.SizeTUint64(uint64(h.AmtToForward)),
+		)
+		addRecord(
+			record.LockTimeOnionType,
+			tlv.SizeTUint64(uint64(h.OutgoingTimeLock)),
+		)
+	}
+
+	// Add the blinded route records if present.
+	if h.isBlinded() {
+		addRecord(
+			record.EncryptedDataOnionType,
+			uint64(len(h.EncryptedData)),
+		)
 
-	// Add lock time size.
-	addRecord(
-		record.LockTimeOnionType,
-		tlv.SizeTUint64(uint64(h.OutgoingTimeLock)),
-	)
+		if h.BlindingPoint != nil {
+			addRecord(record.BlindingPointOnionType, 33)
+		}
+
+		if h.isFinalBlinded() {
+			addRecord(
+				record.TotalAmtMsatBlindedType,
+				tlv.SizeTUint64(uint64(h.TotalAmtMsat)),
+			)
+		}
+	}
 
 	// Add next hop if present.
 	if nextChanID != 0 {
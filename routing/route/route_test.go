@@ -187,6 +187,24 @@ func TestPayloadSize(t *testing.T) {
 				1000000: {4, 5},
 			},
 		},
+		{
+			PubKeyBytes:      testPubKeyBytes,
+			AmtToForward:     1200,
+			OutgoingTimeLock: 700000,
+			EncryptedData:    []byte{1, 2, 3, 4},
+			BlindingPoint:    testPubKey,
+		},
+		{
+			PubKeyBytes:   testPubKeyBytes,
+			EncryptedData: []byte{5, 6},
+		},
+		{
+			PubKeyBytes:      testPubKeyBytes,
+			AmtToForward:     1200,
+			OutgoingTimeLock: 700000,
+			EncryptedData:    []byte{7},
+			TotalAmtMsat:     2400,
+		},
 	}
 
 	rt := Route{
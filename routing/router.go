@@ -4,6 +4,8 @@ import (
 	"bytes"
 	goErrors "errors"
 	"fmt"
+	"math"
+	"math/bits"
 	"runtime"
 	"strings"
 	"sync"
@@ -32,6 +34,7 @@ import (
 	"github.com/brsuite/broln/lnwire"
 	"github.com/brsuite/broln/multimutex"
 	"github.com/brsuite/broln/record"
+	"github.com/brsuite/broln/routing/blindedpath"
 	"github.com/brsuite/broln/routing/chainview"
 	"github.com/brsuite/broln/routing/route"
 	"github.com/brsuite/broln/routing/shards"
@@ -80,12 +83,20 @@ const (
 	// brocoin (160 for litecoin), though we now clamp the lower end of this
 	// range for user-chosen deltas to 18 blocks to be conservative.
 	MinCLTVDelta = 18
+
+	// MaxFeeLimitPPM is the largest proportional fee limit a payment may
+	// have, which allows the fees to be as large as the amount itself.
+	MaxFeeLimitPPM = 1_000_000
 )
 
 var (
 	// ErrRouterShuttingDown is returned if the router is in the process of
 	// shutting down.
 	ErrRouterShuttingDown = fmt.Errorf("router shutting down")
+
+	// ErrNoPairChannel is returned by FetchPairCapacity if there is no
+	// channel in the graph that connects the two nodes.
+	ErrNoPairChannel = goErrors.New("no channel between nodes")
 )
 
 // ChannelGraphSource represents the source of information about the topology
@@ -226,9 +237,10 @@ type MissionController interface {
 	ReportPaymentSuccess(attemptID uint64, rt *route.Route) error
 
 	// GetProbability is expected to return the success probability of a
-	// payment from fromNode along edge.
+	// payment from fromNode along edge. The capacity of the connection is
+	// zero if it is unknown.
 	GetProbability(fromNode, toNode route.Vertex,
-		amt lnwire.MilliSatoshi) float64
+		amt lnwire.MilliSatoshi, capacity bronutil.Amount) float64
 }
 
 // FeeSchema is the set fee configuration for a Lightning Node on the network.
@@ -347,6 +359,11 @@ type Config struct {
 	// from blocking initial usage of the daemon.
 	AssumeChannelValid bool
 
+	// IsOwnAlias returns true if the passed alias ShortChannelID was
+	// allocated by us for one of our own channels. Edges using such an
+	// alias are added without validating their funding output.
+	IsOwnAlias func(scid lnwire.ShortChannelID) bool
+
 	// PathFindingConfig defines global path finding parameters.
 	PathFindingConfig PathFindingConfig
 
@@ -1453,11 +1470,23 @@ func (r *ChannelRouter) processUpdate(msg interface{},
 				"chan_id=%v", msg.ChannelID)
 		}
 
+		// Alias ShortChannelIDs are only used locally, so the only
+		// edges using them are those of our own channels. There is no
+		// funding transaction at the location an alias points to, so
+		// any other edge using one can't be validated.
+		channelID := lnwire.NewShortChanIDFromInt(msg.ChannelID)
+		isAlias := channeldb.IsAlias(channelID)
+		if isAlias && !r.isOwnAliasEdge(channelID, msg) {
+			return newErrf(ErrNoFundingTransaction, "ignoring edge "+
+				"with unknown alias chan_id=%v", msg.ChannelID)
+		}
+
 		// If AssumeChannelValid is present, then we are unable to
 		// perform any of the expensive checks below, so we'll
 		// short-circuit our path straight to adding the edge to our
-		// graph.
-		if r.cfg.AssumeChannelValid {
+		// graph. The same goes for edges of our own channels using an
+		// alias ShortChannelID.
+		if r.cfg.AssumeChannelValid || isAlias {
 			if err := r.cfg.Graph.AddChannelEdge(msg, op...); err != nil {
 				return fmt.Errorf("unable to add edge: %v", err)
 			}
@@ -1473,7 +1502,6 @@ func (r *ChannelRouter) processUpdate(msg interface{},
 		// Before we can add the channel to the channel graph, we need
 		// to obtain the full funding outpoint that's encoded within
 		// the channel ID.
-		channelID := lnwire.NewShortChanIDFromInt(msg.ChannelID)
 		fundingTx, err := r.fetchFundingTx(&channelID)
 		if err != nil {
 			// In order to ensure we don't erroneously mark a
@@ -1688,6 +1716,19 @@ func (r *ChannelRouter) processUpdate(msg interface{},
 	return nil
 }
 
+// isOwnAliasEdge returns true if the edge connects our node, and uses an
+// alias ShortChannelID we allocated for one of our channels.
+func (r *ChannelRouter) isOwnAliasEdge(scid lnwire.ShortChannelID,
+	edge *channeldb.ChannelEdgeInfo) bool {
+
+	self := r.selfNode.PubKeyBytes
+	if edge.NodeKey1Bytes != self && edge.NodeKey2Bytes != self {
+		return false
+	}
+
+	return r.cfg.IsOwnAlias != nil && r.cfg.IsOwnAlias(scid)
+}
+
 // fetchFundingTx returns the funding transaction identified by the passed
 // short channel ID.
 //
@@ -1886,6 +1927,17 @@ type LightningPayment struct {
 	// if there isn't a route with lower fees than this limit.
 	FeeLimit lnwire.MilliSatoshi
 
+	// FeeLimitPPM is the maximum fee of the payment in parts per million
+	// of the amount. If it is set, the fee limit of the payment is the
+	// larger of FeeLimit and the proportional limit, so that FeeLimit acts
+	// as a base budget for small payments. It must not exceed
+	// MaxFeeLimitPPM.
+	FeeLimitPPM uint64
+
+	// MaxHopFee is the maximum fee in millisatoshis that a single hop of a
+	// route may charge. If zero, hops aren't limited individually.
+	MaxHopFee lnwire.MilliSatoshi
+
 	// CltvLimit is the maximum time lock that is allowed for attempts to
 	// complete this payment.
 	CltvLimit uint32
@@ -1929,6 +1981,37 @@ type LightningPayment struct {
 	// is reached. If nil, any node may be used.
 	LastHop *route.Vertex
 
+	// FirstHops is the list of peers that are allowed as the first hop of
+	// the route. If empty, any peer may be used.
+	FirstHops []route.Vertex
+
+	// ExcludedNodes is the list of nodes that may not be used anywhere in
+	// the route.
+	ExcludedNodes []route.Vertex
+
+	// ExcludedCountries is a list of ISO 3166-1 alpha-2 country codes. A
+	// node that advertises an address located in one of these countries
+	// may not be used anywhere in the route. Requires a NodeLocator to be
+	// configured in the SessionSource.
+	ExcludedCountries []string
+
+	// ExcludedASNs is a list of autonomous system numbers. A node that
+	// advertises an address announced by one of these autonomous systems
+	// may not be used anywhere in the route. Requires a NodeLocator to be
+	// configured in the SessionSource.
+	ExcludedASNs []uint32
+
+	// ExcludeUnlocatedNodes indicates whether nodes that don't advertise
+	// any address of a known location, such as private nodes, may not be
+	// used in the route when excluding countries or autonomous systems.
+	// If false, such nodes are not excluded.
+	ExcludeUnlocatedNodes bool
+
+	// MaxHops is the maximum number of hops of a route, including the
+	// final hop. If zero, the number of hops is only limited by the onion
+	// packet size.
+	MaxHops uint32
+
 	// DestFeatures specifies the set of features we assume the final node
 	// has for pathfinding. Typically these will be taken directly from an
 	// invoice, but they can also be manually supplied or assumed by the
@@ -1963,6 +2046,13 @@ type LightningPayment struct {
 	//
 	// NOTE: This field is _optional_.
 	MaxShardAmt *lnwire.MilliSatoshi
+
+	// BlindedPath is a blinded path to the recipient that the payment
+	// should be sent over. If set, Target must be the introduction node of
+	// the path, as the recipient itself remains hidden.
+	//
+	// NOTE: This field is _optional_.
+	BlindedPath *blindedpath.PaymentPath
 }
 
 // AMPOptions houses information that must be known in order to send an AMP
@@ -1994,6 +2084,39 @@ func (l *LightningPayment) SetAMP(amp *AMPOptions) error {
 	return nil
 }
 
+// TotalFeeLimit returns the maximum total fee that may be paid for the payment,
+// taking both the absolute and the proportional fee limit into account.
+func (l *LightningPayment) TotalFeeLimit() lnwire.MilliSatoshi {
+	return TotalFeeLimit(l.Amount, l.FeeLimit, l.FeeLimitPPM)
+}
+
+// TotalFeeLimit returns the maximum total fee that may be paid for a payment of
+// the given amount, which is the larger of the absolute fee limit and the
+// proportional fee limit in parts per million of the amount.
+func TotalFeeLimit(amt, feeLimit lnwire.MilliSatoshi,
+	feeLimitPPM uint64) lnwire.MilliSatoshi {
+
+	if feeLimitPPM == 0 {
+		return feeLimit
+	}
+
+	// Calculate the proportional limit with 128 bit precision, to not
+	// overflow for large amounts and fee rates. If the result doesn't fit
+	// into 64 bits, the payment is effectively unlimited.
+	hi, lo := bits.Mul64(uint64(amt), feeLimitPPM)
+	if hi >= 1_000_000 {
+		return lnwire.MilliSatoshi(math.MaxUint64)
+	}
+	ppmLimit, _ := bits.Div64(hi, lo, 1_000_000)
+
+	limit := lnwire.MilliSatoshi(ppmLimit)
+	if limit < feeLimit {
+		return feeLimit
+	}
+
+	return limit
+}
+
 // Identifier returns a 32-byte slice that uniquely identifies this single
 // payment. For non-AMP payments this will be the payment hash, for AMP
 // payments this will be the used SetID.
@@ -2026,7 +2149,7 @@ func (r *ChannelRouter) SendPayment(payment *LightningPayment) ([32]byte,
 	// Since this is the first time this payment is being made, we pass nil
 	// for the existing attempt.
 	return r.sendPayment(
-		payment.Amount, payment.FeeLimit, payment.Identifier(),
+		payment.Amount, payment.TotalFeeLimit(), payment.Identifier(),
 		payment.PayAttemptTimeout, paySession, shardTracker,
 	)
 }
@@ -2049,7 +2172,8 @@ func (r *ChannelRouter) SendPaymentAsync(payment *LightningPayment) error {
 			spewPayment(payment))
 
 		_, _, err := r.sendPayment(
-			payment.Amount, payment.FeeLimit, payment.Identifier(),
+			payment.Amount, payment.TotalFeeLimit(),
+			payment.Identifier(),
 			payment.PayAttemptTimeout, paySession, shardTracker,
 		)
 		if err != nil {
@@ -2508,6 +2632,30 @@ func (r *ChannelRouter) FetchLightningNode(
 	return r.cfg.Graph.FetchLightningNode(node)
 }
 
+// FetchPairCapacity returns the capacity of the connection between two nodes,
+// which is the largest capacity of the channels that the from node can use to
+// forward to the to node. This is the capacity that path finding passes on to
+// probability estimation. ErrNoPairChannel is returned if the nodes aren't
+// connected by a channel.
+func (r *ChannelRouter) FetchPairCapacity(nodeFrom,
+	nodeTo route.Vertex) (bronutil.Amount, error) {
+
+	u := newUnifiedPolicies(r.selfNode.PubKeyBytes, nodeTo, nil)
+
+	err := u.addGraphPolicies(r.cachedGraph)
+	if err != nil {
+		return 0, err
+	}
+
+	unifiedPolicy, ok := u.policies[nodeFrom]
+	if !ok {
+		return 0, fmt.Errorf("%w: from %v to %v", ErrNoPairChannel,
+			nodeFrom, nodeTo)
+	}
+
+	return unifiedPolicy.capacity(), nil
+}
+
 // ForEachNode is used to iterate over every node in router topology.
 //
 // NOTE: This method is part of the ChannelGraphSource interface.
@@ -116,12 +116,15 @@ func createTestCtxFromGraphInstanceAssumeValid(t *testing.T,
 		AttemptCost:    100,
 	}
 
+	estimator, err := NewAprioriEstimator(AprioriConfig{
+		PenaltyHalfLife:       time.Hour,
+		AprioriHopProbability: 0.9,
+		AprioriWeight:         0.5,
+	})
+	require.NoError(t, err, "failed to create estimator")
+
 	mcConfig := &MissionControlConfig{
-		ProbabilityEstimatorCfg: ProbabilityEstimatorCfg{
-			PenaltyHalfLife:       time.Hour,
-			AprioriHopProbability: 0.9,
-			AprioriWeight:         0.5,
-		},
+		Estimator: estimator,
 	}
 
 	mc, err := NewMissionControl(
@@ -4349,3 +4352,155 @@ func TestBlockDifferenceFix(t *testing.T) {
 		t.Fatalf("block height wasn't updated: %v", err)
 	}
 }
+
+// TestLightningPaymentTotalFeeLimit tests that the total fee limit of a
+// payment is the larger of its absolute and proportional fee limit.
+func TestLightningPaymentTotalFeeLimit(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name        string
+		amount      lnwire.MilliSatoshi
+		feeLimit    lnwire.MilliSatoshi
+		feeLimitPPM uint64
+		expected    lnwire.MilliSatoshi
+	}{
+		{
+			name:     "absolute limit only",
+			amount:   1_000_000,
+			feeLimit: 500,
+			expected: 500,
+		},
+		{
+			name:        "proportional limit only",
+			amount:      1_000_000,
+			feeLimitPPM: 1_000,
+			expected:    1_000,
+		},
+		{
+			name:        "proportional limit larger",
+			amount:      1_000_000,
+			feeLimit:    500,
+			feeLimitPPM: 1_000,
+			expected:    1_000,
+		},
+		{
+			name:        "absolute limit larger",
+			amount:      1_000_000,
+			feeLimit:    5_000,
+			feeLimitPPM: 1_000,
+			expected:    5_000,
+		},
+		{
+			name:        "proportional limit overflow",
+			amount:      math.MaxUint64,
+			feeLimitPPM: math.MaxUint64,
+			expected:    math.MaxUint64,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			payment := &LightningPayment{
+				Amount:      testCase.amount,
+				FeeLimit:    testCase.feeLimit,
+				FeeLimitPPM: testCase.feeLimitPPM,
+			}
+
+			require.Equal(
+				t, testCase.expected, payment.TotalFeeLimit(),
+			)
+		})
+	}
+}
+
+// TestFetchPairCapacity asserts that the capacity of a pair of nodes is the
+// capacity of the channel between them, and that a pair without a channel is
+// reported with ErrNoPairChannel.
+func TestFetchPairCapacity(t *testing.T) {
+	t.Parallel()
+
+	const startingBlockHeight = 101
+	ctx, cleanUp := createTestCtxFromFile(
+		t, startingBlockHeight, basicGraphFilePath,
+	)
+	defer cleanUp()
+
+	capacity, err := ctx.router.FetchPairCapacity(
+		ctx.aliases["songoku"], ctx.aliases["sophon"],
+	)
+	require.NoError(t, err)
+	require.EqualValues(t, 110000, capacity)
+
+	_, err = ctx.router.FetchPairCapacity(
+		ctx.aliases["roasbeef"], ctx.aliases["sophon"],
+	)
+	require.ErrorIs(t, err, ErrNoPairChannel)
+}
+
+// TestAddAliasEdge tests that edges using an alias ShortChannelID are only
+// added if they are edges of our own channels using an alias we allocated.
+func TestAddAliasEdge(t *testing.T) {
+	t.Parallel()
+
+	ctx, cleanUp := createTestCtxSingleNode(t, 0)
+	defer cleanUp()
+
+	ownAlias := channeldb.StartingAlias
+	unknownAlias := ownAlias
+	unknownAlias.TxPosition++
+
+	ctx.router.cfg.IsOwnAlias = func(scid lnwire.ShortChannelID) bool {
+		return scid == ownAlias
+	}
+
+	self := ctx.router.selfNode.PubKeyBytes
+	node1, err := createTestNode()
+	require.NoError(t, err)
+	node2, err := createTestNode()
+	require.NoError(t, err)
+
+	newEdge := func(scid lnwire.ShortChannelID,
+		key1, key2 [33]byte) *channeldb.ChannelEdgeInfo {
+
+		edge := &channeldb.ChannelEdgeInfo{
+			ChannelID:     scid.ToUint64(),
+			NodeKey1Bytes: key1,
+			NodeKey2Bytes: key2,
+		}
+		copy(
+			edge.BrocoinKey1Bytes[:],
+			brocoinKey1.SerializeCompressed(),
+		)
+		copy(
+			edge.BrocoinKey2Bytes[:],
+			brocoinKey2.SerializeCompressed(),
+		)
+
+		return edge
+	}
+
+	// An edge between two other nodes can't use an alias, even if it's
+	// one we allocated.
+	err = ctx.router.AddEdge(
+		newEdge(ownAlias, node1.PubKeyBytes, node2.PubKeyBytes),
+	)
+	require.True(t, IsError(err, ErrNoFundingTransaction))
+
+	// Neither can an edge of ours using an alias we don't know.
+	err = ctx.router.AddEdge(
+		newEdge(unknownAlias, self, node1.PubKeyBytes),
+	)
+	require.True(t, IsError(err, ErrNoFundingTransaction))
+
+	// Our own edge using our alias is added without a funding
+	// transaction.
+	err = ctx.router.AddEdge(newEdge(ownAlias, self, node1.PubKeyBytes))
+	require.NoError(t, err)
+
+	_, _, exists, _, err := ctx.graph.HasChannelEdge(ownAlias.ToUint64())
+	require.NoError(t, err)
+	require.True(t, exists)
+}
@@ -272,3 +272,17 @@ func (u *unifiedPolicy) minAmt() lnwire.MilliSatoshi {
 
 	return min
 }
+
+// capacity returns the largest capacity of the channels of this connection, or
+// zero if none of the capacities is known. Because forwarding is non-strict,
+// the largest channel is the one that is most likely to carry the payment.
+func (u *unifiedPolicy) capacity() bronutil.Amount {
+	var capacity bronutil.Amount
+	for _, edge := range u.edges {
+		if edge.capacity > capacity {
+			capacity = edge.capacity
+		}
+	}
+
+	return capacity
+}
@@ -0,0 +1,379 @@
+package rpcperms
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/brsuite/brond/chaincfg"
+	"github.com/brsuite/broln/accounts"
+	"github.com/brsuite/broln/lnrpc"
+	"github.com/brsuite/broln/lnrpc/routerrpc"
+	"github.com/brsuite/broln/lntypes"
+	"github.com/brsuite/broln/lnwire"
+	"github.com/brsuite/broln/zpay32"
+	"google.golang.org/grpc"
+)
+
+var (
+	// accountAllowedMethods is the set of methods that can be called with
+	// a macaroon bound to an off-chain account. All other methods are
+	// rejected, as they could either spend funds that aren't tracked by
+	// the account, or reveal information about other accounts.
+	accountAllowedMethods = map[string]struct{}{
+		"/lnrpc.Lightning/GetInfo":         {},
+		"/lnrpc.Lightning/DecodePayReq":    {},
+		"/lnrpc.Lightning/AddInvoice":      {},
+		"/lnrpc.Lightning/SendPaymentSync": {},
+		"/lnrpc.Lightning/ListAccounts":    {},
+		"/routerrpc.Router/SendPaymentV2":  {},
+	}
+)
+
+// accountPayment describes a payment made with a macaroon bound to an account.
+type accountPayment struct {
+	// hash is the payment hash of the payment.
+	hash lntypes.Hash
+
+	// fullAmt is the maximum amount the payment may cost, including fees.
+	fullAmt lnwire.MilliSatoshi
+}
+
+// feeLimitFunc returns the maximum fee of a payment of the given amount.
+type feeLimitFunc func(amt lnwire.MilliSatoshi) (lnwire.MilliSatoshi, error)
+
+// parseAccountPayment extracts the payment hash and maximum cost of a payment
+// request made with a macaroon bound to an account.
+func parseAccountPayment(req interface{},
+	netParams *chaincfg.Params) (*accountPayment, error) {
+
+	var (
+		amt            lnwire.MilliSatoshi
+		hashBytes      []byte
+		paymentRequest string
+		feeLimit       feeLimitFunc
+		err            error
+	)
+
+	switch r := req.(type) {
+	case *routerrpc.SendPaymentRequest:
+		if r.Amp {
+			return nil, fmt.Errorf("AMP payments not supported " +
+				"with account macaroon")
+		}
+
+		amt, err = lnrpc.UnmarshallAmt(r.Amt, r.AmtMsat)
+		if err != nil {
+			return nil, err
+		}
+		hashBytes = r.PaymentHash
+		paymentRequest = r.PaymentRequest
+
+		feeLimit = func(amt lnwire.MilliSatoshi) (lnwire.MilliSatoshi,
+			error) {
+
+			return paymentFeeLimit(r, amt)
+		}
+
+	case *lnrpc.SendRequest:
+		amt, err = lnrpc.UnmarshallAmt(r.Amt, r.AmtMsat)
+		if err != nil {
+			return nil, err
+		}
+		hashBytes = r.PaymentHash
+		if r.PaymentHashString != "" {
+			hashBytes, err = hex.DecodeString(r.PaymentHashString)
+			if err != nil {
+				return nil, fmt.Errorf("unable to decode payment "+
+					"hash: %v", err)
+			}
+		}
+		paymentRequest = r.PaymentRequest
+
+		feeLimit = func(amt lnwire.MilliSatoshi) (lnwire.MilliSatoshi,
+			error) {
+
+			return lnrpc.CalculateFeeLimit(r.FeeLimit, amt), nil
+		}
+
+	default:
+		return nil, fmt.Errorf("unable to check account balance for "+
+			"request type %T", req)
+	}
+
+	if paymentRequest != "" {
+		invoice, err := zpay32.Decode(paymentRequest, netParams)
+		if err != nil {
+			return nil, err
+		}
+
+		if invoice.MilliSat != nil && amt == 0 {
+			amt = *invoice.MilliSat
+		}
+		if invoice.PaymentHash != nil {
+			hashBytes = invoice.PaymentHash[:]
+		}
+	}
+
+	if amt == 0 {
+		return nil, fmt.Errorf("payment amount must be specified with " +
+			"account macaroon")
+	}
+
+	hash, err := lntypes.MakeHash(hashBytes)
+	if err != nil {
+		return nil, fmt.Errorf("payment hash must be specified with "+
+			"account macaroon: %v", err)
+	}
+
+	maxFee, err := feeLimit(amt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &accountPayment{
+		hash:    hash,
+		fullAmt: amt + maxFee,
+	}, nil
+}
+
+// AddAccountService adds the service that tracks the balances of off-chain
+// accounts to the interceptor. Until it is added, requests made with macaroons
+// bound to an account are rejected.
+func (r *InterceptorChain) AddAccountService(svc *accounts.Service) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.accountService = svc
+}
+
+// accountFromContext returns the ID of the account the macaroon of the given
+// request context is bound to, and the service tracking its balance. The
+// returned service is nil if the macaroon isn't bound to an account. An error
+// is returned if the method isn't allowed to be called with an account
+// macaroon.
+func (r *InterceptorChain) accountFromContext(ctx context.Context,
+	fullMethod string) (accounts.AccountID, *accounts.Service, error) {
+
+	var id accounts.AccountID
+
+	// Without macaroons, there are no accounts to enforce. The same is
+	// true for the methods that don't require a macaroon at all.
+	if r.noMacaroons {
+		return id, nil, nil
+	}
+	if _, ok := macaroonWhitelist[fullMethod]; ok {
+		return id, nil, nil
+	}
+
+	mac, _, err := macaroonFromContext(ctx)
+	if err != nil {
+		return id, nil, err
+	}
+
+	id, bound, err := accounts.AccountIDFromMacaroon(mac)
+	if err != nil {
+		return id, nil, err
+	}
+	if !bound {
+		return id, nil, nil
+	}
+
+	if _, ok := accountAllowedMethods[fullMethod]; !ok {
+		return id, nil, fmt.Errorf("%s: method not allowed with "+
+			"account macaroon", fullMethod)
+	}
+
+	r.RLock()
+	svc := r.accountService
+	r.RUnlock()
+
+	if svc == nil {
+		return id, nil, fmt.Errorf("account service not active")
+	}
+
+	return id, svc, nil
+}
+
+// accountUnaryServerInterceptor is a unary gRPC interceptor that keeps the
+// balance of the account a macaroon is bound to up to date with the invoices
+// created and the payments made with it.
+func (r *InterceptorChain) accountUnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+
+		id, svc, err := r.accountFromContext(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		if svc == nil {
+			return handler(ctx, req)
+		}
+
+		switch req := req.(type) {
+		case *lnrpc.Invoice:
+			if req.IsAmp {
+				return nil, fmt.Errorf("AMP invoices not " +
+					"supported with account macaroon")
+			}
+
+			resp, err := handler(ctx, req)
+			if err != nil {
+				return nil, err
+			}
+
+			addResp, ok := resp.(*lnrpc.AddInvoiceResponse)
+			if !ok {
+				return nil, fmt.Errorf("unexpected response "+
+					"type %T", resp)
+			}
+
+			hash, err := lntypes.MakeHash(addResp.RHash)
+			if err != nil {
+				return nil, err
+			}
+
+			err = svc.AssociateInvoice(id, hash)
+			if err != nil {
+				return nil, err
+			}
+
+			return resp, nil
+
+		case *lnrpc.SendRequest:
+			payment, err := parseAccountPayment(req, r.netParams)
+			if err != nil {
+				return nil, err
+			}
+
+			err = svc.AssociatePayment(
+				id, payment.hash, payment.fullAmt,
+			)
+			if err != nil {
+				return nil, err
+			}
+
+			resp, err := handler(ctx, req)
+
+			// Whatever the outcome of the call, the account is
+			// only debited once the payment succeeded.
+			trackErr := svc.TrackPayment(id, payment.hash)
+			if trackErr != nil {
+				r.rpcsLog.Errorf("Unable to track payment %v "+
+					"of account %v: %v", payment.hash, id,
+					trackErr)
+			}
+
+			return resp, err
+
+		case *lnrpc.ListAccountsRequest:
+			resp, err := handler(ctx, req)
+			if err != nil {
+				return nil, err
+			}
+
+			listResp, ok := resp.(*lnrpc.ListAccountsResponse)
+			if !ok {
+				return nil, fmt.Errorf("unexpected response "+
+					"type %T", resp)
+			}
+
+			// Only the account the macaroon is bound to is
+			// revealed.
+			var ownAccounts []*lnrpc.Account
+			for _, account := range listResp.Accounts {
+				if account.Id == id.String() {
+					ownAccounts = append(
+						ownAccounts, account,
+					)
+				}
+			}
+
+			return &lnrpc.ListAccountsResponse{
+				Accounts: ownAccounts,
+			}, nil
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// accountStreamServerInterceptor is a streaming gRPC interceptor that keeps
+// the balance of the account a macaroon is bound to up to date with the
+// payments made with it.
+func (r *InterceptorChain) accountStreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream,
+		info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+
+		id, svc, err := r.accountFromContext(
+			ss.Context(), info.FullMethod,
+		)
+		if err != nil {
+			return err
+		}
+		if svc == nil {
+			return handler(srv, ss)
+		}
+
+		wrappedSS := &accountStreamWrapper{
+			ServerStream: ss,
+			id:           id,
+			svc:          svc,
+			netParams:    r.netParams,
+		}
+		err = handler(srv, wrappedSS)
+
+		// Whatever the outcome of the call, the account is only debited
+		// once the payments made over the stream succeeded.
+		for _, hash := range wrappedSS.payments {
+			trackErr := svc.TrackPayment(id, hash)
+			if trackErr != nil {
+				r.rpcsLog.Errorf("Unable to track payment %v "+
+					"of account %v: %v", hash, id, trackErr)
+			}
+		}
+
+		return err
+	}
+}
+
+// accountStreamWrapper is a struct that wraps a server stream in a way that
+// each payment request received is associated with the account the macaroon
+// of the stream is bound to.
+type accountStreamWrapper struct {
+	// ServerStream is the stream that's being wrapped.
+	grpc.ServerStream
+
+	id accounts.AccountID
+
+	svc *accounts.Service
+
+	netParams *chaincfg.Params
+
+	// payments holds the payment hashes of the payments associated with
+	// the account over the stream.
+	payments []lntypes.Hash
+}
+
+// RecvMsg is called when broln wants to receive a message from the client.
+// This is wrapped to reserve the maximum cost of each payment request from
+// the balance of the account.
+func (w *accountStreamWrapper) RecvMsg(m interface{}) error {
+	if err := w.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+
+	payment, err := parseAccountPayment(m, w.netParams)
+	if err != nil {
+		return err
+	}
+
+	err = w.svc.AssociatePayment(w.id, payment.hash, payment.fullAmt)
+	if err != nil {
+		return err
+	}
+	w.payments = append(w.payments, payment.hash)
+
+	return nil
+}
@@ -0,0 +1,35 @@
+package rpcperms
+
+import (
+	"testing"
+
+	"github.com/brsuite/brond/chaincfg"
+	"github.com/brsuite/broln/lnrpc/routerrpc"
+	"github.com/brsuite/broln/lntypes"
+	"github.com/stretchr/testify/require"
+)
+
+// TestParseAccountPaymentFeeLimit asserts that the amount reserved for a
+// payment made with an account covers its proportional fee limit.
+func TestParseAccountPaymentFeeLimit(t *testing.T) {
+	t.Parallel()
+
+	hash := lntypes.Hash{1}
+	req := &routerrpc.SendPaymentRequest{
+		AmtMsat:      1_000_000,
+		PaymentHash:  hash[:],
+		FeeLimitMsat: 1_000,
+		FeeLimitPpm:  5_000,
+	}
+
+	payment, err := parseAccountPayment(
+		req, &chaincfg.RegressionNetParams,
+	)
+	require.NoError(t, err)
+	require.Equal(t, hash, payment.hash)
+	require.EqualValues(t, 1_005_000, payment.fullAmt)
+
+	req.FeeLimitPpm = 1_000_001
+	_, err = parseAccountPayment(req, &chaincfg.RegressionNetParams)
+	require.Error(t, err)
+}
@@ -0,0 +1,275 @@
+package rpcperms
+
+import (
+	"context"
+	"encoding/hex"
+
+	"github.com/brsuite/broln/auditlog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	// maxAuditRequestLen is the maximum length of the request summary
+	// recorded in the audit log. Longer requests are truncated.
+	maxAuditRequestLen = 1024
+)
+
+var (
+	// redactedFields is the set of request fields that carry secrets, and
+	// are thus left out of the request summaries recorded in the audit
+	// log. Custom records are left out as they carry the preimages of
+	// keysend payments.
+	redactedFields = map[protoreflect.Name]struct{}{
+		"preimage":             {},
+		"r_preimage":           {},
+		"payment_preimage":     {},
+		"custom_records":       {},
+		"dest_custom_records":  {},
+		"wallet_password":      {},
+		"current_password":     {},
+		"new_password":         {},
+		"aezeed_passphrase":    {},
+		"cipher_seed_mnemonic": {},
+		"enciphered_seed":      {},
+		"seed_entropy":         {},
+		"extended_master_key":  {},
+	}
+)
+
+// AddAuditLog adds the log that state-changing RPC calls are recorded in to
+// the interceptor. It must be called before the gRPC server is created.
+func (r *InterceptorChain) AddAuditLog(log *auditlog.Log) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.auditLog = log
+}
+
+// AuditLog returns the log that state-changing RPC calls are recorded in, or
+// nil if the audit log is disabled.
+func (r *InterceptorChain) AuditLog() *auditlog.Log {
+	r.RLock()
+	defer r.RUnlock()
+
+	return r.auditLog
+}
+
+// isStateChanging returns true if the given method requires any permission
+// other than a read permission, which is the case for all the methods that
+// can change the state of the node.
+func (r *InterceptorChain) isStateChanging(fullMethod string) bool {
+	r.RLock()
+	defer r.RUnlock()
+
+	for _, op := range r.permissionMap[fullMethod] {
+		if op.Action != "read" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// redactMessage clears all fields of the given message, and of the messages
+// nested in it, that carry secrets.
+func redactMessage(msg protoreflect.Message) {
+	var redacted []protoreflect.FieldDescriptor
+	msg.Range(func(fd protoreflect.FieldDescriptor,
+		v protoreflect.Value) bool {
+
+		if _, ok := redactedFields[fd.Name()]; ok {
+			redacted = append(redacted, fd)
+			return true
+		}
+
+		switch {
+		case fd.IsMap():
+			if fd.MapValue().Message() == nil {
+				return true
+			}
+
+			v.Map().Range(func(_ protoreflect.MapKey,
+				v protoreflect.Value) bool {
+
+				redactMessage(v.Message())
+				return true
+			})
+
+		case fd.IsList():
+			if fd.Message() == nil {
+				return true
+			}
+
+			list := v.List()
+			for i := 0; i < list.Len(); i++ {
+				redactMessage(list.Get(i).Message())
+			}
+
+		case fd.Message() != nil:
+			redactMessage(v.Message())
+		}
+
+		return true
+	})
+
+	for _, fd := range redacted {
+		msg.Clear(fd)
+	}
+}
+
+// summarizeRequest returns the JSON encoding of the given request with all
+// secrets redacted, truncated to maxAuditRequestLen.
+func summarizeRequest(req interface{}) string {
+	msg, ok := req.(proto.Message)
+	if !ok {
+		return ""
+	}
+
+	msg = proto.Clone(msg)
+	redactMessage(msg.ProtoReflect())
+
+	opts := protojson.MarshalOptions{
+		UseProtoNames: true,
+	}
+	summary, err := opts.Marshal(msg)
+	if err != nil {
+		return ""
+	}
+
+	if len(summary) > maxAuditRequestLen {
+		return string(summary[:maxAuditRequestLen]) + "..."
+	}
+
+	return string(summary)
+}
+
+// recordCall appends the result of a state-changing call to the audit log.
+// Failing to do so doesn't fail the call, as its effects can't be undone at
+// this point, but is logged as an error. All further state-changing calls are
+// rejected by checkAuditLog afterwards.
+func (r *InterceptorChain) recordCall(ctx context.Context,
+	log *auditlog.Log, fullMethod, request string, callErr error) {
+
+	record := &auditlog.Record{
+		Method:  fullMethod,
+		Request: request,
+	}
+	if callErr != nil {
+		record.Error = callErr.Error()
+	}
+
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		record.PeerAddr = p.Addr.String()
+	}
+
+	// The macaroon was already validated at this point, so its ID can be
+	// trusted.
+	mac, _, err := macaroonFromContext(ctx)
+	if err == nil && mac != nil {
+		record.MacaroonID = hex.EncodeToString(mac.Id())
+		record.RootKeyID, _ = rootKeyIDFromMacaroon(mac)
+	}
+
+	if _, err := log.Append(record); err != nil {
+		r.rpcsLog.Errorf("Unable to record call to %v in audit log: %v",
+			fullMethod, err)
+	}
+}
+
+// checkAuditLog returns an error if a previous state-changing call couldn't be
+// recorded in the audit log, so that no calls are made that go unrecorded.
+func checkAuditLog(log *auditlog.Log) error {
+	if err := log.Err(); err != nil {
+		return status.Errorf(codes.Unavailable, "audit log "+
+			"unavailable: %v", err)
+	}
+
+	return nil
+}
+
+// auditUnaryServerInterceptor is a unary gRPC interceptor that records the
+// state-changing calls in the audit log once they complete.
+func (r *InterceptorChain) auditUnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+
+		log := r.AuditLog()
+		if log == nil || !r.isStateChanging(info.FullMethod) {
+			return handler(ctx, req)
+		}
+
+		if err := checkAuditLog(log); err != nil {
+			return nil, err
+		}
+
+		resp, err := handler(ctx, req)
+		r.recordCall(
+			ctx, log, info.FullMethod, summarizeRequest(req), err,
+		)
+
+		return resp, err
+	}
+}
+
+// auditStreamServerInterceptor is a streaming gRPC interceptor that records
+// the state-changing streams in the audit log once they are closed. The first
+// message received from the client is recorded as the request of the stream.
+func (r *InterceptorChain) auditStreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream,
+		info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+
+		log := r.AuditLog()
+		if log == nil || !r.isStateChanging(info.FullMethod) {
+			return handler(srv, ss)
+		}
+
+		if err := checkAuditLog(log); err != nil {
+			return err
+		}
+
+		auditStream := &auditServerStream{
+			ServerStream: ss,
+		}
+		err := handler(srv, auditStream)
+		r.recordCall(
+			ss.Context(), log, info.FullMethod, auditStream.request,
+			err,
+		)
+
+		return err
+	}
+}
+
+// auditServerStream is a wrapper around a server stream that keeps the
+// summary of the first message received from the client.
+type auditServerStream struct {
+	grpc.ServerStream
+
+	// request is the summary of the first message received.
+	request string
+
+	// received is set once the first message has been received.
+	received bool
+}
+
+// RecvMsg receives a message from the client, summarizing it if it is the
+// first one.
+func (s *auditServerStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+
+	if !s.received {
+		s.request = summarizeRequest(m)
+		s.received = true
+	}
+
+	return nil
+}
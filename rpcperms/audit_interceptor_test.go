@@ -0,0 +1,245 @@
+package rpcperms
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/brsuite/broln/auditlog"
+	"github.com/brsuite/broln/clock"
+	"github.com/brsuite/broln/lnrpc"
+	"github.com/brsuite/broln/lnrpc/invoicesrpc"
+	"github.com/brsuite/broln/lnrpc/routerrpc"
+	"github.com/brsuite/broln/record"
+	"github.com/btcsuite/btclog"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/macaroon-bakery.v2/bakery"
+)
+
+var (
+	// testPreimage is the secret that must never be written to the audit
+	// log.
+	testPreimage = bytes.Repeat([]byte{0xab}, 32)
+
+	// testPassword is the wallet password that must never be written to
+	// the audit log.
+	testPassword = []byte("correct horse battery staple")
+)
+
+// requireNoSecrets asserts that none of the test secrets appear in the given
+// data, in any of the encodings they may be written in.
+func requireNoSecrets(t *testing.T, data string) {
+	t.Helper()
+
+	for _, secret := range [][]byte{testPreimage, testPassword} {
+		require.NotContains(t, data, hex.EncodeToString(secret))
+		require.NotContains(
+			t, data, base64.StdEncoding.EncodeToString(secret),
+		)
+		require.NotContains(
+			t, data, base64.URLEncoding.EncodeToString(secret),
+		)
+		require.NotContains(t, data, string(secret))
+	}
+}
+
+// TestSummarizeRequestRedaction asserts that the secrets of requests are left
+// out of their summaries, while the other fields are kept.
+func TestSummarizeRequestRedaction(t *testing.T) {
+	t.Parallel()
+
+	keysendRecords := map[uint64][]byte{
+		record.KeySendType: testPreimage,
+	}
+
+	testCases := []struct {
+		name     string
+		req      proto.Message
+		expected string
+	}{
+		{
+			name: "keysend payment",
+			req: &routerrpc.SendPaymentRequest{
+				Amt:               1000,
+				DestCustomRecords: keysendRecords,
+			},
+			expected: `"amt":"1000"`,
+		},
+		{
+			name: "legacy keysend payment",
+			req: &lnrpc.SendRequest{
+				Amt:               1000,
+				DestCustomRecords: keysendRecords,
+			},
+			expected: `"amt":"1000"`,
+		},
+		{
+			name: "invoice with preimage",
+			req: &lnrpc.Invoice{
+				Memo:      "coffee",
+				RPreimage: testPreimage,
+			},
+			expected: `"memo":"coffee"`,
+		},
+		{
+			name: "route with custom records",
+			req: &lnrpc.SendToRouteRequest{
+				Route: &lnrpc.Route{
+					TotalAmt: 1000,
+					Hops: []*lnrpc.Hop{{
+						ChanId:        1,
+						CustomRecords: keysendRecords,
+					}},
+				},
+			},
+			expected: `"chan_id":"1"`,
+		},
+		{
+			name: "settled invoice",
+			req: &invoicesrpc.SettleInvoiceMsg{
+				Preimage: testPreimage,
+			},
+			expected: `{}`,
+		},
+		{
+			name: "wallet init",
+			req: &lnrpc.InitWalletRequest{
+				WalletPassword:     testPassword,
+				CipherSeedMnemonic: []string{string(testPassword)},
+				AezeedPassphrase:   testPassword,
+				RecoveryWindow:     100,
+			},
+			expected: `"recovery_window":100`,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			orig := proto.Clone(testCase.req)
+
+			summary := summarizeRequest(testCase.req)
+			requireNoSecrets(t, summary)
+			require.Contains(t, summary, testCase.expected)
+
+			// The request itself must be left untouched, as it is
+			// still to be handled.
+			require.True(t, proto.Equal(orig, testCase.req))
+		})
+	}
+}
+
+// newTestAuditChain creates an interceptor chain that records calls to the
+// given method in an audit log in a temporary directory.
+func newTestAuditChain(t *testing.T, method string) (*InterceptorChain,
+	*auditlog.Log, string) {
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, auditlog.DefaultFileName)
+	log, err := auditlog.Open(
+		path, filepath.Join(dir, auditlog.DefaultKeyFileName),
+		clock.NewDefaultClock(),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = log.Close()
+	})
+
+	chain := &InterceptorChain{
+		permissionMap: map[string][]bakery.Op{
+			method: {{Entity: "offchain", Action: "write"}},
+		},
+		auditLog: log,
+		rpcsLog:  btclog.Disabled,
+	}
+
+	return chain, log, path
+}
+
+// callAuditInterceptor passes the given request through the unary audit
+// interceptor, and returns whether the handler was called along with the
+// error returned.
+func callAuditInterceptor(chain *InterceptorChain, method string,
+	req interface{}) (bool, error) {
+
+	var called bool
+	handler := func(context.Context, interface{}) (interface{}, error) {
+		called = true
+		return nil, nil
+	}
+
+	interceptor := chain.auditUnaryServerInterceptor()
+	_, err := interceptor(
+		context.Background(), req,
+		&grpc.UnaryServerInfo{FullMethod: method}, handler,
+	)
+
+	return called, err
+}
+
+// TestAuditInterceptorRedaction asserts that the preimages of payments aren't
+// written to the audit log.
+func TestAuditInterceptorRedaction(t *testing.T) {
+	t.Parallel()
+
+	chain, log, path := newTestAuditChain(t, testPaymentMethod)
+
+	called, err := callAuditInterceptor(
+		chain, testPaymentMethod, &routerrpc.SendPaymentRequest{
+			Amt: 1000,
+			DestCustomRecords: map[uint64][]byte{
+				record.KeySendType: testPreimage,
+			},
+		},
+	)
+	require.NoError(t, err)
+	require.True(t, called)
+
+	entries, err := log.Query(&auditlog.Query{})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, testPaymentMethod, entries[0].Method)
+
+	raw, err := os.ReadFile(path)
+	require.NoError(t, err)
+	requireNoSecrets(t, string(raw))
+}
+
+// TestAuditInterceptorFailClosed asserts that state-changing calls are rejected
+// once recording a call in the audit log failed.
+func TestAuditInterceptorFailClosed(t *testing.T) {
+	t.Parallel()
+
+	chain, log, _ := newTestAuditChain(t, testPaymentMethod)
+
+	// Closing the log makes recording the next call fail. The call itself
+	// was already made at that point.
+	require.NoError(t, log.Close())
+
+	req := &routerrpc.SendPaymentRequest{Amt: 1000}
+	called, err := callAuditInterceptor(chain, testPaymentMethod, req)
+	require.NoError(t, err)
+	require.True(t, called)
+
+	called, err = callAuditInterceptor(chain, testPaymentMethod, req)
+	require.Equal(t, codes.Unavailable, status.Code(err))
+	require.False(t, called)
+
+	// Read-only calls aren't recorded, so they can still be made.
+	called, err = callAuditInterceptor(
+		chain, "/lnrpc.Lightning/GetInfo", &lnrpc.GetInfoRequest{},
+	)
+	require.NoError(t, err)
+	require.True(t, called)
+}
@@ -7,8 +7,12 @@ import (
 	"sync"
 	"sync/atomic"
 
+	"github.com/brsuite/brond/chaincfg"
 	"github.com/btcsuite/btclog"
 	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
+	"github.com/brsuite/broln/accounts"
+	"github.com/brsuite/broln/auditlog"
+	"github.com/brsuite/broln/clock"
 	"github.com/brsuite/broln/lnrpc"
 	"github.com/brsuite/broln/macaroons"
 	"github.com/brsuite/broln/monitoring"
@@ -116,6 +120,14 @@ var (
 //      | RPC State Interceptor            |
 //      +----------------------------------+
 //      | Macaroon Interceptor             |
+//      +----------------------------------+
+//      | Audit Log Interceptor            |
+//      +----------------------------------+
+//      | Rate Limit Interceptor           |
+//      +----------------------------------+
+//      | Payment Caveat Interceptor       |
+//      +----------------------------------+
+//      | Account Interceptor              |
 //      +----------------------------------+--------> +---------------------+
 //      | RPC Macaroon Middleware Handler  |<-------- | External Middleware |
 //      +----------------------------------+          |   - approve request |
@@ -164,6 +176,26 @@ type InterceptorChain struct {
 	// permissionMap is the permissions to enforce if macaroons are used.
 	permissionMap map[string][]bakery.Op
 
+	// netParams is the chain the daemon runs on, which is needed to decode
+	// payment requests when enforcing payment caveats.
+	netParams *chaincfg.Params
+
+	// paymentSpends keeps track of the amounts spent with macaroons that
+	// carry a payment budget caveat.
+	paymentSpends *paymentSpendTracker
+
+	// accountService keeps track of the balances of the off-chain accounts
+	// macaroons can be bound to.
+	accountService *accounts.Service
+
+	// rateLimiter limits the calls made to each RPC method with the
+	// macaroons of a root key ID. It is nil if rate limiting is disabled.
+	rateLimiter *rateLimiter
+
+	// auditLog records the state-changing RPC calls. It is nil if the
+	// audit log is disabled.
+	auditLog *auditlog.Log
+
 	// rpcsLog is the logger used to log calls to the RPCs intercepted.
 	rpcsLog btclog.Logger
 
@@ -190,13 +222,18 @@ var _ lnrpc.StateServer = (*InterceptorChain)(nil)
 
 // NewInterceptorChain creates a new InterceptorChain.
 func NewInterceptorChain(log btclog.Logger, noMacaroons bool,
-	mandatoryMiddleware []string) *InterceptorChain {
+	mandatoryMiddleware []string,
+	netParams *chaincfg.Params) *InterceptorChain {
+
+	paymentSpends := newPaymentSpendTracker(clock.NewDefaultClock())
 
 	return &InterceptorChain{
 		state:                waitingToStart,
 		ntfnServer:           subscribe.NewServer(),
 		noMacaroons:          noMacaroons,
 		permissionMap:        make(map[string][]bakery.Op),
+		netParams:            netParams,
+		paymentSpends:        paymentSpends,
 		rpcsLog:              log,
 		registeredMiddleware: make(map[string]*MiddlewareHandler),
 		mandatoryMiddleware:  mandatoryMiddleware,
@@ -538,6 +575,43 @@ func (r *InterceptorChain) CreateServerOpts() []grpc.ServerOption {
 		strmInterceptors, r.MacaroonStreamServerInterceptor(),
 	)
 
+	// Once the macaroon of a request is validated, state-changing calls
+	// are recorded in the audit log, along with the result of all the
+	// checks that follow.
+	unaryInterceptors = append(
+		unaryInterceptors, r.auditUnaryServerInterceptor(),
+	)
+	strmInterceptors = append(
+		strmInterceptors, r.auditStreamServerInterceptor(),
+	)
+
+	// Next, we'll make sure the macaroon's root key ID hasn't exceeded the
+	// rate limits of the called method.
+	unaryInterceptors = append(
+		unaryInterceptors, r.rateLimitUnaryServerInterceptor(),
+	)
+	strmInterceptors = append(
+		strmInterceptors, r.rateLimitStreamServerInterceptor(),
+	)
+
+	// Next, we'll make sure the request satisfies the payment caveats
+	// the macaroon may carry.
+	unaryInterceptors = append(
+		unaryInterceptors, r.paymentUnaryServerInterceptor(),
+	)
+	strmInterceptors = append(
+		strmInterceptors, r.paymentStreamServerInterceptor(),
+	)
+
+	// Requests made with macaroons bound to an off-chain account are then
+	// checked against, and accounted towards, the balance of the account.
+	unaryInterceptors = append(
+		unaryInterceptors, r.accountUnaryServerInterceptor(),
+	)
+	strmInterceptors = append(
+		strmInterceptors, r.accountStreamServerInterceptor(),
+	)
+
 	// Next, we'll add the interceptors for our custom macaroon caveat based
 	// middleware.
 	unaryInterceptors = append(
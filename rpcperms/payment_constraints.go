@@ -0,0 +1,677 @@
+package rpcperms
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brsuite/brond/btcec"
+	"github.com/brsuite/brond/chaincfg"
+	"github.com/brsuite/bronutil"
+	"github.com/brsuite/broln/clock"
+	"github.com/brsuite/broln/kvdb"
+	"github.com/brsuite/broln/lnrpc"
+	"github.com/brsuite/broln/lnrpc/routerrpc"
+	"github.com/brsuite/broln/lnwire"
+	"github.com/brsuite/broln/macaroons"
+	"github.com/brsuite/broln/routing"
+	"github.com/brsuite/broln/zpay32"
+	"google.golang.org/grpc"
+	macaroon "gopkg.in/macaroon.v2"
+)
+
+var (
+	// paymentConstrainedMethods is the set of methods whose requests are
+	// checked against the payment caveats of a macaroon.
+	paymentConstrainedMethods = map[string]struct{}{
+		"/routerrpc.Router/SendPaymentV2":  {},
+		"/lnrpc.Lightning/SendCoins":       {},
+		"/lnrpc.Lightning/OpenChannel":     {},
+		"/lnrpc.Lightning/OpenChannelSync": {},
+		"/lnrpc.Lightning/CloseChannel":    {},
+	}
+
+	// paymentRestrictedMethods is the set of methods that are able to
+	// spend funds, but whose requests can't be checked against the payment
+	// caveats of a macaroon. Macaroons carrying any payment caveat aren't
+	// allowed to call them at all.
+	paymentRestrictedMethods = map[string]struct{}{
+		"/lnrpc.Lightning/SendMany":               {},
+		"/lnrpc.Lightning/BatchOpenChannel":       {},
+		"/lnrpc.Lightning/FundingStateStep":       {},
+		"/lnrpc.Lightning/SendPayment":            {},
+		"/lnrpc.Lightning/SendPaymentSync":        {},
+		"/lnrpc.Lightning/SendToRoute":            {},
+		"/lnrpc.Lightning/SendToRouteSync":        {},
+		"/lnrpc.Lightning/SpliceChannel":          {},
+		"/routerrpc.Router/SendPayment":           {},
+		"/routerrpc.Router/SendToRoute":           {},
+		"/routerrpc.Router/SendToRouteV2":         {},
+		"/routerrpc.Router/PayOffer":              {},
+		"/walletrpc.WalletKit/SendOutputs":        {},
+		"/walletrpc.WalletKit/PublishTransaction": {},
+		"/walletrpc.WalletKit/FundPsbt":           {},
+		"/walletrpc.WalletKit/SignPsbt":           {},
+		"/walletrpc.WalletKit/FinalizePsbt":       {},
+		"/walletrpc.WalletKit/BumpFee":            {},
+	}
+)
+
+// paymentDetails describes the funds a request to one of the payment
+// constrained methods attempts to spend.
+type paymentDetails struct {
+	// amt is the amount that is sent to the destination. It is nil if the
+	// amount can't be determined before the request is executed.
+	amt *bronutil.Amount
+
+	// maxFee is the maximum fee that may be paid on top of amt.
+	maxFee bronutil.Amount
+
+	// dest is the node that is paid. It is nil for on-chain sends.
+	dest *[33]byte
+
+	// memo is the memo of the paid invoice. It is nil if the payment isn't
+	// made to an invoice.
+	memo *string
+}
+
+// msatToSat converts the given amount in milli-satoshis to satoshis, rounding
+// up so no fraction of a satoshi escapes the payment caveats.
+func msatToSat(amt lnwire.MilliSatoshi) bronutil.Amount {
+	return bronutil.Amount((amt + 999) / 1000)
+}
+
+// parseDest parses the serialized public key of a destination node.
+func parseDest(pubKeyBytes []byte) (*[33]byte, error) {
+	pubKey, err := btcec.ParsePubKey(pubKeyBytes, btcec.S256())
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse destination: %v", err)
+	}
+
+	var dest [33]byte
+	copy(dest[:], pubKey.SerializeCompressed())
+
+	return &dest, nil
+}
+
+// paymentFeeLimit returns the maximum fee that may be paid for a payment of the
+// given amount, taking both the absolute and the proportional fee limit of the
+// request into account.
+func paymentFeeLimit(r *routerrpc.SendPaymentRequest,
+	amt lnwire.MilliSatoshi) (lnwire.MilliSatoshi, error) {
+
+	if r.FeeLimitPpm > routing.MaxFeeLimitPPM {
+		return 0, fmt.Errorf("fee_limit_ppm must not exceed %v",
+			routing.MaxFeeLimitPPM)
+	}
+
+	feeLimit, err := lnrpc.UnmarshallAmt(r.FeeLimitSat, r.FeeLimitMsat)
+	if err != nil {
+		return 0, err
+	}
+
+	return routing.TotalFeeLimit(amt, feeLimit, r.FeeLimitPpm), nil
+}
+
+// parsePaymentDetails extracts the payment details from a request to one of
+// the payment constrained methods.
+func parsePaymentDetails(req interface{},
+	netParams *chaincfg.Params) (*paymentDetails, error) {
+
+	details := &paymentDetails{}
+
+	switch r := req.(type) {
+	case *routerrpc.SendPaymentRequest:
+		amtMsat, err := lnrpc.UnmarshallAmt(r.Amt, r.AmtMsat)
+		if err != nil {
+			return nil, err
+		}
+		destBytes := r.Dest
+
+		if r.PaymentRequest != "" {
+			invoice, err := zpay32.Decode(
+				r.PaymentRequest, netParams,
+			)
+			if err != nil {
+				return nil, err
+			}
+
+			if invoice.MilliSat != nil {
+				amtMsat = *invoice.MilliSat
+			}
+			destBytes = invoice.Destination.SerializeCompressed()
+
+			var memo string
+			if invoice.Description != nil {
+				memo = *invoice.Description
+			}
+			details.memo = &memo
+		}
+
+		dest, err := parseDest(destBytes)
+		if err != nil {
+			return nil, err
+		}
+		amt := msatToSat(amtMsat)
+		details.amt = &amt
+		details.dest = dest
+
+		maxFee, err := paymentFeeLimit(r, amtMsat)
+		if err != nil {
+			return nil, err
+		}
+		details.maxFee = msatToSat(maxFee)
+
+	case *lnrpc.SendCoinsRequest:
+		// The amount of a request sweeping all funds is only known
+		// once it's executed.
+		if !r.SendAll {
+			amt := bronutil.Amount(r.Amount)
+			details.amt = &amt
+		}
+
+	case *lnrpc.OpenChannelRequest:
+		destBytes := r.NodePubkey
+		if r.NodePubkeyString != "" { // nolint:staticcheck
+			var err error
+			destBytes, err = hex.DecodeString(
+				r.NodePubkeyString, // nolint:staticcheck
+			)
+			if err != nil {
+				return nil, fmt.Errorf("unable to decode "+
+					"destination: %v", err)
+			}
+		}
+
+		dest, err := parseDest(destBytes)
+		if err != nil {
+			return nil, err
+		}
+		amt := bronutil.Amount(r.LocalFundingAmount)
+		details.amt = &amt
+		details.dest = dest
+
+	default:
+		return nil, fmt.Errorf("unable to check payment caveats of "+
+			"request type %T", req)
+	}
+
+	return details, nil
+}
+
+var (
+	// paymentSpendsBucketName is the name of the top level bucket that
+	// holds the amounts spent with macaroons carrying a payment budget
+	// caveat. It contains a nested bucket for each budget, keyed by the
+	// ID of the macaroon and the budget amount.
+	paymentSpendsBucketName = []byte("macaroon-payment-spends")
+
+	// errNoPaymentSpendDB is returned when a macaroon with a payment budget
+	// caveat is used before the database the spends are persisted in has
+	// been added.
+	errNoPaymentSpendDB = errors.New("payment budgets can't be enforced " +
+		"yet")
+
+	// byteOrder is the byte order payment spends are serialized with.
+	byteOrder = binary.BigEndian
+)
+
+// paymentSpend is an amount spent with a macaroon at a given time.
+type paymentSpend struct {
+	timestamp time.Time
+	amt       bronutil.Amount
+}
+
+// paymentSpendTracker keeps track of the amounts spent with macaroons that
+// carry a payment budget caveat. Amounts are accounted once a request is
+// accepted, regardless of whether it succeeds, erring on the side of caution.
+// The spends are persisted, so budgets aren't reset when the daemon restarts.
+type paymentSpendTracker struct {
+	clock clock.Clock
+
+	// db is the database the spends are persisted in. Until it is set, no
+	// amount can be spent.
+	db kvdb.Backend
+
+	// spends holds the amounts spent within the last PaymentBudgetPeriod,
+	// keyed by the ID of the macaroon they were spent with and the budget
+	// they count towards.
+	spends map[string][]paymentSpend
+
+	sync.Mutex
+}
+
+// newPaymentSpendTracker creates a new paymentSpendTracker.
+func newPaymentSpendTracker(clock clock.Clock) *paymentSpendTracker {
+	return &paymentSpendTracker{
+		clock:  clock,
+		spends: make(map[string][]paymentSpend),
+	}
+}
+
+// spendKey returns the key the spends of the given macaroon towards the given
+// budget are stored under.
+func spendKey(macID []byte, budget bronutil.Amount) string {
+	return fmt.Sprintf("%x:%d", macID, budget)
+}
+
+// serializeSpendKey returns the database key of a spend made at the given
+// time. The sequence number makes sure spends made at the same time don't
+// overwrite each other.
+func serializeSpendKey(timestamp time.Time, seq uint64) []byte {
+	var key [16]byte
+	byteOrder.PutUint64(key[:8], uint64(timestamp.UnixNano()))
+	byteOrder.PutUint64(key[8:], seq)
+
+	return key[:]
+}
+
+// setDB loads the spends that are still within the budget period from the
+// given database, and persists all future spends in it. Spends that have left
+// the budget period are removed from the database.
+func (t *paymentSpendTracker) setDB(db kvdb.Backend) error {
+	t.Lock()
+	defer t.Unlock()
+
+	cutoff := t.clock.Now().Add(-macaroons.PaymentBudgetPeriod)
+
+	spends := make(map[string][]paymentSpend)
+	err := kvdb.Update(db, func(tx kvdb.RwTx) error {
+		spendsBucket, err := tx.CreateTopLevelBucket(
+			paymentSpendsBucketName,
+		)
+		if err != nil {
+			return err
+		}
+
+		var keys [][]byte
+		err = spendsBucket.ForEach(func(k, _ []byte) error {
+			keys = append(keys, k)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, key := range keys {
+			bucket := spendsBucket.NestedReadWriteBucket(key)
+			if bucket == nil {
+				return fmt.Errorf("invalid payment spend "+
+					"bucket %s", key)
+			}
+
+			recent, err := pruneSpends(bucket, cutoff)
+			if err != nil {
+				return err
+			}
+
+			// Forget about budgets that haven't been spent from
+			// within the budget period altogether.
+			if len(recent) == 0 {
+				err := spendsBucket.DeleteNestedBucket(key)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+
+			spends[string(key)] = recent
+		}
+
+		return nil
+	}, func() {
+		spends = make(map[string][]paymentSpend)
+	})
+	if err != nil {
+		return err
+	}
+
+	t.db = db
+	t.spends = spends
+
+	return nil
+}
+
+// pruneSpends removes the spends made before the cutoff from the given bucket
+// and returns the remaining ones, ordered by time.
+func pruneSpends(bucket kvdb.RwBucket,
+	cutoff time.Time) ([]paymentSpend, error) {
+
+	var (
+		expired [][]byte
+		recent  []paymentSpend
+	)
+	err := bucket.ForEach(func(k, v []byte) error {
+		if len(k) != 16 || len(v) != 8 {
+			return fmt.Errorf("invalid payment spend %x", k)
+		}
+
+		timestamp := time.Unix(0, int64(byteOrder.Uint64(k[:8])))
+		if timestamp.Before(cutoff) {
+			expired = append(expired, k)
+			return nil
+		}
+
+		recent = append(recent, paymentSpend{
+			timestamp: timestamp,
+			amt:       bronutil.Amount(byteOrder.Uint64(v)),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, k := range expired {
+		if err := bucket.Delete(k); err != nil {
+			return nil, err
+		}
+	}
+
+	return recent, nil
+}
+
+// spend accounts the given amount towards all budgets of the macaroon with the
+// given ID. An error is returned, and nothing is accounted, if the amount
+// exceeds any of the budgets or can't be persisted.
+func (t *paymentSpendTracker) spend(macID []byte, budgets []bronutil.Amount,
+	amt bronutil.Amount) error {
+
+	t.Lock()
+	defer t.Unlock()
+
+	if t.db == nil {
+		return errNoPaymentSpendDB
+	}
+
+	now := t.clock.Now()
+	cutoff := now.Add(-macaroons.PaymentBudgetPeriod)
+
+	keys := make([]string, 0, len(budgets))
+	for _, budget := range budgets {
+		key := spendKey(macID, budget)
+		keys = append(keys, key)
+
+		var spent bronutil.Amount
+		for _, s := range t.spends[key] {
+			if s.timestamp.Before(cutoff) {
+				continue
+			}
+			spent += s.amt
+		}
+
+		if spent+amt > budget {
+			return fmt.Errorf("payment of %v exceeds remaining "+
+				"macaroon budget of %v", amt, budget-spent)
+		}
+	}
+
+	// Persist the spend before accounting it in memory, so the budgets
+	// can't be exceeded by restarting the daemon. Spends that have left the
+	// budget period are removed along the way.
+	recentSpends := make(map[string][]paymentSpend, len(keys))
+	err := kvdb.Update(t.db, func(tx kvdb.RwTx) error {
+		spendsBucket := tx.ReadWriteBucket(paymentSpendsBucketName)
+		if spendsBucket == nil {
+			return fmt.Errorf("payment spends bucket not found")
+		}
+
+		for _, key := range keys {
+			bucket, err := spendsBucket.CreateBucketIfNotExists(
+				[]byte(key),
+			)
+			if err != nil {
+				return err
+			}
+
+			recent, err := pruneSpends(bucket, cutoff)
+			if err != nil {
+				return err
+			}
+
+			seq, err := bucket.NextSequence()
+			if err != nil {
+				return err
+			}
+
+			var amtBytes [8]byte
+			byteOrder.PutUint64(amtBytes[:], uint64(amt))
+			err = bucket.Put(
+				serializeSpendKey(now, seq), amtBytes[:],
+			)
+			if err != nil {
+				return err
+			}
+
+			recentSpends[key] = append(recent, paymentSpend{
+				timestamp: now,
+				amt:       amt,
+			})
+		}
+
+		return nil
+	}, func() {
+		recentSpends = make(map[string][]paymentSpend, len(keys))
+	})
+	if err != nil {
+		return fmt.Errorf("unable to persist payment spend: %v", err)
+	}
+
+	for key, recent := range recentSpends {
+		t.spends[key] = recent
+	}
+
+	return nil
+}
+
+// AddPaymentSpendDB adds the database the amounts spent with macaroons that
+// carry a payment budget caveat are persisted in to the interceptor, loading
+// the spends that still count towards the budgets. Until it is added, requests
+// made with such macaroons are rejected.
+func (r *InterceptorChain) AddPaymentSpendDB(db kvdb.Backend) error {
+	return r.paymentSpends.setDB(db)
+}
+
+// paymentConstraints returns the payment caveats of the macaroon of the given
+// request context that need to be checked against each request to the given
+// method. Nil is returned if there are no caveats to check. An error is
+// returned if the macaroon carries payment caveats, but the method isn't
+// allowed to be called with it.
+func (r *InterceptorChain) paymentConstraints(ctx context.Context,
+	fullMethod string) (*macaroon.Macaroon, *macaroons.PaymentConstraints,
+	error) {
+
+	// Without macaroons, there are no caveats to enforce.
+	if r.noMacaroons {
+		return nil, nil, nil
+	}
+
+	_, constrained := paymentConstrainedMethods[fullMethod]
+	_, restricted := paymentRestrictedMethods[fullMethod]
+	if !constrained && !restricted {
+		return nil, nil, nil
+	}
+
+	mac, _, err := macaroonFromContext(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	constraints, err := macaroons.GetPaymentConstraints(mac)
+	if err != nil {
+		return nil, nil, err
+	}
+	if constraints.Empty() {
+		return nil, nil, nil
+	}
+
+	if restricted {
+		return nil, nil, fmt.Errorf("%s: method not allowed with "+
+			"payment restricted macaroon", fullMethod)
+	}
+
+	return mac, constraints, nil
+}
+
+// checkPaymentConstraints checks that the given request satisfies all the
+// payment caveats of the macaroon it was sent with, and accounts its amount
+// towards the macaroon's budgets.
+func (r *InterceptorChain) checkPaymentConstraints(mac *macaroon.Macaroon,
+	constraints *macaroons.PaymentConstraints, req interface{}) error {
+
+	// Closing a channel only spends funds if they are paid out to a
+	// delivery address outside of our wallet. As the amount that is paid
+	// out isn't known upfront, we can't check such requests against the
+	// caveats.
+	if closeReq, ok := req.(*lnrpc.CloseChannelRequest); ok {
+		if closeReq.DeliveryAddress != "" {
+			return fmt.Errorf("cannot close channel to delivery " +
+				"address with payment restricted macaroon")
+		}
+
+		return nil
+	}
+
+	details, err := parsePaymentDetails(req, r.netParams)
+	if err != nil {
+		return err
+	}
+
+	// If the amount can't be determined upfront, we can't make sure the
+	// request respects any amount restriction.
+	if details.amt == nil {
+		if len(constraints.MaxAmts) != 0 ||
+			len(constraints.Budgets) != 0 {
+
+			return fmt.Errorf("cannot spend unknown amount with " +
+				"payment restricted macaroon")
+		}
+	}
+
+	for _, maxAmt := range constraints.MaxAmts {
+		if *details.amt > maxAmt {
+			return fmt.Errorf("payment amount of %v exceeds "+
+				"macaroon limit of %v", *details.amt, maxAmt)
+		}
+	}
+
+	for _, dests := range constraints.Dests {
+		if details.dest == nil {
+			return fmt.Errorf("macaroon only allows payments to " +
+				"specific destinations")
+		}
+
+		if _, ok := dests[*details.dest]; !ok {
+			return fmt.Errorf("destination %x not allowed by "+
+				"macaroon", details.dest[:])
+		}
+	}
+
+	for _, prefix := range constraints.MemoPrefixes {
+		if details.memo == nil {
+			return fmt.Errorf("macaroon only allows payments to " +
+				"invoices")
+		}
+
+		if !strings.HasPrefix(*details.memo, prefix) {
+			return fmt.Errorf("invoice memo doesn't start with "+
+				"prefix %q required by macaroon", prefix)
+		}
+	}
+
+	// Finally, we'll account the amount, including the maximum fee, towards
+	// the budgets of the macaroon, now that we know the request satisfies
+	// all other caveats.
+	if len(constraints.Budgets) == 0 {
+		return nil
+	}
+
+	return r.paymentSpends.spend(
+		mac.Id(), constraints.Budgets, *details.amt+details.maxFee,
+	)
+}
+
+// paymentUnaryServerInterceptor is a unary gRPC interceptor that enforces the
+// payment caveats of the macaroon a request was sent with.
+func (r *InterceptorChain) paymentUnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+
+		mac, constraints, err := r.paymentConstraints(
+			ctx, info.FullMethod,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if constraints != nil {
+			err := r.checkPaymentConstraints(mac, constraints, req)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// paymentStreamServerInterceptor is a streaming gRPC interceptor that enforces
+// the payment caveats of the macaroon a stream was opened with on each request
+// received on the stream.
+func (r *InterceptorChain) paymentStreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream,
+		info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+
+		mac, constraints, err := r.paymentConstraints(
+			ss.Context(), info.FullMethod,
+		)
+		if err != nil {
+			return err
+		}
+
+		if constraints == nil {
+			return handler(srv, ss)
+		}
+
+		wrappedSS := &paymentStreamWrapper{
+			ServerStream: ss,
+			mac:          mac,
+			constraints:  constraints,
+			interceptor:  r,
+		}
+
+		return handler(srv, wrappedSS)
+	}
+}
+
+// paymentStreamWrapper is a struct that wraps a server stream in a way that
+// each request received is checked against the payment caveats of the
+// macaroon the stream was opened with.
+type paymentStreamWrapper struct {
+	// ServerStream is the stream that's being wrapped.
+	grpc.ServerStream
+
+	mac *macaroon.Macaroon
+
+	constraints *macaroons.PaymentConstraints
+
+	interceptor *InterceptorChain
+}
+
+// RecvMsg is called when broln wants to receive a message from the client.
+// This is wrapped to check the payment caveats of streaming RPC requests.
+func (w *paymentStreamWrapper) RecvMsg(m interface{}) error {
+	if err := w.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+
+	return w.interceptor.checkPaymentConstraints(
+		w.mac, w.constraints, m,
+	)
+}
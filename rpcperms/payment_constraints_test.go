@@ -0,0 +1,461 @@
+package rpcperms
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/brsuite/brond/btcec"
+	"github.com/brsuite/brond/chaincfg"
+	"github.com/brsuite/brond/chaincfg/chainhash"
+	"github.com/brsuite/broln/channeldb"
+	"github.com/brsuite/broln/clock"
+	"github.com/brsuite/broln/kvdb"
+	"github.com/brsuite/broln/lnrpc"
+	"github.com/brsuite/broln/lnrpc/routerrpc"
+	"github.com/brsuite/broln/lnwire"
+	"github.com/brsuite/broln/macaroons"
+	"github.com/brsuite/broln/zpay32"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	macaroon "gopkg.in/macaroon.v2"
+)
+
+const (
+	// testPaymentMethod is the payment constrained method the interceptor
+	// tests call.
+	testPaymentMethod = "/routerrpc.Router/SendPaymentV2"
+
+	// testOtherDest is a destination that isn't allowed by the test
+	// macaroons restricting the destinations.
+	testOtherDest = "0235f2dbfaa89b57ec7b055afe29849ef7ddfeb1cefdb9ebdc43" +
+		"f5494984db29e5"
+)
+
+const testDest = "0286098b97bc843372b4426d4b276cea9aa2f48f0428d6f5b66ae1" +
+	"01befc14f8b4"
+
+// testDestBytes returns the serialized public key of the test destination.
+func testDestBytes(t *testing.T) []byte {
+	dest, err := hex.DecodeString(testDest)
+	require.NoError(t, err)
+
+	return dest
+}
+
+// TestParsePaymentDetailsFeeLimit asserts that the maximum fee of a payment is
+// the larger of its absolute and proportional fee limit.
+func TestParsePaymentDetailsFeeLimit(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name        string
+		req         *routerrpc.SendPaymentRequest
+		expectedFee int64
+		expectErr   bool
+	}{
+		{
+			name: "absolute limit",
+			req: &routerrpc.SendPaymentRequest{
+				Amt:         100_000,
+				FeeLimitSat: 50,
+			},
+			expectedFee: 50,
+		},
+		{
+			name: "proportional limit",
+			req: &routerrpc.SendPaymentRequest{
+				Amt:         100_000,
+				FeeLimitPpm: 10_000,
+			},
+			expectedFee: 1_000,
+		},
+		{
+			name: "larger proportional limit",
+			req: &routerrpc.SendPaymentRequest{
+				Amt:         100_000,
+				FeeLimitSat: 50,
+				FeeLimitPpm: 10_000,
+			},
+			expectedFee: 1_000,
+		},
+		{
+			name: "larger absolute limit",
+			req: &routerrpc.SendPaymentRequest{
+				AmtMsat:      100_000_000,
+				FeeLimitMsat: 2_000_000,
+				FeeLimitPpm:  10_000,
+			},
+			expectedFee: 2_000,
+		},
+		{
+			name: "proportional limit above maximum",
+			req: &routerrpc.SendPaymentRequest{
+				Amt:         100_000,
+				FeeLimitPpm: 1_000_001,
+			},
+			expectErr: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			testCase.req.Dest = testDestBytes(t)
+
+			details, err := parsePaymentDetails(
+				testCase.req, &chaincfg.RegressionNetParams,
+			)
+			if testCase.expectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.EqualValues(
+				t, testCase.expectedFee, details.maxFee,
+			)
+		})
+	}
+}
+
+// newTestPaymentChain creates an interceptor chain that enforces payment
+// caveats and persists the spends in the given database.
+func newTestPaymentChain(t *testing.T, db kvdb.Backend,
+	testClock clock.Clock) *InterceptorChain {
+
+	spends := newPaymentSpendTracker(testClock)
+	require.NoError(t, spends.setDB(db))
+
+	return &InterceptorChain{
+		netParams:     &chaincfg.RegressionNetParams,
+		paymentSpends: spends,
+	}
+}
+
+// newTestPaymentDB creates a temporary database to persist payment spends in.
+func newTestPaymentDB(t *testing.T) kvdb.Backend {
+	db, cleanUp, err := channeldb.MakeTestDB()
+	require.NoError(t, err)
+	t.Cleanup(cleanUp)
+
+	return db
+}
+
+// macaroonContext returns a request context carrying a macaroon with the given
+// payment constraints.
+func macaroonContext(t *testing.T,
+	constraints ...macaroons.Constraint) context.Context {
+
+	mac, err := macaroon.New(
+		[]byte("root-key"), []byte("macaroon-id"), "broln",
+		macaroon.LatestVersion,
+	)
+	require.NoError(t, err)
+
+	mac, err = macaroons.AddConstraints(mac, constraints...)
+	require.NoError(t, err)
+
+	macBytes, err := mac.MarshalBinary()
+	require.NoError(t, err)
+
+	md := metadata.Pairs("macaroon", hex.EncodeToString(macBytes))
+	return metadata.NewIncomingContext(context.Background(), md)
+}
+
+// testPayment returns a keysend payment of the given amount to the given
+// destination.
+func testPayment(t *testing.T, amt int64,
+	dest string) *routerrpc.SendPaymentRequest {
+
+	destBytes, err := hex.DecodeString(dest)
+	require.NoError(t, err)
+
+	return &routerrpc.SendPaymentRequest{
+		Amt:  amt,
+		Dest: destBytes,
+	}
+}
+
+// testInvoicePayment returns a payment to an invoice of the given amount with
+// the given memo.
+func testInvoicePayment(t *testing.T, amt lnwire.MilliSatoshi,
+	memo string) *routerrpc.SendPaymentRequest {
+
+	privKey, err := btcec.NewPrivateKey(btcec.S256())
+	require.NoError(t, err)
+
+	invoice, err := zpay32.NewInvoice(
+		&chaincfg.RegressionNetParams, [32]byte{1}, testTime,
+		zpay32.Amount(amt), zpay32.Description(memo),
+	)
+	require.NoError(t, err)
+
+	payReq, err := invoice.Encode(zpay32.MessageSigner{
+		SignCompact: func(msg []byte) ([]byte, error) {
+			return btcec.SignCompact(
+				btcec.S256(), privKey, chainhash.HashB(msg),
+				true,
+			)
+		},
+	})
+	require.NoError(t, err)
+
+	return &routerrpc.SendPaymentRequest{
+		PaymentRequest: payReq,
+	}
+}
+
+// callPaymentInterceptor passes the given request through the unary payment
+// interceptor, and returns whether the handler was called along with the
+// error returned.
+func callPaymentInterceptor(chain *InterceptorChain, ctx context.Context,
+	method string, req interface{}) (bool, error) {
+
+	var called bool
+	handler := func(context.Context, interface{}) (interface{}, error) {
+		called = true
+		return nil, nil
+	}
+
+	interceptor := chain.paymentUnaryServerInterceptor()
+	_, err := interceptor(
+		ctx, req, &grpc.UnaryServerInfo{FullMethod: method}, handler,
+	)
+
+	return called, err
+}
+
+// TestPaymentInterceptorMaxAmt asserts that payments exceeding the maximum
+// amount of a macaroon are rejected.
+func TestPaymentInterceptorMaxAmt(t *testing.T) {
+	t.Parallel()
+
+	chain := newTestPaymentChain(
+		t, newTestPaymentDB(t), clock.NewTestClock(testTime),
+	)
+	ctx := macaroonContext(t, macaroons.PaymentMaxAmtConstraint(1_000))
+
+	called, err := callPaymentInterceptor(
+		chain, ctx, testPaymentMethod, testPayment(t, 1_000, testDest),
+	)
+	require.NoError(t, err)
+	require.True(t, called)
+
+	called, err = callPaymentInterceptor(
+		chain, ctx, testPaymentMethod, testPayment(t, 1_001, testDest),
+	)
+	require.ErrorContains(t, err, "exceeds macaroon limit")
+	require.False(t, called)
+
+	// Sweeping all on-chain funds can't be checked against the maximum
+	// amount upfront, and methods that can't be checked at all are off
+	// limits.
+	called, err = callPaymentInterceptor(
+		chain, ctx, "/lnrpc.Lightning/SendCoins",
+		&lnrpc.SendCoinsRequest{SendAll: true},
+	)
+	require.ErrorContains(t, err, "unknown amount")
+	require.False(t, called)
+
+	called, err = callPaymentInterceptor(
+		chain, ctx, "/lnrpc.Lightning/SendPaymentSync",
+		&lnrpc.SendRequest{},
+	)
+	require.ErrorContains(t, err, "method not allowed")
+	require.False(t, called)
+}
+
+// TestPaymentInterceptorBudget asserts that the payments made with a macaroon
+// are accounted towards its budget, including the maximum fee, that the budget
+// is replenished after the budget period and that the spends survive a
+// restart.
+func TestPaymentInterceptorBudget(t *testing.T) {
+	t.Parallel()
+
+	db := newTestPaymentDB(t)
+	testClock := clock.NewTestClock(testTime)
+	chain := newTestPaymentChain(t, db, testClock)
+	ctx := macaroonContext(t, macaroons.PaymentBudgetConstraint(1_000))
+
+	called, err := callPaymentInterceptor(
+		chain, ctx, testPaymentMethod, testPayment(t, 400, testDest),
+	)
+	require.NoError(t, err)
+	require.True(t, called)
+
+	testClock.SetTime(testTime.Add(time.Hour))
+	req := testPayment(t, 400, testDest)
+	req.FeeLimitSat = 100
+	called, err = callPaymentInterceptor(
+		chain, ctx, testPaymentMethod, req,
+	)
+	require.NoError(t, err)
+	require.True(t, called)
+
+	// Only 100 satoshis are left, so a payment of 101 satoshis exceeds
+	// the budget.
+	called, err = callPaymentInterceptor(
+		chain, ctx, testPaymentMethod, testPayment(t, 101, testDest),
+	)
+	require.ErrorContains(t, err, "exceeds remaining macaroon budget")
+	require.False(t, called)
+
+	// The spends are still accounted after a restart.
+	chain = newTestPaymentChain(t, db, testClock)
+	called, err = callPaymentInterceptor(
+		chain, ctx, testPaymentMethod, testPayment(t, 101, testDest),
+	)
+	require.ErrorContains(t, err, "exceeds remaining macaroon budget")
+	require.False(t, called)
+
+	// Once the first payment leaves the budget period, its amount can be
+	// spent again.
+	testClock.SetTime(
+		testTime.Add(macaroons.PaymentBudgetPeriod + time.Minute),
+	)
+	chain = newTestPaymentChain(t, db, testClock)
+	called, err = callPaymentInterceptor(
+		chain, ctx, testPaymentMethod, testPayment(t, 500, testDest),
+	)
+	require.NoError(t, err)
+	require.True(t, called)
+
+	called, err = callPaymentInterceptor(
+		chain, ctx, testPaymentMethod, testPayment(t, 1, testDest),
+	)
+	require.ErrorContains(t, err, "exceeds remaining macaroon budget")
+	require.False(t, called)
+
+	// Without the database the spends are persisted in, budgets can't be
+	// enforced and all payments are rejected.
+	chain.paymentSpends = newPaymentSpendTracker(testClock)
+	called, err = callPaymentInterceptor(
+		chain, ctx, testPaymentMethod, testPayment(t, 1, testDest),
+	)
+	require.ErrorIs(t, err, errNoPaymentSpendDB)
+	require.False(t, called)
+}
+
+// TestPaymentInterceptorDest asserts that payments to destinations not allowed
+// by a macaroon are rejected.
+func TestPaymentInterceptorDest(t *testing.T) {
+	t.Parallel()
+
+	chain := newTestPaymentChain(
+		t, newTestPaymentDB(t), clock.NewTestClock(testTime),
+	)
+	ctx := macaroonContext(t, macaroons.PaymentDestConstraint(testDest))
+
+	called, err := callPaymentInterceptor(
+		chain, ctx, testPaymentMethod, testPayment(t, 1_000, testDest),
+	)
+	require.NoError(t, err)
+	require.True(t, called)
+
+	called, err = callPaymentInterceptor(
+		chain, ctx, testPaymentMethod,
+		testPayment(t, 1_000, testOtherDest),
+	)
+	require.ErrorContains(t, err, "not allowed by macaroon")
+	require.False(t, called)
+
+	// On-chain sends have no destination node, so they can't be made.
+	called, err = callPaymentInterceptor(
+		chain, ctx, "/lnrpc.Lightning/SendCoins",
+		&lnrpc.SendCoinsRequest{Amount: 1_000},
+	)
+	require.ErrorContains(t, err, "specific destinations")
+	require.False(t, called)
+}
+
+// TestPaymentInterceptorMemoPrefix asserts that only invoices whose memo starts
+// with the prefix required by a macaroon can be paid.
+func TestPaymentInterceptorMemoPrefix(t *testing.T) {
+	t.Parallel()
+
+	chain := newTestPaymentChain(
+		t, newTestPaymentDB(t), clock.NewTestClock(testTime),
+	)
+	ctx := macaroonContext(
+		t, macaroons.PaymentMemoPrefixConstraint("tenant:"),
+	)
+
+	called, err := callPaymentInterceptor(
+		chain, ctx, testPaymentMethod,
+		testInvoicePayment(t, 1_000_000, "tenant: coffee"),
+	)
+	require.NoError(t, err)
+	require.True(t, called)
+
+	called, err = callPaymentInterceptor(
+		chain, ctx, testPaymentMethod,
+		testInvoicePayment(t, 1_000_000, "coffee"),
+	)
+	require.ErrorContains(t, err, "doesn't start with prefix")
+	require.False(t, called)
+
+	// Keysend payments aren't made to an invoice, so they have no memo.
+	called, err = callPaymentInterceptor(
+		chain, ctx, testPaymentMethod, testPayment(t, 1_000, testDest),
+	)
+	require.ErrorContains(t, err, "only allows payments to invoices")
+	require.False(t, called)
+}
+
+// TestPaymentInterceptorChannelMethods asserts that payment restricted
+// macaroons can't be used to move channel funds outside of the wallet.
+func TestPaymentInterceptorChannelMethods(t *testing.T) {
+	t.Parallel()
+
+	chain := newTestPaymentChain(
+		t, newTestPaymentDB(t), clock.NewTestClock(testTime),
+	)
+	ctx := macaroonContext(t, macaroons.PaymentMaxAmtConstraint(1_000))
+
+	// Cooperatively closing a channel to our own wallet doesn't spend any
+	// funds.
+	called, err := callPaymentInterceptor(
+		chain, ctx, "/lnrpc.Lightning/CloseChannel",
+		&lnrpc.CloseChannelRequest{},
+	)
+	require.NoError(t, err)
+	require.True(t, called)
+
+	// Paying the channel balance out to a delivery address is, however.
+	called, err = callPaymentInterceptor(
+		chain, ctx, "/lnrpc.Lightning/CloseChannel",
+		&lnrpc.CloseChannelRequest{
+			DeliveryAddress: "bcrt1qsw3ptzsjdc5vjnhz8wp6m5hfdahsq",
+		},
+	)
+	require.ErrorContains(t, err, "delivery address")
+	require.False(t, called)
+
+	// Splicing funds out of a channel can't be checked at all.
+	called, err = callPaymentInterceptor(
+		chain, ctx, "/lnrpc.Lightning/SpliceChannel",
+		&lnrpc.SpliceChannelRequest{},
+	)
+	require.ErrorContains(t, err, "method not allowed")
+	require.False(t, called)
+}
+
+// TestPaymentInterceptorUnconstrained asserts that requests made with
+// macaroons without payment caveats aren't checked.
+func TestPaymentInterceptorUnconstrained(t *testing.T) {
+	t.Parallel()
+
+	chain := newTestPaymentChain(
+		t, newTestPaymentDB(t), clock.NewTestClock(testTime),
+	)
+
+	called, err := callPaymentInterceptor(
+		chain, macaroonContext(t), "/lnrpc.Lightning/SendPaymentSync",
+		&lnrpc.SendRequest{},
+	)
+	require.NoError(t, err)
+	require.True(t, called)
+}
@@ -0,0 +1,288 @@
+package rpcperms
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/brsuite/broln/clock"
+	"github.com/brsuite/broln/lncfg"
+	"github.com/brsuite/broln/lnrpc"
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/macaroon-bakery.v2/bakery"
+	macaroon "gopkg.in/macaroon.v2"
+)
+
+// limiterPruneInterval is the interval at which the token buckets that are
+// full again are removed from the rate limiter.
+const limiterPruneInterval = time.Minute
+
+// tokenBucket is the token bucket of a root key ID and method, or of a root
+// key ID quota.
+type tokenBucket struct {
+	limiter *rate.Limiter
+
+	// refill is the time it takes to refill the empty bucket completely.
+	refill time.Duration
+
+	// fullAt is the time at which the bucket is refilled completely if no
+	// more tokens are taken. A full bucket behaves exactly like a newly
+	// created one, so it can be dropped from then on.
+	fullAt time.Time
+}
+
+// take takes a token from the bucket. False is returned, and no token is
+// taken, if the bucket is empty.
+func (b *tokenBucket) take(now time.Time) (bool, *rate.Reservation) {
+	res := b.limiter.ReserveN(now, 1)
+	if !res.OK() || res.DelayFrom(now) > 0 {
+		res.CancelAt(now)
+		return false, nil
+	}
+	b.fullAt = now.Add(b.refill)
+
+	return true, res
+}
+
+// rateLimiter limits the calls made to each RPC method with the macaroons of
+// a root key ID, and optionally the total calls made with them, using token
+// buckets.
+type rateLimiter struct {
+	clock clock.Clock
+
+	// defaultLimit is the limit of the methods without a limit of their
+	// own.
+	defaultLimit lncfg.TokenBucket
+
+	// methodLimits holds the limits overriding the default limit, keyed
+	// by method URI.
+	methodLimits map[string]lncfg.TokenBucket
+
+	// rootKeyQuotas holds the limits of the total calls made with the
+	// macaroons of a root key ID, keyed by root key ID.
+	rootKeyQuotas map[string]lncfg.TokenBucket
+
+	// callLimiters holds the token buckets of each root key ID and
+	// method that were used recently.
+	callLimiters map[string]*tokenBucket
+
+	// quotaLimiters holds the token buckets of each root key ID with a
+	// quota that were used recently.
+	quotaLimiters map[string]*tokenBucket
+
+	// lastPrune is the time the full token buckets were last removed.
+	lastPrune time.Time
+
+	sync.Mutex
+}
+
+// newRateLimiter creates a rate limiter from the given configuration.
+func newRateLimiter(cfg *lncfg.RateLimit,
+	clock clock.Clock) (*rateLimiter, error) {
+
+	methodLimits, err := cfg.MethodLimits()
+	if err != nil {
+		return nil, err
+	}
+
+	rootKeyQuotas, err := cfg.RootKeyQuotas()
+	if err != nil {
+		return nil, err
+	}
+
+	return &rateLimiter{
+		clock:         clock,
+		defaultLimit:  cfg.DefaultLimit(),
+		methodLimits:  methodLimits,
+		rootKeyQuotas: rootKeyQuotas,
+		callLimiters:  make(map[string]*tokenBucket),
+		quotaLimiters: make(map[string]*tokenBucket),
+		lastPrune:     clock.Now(),
+	}, nil
+}
+
+// newLimiter creates the token bucket of the given limit.
+func newLimiter(limit lncfg.TokenBucket) *tokenBucket {
+	return &tokenBucket{
+		limiter: rate.NewLimiter(rate.Limit(limit.Rate), limit.Burst),
+		refill: time.Duration(
+			float64(limit.Burst) / limit.Rate * float64(time.Second),
+		),
+	}
+}
+
+// prune removes the token buckets that are full again, so that the buckets of
+// root key IDs and methods that are no longer used don't accumulate.
+//
+// NOTE: The caller must hold the rate limiter's mutex.
+func (l *rateLimiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < limiterPruneInterval {
+		return
+	}
+	l.lastPrune = now
+
+	for key, bucket := range l.callLimiters {
+		if !now.Before(bucket.fullAt) {
+			delete(l.callLimiters, key)
+		}
+	}
+	for key, bucket := range l.quotaLimiters {
+		if !now.Before(bucket.fullAt) {
+			delete(l.quotaLimiters, key)
+		}
+	}
+}
+
+// allow takes a token from the buckets of the given root key ID and method.
+// A ResourceExhausted error is returned, and no token is taken, if any of
+// the buckets is empty.
+func (l *rateLimiter) allow(rootKeyID, fullMethod string) error {
+	l.Lock()
+	defer l.Unlock()
+
+	now := l.clock.Now()
+	l.prune(now)
+
+	limit, ok := l.methodLimits[fullMethod]
+	if !ok {
+		limit = l.defaultLimit
+	}
+
+	key := fmt.Sprintf("%s:%s", rootKeyID, fullMethod)
+	callLimiter, ok := l.callLimiters[key]
+	if !ok {
+		callLimiter = newLimiter(limit)
+		l.callLimiters[key] = callLimiter
+	}
+
+	ok, callRes := callLimiter.take(now)
+	if !ok {
+		return status.Errorf(codes.ResourceExhausted, "rate limit of "+
+			"%v calls per second exceeded for %s", limit.Rate,
+			fullMethod)
+	}
+
+	quota, ok := l.rootKeyQuotas[rootKeyID]
+	if !ok {
+		return nil
+	}
+
+	quotaLimiter, ok := l.quotaLimiters[rootKeyID]
+	if !ok {
+		quotaLimiter = newLimiter(quota)
+		l.quotaLimiters[rootKeyID] = quotaLimiter
+	}
+
+	if ok, _ := quotaLimiter.take(now); !ok {
+		callRes.CancelAt(now)
+
+		return status.Errorf(codes.ResourceExhausted, "quota of %v "+
+			"calls per second exceeded for macaroon root key ID %q",
+			quota.Rate, rootKeyID)
+	}
+
+	return nil
+}
+
+// rootKeyIDFromMacaroon returns the root key ID of the given macaroon, or an
+// empty string if there is no macaroon.
+func rootKeyIDFromMacaroon(mac *macaroon.Macaroon) (string, error) {
+	if mac == nil {
+		return "", nil
+	}
+
+	rawID := mac.Id()
+	if len(rawID) == 0 || rawID[0] != byte(bakery.LatestVersion) {
+		return "", fmt.Errorf("invalid macaroon version: %x", rawID)
+	}
+
+	decodedID := &lnrpc.MacaroonId{}
+	if err := proto.Unmarshal(rawID[1:], decodedID); err != nil {
+		return "", fmt.Errorf("unable to decode macaroon ID: %v", err)
+	}
+
+	return string(decodedID.StorageId), nil
+}
+
+// SetRateLimit enables rate limiting of the RPC calls with the given
+// configuration. It must be called before the gRPC server is created.
+func (r *InterceptorChain) SetRateLimit(cfg *lncfg.RateLimit) error {
+	r.Lock()
+	defer r.Unlock()
+
+	if !cfg.Enable {
+		r.rateLimiter = nil
+		return nil
+	}
+
+	limiter, err := newRateLimiter(cfg, clock.NewDefaultClock())
+	if err != nil {
+		return err
+	}
+	r.rateLimiter = limiter
+
+	return nil
+}
+
+// checkRateLimit takes a token from the rate limiter buckets of the macaroon
+// of the given request context and the given method.
+func (r *InterceptorChain) checkRateLimit(ctx context.Context,
+	fullMethod string) error {
+
+	r.RLock()
+	limiter := r.rateLimiter
+	r.RUnlock()
+
+	if limiter == nil {
+		return nil
+	}
+
+	// The macaroon was already validated at this point, so its root key
+	// ID can be trusted.
+	mac, _, err := macaroonFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	rootKeyID, err := rootKeyIDFromMacaroon(mac)
+	if err != nil {
+		return err
+	}
+
+	return limiter.allow(rootKeyID, fullMethod)
+}
+
+// rateLimitUnaryServerInterceptor is a unary gRPC interceptor that rejects
+// calls exceeding the configured rate limits.
+func (r *InterceptorChain) rateLimitUnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+
+		if err := r.checkRateLimit(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// rateLimitStreamServerInterceptor is a streaming gRPC interceptor that
+// rejects the opening of streams exceeding the configured rate limits.
+func (r *InterceptorChain) rateLimitStreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream,
+		info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+
+		err := r.checkRateLimit(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+
+		return handler(srv, ss)
+	}
+}
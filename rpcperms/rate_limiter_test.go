@@ -0,0 +1,151 @@
+package rpcperms
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/brsuite/broln/clock"
+	"github.com/brsuite/broln/lncfg"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	testMethod      = "/lnrpc.Lightning/GetInfo"
+	testOtherMethod = "/lnrpc.Lightning/ListChannels"
+)
+
+var testTime = time.Unix(1_600_000_000, 0)
+
+// newTestRateLimiter creates a rate limiter allowing one call per second with
+// a burst of two calls per method, and a quota of one call per second with a
+// burst of three calls for the root key ID "quota".
+func newTestRateLimiter(t *testing.T) (*rateLimiter, *clock.TestClock) {
+	testClock := clock.NewTestClock(testTime)
+	limiter, err := newRateLimiter(&lncfg.RateLimit{
+		Enable: true,
+		Rate:   1,
+		Burst:  2,
+		Quotas: []string{"quota=1:3"},
+	}, testClock)
+	require.NoError(t, err)
+
+	return limiter, testClock
+}
+
+// requireExhausted asserts that the given error is a ResourceExhausted error.
+func requireExhausted(t *testing.T, err error) {
+	t.Helper()
+
+	require.Error(t, err)
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
+
+// TestRateLimiterRefill asserts that calls are rejected once the token bucket
+// of a method is empty, and allowed again once it's refilled.
+func TestRateLimiterRefill(t *testing.T) {
+	t.Parallel()
+
+	limiter, testClock := newTestRateLimiter(t)
+
+	// The burst allows two calls at once.
+	require.NoError(t, limiter.allow("", testMethod))
+	require.NoError(t, limiter.allow("", testMethod))
+	requireExhausted(t, limiter.allow("", testMethod))
+
+	// The buckets of other methods and root key IDs are independent.
+	require.NoError(t, limiter.allow("", testOtherMethod))
+	require.NoError(t, limiter.allow("other", testMethod))
+
+	// A token is added every second.
+	testClock.SetTime(testTime.Add(time.Second))
+	require.NoError(t, limiter.allow("", testMethod))
+	requireExhausted(t, limiter.allow("", testMethod))
+
+	// The bucket doesn't hold more tokens than the burst.
+	testClock.SetTime(testTime.Add(time.Hour))
+	require.NoError(t, limiter.allow("", testMethod))
+	require.NoError(t, limiter.allow("", testMethod))
+	requireExhausted(t, limiter.allow("", testMethod))
+}
+
+// TestRateLimiterQuota asserts that the quota of a root key ID limits the
+// calls across all methods.
+func TestRateLimiterQuota(t *testing.T) {
+	t.Parallel()
+
+	limiter, testClock := newTestRateLimiter(t)
+
+	require.NoError(t, limiter.allow("quota", testMethod))
+	require.NoError(t, limiter.allow("quota", testOtherMethod))
+	require.NoError(t, limiter.allow("quota", testOtherMethod))
+	requireExhausted(t, limiter.allow("quota", testMethod))
+
+	// One token is added to the quota every second.
+	testClock.SetTime(testTime.Add(time.Second))
+	require.NoError(t, limiter.allow("quota", testMethod))
+	requireExhausted(t, limiter.allow("quota", testMethod))
+}
+
+// TestRateLimiterPrune asserts that the token buckets that are full again are
+// removed, while the ones still refilling are kept.
+func TestRateLimiterPrune(t *testing.T) {
+	t.Parallel()
+
+	limiter, testClock := newTestRateLimiter(t)
+
+	require.NoError(t, limiter.allow("quota", testMethod))
+	require.Len(t, limiter.callLimiters, 1)
+	require.Len(t, limiter.quotaLimiters, 1)
+
+	// Just before the prune interval, a call to another method empties
+	// its bucket, which needs two seconds to be refilled.
+	now := testTime.Add(limiterPruneInterval - time.Second)
+	testClock.SetTime(now)
+	require.NoError(t, limiter.allow("", testOtherMethod))
+	require.NoError(t, limiter.allow("", testOtherMethod))
+	require.Len(t, limiter.callLimiters, 2)
+
+	// Once the prune interval passed, only the full buckets are removed.
+	// The bucket that is still refilling keeps its state, so only the one
+	// token added since is available.
+	testClock.SetTime(testTime.Add(limiterPruneInterval))
+	require.NoError(t, limiter.allow("", testOtherMethod))
+	require.Len(t, limiter.callLimiters, 1)
+	require.Contains(t, limiter.callLimiters, ":"+testOtherMethod)
+	require.Empty(t, limiter.quotaLimiters)
+	requireExhausted(t, limiter.allow("", testOtherMethod))
+}
+
+// TestRateLimitInterceptor asserts that the rate limit interceptors reject
+// calls exceeding the limit with a ResourceExhausted error without calling the
+// handler.
+func TestRateLimitInterceptor(t *testing.T) {
+	t.Parallel()
+
+	limiter, _ := newTestRateLimiter(t)
+	chain := &InterceptorChain{
+		rateLimiter: limiter,
+	}
+
+	var numCalls int
+	handler := func(context.Context, interface{}) (interface{}, error) {
+		numCalls++
+		return nil, nil
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: testMethod}
+	interceptor := chain.rateLimitUnaryServerInterceptor()
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		_, err := interceptor(ctx, nil, info, handler)
+		require.NoError(t, err)
+	}
+
+	_, err := interceptor(ctx, nil, info, handler)
+	requireExhausted(t, err)
+	require.Equal(t, 2, numCalls)
+}
@@ -3,6 +3,7 @@ package broln
 import (
 	"bytes"
 	"context"
+	"crypto/rand"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -29,6 +30,8 @@ import (
 	"github.com/brsuite/bronwallet/wallet/txauthor"
 	"github.com/davecgh/go-spew/spew"
 	proxy "github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/brsuite/broln/accounts"
+	"github.com/brsuite/broln/auditlog"
 	"github.com/brsuite/broln/autopilot"
 	"github.com/brsuite/broln/build"
 	"github.com/brsuite/broln/chainreg"
@@ -61,10 +64,12 @@ import (
 	"github.com/brsuite/broln/lnwallet/chanfunding"
 	"github.com/brsuite/broln/lnwire"
 	"github.com/brsuite/broln/macaroons"
+	"github.com/brsuite/broln/onionmsg"
 	"github.com/brsuite/broln/peer"
 	"github.com/brsuite/broln/peernotifier"
 	"github.com/brsuite/broln/record"
 	"github.com/brsuite/broln/routing"
+	"github.com/brsuite/broln/routing/blindedpath"
 	"github.com/brsuite/broln/routing/route"
 	"github.com/brsuite/broln/rpcperms"
 	"github.com/brsuite/broln/signal"
@@ -207,6 +212,16 @@ var (
 	// macaroon related services are used.
 	errMacaroonDisabled = fmt.Errorf("macaroon authentication disabled, " +
 		"remove --no-macaroons flag to enable")
+
+	// errOnionMessagesDisabled is returned by the onion message RPCs if
+	// onion messages aren't enabled.
+	errOnionMessagesDisabled = errors.New("onion messages not enabled, " +
+		"enable them with --protocol.onion-messages")
+
+	// errAuditLogDisabled is returned by the audit log RPCs if the audit
+	// log isn't enabled.
+	errAuditLogDisabled = errors.New("audit log not enabled, enable it " +
+		"with --auditlog.enable")
 )
 
 // stringInSlice returns true if a string is contained in the given slice.
@@ -362,6 +377,13 @@ func MainRPCServerPermissions() map[string][]bakery.Op {
 			Entity: "offchain",
 			Action: "write",
 		}},
+		"/lnrpc.Lightning/SpliceChannel": {{
+			Entity: "onchain",
+			Action: "write",
+		}, {
+			Entity: "offchain",
+			Action: "write",
+		}},
 		"/lnrpc.Lightning/GetInfo": {{
 			Entity: "info",
 			Action: "read",
@@ -576,6 +598,46 @@ func MainRPCServerPermissions() map[string][]bakery.Op {
 			Entity: "offchain",
 			Action: "read",
 		}},
+		"/lnrpc.Lightning/SendOnionMessage": {{
+			Entity: "offchain",
+			Action: "write",
+		}},
+		"/lnrpc.Lightning/SubscribeOnionMessages": {{
+			Entity: "offchain",
+			Action: "read",
+		}},
+		"/lnrpc.Lightning/CreateAccount": {{
+			Entity: "macaroon",
+			Action: "generate",
+		}},
+		"/lnrpc.Lightning/ListAccounts": {{
+			Entity: "offchain",
+			Action: "read",
+		}},
+		"/lnrpc.Lightning/RemoveAccount": {{
+			Entity: "macaroon",
+			Action: "write",
+		}},
+		"/lnrpc.Lightning/QueryAuditLog": {{
+			Entity: "onchain",
+			Action: "read",
+		}, {
+			Entity: "offchain",
+			Action: "read",
+		}, {
+			Entity: "macaroon",
+			Action: "read",
+		}},
+		"/lnrpc.Lightning/VerifyAuditLog": {{
+			Entity: "onchain",
+			Action: "read",
+		}, {
+			Entity: "offchain",
+			Action: "read",
+		}, {
+			Entity: "macaroon",
+			Action: "read",
+		}},
 	}
 }
 
@@ -746,7 +808,7 @@ func (r *rpcServer) addDeps(s *server, macService *macaroons.Service,
 		routerBackend, s.nodeSigner, s.graphDB, s.chanStateDB,
 		s.sweeper, tower, s.towerClient, s.anchorTowerClient,
 		r.cfg.net.ResolveTCPAddr, genInvoiceFeatures,
-		genAmpInvoiceFeatures, rpcsLog,
+		genAmpInvoiceFeatures, s.offersMgr, s.prober, rpcsLog,
 	)
 	if err != nil {
 		return err
@@ -2024,6 +2086,24 @@ func (r *rpcServer) parseOpenChannelReq(in *lnrpc.OpenChannelRequest,
 			in.CommitmentType)
 	}
 
+	// Liquidity can only be requested from the remote peer as part of a
+	// dual funded channel open, which doesn't support pushing funds.
+	requestRemoteAmt := bronutil.Amount(in.RequestRemoteAmt)
+	maxLeaseFee := bronutil.Amount(in.MaxLeaseFeeSat)
+	switch {
+	case !in.DualFund && (requestRemoteAmt != 0 || maxLeaseFee != 0):
+		return nil, fmt.Errorf("request_remote_amt and " +
+			"max_lease_fee_sat require dual_fund to be set")
+
+	case requestRemoteAmt < 0 || maxLeaseFee < 0:
+		return nil, fmt.Errorf("request_remote_amt and " +
+			"max_lease_fee_sat must not be negative")
+
+	case in.DualFund && remoteInitialBalance != 0:
+		return nil, fmt.Errorf("push_sat is not supported for dual " +
+			"funded channels")
+	}
+
 	// Instruct the server to trigger the necessary events to attempt to
 	// open a new channel. A stream is returned in place, this stream will
 	// be used to consume updates of the state of the pending channel.
@@ -2042,6 +2122,11 @@ func (r *rpcServer) parseOpenChannelReq(in *lnrpc.OpenChannelRequest,
 		MaxHtlcs:         maxHtlcs,
 		MaxLocalCsv:      uint16(in.MaxLocalCsv),
 		ChannelType:      channelType,
+		ZeroConf:         in.ZeroConf,
+		ScidAlias:        in.ScidAlias,
+		DualFund:         in.DualFund,
+		RemoteFundingAmt: requestRemoteAmt,
+		MaxLeaseFee:      maxLeaseFee,
 	}, nil
 }
 
@@ -2289,7 +2374,8 @@ func (r *rpcServer) CloseChannel(in *lnrpc.CloseChannelRequest,
 	// If force closing a channel, the fee set in the commitment transaction
 	// is used.
 	if in.Force && (in.SatPerByte != 0 || in.SatPerVbyte != 0 || // nolint:staticcheck
-		in.TargetConf != 0) {
+		in.TargetConf != 0 || in.MinSatPerVbyte != 0 ||
+		in.MaxSatPerVbyte != 0) {
 
 		return fmt.Errorf("force closing a channel uses a pre-defined fee")
 	}
@@ -2410,13 +2496,20 @@ func (r *rpcServer) CloseChannel(in *lnrpc.CloseChannelRequest,
 		}
 
 		// If the link is not known by the switch, we cannot gracefully close
-		// the channel.
+		// the channel, unless the channel is already being closed
+		// cooperatively. In that case, the request is passed on to the
+		// peer, which is able to bump the fee of the pending close if it
+		// uses replaceable closing transactions.
 		channelID := lnwire.NewChanIDFromOutPoint(chanPoint)
-		if _, err := r.server.htlcSwitch.GetLink(channelID); err != nil {
+		_, linkErr := r.server.htlcSwitch.GetLink(channelID)
+		pendingClose := channel.HasChanStatus(
+			channeldb.ChanStatusCoopBroadcasted,
+		)
+		if linkErr != nil && !pendingClose {
 			rpcsLog.Debugf("Trying to non-force close offline channel with "+
 				"chan_point=%v", chanPoint)
 			return fmt.Errorf("unable to gracefully close channel while peer "+
-				"is offline (try force closing it instead): %v", err)
+				"is offline (try force closing it instead): %v", linkErr)
 		}
 
 		// Based on the passed fee related parameters, we'll determine
@@ -2433,6 +2526,21 @@ func (r *rpcServer) CloseChannel(in *lnrpc.CloseChannelRequest,
 		rpcsLog.Debugf("Target sat/kw for closing transaction: %v",
 			int64(feeRate))
 
+		// The fee rates we accept during the fee negotiation may
+		// optionally be bounded by the caller.
+		if in.MaxSatPerVbyte != 0 &&
+			in.MinSatPerVbyte > in.MaxSatPerVbyte {
+
+			return fmt.Errorf("min_sat_per_vbyte must not exceed " +
+				"max_sat_per_vbyte")
+		}
+		minFeeRate := chainfee.SatPerKVByte(
+			in.MinSatPerVbyte * 1000,
+		).FeePerKWeight()
+		maxFeeRate := chainfee.SatPerKVByte(
+			in.MaxSatPerVbyte * 1000,
+		).FeePerKWeight()
+
 		// Before we attempt the cooperative channel closure, we'll
 		// examine the channel to ensure that it doesn't have a
 		// lingering HTLC.
@@ -2465,10 +2573,34 @@ func (r *rpcServer) CloseChannel(in *lnrpc.CloseChannelRequest,
 			}
 		}
 
-		updateChan, errChan = r.server.htlcSwitch.CloseLink(
-			chanPoint, contractcourt.CloseRegular, feeRate,
-			deliveryScript,
-		)
+		if linkErr == nil {
+			updateChan, errChan = r.server.htlcSwitch.CloseLink(
+				chanPoint, contractcourt.CloseRegular, feeRate,
+				minFeeRate, maxFeeRate, deliveryScript,
+			)
+		} else {
+			peer, err := r.server.FindPeer(channel.IdentityPub)
+			if err != nil {
+				return fmt.Errorf("unable to bump fee of pending "+
+					"close while peer is offline: %v", err)
+			}
+
+			rpcsLog.Debugf("Requesting fee bump of pending close "+
+				"of chan_point=%v", chanPoint)
+
+			updateChan = make(chan interface{}, 2)
+			errChan = make(chan error, 1)
+			peer.HandleLocalCloseChanReqs(&htlcswitch.ChanClose{
+				CloseType:      contractcourt.CloseRegular,
+				ChanPoint:      chanPoint,
+				Updates:        updateChan,
+				TargetFeePerKw: feeRate,
+				MinFeePerKw:    minFeeRate,
+				MaxFeePerKw:    maxFeeRate,
+				DeliveryScript: deliveryScript,
+				Err:            errChan,
+			})
+		}
 	}
 out:
 	for {
@@ -2682,6 +2814,92 @@ func (r *rpcServer) AbandonChannel(_ context.Context,
 	return &lnrpc.AbandonChannelResponse{}, nil
 }
 
+// SpliceChannel adds funds from the internal wallet to an active channel,
+// and/or removes funds from it to an on-chain address. The call returns once
+// the splice transaction has been published.
+func (r *rpcServer) SpliceChannel(_ context.Context,
+	in *lnrpc.SpliceChannelRequest) (*lnrpc.SpliceChannelResponse, error) {
+
+	if !r.cfg.ProtocolOptions.Splicing() {
+		return nil, fmt.Errorf("splicing is not enabled, set " +
+			"protocol.splicing to enable it")
+	}
+
+	if in.SpliceInSat < 0 || in.SpliceOutSat < 0 {
+		return nil, fmt.Errorf("splice amounts must not be negative")
+	}
+
+	if in.ChannelPoint == nil {
+		return nil, fmt.Errorf("channel point must be set")
+	}
+	txid, err := lnrpc.GetChanPointFundingTxid(in.GetChannelPoint())
+	if err != nil {
+		return nil, err
+	}
+	chanPoint := wire.NewOutPoint(txid, in.ChannelPoint.OutputIndex)
+
+	feeRate, err := calculateFeeRate(
+		0, in.SatPerVbyte, uint32(in.TargetConf),
+		r.server.cc.FeeEstimator,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &peer.SpliceRequest{
+		ChanPoint: *chanPoint,
+		SpliceIn:  bronutil.Amount(in.SpliceInSat),
+		SpliceOut: bronutil.Amount(in.SpliceOutSat),
+		FeeRate:   feeRate,
+	}
+
+	// If funds are removed from the channel, we'll send them to the
+	// address given, which must be valid for the active network.
+	if in.SpliceOutSat != 0 {
+		addr, err := bronutil.DecodeAddress(
+			in.SpliceOutAddress, r.cfg.ActiveNetParams.Params,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("invalid splice out address: "+
+				"%v", err)
+		}
+		if !addr.IsForNet(r.cfg.ActiveNetParams.Params) {
+			return nil, fmt.Errorf("address: %v is not valid for "+
+				"this network: %v", addr.String(),
+				r.cfg.ActiveNetParams.Params.Name)
+		}
+
+		req.SpliceOutScript, err = txscript.PayToAddrScript(addr)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	channel, err := r.server.chanStateDB.FetchChannel(nil, *chanPoint)
+	if err != nil {
+		return nil, err
+	}
+
+	peer, err := r.server.FindPeer(channel.IdentityPub)
+	if err != nil {
+		return nil, fmt.Errorf("unable to splice channel, peer of "+
+			"ChannelPoint(%v) is offline", chanPoint)
+	}
+
+	rpcsLog.Infof("[splicechannel] ChannelPoint(%v), splice_in=%v, "+
+		"splice_out=%v, sat/kw=%v", chanPoint, req.SpliceIn,
+		req.SpliceOut, int64(feeRate))
+
+	spliceTxid, err := peer.SpliceChannel(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return &lnrpc.SpliceChannelResponse{
+		SpliceTxid: spliceTxid[:],
+	}, nil
+}
+
 // GetInfo returns general information concerning the lightning node including
 // its identity pubkey, alias, the chains it is connected to, and information
 // concerning the number of open+pending channels.
@@ -3969,6 +4187,26 @@ func createRPCOpenChannel(r *rpcServer, graph *channeldb.ChannelGraph,
 		CsvDelay:             uint32(dbChannel.LocalChanCfg.CsvDelay),
 		LocalChanReserveSat:  int64(dbChannel.LocalChanCfg.ChanReserve),
 		RemoteChanReserveSat: int64(dbChannel.RemoteChanCfg.ChanReserve),
+		ZeroConf:             dbChannel.IsZeroConf(),
+	}
+
+	// Report the aliases the channel can be referred to by. The confirmed
+	// short channel ID of a zero-conf channel is tracked alongside its
+	// aliases, so we'll report it separately.
+	aliases, err := r.server.chanStateDB.GetAliases(dbChannel.ShortChannelID)
+	if err != nil {
+		return nil, err
+	}
+	for _, alias := range aliases {
+		if channeldb.IsAlias(alias) {
+			channel.AliasScids = append(
+				channel.AliasScids, alias.ToUint64(),
+			)
+		}
+	}
+	if dbChannel.IsZeroConf() && dbChannel.ZeroConfConfirmed() {
+		realScid := dbChannel.ZeroConfRealScid()
+		channel.ZeroConfConfirmedScid = realScid.ToUint64()
 	}
 
 	for i, htlc := range localCommit.Htlcs {
@@ -4031,7 +4269,7 @@ func createRPCOpenChannel(r *rpcServer, graph *channeldb.ChannelGraph,
 	// Lookup our balances at height 0, because they will reflect any
 	// push amounts that may have been present when this channel was
 	// created.
-	localBalance, remoteBalance, err := dbChannel.BalancesAtHeight(0)
+	localBalance, remoteBalance, err = dbChannel.BalancesAtHeight(0)
 	if err != nil {
 		return nil, err
 	}
@@ -5199,6 +5437,10 @@ func (r *rpcServer) AddInvoice(ctx context.Context,
 		GenAmpInvoiceFeatures: func() *lnwire.FeatureVector {
 			return r.server.featureMgr.Get(feature.SetInvoiceAmp)
 		},
+		BestHeight: func() (uint32, error) {
+			_, height, err := r.server.cc.ChainIO.GetBestBlock()
+			return uint32(height), err
+		},
 	}
 
 	value, err := lnrpc.UnmarshallAmt(invoice.Value, invoice.ValueMsat)
@@ -5221,6 +5463,7 @@ func (r *rpcServer) AddInvoice(ctx context.Context,
 		Private:         invoice.Private,
 		RouteHints:      routeHints,
 		Amp:             invoice.IsAmp,
+		Blind:           invoice.Blind,
 	}
 
 	if invoice.RPreimage != nil {
@@ -5320,7 +5563,7 @@ func (r *rpcServer) ListInvoices(ctx context.Context,
 		PendingOnly:    req.PendingOnly,
 		Reversed:       req.Reversed,
 	}
-	invoiceSlice, err := r.server.miscDB.QueryInvoices(q)
+	invoiceSlice, err := r.server.invoicesDB.QueryInvoices(q)
 	if err != nil {
 		return nil, fmt.Errorf("unable to query invoices: %v", err)
 	}
@@ -7544,6 +7787,308 @@ func (r *rpcServer) SubscribeCustomMessages(req *lnrpc.SubscribeCustomMessagesRe
 	}
 }
 
+// SendOnionMessage sends an onion message to a node, either directly or over a
+// blinded path the node created.
+func (r *rpcServer) SendOnionMessage(ctx context.Context,
+	req *lnrpc.SendOnionMessageRequest) (*lnrpc.SendOnionMessageResponse,
+	error) {
+
+	messenger := r.server.onionMessenger
+	if messenger == nil {
+		return nil, errOnionMessagesDisabled
+	}
+
+	var path *blindedpath.BlindedPath
+	switch {
+	case len(req.Peer) > 0 && len(req.BlindedPath) > 0:
+		return nil, status.Error(codes.InvalidArgument, "only one of "+
+			"peer and blinded_path can be set")
+
+	case len(req.Peer) > 0:
+		pubKey, err := btcec.ParsePubKey(req.Peer, btcec.S256())
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument,
+				"invalid peer: %v", err)
+		}
+
+		path, err = onionmsg.DirectPath(pubKey)
+		if err != nil {
+			return nil, err
+		}
+
+	case len(req.BlindedPath) > 0:
+		path = &blindedpath.BlindedPath{}
+		err := path.Decode(bytes.NewReader(req.BlindedPath))
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument,
+				"invalid blinded path: %v", err)
+		}
+
+	default:
+		return nil, status.Error(codes.InvalidArgument, "either peer "+
+			"or blinded_path must be set")
+	}
+
+	payload := &onionmsg.Payload{
+		CustomRecords: req.CustomRecords,
+	}
+
+	// If the recipient should be able to reply, we include a path back to
+	// us, along with a random path ID that lets the caller match replies
+	// to this message.
+	resp := &lnrpc.SendOnionMessageResponse{}
+	if req.RequestReply {
+		pathID := make([]byte, 32)
+		if _, err := rand.Read(pathID); err != nil {
+			return nil, err
+		}
+
+		replyPath, err := messenger.ReplyPath(pathID)
+		if err != nil {
+			return nil, err
+		}
+
+		payload.ReplyPath = replyPath
+		resp.ReplyPathId = pathID
+	}
+
+	err := messenger.SendMessage(path, payload)
+	switch {
+	case err == onionmsg.ErrInvalidCustomRecord:
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+
+	case err != nil:
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// SubscribeOnionMessages subscribes to a stream of the onion messages we are
+// the final recipient of.
+func (r *rpcServer) SubscribeOnionMessages(
+	req *lnrpc.SubscribeOnionMessagesRequest,
+	server lnrpc.Lightning_SubscribeOnionMessagesServer) error {
+
+	messenger := r.server.onionMessenger
+	if messenger == nil {
+		return errOnionMessagesDisabled
+	}
+
+	client, err := messenger.SubscribeMessages()
+	if err != nil {
+		return err
+	}
+	defer client.Cancel()
+
+	for {
+		select {
+		case <-client.Quit():
+			return errors.New("shutdown")
+
+		case <-server.Context().Done():
+			return server.Context().Err()
+
+		case update := <-client.Updates():
+			onionMsg := update.(*onionmsg.ReceivedMessage)
+
+			rpcMsg := &lnrpc.OnionMessageUpdate{
+				PathId:        onionMsg.PathID,
+				CustomRecords: onionMsg.Payload.CustomRecords,
+			}
+
+			if onionMsg.Payload.ReplyPath != nil {
+				var b bytes.Buffer
+				err := onionMsg.Payload.ReplyPath.Encode(&b)
+				if err != nil {
+					return err
+				}
+				rpcMsg.ReplyPath = b.Bytes()
+			}
+
+			if err := server.Send(rpcMsg); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// CreateAccount creates a new off-chain account with a virtual balance.
+func (r *rpcServer) CreateAccount(_ context.Context,
+	req *lnrpc.CreateAccountRequest) (*lnrpc.CreateAccountResponse, error) {
+
+	rpcsLog.Debugf("[createaccount] balance=%v, label=%v",
+		lnwire.MilliSatoshi(req.BalanceMsat), req.Label)
+
+	account, err := r.server.accountService.NewAccount(
+		lnwire.MilliSatoshi(req.BalanceMsat), req.Label,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &lnrpc.CreateAccountResponse{
+		Account: marshalAccount(account),
+	}, nil
+}
+
+// ListAccounts returns all off-chain accounts.
+func (r *rpcServer) ListAccounts(_ context.Context,
+	_ *lnrpc.ListAccountsRequest) (*lnrpc.ListAccountsResponse, error) {
+
+	rpcsLog.Debugf("[listaccounts]")
+
+	allAccounts, err := r.server.accountService.Accounts()
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &lnrpc.ListAccountsResponse{
+		Accounts: make([]*lnrpc.Account, 0, len(allAccounts)),
+	}
+	for _, account := range allAccounts {
+		resp.Accounts = append(resp.Accounts, marshalAccount(account))
+	}
+
+	return resp, nil
+}
+
+// RemoveAccount removes an off-chain account.
+func (r *rpcServer) RemoveAccount(_ context.Context,
+	req *lnrpc.RemoveAccountRequest) (*lnrpc.RemoveAccountResponse, error) {
+
+	rpcsLog.Debugf("[removeaccount] id=%v", req.Id)
+
+	id, err := accounts.ParseAccountID(req.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.server.accountService.RemoveAccount(id); err != nil {
+		return nil, err
+	}
+
+	return &lnrpc.RemoveAccountResponse{}, nil
+}
+
+// marshalAccount converts an off-chain account into its RPC representation.
+func marshalAccount(account *accounts.OffChainBalanceAccount) *lnrpc.Account {
+	rpcAccount := &lnrpc.Account{
+		Id:                   account.ID.String(),
+		InitialBalanceMsat:   uint64(account.InitialBalance),
+		CurrentBalanceMsat:   account.CurrentBalance,
+		AvailableBalanceMsat: uint64(account.AvailableBalance()),
+		LastUpdate:           account.LastUpdate.Unix(),
+		Label:                account.Label,
+	}
+
+	for hash := range account.Invoices {
+		hash := hash
+		rpcAccount.Invoices = append(rpcAccount.Invoices, hash[:])
+	}
+
+	for hash, entry := range account.Payments {
+		hash := hash
+
+		status := "IN_FLIGHT"
+		if entry.Status == channeldb.StatusSucceeded {
+			status = "SUCCEEDED"
+		}
+
+		rpcAccount.Payments = append(
+			rpcAccount.Payments, &lnrpc.AccountPayment{
+				PaymentHash:    hash[:],
+				Status:         status,
+				FullAmountMsat: uint64(entry.FullAmount),
+			},
+		)
+	}
+
+	return rpcAccount
+}
+
+// QueryAuditLog returns the entries of the audit log of state-changing RPC
+// calls matching the request.
+func (r *rpcServer) QueryAuditLog(_ context.Context,
+	req *lnrpc.QueryAuditLogRequest) (*lnrpc.QueryAuditLogResponse, error) {
+
+	rpcsLog.Debugf("[queryauditlog] index_offset=%v, max_entries=%v, "+
+		"method=%v", req.IndexOffset, req.MaxEntries, req.Method)
+
+	auditLog := r.interceptorChain.AuditLog()
+	if auditLog == nil {
+		return nil, errAuditLogDisabled
+	}
+
+	query := &auditlog.Query{
+		IndexOffset: req.IndexOffset,
+		MaxEntries:  req.MaxEntries,
+		Method:      req.Method,
+	}
+	if req.StartTime != 0 {
+		query.StartTime = time.Unix(req.StartTime, 0)
+	}
+	if req.EndTime != 0 {
+		query.EndTime = time.Unix(req.EndTime, 0)
+	}
+
+	entries, err := auditLog.Query(query)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &lnrpc.QueryAuditLogResponse{
+		Entries:         make([]*lnrpc.AuditLogEntry, 0, len(entries)),
+		LastIndexOffset: req.IndexOffset,
+	}
+	for _, entry := range entries {
+		entry := entry
+
+		resp.Entries = append(resp.Entries, &lnrpc.AuditLogEntry{
+			Index:       entry.Index,
+			TimestampNs: entry.Timestamp.UnixNano(),
+			Method:      entry.Method,
+			MacaroonId:  entry.MacaroonID,
+			RootKeyId:   entry.RootKeyID,
+			PeerAddr:    entry.PeerAddr,
+			Request:     entry.Request,
+			Success:     entry.Success(),
+			Error:       entry.Error,
+			PrevHash:    entry.PrevHash[:],
+			Hash:        entry.Hash[:],
+		})
+		resp.LastIndexOffset = entry.Index
+	}
+
+	return resp, nil
+}
+
+// VerifyAuditLog verifies the hash chain of the audit log of state-changing
+// RPC calls.
+func (r *rpcServer) VerifyAuditLog(_ context.Context,
+	_ *lnrpc.VerifyAuditLogRequest) (*lnrpc.VerifyAuditLogResponse, error) {
+
+	rpcsLog.Debugf("[verifyauditlog]")
+
+	auditLog := r.interceptorChain.AuditLog()
+	if auditLog == nil {
+		return nil, errAuditLogDisabled
+	}
+
+	verification, err := auditLog.Verify()
+	if err != nil {
+		return nil, err
+	}
+
+	return &lnrpc.VerifyAuditLogResponse{
+		Valid:       verification.Valid(),
+		NumEntries:  verification.NumEntries,
+		LastHash:    verification.LastHash[:],
+		BrokenIndex: verification.BrokenIndex,
+		Reason:      verification.Reason,
+	}, nil
+}
+
 // rpcInitiator returns the correct lnrpc initiator for channels where we have
 // a record of the opening channel.
 func rpcInitiator(isInitiator bool) lnrpc.Initiator {
@@ -10,6 +10,7 @@ import (
 	"math/big"
 	prand "math/rand"
 	"net"
+	"os"
 	"regexp"
 	"strconv"
 	"strings"
@@ -25,6 +26,7 @@ import (
 	"github.com/brsuite/bronutil"
 	"github.com/go-errors/errors"
 	sphinx "github.com/brsuite/lightning-onion"
+	"github.com/brsuite/broln/accounts"
 	"github.com/brsuite/broln/autopilot"
 	"github.com/brsuite/broln/brontide"
 	"github.com/brsuite/broln/cert"
@@ -50,12 +52,15 @@ import (
 	"github.com/brsuite/broln/lnpeer"
 	"github.com/brsuite/broln/lnrpc"
 	"github.com/brsuite/broln/lnrpc/routerrpc"
+	"github.com/brsuite/broln/lntypes"
 	"github.com/brsuite/broln/lnwallet"
 	"github.com/brsuite/broln/lnwallet/chainfee"
 	"github.com/brsuite/broln/lnwallet/rpcwallet"
 	"github.com/brsuite/broln/lnwire"
 	"github.com/brsuite/broln/nat"
 	"github.com/brsuite/broln/netann"
+	"github.com/brsuite/broln/offers"
+	"github.com/brsuite/broln/onionmsg"
 	"github.com/brsuite/broln/peer"
 	"github.com/brsuite/broln/peernotifier"
 	"github.com/brsuite/broln/pool"
@@ -249,6 +254,9 @@ type server struct {
 	// channel DB that haven't been separated out yet.
 	miscDB *channeldb.DB
 
+	// invoicesDB is the DB that stores all of our node's invoices.
+	invoicesDB invoices.InvoiceDB
+
 	htlcSwitch *htlcswitch.Switch
 
 	interceptableSwitch *htlcswitch.InterceptableSwitch
@@ -313,6 +321,11 @@ type server struct {
 	// channelNotifier to be notified of newly opened and closed channels.
 	chanSubSwapper *chanbackup.SubSwapper
 
+	// peerBackupPusher is a sub-system that hands each channel peer an
+	// encrypted backup of the channels we have with it, for the peer to
+	// store on our behalf. It is nil if peer storage is disabled.
+	peerBackupPusher *chanbackup.PeerBackupPusher
+
 	// chanEventStore tracks the behaviour of channels and their remote peers to
 	// provide insights into their health and performance.
 	chanEventStore *chanfitness.ChannelEventStore
@@ -324,6 +337,22 @@ type server struct {
 
 	customMessageServer *subscribe.Server
 
+	// onionMessenger sends and receives onion messages. It is nil if
+	// onion messages aren't enabled.
+	onionMessenger *onionmsg.Messenger
+
+	// offersMgr creates and pays BOLT 12 offers. It is nil if offers
+	// aren't enabled.
+	offersMgr *offers.Manager
+
+	// prober probes destinations to estimate the amount that can be sent
+	// to them.
+	prober *routing.Prober
+
+	// accountService keeps track of the virtual balances of the off-chain
+	// accounts macaroons can be bound to.
+	accountService *accounts.Service
+
 	quit chan struct{}
 
 	wg sync.WaitGroup
@@ -536,6 +565,14 @@ func newServer(cfg *Config, listenAddrs []net.Addr,
 		NoAnchors:                cfg.ProtocolOptions.NoAnchorCommitments(),
 		NoWumbo:                  !cfg.ProtocolOptions.Wumbo(),
 		NoScriptEnforcementLease: cfg.ProtocolOptions.NoScriptEnforcementLease(),
+		NoScidAlias:              !cfg.ProtocolOptions.ScidAlias(),
+		NoZeroConf:               !cfg.ProtocolOptions.ZeroConf(),
+		NoOnionMessages:          !cfg.ProtocolOptions.OnionMessages(),
+		NoRouteBlinding:          cfg.ProtocolOptions.NoRouteBlinding(),
+		NoDualFund:               !cfg.ProtocolOptions.DualFunding(),
+		NoSplice:                 !cfg.ProtocolOptions.Splicing(),
+		NoPeerStorage:            !cfg.ProtocolOptions.PeerStorage(),
+		NoRbfCoopClose:           !cfg.ProtocolOptions.RbfCoopClose(),
 	})
 	if err != nil {
 		return nil, err
@@ -558,6 +595,7 @@ func newServer(cfg *Config, listenAddrs []net.Addr,
 		chanStateDB:    dbs.ChanStateDB.ChannelStateDB(),
 		addrSource:     dbs.ChanStateDB,
 		miscDB:         dbs.ChanStateDB,
+		invoicesDB:     dbs.InvoiceDB,
 		cc:             cc,
 		sigPool:        lnwallet.NewSigPool(cfg.Workers.Sig, cc.Signer),
 		writePool:      writePool,
@@ -576,7 +614,7 @@ func newServer(cfg *Config, listenAddrs []net.Addr,
 
 		// TODO(roasbeef): derive proper onion key based on rotation
 		// schedule
-		sphinx: hop.NewOnionProcessor(sphinxRouter),
+		sphinx: hop.NewOnionProcessor(sphinxRouter, nodeKeyECDH),
 
 		torController: torController,
 
@@ -617,9 +655,20 @@ func newServer(cfg *Config, listenAddrs []net.Addr,
 		uint32(currentHeight), currentHash, cc.ChainNotifier,
 	)
 	s.invoices = invoices.NewRegistry(
-		dbs.ChanStateDB, expiryWatcher, &registryConfig,
+		dbs.InvoiceDB, expiryWatcher, &registryConfig,
 	)
 
+	if cfg.ProtocolOptions.OnionMessages() {
+		s.initOnionMessenger(nodeKeyDesc, nodeKeyECDH)
+	}
+
+	if cfg.ProtocolOptions.Offers() {
+		err := s.initOffers(nodeKeyDesc, nodeKeyECDH)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	s.htlcNotifier = htlcswitch.NewHtlcNotifier(time.Now)
 
 	thresholdSats := bronutil.Amount(cfg.DustThreshold)
@@ -657,11 +706,19 @@ func newServer(cfg *Config, listenAddrs []net.Addr,
 		Clock:                  clock.NewDefaultClock(),
 		HTLCExpiry:             htlcswitch.DefaultHTLCExpiry,
 		DustThreshold:          thresholdMSats,
+		GetAliases:             s.chanStateDB.GetAliases,
 	}, uint32(currentHeight))
 	if err != nil {
 		return nil, err
 	}
-	s.interceptableSwitch = htlcswitch.NewInterceptableSwitch(s.htlcSwitch)
+	s.interceptableSwitch = htlcswitch.NewInterceptableSwitch(
+		&htlcswitch.InterceptableSwitchConfig{
+			Switch:             s.htlcSwitch,
+			Notifier:           s.cc.ChainNotifier,
+			CltvRejectDelta:    cfg.InterceptorCltvDelta,
+			RequireInterceptor: cfg.RequireInterceptor,
+		},
+	)
 
 	chanStatusMgrCfg := &netann.ChanStatusConfig{
 		ChanStatusSampleInterval: cfg.ChanStatusSampleInterval,
@@ -843,16 +900,41 @@ func newServer(cfg *Config, listenAddrs []net.Addr,
 	// servers, the mission control instance itself can be moved there too.
 	routingConfig := routerrpc.GetRoutingConfig(cfg.SubRPCServers.RouterRPC)
 
-	estimatorCfg := routing.ProbabilityEstimatorCfg{
-		AprioriHopProbability: routingConfig.AprioriHopProbability,
-		PenaltyHalfLife:       routingConfig.PenaltyHalfLife,
-		AprioriWeight:         routingConfig.AprioriWeight,
+	var estimator routing.Estimator
+	switch routingConfig.ProbabilityEstimatorType {
+	case routing.AprioriEstimatorName:
+		aCfg := routing.AprioriConfig{
+			AprioriHopProbability: routingConfig.AprioriHopProbability,
+			PenaltyHalfLife:       routingConfig.PenaltyHalfLife,
+			AprioriWeight:         routingConfig.AprioriWeight,
+		}
+		estimator, err = routing.NewAprioriEstimator(aCfg)
+		if err != nil {
+			return nil, err
+		}
+
+	case routing.BimodalEstimatorName:
+		bCfg := routing.BimodalConfig{
+			BimodalNodeWeight: routingConfig.BimodalConfig.NodeWeight,
+			BimodalScaleMsat: lnwire.MilliSatoshi(
+				routingConfig.BimodalConfig.Scale,
+			),
+			BimodalDecayTime: routingConfig.BimodalConfig.DecayTime,
+		}
+		estimator, err = routing.NewBimodalEstimator(bCfg)
+		if err != nil {
+			return nil, err
+		}
+
+	default:
+		return nil, fmt.Errorf("unknown estimator type %v",
+			routingConfig.ProbabilityEstimatorType)
 	}
 
 	s.missionControl, err = routing.NewMissionControl(
 		dbs.ChanStateDB, selfNode.PubKeyBytes,
 		&routing.MissionControlConfig{
-			ProbabilityEstimatorCfg: estimatorCfg,
+			Estimator:               estimator,
 			MaxMcHistory:            routingConfig.MaxMcHistory,
 			McFlushInterval:         routingConfig.McFlushInterval,
 			MinFailureRelaxInterval: routing.DefaultMinFailureRelaxInterval,
@@ -862,6 +944,20 @@ func newServer(cfg *Config, listenAddrs []net.Addr,
 		return nil, fmt.Errorf("can't create mission control: %v", err)
 	}
 
+	// If a mission control export was configured, seed mission control
+	// with it. This lets a node that replaces another one start out with
+	// its predecessor's routing knowledge.
+	if routingConfig.McImportFile != "" {
+		err := seedMissionControl(
+			s.missionControl, routingConfig.McImportFile,
+			routingConfig.McImportStrategy,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("can't seed mission control: %v",
+				err)
+		}
+	}
+
 	srvrLog.Debugf("Instantiating payment session source with config: "+
 		"AttemptCost=%v + %v%%, MinRouteProbability=%v",
 		int64(routingConfig.AttemptCost),
@@ -888,10 +984,31 @@ func newServer(cfg *Config, listenAddrs []net.Addr,
 		PathFindingConfig: pathFindingConfig,
 	}
 
+	if routingConfig.NodeLocationFile != "" {
+		nodeLocator, err := routing.LoadPrefixLocator(
+			routingConfig.NodeLocationFile,
+		)
+		if err != nil {
+			return nil, err
+		}
+		paymentSessionSource.NodeLocator = nodeLocator
+	}
+
 	paymentControl := channeldb.NewPaymentControl(dbs.ChanStateDB)
 
 	s.controlTower = routing.NewControlTower(paymentControl)
 
+	accountStore, err := accounts.NewStore(dbs.ChanStateDB)
+	if err != nil {
+		return nil, err
+	}
+	s.accountService = accounts.NewService(&accounts.Config{
+		Store:             accountStore,
+		SubscribeInvoices: s.invoices.SubscribeNotifications,
+		FetchPayment:      s.controlTower.FetchPayment,
+		SubscribePayment:  s.controlTower.SubscribePayment,
+	})
+
 	strictPruning := (cfg.Brocoin.Node == "neutrino" ||
 		cfg.Routing.StrictZombiePruning)
 	s.chanRouter, err = routing.New(routing.Config{
@@ -908,6 +1025,10 @@ func newServer(cfg *Config, listenAddrs []net.Addr,
 		FirstTimePruneDelay: routing.DefaultFirstTimePruneDelay,
 		GetLink:             s.htlcSwitch.GetLinkByShortID,
 		AssumeChannelValid:  cfg.Routing.AssumeChannelValid,
+		IsOwnAlias: func(scid lnwire.ShortChannelID) bool {
+			_, err := s.chanStateDB.FindBaseSCID(scid)
+			return err == nil
+		},
 		NextPaymentID:       sequencer.NextID,
 		PathFindingConfig:   pathFindingConfig,
 		Clock:               clock.NewDefaultClock(),
@@ -917,6 +1038,18 @@ func newServer(cfg *Config, listenAddrs []net.Addr,
 		return nil, fmt.Errorf("can't create router: %v", err)
 	}
 
+	s.prober, err = routing.NewProber(&routing.ProberConfig{
+		SendPayment: s.chanRouter.SendPayment,
+		DeletePayment: func(hash lntypes.Hash) error {
+			return dbs.ChanStateDB.DeletePayment(hash, false)
+		},
+		MaxConcurrency: routingConfig.MaxProbeConcurrency,
+		Clock:          clock.NewDefaultClock(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("can't create prober: %v", err)
+	}
+
 	chanSeries := discovery.NewChanSeries(s.graphDB)
 	gossipMessageStore, err := discovery.NewMessageStore(dbs.ChanStateDB)
 	if err != nil {
@@ -1019,7 +1152,7 @@ func newServer(cfg *Config, listenAddrs []net.Addr,
 		// Instruct the switch to close the channel.  Provide no close out
 		// delivery script or target fee per kw because user input is not
 		// available when the remote peer closes the channel.
-		s.htlcSwitch.CloseLink(chanPoint, closureType, 0, nil)
+		s.htlcSwitch.CloseLink(chanPoint, closureType, 0, 0, 0, nil)
 	}
 
 	// We will use the following channel to reliably hand off contract
@@ -1173,9 +1306,10 @@ func newServer(cfg *Config, listenAddrs []net.Addr,
 		CurrentNodeAnnouncement: func() (lnwire.NodeAnnouncement, error) {
 			return s.genNodeAnnouncement(true)
 		},
-		SendAnnouncement: s.authGossiper.ProcessLocalAnnouncement,
-		NotifyWhenOnline: s.NotifyWhenOnline,
-		TempChanIDSeed:   chanIDSeed,
+		SendAnnouncement:  s.authGossiper.ProcessLocalAnnouncement,
+		NotifyWhenOnline:  s.NotifyWhenOnline,
+		NotifyWhenOffline: s.NotifyWhenOffline,
+		TempChanIDSeed:    chanIDSeed,
 		FindChannel: func(chanID lnwire.ChannelID) (
 			*channeldb.OpenChannel, error) {
 
@@ -1342,6 +1476,14 @@ func newServer(cfg *Config, listenAddrs []net.Addr,
 		RegisteredChains:              cfg.registeredChains,
 		MaxAnchorsCommitFeeRate: chainfee.SatPerKVByte(
 			s.cfg.MaxCommitFeeRateAnchors * 1000).FeePerKWeight(),
+		AliasManager: s.chanStateDB,
+		LeaseRates:   cfg.LiquidityAds.LeaseRates(),
+		MaxLeaseAmt:  cfg.LiquidityAds.MaxLeaseAmount(),
+		DeleteAliasEdge: func(scid lnwire.ShortChannelID) error {
+			return s.graphDB.DeleteChannelEdges(
+				false, scid.ToUint64(),
+			)
+		},
 	})
 	if err != nil {
 		return nil, err
@@ -1367,6 +1509,17 @@ func newServer(cfg *Config, listenAddrs []net.Addr,
 		return nil, err
 	}
 
+	// If peer storage is enabled, we'll also hand each channel peer a
+	// backup of the channels we have with it.
+	if cfg.ProtocolOptions.PeerStorage() {
+		s.peerBackupPusher, err = chanbackup.NewPeerBackupPusher(
+			startingChans, chanNotifier, s.cc.KeyRing, s,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// Assemble a peer notifier which will provide clients with subscriptions
 	// to peer online and offline events.
 	s.peerNotifier = peernotifier.New()
@@ -1794,12 +1947,40 @@ func (s *server) Start() error {
 		}
 		cleanup = cleanup.add(s.chanRouter.Stop)
 
+		if err := s.prober.Start(); err != nil {
+			startErr = err
+			return
+		}
+		cleanup = cleanup.add(s.prober.Stop)
+
 		if err := s.invoices.Start(); err != nil {
 			startErr = err
 			return
 		}
 		cleanup = cleanup.add(s.invoices.Stop)
 
+		if s.onionMessenger != nil {
+			if err := s.onionMessenger.Start(); err != nil {
+				startErr = err
+				return
+			}
+			cleanup = cleanup.add(s.onionMessenger.Stop)
+		}
+
+		if s.offersMgr != nil {
+			if err := s.offersMgr.Start(); err != nil {
+				startErr = err
+				return
+			}
+			cleanup = cleanup.add(s.offersMgr.Stop)
+		}
+
+		if err := s.accountService.Start(); err != nil {
+			startErr = err
+			return
+		}
+		cleanup = cleanup.add(s.accountService.Stop)
+
 		if err := s.sphinx.Start(); err != nil {
 			startErr = err
 			return
@@ -1812,6 +1993,12 @@ func (s *server) Start() error {
 		}
 		cleanup = cleanup.add(s.htlcSwitch.Stop)
 
+		if err := s.interceptableSwitch.Start(); err != nil {
+			startErr = err
+			return
+		}
+		cleanup = cleanup.add(s.interceptableSwitch.Stop)
+
 		if err := s.chanStatusMgr.Start(); err != nil {
 			startErr = err
 			return
@@ -1871,6 +2058,14 @@ func (s *server) Start() error {
 		}
 		cleanup = cleanup.add(s.chanSubSwapper.Stop)
 
+		if s.peerBackupPusher != nil {
+			if err := s.peerBackupPusher.Start(); err != nil {
+				startErr = err
+				return
+			}
+			cleanup = cleanup.add(s.peerBackupPusher.Stop)
+		}
+
 		if s.torController != nil {
 			if err := s.createNewHiddenService(); err != nil {
 				startErr = err
@@ -2013,18 +2208,40 @@ func (s *server) Stop() error {
 
 		// Shutdown the wallet, funding manager, and the rpc server.
 		s.chanStatusMgr.Stop()
+		if err := s.interceptableSwitch.Stop(); err != nil {
+			srvrLog.Warnf("failed to stop interceptable "+
+				"switch: %v", err)
+		}
 		if err := s.htlcSwitch.Stop(); err != nil {
 			srvrLog.Warnf("failed to stop htlcSwitch: %v", err)
 		}
 		if err := s.sphinx.Stop(); err != nil {
 			srvrLog.Warnf("failed to stop sphinx: %v", err)
 		}
+		if err := s.accountService.Stop(); err != nil {
+			srvrLog.Warnf("failed to stop accountService: %v", err)
+		}
+		if s.offersMgr != nil {
+			if err := s.offersMgr.Stop(); err != nil {
+				srvrLog.Warnf("failed to stop offersMgr: %v",
+					err)
+			}
+		}
+		if s.onionMessenger != nil {
+			if err := s.onionMessenger.Stop(); err != nil {
+				srvrLog.Warnf("failed to stop onionMessenger: "+
+					"%v", err)
+			}
+		}
 		if err := s.invoices.Stop(); err != nil {
 			srvrLog.Warnf("failed to stop invoices: %v", err)
 		}
 		if err := s.chanRouter.Stop(); err != nil {
 			srvrLog.Warnf("failed to stop chanRouter: %v", err)
 		}
+		if err := s.prober.Stop(); err != nil {
+			srvrLog.Warnf("failed to stop prober: %v", err)
+		}
 		if err := s.chainArb.Stop(); err != nil {
 			srvrLog.Warnf("failed to stop chainArb: %v", err)
 		}
@@ -2055,6 +2272,12 @@ func (s *server) Stop() error {
 		if err := s.chanSubSwapper.Stop(); err != nil {
 			srvrLog.Warnf("failed to stop chanSubSwapper: %v", err)
 		}
+		if s.peerBackupPusher != nil {
+			if err := s.peerBackupPusher.Stop(); err != nil {
+				srvrLog.Warnf("failed to stop "+
+					"peerBackupPusher: %v", err)
+			}
+		}
 		if err := s.cc.ChainNotifier.Stop(); err != nil {
 			srvrLog.Warnf("Unable to stop ChainNotifier: %v", err)
 		}
@@ -3418,6 +3641,165 @@ func (s *server) SubscribeCustomMessages() (*subscribe.Client, error) {
 	return s.customMessageServer.Subscribe()
 }
 
+// initOnionMessenger creates the onion messenger that sends, receives and
+// forwards onion messages.
+func (s *server) initOnionMessenger(nodeKeyDesc *keychain.KeyDescriptor,
+	nodeKeyECDH keychain.SingleKeyECDH) {
+
+	selfNode := route.NewVertex(nodeKeyDesc.PubKey)
+
+	s.onionMessenger = onionmsg.New(&onionmsg.Config{
+		NodeKey:           nodeKeyECDH,
+		SendToPeer:        s.sendOnionMessage,
+		ForwardToPeer:     s.forwardOnionMessage,
+		LookupChannelPeer: s.lookupChannelPeer,
+		FindIntroductionNode: func() (*btcec.PublicKey, error) {
+			return onionmsg.FindIntroductionNode(
+				s.graphDB, selfNode,
+				func(node route.Vertex) bool {
+					_, err := s.FindPeerByPubStr(
+						string(node[:]),
+					)
+					return err == nil
+				},
+			)
+		},
+	})
+}
+
+// initOffers creates the offers manager that uses the onion messenger to
+// exchange invoice requests and invoices.
+func (s *server) initOffers(nodeKeyDesc *keychain.KeyDescriptor,
+	nodeKeyECDH keychain.SingleKeyECDH) error {
+
+	// We authenticate the metadata of our offers with a key only we are
+	// able to derive, which is the result of ECDH with our own node key.
+	metadataKey, err := nodeKeyECDH.ECDH(nodeKeyDesc.PubKey)
+	if err != nil {
+		return err
+	}
+
+	s.offersMgr = offers.NewManager(&offers.Config{
+		ChainHash:   *s.cfg.ActiveNetParams.GenesisHash,
+		NodePubKey:  nodeKeyDesc.PubKey,
+		MetadataKey: metadataKey,
+		SignInvoice: func(digest chainhash.Hash) (*input.SchnorrSignature,
+			error) {
+
+			privKey, err := s.cc.KeyRing.DerivePrivKey(*nodeKeyDesc)
+			if err != nil {
+				return nil, err
+			}
+
+			return input.SchnorrSign(privKey, digest[:])
+		},
+		Messenger:  s.onionMessenger,
+		AddInvoice: s.invoices.AddInvoice,
+		InvoiceFeatures: func() *lnwire.FeatureVector {
+			return s.featureMgr.Get(feature.SetInvoice)
+		},
+		Clock: clock.NewDefaultClock(),
+	})
+
+	return nil
+}
+
+// sendOnionMessage sends an onion message to the peer with the given public
+// key. Onion messages are also exchanged with nodes we don't have a channel
+// with, so we connect to one of the addresses the peer advertises if we
+// aren't connected to it yet.
+func (s *server) sendOnionMessage(peerPub [33]byte,
+	msg *lnwire.OnionMessage) error {
+
+	peerChan := make(chan lnpeer.Peer, 1)
+	s.NotifyWhenOnline(peerPub, peerChan)
+
+	_, err := s.FindPeerByPubStr(string(peerPub[:]))
+	if err == ErrPeerNotConnected {
+		if err := s.connectToAdvertisedAddrs(peerPub); err != nil {
+			return err
+		}
+	}
+
+	var targetPeer lnpeer.Peer
+	select {
+	case targetPeer = <-peerChan:
+	case <-time.After(s.cfg.ConnectionTimeout):
+		return fmt.Errorf("peer %x didn't come online", peerPub)
+	case <-s.quit:
+		return ErrServerShuttingDown
+	}
+
+	// Onion messages aren't time critical, so we send them with low
+	// priority.
+	return targetPeer.SendMessageLazy(false, msg)
+}
+
+// forwardOnionMessage passes on an onion message to the next hop, which must be
+// one of our connected peers.
+func (s *server) forwardOnionMessage(peerPub [33]byte,
+	msg *lnwire.OnionMessage) error {
+
+	targetPeer, err := s.FindPeerByPubStr(string(peerPub[:]))
+	if err != nil {
+		return err
+	}
+
+	return targetPeer.SendMessageLazy(false, msg)
+}
+
+// lookupChannelPeer returns the public key of the peer on the other end of the
+// channel with the given short channel ID.
+func (s *server) lookupChannelPeer(scid lnwire.ShortChannelID) ([33]byte,
+	error) {
+
+	info, _, _, err := s.graphDB.FetchChannelEdgesByID(scid.ToUint64())
+	if err != nil {
+		return [33]byte{}, err
+	}
+
+	selfKey := s.identityECDH.PubKey().SerializeCompressed()
+	if bytes.Equal(info.NodeKey1Bytes[:], selfKey) {
+		return info.NodeKey2Bytes, nil
+	}
+	if bytes.Equal(info.NodeKey2Bytes[:], selfKey) {
+		return info.NodeKey1Bytes, nil
+	}
+
+	return [33]byte{}, fmt.Errorf("channel %v isn't ours", scid)
+}
+
+// connectToAdvertisedAddrs makes a non-permanent connection to the node with
+// the given public key, trying each of the addresses it advertises in turn.
+func (s *server) connectToAdvertisedAddrs(peerPub [33]byte) error {
+	pubKey, err := btcec.ParsePubKey(peerPub[:], btcec.S256())
+	if err != nil {
+		return err
+	}
+
+	addrs, err := s.fetchNodeAdvertisedAddrs(pubKey)
+	if err != nil {
+		return fmt.Errorf("unable to connect to %x: %v", peerPub, err)
+	}
+
+	for _, addr := range addrs {
+		err = s.ConnectToPeer(&lnwire.NetAddress{
+			IdentityKey: pubKey,
+			Address:     addr,
+			ChainNet:    s.cfg.ActiveNetParams.Net,
+		}, false, s.cfg.ConnectionTimeout)
+
+		if err == nil {
+			return nil
+		}
+		if _, ok := err.(*errPeerAlreadyConnected); ok {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unable to connect to %x: %v", peerPub, err)
+}
+
 // peerConnected is a function that handles initialization a newly connected
 // peer by adding it to the server's global list of all active peers, and
 // starting all the goroutines the peer needs to function properly. The inbound
@@ -3519,6 +3901,13 @@ func (s *server) peerConnected(conn net.Conn, connReq *connmgr.ConnReq,
 		Quit:                   s.quit,
 	}
 
+	if s.onionMessenger != nil {
+		pCfg.HandleOnionMessage = s.onionMessenger.HandleMessage
+	}
+	if s.peerBackupPusher != nil {
+		pCfg.HandlePeerBackup = s.handlePeerBackup
+	}
+
 	copy(pCfg.PubKeyBytes[:], peerAddr.IdentityKey.SerializeCompressed())
 	copy(pCfg.ServerPubKey[:], s.identityECDH.PubKey().SerializeCompressed())
 
@@ -3620,6 +4009,16 @@ func (s *server) peerInitializer(p *peer.Brontide) {
 	// was successful, and to begin watching the peer's wait group.
 	close(ready)
 
+	// Now that the peer is active, we'll hand it the latest backup of the
+	// channels we have with it.
+	if s.peerBackupPusher != nil {
+		err := s.peerBackupPusher.PushPeerBackup(p.IdentityKey())
+		if err != nil {
+			srvrLog.Errorf("Unable to push channel backup to %v: %v",
+				p, err)
+		}
+	}
+
 	pubStr := string(p.IdentityKey().SerializeCompressed())
 
 	s.mu.Lock()
@@ -4216,6 +4615,39 @@ func parseHexColor(colorStr string) (color.RGBA, error) {
 	return color.RGBA{R: colorBytes[0], G: colorBytes[1], B: colorBytes[2]}, nil
 }
 
+// seedMissionControl merges the mission control export at the given path into
+// mission control, using the named merge strategy.
+func seedMissionControl(mc *routing.MissionControl, path,
+	strategyName string) error {
+
+	strategy, err := routing.ParseMergeStrategy(strategyName)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	snapshot, err := routing.ReadMissionControlSnapshot(file)
+	if err != nil {
+		return err
+	}
+
+	merged, err := mc.MergeHistory(snapshot, strategy)
+	if err != nil {
+		return err
+	}
+
+	srvrLog.Infof("Seeded mission control from %v: merged %d of %d "+
+		"pairs using strategy %v", path, merged, len(snapshot.Pairs),
+		strategy)
+
+	return nil
+}
+
 // computeNextBackoff uses a truncated exponential backoff to compute the next
 // backoff using the value of the exiting backoff. The returned duration is
 // randomized in either direction by 1/20 to prevent tight loops from
@@ -23,6 +23,7 @@ import (
 	"github.com/brsuite/broln/lnwire"
 	"github.com/brsuite/broln/macaroons"
 	"github.com/brsuite/broln/netann"
+	"github.com/brsuite/broln/offers"
 	"github.com/brsuite/broln/routing"
 	"github.com/brsuite/broln/sweep"
 	"github.com/brsuite/broln/watchtower"
@@ -101,6 +102,8 @@ func (s *subRPCServerConfigs) PopulateDependencies(cfg *Config,
 	tcpResolver lncfg.TCPResolver,
 	genInvoiceFeatures func() *lnwire.FeatureVector,
 	genAmpInvoiceFeatures func() *lnwire.FeatureVector,
+	offersMgr *offers.Manager,
+	prober *routing.Prober,
 	rpcLogger btclog.Logger) error {
 
 	// First, we'll use reflect to obtain a version of the config struct
@@ -281,6 +284,8 @@ func (s *subRPCServerConfigs) PopulateDependencies(cfg *Config,
 	s.RouterRPC.MacService = macService
 	s.RouterRPC.Router = chanRouter
 	s.RouterRPC.RouterBackend = routerBackend
+	s.RouterRPC.OffersManager = offersMgr
+	s.RouterRPC.Prober = prober
 
 	return nil
 }
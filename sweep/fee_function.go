@@ -0,0 +1,73 @@
+package sweep
+
+import (
+	"fmt"
+
+	"github.com/brsuite/broln/lnwallet/chainfee"
+)
+
+// FeeFunction defines an interface that is used to determine the fee rate of
+// a time sensitive input at a given block height. Implementations are
+// expected to never decrease the fee rate as the height increases, so that
+// each new sweep transaction is able to replace the previous one.
+type FeeFunction interface {
+	// FeeRate returns the fee rate that should be used to sweep the input
+	// at the given block height.
+	FeeRate(height int32) chainfee.SatPerKWeight
+}
+
+// LinearFeeFunction is a FeeFunction that raises the fee rate linearly from
+// a starting fee rate at the height the input was offered, to an ending fee
+// rate at the deadline height. Once the deadline is reached, the ending fee
+// rate is used.
+type LinearFeeFunction struct {
+	startFeeRate chainfee.SatPerKWeight
+	endFeeRate   chainfee.SatPerKWeight
+
+	startHeight    int32
+	deadlineHeight int32
+}
+
+// A compile-time check to ensure LinearFeeFunction implements the FeeFunction
+// interface.
+var _ FeeFunction = (*LinearFeeFunction)(nil)
+
+// NewLinearFeeFunction creates a new linear fee function that starts at
+// startFeeRate at the given start height and reaches endFeeRate at the
+// deadline height.
+func NewLinearFeeFunction(startFeeRate, endFeeRate chainfee.SatPerKWeight,
+	startHeight, deadlineHeight int32) (*LinearFeeFunction, error) {
+
+	if endFeeRate < startFeeRate {
+		return nil, fmt.Errorf("ending fee rate %v is below starting "+
+			"fee rate %v", endFeeRate, startFeeRate)
+	}
+
+	return &LinearFeeFunction{
+		startFeeRate:   startFeeRate,
+		endFeeRate:     endFeeRate,
+		startHeight:    startHeight,
+		deadlineHeight: deadlineHeight,
+	}, nil
+}
+
+// FeeRate returns the fee rate that should be used to sweep the input at the
+// given block height.
+//
+// NOTE: Part of the FeeFunction interface.
+func (l *LinearFeeFunction) FeeRate(height int32) chainfee.SatPerKWeight {
+	switch {
+	// Once the deadline is reached, or if the input was offered past its
+	// deadline, we'll pay as much as we're allowed to.
+	case height >= l.deadlineHeight:
+		return l.endFeeRate
+
+	case height <= l.startHeight:
+		return l.startFeeRate
+	}
+
+	elapsed := chainfee.SatPerKWeight(height - l.startHeight)
+	total := chainfee.SatPerKWeight(l.deadlineHeight - l.startHeight)
+
+	return l.startFeeRate + (l.endFeeRate-l.startFeeRate)*elapsed/total
+}
@@ -0,0 +1,46 @@
+package sweep
+
+import (
+	"testing"
+
+	"github.com/brsuite/broln/lnwallet/chainfee"
+	"github.com/stretchr/testify/require"
+)
+
+// TestLinearFeeFunction asserts that the linear fee function raises the fee
+// rate from the starting to the ending fee rate as the deadline approaches.
+func TestLinearFeeFunction(t *testing.T) {
+	t.Parallel()
+
+	// An ending fee rate below the starting fee rate is invalid.
+	_, err := NewLinearFeeFunction(2000, 1000, 100, 110)
+	require.Error(t, err)
+
+	feeFunc, err := NewLinearFeeFunction(1000, 2000, 100, 110)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		height  int32
+		feeRate chainfee.SatPerKWeight
+	}{
+		{height: 90, feeRate: 1000},
+		{height: 100, feeRate: 1000},
+		{height: 101, feeRate: 1100},
+		{height: 105, feeRate: 1500},
+		{height: 109, feeRate: 1900},
+		{height: 110, feeRate: 2000},
+		{height: 200, feeRate: 2000},
+	}
+	for _, test := range testCases {
+		require.Equal(
+			t, test.feeRate, feeFunc.FeeRate(test.height),
+			"height %v", test.height,
+		)
+	}
+
+	// An input offered past its deadline should immediately use the
+	// ending fee rate.
+	feeFunc, err = NewLinearFeeFunction(1000, 2000, 120, 110)
+	require.NoError(t, err)
+	require.Equal(t, chainfee.SatPerKWeight(2000), feeFunc.FeeRate(120))
+}
@@ -52,6 +52,11 @@ var (
 	// request from a client whom did not specify a fee preference.
 	ErrNoFeePreference = errors.New("no fee preference specified")
 
+	// ErrNoBudget is returned when a client requests an input to be swept
+	// before a deadline, without specifying the budget that may be spent
+	// on fees to meet it.
+	ErrNoBudget = errors.New("deadline specified without budget")
+
 	// ErrExclusiveGroupSpend is returned in case a different input of the
 	// same exclusive group was spent.
 	ErrExclusiveGroupSpend = errors.New("other member of exclusive group " +
@@ -82,6 +87,17 @@ type Params struct {
 	// ExclusiveGroup is an identifier that, if set, prevents other inputs
 	// with the same identifier from being batched together.
 	ExclusiveGroup *uint64
+
+	// DeadlineHeight, if non-zero, is the block height by which the input
+	// needs to be confirmed. The fee rate of the input is raised every
+	// block as the deadline approaches, starting at the fee rate of the fee
+	// preference and ending at the highest fee rate the budget allows.
+	DeadlineHeight int32
+
+	// Budget, if non-zero, is the maximum amount of fees the input is
+	// allowed to pay for when swept on its own. It must be set if a
+	// deadline height is specified.
+	Budget bronutil.Amount
 }
 
 // ParamsUpdate contains a new set of parameters to update a pending sweep with.
@@ -98,8 +114,9 @@ type ParamsUpdate struct {
 
 // String returns a human readable interpretation of the sweep parameters.
 func (p Params) String() string {
-	return fmt.Sprintf("fee=%v, force=%v, exclusive_group=%v",
-		p.Fee, p.Force, p.ExclusiveGroup)
+	return fmt.Sprintf("fee=%v, force=%v, exclusive_group=%v, "+
+		"deadline_height=%v, budget=%v", p.Fee, p.Force,
+		p.ExclusiveGroup, p.DeadlineHeight, p.Budget)
 }
 
 // pendingInput is created when an input reaches the main loop for the first
@@ -130,6 +147,12 @@ type pendingInput struct {
 	// lastFeeRate is the most recent fee rate used for this input within a
 	// transaction broadcast to the network.
 	lastFeeRate chainfee.SatPerKWeight
+
+	// feeFunc is the fee function used to raise the fee rate of the input
+	// as its deadline approaches. It is created the first time a fee rate
+	// is determined for an input with a deadline, and reset whenever the
+	// sweep parameters change.
+	feeFunc FeeFunction
 }
 
 // parameters returns the sweep parameters for this input.
@@ -460,6 +483,11 @@ func (s *UtxoSweeper) SweepInput(input input.Input,
 		return nil, err
 	}
 
+	// A deadline can only be met if we know how much we may spend on it.
+	if params.DeadlineHeight != 0 && params.Budget == 0 {
+		return nil, ErrNoBudget
+	}
+
 	absoluteTimeLock, _ := input.RequiredLockTime()
 	log.Infof("Sweep request received: out_point=%v, witness_type=%v, "+
 		"relative_time_lock=%v, absolute_time_lock=%v, amount=%v, "+
@@ -510,6 +538,108 @@ func (s *UtxoSweeper) feeRateForPreference(
 	return feeRate, nil
 }
 
+// feeRateForInput returns the fee rate the given input should be swept at,
+// at the given height. Inputs without a budget simply use the fee rate of
+// their fee preference. For inputs with a budget, the fee rate is capped to
+// what the budget allows, and for inputs that also specify a deadline, the
+// fee rate is raised towards that cap as the deadline approaches.
+func (s *UtxoSweeper) feeRateForInput(input *pendingInput,
+	currentHeight int32) (chainfee.SatPerKWeight, error) {
+
+	feeRate, err := s.feeRateForPreference(input.params.Fee)
+	if err != nil {
+		return 0, err
+	}
+
+	if input.params.Budget == 0 {
+		return feeRate, nil
+	}
+
+	maxFeeRate, err := s.budgetFeeRate(input)
+	if err != nil {
+		return 0, err
+	}
+
+	// Without a deadline, the budget only acts as a cap on the fee rate.
+	if input.params.DeadlineHeight == 0 {
+		if feeRate > maxFeeRate {
+			feeRate = maxFeeRate
+		}
+
+		return feeRate, nil
+	}
+
+	// Set up the fee function the first time we're asked for the fee rate
+	// of this input, starting from the current fee rate of its fee
+	// preference.
+	if input.feeFunc == nil {
+		startFeeRate := feeRate
+		if startFeeRate > maxFeeRate {
+			startFeeRate = maxFeeRate
+		}
+
+		feeFunc, err := NewLinearFeeFunction(
+			startFeeRate, maxFeeRate, currentHeight,
+			input.params.DeadlineHeight,
+		)
+		if err != nil {
+			return 0, err
+		}
+		input.feeFunc = feeFunc
+	}
+
+	// The fee estimate may have risen faster than the fee function, in
+	// which case we'll follow the estimate up to the budget instead.
+	deadlineFeeRate := input.feeFunc.FeeRate(currentHeight)
+	if feeRate < deadlineFeeRate {
+		feeRate = deadlineFeeRate
+	}
+	if feeRate > maxFeeRate {
+		feeRate = maxFeeRate
+	}
+
+	return feeRate, nil
+}
+
+// budgetFeeRate returns the highest fee rate the given input can be swept at
+// without exceeding its budget, assuming it is swept on its own. If the input
+// has an unconfirmed parent, the fee rate accounts for paying for the parent
+// as well. If the budget doesn't even cover the relay fee, the relay fee rate
+// is returned instead, as the input couldn't be swept at all otherwise.
+func (s *UtxoSweeper) budgetFeeRate(
+	pi *pendingInput) (chainfee.SatPerKWeight, error) {
+
+	var estimator input.TxWeightEstimator
+	err := pi.WitnessType().AddWeightEstimation(&estimator)
+	if err != nil {
+		return 0, err
+	}
+	estimator.AddP2WKHOutput()
+
+	weight := int64(estimator.Weight())
+	fee := pi.params.Budget
+	if parentTx := pi.UnconfParent(); parentTx != nil {
+		weight += parentTx.Weight
+		fee += parentTx.Fee
+	}
+
+	feeRate := chainfee.SatPerKWeight(fee*1000) /
+		chainfee.SatPerKWeight(weight)
+	if feeRate < s.relayFeeRate {
+		log.Warnf("Budget %v of input %v results in fee rate %v below "+
+			"the minimum of %v, sweeping at the minimum instead",
+			pi.params.Budget, pi.OutPoint(), feeRate,
+			s.relayFeeRate)
+
+		feeRate = s.relayFeeRate
+	}
+	if feeRate > s.cfg.MaxFeeRate {
+		feeRate = s.cfg.MaxFeeRate
+	}
+
+	return feeRate, nil
+}
+
 // collector is the sweeper main loop. It processes new inputs, spend
 // notifications and counts down to publication of the sweep tx.
 func (s *UtxoSweeper) collector(blockEpochs <-chan *chainntnfs.BlockEpoch) {
@@ -553,6 +683,7 @@ func (s *UtxoSweeper) collector(blockEpochs <-chan *chainntnfs.BlockEpoch) {
 				// change to the unconfirmed parent tx info.
 				pendInput.params = input.params
 				pendInput.Input = input.input
+				pendInput.feeFunc = nil
 
 				// Add additional result channel to signal
 				// spend of this input.
@@ -695,7 +826,7 @@ func (s *UtxoSweeper) collector(blockEpochs <-chan *chainntnfs.BlockEpoch) {
 			// this to ensure any inputs which have had their fee
 			// rate bumped are broadcast first in order enforce the
 			// RBF policy.
-			inputClusters := s.createInputClusters()
+			inputClusters := s.createInputClusters(bestHeight)
 			sort.Slice(inputClusters, func(i, j int) bool {
 				return inputClusters[i].sweepFeeRate >
 					inputClusters[j].sweepFeeRate
@@ -802,16 +933,22 @@ func (s *UtxoSweeper) bucketForFeeRate(
 // inputs known by the UtxoSweeper. It clusters inputs by
 // 1) Required tx locktime
 // 2) Similar fee rates
-func (s *UtxoSweeper) createInputClusters() []inputCluster {
+func (s *UtxoSweeper) createInputClusters(
+	currentHeight int32) []inputCluster {
+
 	inputs := s.pendingInputs
 
 	// We start by getting the inputs clusters by locktime. Since the
 	// inputs commit to the locktime, they can only be clustered together
 	// if the locktime is equal.
-	lockTimeClusters, nonLockTimeInputs := s.clusterByLockTime(inputs)
+	lockTimeClusters, nonLockTimeInputs := s.clusterByLockTime(
+		inputs, currentHeight,
+	)
 
 	// Cluster the the remaining inputs by sweep fee rate.
-	feeClusters := s.clusterBySweepFeeRate(nonLockTimeInputs)
+	feeClusters := s.clusterBySweepFeeRate(
+		nonLockTimeInputs, currentHeight,
+	)
 
 	// Since the inputs that we clustered by fee rate don't commit to a
 	// specific locktime, we can try to merge a locktime cluster with a fee
@@ -824,8 +961,8 @@ func (s *UtxoSweeper) createInputClusters() []inputCluster {
 // is determined by calculating the average fee rate of all inputs within that
 // cluster. In addition to the created clusters, inputs that did not specify a
 // required lock time are returned.
-func (s *UtxoSweeper) clusterByLockTime(inputs pendingInputs) ([]inputCluster,
-	pendingInputs) {
+func (s *UtxoSweeper) clusterByLockTime(inputs pendingInputs,
+	currentHeight int32) ([]inputCluster, pendingInputs) {
 
 	locktimes := make(map[uint32]pendingInputs)
 	inputFeeRates := make(map[wire.OutPoint]chainfee.SatPerKWeight)
@@ -849,7 +986,7 @@ func (s *UtxoSweeper) clusterByLockTime(inputs pendingInputs) ([]inputCluster,
 		locktimes[lt] = p
 
 		// We also get the preferred fee rate for this input.
-		feeRate, err := s.feeRateForPreference(input.params.Fee)
+		feeRate, err := s.feeRateForInput(input, currentHeight)
 		if err != nil {
 			log.Warnf("Skipping input %v: %v", op, err)
 			continue
@@ -885,14 +1022,16 @@ func (s *UtxoSweeper) clusterByLockTime(inputs pendingInputs) ([]inputCluster,
 // and clusters those together with similar fee rates. Each cluster contains a
 // sweep fee rate, which is determined by calculating the average fee rate of
 // all inputs within that cluster.
-func (s *UtxoSweeper) clusterBySweepFeeRate(inputs pendingInputs) []inputCluster {
+func (s *UtxoSweeper) clusterBySweepFeeRate(inputs pendingInputs,
+	currentHeight int32) []inputCluster {
+
 	bucketInputs := make(map[int]*bucketList)
 	inputFeeRates := make(map[wire.OutPoint]chainfee.SatPerKWeight)
 
 	// First, we'll group together all inputs with similar fee rates. This
 	// is done by determining the fee rate bucket they should belong in.
 	for op, input := range inputs {
-		feeRate, err := s.feeRateForPreference(input.params.Fee)
+		feeRate, err := s.feeRateForInput(input, currentHeight)
 		if err != nil {
 			log.Warnf("Skipping input %v: %v", op, err)
 			continue
@@ -1060,7 +1199,7 @@ func (s *UtxoSweeper) scheduleSweep(currentHeight int32) error {
 
 	// We'll only start our timer once we have inputs we're able to sweep.
 	startTimer := false
-	for _, cluster := range s.createInputClusters() {
+	for _, cluster := range s.createInputClusters(currentHeight) {
 		// Examine pending inputs and try to construct lists of inputs.
 		// We don't need to obtain the coin selection lock, because we
 		// just need an indication as to whether we can sweep. More
@@ -1271,10 +1410,15 @@ func (s *UtxoSweeper) sweep(inputs inputSet, feeRate chainfee.SatPerKWeight,
 		// We don't care what the result of the publish call was. Even
 		// if it is published successfully, it can still be that it
 		// needs to be retried. Call NextAttemptDeltaFunc to calculate
-		// when to resweep this input.
+		// when to resweep this input. Inputs with a deadline are
+		// retried every block instead, as their fee rate is raised
+		// with every block that passes.
 		nextAttemptDelta := s.cfg.NextAttemptDeltaFunc(
 			pi.publishAttempts,
 		)
+		if pi.params.DeadlineHeight != 0 {
+			nextAttemptDelta = 1
+		}
 
 		pi.minPublishHeight = currentHeight + nextAttemptDelta
 
@@ -1283,7 +1427,12 @@ func (s *UtxoSweeper) sweep(inputs inputSet, feeRate chainfee.SatPerKWeight,
 			pi.publishAttempts, pi.minPublishHeight,
 			nextAttemptDelta)
 
-		if pi.publishAttempts >= s.cfg.MaxSweepAttempts {
+		// Inputs with a deadline are never given up on, as they are
+		// expected to be swept at an increasing fee rate until they
+		// confirm.
+		if pi.publishAttempts >= s.cfg.MaxSweepAttempts &&
+			pi.params.DeadlineHeight == 0 {
+
 			// Signal result channels sweep result.
 			s.signalAndRemove(&input.PreviousOutPoint, Result{
 				Err: ErrTooManyAttempts,
@@ -1389,7 +1538,8 @@ func (s *UtxoSweeper) handlePendingSweepsReq(
 // UtxoSweeper. This function can be used to provide an updated fee preference
 // and force flag that will be used for a new sweep transaction of the input
 // that will act as a replacement transaction (RBF) of the original sweeping
-// transaction, if any. The exclusive group is left unchanged.
+// transaction, if any. The exclusive group, deadline and budget are left
+// unchanged.
 //
 // NOTE: This currently doesn't do any fee rate validation to ensure that a bump
 // is actually successful. The responsibility of doing so should be handled by
@@ -1445,8 +1595,8 @@ func (s *UtxoSweeper) handleUpdateReq(req *updateReq, bestHeight int32) (
 		return nil, lnwallet.ErrNotMine
 	}
 
-	// Create the updated parameters struct. Leave the exclusive group
-	// unchanged.
+	// Create the updated parameters struct. Leave the exclusive group,
+	// deadline and budget unchanged.
 	newParams := pendingInput.params
 	newParams.Fee = req.params.Fee
 	newParams.Force = req.params.Force
@@ -1455,6 +1605,7 @@ func (s *UtxoSweeper) handleUpdateReq(req *updateReq, bestHeight int32) (
 		pendingInput.params, newParams)
 
 	pendingInput.params = newParams
+	pendingInput.feeFunc = nil
 
 	// We'll reset the input's publish height to the current so that a new
 	// transaction can be created that replaces the transaction currently
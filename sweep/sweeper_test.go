@@ -1254,6 +1254,107 @@ func TestBumpFeeRBF(t *testing.T) {
 	ctx.finish(1)
 }
 
+// TestDeadlineFeeBump asserts that the fee rate of an input with a deadline is
+// raised every block as the deadline approaches, up to what its budget allows,
+// and that the sweeper doesn't give up on such an input.
+func TestDeadlineFeeBump(t *testing.T) {
+	ctx := createSweeperTestContext(t)
+
+	feePref := FeePreference{ConfTarget: 6}
+	startFeeRate := chainfee.SatPerKWeight(2000)
+	ctx.estimator.blocksToFee[feePref.ConfTarget] = startFeeRate
+
+	inp := createTestInput(
+		bronutil.SatoshiPerBrocoin, input.CommitmentTimeLock,
+	)
+
+	// A deadline can't be met without a budget.
+	_, err := ctx.sweeper.SweepInput(&inp, Params{
+		Fee:            feePref,
+		DeadlineHeight: mockChainHeight + 10,
+	})
+	require.ErrorIs(t, err, ErrNoBudget)
+
+	// Set the budget such that the input may be swept at up to 12,000
+	// sat/kw when it is swept on its own.
+	var estimator input.TxWeightEstimator
+	require.NoError(t, inp.WitnessType().AddWeightEstimation(&estimator))
+	estimator.AddP2WKHOutput()
+	budget := bronutil.Amount(12 * estimator.Weight())
+
+	sweepResult, err := ctx.sweeper.SweepInput(&inp, Params{
+		Fee:            feePref,
+		DeadlineHeight: mockChainHeight + 10,
+		Budget:         budget,
+	})
+	require.NoError(t, err)
+
+	// The first sweep should use the fee rate of the fee preference.
+	ctx.tick()
+	sweepTx := ctx.receiveTx()
+	assertTxFeeRate(t, &sweepTx, startFeeRate, &inp)
+
+	// With every new block, the input should be swept again at a fee rate
+	// that approaches the budget linearly, without the sweeper giving up
+	// after the maximum number of attempts. Past the deadline, the fee
+	// rate shouldn't exceed the budget.
+	testCases := []struct {
+		height  int32
+		feeRate chainfee.SatPerKWeight
+	}{
+		{height: mockChainHeight + 1, feeRate: 3000},
+		{height: mockChainHeight + 5, feeRate: 7000},
+		{height: mockChainHeight + 10, feeRate: 12000},
+		{height: mockChainHeight + 15, feeRate: 12000},
+	}
+	for _, test := range testCases {
+		ctx.notifier.NotifyEpoch(test.height)
+		ctx.tick()
+		sweepTx := ctx.receiveTx()
+		assertTxFeeRate(t, &sweepTx, test.feeRate, &inp)
+	}
+
+	ctx.backend.mine()
+	ctx.expectResult(sweepResult, nil)
+
+	ctx.finish(1)
+}
+
+// TestBudgetBelowRelayFee asserts that an input whose budget doesn't cover
+// the relay fee is still swept, at the relay fee rate.
+func TestBudgetBelowRelayFee(t *testing.T) {
+	ctx := createSweeperTestContext(t)
+
+	feePref := FeePreference{ConfTarget: 6}
+	ctx.estimator.blocksToFee[feePref.ConfTarget] = 2000
+
+	inp := createTestInput(
+		bronutil.SatoshiPerBrocoin, input.CommitmentTimeLock,
+	)
+	sweepResult, err := ctx.sweeper.SweepInput(&inp, Params{
+		Fee:            feePref,
+		DeadlineHeight: mockChainHeight + 10,
+		Budget:         1,
+	})
+	require.NoError(t, err)
+
+	// The input should be swept at the relay fee rate, also as the
+	// deadline approaches.
+	ctx.tick()
+	sweepTx := ctx.receiveTx()
+	assertTxFeeRate(t, &sweepTx, ctx.sweeper.relayFeeRate, &inp)
+
+	ctx.notifier.NotifyEpoch(mockChainHeight + 5)
+	ctx.tick()
+	sweepTx = ctx.receiveTx()
+	assertTxFeeRate(t, &sweepTx, ctx.sweeper.relayFeeRate, &inp)
+
+	ctx.backend.mine()
+	ctx.expectResult(sweepResult, nil)
+
+	ctx.finish(1)
+}
+
 // TestExclusiveGroup tests the sweeper exclusive group functionality.
 func TestExclusiveGroup(t *testing.T) {
 	ctx := createSweeperTestContext(t)
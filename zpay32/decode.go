@@ -14,6 +14,7 @@ import (
 	"github.com/brsuite/bronutil"
 	"github.com/brsuite/bronutil/bech32"
 	"github.com/brsuite/broln/lnwire"
+	"github.com/brsuite/broln/routing/blindedpath"
 )
 
 // Decode parses the provided encoded invoice and returns a decoded Invoice if
@@ -279,6 +280,17 @@ func parseTaggedFields(invoice *Invoice, fields []byte, net *chaincfg.Params) er
 			}
 
 			invoice.RouteHints = append(invoice.RouteHints, routeHint)
+		case fieldTypeB:
+			// Like route hints, blinded paths may be included
+			// multiple times.
+			path, err := parseBlindedPaymentPath(base32Data)
+			if err != nil {
+				return err
+			}
+
+			invoice.BlindedPaymentPaths = append(
+				invoice.BlindedPaymentPaths, path,
+			)
 		case fieldType9:
 			if invoice.Features != nil {
 				// We skip the field if we have already seen a
@@ -443,6 +455,37 @@ func parseFallbackAddr(data []byte, net *chaincfg.Params) (bronutil.Address, err
 	return addr, nil
 }
 
+// parseBlindedPaymentPath converts the data (encoded in base32) into a blinded
+// path along with its payment information.
+func parseBlindedPaymentPath(data []byte) (*blindedpath.PaymentPath, error) {
+	base256Data, err := bech32.ConvertBits(data, 5, 8, false)
+	if err != nil {
+		return nil, err
+	}
+
+	r := bytes.NewReader(base256Data)
+
+	payInfo := &blindedpath.PayInfo{}
+	if err := payInfo.Decode(r); err != nil {
+		return nil, err
+	}
+
+	path := &blindedpath.BlindedPath{}
+	if err := path.Decode(r); err != nil {
+		return nil, err
+	}
+
+	if r.Len() != 0 {
+		return nil, fmt.Errorf("%d trailing bytes after blinded path",
+			r.Len())
+	}
+
+	return &blindedpath.PaymentPath{
+		PayInfo: payInfo,
+		Path:    path,
+	}, nil
+}
+
 // parseRouteHint converts the data (encoded in base32) into an array containing
 // one or more routing hop hints that represent a single route hint.
 func parseRouteHint(data []byte) ([]HopHint, error) {
@@ -9,6 +9,7 @@ import (
 	"github.com/brsuite/brond/chaincfg"
 	"github.com/brsuite/bronutil"
 	"github.com/brsuite/broln/lnwire"
+	"github.com/brsuite/broln/routing/blindedpath"
 )
 
 const (
@@ -73,6 +74,11 @@ const (
 	// probing the recipient.
 	fieldTypeS = 16
 
+	// fieldTypeB contains a blinded path to the recipient, along with the
+	// information required to pay over it. This field may be repeated to
+	// include multiple blinded paths.
+	fieldTypeB = 20
+
 	// maxInvoiceLength is the maximum total length an invoice can have.
 	// This is chosen to be the maximum number of bytes that can fit into a
 	// single QR code: https://en.wikipedia.org/wiki/QR_code#Storage
@@ -180,6 +186,13 @@ type Invoice struct {
 	// NOTE: This is optional.
 	RouteHints [][]HopHint
 
+	// BlindedPaymentPaths represents one or more blinded paths to the
+	// recipient. If set, senders must pay over one of them, as the
+	// recipient isn't necessarily reachable by its node key.
+	//
+	// NOTE: This is optional.
+	BlindedPaymentPaths []*blindedpath.PaymentPath
+
 	// Features represents an optional field used to signal optional or
 	// required support for features by the receiver.
 	Features *lnwire.FeatureVector
@@ -256,6 +269,14 @@ func RouteHint(routeHint []HopHint) func(*Invoice) {
 	}
 }
 
+// WithBlindedPaymentPath is a functional option that allows callers of
+// NewInvoice to add a blinded path the invoice can be paid over.
+func WithBlindedPaymentPath(path *blindedpath.PaymentPath) func(*Invoice) {
+	return func(i *Invoice) {
+		i.BlindedPaymentPaths = append(i.BlindedPaymentPaths, path)
+	}
+}
+
 // Features is a functional option that allows callers of NewInvoice to set the
 // desired feature bits that are advertised on the invoice. If this option is
 // not used, an empty feature vector will automatically be populated.
@@ -365,6 +386,13 @@ func validateInvoice(invoice *Invoice) error {
 			len(invoice.Destination.SerializeCompressed()))
 	}
 
+	// Route hints are pointless if the recipient can only be reached over
+	// blinded paths.
+	if len(invoice.RouteHints) != 0 && len(invoice.BlindedPaymentPaths) != 0 {
+		return fmt.Errorf("cannot have both route hints and blinded " +
+			"payment paths")
+	}
+
 	// Ensure that all invoices have feature vectors.
 	if invoice.Features == nil {
 		return fmt.Errorf("missing feature vector")
@@ -18,6 +18,7 @@ import (
 	"github.com/brsuite/brond/wire"
 	"github.com/brsuite/bronutil"
 	"github.com/brsuite/broln/lnwire"
+	"github.com/brsuite/broln/routing/blindedpath"
 
 	litecoinCfg "github.com/ltcsuite/ltcd/chaincfg"
 )
@@ -955,6 +956,92 @@ func TestInvoiceChecksumMalleability(t *testing.T) {
 
 }
 
+// TestBlindedPaymentPaths asserts that blinded payment paths survive a round
+// trip through an encoded invoice, and that they can't be mixed with route
+// hints.
+func TestBlindedPaymentPaths(t *testing.T) {
+	t.Parallel()
+
+	path := &blindedpath.PaymentPath{
+		PayInfo: &blindedpath.PayInfo{
+			FeeBaseMsat:               1000,
+			FeeProportionalMillionths: 100,
+			CltvExpiryDelta:           144,
+			HtlcMinimumMsat:           1,
+			HtlcMaximumMsat:           testMillisat20mBTC,
+			Features:                  lnwire.NewRawFeatureVector(),
+		},
+		Path: &blindedpath.BlindedPath{
+			IntroductionPoint: testHopHintPubkey1,
+			BlindingPoint:     testHopHintPubkey2,
+			BlindedHops: []*blindedpath.BlindedHop{
+				{
+					BlindedNodePub: testHopHintPubkey1,
+					CipherText:     []byte{1, 2, 3},
+				},
+				{
+					BlindedNodePub: testPubKey,
+					CipherText:     []byte{4, 5, 6, 7},
+				},
+			},
+		},
+	}
+
+	invoice, err := NewInvoice(&chaincfg.MainNetParams,
+		testPaymentHash, time.Unix(1496314658, 0),
+		Amount(testMillisat20mBTC),
+		Description(testCupOfCoffee),
+		Destination(testPubKey),
+		PaymentAddr(testPaymentAddr),
+		WithBlindedPaymentPath(path),
+		WithBlindedPaymentPath(path),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encoded, err := invoice.Encode(testMessageSigner)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := Decode(encoded, &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(decoded.BlindedPaymentPaths) != 2 {
+		t.Fatalf("expected 2 blinded paths, got %d",
+			len(decoded.BlindedPaymentPaths))
+	}
+	for _, decodedPath := range decoded.BlindedPaymentPaths {
+		if !reflect.DeepEqual(path.PayInfo, decodedPath.PayInfo) {
+			t.Fatalf("expected pay info %v, got %v",
+				path.PayInfo, decodedPath.PayInfo)
+		}
+		if !reflect.DeepEqual(path.Path, decodedPath.Path) {
+			t.Fatalf("expected blinded path %v, got %v",
+				path.Path, decodedPath.Path)
+		}
+	}
+
+	// Re-encoding the decoded invoice must result in the same string.
+	reencoded, err := decoded.Encode(testMessageSigner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reencoded != encoded {
+		t.Fatalf("expected %v, got %v", encoded, reencoded)
+	}
+
+	// Blinded paths and route hints are mutually exclusive.
+	invoice.RouteHints = [][]HopHint{testSingleHop}
+	if _, err := invoice.Encode(testMessageSigner); err == nil {
+		t.Fatalf("expected invoice with route hints and blinded " +
+			"paths to be rejected")
+	}
+}
+
 func compareInvoices(expected, actual *Invoice) error {
 	if !reflect.DeepEqual(expected.Net, actual.Net) {
 		return fmt.Errorf("expected net %v, got %v",